@@ -0,0 +1,9 @@
+// Command pr-review reviews the changes on a branch with a model provider.
+// See the README for its usage.
+package main
+
+import "github.com/marete/pr-review/internal/cli"
+
+func main() {
+	cli.Main()
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// annotationLevel maps a severity to the GitHub Actions workflow command
+// that annotates a line with it
+func annotationLevel(s Severity) string {
+	switch {
+	case s >= SeverityHigh:
+		return "error"
+	case s == SeverityMedium:
+		return "warning"
+	default:
+		return "notice"
+	}
+}
+
+// annotationEscaper escapes a workflow command's message, and
+// annotationPropertyEscaper its properties, which also end at ',' and ':'
+var (
+	annotationEscaper         = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	annotationPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// writeAnnotations writes a review's findings as GitHub Actions workflow
+// commands, which a workflow step's output turns into annotations on the
+// lines of the pull request. Findings in the baseline are left out, and
+// findings without a file annotate the run instead of a line.
+func writeAnnotations(out io.Writer, doc *reviewDocument) error {
+	for _, f := range doc.Findings {
+		if f.Baselined {
+			continue
+		}
+		var props []string
+		if f.File != "" {
+			props = append(props, "file="+annotationPropertyEscaper.Replace(f.File))
+			if f.Line > 0 {
+				props = append(props, fmt.Sprintf("line=%d", f.Line))
+			}
+		}
+		title := fmt.Sprintf("[%s] %s", f.Severity, f.Title)
+		props = append(props, "title="+annotationPropertyEscaper.Replace(title))
+
+		message := f.Title
+		if f.Message != "" {
+			message = f.Message
+		}
+		if f.Suggestion != "" {
+			message += "\n\nSuggestion: " + f.Suggestion
+		}
+		if _, err := fmt.Fprintf(out, "::%s %s::%s\n", annotationLevel(f.Severity), strings.Join(props, ","), annotationEscaper.Replace(message)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestWriteAnnotations tests writing findings as GitHub Actions workflow
+// commands
+func TestWriteAnnotations(t *testing.T) {
+	doc := &reviewDocument{Findings: []Finding{
+		{File: "auth/login.go", Line: 42, Severity: SeverityCritical, Title: "SQL injection", Message: "The query is built with Sprintf.\nUse a placeholder.", Suggestion: "100% safer"},
+		{File: "a,b:c.go", Severity: SeverityMedium, Title: "Odd name"},
+		{Severity: SeverityLow, Title: "No changelog entry"},
+		{File: "cache.go", Line: 7, Severity: SeverityHigh, Title: "Accepted", Baselined: true},
+	}}
+	var b strings.Builder
+	if err := writeAnnotations(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	want := "::error file=auth/login.go,line=42,title=[critical] SQL injection::The query is built with Sprintf.%0AUse a placeholder.%0A%0ASuggestion: 100%25 safer\n" +
+		"::warning file=a%2Cb%3Ac.go,title=[medium] Odd name::Odd name\n" +
+		"::notice title=[low] No changelog entry::No changelog entry\n"
+	if b.String() != want {
+		t.Errorf("writeAnnotations() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
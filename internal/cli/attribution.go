@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// blameLine is the commit git blame attributes one line to
+type blameLine struct {
+	SHA      string
+	Subject  string
+	Boundary bool // the line predates the range
+}
+
+// attributeFindings sets the commit that introduced each finding's lines,
+// found by blaming them over base..head, so authors of a stack of commits
+// know which one to amend. Lines from several commits are attributed to
+// the one that touched the most of them (the newest on a tie), and lines
+// older than the range aren't attributed. With a single commit in the
+// range there is nothing to tell apart, so nothing is attributed. It
+// returns the number of findings attributed.
+func attributeFindings(findings []Finding, base, head string) int {
+	count, err := gitCommand("rev-list", "--count", base+".."+head).Output()
+	if err != nil {
+		return 0
+	}
+	if n, _ := strconv.Atoi(strings.TrimSpace(string(count))); n < 2 {
+		return 0
+	}
+
+	attributed := 0
+	for i := range findings {
+		f := &findings[i]
+		if f.File == "" || f.Line <= 0 {
+			continue
+		}
+		end := max(f.EndLine, f.Line)
+		lines, err := blameRange(base, head, f.File, f.Line, end)
+		if err != nil {
+			continue
+		}
+		if c := dominantCommit(lines); c != nil {
+			f.Commit, f.CommitSubject = c.SHA, c.Subject
+			attributed++
+		}
+	}
+	return attributed
+}
+
+// blameRange blames lines start to end of file at head, stopping at base
+func blameRange(base, head, file string, start, end int) ([]blameLine, error) {
+	output, err := gitCommand("blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", start, end),
+		base+".."+head, "--", file).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to blame %s: %w", file, err)
+	}
+	return parseBlamePorcelain(string(output)), nil
+}
+
+// parseBlamePorcelain reads the commit of each line from git blame
+// --porcelain output. A commit's details are only given the first time it
+// appears.
+func parseBlamePorcelain(output string) []blameLine {
+	commits := map[string]*blameLine{}
+	var lines []blameLine
+	var current *blameLine
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			// The line's content ends its entry
+			if current != nil {
+				lines = append(lines, *current)
+			}
+		case current != nil && strings.HasPrefix(line, "summary "):
+			current.Subject = strings.TrimPrefix(line, "summary ")
+		case current != nil && line == "boundary":
+			current.Boundary = true
+		default:
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && len(fields[0]) == 40 {
+				sha := fields[0]
+				if commits[sha] == nil {
+					commits[sha] = &blameLine{SHA: sha}
+				}
+				current = commits[sha]
+			}
+		}
+	}
+	return lines
+}
+
+// dominantCommit returns the commit in the range blamed for the most lines,
+// or nil if every line predates the range. Ties go to the commit of the
+// later line, which in a stack is usually the newer commit.
+func dominantCommit(lines []blameLine) *blameLine {
+	counts := map[string]int{}
+	var best *blameLine
+	for i := range lines {
+		l := &lines[i]
+		if l.Boundary || strings.Trim(l.SHA, "0") == "" {
+			continue
+		}
+		counts[l.SHA]++
+		if best == nil || counts[l.SHA] >= counts[best.SHA] {
+			best = l
+		}
+	}
+	return best
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAttributeFindings tests that findings are attributed to the commit in
+// the range that introduced their lines
+func TestAttributeFindings(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := gitCommand(args...)
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "app.go"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q", "-b", "main")
+	write("package app\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	git("checkout", "-q", "-b", "stack")
+	write("package app\n\nfunc Open() {}\n")
+	git("commit", "-q", "-am", "add Open")
+	write("package app\n\nfunc Open() {}\n\nfunc Close() {}\n")
+	git("commit", "-q", "-am", "add Close")
+
+	findings := []Finding{
+		{File: "app.go", Line: 3, Title: "Open ignores errors"},
+		{File: "app.go", Line: 5, Title: "Close leaks"},
+		{File: "app.go", Line: 1, Title: "Package predates the range"},
+		{File: "missing.go", Line: 1, Title: "File not in the tree"},
+		{Title: "No location"},
+	}
+	if n := attributeFindings(findings, "main", "HEAD"); n != 2 {
+		t.Errorf("attributeFindings() = %d, want 2", n)
+	}
+	if findings[0].CommitSubject != "add Open" || len(findings[0].Commit) != 40 {
+		t.Errorf("findings[0] attributed to %q %q, want add Open", findings[0].Commit, findings[0].CommitSubject)
+	}
+	if findings[1].CommitSubject != "add Close" {
+		t.Errorf("findings[1] attributed to %q, want add Close", findings[1].CommitSubject)
+	}
+	for _, f := range findings[2:] {
+		if f.Commit != "" {
+			t.Errorf("%q attributed to %q, want none", f.Title, f.CommitSubject)
+		}
+	}
+
+	rendered := renderFindings(findings[:1], groupBySeverity)
+	if !strings.Contains(rendered, "Introduced in: `"+shortSHA(findings[0].Commit)+"` add Open") {
+		t.Errorf("renderFindings() doesn't name the commit:\n%s", rendered)
+	}
+
+	// A single commit needs no attribution
+	single := []Finding{{File: "app.go", Line: 5}}
+	if n := attributeFindings(single, "HEAD~1", "HEAD"); n != 0 || single[0].Commit != "" {
+		t.Errorf("attributeFindings() of a single commit = %d, want 0", n)
+	}
+}
+
+// TestDominantCommit tests that the commit blamed for most lines wins
+func TestDominantCommit(t *testing.T) {
+	a := blameLine{SHA: strings.Repeat("a", 40)}
+	b := blameLine{SHA: strings.Repeat("b", 40)}
+	old := blameLine{SHA: strings.Repeat("c", 40), Boundary: true}
+
+	tests := []struct {
+		name  string
+		lines []blameLine
+		want  string
+	}{
+		{"majority", []blameLine{a, b, a}, a.SHA},
+		{"tie goes to the later line", []blameLine{a, b}, b.SHA},
+		{"boundary ignored", []blameLine{old, old, a}, a.SHA},
+		{"all boundary", []blameLine{old}, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if c := dominantCommit(tt.lines); c != nil {
+			got = c.SHA
+		}
+		if got != tt.want {
+			t.Errorf("%s: dominantCommit() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
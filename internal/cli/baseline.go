@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// baselineFile records the findings a team has triaged and accepted, at the
+// top level of the repository, so reviews and their gate only flag new ones
+const baselineFile = ".pr-review-baseline.json"
+
+// baselineVersion is the format of the baseline file
+const baselineVersion = 1
+
+// Baseline is the set of known findings in baselineFile
+type Baseline struct {
+	Version  int             `json:"version"`
+	Findings []baselineEntry `json:"findings"`
+}
+
+// baselineEntry is a known finding. The fingerprint identifies it; the rest
+// is for people reading the file, and lets a finding the model words a
+// little differently still match.
+type baselineEntry struct {
+	Fingerprint string   `json:"fingerprint"`
+	File        string   `json:"file,omitempty"`
+	Line        int      `json:"line,omitempty"`
+	Severity    Severity `json:"severity"`
+	Category    string   `json:"category,omitempty"`
+	Title       string   `json:"title"`
+
+	// Note records why the finding was accepted; it is kept when the
+	// baseline is updated
+	Note string `json:"note,omitempty"`
+}
+
+// loadBaseline reads a baseline file, returning nil if it doesn't exist
+func loadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	if b.Version != baselineVersion {
+		return nil, fmt.Errorf("%s has version %d; this version of pr-review reads version %d", path, b.Version, baselineVersion)
+	}
+	return &b, nil
+}
+
+// known returns the baseline entry matching a finding, or nil. A finding
+// matches an entry with its fingerprint, or in the same file and category
+// with mostly the same title.
+func (b *Baseline) known(f Finding) *baselineEntry {
+	if b == nil {
+		return nil
+	}
+	fp := f.Fingerprint()
+	for i, e := range b.Findings {
+		if e.Fingerprint == fp {
+			return &b.Findings[i]
+		}
+	}
+	for i, e := range b.Findings {
+		if e.File == f.File && strings.EqualFold(e.Category, f.Category) &&
+			titleOverlap(e.Title, f.Title) >= ensembleTitleOverlap {
+			return &b.Findings[i]
+		}
+	}
+	return nil
+}
+
+// split separates the findings not in the baseline from the known ones,
+// which are marked Baselined
+func (b *Baseline) split(findings []Finding) (fresh, known []Finding) {
+	for _, f := range findings {
+		f.Baselined = b.known(f) != nil
+		if f.Baselined {
+			known = append(known, f)
+		} else {
+			fresh = append(fresh, f)
+		}
+	}
+	return fresh, known
+}
+
+// newBaseline records findings as known, keeping the notes of the entries
+// of previous they match
+func newBaseline(findings []Finding, previous *Baseline) *Baseline {
+	b := &Baseline{Version: baselineVersion, Findings: []baselineEntry{}}
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		e := baselineEntry{
+			Fingerprint: f.Fingerprint(),
+			File:        f.File,
+			Line:        f.Line,
+			Severity:    f.Severity,
+			Category:    f.Category,
+			Title:       f.Title,
+		}
+		if seen[e.Fingerprint] {
+			continue
+		}
+		seen[e.Fingerprint] = true
+		if old := previous.known(f); old != nil {
+			e.Note = old.Note
+		}
+		b.Findings = append(b.Findings, e)
+	}
+	// A stable order keeps the file's diffs readable
+	sort.Slice(b.Findings, func(i, j int) bool {
+		a, c := b.Findings[i], b.Findings[j]
+		if a.File != c.File {
+			return a.File < c.File
+		}
+		if a.Line != c.Line {
+			return a.Line < c.Line
+		}
+		return a.Fingerprint < c.Fingerprint
+	})
+	return b
+}
+
+// write saves the baseline to path
+func (b *Baseline) write(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// applyBaseline leaves the findings in the baseline of the repository at
+// root out of findings, returning the new findings and the known ones.
+// Outside a repository, with root "", there is no baseline.
+func applyBaseline(root string, findings []Finding) (fresh, known []Finding) {
+	if root == "" {
+		return findings, nil
+	}
+	b, err := loadBaseline(filepath.Join(root, baselineFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not load the baseline: %v\n", err)
+		return findings, nil
+	}
+	if b == nil {
+		return findings, nil
+	}
+	fresh, known = b.split(findings)
+	if len(known) > 0 {
+		fmt.Println("🙈 " + tr("Leaving out %s in %s", trPlural(len(known), "known finding"), baselineFile))
+	}
+	return fresh, known
+}
+
+// runBaseline implements `pr-review baseline update`
+func runBaseline(args []string) {
+	if len(args) == 0 || args[0] != "update" {
+		fmt.Fprintln(os.Stderr, "Usage: pr-review baseline update [-base ref] [-head commit] [-from review.json]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("baseline update", flag.ExitOnError)
+	addPathFlags(fs)
+	base := fs.String("base", "", "Base branch/commit the review compared against (default: main or master)")
+	head := fs.String("head", "HEAD", "Commit that was reviewed")
+	from := fs.String("from", "", "Take the findings from this JSON review (-format json output) instead of the review history")
+	parseFlags(fs, fs.Name(), args[1:])
+
+	findings, source, err := baselineFindings(*base, *head, *from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	path := filepath.Join(getRepoRoot(), baselineFile)
+	previous, err := loadBaseline(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	b := newBaseline(findings, previous)
+	if err := b.write(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing baseline: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s with %s from %s\n", path, plural(len(b.Findings), "known finding"), source)
+}
+
+// baselineFindings returns the findings to baseline: those of a JSON
+// review, or of the latest review of head against base in the history
+func baselineFindings(base, head, from string) ([]Finding, string, error) {
+	if from != "" {
+		data, err := os.ReadFile(from)
+		if err != nil {
+			return nil, "", err
+		}
+		var doc reviewDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, "", fmt.Errorf("error parsing %s: %w", from, err)
+		}
+		return doc.Findings, from, nil
+	}
+
+	if base == "" {
+		base = getDefaultBranch()
+	}
+	if err := checkRefs(base, head); err != nil {
+		return nil, "", err
+	}
+	repo, baseSHA, headSHA := getRepoIdentity(), resolveRef(base), resolveRef(head)
+	history, err := openHistoryFor(repo)
+	if err != nil {
+		return nil, "", fmt.Errorf("error opening review history: %w", err)
+	}
+	defer history.Close()
+	record, err := history.FindLatest(repo, baseSHA, headSHA)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading review history: %w", err)
+	}
+	if record == nil {
+		return nil, "", fmt.Errorf("%s hasn't been reviewed against %s; run pr-review first, or use -from", shortSHA(headSHA), base)
+	}
+	return record.Findings, "the review of " + shortSHA(headSHA), nil
+}
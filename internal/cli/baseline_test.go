@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBaseline_Split tests recognizing known findings, including ones the
+// model words differently or that moved
+func TestBaseline_Split(t *testing.T) {
+	accepted := []Finding{
+		{File: "db/query.go", Line: 40, Severity: SeverityHigh, Category: "security", Title: "SQL built from user input",
+			Evidence: "+\tq := \"SELECT * FROM users WHERE id = \" + id"},
+		{File: "api/handler.go", Line: 12, Severity: SeverityLow, Category: "style", Title: "Handler name is unclear"},
+	}
+	b := newBaseline(accepted, nil)
+
+	findings := []Finding{
+		// Moved, with the same evidence up to whitespace
+		{File: "db/query.go", Line: 55, Severity: SeverityHigh, Category: "security", Title: "Possible SQL injection",
+			Evidence: "+ q := \"SELECT * FROM users WHERE id = \"  + id"},
+		// Worded a little differently
+		{File: "api/handler.go", Line: 14, Severity: SeverityLow, Category: "style", Title: "The handler name is unclear"},
+		// New
+		{File: "api/handler.go", Line: 30, Severity: SeverityMedium, Category: "bug", Title: "Error is ignored"},
+	}
+	fresh, known := b.split(findings)
+	if len(fresh) != 1 || fresh[0].Title != "Error is ignored" || fresh[0].Baselined {
+		t.Errorf("fresh = %+v", fresh)
+	}
+	if len(known) != 2 || !known[0].Baselined || !known[1].Baselined {
+		t.Errorf("known = %+v", known)
+	}
+
+	var none *Baseline
+	if fresh, known := none.split(findings); len(fresh) != 3 || known != nil {
+		t.Errorf("split() without a baseline = %d new, %d known", len(fresh), len(known))
+	}
+}
+
+// TestBaseline_Update tests regenerating the baseline file, keeping the
+// notes of the findings still in it
+func TestBaseline_Update(t *testing.T) {
+	path := filepath.Join(t.TempDir(), baselineFile)
+	if b, err := loadBaseline(path); b != nil || err != nil {
+		t.Fatalf("loadBaseline() of a missing file = %v, %v", b, err)
+	}
+
+	kept := Finding{File: "main.go", Line: 3, Severity: SeverityMedium, Category: "bug", Title: "Unchecked error"}
+	dropped := Finding{File: "util.go", Line: 9, Severity: SeverityLow, Title: "Dead code"}
+	b := newBaseline([]Finding{dropped, kept, kept}, nil)
+	if len(b.Findings) != 2 || b.Findings[0].File != "main.go" {
+		t.Fatalf("newBaseline() = %+v; want two entries sorted by file", b.Findings)
+	}
+	b.Findings[0].Note = "Checked by the caller"
+	if err := b.write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	previous, err := loadBaseline(path)
+	if err != nil {
+		t.Fatalf("loadBaseline() returned error: %v", err)
+	}
+	added := Finding{File: "api.go", Line: 1, Severity: SeverityHigh, Title: "Token logged"}
+	b = newBaseline([]Finding{kept, added}, previous)
+	if len(b.Findings) != 2 || b.Findings[1].Note != "Checked by the caller" || b.Findings[0].Note != "" {
+		t.Errorf("updated baseline = %+v", b.Findings)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 2, "findings": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBaseline(path); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("loadBaseline() of a newer version = %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// benchKey identifies one measured quantity of one benchmark, e.g.
+// BenchmarkParse-8 in ns/op
+type benchKey struct {
+	Name string
+	Unit string
+}
+
+// benchResults holds every sample from a Go benchmark output file, keeping
+// benchmarks in the order they first appeared
+type benchResults struct {
+	keys    []benchKey
+	samples map[benchKey][]float64
+}
+
+// parseBenchFile reads benchmark results in the format printed by `go test
+// -bench` (and accepted by benchstat)
+func parseBenchFile(path string) (*benchResults, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open benchmark results: %w", err)
+	}
+	defer f.Close()
+
+	results := &benchResults{samples: make(map[benchKey][]float64)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		results.parseLine(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read benchmark results %s: %w", path, err)
+	}
+	if len(results.keys) == 0 {
+		return nil, fmt.Errorf("no benchmark results found in %s", path)
+	}
+	return results, nil
+}
+
+// parseLine records the measurements on a line such as
+// "BenchmarkParse-8   5000   240133 ns/op   1024 B/op   12 allocs/op".
+// Any other line is ignored.
+func (r *benchResults) parseLine(line string) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return
+	}
+	if _, err := strconv.Atoi(fields[1]); err != nil {
+		return
+	}
+	for i := 2; i+1 < len(fields); i += 2 {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return
+		}
+		key := benchKey{Name: fields[0], Unit: fields[i+1]}
+		if _, seen := r.samples[key]; !seen {
+			r.keys = append(r.keys, key)
+		}
+		r.samples[key] = append(r.samples[key], value)
+	}
+}
+
+// benchDelta compares one benchmark quantity between two runs
+type benchDelta struct {
+	Key         benchKey
+	Old, New    float64 // medians
+	OldN, NewN  int
+	Change      float64 // relative change of the median, e.g. 0.12 for +12%
+	P           float64 // p-value of the Mann-Whitney U test; 1 with too few samples
+	Significant bool
+}
+
+// Benchmark changes are significant, as benchstat has it, when each run has
+// at least minBenchSamples samples and the Mann-Whitney U test rejects that
+// they come from the same distribution at benchAlpha
+const (
+	minBenchSamples = 5
+	benchAlpha      = 0.05
+)
+
+// compareBenchmarks pairs up quantities measured in both runs. A change is
+// only reported as significant when both runs have enough samples and a
+// Mann-Whitney U test finds the difference unlikely to be noise, so noise
+// between runs, or a single run of each, isn't presented as a regression.
+func compareBenchmarks(before, after *benchResults) []benchDelta {
+	var deltas []benchDelta
+	for _, key := range before.keys {
+		newSamples, ok := after.samples[key]
+		if !ok {
+			continue
+		}
+		oldSamples := before.samples[key]
+		d := benchDelta{
+			Key:  key,
+			Old:  median(oldSamples),
+			New:  median(newSamples),
+			OldN: len(oldSamples),
+			NewN: len(newSamples),
+		}
+		if d.Old != 0 {
+			d.Change = (d.New - d.Old) / d.Old
+		}
+		d.P = 1
+		if d.OldN >= minBenchSamples && d.NewN >= minBenchSamples {
+			d.P = mannWhitneyU(oldSamples, newSamples)
+		}
+		d.Significant = d.Change != 0 && d.P < benchAlpha
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// formatBenchTable renders benchmark deltas as a markdown table
+func formatBenchTable(deltas []benchDelta) string {
+	if len(deltas) == 0 {
+		return "No benchmarks were present in both the old and new results.\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("| Benchmark | Unit | Old (median) | New (median) | Delta |\n")
+	b.WriteString("|---|---|---:|---:|---:|\n")
+	for _, d := range deltas {
+		delta := "~"
+		if d.Significant {
+			delta = fmt.Sprintf("%+.2f%% (p=%.3f)", d.Change*100, d.P)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s (n=%d) | %s (n=%d) | %s |\n",
+			d.Key.Name, d.Key.Unit, formatBenchValue(d.Old), d.OldN, formatBenchValue(d.New), d.NewN, delta)
+	}
+	fmt.Fprintf(&b, "\n`~` means there is no statistically significant difference (Mann-Whitney U test, p < %.2f, with at least %d samples of each run), so any difference may be noise.\n", benchAlpha, minBenchSamples)
+	return b.String()
+}
+
+// benchmarkSection loads two benchmark runs and renders their comparison
+func benchmarkSection(oldPath, newPath string) (string, error) {
+	before, err := parseBenchFile(oldPath)
+	if err != nil {
+		return "", err
+	}
+	after, err := parseBenchFile(newPath)
+	if err != nil {
+		return "", err
+	}
+	return formatBenchTable(compareBenchmarks(before, after)), nil
+}
+
+// benchmarkInstructions tells the model how to use measured benchmark deltas
+const benchmarkInstructions = `The table below compares benchmarks run before (old) and after (new) this
+change. Ground any performance claims in these measurements: call out
+significant regressions and relate them to the code that causes them, and do
+not speculate about performance where the numbers show no change.
+
+`
+
+func formatBenchValue(v float64) string {
+	if v >= 1000 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U test of
+// whether x and y come from the same distribution. Without ties it is exact
+// for samples of up to 50 each; otherwise it uses the normal approximation,
+// corrected for ties.
+func mannWhitneyU(x, y []float64) float64 {
+	n1, n2 := len(x), len(y)
+	type sample struct {
+		value float64
+		first bool
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].value < all[j].value })
+
+	// Rank the samples, giving tied ones their average rank
+	var rankSum, tieTerm float64
+	ties := false
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].value == all[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].first {
+				rankSum += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieTerm += t*t*t - t
+		}
+		i = j
+	}
+	u := rankSum - float64(n1*(n1+1))/2
+
+	if !ties && n1 <= 50 && n2 <= 50 {
+		return mannWhitneyExact(n1, n2, u)
+	}
+	n := float64(n1 + n2)
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * (n + 1 - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	// Continuity correction towards the mean
+	z := math.Max(math.Abs(u-mean)-0.5, 0) / math.Sqrt(variance)
+	return math.Min(1, math.Erfc(z/math.Sqrt2))
+}
+
+// mannWhitneyExact returns the exact two-sided p-value of U = u for samples
+// of n1 and n2 without ties, counting the orderings of the samples that give
+// each U
+func mannWhitneyExact(n1, n2 int, u float64) float64 {
+	// counts[i][j][k] is the number of orderings of i and j samples with
+	// U = k; only the previous row of i is kept
+	maxU := n1 * n2
+	prev := make([][]float64, n2+1)
+	for j := range prev {
+		prev[j] = make([]float64, maxU+1)
+		prev[j][0] = 1
+	}
+	for i := 1; i <= n1; i++ {
+		cur := make([][]float64, n2+1)
+		cur[0] = make([]float64, maxU+1)
+		cur[0][0] = 1
+		for j := 1; j <= n2; j++ {
+			cur[j] = make([]float64, maxU+1)
+			for k := 0; k <= i*j; k++ {
+				// The largest sample is either one of the first i, which is
+				// then above all j others, or one of the j
+				if k >= j {
+					cur[j][k] += prev[j][k-j]
+				}
+				cur[j][k] += cur[j-1][k]
+			}
+		}
+		prev = cur
+	}
+	counts := prev[n2]
+	var total, below, above float64
+	for k, c := range counts {
+		total += c
+		if float64(k) <= u {
+			below += c
+		}
+		if float64(k) >= u {
+			above += c
+		}
+	}
+	return math.Min(1, 2*math.Min(below, above)/total)
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeBenchFile writes benchmark output to a temporary file
+func writeBenchFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bench.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write benchmark file: %v", err)
+	}
+	return path
+}
+
+// TestParseBenchFile tests parsing go test -bench output
+func TestParseBenchFile(t *testing.T) {
+	path := writeBenchFile(t, `goos: linux
+goarch: amd64
+pkg: example.com/app
+BenchmarkParse-8   	    5000	    240133 ns/op	    1024 B/op	      12 allocs/op
+BenchmarkParse-8   	    5000	    250000 ns/op	    1024 B/op	      12 allocs/op
+BenchmarkEncode-8  	   10000	     12.5 ns/op
+PASS
+ok  	example.com/app	3.2s
+`)
+
+	results, err := parseBenchFile(path)
+	if err != nil {
+		t.Fatalf("parseBenchFile() returned error: %v", err)
+	}
+
+	wantKeys := []benchKey{
+		{"BenchmarkParse-8", "ns/op"},
+		{"BenchmarkParse-8", "B/op"},
+		{"BenchmarkParse-8", "allocs/op"},
+		{"BenchmarkEncode-8", "ns/op"},
+	}
+	if len(results.keys) != len(wantKeys) {
+		t.Fatalf("got keys %v, want %v", results.keys, wantKeys)
+	}
+	for i, k := range wantKeys {
+		if results.keys[i] != k {
+			t.Errorf("key %d = %v, want %v", i, results.keys[i], k)
+		}
+	}
+	if got := results.samples[benchKey{"BenchmarkParse-8", "ns/op"}]; len(got) != 2 || got[1] != 250000 {
+		t.Errorf("ns/op samples = %v", got)
+	}
+}
+
+// TestParseBenchFile_Empty tests that files without benchmarks are rejected
+func TestParseBenchFile_Empty(t *testing.T) {
+	path := writeBenchFile(t, "PASS\nok  \texample.com/app\t0.1s\n")
+	if _, err := parseBenchFile(path); err == nil {
+		t.Error("parseBenchFile() expected error for output without benchmarks")
+	}
+}
+
+// TestCompareBenchmarks tests significance and deltas between runs
+func TestCompareBenchmarks(t *testing.T) {
+	before := &benchResults{samples: map[benchKey][]float64{}}
+	after := &benchResults{samples: map[benchKey][]float64{}}
+	for i, ns := range []int{100, 102, 101, 99, 103} {
+		before.parseLine(fmt.Sprintf("BenchmarkSlow-8 100 %d ns/op %d B/op", ns, 10+i%2))
+	}
+	before.parseLine("BenchmarkGone-8 100 5 ns/op")
+	before.parseLine("BenchmarkOnce-8 100 50 ns/op")
+	for i, ns := range []int{120, 124, 122, 121, 123} {
+		after.parseLine(fmt.Sprintf("BenchmarkSlow-8 100 %d ns/op %d B/op", ns, 11-i%2))
+	}
+	after.parseLine("BenchmarkOnce-8 100 80 ns/op")
+
+	deltas := compareBenchmarks(before, after)
+	if len(deltas) != 3 {
+		t.Fatalf("got %d deltas, want 3 (benchmarks missing from the new run are skipped)", len(deltas))
+	}
+
+	ns := deltas[0]
+	if ns.Key.Unit != "ns/op" || ns.Old != 101 || ns.New != 122 || !ns.Significant {
+		t.Errorf("ns/op delta = %+v", ns)
+	}
+	bytes := deltas[1]
+	if bytes.Key.Unit != "B/op" || bytes.Significant {
+		t.Errorf("B/op delta should overlap and not be significant: %+v", bytes)
+	}
+	// A single sample of each can't tell a change from noise
+	if once := deltas[2]; once.Significant || once.P != 1 {
+		t.Errorf("delta from one sample each = %+v, want not significant", once)
+	}
+
+	table := formatBenchTable(deltas)
+	if !strings.Contains(table, "| BenchmarkSlow-8 | ns/op | 101 (n=5) | 122 (n=5) | +20.79% (p=0.008) |") {
+		t.Errorf("unexpected table:\n%s", table)
+	}
+	if !strings.Contains(table, "| BenchmarkOnce-8 | ns/op | 50 (n=1) | 80 (n=1) | ~ |") {
+		t.Errorf("unexpected table:\n%s", table)
+	}
+}
+
+// TestMannWhitneyU tests exact and approximate p-values
+func TestMannWhitneyU(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		x, y []float64
+		want float64
+	}{
+		// Every sample of y is larger: 2 of the C(10,5) = 252 orderings
+		{"separated", []float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 2.0 / 252},
+		{"interleaved", []float64{1, 3, 5, 7, 9}, []float64{2, 4, 6, 8, 10}, 0.6904761904761905},
+		{"identical", []float64{5, 5, 5, 5, 5}, []float64{5, 5, 5, 5, 5}, 1},
+		// With ties, the normal approximation with continuity correction
+		{"ties", []float64{1, 2, 2, 3, 4, 5}, []float64{3, 4, 5, 5, 6, 7}, 0.042708},
+	} {
+		if got := mannWhitneyU(tc.x, tc.y); math.Abs(got-tc.want) > 1e-4 {
+			t.Errorf("%s: mannWhitneyU() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// bisectFinalists is how many candidates the narrowing rounds stop at;
+	// the final round ranks them with their full diffs
+	bisectFinalists = 3
+
+	// maxBisectCommits bounds the range; larger ranges should be narrowed
+	// with tags or dates first
+	maxBisectCommits = 300
+
+	// maxBisectDiffBytes bounds the diffs included in one round. Rounds
+	// whose candidates have more show only commit subjects and stats.
+	maxBisectDiffBytes = 200 * 1024
+
+	// maxCommitDiffBytes bounds the diff of a single commit in a prompt
+	maxCommitDiffBytes = 40 * 1024
+)
+
+// Tags delimiting the suspect list in a narrowing round's response
+const (
+	suspectsStartTag = "<suspects>"
+	suspectsEndTag   = "</suspects>"
+)
+
+// bisectCommit is a commit in the range being narrowed
+type bisectCommit struct {
+	SHA     string
+	Short   string
+	Author  string
+	Date    string
+	Subject string
+	Stat    string
+}
+
+// bisectRound records how one round narrowed the candidates
+type bisectRound struct {
+	Before, After int
+	WithDiffs     bool
+}
+
+// bisector narrows a commit range down to the commits most likely to cause
+// a symptom by asking the model, round by round, which candidates are most
+// plausible: like git bisect, but without building or running anything
+type bisector struct {
+	client Provider
+	opts   CompletionOptions
+	policy *Policy
+
+	symptom string
+	context string
+
+	// diff returns the patch a commit introduces
+	diff func(sha string) (string, error)
+}
+
+// narrow runs rounds until at most bisectFinalists candidates remain or a
+// round fails to narrow them further
+func (b *bisector) narrow(candidates []bisectCommit) ([]bisectCommit, []bisectRound, Usage, error) {
+	var rounds []bisectRound
+	var usage Usage
+	for len(candidates) > bisectFinalists {
+		keep := max(len(candidates)/2, bisectFinalists)
+		diffs, withDiffs := b.diffs(candidates)
+
+		evidence := "subjects and stats"
+		if withDiffs {
+			evidence = "full diffs"
+		}
+		fmt.Printf("🔎 Round %d: choosing %d of %d candidate commits from their %s...\n",
+			len(rounds)+1, keep, len(candidates), evidence)
+		prompt := b.policy.redact(buildBisectRoundPrompt(b.symptom, candidates, diffs, keep, b.context))
+		response, callUsage, err := b.client.Complete(prompt, b.opts)
+		if err != nil {
+			return candidates, rounds, usage, err
+		}
+		usage.InputTokens += callUsage.InputTokens
+		usage.OutputTokens += callUsage.OutputTokens
+
+		chosen, err := parseSuspects(response, candidates, keep)
+		if err != nil {
+			return candidates, rounds, usage, err
+		}
+		if len(chosen) >= len(candidates) {
+			break
+		}
+		rounds = append(rounds, bisectRound{Before: len(candidates), After: len(chosen), WithDiffs: withDiffs})
+		candidates = chosen
+	}
+	return candidates, rounds, usage, nil
+}
+
+// diffs returns the diffs of all candidates if they fit in one prompt
+func (b *bisector) diffs(candidates []bisectCommit) (map[string]string, bool) {
+	diffs := make(map[string]string, len(candidates))
+	total := 0
+	for _, c := range candidates {
+		diff, err := b.diff(c.SHA)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not get diff of %s: %v\n", c.Short, err)
+			continue
+		}
+		if len(diff) > maxCommitDiffBytes {
+			diff = diff[:maxCommitDiffBytes] + "\n... (diff truncated)\n"
+		}
+		total += len(diff)
+		if total > maxBisectDiffBytes {
+			return nil, false
+		}
+		diffs[c.SHA] = diff
+	}
+	return diffs, true
+}
+
+// bisectCommitList renders candidates, oldest first, with their stats and,
+// if given, their diffs
+func bisectCommitList(candidates []bisectCommit, diffs map[string]string) string {
+	var b strings.Builder
+	for _, c := range candidates {
+		fmt.Fprintf(&b, "### %s %s\n%s, %s\n", c.Short, c.Subject, c.Author, c.Date)
+		if c.Stat != "" {
+			b.WriteString("```\n" + c.Stat + "\n```\n")
+		}
+		if diff, ok := diffs[c.SHA]; ok {
+			b.WriteString("```diff\n" + diff + "\n```\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// buildBisectRoundPrompt asks the model to keep the candidates most likely
+// to cause the symptom
+func buildBisectRoundPrompt(symptom string, candidates []bisectCommit, diffs map[string]string, keep int, additionalContext string) string {
+	prompt := fmt.Sprintf(`You are an expert at tracking down regressions. A bug was introduced somewhere
+in the commit range below. Without building or running anything, pick the %d
+commits that most plausibly cause the symptom, judging by what each commit
+changes. Consider direct causes (changed code on the failing path) and
+indirect ones (configuration, dependencies, shared helpers, error handling).
+
+## Symptom
+
+%s
+
+## Candidate Commits (oldest first)
+
+%s`, keep, symptom, bisectCommitList(candidates, diffs))
+
+	if additionalContext != "" {
+		prompt += "## Additional Context\n" + additionalContext + "\n\n"
+	}
+
+	prompt += fmt.Sprintf(`Briefly explain your reasoning, then end your response with exactly %d
+commits, most plausible first, as a JSON array between %s and %s tags:
+
+%s
+[{"commit": "<short sha>", "reason": "<one sentence>"}]
+%s
+`, keep, suspectsStartTag, suspectsEndTag, suspectsStartTag, suspectsEndTag)
+	return prompt
+}
+
+// buildBisectFinalPrompt asks for a ranked diagnosis of the last candidates
+func buildBisectFinalPrompt(symptom string, candidates []bisectCommit, diffs map[string]string, additionalContext string) string {
+	prompt := `You are an expert at tracking down regressions. A bug was introduced in the
+commit range under investigation, and earlier analysis narrowed it down to
+the candidate commits below. Without building or running anything:
+
+1. **Most Likely Culprit**: Rank the candidates by how plausibly each causes
+   the symptom, naming the specific change (file and hunk) and the causal chain.
+2. **Ruled Out**: Say briefly why the others are less likely.
+3. **How to Confirm**: Give the quickest way to confirm the diagnosis (a test to
+   write, a log line to check, a revert to try).
+4. **Fix**: Propose a fix for the most likely culprit.
+
+If none of the candidates plausibly explains the symptom, say so.
+
+## Symptom
+
+` + symptom + "\n\n## Candidate Commits (oldest first)\n\n" + bisectCommitList(candidates, diffs)
+
+	if additionalContext != "" {
+		prompt += "## Additional Context\n" + additionalContext + "\n\n"
+	}
+	return prompt + "Please provide your diagnosis."
+}
+
+// parseSuspects reads the commits a round chose, in the order given, from
+// the suspects section of a response. Commits are matched to candidates by
+// SHA prefix; unknown and duplicate commits are ignored.
+func parseSuspects(response string, candidates []bisectCommit, keep int) ([]bisectCommit, error) {
+	start := strings.LastIndex(response, suspectsStartTag)
+	if start == -1 {
+		return nil, fmt.Errorf("response has no %s section", suspectsStartTag)
+	}
+	end := strings.Index(response[start:], suspectsEndTag)
+	if end == -1 {
+		return nil, fmt.Errorf("suspects section is not terminated by %s", suspectsEndTag)
+	}
+	body := strings.TrimSpace(response[start+len(suspectsStartTag) : start+end])
+	body = strings.TrimPrefix(body, "```json")
+	body = strings.TrimPrefix(body, "```")
+	body = strings.TrimSuffix(body, "```")
+
+	var suspects []struct {
+		Commit string `json:"commit"`
+	}
+	if err := json.Unmarshal([]byte(body), &suspects); err != nil {
+		return nil, fmt.Errorf("error parsing suspects: %w", err)
+	}
+
+	var chosen []bisectCommit
+	seen := make(map[string]bool)
+	for _, s := range suspects {
+		sha := strings.ToLower(strings.TrimSpace(s.Commit))
+		if len(sha) < 4 {
+			continue
+		}
+		for _, c := range candidates {
+			if strings.HasPrefix(c.SHA, sha) && !seen[c.SHA] {
+				seen[c.SHA] = true
+				chosen = append(chosen, c)
+				break
+			}
+		}
+		if len(chosen) == keep {
+			break
+		}
+	}
+	if len(chosen) == 0 {
+		return nil, fmt.Errorf("none of the suspects are commits in the range")
+	}
+	return chosen, nil
+}
+
+// listBisectCommits returns the commits reachable from bad but not good,
+// oldest first
+func listBisectCommits(good, bad string) ([]bisectCommit, error) {
+	output, err := gitCommand("log", "--reverse", "--no-merges", "--date=short",
+		"--format=%H%x1f%h%x1f%an%x1f%ad%x1f%s", good+".."+bad).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits %s..%s: %w", good, bad, err)
+	}
+
+	var commits []bisectCommit
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		commits = append(commits, bisectCommit{
+			SHA: fields[0], Short: fields[1], Author: fields[2], Date: fields[3], Subject: fields[4],
+		})
+	}
+	if len(commits) > maxBisectCommits {
+		return nil, fmt.Errorf("%s..%s has %d commits; narrow the range to at most %d", good, bad, len(commits), maxBisectCommits)
+	}
+	for i := range commits {
+		stat, err := gitCommand("show", "--stat", "--format=", commits[i].SHA).Output()
+		if err == nil {
+			commits[i].Stat = strings.TrimSpace(string(stat))
+		}
+	}
+	return commits, nil
+}
+
+// commitDiff returns the patch a commit introduces, made safe for the prompt
+func commitDiff(sha string) (string, error) {
+	output, err := gitCommand("show", "--format=", sha).Output()
+	if err != nil {
+		return "", err
+	}
+	diff, _ := sanitizeDiff(string(output))
+	return diff, nil
+}
+
+// runBisect implements `pr-review bisect`, narrowing a commit range to the
+// commits most likely to cause a described symptom
+func runBisect(args []string) {
+	fs := flag.NewFlagSet("bisect", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	good := fs.String("good", "", "Known good commit, tag or branch (required)")
+	bad := fs.String("bad", "HEAD", "Commit where the symptom occurs")
+	symptom := fs.String("symptom", "", "Description of the bug, e.g. \"login returns 500\" (required)")
+	outputFile := fs.String("output", "BISECT.md", "Output file for the report (will create numbered backups if exists)")
+	parseFlags(fs, fs.Name(), args)
+
+	if *good == "" || *symptom == "" {
+		fmt.Fprintln(os.Stderr, "Error: bisect requires -good and -symptom")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	client, policy := common.provider()
+
+	commits, err := listBisectCommits(*good, *bad)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(commits) == 0 {
+		fmt.Printf("No commits between %s and %s.\n", *good, *bad)
+		os.Exit(0)
+	}
+	fmt.Printf("🔍 Narrowing %s in %s..%s for: %s\n\n", plural(len(commits), "commit"), *good, *bad, *symptom)
+
+	b := &bisector{
+		client:  client,
+		opts:    common.completionOptions(),
+		policy:  policy,
+		symptom: *symptom,
+		context: common.readContext(client, policy),
+		diff:    commitDiff,
+	}
+	finalists, rounds, usage, err := b.narrow(commits)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error narrowing commits: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("🤖 Ranking the %s...\n\n", plural(len(finalists), "remaining candidate"))
+	diffs, _ := b.diffs(finalists)
+	prompt := policy.redact(buildBisectFinalPrompt(*symptom, finalists, diffs, b.context))
+	diagnosis, finalUsage, err := client.Complete(prompt, b.opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error calling %s API: %v\n", client.Name(), err)
+		os.Exit(1)
+	}
+	usage.InputTokens += finalUsage.InputTokens
+	usage.OutputTokens += finalUsage.OutputTokens
+
+	report := formatBisectReport(*symptom, *good, *bad, len(commits), rounds, finalists, diagnosis)
+	if err := writeReviewToFile(*outputFile, report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing report to file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Report written to: %s\n\n", *outputFile)
+	common.printTranscript()
+
+	printReport("BISECT DIAGNOSIS", report, usage)
+}
+
+// formatBisectReport renders the narrowing rounds and the final diagnosis
+func formatBisectReport(symptom, good, bad string, total int, rounds []bisectRound, finalists []bisectCommit, diagnosis string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Bisect: %s\n\n", symptom)
+	fmt.Fprintf(&b, "Range `%s..%s` (%s). This analysis is based on the diffs alone; confirm it before acting on it.\n\n",
+		good, bad, plural(total, "commit"))
+	if len(rounds) > 0 {
+		b.WriteString("| Round | Candidates | Kept | Evidence |\n|---:|---:|---:|---|\n")
+		for i, r := range rounds {
+			evidence := "subjects and stats"
+			if r.WithDiffs {
+				evidence = "full diffs"
+			}
+			fmt.Fprintf(&b, "| %d | %d | %d | %s |\n", i+1, r.Before, r.After, evidence)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("## Final Candidates\n\n")
+	for _, c := range finalists {
+		fmt.Fprintf(&b, "- `%s` %s (%s, %s)\n", c.Short, c.Subject, c.Author, c.Date)
+	}
+	b.WriteString("\n## Diagnosis\n\n" + diagnosis + "\n")
+	return b.String()
+}
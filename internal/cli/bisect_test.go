@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func testCommits(n int) []bisectCommit {
+	commits := make([]bisectCommit, n)
+	for i := range commits {
+		sha := fmt.Sprintf("%02x%038d", i, i)
+		commits[i] = bisectCommit{SHA: sha, Short: sha[:7], Subject: fmt.Sprintf("change %d", i)}
+	}
+	return commits
+}
+
+// suspectsResponse is a canned API response choosing the given commits
+func suspectsResponse(commits ...bisectCommit) string {
+	var list []string
+	for _, c := range commits {
+		list = append(list, fmt.Sprintf(`{"commit": %q, "reason": "touches login"}`, c.Short))
+	}
+	text := "Reasoning.\n" + suspectsStartTag + "\n[" + strings.Join(list, ", ") + "]\n" + suspectsEndTag
+	body, _ := json.Marshal(map[string]any{
+		"content":     []map[string]string{{"type": "text", "text": text}},
+		"stop_reason": "end_turn",
+		"usage":       map[string]int{"input_tokens": 1, "output_tokens": 1},
+	})
+	return string(body)
+}
+
+// TestParseSuspects tests matching chosen commits to candidates
+func TestParseSuspects(t *testing.T) {
+	commits := testCommits(6)
+	response := "Thinking it over.\n<suspects>\n```json\n" +
+		fmt.Sprintf(`[{"commit": %q}, {"commit": "deadbeef"}, {"commit": %q}, {"commit": %q}, {"commit": %q}]`,
+			commits[4].Short, strings.ToUpper(commits[1].SHA), commits[4].SHA, commits[0].Short) +
+		"\n```\n</suspects>"
+
+	got, err := parseSuspects(response, commits, 2)
+	if err != nil {
+		t.Fatalf("parseSuspects() returned error: %v", err)
+	}
+	if len(got) != 2 || got[0].SHA != commits[4].SHA || got[1].SHA != commits[1].SHA {
+		t.Errorf("parseSuspects() = %+v, want commits 4 and 1 in that order", got)
+	}
+
+	for _, bad := range []string{"no section", "<suspects>[{\"commit\": \"zzzzzz\"}]</suspects>", "<suspects>not json</suspects>"} {
+		if _, err := parseSuspects(bad, commits, 2); err == nil {
+			t.Errorf("parseSuspects(%q) expected error", bad)
+		}
+	}
+}
+
+// TestBisectorNarrow tests halving the candidates round by round
+func TestBisectorNarrow(t *testing.T) {
+	commits := testCommits(10)
+	fake := &fakeClaude{responses: []string{
+		suspectsResponse(commits[7], commits[2], commits[5], commits[6], commits[9]),
+		suspectsResponse(commits[5], commits[6], commits[7]),
+	}}
+	b := &bisector{
+		client:  fake.serve(t),
+		opts:    CompletionOptions{Model: "m"},
+		symptom: "login returns 500",
+		diff:    func(sha string) (string, error) { return "diff of " + sha[:7], nil },
+	}
+
+	finalists, rounds, _, err := b.narrow(commits)
+	if err != nil {
+		t.Fatalf("narrow() returned error: %v", err)
+	}
+	if len(finalists) != 3 || finalists[0].SHA != commits[5].SHA {
+		t.Errorf("finalists = %+v", finalists)
+	}
+	if len(rounds) != 2 || rounds[0] != (bisectRound{Before: 10, After: 5, WithDiffs: true}) || rounds[1].After != 3 {
+		t.Errorf("rounds = %+v", rounds)
+	}
+	if len(fake.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(fake.requests))
+	}
+	first := fake.requests[0].Messages[0].Content
+	if !strings.Contains(first, "login returns 500") || !strings.Contains(first, "pick the 5") || !strings.Contains(first, "diff of "+commits[3].Short) {
+		t.Errorf("first round prompt missing symptom, count or diffs:\n%s", first)
+	}
+}
+
+// TestBisectorNarrow_LargeDiffs tests that rounds fall back to stats when the
+// candidates' diffs don't fit
+func TestBisectorNarrow_LargeDiffs(t *testing.T) {
+	commits := testCommits(8)
+	fake := &fakeClaude{responses: []string{suspectsResponse(commits[0], commits[1], commits[2], commits[3])}}
+	b := &bisector{
+		client: fake.serve(t),
+		diff:   func(string) (string, error) { return strings.Repeat("x", maxCommitDiffBytes), nil },
+	}
+	// The second round has no canned response; stop after the first
+	_, rounds, _, err := b.narrow(commits)
+	if err == nil || len(rounds) != 1 || rounds[0].WithDiffs {
+		t.Errorf("narrow() = %+v, %v; want one stats-only round, then an error", rounds, err)
+	}
+}
+
+// TestListBisectCommits tests listing a range from a real repository
+func TestListBisectCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Chdir(t.TempDir())
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=T", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "v1"},
+		{"tag", "v1"},
+	} {
+		if out, err := gitCommand(args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	for _, name := range []string{"auth.go", "login.go"} {
+		if err := os.WriteFile(name, []byte("package app\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, args := range [][]string{
+			{"add", name},
+			{"-c", "user.name=T", "-c", "user.email=t@example.com", "commit", "-q", "-m", "Add " + name},
+		} {
+			if out, err := gitCommand(args...).CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, out)
+			}
+		}
+	}
+
+	commits, err := listBisectCommits("v1", "HEAD")
+	if err != nil {
+		t.Fatalf("listBisectCommits() returned error: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Subject != "Add auth.go" || !strings.Contains(commits[1].Stat, "login.go") {
+		t.Errorf("listBisectCommits() = %+v", commits)
+	}
+	diff, err := commitDiff(commits[1].SHA)
+	if err != nil || !strings.Contains(diff, "+package app") {
+		t.Errorf("commitDiff() = %q, %v", diff, err)
+	}
+}
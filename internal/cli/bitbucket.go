@@ -0,0 +1,438 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const postBitbucket = "bitbucket"
+
+const (
+	// bitbucketCommentLimit keeps comments under the smaller of the Cloud
+	// and Server limits
+	bitbucketCommentLimit = 32768
+
+	// Code Insights accepts at most 1000 annotations per report, and Cloud
+	// at most 100 per request
+	maxAnnotations        = 1000
+	annotationsPerRequest = 100
+
+	// bitbucketReportKey identifies the tool's Code Insights report on a
+	// commit, so a new review replaces the last one
+	bitbucketReportKey = "pr-review"
+)
+
+// bitbucketCloudAPI is the Bitbucket Cloud REST API root
+var bitbucketCloudAPI = "https://api.bitbucket.org/2.0"
+
+// bitbucketClient calls the Bitbucket Cloud or Bitbucket Server (Data
+// Center) REST API for one repository
+type bitbucketClient struct {
+	token   string
+	user    string // with token as an app password; empty for bearer auth
+	server  string // Bitbucket Server URL; empty for Bitbucket Cloud
+	api     string // REST API root
+	project string // Cloud workspace or Server project key
+	repo    string
+}
+
+// newBitbucketClient returns a client for the repository at remote (as
+// normalized by normalizeRemoteURL), authenticated with BITBUCKET_TOKEN: an
+// access token, or an app password if BITBUCKET_USERNAME is set. Remotes
+// not on bitbucket.org are on the Bitbucket Server at BITBUCKET_URL.
+func newBitbucketClient(remote string) (*bitbucketClient, error) {
+	token := bitbucketToken()
+	if token == "" {
+		return nil, fmt.Errorf("BITBUCKET_TOKEN environment variable not set")
+	}
+
+	parts := strings.Split(remote, "/")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("origin %q is not a Bitbucket repository URL", remote)
+	}
+	b := &bitbucketClient{
+		token:   token,
+		user:    os.Getenv("BITBUCKET_USERNAME"),
+		api:     bitbucketCloudAPI,
+		project: parts[len(parts)-2],
+		repo:    parts[len(parts)-1],
+	}
+	if parts[0] != "bitbucket.org" {
+		// Server clone URLs are https://host/scm/PROJECT/repo.git or
+		// ssh://git@host:7999/project/repo.git, neither of which gives the
+		// web URL, so it must be configured
+		b.server = strings.TrimSuffix(os.Getenv("BITBUCKET_URL"), "/")
+		if b.server == "" {
+			return nil, fmt.Errorf("origin %q is not on bitbucket.org; set BITBUCKET_URL to your Bitbucket Server URL", remote)
+		}
+		b.api = b.server + "/rest/api/1.0"
+		b.project = strings.ToUpper(b.project)
+	}
+	return b, nil
+}
+
+// bitbucketToken returns the Bitbucket token from BITBUCKET_TOKEN
+func bitbucketToken() string {
+	return os.Getenv("BITBUCKET_TOKEN")
+}
+
+// do sends an API request to endpoint and decodes a JSON response into out,
+// if set
+func (b *bitbucketClient) do(method, endpoint string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("error marshaling request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if b.user != "" {
+		req.SetBasicAuth(b.user, b.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("API error from Bitbucket (status %d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("error unmarshaling response: %w", err)
+		}
+	}
+	return nil
+}
+
+// repoURL returns the API URL of the repository
+func (b *bitbucketClient) repoURL() string {
+	if b.server != "" {
+		return fmt.Sprintf("%s/projects/%s/repos/%s", b.api, b.project, b.repo)
+	}
+	return fmt.Sprintf("%s/repositories/%s/%s", b.api, b.project, b.repo)
+}
+
+// pullRequestURL returns the API URL of pull request pr
+func (b *bitbucketClient) pullRequestURL(pr int) string {
+	if b.server != "" {
+		return fmt.Sprintf("%s/pull-requests/%d", b.repoURL(), pr)
+	}
+	return fmt.Sprintf("%s/pullrequests/%d", b.repoURL(), pr)
+}
+
+// bitbucketPull is what the tool needs of a pull request
+type bitbucketPull struct {
+	Branch     string
+	BaseBranch string
+	Commit     string
+	Fork       string // the source repository, if not this one
+}
+
+// pullRequest looks up pull request pr
+func (b *bitbucketClient) pullRequest(pr int) (*bitbucketPull, error) {
+	if b.server != "" {
+		var pull struct {
+			FromRef struct {
+				DisplayID    string `json:"displayId"`
+				LatestCommit string `json:"latestCommit"`
+			} `json:"fromRef"`
+			ToRef struct {
+				DisplayID string `json:"displayId"`
+			} `json:"toRef"`
+		}
+		if err := b.do("GET", b.pullRequestURL(pr), nil, &pull); err != nil {
+			return nil, fmt.Errorf("failed to get pull request #%d: %w", pr, err)
+		}
+		// Server publishes every pull request's head as a ref of this
+		// repository, so forks need no special handling
+		return &bitbucketPull{Branch: pull.FromRef.DisplayID, BaseBranch: pull.ToRef.DisplayID, Commit: pull.FromRef.LatestCommit}, nil
+	}
+
+	var pull struct {
+		Source struct {
+			Branch     struct{ Name string }
+			Commit     struct{ Hash string }
+			Repository struct {
+				FullName string `json:"full_name"`
+			}
+		}
+		Destination struct {
+			Branch struct{ Name string }
+		}
+	}
+	if err := b.do("GET", b.pullRequestURL(pr), nil, &pull); err != nil {
+		return nil, fmt.Errorf("failed to get pull request #%d: %w", pr, err)
+	}
+	result := &bitbucketPull{Branch: pull.Source.Branch.Name, BaseBranch: pull.Destination.Branch.Name, Commit: pull.Source.Commit.Hash}
+	if full := pull.Source.Repository.FullName; full != "" && !strings.EqualFold(full, b.project+"/"+b.repo) {
+		result.Fork = full
+	}
+	return result, nil
+}
+
+// findPullRequest returns the ID of the open pull request for branch
+func (b *bitbucketClient) findPullRequest(branch string) (int, error) {
+	var page struct {
+		Values []struct {
+			ID int `json:"id"`
+		} `json:"values"`
+	}
+	endpoint := b.repoURL() + "/pullrequests?" + url.Values{"q": {fmt.Sprintf(`source.branch.name = %q AND state = "OPEN"`, branch)}}.Encode()
+	if b.server != "" {
+		query := url.Values{"at": {"refs/heads/" + branch}, "direction": {"OUTGOING"}, "state": {"OPEN"}}
+		endpoint = b.repoURL() + "/pull-requests?" + query.Encode()
+	}
+	if err := b.do("GET", endpoint, nil, &page); err != nil {
+		return 0, fmt.Errorf("failed to find pull request for %s: %w", branch, err)
+	}
+	if len(page.Values) == 0 {
+		return 0, fmt.Errorf("no open pull request for branch %s in %s/%s; pass -pr", branch, b.project, b.repo)
+	}
+	return page.Values[0].ID, nil
+}
+
+// postComment posts body on a pull request, split into several comments if
+// it is over the size limit, and returns the URL of the first
+func (b *bitbucketClient) postComment(pr int, body string) (string, error) {
+	var first string
+	for _, part := range splitComment(body, bitbucketCommentLimit) {
+		link, err := b.createComment(pr, part)
+		if err != nil {
+			return first, fmt.Errorf("failed to post comment on #%d: %w", pr, err)
+		}
+		if first == "" {
+			first = link
+		}
+	}
+	return first, nil
+}
+
+// createComment posts one comment and returns its URL
+func (b *bitbucketClient) createComment(pr int, body string) (string, error) {
+	if b.server != "" {
+		var comment struct {
+			ID int `json:"id"`
+		}
+		if err := b.do("POST", b.pullRequestURL(pr)+"/comments", map[string]string{"text": body}, &comment); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s/projects/%s/repos/%s/pull-requests/%d/overview?commentId=%d", b.server, b.project, b.repo, pr, comment.ID), nil
+	}
+
+	request := map[string]any{"content": map[string]string{"raw": body}}
+	var comment struct {
+		Links struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+	}
+	if err := b.do("POST", b.pullRequestURL(pr)+"/comments", request, &comment); err != nil {
+		return "", err
+	}
+	return comment.Links.HTML.Href, nil
+}
+
+// reportURL returns the API URL of the tool's Code Insights report on commit
+func (b *bitbucketClient) reportURL(commit string) string {
+	if b.server != "" {
+		return fmt.Sprintf("%s/rest/insights/1.0/projects/%s/repos/%s/commits/%s/reports/%s",
+			b.server, b.project, b.repo, commit, bitbucketReportKey)
+	}
+	return fmt.Sprintf("%s/commit/%s/reports/%s", b.repoURL(), commit, bitbucketReportKey)
+}
+
+// postReport publishes the findings as a Code Insights report on commit,
+// each finding an annotation on its line, replacing any earlier report
+func (b *bitbucketClient) postReport(commit string, findings []Finding) error {
+	failed := false
+	for _, f := range findings {
+		failed = failed || f.Severity >= SeverityHigh
+	}
+	details := fmt.Sprintf("AI code review found %s.", plural(len(findings), "finding"))
+	if len(findings) > maxAnnotations {
+		details += fmt.Sprintf(" The first %d are annotated; see the review comment for the rest.", maxAnnotations)
+		findings = findings[:maxAnnotations]
+	}
+
+	// Annotations of the old report would otherwise linger; there may not
+	// be one, so failing to delete it is not an error
+	b.do("DELETE", b.reportURL(commit), nil, nil)
+
+	var report map[string]any
+	if b.server != "" {
+		result := "PASS"
+		if failed {
+			result = "FAIL"
+		}
+		report = map[string]any{"title": "pr-review", "details": details, "reporter": "pr-review", "result": result}
+	} else {
+		result := "PASSED"
+		if failed {
+			result = "FAILED"
+		}
+		report = map[string]any{"title": "pr-review", "details": details, "reporter": "pr-review", "report_type": "BUG", "result": result}
+	}
+	if err := b.do("PUT", b.reportURL(commit), report, nil); err != nil {
+		return fmt.Errorf("failed to create Code Insights report on %s: %w", shortSHA(commit), err)
+	}
+
+	for start := 0; start < len(findings); start += annotationsPerRequest {
+		batch := findings[start:min(start+annotationsPerRequest, len(findings))]
+		annotations := make([]map[string]any, len(batch))
+		for i, f := range batch {
+			annotations[i] = b.annotation(start+i, f)
+		}
+		var request any = annotations
+		if b.server != "" {
+			request = map[string]any{"annotations": annotations}
+		}
+		if err := b.do("POST", b.reportURL(commit)+"/annotations", request, nil); err != nil {
+			return fmt.Errorf("failed to add annotations to Code Insights report: %w", err)
+		}
+	}
+	return nil
+}
+
+// annotation formats finding i as a Code Insights annotation
+func (b *bitbucketClient) annotation(i int, f Finding) map[string]any {
+	kind := "CODE_SMELL"
+	if strings.Contains(strings.ToLower(f.Category), "security") {
+		kind = "VULNERABILITY"
+	} else if f.Severity >= SeverityMedium {
+		kind = "BUG"
+	}
+	summary := fmt.Sprintf("[%s] %s", strings.ToUpper(f.Severity.String()), f.Title)
+	if f.CriticalPath {
+		summary += " (critical path)"
+	}
+	details := f.Message
+	if f.Suggestion != "" {
+		details += "\n\nSuggestion: " + f.Suggestion
+	}
+
+	a := map[string]any{}
+	if f.File != "" {
+		a["path"] = f.File
+	}
+	if f.File != "" && f.Line > 0 {
+		a["line"] = f.Line
+	}
+	if b.server != "" {
+		// Server has no critical severity and one message field
+		severity := strings.ToUpper(max(SeverityLow, min(f.Severity, SeverityHigh)).String())
+		a["externalId"] = fmt.Sprintf("pr-review-%d", i+1)
+		a["severity"] = severity
+		a["type"] = kind
+		a["message"] = clipText(summary+"\n\n"+details, 2000)
+		return a
+	}
+	a["external_id"] = fmt.Sprintf("pr-review-%d", i+1)
+	a["severity"] = strings.ToUpper(max(SeverityLow, f.Severity).String())
+	a["annotation_type"] = kind
+	a["summary"] = clipText(summary, 450)
+	if details != "" {
+		a["details"] = clipText(details, 2000)
+	}
+	return a
+}
+
+// clipText shortens s to at most n runes, marking the cut with an ellipsis
+func clipText(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// postBitbucketReview posts a review as a comment on the pull request for
+// branch, or on pr if it is set, and its findings as a Code Insights report
+// on the reviewed commit
+func postBitbucketReview(remote, branch string, pr int, review string, findings []Finding, headSHA string) error {
+	b, err := newBitbucketClient(remote)
+	if err != nil {
+		return err
+	}
+	if pr == 0 {
+		if pr, err = b.findPullRequest(branch); err != nil {
+			return err
+		}
+	}
+
+	link, err := b.postComment(pr, review)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("💬 Review posted to %s/%s#%d: %s\n", b.project, b.repo, pr, link)
+
+	if headSHA != "" {
+		if pull, err := b.pullRequest(pr); err == nil && pull.Commit != "" && !strings.HasPrefix(headSHA, pull.Commit) {
+			fmt.Fprintf(os.Stderr, "Warning: Pull request #%d is at %s but the review is of %s; the Code Insights report is on the reviewed commit\n",
+				pr, shortSHA(pull.Commit), shortSHA(headSHA))
+		}
+		if err := b.postReport(headSHA, findings); err != nil {
+			return err
+		}
+		fmt.Printf("📋 Code Insights report with %s added to %s\n", plural(len(findings), "annotation"), shortSHA(headSHA))
+	}
+	fmt.Println()
+	return nil
+}
+
+// bitbucketPullSource finds where pull request pr's head can be fetched
+// from, and its branches if a token is set
+func bitbucketPullSource(pr int) (*pullSource, error) {
+	remote := getRepoIdentity()
+	if bitbucketToken() == "" {
+		if strings.HasPrefix(remote, "bitbucket.org/") {
+			return nil, fmt.Errorf("fetching a Bitbucket Cloud pull request requires the BITBUCKET_TOKEN environment variable")
+		}
+		return &pullSource{Remote: "origin", Ref: fmt.Sprintf("refs/pull-requests/%d/from", pr)}, nil
+	}
+
+	b, err := newBitbucketClient(remote)
+	if err != nil {
+		return nil, err
+	}
+	pull, err := b.pullRequest(pr)
+	if err != nil {
+		return nil, err
+	}
+	src := &pullSource{Remote: "origin", Branch: pull.Branch, BaseBranch: pull.BaseBranch}
+	switch {
+	case b.server != "":
+		src.Ref = fmt.Sprintf("refs/pull-requests/%d/from", pr)
+	case pull.Fork != "":
+		src.Remote = "https://bitbucket.org/" + pull.Fork + ".git"
+		src.Ref = "refs/heads/" + pull.Branch
+	default:
+		src.Ref = "refs/heads/" + pull.Branch
+	}
+	return src, nil
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestNewBitbucketClient tests telling Cloud and Server repositories apart
+// from the origin remote
+func TestNewBitbucketClient(t *testing.T) {
+	t.Setenv("BITBUCKET_TOKEN", "secret")
+	t.Setenv("BITBUCKET_URL", "")
+
+	b, err := newBitbucketClient("bitbucket.org/team/app")
+	if err != nil {
+		t.Fatalf("newBitbucketClient() returned error: %v", err)
+	}
+	if b.server != "" || b.repoURL() != bitbucketCloudAPI+"/repositories/team/app" {
+		t.Errorf("Cloud client = %+v", b)
+	}
+
+	if _, err := newBitbucketClient("git.example.com/scm/proj/app"); err == nil {
+		t.Error("newBitbucketClient() of a Server remote without BITBUCKET_URL expected error")
+	}
+	t.Setenv("BITBUCKET_URL", "https://git.example.com/")
+	b, err = newBitbucketClient("git.example.com:7999/proj/app")
+	if err != nil {
+		t.Fatalf("newBitbucketClient() returned error: %v", err)
+	}
+	if want := "https://git.example.com/rest/api/1.0/projects/PROJ/repos/app"; b.repoURL() != want {
+		t.Errorf("Server repoURL() = %q, want %q", b.repoURL(), want)
+	}
+
+	t.Setenv("BITBUCKET_TOKEN", "")
+	if _, err := newBitbucketClient("bitbucket.org/team/app"); err == nil {
+		t.Error("newBitbucketClient() without a token expected error")
+	}
+}
+
+// TestPostBitbucketReview_Cloud tests posting the review comment and a Code
+// Insights report with annotations to Bitbucket Cloud
+func TestPostBitbucketReview_Cloud(t *testing.T) {
+	var comments []string
+	var report map[string]any
+	var annotations []map[string]any
+	deleted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me" || pass != "secret" {
+			t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+		}
+		const reportPath = "/repositories/team/app/commit/abc123/reports/pr-review"
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/repositories/team/app/pullrequests":
+			if !strings.Contains(r.URL.Query().Get("q"), `"feature"`) {
+				fmt.Fprint(w, `{"values": []}`)
+				return
+			}
+			fmt.Fprint(w, `{"values": [{"id": 9}]}`)
+		case r.Method == "GET" && r.URL.Path == "/repositories/team/app/pullrequests/9":
+			fmt.Fprint(w, `{"source": {"branch": {"name": "feature"}, "commit": {"hash": "abc123"}}}`)
+		case r.Method == "POST" && r.URL.Path == "/repositories/team/app/pullrequests/9/comments":
+			var c struct{ Content struct{ Raw string } }
+			json.NewDecoder(r.Body).Decode(&c)
+			comments = append(comments, c.Content.Raw)
+			fmt.Fprint(w, `{"links": {"html": {"href": "https://bitbucket.org/team/app/pull-requests/9#comment-1"}}}`)
+		case r.Method == "DELETE" && r.URL.Path == reportPath:
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "PUT" && r.URL.Path == reportPath:
+			json.NewDecoder(r.Body).Decode(&report)
+			fmt.Fprint(w, `{}`)
+		case r.Method == "POST" && r.URL.Path == reportPath+"/annotations":
+			var batch []map[string]any
+			json.NewDecoder(r.Body).Decode(&batch)
+			annotations = append(annotations, batch...)
+			fmt.Fprint(w, `[]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("BITBUCKET_TOKEN", "secret")
+	t.Setenv("BITBUCKET_USERNAME", "me")
+	t.Setenv("BITBUCKET_URL", "")
+	api := bitbucketCloudAPI
+	bitbucketCloudAPI = server.URL
+	t.Cleanup(func() { bitbucketCloudAPI = api })
+
+	findings := []Finding{
+		{File: "db.go", Line: 12, Severity: SeverityCritical, Category: "security", Title: "SQL injection", Message: "User input reaches the query."},
+		{Severity: SeverityInfo, Title: "Consider a changelog entry"},
+	}
+	if err := postBitbucketReview("bitbucket.org/team/app", "feature", 0, "Looks risky.", findings, "abc123"); err != nil {
+		t.Fatalf("postBitbucketReview() returned error: %v", err)
+	}
+
+	if len(comments) != 1 || comments[0] != "Looks risky." {
+		t.Errorf("comments = %q", comments)
+	}
+	if !deleted {
+		t.Error("the previous report was not deleted")
+	}
+	if report["result"] != "FAILED" || report["report_type"] != "BUG" {
+		t.Errorf("report = %v", report)
+	}
+	if len(annotations) != 2 {
+		t.Fatalf("got %d annotations, want 2", len(annotations))
+	}
+	first := annotations[0]
+	if first["path"] != "db.go" || first["line"] != float64(12) || first["severity"] != "CRITICAL" || first["annotation_type"] != "VULNERABILITY" {
+		t.Errorf("annotation = %v", first)
+	}
+	if _, ok := annotations[1]["line"]; ok || annotations[1]["severity"] != "LOW" || annotations[1]["annotation_type"] != "CODE_SMELL" {
+		t.Errorf("file-less annotation = %v", annotations[1])
+	}
+}
+
+// TestPostBitbucketReview_Server tests the Bitbucket Server comment and Code
+// Insights endpoints
+func TestPostBitbucketReview_Server(t *testing.T) {
+	var annotations struct {
+		Annotations []map[string]any `json:"annotations"`
+	}
+	var report map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		const reportPath = "/rest/insights/1.0/projects/PROJ/repos/app/commits/abc123/reports/pr-review"
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/rest/api/1.0/projects/PROJ/repos/app/pull-requests/3":
+			fmt.Fprint(w, `{"fromRef": {"displayId": "feature", "latestCommit": "abc123"}, "toRef": {"displayId": "main"}}`)
+		case r.Method == "POST" && r.URL.Path == "/rest/api/1.0/projects/PROJ/repos/app/pull-requests/3/comments":
+			io.Copy(io.Discard, r.Body)
+			fmt.Fprint(w, `{"id": 77}`)
+		case r.Method == "DELETE" && r.URL.Path == reportPath:
+			http.NotFound(w, r)
+		case r.Method == "PUT" && r.URL.Path == reportPath:
+			json.NewDecoder(r.Body).Decode(&report)
+			fmt.Fprint(w, `{}`)
+		case r.Method == "POST" && r.URL.Path == reportPath+"/annotations":
+			json.NewDecoder(r.Body).Decode(&annotations)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("BITBUCKET_TOKEN", "secret")
+	t.Setenv("BITBUCKET_USERNAME", "")
+	t.Setenv("BITBUCKET_URL", server.URL)
+
+	findings := []Finding{{File: "app.go", Line: 4, Severity: SeverityCritical, Title: "Nil dereference", Message: strings.Repeat("x", 3000)}}
+	if err := postBitbucketReview("git.example.com/scm/proj/app", "", 3, "Review", findings, "abc123"); err != nil {
+		t.Fatalf("postBitbucketReview() returned error: %v", err)
+	}
+
+	if report["result"] != "FAIL" {
+		t.Errorf("report = %v", report)
+	}
+	if len(annotations.Annotations) != 1 {
+		t.Fatalf("got %d annotations, want 1", len(annotations.Annotations))
+	}
+	a := annotations.Annotations[0]
+	if a["severity"] != "HIGH" || a["type"] != "BUG" || a["externalId"] != "pr-review-1" {
+		t.Errorf("annotation = %v", a)
+	}
+	if message := a["message"].(string); len([]rune(message)) != 2000 || !strings.HasPrefix(message, "[CRITICAL] Nil dereference") {
+		t.Errorf("message is %d runes: %.40q", len([]rune(message)), message)
+	}
+}
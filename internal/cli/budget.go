@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+)
+
+// reviewBudget caps what a review may spend; zero fields are unlimited
+type reviewBudget struct {
+	MaxCost        float64 // USD at list prices
+	MaxInputTokens int     // per request
+}
+
+// budgetFor combines -max-cost and -max-input-tokens with the org policy's
+// limits. The policy's limits are a ceiling: flags can only lower them.
+func budgetFor(maxCost float64, maxInputTokens int, p *Policy) reviewBudget {
+	b := reviewBudget{MaxCost: maxCost, MaxInputTokens: maxInputTokens}
+	if p == nil {
+		return b
+	}
+	if p.MaxCost > 0 && (b.MaxCost <= 0 || p.MaxCost < b.MaxCost) {
+		b.MaxCost = p.MaxCost
+	}
+	if p.MaxInputTokens > 0 && (b.MaxInputTokens <= 0 || p.MaxInputTokens < b.MaxInputTokens) {
+		b.MaxInputTokens = p.MaxInputTokens
+	}
+	return b
+}
+
+// check returns why a review with the estimated cost would exceed the
+// budget, or nil if it fits. A price that isn't known can't be checked
+// against -max-cost, so it doesn't fit.
+func (b reviewBudget) check(est costEstimate) error {
+	if b.MaxInputTokens > 0 && est.InputTokens > b.MaxInputTokens {
+		approx := ""
+		if !est.Counted {
+			approx = "~"
+		}
+		return fmt.Errorf("the prompt has %s%d input tokens, over the limit of %d", approx, est.InputTokens, b.MaxInputTokens)
+	}
+	if b.MaxCost > 0 {
+		if !est.Known {
+			return fmt.Errorf("the model's price is unknown, so the $%.2f cost limit can't be checked", b.MaxCost)
+		}
+		if est.Cost > b.MaxCost {
+			return fmt.Errorf("the review would cost ~$%.2f, over the limit of $%.2f", est.Cost, b.MaxCost)
+		}
+	}
+	return nil
+}
+
+// enforceBudget estimates the review of prompt by models and checks it
+// against the budget. If it doesn't fit and there is a fallback model, the
+// review is estimated with that instead, and the fallback is returned as
+// the model to use when it fits. An error means the review mustn't be sent.
+func enforceBudget(client Provider, prompt string, opts CompletionOptions, models []string, b reviewBudget, fallback string) (costEstimate, string, error) {
+	est := estimateReview(client, prompt, opts, models)
+	err := b.check(est)
+	if err == nil {
+		return est, opts.Model, nil
+	}
+	if fallback == "" || len(models) > 1 {
+		return est, opts.Model, err
+	}
+
+	opts.Model = fallback
+	fallbackEst := estimateReview(client, prompt, opts, []string{fallback})
+	if fallbackErr := b.check(fallbackEst); fallbackErr != nil {
+		return est, "", fmt.Errorf("%v, and with -budget-model %s %v", err, fallback, fallbackErr)
+	}
+	fmt.Printf("💸 %s\n", tr("Over budget with %s (%s); reviewing with %s instead", models[0], err.Error(), fallback))
+	return fallbackEst, fallback, nil
+}
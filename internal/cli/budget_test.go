@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestBudgetFor tests that the org policy's limits are a ceiling flags can
+// only lower
+func TestBudgetFor(t *testing.T) {
+	policy := &Policy{MaxCost: 1, MaxInputTokens: 100000}
+	tests := []struct {
+		name     string
+		maxCost  float64
+		maxInput int
+		policy   *Policy
+		want     reviewBudget
+	}{
+		{"flags only", 0.5, 2000, nil, reviewBudget{0.5, 2000}},
+		{"policy only", 0, 0, policy, reviewBudget{1, 100000}},
+		{"flags lower the policy", 0.25, 50000, policy, reviewBudget{0.25, 50000}},
+		{"flags can't raise the policy", 5, 500000, policy, reviewBudget{1, 100000}},
+	}
+	for _, tt := range tests {
+		if got := budgetFor(tt.maxCost, tt.maxInput, tt.policy); got != tt.want {
+			t.Errorf("%s: budgetFor() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if _, err := parsePolicy([]byte("max_cost: -1\n")); err == nil {
+		t.Error("parsePolicy() accepted a negative max_cost")
+	}
+}
+
+// TestBudgetCheck tests checking an estimate against the budget
+func TestBudgetCheck(t *testing.T) {
+	est := costEstimate{InputTokens: 120000, OutputTokens: 4000, Counted: true, Known: true, Cost: 0.42}
+	tests := []struct {
+		budget reviewBudget
+		want   string
+	}{
+		{reviewBudget{}, ""},
+		{reviewBudget{MaxCost: 0.5, MaxInputTokens: 200000}, ""},
+		{reviewBudget{MaxCost: 0.4}, "the review would cost ~$0.42, over the limit of $0.40"},
+		{reviewBudget{MaxInputTokens: 100000}, "the prompt has 120000 input tokens, over the limit of 100000"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if err := tt.budget.check(est); err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("check(%+v) = %q, want %q", tt.budget, got, tt.want)
+		}
+	}
+	if err := (reviewBudget{MaxCost: 1}).check(costEstimate{InputTokens: 10}); err == nil || !strings.Contains(err.Error(), "price is unknown") {
+		t.Errorf("check() of an unknown price = %v", err)
+	}
+}
+
+// TestEnforceBudget tests stopping a review over budget, or switching it to
+// the -budget-model
+func TestEnforceBudget(t *testing.T) {
+	// ~250000 input tokens: $0.75 with Sonnet, $0.25 with Haiku
+	prompt := strings.Repeat("x", 1000000)
+	opts := CompletionOptions{Model: "claude-sonnet-4-5", MaxTokens: 4000}
+	limit := reviewBudget{MaxCost: 0.5}
+
+	if _, _, err := enforceBudget(&fakeProvider{}, prompt, opts, []string{opts.Model}, limit, ""); err == nil || !strings.Contains(err.Error(), "over the limit of $0.50") {
+		t.Errorf("enforceBudget() without a fallback = %v", err)
+	}
+
+	est, model, err := enforceBudget(&fakeProvider{}, prompt, opts, []string{opts.Model}, limit, "claude-haiku-4-5")
+	if err != nil || model != "claude-haiku-4-5" || est.Cost > 0.5 {
+		t.Errorf("enforceBudget() with a fallback = %+v, %q, %v", est, model, err)
+	}
+
+	_, _, err = enforceBudget(&fakeProvider{}, prompt, opts, []string{opts.Model}, reviewBudget{MaxCost: 0.1}, "claude-haiku-4-5")
+	if err == nil || !strings.Contains(err.Error(), "and with -budget-model claude-haiku-4-5") {
+		t.Errorf("enforceBudget() with a fallback over budget = %v", err)
+	}
+
+	// A comparison isn't switched to a single model
+	models := []string{"claude-sonnet-4-5", "claude-haiku-4-5"}
+	if _, _, err := enforceBudget(&fakeProvider{}, prompt, opts, models, limit, "claude-haiku-4-5"); err == nil {
+		t.Error("enforceBudget() switched a comparison to the fallback")
+	}
+
+	if _, model, err := enforceBudget(&fakeProvider{}, prompt, opts, []string{opts.Model}, reviewBudget{MaxCost: 1}, "claude-haiku-4-5"); err != nil || model != opts.Model {
+		t.Errorf("enforceBudget() within budget = %q, %v", model, err)
+	}
+}
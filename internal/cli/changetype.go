@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// Change types a review can be tailored to
+const (
+	changeFeature    = "feature"
+	changeBugfix     = "bugfix"
+	changeRefactor   = "refactor"
+	changeDependency = "dependency"
+	changeRevert     = "revert"
+)
+
+var changeTypes = []string{changeFeature, changeBugfix, changeRefactor, changeDependency, changeRevert}
+
+// ChangeTypeConfig tailors the review of one type of change
+type ChangeTypeConfig struct {
+	// Prompt replaces the review rubric, the instructions ahead of the diff
+	Prompt string `yaml:"prompt"`
+
+	// FailOn fails the review when a finding is at least this severe,
+	// replacing the config's fail_on for this type of change
+	FailOn *Severity `yaml:"fail_on"`
+}
+
+// validateChangeType checks a change type name given in config or -change-type
+func validateChangeType(name string) error {
+	for _, t := range changeTypes {
+		if name == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown change type %q (want one of: %s)", name, strings.Join(changeTypes, ", "))
+}
+
+// changeSignals is what a change's type is inferred from
+type changeSignals struct {
+	Branch         string
+	Labels         []string // pull request labels, if known
+	CommitMessages string   // as from gitdiff.Git.Log, newest first
+	Files          []string
+}
+
+// labelTypes maps words in pull request labels to change types, checked
+// in order
+var labelTypes = []struct {
+	word, changeType string
+}{
+	{"revert", changeRevert},
+	{"dependencies", changeDependency},
+	{"dependency", changeDependency},
+	{"deps", changeDependency},
+	{"bug", changeBugfix},
+	{"fix", changeBugfix},
+	{"refactor", changeRefactor},
+	{"feature", changeFeature},
+	{"enhancement", changeFeature},
+}
+
+// branchTypes maps branch name prefixes to change types
+var branchTypes = []struct {
+	prefix, changeType string
+}{
+	{"dependabot/", changeDependency},
+	{"renovate/", changeDependency},
+	{"deps/", changeDependency},
+	{"revert-", changeRevert},
+	{"revert/", changeRevert},
+	{"fix/", changeBugfix},
+	{"bugfix/", changeBugfix},
+	{"hotfix/", changeBugfix},
+	{"refactor/", changeRefactor},
+	{"feat/", changeFeature},
+	{"feature/", changeFeature},
+}
+
+// dependencyFiles are manifests and lock files of package managers
+var dependencyFiles = map[string]bool{
+	"go.mod": true, "go.sum": true,
+	"package.json": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"Cargo.toml": true, "Cargo.lock": true,
+	"requirements.txt": true, "poetry.lock": true, "Pipfile": true, "Pipfile.lock": true, "pyproject.toml": true, "uv.lock": true,
+	"Gemfile": true, "Gemfile.lock": true,
+	"composer.json": true, "composer.lock": true,
+	"pom.xml": true, "build.gradle": true, "build.gradle.kts": true, "gradle.lockfile": true,
+}
+
+// detectChangeType infers a change's type from, in order, its pull request
+// labels, its branch name, its commit subjects, and whether it only touches
+// dependency manifests. It returns "" if nothing points to a type.
+func detectChangeType(s changeSignals) string {
+	for _, label := range s.Labels {
+		label = strings.ToLower(label)
+		for _, lt := range labelTypes {
+			if strings.Contains(label, lt.word) {
+				return lt.changeType
+			}
+		}
+	}
+
+	branch := strings.ToLower(s.Branch)
+	for _, bt := range branchTypes {
+		if strings.HasPrefix(branch, bt.prefix) {
+			return bt.changeType
+		}
+	}
+
+	if t := commitChangeType(s.CommitMessages); t != "" {
+		return t
+	}
+
+	if len(s.Files) > 0 {
+		for _, f := range s.Files {
+			if !dependencyFiles[path.Base(f)] && !strings.HasPrefix(f, "vendor/") {
+				return ""
+			}
+		}
+		return changeDependency
+	}
+	return ""
+}
+
+// commitChangeType infers a type from commit subjects: a revert, or one
+// Conventional Commits type shared by every commit
+func commitChangeType(messages string) string {
+	found := ""
+	for _, line := range strings.Split(messages, "\n") {
+		// gitdiff.Git.Log lines are "<sha> - <subject> (<author>, <age>)"
+		_, subject, ok := strings.Cut(line, " - ")
+		if !ok {
+			continue
+		}
+		subject = strings.ToLower(subject)
+		var t string
+		switch {
+		case strings.HasPrefix(subject, `revert "`), strings.HasPrefix(subject, "revert:"):
+			t = changeRevert
+		case strings.HasPrefix(subject, "chore(deps"), strings.HasPrefix(subject, "build(deps"), strings.HasPrefix(subject, "fix(deps"):
+			t = changeDependency
+		case strings.HasPrefix(subject, "fix:"), strings.HasPrefix(subject, "fix("):
+			t = changeBugfix
+		case strings.HasPrefix(subject, "refactor:"), strings.HasPrefix(subject, "refactor("):
+			t = changeRefactor
+		case strings.HasPrefix(subject, "feat:"), strings.HasPrefix(subject, "feat("):
+			t = changeFeature
+		default:
+			return ""
+		}
+		if found != "" && t != found {
+			return ""
+		}
+		found = t
+	}
+	return found
+}
+
+// builtinRubrics replace the full review rubric for changes that don't need
+// it; the config's change_types can replace them in turn
+var builtinRubrics = map[string]string{
+	changeDependency: `You are an expert code reviewer. This Pull Request bumps dependencies. Rather than a full code review, do a short risk check:
+
+- Which packages change, and by how much (patch, minor, major)?
+- Known breaking changes, deprecations or security advisories in the new versions
+- Whether the code in the diff (if any) was adapted to the new APIs
+- Lock file changes that don't match the manifest changes, or unexpected new transitive dependencies
+- License changes
+
+Keep the review brief and only raise findings that need action.`,
+
+	changeRevert: `You are an expert code reviewer. This Pull Request reverts earlier changes. Rather than a full code review, check that the revert is safe:
+
+- Is it a clean revert, or does it also change other code?
+- Does anything merged since depend on the reverted code?
+- Data or schema changes the reverted code made that the revert doesn't undo
+- Tests that should be removed or restored along with the code
+
+Keep the review brief and only raise findings that need action.`,
+}
+
+// changeTypeFocus tells the model what to look for in a type of change
+// reviewed with the full rubric
+var changeTypeFocus = map[string]string{
+	changeFeature:  "This change adds a feature. Check that it is complete, tested, and documented where users will look for it.",
+	changeBugfix:   "This change fixes a bug. Check that it fixes the root cause rather than a symptom and adds a test that fails without the fix.",
+	changeRefactor: "This change is a refactoring. Check that behavior is unchanged; call out anything that alters behavior, however small.",
+}
+
+// applyChangeType tailors cfg to a type of change: its rubric and gate, and
+// a note on what to focus on. It returns the prompt section for the note,
+// if there is one.
+func (cfg *Config) applyChangeType(changeType string) *promptSection {
+	if changeType == "" {
+		return nil
+	}
+	if rubric, ok := builtinRubrics[changeType]; ok {
+		cfg.rubric = rubric
+	}
+	if ct, ok := cfg.ChangeTypes[changeType]; ok {
+		if ct.Prompt != "" {
+			cfg.rubric = ct.Prompt
+		}
+		if ct.FailOn != nil {
+			cfg.FailOn = ct.FailOn
+		}
+	}
+	if focus, ok := changeTypeFocus[changeType]; ok && cfg.rubric == "" {
+		return &promptSection{Title: "Change Type", Body: focus}
+	}
+	return nil
+}
+
+// parseFailOn parses -fail-on: a severity, or a comma-separated list of
+// them, gating on the least severe listed, so "critical,high" fails on high
+// and critical findings. "none" turns the gate off, returning nil.
+func parseFailOn(value string) (*Severity, error) {
+	if strings.TrimSpace(value) == "none" {
+		return nil, nil
+	}
+	var gate *Severity
+	for _, name := range strings.Split(value, ",") {
+		s, err := review.ParseSeverity(name)
+		if err != nil {
+			return nil, err
+		}
+		if gate == nil || s < *gate {
+			gate = &s
+		}
+	}
+	return gate, nil
+}
+
+// gate returns the severity reviews fail on: the config's fail_on, or the
+// org policy's if that is stricter, or nil if neither sets a gate
+func (cfg *Config) gate() *Severity {
+	if cfg.policyFailOn != nil && (cfg.FailOn == nil || *cfg.policyFailOn < *cfg.FailOn) {
+		return cfg.policyFailOn
+	}
+	return cfg.FailOn
+}
+
+// gateFailures returns the findings at or above the gate's severity, or
+// nil if no gate is set
+func (cfg *Config) gateFailures(findings []Finding) []Finding {
+	gate := cfg.gate()
+	if gate == nil {
+		return nil
+	}
+	var failed []Finding
+	for _, f := range findings {
+		if f.Severity >= *gate {
+			failed = append(failed, f)
+		}
+	}
+	return failed
+}
+
+// exitOnGate exits with exitGate if any findings fail the config's gate,
+// or with exitPartial if any units of the run failed; otherwise it writes
+// the status file of a successful run
+func exitOnGate(cfg *Config, findings []Finding) {
+	failed := cfg.gateFailures(findings)
+	run.setFindings(findings, failed)
+	if len(failed) == 0 {
+		if _, units, _ := countUnits(run.Units); units > 0 {
+			fmt.Fprintf(os.Stderr, "❌ %d of %d units failed; the report covers the rest\n", units, len(run.Units))
+			exitWith(exitPartial)
+		}
+		if err := run.write(exitOK); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not write status file: %v\n", err)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "❌ Review gate failed: %s at or above %s severity\n", plural(len(failed), "finding"), *cfg.gate())
+	exitWith(exitGate)
+}
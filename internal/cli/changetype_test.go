@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDetectChangeType tests inferring the type of change from labels,
+// branch names, commit subjects and the files changed
+func TestDetectChangeType(t *testing.T) {
+	tests := []struct {
+		name    string
+		signals changeSignals
+		want    string
+	}{
+		{"label", changeSignals{Branch: "feature/sso", Labels: []string{"type: bug"}}, changeBugfix},
+		{"dependency label", changeSignals{Labels: []string{"dependencies"}}, changeDependency},
+		{"dependabot branch", changeSignals{Branch: "dependabot/go_modules/golang.org/x/net-0.30.0"}, changeDependency},
+		{"revert branch", changeSignals{Branch: "revert-123-feature"}, changeRevert},
+		{"feature branch", changeSignals{Branch: "feat/sso"}, changeFeature},
+		{"revert commit", changeSignals{Branch: "main", CommitMessages: `abc1234 - Revert "Add SSO" (Ann, 2 hours ago)`}, changeRevert},
+		{"conventional commits", changeSignals{CommitMessages: "abc1234 - fix: close body (Ann, 1 hour ago)\ndef5678 - fix(api): retry (Ann, 2 hours ago)"}, changeBugfix},
+		{"mixed commits", changeSignals{CommitMessages: "abc1234 - fix: close body (Ann, 1 hour ago)\ndef5678 - feat: add retry (Ann, 2 hours ago)"}, ""},
+		{"lock files only", changeSignals{Branch: "update", Files: []string{"go.mod", "go.sum", "web/package-lock.json"}}, changeDependency},
+		{"code too", changeSignals{Branch: "update", Files: []string{"go.mod", "main.go"}}, ""},
+		{"nothing", changeSignals{Branch: "my-work"}, ""},
+	}
+	for _, tt := range tests {
+		if got := detectChangeType(tt.signals); got != tt.want {
+			t.Errorf("%s: detectChangeType() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestApplyChangeType tests that change types select the rubric and gate
+// from the built-ins and the config
+func TestApplyChangeType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), repoConfigFile)
+	config := `fail_on: critical
+change_types:
+  feature:
+    fail_on: high
+  refactor:
+    prompt: Check that behavior is unchanged.
+`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	load := func() *Config {
+		cfg, err := loadConfig(path)
+		if err != nil {
+			t.Fatalf("loadConfig() returned error: %v", err)
+		}
+		return cfg
+	}
+	findings := []Finding{{Severity: SeverityHigh}, {Severity: SeverityMedium}}
+
+	// A dependency bump gets the short risk check and the default gate
+	cfg := load()
+	if section := cfg.applyChangeType(changeDependency); section != nil {
+		t.Errorf("applyChangeType(dependency) section = %+v, want none", section)
+	}
+	prompt := buildReviewPrompt("diff", "go.mod", "", "", nil, cfg)
+	if !strings.Contains(prompt, "short risk check") || strings.Contains(prompt, "Code Quality & Best Practices") {
+		t.Error("dependency prompt doesn't use the risk check rubric")
+	}
+	if failed := cfg.gateFailures(findings); len(failed) != 0 {
+		t.Errorf("gateFailures() = %d, want 0 with fail_on critical", len(failed))
+	}
+
+	// A feature keeps the full rubric, with a focus note and a stricter gate
+	cfg = load()
+	section := cfg.applyChangeType(changeFeature)
+	if section == nil || !strings.Contains(section.Body, "adds a feature") {
+		t.Errorf("applyChangeType(feature) section = %+v", section)
+	}
+	if !strings.Contains(buildReviewPrompt("diff", "a.go", "", "", nil, cfg), "Code Quality & Best Practices") {
+		t.Error("feature prompt doesn't use the full rubric")
+	}
+	if failed := cfg.gateFailures(findings); len(failed) != 1 {
+		t.Errorf("gateFailures() = %d, want 1 with fail_on high", len(failed))
+	}
+
+	// The config's prompt replaces the rubric
+	cfg = load()
+	cfg.applyChangeType(changeRefactor)
+	if prompt := buildReviewPrompt("diff", "a.go", "", "", nil, cfg); !strings.HasPrefix(prompt, "Check that behavior is unchanged.") {
+		t.Errorf("refactor prompt starts %q", prompt[:40])
+	}
+
+	if err := os.WriteFile(path, []byte("change_types:\n  chore: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil || !strings.Contains(err.Error(), `unknown change type "chore"`) {
+		t.Errorf("loadConfig() with an unknown change type error = %v", err)
+	}
+}
+
+// TestParseFailOn tests the severities -fail-on gates on
+func TestParseFailOn(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"high", "high"},
+		{"critical,high", "high"},
+		{"CRITICAL, medium ,high", "medium"},
+		{"none", "<nil>"},
+	}
+	for _, tt := range tests {
+		gate, err := parseFailOn(tt.value)
+		got := "<nil>"
+		if gate != nil {
+			got = gate.String()
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseFailOn(%q) = %s, %v; want %s", tt.value, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"blocker", "high,", "none,high"} {
+		if _, err := parseFailOn(bad); err == nil {
+			t.Errorf("parseFailOn(%q) accepted", bad)
+		}
+	}
+
+	// The flag replaces the config's gate, including turning it off
+	failOn := SeverityLow
+	cfg := &Config{FailOn: &failOn}
+	findings := []Finding{{Severity: SeverityMedium, Title: "Unchecked error"}}
+	if len(cfg.gateFailures(findings)) != 1 {
+		t.Fatal("fail_on: low passed a medium finding")
+	}
+	cfg.FailOn, _ = parseFailOn("critical,high")
+	if failed := cfg.gateFailures(findings); len(failed) != 0 {
+		t.Errorf("-fail-on critical,high failed %v", failed)
+	}
+}
@@ -0,0 +1,14 @@
+package cli
+
+import (
+	"strings"
+)
+
+// formatChecklist renders checklist items as a markdown task list
+func formatChecklist(items []string) string {
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString("- [ ] " + item + "\n")
+	}
+	return b.String()
+}
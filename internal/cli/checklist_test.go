@@ -0,0 +1,11 @@
+package cli
+
+import "testing"
+
+// TestFormatChecklist tests rendering checklist items as a task list
+func TestFormatChecklist(t *testing.T) {
+	items := []string{"Verify the new orders.customer_id index exists in staging", "Confirm the new_checkout flag defaults to off"}
+	if got := formatChecklist(items); got != "- [ ] "+items[0]+"\n- [ ] "+items[1]+"\n" {
+		t.Errorf("formatChecklist() = %q", got)
+	}
+}
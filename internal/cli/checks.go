@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// checkTimeout bounds each verification command run before the review
+	checkTimeout = 5 * time.Minute
+
+	// maxCheckOutput bounds how much of a command's output goes in the prompt
+	maxCheckOutput = 20 * 1024
+)
+
+// checkResult is the outcome of a verification command run before the review
+type checkResult struct {
+	Name     string
+	Command  string
+	Passed   bool
+	TimedOut bool
+	Output   string
+}
+
+// runCheck runs a command in dir and captures its combined output
+func runCheck(dir, name string, args ...string) checkResult {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+
+	result := checkResult{
+		Name:    name,
+		Command: strings.Join(args, " "),
+		Passed:  err == nil,
+		Output:  strings.TrimSpace(string(output)),
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+	} else if err != nil && result.Output == "" {
+		result.Output = err.Error()
+	}
+	return result
+}
+
+// goVerifyChecks builds and vets the Go module in dir
+func goVerifyChecks(dir string) ([]checkResult, error) {
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+		return nil, fmt.Errorf("no go.mod in %s", dir)
+	}
+
+	results := []checkResult{runCheck(dir, "go build", "go", "build", "-o", os.DevNull, "./...")}
+	// go vet type-checks too, so when the build fails it would only repeat
+	// the compile errors
+	if results[0].Passed {
+		results = append(results, runCheck(dir, "go vet", "go", "vet", "./..."))
+	}
+	return results, nil
+}
+
+// preReviewChecks runs the configured pre-review commands through the shell
+func preReviewChecks(dir string, commands []string) []checkResult {
+	var results []checkResult
+	for _, command := range commands {
+		result := runCheck(dir, command, shellCommand(command)...)
+		result.Command = command
+		results = append(results, result)
+	}
+	return results
+}
+
+// shellCommand returns the argv that runs command through the platform shell
+func shellCommand(command string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+	return []string{"sh", "-c", command}
+}
+
+// formatChecks renders check results for the prompt, keeping the tail (and
+// earlier error lines) of long output
+func formatChecks(results []checkResult) string {
+	var b strings.Builder
+	for _, r := range results {
+		status := "passed"
+		switch {
+		case r.TimedOut:
+			status = fmt.Sprintf("timed out after %s", checkTimeout)
+		case !r.Passed:
+			status = "FAILED"
+		}
+		fmt.Fprintf(&b, "### %s: %s\n\n`%s`\n", r.Name, status, r.Command)
+		if r.Output != "" {
+			b.WriteString("```\n" + logExcerpt(r.Output, maxCheckOutput) + "\n```\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// checksFailed reports whether any check did not pass
+func checksFailed(results []checkResult) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return true
+		}
+	}
+	return false
+}
+
+// verificationInstructions tells the model how to use build and vet results
+const verificationInstructions = `These commands were run on the head of the branch before this review. If
+the build fails, do not spend effort reviewing code that does not compile:
+explain each error, the change that caused it, and how to fix it. Treat vet
+findings as confirmed issues and suggest fixes for them.
+
+`
+
+// preReviewInstructions tells the model how to use pre-review command output
+const preReviewInstructions = `The repository's pre-review commands (linters, tests, etc.) were run on the
+branch before this review. Relate any failures to the changes that caused
+them and suggest fixes; do not repeat issues these tools already reported
+unless you have something to add.
+
+`
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeGoModule creates a Go module with a single main.go in a temp dir
+func writeGoModule(t *testing.T, source string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/check\n\ngo 1.21\n",
+		"main.go": source,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// TestGoVerifyChecks_Passing tests that a clean module passes build and vet
+func TestGoVerifyChecks_Passing(t *testing.T) {
+	dir := writeGoModule(t, "package main\n\nfunc main() {}\n")
+
+	results, err := goVerifyChecks(dir)
+	if err != nil {
+		t.Fatalf("goVerifyChecks() returned error: %v", err)
+	}
+	if len(results) != 2 || checksFailed(results) {
+		t.Errorf("goVerifyChecks() = %+v, want build and vet to pass", results)
+	}
+}
+
+// TestGoVerifyChecks_BuildFailure tests that compile errors are captured
+func TestGoVerifyChecks_BuildFailure(t *testing.T) {
+	dir := writeGoModule(t, "package main\n\nfunc main() { undefinedFunc() }\n")
+
+	results, err := goVerifyChecks(dir)
+	if err != nil {
+		t.Fatalf("goVerifyChecks() returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].Passed {
+		t.Fatalf("goVerifyChecks() = %+v, want a single failed build", results)
+	}
+	if !strings.Contains(results[0].Output, "undefined: undefinedFunc") {
+		t.Errorf("build output = %q", results[0].Output)
+	}
+
+	formatted := formatChecks(results)
+	if !strings.Contains(formatted, "### go build: FAILED") || !strings.Contains(formatted, "undefinedFunc") {
+		t.Errorf("formatChecks() = %q", formatted)
+	}
+}
+
+// TestGoVerifyChecks_VetFailure tests that vet findings are captured
+func TestGoVerifyChecks_VetFailure(t *testing.T) {
+	dir := writeGoModule(t, "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Printf(\"%d\\n\", \"str\") }\n")
+
+	results, err := goVerifyChecks(dir)
+	if err != nil {
+		t.Fatalf("goVerifyChecks() returned error: %v", err)
+	}
+	if len(results) != 2 || !results[0].Passed || results[1].Passed {
+		t.Fatalf("goVerifyChecks() = %+v, want build to pass and vet to fail", results)
+	}
+}
+
+// TestGoVerifyChecks_NotAModule tests that non-Go repositories are skipped
+func TestGoVerifyChecks_NotAModule(t *testing.T) {
+	if _, err := goVerifyChecks(t.TempDir()); err == nil {
+		t.Error("goVerifyChecks() expected error without go.mod")
+	}
+}
+
+// TestPreReviewChecks tests running configured shell commands
+func TestPreReviewChecks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "marker.txt"), []byte("present"), 0644); err != nil {
+		t.Fatalf("Failed to write marker: %v", err)
+	}
+
+	show, fail := "cat marker.txt", "echo lint error >&2; exit 3"
+	if runtime.GOOS == "windows" {
+		show, fail = "type marker.txt", "echo lint error>&2 & exit /b 3"
+	}
+	results := preReviewChecks(dir, []string{show, fail})
+	if len(results) != 2 {
+		t.Fatalf("preReviewChecks() returned %d results, want 2", len(results))
+	}
+	if !results[0].Passed || results[0].Output != "present" || results[0].Command != show {
+		t.Errorf("first result = %+v, want it to pass in dir", results[0])
+	}
+	if results[1].Passed || results[1].Output != "lint error" {
+		t.Errorf("second result = %+v, want it to fail with its stderr", results[1])
+	}
+
+	formatted := formatChecks(results)
+	if !strings.Contains(formatted, "### "+show+": passed") || !strings.Contains(formatted, "FAILED") {
+		t.Errorf("formatChecks() = %q", formatted)
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// codeClimateIssue is an issue in a GitLab Code Quality report, the subset
+// of the Code Climate format GitLab reads
+type codeClimateIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeClimateLocation `json:"location"`
+}
+
+type codeClimateLocation struct {
+	Path  string           `json:"path"`
+	Lines codeClimateLines `json:"lines"`
+}
+
+type codeClimateLines struct {
+	Begin int `json:"begin"`
+}
+
+// codeClimateSeverity maps a severity to GitLab's, which has one more level
+// above critical
+var codeClimateSeverity = map[Severity]string{
+	SeverityCritical: "blocker",
+	SeverityHigh:     "critical",
+	SeverityMedium:   "major",
+	SeverityLow:      "minor",
+	SeverityInfo:     "info",
+}
+
+// buildCodeClimate converts a review's findings to Code Quality issues.
+// Findings without a file are left out, since GitLab needs a location, as
+// are findings in the baseline; it returns how many were left out for want
+// of a file.
+func buildCodeClimate(doc *reviewDocument) ([]codeClimateIssue, int) {
+	issues := []codeClimateIssue{}
+	seen := make(map[string]int)
+	skipped := 0
+	for _, f := range doc.Findings {
+		if f.Baselined {
+			continue
+		}
+		if f.File == "" {
+			skipped++
+			continue
+		}
+		description := f.Title
+		if f.Message != "" {
+			description += ": " + f.Message
+		}
+		// GitLab tells issues apart by fingerprint, so findings that
+		// would share one are numbered
+		fingerprint := f.Fingerprint()
+		if seen[fingerprint]++; seen[fingerprint] > 1 {
+			fingerprint = fmt.Sprintf("%s-%d", fingerprint, seen[fingerprint])
+		}
+		issues = append(issues, codeClimateIssue{
+			Description: description,
+			CheckName:   sarifRuleID(f.Category),
+			Fingerprint: fingerprint,
+			Severity:    codeClimateSeverity[f.Severity],
+			Location:    codeClimateLocation{Path: f.File, Lines: codeClimateLines{Begin: max(f.Line, 1)}},
+		})
+	}
+	return issues, skipped
+}
+
+// writeCodeClimate writes a review's findings as a GitLab Code Quality
+// report
+func writeCodeClimate(out io.Writer, doc *reviewDocument) error {
+	issues, skipped := buildCodeClimate(doc)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s without a file left out of the Code Quality report\n", plural(skipped, "finding"))
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(issues)
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"testing"
+)
+
+// TestBuildCodeClimate tests converting findings to GitLab Code Quality
+// issues
+func TestBuildCodeClimate(t *testing.T) {
+	doc := &reviewDocument{Findings: []Finding{
+		{File: "auth/login.go", Line: 42, Severity: SeverityCritical, Category: "Security", Title: "SQL injection", Message: "The query is built with Sprintf"},
+		{File: "cache.go", Severity: SeverityLow, Category: "Error Handling", Title: "Ignored error"},
+		{File: "cache.go", Line: 9, Severity: SeverityLow, Category: "Error Handling", Title: "Ignored error"},
+		{Severity: SeverityHigh, Title: "No location"},
+		{File: "old.go", Line: 3, Severity: SeverityHigh, Title: "Accepted", Baselined: true},
+	}}
+	issues, skipped := buildCodeClimate(doc)
+	if skipped != 1 {
+		t.Errorf("skipped = %d, want 1", skipped)
+	}
+	if len(issues) != 3 {
+		t.Fatalf("got %d issues, want 3: %+v", len(issues), issues)
+	}
+	first := issues[0]
+	if first.Severity != "blocker" || first.CheckName != "pr-review/security" || first.Location.Path != "auth/login.go" || first.Location.Lines.Begin != 42 {
+		t.Errorf("issue = %+v", first)
+	}
+	if first.Description != "SQL injection: The query is built with Sprintf" {
+		t.Errorf("description = %q", first.Description)
+	}
+	if issues[1].Location.Lines.Begin != 1 || issues[1].Severity != "minor" {
+		t.Errorf("issue without a line = %+v", issues[1])
+	}
+	if issues[1].Fingerprint == issues[2].Fingerprint {
+		t.Errorf("findings share the fingerprint %s", issues[1].Fingerprint)
+	}
+}
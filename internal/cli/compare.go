@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// maxCompareDiffBytes bounds each implementation's diff in the synthesis
+// prompt; the individual reviews have already seen the full diffs
+const maxCompareDiffBytes = 100 * 1024
+
+// headReview is the review of one of the implementations being compared
+type headReview struct {
+	Head     string
+	Changes  *branchChanges
+	Review   string
+	Findings []Finding
+}
+
+// comparer reviews alternative implementations of the same change with the
+// normal review prompt, then asks the model to weigh them against each other
+type comparer struct {
+	client  Provider
+	opts    CompletionOptions
+	policy  *Policy
+	cfg     *Config
+	context string
+}
+
+// review runs the normal review of one implementation
+func (c *comparer) review(head string, changes *branchChanges) (*headReview, Usage, error) {
+	prompt := c.policy.redact(buildReviewPrompt(changes.Diff, changes.ChangedFiles, changes.CommitMessages, c.context, nil, c.cfg))
+	r, err := review.Run(c.client, prompt, c.opts)
+	if err != nil {
+		return nil, Usage{}, err
+	}
+	if r.FindingsErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not parse structured findings for %s: %v\n", head, r.FindingsErr)
+	}
+	checkEvidence(r.Findings, changes.Diff)
+	applyCalibration(r.Findings, c.cfg.SeverityCalibration)
+	escalateCritical(r.Findings, c.cfg.CriticalPaths)
+	applySeverityRules(r.Findings, c.cfg.SeverityRules)
+	return &headReview{Head: head, Changes: changes, Review: r.Review, Findings: r.Findings}, r.Usage, nil
+}
+
+// synthesize compares the reviewed implementations and recommends one
+func (c *comparer) synthesize(base string, reviews []*headReview) (string, Usage, error) {
+	prompt := c.policy.redact(buildComparePrompt(base, reviews, c.context))
+	return c.client.Complete(prompt, c.opts)
+}
+
+// buildComparePrompt asks for a comparative analysis of implementations,
+// given their diffs and independent reviews
+func buildComparePrompt(base string, reviews []*headReview, additionalContext string) string {
+	heads := make([]string, len(reviews))
+	for i, r := range reviews {
+		heads[i] = "`" + r.Head + "`"
+	}
+
+	prompt := fmt.Sprintf(`You are an expert code reviewer. The branches %s are alternative
+implementations of the same feature, each made against %s. Each has already
+been reviewed on its own; the diffs and reviews are below. Compare them:
+
+1. **Approaches**: Summarize how each implementation solves the problem.
+2. **Comparison**: Compare them on correctness, completeness, design and
+   complexity, test coverage, performance, security and maintainability, as a
+   table followed by the differences that matter most.
+3. **Recommendation**: Recommend one implementation, or a combination, and say
+   what must change before it is merged (including anything worth taking from
+   the other).
+
+Judge them against each other, not against an ideal; don't repeat the
+individual reviews beyond what the comparison needs.
+
+---
+`, strings.Join(heads, " and "), "`"+base+"`")
+
+	for _, r := range reviews {
+		diff := r.Changes.Diff
+		if len(diff) > maxCompareDiffBytes {
+			diff = diff[:maxCompareDiffBytes] + "\n... (diff truncated)\n"
+		}
+		prompt += "\n## Implementation `" + r.Head + "`\n\n### Changed Files\n```\n" + r.Changes.ChangedFiles + "\n```\n\n"
+		if r.Changes.CommitMessages != "" {
+			prompt += "### Commit Messages\n```\n" + r.Changes.CommitMessages + "\n```\n\n"
+		}
+		prompt += "### Diff\n```diff\n" + diff + "\n```\n\n### Review\n\n" + r.Review + "\n"
+		if rendered := renderFindings(r.Findings, groupBySeverity); rendered != "" {
+			prompt += "\n" + strings.Replace(rendered, "## Findings", "### Findings", 1)
+		}
+	}
+
+	if additionalContext != "" {
+		prompt += "\n## Additional Context\n" + additionalContext + "\n"
+	}
+	return prompt + "\n\nPlease provide your comparative analysis and recommendation."
+}
+
+// formatCompareReport puts the comparison first, followed by each
+// implementation's own review
+func formatCompareReport(base, analysis string, reviews []*headReview) string {
+	heads := make([]string, len(reviews))
+	for i, r := range reviews {
+		heads[i] = "`" + r.Head + "`"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Comparison: %s\n\nAlternative implementations against `%s`.\n\n", strings.Join(heads, " vs "), base)
+	b.WriteString("## Analysis\n\n" + analysis + "\n")
+	for _, r := range reviews {
+		fmt.Fprintf(&b, "\n---\n\n# Review of `%s`\n\n%s\n", r.Head, r.Review)
+		if rendered := renderFindings(r.Findings, groupBySeverity); rendered != "" {
+			b.WriteString("\n" + rendered)
+		}
+	}
+	return b.String()
+}
+
+// runCompare implements `pr-review compare`, reviewing two implementations
+// of the same feature and recommending one
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	outputFile := fs.String("output", "COMPARISON.md", "Output file for the comparison (will create numbered backups if exists)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pr-review compare [flags] <branch-a> <branch-b> [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	// Allow flags after the branches, as in `compare a b -base main`
+	var heads []string
+	for fs.NArg() > 0 {
+		heads = append(heads, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+	envFlags(fs, fs.Name())
+	if len(heads) != 2 {
+		fmt.Fprintln(os.Stderr, "Error: compare requires exactly two branches")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	client, policy := common.provider()
+
+	cfg, err := loadConfig(filepath.Join(getRepoRoot(), repoConfigFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	policy.enforce(cfg)
+
+	base := common.baseRef()
+	fmt.Printf("🔍 Comparing '%s' and '%s' against '%s'\n\n", heads[0], heads[1], base)
+
+	c := &comparer{
+		client:  client,
+		opts:    common.completionOptions(),
+		policy:  policy,
+		cfg:     cfg,
+		context: common.readContext(client, policy),
+	}
+
+	var reviews []*headReview
+	var usage Usage
+	for _, head := range heads {
+		changes, err := collectChanges(base, head)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting diff of %s: %v\n", head, err)
+			os.Exit(1)
+		}
+		if changes.Diff == "" {
+			fmt.Fprintf(os.Stderr, "Error: %s has no changes against %s\n", head, base)
+			os.Exit(1)
+		}
+
+		fmt.Printf("🤖 Reviewing '%s'...\n", head)
+		r, callUsage, err := c.review(head, changes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error calling %s API: %v\n", client.Name(), err)
+			os.Exit(1)
+		}
+		usage.InputTokens += callUsage.InputTokens
+		usage.OutputTokens += callUsage.OutputTokens
+		reviews = append(reviews, r)
+	}
+
+	fmt.Println("⚖️  Comparing the implementations...")
+	fmt.Println()
+	analysis, callUsage, err := c.synthesize(base, reviews)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error calling %s API: %v\n", client.Name(), err)
+		os.Exit(1)
+	}
+	usage.InputTokens += callUsage.InputTokens
+	usage.OutputTokens += callUsage.OutputTokens
+
+	report := formatCompareReport(base, analysis, reviews)
+	if err := writeReviewToFile(*outputFile, report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing comparison to file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Comparison written to: %s\n\n", *outputFile)
+	common.printTranscript()
+
+	printReport("IMPLEMENTATION COMPARISON", report, usage)
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestComparer tests reviewing two implementations and synthesizing a
+// comparison from their reviews
+func TestComparer(t *testing.T) {
+	fake := &fakeClaude{responses: []string{
+		`{"content":[{"type":"text","text":"Uses a mutex.\n<findings>[{\"file\":\"cache.go\",\"line\":3,\"severity\":\"medium\",\"category\":\"performance\",\"title\":\"Lock held during I/O\",\"message\":\"m\"}]</findings>"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`,
+		`{"content":[{"type":"text","text":"Uses sync.Map.\n<findings>[]</findings>"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":5}}`,
+		`{"content":[{"type":"text","text":"Recommend lock-free."}],"stop_reason":"end_turn","usage":{"input_tokens":30,"output_tokens":5}}`,
+	}}
+	c := &comparer{client: fake.serve(t), opts: CompletionOptions{Model: "m", MaxTokens: 100}, cfg: &Config{}}
+
+	var reviews []*headReview
+	for _, head := range []string{"mutex", "lock-free"} {
+		changes := &branchChanges{Diff: "diff --git a/cache.go b/cache.go\n+// " + head + "\n", ChangedFiles: "M\tcache.go"}
+		r, _, err := c.review(head, changes)
+		if err != nil {
+			t.Fatalf("review(%s) returned error: %v", head, err)
+		}
+		reviews = append(reviews, r)
+	}
+	if len(reviews[0].Findings) != 1 || reviews[0].Review != "Uses a mutex." {
+		t.Errorf("first review = %+v", reviews[0])
+	}
+
+	analysis, _, err := c.synthesize("main", reviews)
+	if err != nil {
+		t.Fatalf("synthesize() returned error: %v", err)
+	}
+	prompt := fake.requests[2].Messages[0].Content
+	for _, want := range []string{"`mutex` and `lock-free`", "## Implementation `lock-free`", "+// mutex", "Uses sync.Map.", "Lock held during I/O"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("synthesis prompt missing %q", want)
+		}
+	}
+
+	report := formatCompareReport("main", analysis, reviews)
+	for _, want := range []string{"# Comparison: `mutex` vs `lock-free`", "## Analysis\n\nRecommend lock-free.", "# Review of `lock-free`", "## Findings"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// nestedCommands are the subcommands that take a command of their own
+var nestedCommands = map[string][]string{
+	"baseline": {"update"},
+	"config":   {"show"},
+	"history":  {"list", "export", "import"},
+	"hook":     {"install", "uninstall"},
+	"policy":   {"keygen", "sign", "show"},
+}
+
+// flaglessCommands take no flags, so their usage isn't asked for: `policy
+// keygen -h` would generate a key
+var flaglessCommands = map[string]bool{
+	"policy keygen": true,
+	"policy show":   true,
+}
+
+// branchArgs are the commands whose arguments are branches
+var branchArgs = map[string]bool{
+	"compare":    true,
+	"postmortem": true,
+}
+
+// completionFlag is a flag offered for completion
+type completionFlag struct {
+	Name       string
+	TakesValue bool
+}
+
+// runCompletion implements `pr-review completion bash|zsh|fish`, printing a
+// script that completes the command in that shell. The scripts ask the
+// binary for the candidates with `pr-review __complete`, so they never go
+// out of date.
+func runCompletion(args []string) {
+	scripts := map[string]string{"bash": bashCompletion, "zsh": zshCompletion, "fish": fishCompletion}
+	if len(args) != 1 || scripts[args[0]] == "" {
+		fmt.Fprintln(os.Stderr, "Usage: pr-review completion bash|zsh|fish")
+		os.Exit(2)
+	}
+	fmt.Print(scripts[args[0]])
+}
+
+// runComplete implements the hidden `pr-review __complete <word>...` the
+// completion scripts call with the words of the command line after
+// pr-review, the last being the word to complete. It prints a candidate
+// per line, or nothing to let the shell complete a file name.
+func runComplete(args []string) {
+	for _, c := range completeWords(args, commandFlags) {
+		fmt.Println(c)
+	}
+}
+
+// completeWords returns the candidates for the last of words, given the
+// flags of each command
+func completeWords(words []string, flagsOf func(command string) []completionFlag) []string {
+	if len(words) == 0 {
+		return nil
+	}
+	current, before := words[len(words)-1], words[:len(words)-1]
+
+	// Subcommands are only recognized as the first word, as in main
+	command := ""
+	if len(before) == 0 && !strings.HasPrefix(current, "-") {
+		names := make([]string, 0, len(subcommands))
+		for name := range subcommands {
+			names = append(names, name)
+		}
+		return matching(names, current)
+	}
+	if len(before) > 0 && subcommands[before[0]] != nil {
+		command, before = before[0], before[1:]
+		if nested, ok := nestedCommands[command]; ok {
+			if len(before) == 0 {
+				return matching(nested, current)
+			}
+			command, before = command+" "+before[0], before[1:]
+		}
+	}
+	if flaglessCommands[command] {
+		return nil
+	}
+	flags := flagsOf(command)
+	takesValue := func(arg string) (string, bool) {
+		name := strings.TrimLeft(arg, "-")
+		for _, f := range flags {
+			if f.Name == name {
+				return name, f.TakesValue
+			}
+		}
+		return "", false
+	}
+
+	// -flag=value
+	if strings.HasPrefix(current, "-") && strings.Contains(current, "=") {
+		arg, value, _ := strings.Cut(current, "=")
+		name, ok := takesValue(arg)
+		if !ok {
+			return nil
+		}
+		var candidates []string
+		for _, v := range matching(flagValues(command, name), value) {
+			candidates = append(candidates, arg+"="+v)
+		}
+		return candidates
+	}
+	// -flag value
+	if len(before) > 0 {
+		prev := before[len(before)-1]
+		if strings.HasPrefix(prev, "-") && !strings.Contains(prev, "=") {
+			if name, ok := takesValue(prev); ok {
+				return matching(flagValues(command, name), current)
+			}
+		}
+	}
+	if strings.HasPrefix(current, "-") {
+		// Keep the dashes typed: Go flags take one or two
+		dashes := "-"
+		if strings.HasPrefix(current, "--") {
+			dashes = "--"
+		}
+		names := make([]string, len(flags))
+		for i, f := range flags {
+			names[i] = dashes + f.Name
+		}
+		return matching(names, current)
+	}
+	if branchArgs[command] {
+		return matching(gitBranches(), current)
+	}
+	return nil
+}
+
+// matching returns the sorted candidates starting with prefix
+func matching(candidates []string, prefix string) []string {
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// flagValues returns the values to offer for a flag of command, or nil to
+// let the shell complete a file name
+func flagValues(command, name string) []string {
+	switch name {
+	case "branch", "base":
+		return gitBranches()
+	case "provider":
+		providers := make([]string, 0, len(defaultModels))
+		for p := range defaultModels {
+			providers = append(providers, p)
+		}
+		return providers
+	case "profile":
+		global, err := loadGlobalConfig()
+		if err != nil {
+			return nil
+		}
+		var names []string
+		for p := range global.Profiles {
+			names = append(names, p)
+		}
+		return names
+	case "format":
+		if command == "" || command == "config show" {
+			return formats
+		}
+	case "group-by":
+		if command == "" {
+			return []string{groupBySeverity, groupByFile, groupByCategory}
+		}
+	case "change-type":
+		if command == "" {
+			return changeTypes
+		}
+	case "hook":
+		if command == "" {
+			return []string{"pre-commit"}
+		}
+	}
+	return nil
+}
+
+// gitBranches returns the names of the local branches
+func gitBranches() []string {
+	out, err := gitCommand("for-each-ref", "--format=%(refname:short)", "refs/heads").Output()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(out))
+}
+
+// commandFlags returns the flags of command, "" being the review, from
+// the usage it prints for -h, so they are always those of this binary
+func commandFlags(command string) []completionFlag {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	out, _ := exec.Command(exe, append(strings.Fields(command), "-h")...).CombinedOutput()
+	return parseUsageFlags(string(out))
+}
+
+// parseUsageFlags reads the flags from the usage flag.PrintDefaults writes:
+// "  -name type" lines for flags that take a value, "  -name" for booleans.
+// A short name's usage may follow on the same line after a tab.
+func parseUsageFlags(usage string) []completionFlag {
+	var flags []completionFlag
+	for _, line := range strings.Split(usage, "\n") {
+		if !strings.HasPrefix(line, "  -") {
+			continue
+		}
+		head, _, _ := strings.Cut(line, "\t")
+		fields := strings.Fields(head)
+		flags = append(flags, completionFlag{Name: strings.TrimPrefix(fields[0], "-"), TakesValue: len(fields) > 1})
+	}
+	return flags
+}
+
+// bashCompletion completes pr-review in bash. The line is split here
+// rather than taken from COMP_WORDS, which splits -flag=value at the "=";
+// bash then replaces only the part after it.
+const bashCompletion = `# bash completion for pr-review
+# Add to ~/.bashrc: source <(pr-review completion bash)
+_pr_review() {
+	local line="${COMP_LINE:0:COMP_POINT}" words
+	read -ra words <<< "$line"
+	[[ $line == *[[:space:]] ]] && words+=("")
+	local IFS=$'\n'
+	COMPREPLY=($(pr-review __complete "${words[@]:1}" 2>/dev/null))
+	if [[ ${words[${#words[@]}-1]} == -*=* ]]; then
+		COMPREPLY=("${COMPREPLY[@]#*=}")
+	fi
+}
+complete -o default -F _pr_review pr-review
+`
+
+// zshCompletion completes pr-review in zsh
+const zshCompletion = `#compdef pr-review
+# Add to ~/.zshrc, after compinit: source <(pr-review completion zsh)
+_pr_review() {
+	local -a candidates
+	candidates=(${(f)"$(pr-review __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+	if (( ${#candidates} )); then
+		compadd -- $candidates
+	else
+		_files
+	fi
+}
+compdef _pr_review pr-review
+`
+
+// fishCompletion completes pr-review in fish
+const fishCompletion = `# fish completion for pr-review
+# Save as ~/.config/fish/completions/pr-review.fish:
+#   pr-review completion fish > ~/.config/fish/completions/pr-review.fish
+function __pr_review_complete
+	set -l words (commandline -opc)
+	set -e words[1]
+	set -l candidates (pr-review __complete $words (commandline -ct | string collect --allow-empty) 2>/dev/null)
+	if test (count $candidates) -gt 0
+		printf '%s\n' $candidates
+	else
+		__fish_complete_path (commandline -ct)
+	end
+end
+complete -c pr-review -f -a '(__pr_review_complete)'
+`
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"testing"
+)
+
+// TestParseUsageFlags tests reading the flags from a flag set's usage
+func TestParseUsageFlags(t *testing.T) {
+	fs := flag.NewFlagSet("history export", flag.ContinueOnError)
+	fs.Bool("all", false, "Export every repository")
+	fs.Bool("q", false, "Quiet")
+	fs.String("o", "", "Write to this file")
+	fs.String("format", "jsonl", "Output format")
+	var usage bytes.Buffer
+	fs.SetOutput(&usage)
+	fs.PrintDefaults()
+
+	want := []completionFlag{{"all", false}, {"format", true}, {"o", true}, {"q", false}}
+	if got := parseUsageFlags(usage.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseUsageFlags() = %v, want %v", got, want)
+	}
+}
+
+// TestCompleteWords tests the candidates for the word being completed
+func TestCompleteWords(t *testing.T) {
+	flagsOf := func(command string) []completionFlag {
+		switch command {
+		case "":
+			return []completionFlag{{"format", true}, {"force", false}, {"output", true}, {"provider", true}}
+		case "history export":
+			return []completionFlag{{"all", false}, {"format", true}}
+		}
+		t.Errorf("flags of %q asked for", command)
+		return nil
+	}
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"hist"}, []string{"history"}},
+		{[]string{"history", ""}, []string{"export", "import", "list"}},
+		{[]string{"history", "export", "-"}, []string{"-all", "-format"}},
+		{[]string{"-f"}, []string{"-force", "-format"}},
+		{[]string{"--fo"}, []string{"--force", "--format"}},
+		{[]string{"-format", ""}, []string{"codeclimate", "gh-annotations", "json", "junit", "markdown", "sarif"}},
+		{[]string{"-format=s"}, []string{"-format=sarif"}},
+		{[]string{"-provider", "o"}, []string{"openai"}},
+		// File names are left to the shell
+		{[]string{"-output", ""}, nil},
+		{[]string{"-force", ""}, nil},
+		// The export's -format isn't the review's
+		{[]string{"history", "export", "-format", ""}, nil},
+		// Nor is a flagless command asked for its usage
+		{[]string{"policy", "keygen", "-"}, nil},
+	}
+	for _, tt := range tests {
+		if got := completeWords(tt.words, flagsOf); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("completeWords(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+	"gopkg.in/yaml.v3"
+)
+
+// repoConfigFile is the name of the per-repository config file, looked up at
+// the top level of the repository
+const repoConfigFile = ".pr-review.yaml"
+
+// Config holds settings loaded from the repository config file
+type Config struct {
+	// Team owns the repository; review history and stats are grouped by it
+	Team string `yaml:"team"`
+
+	// SeverityCalibration defines what each severity means for this repo
+	SeverityCalibration []CalibrationRule `yaml:"severity_calibration"`
+
+	// CriticalPaths are glob patterns (e.g. "auth/", "db/migrations/**") for
+	// code where mistakes are costly. Findings in matching files are
+	// escalated one severity level, and the report calls the changes out.
+	CriticalPaths []string `yaml:"critical_paths"`
+
+	// SeverityRules escalate findings meeting their conditions, after the
+	// model's output is calibrated
+	SeverityRules []SeverityRule `yaml:"severity_rules"`
+
+	// PreReview lists shell commands (e.g. "make lint") run before the
+	// review; their pass/fail status and output are included as context
+	PreReview []string `yaml:"pre_review"`
+
+	// Retention limits the review history kept for the repository; it is
+	// applied after each review and by `pr-review clean`
+	Retention Retention `yaml:"retention"`
+
+	// FailOn fails the review (exit status 1) when a finding is at least
+	// this severe
+	FailOn *Severity `yaml:"fail_on"`
+
+	// ChangeTypes tailor the rubric and gate to the type of change
+	ChangeTypes map[string]ChangeTypeConfig `yaml:"change_types"`
+
+	// Model (unless -provider is given), ThinkingBudget, Context and Format
+	// are defaults for -model, -thinking-budget, -context and -format; the
+	// command line wins. Context paths are relative to the repository.
+	Model          string   `yaml:"model"`
+	ThinkingBudget int      `yaml:"thinking_budget"`
+	Context        []string `yaml:"context"`
+	Format         string   `yaml:"format"`
+
+	// ContextLines is the default for -context-lines, the lines of context
+	// the diff shows around each change
+	ContextLines *int `yaml:"context_lines"`
+
+	// Exclude are glob patterns for files left out of the review, such as
+	// vendored or generated code
+	Exclude []string `yaml:"exclude"`
+
+	// PromptSections are added to every review prompt, e.g. the team's
+	// conventions or what to pay attention to
+	PromptSections []promptSection `yaml:"prompt_sections"`
+
+	// rubric is the review instructions for the type of change under
+	// review; empty means the full rubric
+	rubric string
+
+	// policyFailOn is the org policy's gate, which FailOn can only make
+	// stricter
+	policyFailOn *Severity
+}
+
+// CalibrationRule pins the severity of a kind of issue, e.g. "missing test"
+// is always medium and "SQL injection" is always critical
+type CalibrationRule struct {
+	Issue    string   `yaml:"issue"`
+	Severity Severity `yaml:"severity"`
+}
+
+// loadConfig reads the config file at path. A missing file yields an empty config.
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	for i, rule := range cfg.SeverityCalibration {
+		if strings.TrimSpace(rule.Issue) == "" {
+			return nil, fmt.Errorf("%s: severity_calibration[%d] has no issue", path, i)
+		}
+	}
+	for i, rule := range cfg.SeverityRules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("%s: severity_rules[%d]: %w", path, i, err)
+		}
+	}
+	for name := range cfg.ChangeTypes {
+		if err := validateChangeType(name); err != nil {
+			return nil, fmt.Errorf("%s: change_types: %w", path, err)
+		}
+	}
+	if cfg.ThinkingBudget < 0 {
+		return nil, fmt.Errorf("%s: thinking_budget must not be negative", path)
+	}
+	if cfg.ContextLines != nil && *cfg.ContextLines < 0 {
+		return nil, fmt.Errorf("%s: context_lines must not be negative", path)
+	}
+	if cfg.Format != "" {
+		if err := validateFormat(cfg.Format); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for i, section := range cfg.PromptSections {
+		if strings.TrimSpace(section.Title) == "" || strings.TrimSpace(section.Body) == "" {
+			return nil, fmt.Errorf("%s: prompt_sections[%d] needs a title and a body", path, i)
+		}
+	}
+	return &cfg, nil
+}
+
+// applyDefaults sets the flags of fs the config has defaults for, unless
+// they were given on the command line. root is the repository's top level.
+func (cfg *Config) applyDefaults(fs *flag.FlagSet, root string) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	defaults := map[string]string{"format": cfg.Format}
+	if !given["provider"] {
+		// Another provider's default model wouldn't make sense
+		defaults["model"] = cfg.Model
+	}
+	if cfg.ThinkingBudget > 0 {
+		defaults["thinking-budget"] = strconv.Itoa(cfg.ThinkingBudget)
+	}
+	if cfg.ContextLines != nil {
+		defaults["context-lines"] = strconv.Itoa(*cfg.ContextLines)
+	}
+	var context []string
+	for _, file := range cfg.Context {
+		context = append(context, expandPath(file, root))
+	}
+	defaults["context"] = strings.Join(context, ",")
+
+	for name, value := range defaults {
+		if value == "" || given[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", strings.ReplaceAll(name, "-", "_"), err)
+		}
+	}
+	return nil
+}
+
+// pathFilter chooses the changed files to review: those matching one of
+// Include, or any file if it is empty, and not excluded by Exclude, whose
+// patterns apply in order as in .gitignore (see matchIgnore)
+type pathFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// keeps reports whether the filter keeps the file at path
+func (p pathFilter) keeps(path string) bool {
+	return (len(p.Include) == 0 || matchAnyGlob(p.Include, path)) && !matchIgnore(p.Exclude, path)
+}
+
+// excludeFiles leaves the files matching patterns out of the changes,
+// returning the files left out
+func excludeFiles(changes *branchChanges, patterns []string) []string {
+	if len(patterns) == 0 {
+		return nil
+	}
+	return filterFiles(changes, pathFilter{Exclude: patterns}.keeps)
+}
+
+// filterFiles leaves the files keep rejects out of the diff and changed
+// files, returning the files left out
+func filterFiles(changes *branchChanges, keep func(path string) bool) []string {
+	var kept []gitdiff.File
+	var excluded []string
+	for _, f := range gitdiff.Split(changes.Diff) {
+		if keep(f.Path) {
+			kept = append(kept, f)
+		} else {
+			excluded = append(excluded, f.Path)
+		}
+	}
+	if len(excluded) == 0 {
+		return nil
+	}
+	diff := gitdiff.Join(kept)
+	if diff != "" && strings.HasSuffix(changes.Diff, "\n") && !strings.HasSuffix(diff, "\n") {
+		diff += "\n"
+	}
+	changes.Diff = diff
+
+	// Changed files are listed as "M\tpath", or "R100\told\tnew" for renames
+	var files []string
+	for _, line := range strings.Split(changes.ChangedFiles, "\n") {
+		fields := strings.Split(line, "\t")
+		if keep(fields[len(fields)-1]) {
+			files = append(files, line)
+		}
+	}
+	changes.ChangedFiles = strings.Join(files, "\n")
+	return excluded
+}
+
+// filterCommits leaves the commits of a log (one per line, as from
+// gitdiff.Git.Log) that only change files keep rejects out of it.
+// Commits whose files commitFiles can't list, such as merges or commits
+// not fetched, are kept.
+func filterCommits(log string, keep func(path string) bool, commitFiles func(sha string) ([]string, error)) string {
+	if log == "" {
+		return log
+	}
+	var kept []string
+	for _, line := range strings.Split(log, "\n") {
+		sha, _, _ := strings.Cut(line, " ")
+		files, err := commitFiles(sha)
+		if err != nil || len(files) == 0 || slices.ContainsFunc(files, keep) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// excludedInstructions tells the review which files were left out
+func excludedInstructions(excluded []string) string {
+	var b strings.Builder
+	b.WriteString("The repository's configuration or the command line excludes the files below from review, or they are generated, so their changes are left out of the diff. Don't review them or ask for them.\n\n")
+	for _, file := range excluded {
+		fmt.Fprintf(&b, "- `%s`\n", file)
+	}
+	return b.String()
+}
+
+// globalConfigFile is the name of the global config file in the config
+// directory
+const globalConfigFile = "config.yaml"
+
+// GlobalConfig holds the user's settings that apply to every repository
+type GlobalConfig struct {
+	// DataDir and CacheDir replace the default data and cache directories;
+	// relative paths are relative to the config file
+	DataDir  string `yaml:"data_dir"`
+	CacheDir string `yaml:"cache_dir"`
+
+	// Providers holds settings for each LLM provider, by -provider name
+	Providers map[string]ProviderConfig `yaml:"providers"`
+
+	// UserID identifies the user to the provider for usage attribution,
+	// unless -user-id is given
+	UserID string `yaml:"user_id"`
+
+	// Storage selects where review history and the upload cache are kept
+	Storage StorageConfig `yaml:"storage"`
+
+	// Profiles are named bundles of settings selected with -profile;
+	// DefaultProfile is used without it
+	Profiles       map[string]Profile `yaml:"profiles"`
+	DefaultProfile string             `yaml:"default_profile"`
+}
+
+// ProviderConfig holds the settings for one LLM provider
+type ProviderConfig struct {
+	// Headers are added to every API request, e.g. a gateway's routing key,
+	// anthropic-beta features or cost-center tags
+	Headers map[string]string `yaml:"headers"`
+}
+
+// loadGlobalConfig reads the global config file (see configPath). A missing
+// file yields an empty config, unless it was named with -config.
+func loadGlobalConfig() (*GlobalConfig, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path.Path)
+	if os.IsNotExist(err) && configFlag == "" {
+		return &GlobalConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path.Path, err)
+	}
+
+	var cfg GlobalConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path.Path, err)
+	}
+	if err := cfg.validateProfiles(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path.Path, err)
+	}
+	base := filepath.Dir(path.Path)
+	cfg.DataDir = expandPath(cfg.DataDir, base)
+	cfg.CacheDir = expandPath(cfg.CacheDir, base)
+	return &cfg, nil
+}
+
+// calibrationPrompt describes the severity calibration to the model
+func calibrationPrompt(rules []CalibrationRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("## Severity Calibration\n\n")
+	b.WriteString("This repository defines the following severities. When a finding is one of\n")
+	b.WriteString("these issue types, use exactly this severity and set its category to the\n")
+	b.WriteString("issue type as written:\n\n")
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "- %s: %s\n", rule.Issue, rule.Severity)
+	}
+	return b.String()
+}
+
+// applyCalibration enforces the calibrated severity on findings whose category
+// or title names a calibrated issue type. The first matching rule wins.
+func applyCalibration(findings []Finding, rules []CalibrationRule) {
+	for i := range findings {
+		for _, rule := range rules {
+			if rule.matches(findings[i]) {
+				findings[i].Severity = rule.Severity
+				break
+			}
+		}
+	}
+}
+
+func (r CalibrationRule) matches(f Finding) bool {
+	issue := strings.ToLower(strings.TrimSpace(r.Issue))
+	return strings.ToLower(strings.TrimSpace(f.Category)) == issue ||
+		strings.Contains(strings.ToLower(f.Title), issue)
+}
@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadConfig_Missing tests that a missing config file yields an empty config
+func TestLoadConfig_Missing(t *testing.T) {
+	cfg, err := loadConfig(filepath.Join(t.TempDir(), repoConfigFile))
+	if err != nil {
+		t.Fatalf("loadConfig() returned error: %v", err)
+	}
+	if len(cfg.SeverityCalibration) != 0 {
+		t.Errorf("expected empty config, got %+v", cfg)
+	}
+}
+
+// TestLoadConfig_Calibration tests parsing severity calibration rules
+func TestLoadConfig_Calibration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), repoConfigFile)
+	content := `severity_calibration:
+  - issue: missing test
+    severity: medium
+  - issue: SQL injection
+    severity: critical
+  - issue: naming nit
+    severity: info
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() returned error: %v", err)
+	}
+
+	want := []CalibrationRule{
+		{"missing test", SeverityMedium},
+		{"SQL injection", SeverityCritical},
+		{"naming nit", SeverityInfo},
+	}
+	if len(cfg.SeverityCalibration) != len(want) {
+		t.Fatalf("got %d rules, want %d", len(cfg.SeverityCalibration), len(want))
+	}
+	for i, rule := range want {
+		if cfg.SeverityCalibration[i] != rule {
+			t.Errorf("rule %d = %+v, want %+v", i, cfg.SeverityCalibration[i], rule)
+		}
+	}
+}
+
+// TestLoadConfig_InvalidSeverity tests that unknown severities are rejected
+func TestLoadConfig_InvalidSeverity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), repoConfigFile)
+	content := "severity_calibration:\n  - issue: typo\n    severity: meh\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	if _, err := loadConfig(path); err == nil {
+		t.Error("loadConfig() expected error for unknown severity")
+	}
+}
+
+// TestApplyCalibration tests that calibrated severities override the model's
+func TestApplyCalibration(t *testing.T) {
+	rules := []CalibrationRule{
+		{"missing test", SeverityMedium},
+		{"SQL injection", SeverityCritical},
+	}
+	findings := []Finding{
+		{Category: "Missing Test", Severity: SeverityLow, Title: "No test for parser"},
+		{Category: "security", Severity: SeverityHigh, Title: "Possible SQL injection in search"},
+		{Category: "style", Severity: SeverityLow, Title: "Long line"},
+	}
+
+	applyCalibration(findings, rules)
+
+	want := []Severity{SeverityMedium, SeverityCritical, SeverityLow}
+	for i, sev := range want {
+		if findings[i].Severity != sev {
+			t.Errorf("finding %d severity = %v, want %v", i, findings[i].Severity, sev)
+		}
+	}
+}
+
+// TestCalibrationPrompt tests the calibration section injected into the prompt
+func TestCalibrationPrompt(t *testing.T) {
+	if calibrationPrompt(nil) != "" {
+		t.Error("calibrationPrompt(nil) should be empty")
+	}
+
+	got := calibrationPrompt([]CalibrationRule{{"naming nit", SeverityInfo}})
+	if !strings.Contains(got, "- naming nit: info") {
+		t.Errorf("calibrationPrompt() = %q", got)
+	}
+}
+
+// TestLoadConfig_PreReview tests parsing pre-review commands
+func TestLoadConfig_PreReview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), repoConfigFile)
+	content := "pre_review:\n  - make lint\n  - npm test -- --changed\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() returned error: %v", err)
+	}
+	if len(cfg.PreReview) != 2 || cfg.PreReview[1] != "npm test -- --changed" {
+		t.Errorf("PreReview = %q", cfg.PreReview)
+	}
+}
+
+// TestLoadConfig_Defaults tests that the repository's defaults apply to
+// flags not given on the command line
+func TestLoadConfig_Defaults(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, repoConfigFile)
+	content := `model: claude-opus-4-1
+thinking_budget: 8000
+context: [docs/ARCHITECTURE.md, /etc/review/style.md]
+format: json
+context_lines: 10
+prompt_sections:
+  - title: Conventions
+    body: Errors are wrapped with fmt.Errorf and %w.
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() returned error: %v", err)
+	}
+	if len(cfg.PromptSections) != 1 || cfg.PromptSections[0].Title != "Conventions" {
+		t.Errorf("PromptSections = %+v", cfg.PromptSections)
+	}
+
+	newFlags := func(args ...string) (*flag.FlagSet, *commonFlags, *string) {
+		fs := flag.NewFlagSet("review", flag.ContinueOnError)
+		common := addCommonFlags(fs)
+		format := fs.String("format", formatMarkdown, "")
+		fs.Int("context-lines", defaultContextLines, "")
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.applyDefaults(fs, root); err != nil {
+			t.Fatalf("applyDefaults() returned error: %v", err)
+		}
+		return fs, common, format
+	}
+
+	fs, common, format := newFlags()
+	if lines := fs.Lookup("context-lines").Value.String(); lines != "10" {
+		t.Errorf("context-lines = %s, want 10", lines)
+	}
+	if *common.model != "claude-opus-4-1" || *common.thinkingBudget != 8000 || *format != formatJSON {
+		t.Errorf("defaults not applied: model %q, thinking budget %d, format %q", *common.model, *common.thinkingBudget, *format)
+	}
+	if want := filepath.Join(root, "docs", "ARCHITECTURE.md") + ",/etc/review/style.md"; *common.contextFiles != want {
+		t.Errorf("context = %q, want %q", *common.contextFiles, want)
+	}
+
+	_, common, format = newFlags("-model", "claude-sonnet-4-5", "-format", "markdown", "-context", "notes.md")
+	if *common.model != "claude-sonnet-4-5" || *format != formatMarkdown || *common.contextFiles != "notes.md" || *common.thinkingBudget != 8000 {
+		t.Errorf("the command line didn't win: model %q, format %q, context %q", *common.model, *format, *common.contextFiles)
+	}
+
+	// The model is another provider's, so it only applies to the default one
+	if _, common, _ = newFlags("-provider", "openai"); *common.model != "" {
+		t.Errorf("model = %q with -provider openai, want the provider's default", *common.model)
+	}
+
+	for _, bad := range []string{"format: yaml\n", "thinking_budget: -1\n", "prompt_sections:\n  - title: Empty\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("loadConfig() accepted %q", bad)
+		}
+	}
+}
+
+// TestExcludeFiles tests leaving the files matching exclude out of the
+// changes under review
+func TestExcludeFiles(t *testing.T) {
+	changes := &branchChanges{
+		Diff: "diff --git a/app.go b/app.go\n+app\n" +
+			"diff --git a/vendor/lib/lib.go b/vendor/lib/lib.go\n+lib\n" +
+			"diff --git a/api/types.pb.go b/api/types.pb.go\n+generated\n",
+		ChangedFiles: "M\tapp.go\nA\tvendor/lib/lib.go\nR100\tapi/old.pb.go\tapi/types.pb.go",
+	}
+	excluded := excludeFiles(changes, []string{"vendor/", "*.pb.go"})
+	if strings.Join(excluded, ",") != "vendor/lib/lib.go,api/types.pb.go" {
+		t.Errorf("excluded = %q", excluded)
+	}
+	if changes.Diff != "diff --git a/app.go b/app.go\n+app\n" || changes.ChangedFiles != "M\tapp.go" {
+		t.Errorf("changes after exclusion = %+v", changes)
+	}
+	if !strings.Contains(excludedInstructions(excluded), "- `vendor/lib/lib.go`") {
+		t.Error("instructions don't list the excluded files")
+	}
+	if excludeFiles(changes, nil) != nil || excludeFiles(changes, []string{"docs/"}) != nil {
+		t.Error("excludeFiles() excluded files that don't match")
+	}
+}
+
+// TestFilterFiles tests reviewing only the files -include and -exclude keep
+func TestFilterFiles(t *testing.T) {
+	changes := &branchChanges{
+		Diff: "diff --git a/src/app.go b/src/app.go\n+app\n" +
+			"diff --git a/src/vendor/x.go b/src/vendor/x.go\n+x\n" +
+			"diff --git a/web/app.min.js b/web/app.min.js\n+min\n",
+		ChangedFiles: "M\tsrc/app.go\nA\tsrc/vendor/x.go\nM\tweb/app.min.js",
+	}
+	filtered := filterFiles(changes, pathFilter{Include: []string{"src/**"}, Exclude: []string{"**/vendor/**"}}.keeps)
+	if strings.Join(filtered, ",") != "src/vendor/x.go,web/app.min.js" {
+		t.Errorf("filtered = %q", filtered)
+	}
+	if changes.Diff != "diff --git a/src/app.go b/src/app.go\n+app\n" || changes.ChangedFiles != "M\tsrc/app.go" {
+		t.Errorf("changes after filtering = %+v", changes)
+	}
+	if filterFiles(changes, pathFilter{}.keeps) != nil {
+		t.Error("filterFiles() without patterns left files out")
+	}
+}
+
+// TestFilterCommits tests leaving out the commits that only change
+// filtered files
+func TestFilterCommits(t *testing.T) {
+	log := "aaa1111 - Bump bundle (Ann, 1 day ago)\nbbb2222 - Fix app (Bo, 2 days ago)\nccc3333 - Merge main (Ann, 3 days ago)"
+	files := map[string][]string{"aaa1111": {"web/app.min.js"}, "bbb2222": {"web/app.min.js", "src/app.go"}}
+	got := filterCommits(log, pathFilter{Exclude: []string{"*.min.js"}}.keeps, func(sha string) ([]string, error) {
+		return files[sha], nil
+	})
+	if want := "bbb2222 - Fix app (Bo, 2 days ago)\nccc3333 - Merge main (Ann, 3 days ago)"; got != want {
+		t.Errorf("filterCommits() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package cli
+
+import "strings"
+
+// modelPrice is the list price of a model family in USD per million tokens
+type modelPrice struct {
+	prefix string
+	input  float64
+	output float64
+}
+
+// modelPrices lists known model families, most specific prefix first
+var modelPrices = []modelPrice{
+	{"claude-opus-4-5", 5, 25},
+	{"claude-opus-4", 15, 75},
+	{"claude-sonnet-4", 3, 15},
+	{"claude-3-7-sonnet", 3, 15},
+	{"claude-haiku-4-5", 1, 5},
+	{"claude-3-5-haiku", 0.8, 4},
+	{"gpt-4o-mini", 0.15, 0.6},
+	{"gpt-4o", 2.5, 10},
+	{"o4-mini", 1.1, 4.4},
+}
+
+// estimateCost returns the list-price cost in USD of a model call, and false
+// if the model's price is unknown
+func estimateCost(model string, inputTokens, outputTokens int) (float64, bool) {
+	// Bedrock model IDs wrap the name, e.g. us.anthropic.claude-...-v1:0
+	if _, name, ok := strings.Cut(model, "anthropic."); ok {
+		model = name
+	}
+	for _, p := range modelPrices {
+		if strings.HasPrefix(model, p.prefix) {
+			return (float64(inputTokens)*p.input + float64(outputTokens)*p.output) / 1e6, true
+		}
+	}
+	return 0, false
+}
+
+// Prompt cache writes and reads are priced relative to a model's input
+const (
+	cacheWritePriceFactor = 1.25
+	cacheReadPriceFactor  = 0.1
+)
+
+// usageCost returns the list-price cost in USD of a request's usage,
+// including prompt cache writes and reads, and false if the model's price
+// is unknown
+func usageCost(model string, u Usage) (float64, bool) {
+	cost, ok := estimateCost(model, u.InputTokens, u.OutputTokens)
+	if !ok {
+		return 0, false
+	}
+	cache, _ := estimateCost(model, u.CacheCreationInputTokens, 0)
+	read, _ := estimateCost(model, u.CacheReadInputTokens, 0)
+	return cost + cache*cacheWritePriceFactor + read*cacheReadPriceFactor, true
+}
+
+// modelUsage totals a run's usage per model, for runs that call more than
+// one, such as a cheap pre-screen before the deep review
+type modelUsage map[string]Usage
+
+// add adds the usage of a request to model
+func (m modelUsage) add(model string, u Usage) {
+	total := m[model]
+	total.Add(u)
+	m[model] = total
+}
+
+// cost returns the list-price cost in USD of the run, each model's usage at
+// its own price, and false if any model's price is unknown
+func (m modelUsage) cost() (float64, bool) {
+	var total float64
+	known := true
+	for model, u := range m {
+		cost, ok := usageCost(model, u)
+		total += cost
+		known = known && ok
+	}
+	return total, known
+}
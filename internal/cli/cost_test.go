@@ -0,0 +1,45 @@
+package cli
+
+import "testing"
+
+// TestEstimateCost tests pricing by model family
+func TestEstimateCost(t *testing.T) {
+	tests := []struct {
+		model string
+		want  float64
+		known bool
+	}{
+		{"claude-sonnet-4-5-20250929", 3 + 15, true},
+		{"claude-opus-4-5-20251101", 5 + 25, true},
+		{"claude-opus-4-1-20250805", 15 + 75, true},
+		{"us.anthropic.claude-sonnet-4-5-20250929-v1:0", 3 + 15, true},
+		{"gpt-4o-2024-08-06", 2.5 + 10, true},
+		{"gpt-4o-mini", 0.15 + 0.6, true},
+		{"llama-3.1-70b", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := estimateCost(tt.model, 1_000_000, 1_000_000)
+		if got != tt.want || ok != tt.known {
+			t.Errorf("estimateCost(%q) = %v, %v; want %v, %v", tt.model, got, ok, tt.want, tt.known)
+		}
+	}
+}
+
+// TestModelUsage_Cost tests that each model's usage is priced at its own rate
+func TestModelUsage_Cost(t *testing.T) {
+	usage := modelUsage{}
+	usage.add("claude-sonnet-4-5-20250929", Usage{InputTokens: 1_000_000})
+	usage.add("claude-haiku-4-5-20251001", Usage{InputTokens: 1_000_000, CacheReadInputTokens: 1_000_000})
+	usage.add("claude-sonnet-4-5-20250929", Usage{OutputTokens: 1_000_000})
+	if got, ok := usage.cost(); got != 3+15+1+0.1 || !ok {
+		t.Errorf("cost() = %v, %v; want %v, true", got, ok, 3+15+1+0.1)
+	}
+	if u := usage["claude-haiku-4-5-20251001"]; u.CacheReadInputTokens != 1_000_000 {
+		t.Errorf("cache reads not totaled: %+v", u)
+	}
+
+	usage.add("llama-3.1-70b", Usage{InputTokens: 1000})
+	if _, ok := usage.cost(); ok {
+		t.Error("cost() with an unpriced model reported a known cost")
+	}
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// criticalFiles returns the files that match a critical path pattern
+func criticalFiles(files, patterns []string) []string {
+	var critical []string
+	for _, f := range files {
+		if matchAnyGlob(patterns, f) {
+			critical = append(critical, f)
+		}
+	}
+	return critical
+}
+
+// escalateCritical raises findings in critical-path files by one severity
+// level, up to critical, and marks them
+func escalateCritical(findings []Finding, patterns []string) {
+	for i := range findings {
+		f := &findings[i]
+		if f.File == "" || !matchAnyGlob(patterns, f.File) {
+			continue
+		}
+		f.CriticalPath = true
+		if f.Severity < SeverityCritical {
+			f.Severity++
+		}
+	}
+}
+
+// criticalPathInstructions tells the model which changed files are critical
+func criticalPathInstructions(files []string) string {
+	return "The repository marks these changed files as critical paths, where mistakes are\n" +
+		"costly (security, money, data). Review them with extra scrutiny, and call out\n" +
+		"any change to their behavior even if it looks intentional:\n\n- " +
+		strings.Join(files, "\n- ") + "\n"
+}
+
+// criticalPathSummary calls out critical-path changes at the top of the
+// report, with the number of findings in each file
+func criticalPathSummary(files []string, findings []Finding) string {
+	if len(files) == 0 {
+		return ""
+	}
+	counts := make(map[string]int)
+	for _, f := range findings {
+		if f.CriticalPath {
+			counts[f.File]++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## ⚠️ %s\n\n%s\n\n", tr("Critical-Path Changes"),
+		tr("This change touches %s marked critical; findings there were escalated one severity level.", trPlural(len(files), "file")))
+	for _, f := range files {
+		fmt.Fprintf(&b, "- `%s`", f)
+		if n := counts[f]; n > 0 {
+			fmt.Fprintf(&b, " (%s)", trPlural(n, "finding"))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestEscalateCritical tests that findings in critical paths are raised one
+// severity level, capped at critical, and marked
+func TestEscalateCritical(t *testing.T) {
+	patterns := []string{"auth/", "db/migrations/**"}
+	findings := []Finding{
+		{File: "auth/session.go", Severity: SeverityMedium, Title: "Token not rotated"},
+		{File: "internal/auth/login.go", Severity: SeverityCritical, Title: "Password logged"},
+		{File: "db/migrations/0042_users.sql", Severity: SeverityInfo, Title: "Missing down migration"},
+		{File: "cmd/main.go", Severity: SeverityLow, Title: "Unused flag"},
+		{Severity: SeverityHigh, Title: "General"},
+	}
+
+	escalateCritical(findings, patterns)
+
+	want := []struct {
+		sev      Severity
+		critical bool
+	}{
+		{SeverityHigh, true},
+		{SeverityCritical, true},
+		{SeverityLow, true},
+		{SeverityLow, false},
+		{SeverityHigh, false},
+	}
+	for i, w := range want {
+		if findings[i].Severity != w.sev || findings[i].CriticalPath != w.critical {
+			t.Errorf("finding %d = %v (critical %v), want %v (critical %v)",
+				i, findings[i].Severity, findings[i].CriticalPath, w.sev, w.critical)
+		}
+	}
+}
+
+// TestCriticalPathSummary tests the report section calling out critical-path
+// changes
+func TestCriticalPathSummary(t *testing.T) {
+	if criticalPathSummary(nil, nil) != "" {
+		t.Error("criticalPathSummary(nil) should be empty")
+	}
+
+	files := criticalFiles([]string{"README.md", "payments/charge.go", "payments/refund.go"}, []string{"payments/"})
+	if len(files) != 2 {
+		t.Fatalf("criticalFiles() = %q, want the two payments files", files)
+	}
+	findings := []Finding{
+		{File: "payments/charge.go", CriticalPath: true},
+		{File: "payments/charge.go", CriticalPath: true},
+		{File: "README.md"},
+	}
+	got := criticalPathSummary(files, findings)
+	for _, want := range []string{"Critical-Path Changes", "2 files", "`payments/charge.go` (2 findings)", "`payments/refund.go`\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("criticalPathSummary() missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+// encodingNotePrefix marks lines the tool adds to a diff to explain how its
+// content was changed; they sit outside hunks, so diff parsing ignores them
+const encodingNotePrefix = "# pr-review: "
+
+// cp1252 maps the bytes 0x80-0x9f of Windows-1252, the usual superset of
+// Latin-1 in legacy source files, to Unicode. The five undefined bytes keep
+// their Latin-1 (C1 control) meaning.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// sanitizeDiff makes every file section of a diff valid UTF-8 before it is
+// sent to the model: UTF-16 content is transcoded, other invalid lines are
+// read as Windows-1252, and hunks that look binary are dropped. Each change
+// is noted in the diff itself and returned as a note for the user.
+func sanitizeDiff(diff string) (string, []string) {
+	if utf8.ValidString(diff) && !strings.ContainsRune(diff, 0) {
+		return diff, nil
+	}
+
+	var notes []string
+	files := gitdiff.Split(diff)
+	for i, f := range files {
+		if utf8.ValidString(f.Text) && !strings.ContainsRune(f.Text, 0) {
+			continue
+		}
+		text, note := sanitizeFile(f.Text)
+		files[i].Text = text
+		if note != "" {
+			notes = append(notes, fmt.Sprintf("%s: %s", f.Path, note))
+		}
+	}
+	return gitdiff.Join(files), notes
+}
+
+// sanitizeFile transcodes one file section and describes what was done
+func sanitizeFile(text string) (string, string) {
+	lines := strings.Split(text, "\n")
+	header, hunks := splitHunks(lines)
+
+	utf16Order := ""
+	var transcoded, skipped int
+	var out []string
+	for _, hunk := range hunks {
+		body := hunk[1:]
+		if ok, order := looksUTF16(body); ok {
+			decoded, ok := decodeUTF16Hunk(body, order == "UTF-16BE")
+			if !ok && order == "UTF-16" {
+				decoded, ok = decodeUTF16Hunk(body, true)
+			}
+			if ok {
+				utf16Order = order
+				out = append(out, hunk[0])
+				out = append(out, decoded...)
+				continue
+			}
+		}
+		if looksBinary(body) {
+			skipped++
+			continue
+		}
+		out = append(out, hunk[0])
+		for _, line := range body {
+			if !utf8.ValidString(line) {
+				line = decodeCP1252(line)
+				transcoded++
+			}
+			out = append(out, line)
+		}
+	}
+
+	var notes []string
+	if utf16Order != "" {
+		notes = append(notes, "transcoded from "+utf16Order)
+	}
+	if transcoded > 0 {
+		notes = append(notes, fmt.Sprintf("%s transcoded from Windows-1252/Latin-1", plural(transcoded, "line")))
+	}
+	if skipped > 0 {
+		notes = append(notes, fmt.Sprintf("%s of binary or undecodable content omitted", plural(skipped, "hunk")))
+	}
+	note := strings.Join(notes, "; ")
+
+	for i, line := range header {
+		// Header lines hold paths, which may be in a legacy encoding too
+		if !utf8.ValidString(line) {
+			header[i] = decodeCP1252(line)
+		}
+	}
+	if note != "" {
+		header = append(header, encodingNotePrefix+note)
+	}
+	return strings.Join(append(header, out...), "\n"), note
+}
+
+// splitHunks separates a file section's header lines from its hunks; each
+// hunk starts with its @@ line
+func splitHunks(lines []string) (header []string, hunks [][]string) {
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			hunks = append(hunks, []string{line})
+		case len(hunks) == 0:
+			header = append(header, line)
+		default:
+			hunks[len(hunks)-1] = append(hunks[len(hunks)-1], line)
+		}
+	}
+	return header, hunks
+}
+
+// looksUTF16 reports whether a hunk's content looks like UTF-16 text: it
+// has a byte order mark, or NULs make up a large share of its bytes, as they
+// do for ASCII-range text in UTF-16. It returns the byte order if a BOM
+// gives it away.
+func looksUTF16(lines []string) (bool, string) {
+	var nuls, total int
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		content := line[1:]
+		if strings.HasPrefix(content, "\xff\xfe") {
+			return true, "UTF-16LE"
+		}
+		if strings.HasPrefix(content, "\xfe\xff") {
+			return true, "UTF-16BE"
+		}
+		total += len(content)
+		nuls += strings.Count(content, "\x00")
+	}
+	return total > 0 && nuls*4 >= total, "UTF-16"
+}
+
+// decodeUTF16Hunk decodes the content of each diff line from UTF-16, failing
+// if the result isn't printable text. Git splits lines at the 0x0a byte,
+// leaving the newline's NUL at the start of the next line (little-endian)
+// or the end of this one (big-endian).
+func decodeUTF16Hunk(lines []string, bigEndian bool) ([]string, bool) {
+	decoded := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line == "" || line[0] == '\\' {
+			decoded = append(decoded, line)
+			continue
+		}
+		content := []byte(line[1:])
+		content = bytes.TrimPrefix(content, []byte("\xff\xfe"))
+		content = bytes.TrimPrefix(content, []byte("\xfe\xff"))
+		if len(content)%2 == 1 {
+			if bigEndian && content[len(content)-1] == 0 {
+				content = content[:len(content)-1]
+			} else if !bigEndian && content[0] == 0 {
+				content = content[1:]
+			} else {
+				return nil, false
+			}
+		}
+
+		units := make([]uint16, len(content)/2)
+		for i := range units {
+			lo, hi := content[2*i], content[2*i+1]
+			if bigEndian {
+				lo, hi = hi, lo
+			}
+			units[i] = uint16(hi)<<8 | uint16(lo)
+		}
+		text := strings.TrimSuffix(string(utf16.Decode(units)), "\r")
+		for _, r := range text {
+			if r == utf8.RuneError || (unicode.IsControl(r) && r != '\t') {
+				return nil, false
+			}
+		}
+		decoded = append(decoded, line[:1]+text)
+	}
+	return decoded, true
+}
+
+// looksBinary reports whether a hunk contains NUL bytes or is mostly
+// control characters, so showing it to the model would be useless
+func looksBinary(lines []string) bool {
+	var control, total int
+	for _, line := range lines {
+		for _, b := range []byte(line) {
+			total++
+			if b == 0 {
+				return true
+			}
+			if b < 0x20 && b != '\t' && b != '\r' && b != '\f' && b != 0x1b {
+				control++
+			}
+		}
+	}
+	return total > 0 && control*10 > total
+}
+
+// decodeCP1252 reads s as Windows-1252, keeping any valid UTF-8 sequences
+// in it so mixed-encoding lines are not double-decoded
+func decodeCP1252(s string) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if r != utf8.RuneError || size > 1 {
+			b.WriteString(s[:size])
+			s = s[size:]
+			continue
+		}
+		if c := s[0]; c >= 0x80 && c < 0xa0 {
+			b.WriteRune(cp1252[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+		s = s[1:]
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+// utf16Diff builds the diff git produces for a UTF-16 file whose content is
+// added in full: lines are split at the 0x0a byte of each newline
+func utf16Diff(text string, bigEndian bool) string {
+	var raw []byte
+	if bigEndian {
+		raw = []byte{0xfe, 0xff}
+	} else {
+		raw = []byte{0xff, 0xfe}
+	}
+	for _, u := range utf16.Encode([]rune(text)) {
+		if bigEndian {
+			raw = append(raw, byte(u>>8), byte(u))
+		} else {
+			raw = append(raw, byte(u), byte(u>>8))
+		}
+	}
+	var b strings.Builder
+	b.WriteString("diff --git a/res.rc b/res.rc\n--- /dev/null\n+++ b/res.rc\n@@ -0,0 +1,2 @@\n")
+	for _, line := range strings.SplitAfter(string(raw), "\n") {
+		if line == "" {
+			continue
+		}
+		b.WriteString("+" + line)
+		if !strings.HasSuffix(line, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	return b.String()
+}
+
+// TestSanitizeDiff_UTF16 tests transcoding UTF-16 files in both byte orders
+func TestSanitizeDiff_UTF16(t *testing.T) {
+	for _, bigEndian := range []bool{false, true} {
+		diff := utf16Diff("Title=Café\r\nCaption=日本\r\n", bigEndian)
+		got, notes := sanitizeDiff(diff)
+		if !utf8.ValidString(got) || strings.ContainsRune(got, 0) {
+			t.Fatalf("bigEndian=%v: result is not clean UTF-8: %q", bigEndian, got)
+		}
+		if !strings.Contains(got, "+Title=Café\n+Caption=日本\n") {
+			t.Errorf("bigEndian=%v: decoded diff = %q", bigEndian, got)
+		}
+		want := "UTF-16LE"
+		if bigEndian {
+			want = "UTF-16BE"
+		}
+		if len(notes) != 1 || notes[0] != "res.rc: transcoded from "+want {
+			t.Errorf("bigEndian=%v: notes = %v", bigEndian, notes)
+		}
+		if !strings.Contains(got, encodingNotePrefix+"transcoded from "+want) {
+			t.Errorf("bigEndian=%v: diff has no note for the model", bigEndian)
+		}
+		if files := gitdiff.Files(got); len(files) != 1 || files[0] != "res.rc" {
+			t.Errorf("bigEndian=%v: diffFiles() = %v", bigEndian, files)
+		}
+	}
+}
+
+// TestSanitizeDiff_Latin1 tests transcoding legacy-encoded lines while
+// leaving UTF-8 lines and other files alone
+func TestSanitizeDiff_Latin1(t *testing.T) {
+	diff := "diff --git a/ok.go b/ok.go\n--- a/ok.go\n+++ b/ok.go\n@@ -1 +1 @@\n-x := \"é\"\n+x := \"è\"\n" +
+		"diff --git a/legacy.c b/legacy.c\n--- a/legacy.c\n+++ b/legacy.c\n@@ -1,2 +1,2 @@\n /* d\xe9j\xe0 vu \x80 */\n-int a;\n+int \xe9t\xe9; /* ok: \"é\" */\n"
+	got, notes := sanitizeDiff(diff)
+	if !utf8.ValidString(got) {
+		t.Fatalf("result is not valid UTF-8: %q", got)
+	}
+	if !strings.Contains(got, " /* déjà vu € */\n") || !strings.Contains(got, "+int été; /* ok: \"é\" */") {
+		t.Errorf("legacy lines not transcoded: %q", got)
+	}
+	if !strings.HasPrefix(got, diff[:strings.Index(diff, "diff --git a/legacy.c")]) {
+		t.Error("valid UTF-8 file was changed")
+	}
+	if len(notes) != 1 || notes[0] != "legacy.c: 2 lines transcoded from Windows-1252/Latin-1" {
+		t.Errorf("notes = %v", notes)
+	}
+	added := gitdiff.Added(gitdiff.Split(got)[1])
+	if len(added) != 1 || added[0].Line != 2 {
+		t.Errorf("addedLines() after the note = %+v", added)
+	}
+}
+
+// TestSanitizeDiff_Binary tests that binary-looking hunks are omitted
+func TestSanitizeDiff_Binary(t *testing.T) {
+	diff := "diff --git a/blob.dat b/blob.dat\n--- a/blob.dat\n+++ b/blob.dat\n@@ -1 +1 @@\n-\x01\x02\x03\x04\xff\x05\n+\x01\x02\x07\x04\xfe\x06\n" +
+		"@@ -10 +10 @@\n-name=old\n+name=new\n"
+	got, notes := sanitizeDiff(diff)
+	if strings.Contains(got, "\x01") || !strings.Contains(got, "+name=new") {
+		t.Errorf("sanitizeDiff() = %q, want only the text hunk", got)
+	}
+	if len(notes) != 1 || notes[0] != "blob.dat: 1 hunk of binary or undecodable content omitted" {
+		t.Errorf("notes = %v", notes)
+	}
+}
+
+// TestSanitizeDiff_Clean tests that valid diffs are returned unchanged
+func TestSanitizeDiff_Clean(t *testing.T) {
+	diff := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+ä\n"
+	if got, notes := sanitizeDiff(diff); got != diff || notes != nil {
+		t.Errorf("sanitizeDiff() = %q, %v", got, notes)
+	}
+}
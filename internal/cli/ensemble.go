@@ -0,0 +1,335 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/llm"
+	"github.com/marete/pr-review/pkg/review"
+)
+
+const (
+	// ensembleLineSlack is how far apart two models may place the same
+	// finding
+	ensembleLineSlack = 3
+
+	// ensembleTitleOverlap is the share of title words two findings in the
+	// same place must have in common to count as the same issue, unless
+	// their categories match
+	ensembleTitleOverlap = 0.5
+
+	// disagreementMaxTokens bounds the response of the disagreement pass
+	disagreementMaxTokens = 4000
+)
+
+// Confidence of a finding raised by only some of the models of an ensemble
+const confidenceLow = "low"
+
+const (
+	notesStartTag = "<notes>"
+	notesEndTag   = "</notes>"
+)
+
+// modelFindings are the findings one model raised on a change
+type modelFindings struct {
+	Model    string
+	Findings []Finding
+}
+
+// sameIssue reports whether two findings from different models describe
+// the same issue: in the same file, within a few lines, and in the same
+// category or with mostly the same title
+func sameIssue(a, b Finding) bool {
+	if a.File != b.File {
+		return false
+	}
+	if (a.Line > 0) != (b.Line > 0) {
+		return false
+	}
+	if d := a.Line - b.Line; d > ensembleLineSlack || d < -ensembleLineSlack {
+		return false
+	}
+	if a.Category != "" && strings.EqualFold(a.Category, b.Category) {
+		return true
+	}
+	return titleOverlap(a.Title, b.Title) >= ensembleTitleOverlap
+}
+
+// titleOverlap is the Jaccard similarity of the words of two titles
+func titleOverlap(a, b string) float64 {
+	words := func(s string) map[string]bool {
+		set := make(map[string]bool)
+		for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+		}) {
+			set[w] = true
+		}
+		return set
+	}
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
+
+// synthesizeFindings merges the findings of several models. Findings that
+// describe the same issue are merged into the most severe of them, and each
+// merged finding lists the models that raised it. Findings not every model
+// raised are disagreements: they are kept, but marked low confidence rather
+// than silently dropped or merged. The findings most agreed on come first.
+func synthesizeFindings(sets []modelFindings) []Finding {
+	type cluster struct {
+		finding Finding
+		models  []string
+	}
+	var clusters []*cluster
+	for _, set := range sets {
+		for _, f := range set.Findings {
+			var match *cluster
+			for _, c := range clusters {
+				if sameIssue(c.finding, f) && !containsString(c.models, set.Model) {
+					match = c
+					break
+				}
+			}
+			if match == nil {
+				clusters = append(clusters, &cluster{finding: f, models: []string{set.Model}})
+				continue
+			}
+			match.models = append(match.models, set.Model)
+			if f.Severity > match.finding.Severity {
+				f.RaisedBy = nil
+				match.finding = f
+			}
+		}
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].models) > len(clusters[j].models)
+	})
+	merged := make([]Finding, len(clusters))
+	for i, c := range clusters {
+		merged[i] = c.finding
+		merged[i].RaisedBy = c.models
+		if len(sets) > 1 && len(c.models) < len(sets) {
+			merged[i].Confidence = confidenceLow
+		}
+	}
+	return merged
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// buildDisagreementPrompt asks for a short note on why the models might
+// differ on each finding only some of them raised
+func buildDisagreementPrompt(models []string, disagreements []*Finding, diff string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `Several models (%s) reviewed the same change. Each finding below was raised by some of them but not the others.
+
+For each finding, write one or two sentences on why the models might differ: for example, whether it depends on context outside the diff, is a judgment call or style preference, rests on an assumption about how the code is used, or looks like a false positive or a real issue the other models missed. Don't restate the finding.
+
+Return the notes as a JSON array enclosed in %s and %s tags, with one element per finding: {"finding": <number>, "note": "..."}.
+
+## Findings
+
+`, strings.Join(models, ", "), notesStartTag, notesEndTag)
+	for i, f := range disagreements {
+		fmt.Fprintf(&b, "%d. [%s] %s", i+1, strings.Join(f.RaisedBy, ", "), f.Title)
+		if loc := f.Location(); loc != "" {
+			fmt.Fprintf(&b, " (%s)", loc)
+		}
+		b.WriteString("\n")
+		if f.Message != "" {
+			fmt.Fprintf(&b, "   %s\n", f.Message)
+		}
+	}
+	b.WriteString("\n## Diff\n```diff\n" + diff + "\n```\n")
+	return b.String()
+}
+
+// applyDisagreementNotes sets each disagreement's note from the notes
+// section of a response, numbered as in buildDisagreementPrompt
+func applyDisagreementNotes(response string, disagreements []*Finding) error {
+	start := strings.LastIndex(response, notesStartTag)
+	if start == -1 {
+		return fmt.Errorf("response has no %s section", notesStartTag)
+	}
+	end := strings.Index(response[start:], notesEndTag)
+	if end == -1 {
+		return fmt.Errorf("notes section is not terminated by %s", notesEndTag)
+	}
+	body := strings.TrimSpace(response[start+len(notesStartTag) : start+end])
+	body = strings.TrimPrefix(body, "```json")
+	body = strings.TrimPrefix(body, "```")
+	body = strings.TrimSuffix(body, "```")
+
+	var notes []struct {
+		Finding int    `json:"finding"`
+		Note    string `json:"note"`
+	}
+	if err := json.Unmarshal([]byte(body), &notes); err != nil {
+		return fmt.Errorf("error parsing notes: %w", err)
+	}
+	for _, n := range notes {
+		if n.Finding >= 1 && n.Finding <= len(disagreements) {
+			disagreements[n.Finding-1].Disagreement = strings.TrimSpace(n.Note)
+		}
+	}
+	return nil
+}
+
+// explainDisagreements asks client for a note on each low-confidence
+// finding of an ensemble on why the models might differ. The notes are
+// advisory, so a failure leaves the findings without them.
+func explainDisagreements(client Provider, opts CompletionOptions, models []string, findings []Finding, diff string) (Usage, error) {
+	var disagreements []*Finding
+	for i := range findings {
+		if findings[i].Confidence == confidenceLow {
+			disagreements = append(disagreements, &findings[i])
+		}
+	}
+	if len(disagreements) == 0 {
+		return Usage{}, nil
+	}
+	opts.Thinking, opts.MaxTokens = false, disagreementMaxTokens
+	response, usage, err := client.Complete(buildDisagreementPrompt(models, disagreements, diff), opts)
+	if err != nil {
+		return usage, err
+	}
+	return usage, applyDisagreementNotes(response, disagreements)
+}
+
+// parseModelList parses -compare's comma-separated list, which must name at
+// least two different models
+func parseModelList(list string) ([]string, error) {
+	var models []string
+	for _, m := range strings.Split(list, ",") {
+		if m = strings.TrimSpace(m); m != "" && !containsString(models, m) {
+			models = append(models, m)
+		}
+	}
+	if len(models) < 2 {
+		return nil, fmt.Errorf("want at least two different models, got %q", list)
+	}
+	return models, nil
+}
+
+// renderModelComparison formats a comparison of models: a table of what each
+// model found and the tokens it used, the findings merged across models,
+// and then each model's own review
+func renderModelComparison(reviews []*review.Result, merged []Finding, groupBy string) string {
+	var b strings.Builder
+	b.WriteString("# " + tr("Model Comparison") + "\n\n")
+	fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n|---|---:|---:|---:|---:|\n",
+		tr("Model"), tr("Findings"), tr("Only this model"), tr("Input tokens"), tr("Output tokens"))
+	succeeded := 0
+	for _, r := range reviews {
+		if r.Err != nil {
+			fmt.Fprintf(&b, "| %s | %s | | | |\n", r.Model, tr("failed: %s", strings.ReplaceAll(r.Err.Error(), "|", "\\|")))
+			continue
+		}
+		succeeded++
+		only := 0
+		for _, f := range merged {
+			if len(f.RaisedBy) == 1 && f.RaisedBy[0] == r.Model {
+				only++
+			}
+		}
+		fmt.Fprintf(&b, "| %s | %d | %d | %d | %d |\n", r.Model, len(r.Findings), only, r.Usage.InputTokens, r.Usage.OutputTokens)
+	}
+
+	agreed := 0
+	for _, f := range merged {
+		if len(f.RaisedBy) == succeeded {
+			agreed++
+		}
+	}
+	b.WriteString("\n" + tr("%d of %d findings were raised by every model.", agreed, len(merged)) + "\n")
+
+	if rendered := renderFindings(merged, groupBy); rendered != "" {
+		b.WriteString("\n" + rendered)
+	}
+	for _, r := range reviews {
+		if r.Err == nil {
+			b.WriteString("\n## " + tr("Review by %s", r.Model) + "\n\n" + strings.TrimSpace(r.Review) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// compareModels reviews a change with several models at once and merges
+// their findings, once each model's have been checked against the diff and
+// the repository's calibration and rules applied. The first model to
+// succeed explains where they disagree. It fails if every model does, or
+// with failFast if any does.
+func compareModels(client Provider, opts CompletionOptions, policy *Policy, models []string, prompt, diff string, cfg *Config, failFast bool) ([]*review.Result, []Finding, Usage, error) {
+	reviews := review.RunModels(client, prompt, opts, models)
+	var total Usage
+	var sets []modelFindings
+	for _, r := range reviews {
+		total.Add(r.Usage)
+		if r.Err != nil && failFast {
+			return reviews, nil, total, fmt.Errorf("review with %s failed: %w", r.Model, r.Err)
+		}
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Review with %s failed: %v\n", r.Model, r.Err)
+			continue
+		}
+		if r.FindingsErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not parse structured findings from %s: %v\n", r.Model, r.FindingsErr)
+		}
+		checkEvidence(r.Findings, diff)
+		applyCalibration(r.Findings, cfg.SeverityCalibration)
+		escalateCritical(r.Findings, cfg.CriticalPaths)
+		applySeverityRules(r.Findings, cfg.SeverityRules)
+		sets = append(sets, modelFindings{Model: r.Model, Findings: r.Findings})
+	}
+	if len(sets) == 0 {
+		return reviews, nil, total, fmt.Errorf("every model failed, %s with: %w", reviews[0].Model, reviews[0].Err)
+	}
+
+	merged := synthesizeFindings(sets)
+	succeeded := make([]string, len(sets))
+	for i, set := range sets {
+		succeeded[i] = set.Model
+	}
+	explainOpts := opts
+	explainOpts.Model = succeeded[0]
+	usage, err := explainDisagreements(llm.WithoutDocuments(client), explainOpts, succeeded, merged, policy.redact(diff))
+	total.InputTokens += usage.InputTokens
+	total.OutputTokens += usage.OutputTokens
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not explain where the models disagree: %v\n", err)
+	}
+	return reviews, merged, total, nil
+}
+
+// modelUnits returns the outcome of each model's review
+func modelUnits(reviews []*review.Result) []unitResult {
+	units := make([]unitResult, len(reviews))
+	for i, r := range reviews {
+		units[i] = unitResult{Name: r.Model, Status: unitSucceeded}
+		if r.Err != nil {
+			units[i].Status, units[i].Error = unitFailed, r.Err.Error()
+		}
+	}
+	return units
+}
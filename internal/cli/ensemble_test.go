@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSynthesizeFindings tests that findings every model raised are merged,
+// and findings only some raised are kept at low confidence
+func TestSynthesizeFindings(t *testing.T) {
+	sets := []modelFindings{
+		{Model: "claude", Findings: []Finding{
+			{File: "db.go", Line: 40, Severity: SeverityMedium, Category: "security", Title: "SQL built from user input"},
+			{File: "api.go", Line: 10, Severity: SeverityLow, Category: "style", Title: "Long handler"},
+		}},
+		{Model: "gpt", Findings: []Finding{
+			{File: "db.go", Line: 42, Severity: SeverityHigh, Category: "injection", Title: "SQL query built from user input"},
+			{File: "cache.go", Line: 5, Severity: SeverityHigh, Category: "concurrency", Title: "Map written without a lock"},
+		}},
+	}
+	merged := synthesizeFindings(sets)
+	if len(merged) != 3 {
+		t.Fatalf("synthesizeFindings() returned %d findings, want 3: %+v", len(merged), merged)
+	}
+
+	// The shared finding comes first, at the most severe model's severity
+	if f := merged[0]; f.File != "db.go" || f.Severity != SeverityHigh || f.Confidence != "" || !reflect.DeepEqual(f.RaisedBy, []string{"claude", "gpt"}) {
+		t.Errorf("merged[0] = %+v, want the SQL finding from both models", f)
+	}
+	for _, f := range merged[1:] {
+		if f.Confidence != confidenceLow || len(f.RaisedBy) != 1 {
+			t.Errorf("%q: confidence %q raised by %v, want low from one model", f.Title, f.Confidence, f.RaisedBy)
+		}
+	}
+
+	// A single model has nothing to disagree with
+	for _, f := range synthesizeFindings(sets[:1]) {
+		if f.Confidence != "" {
+			t.Errorf("single-model finding %q has confidence %q", f.Title, f.Confidence)
+		}
+	}
+}
+
+// TestExplainDisagreements tests that notes on why models differ are added
+// to the low-confidence findings
+func TestExplainDisagreements(t *testing.T) {
+	findings := []Finding{
+		{File: "db.go", Line: 40, Title: "SQL injection", RaisedBy: []string{"a", "b"}},
+		{File: "cache.go", Line: 5, Title: "Map race", RaisedBy: []string{"b"}, Confidence: confidenceLow},
+	}
+	client := &fakeProvider{response: "Notes:\n<notes>\n```json\n[{\"finding\": 1, \"note\": \"Depends on whether the cache is shared.\"}]\n```\n</notes>"}
+	if _, err := explainDisagreements(client, CompletionOptions{Model: "a"}, []string{"a", "b"}, findings, "diff"); err != nil {
+		t.Fatalf("explainDisagreements() returned error: %v", err)
+	}
+	if findings[1].Disagreement != "Depends on whether the cache is shared." || findings[0].Disagreement != "" {
+		t.Errorf("notes = %q, %q", findings[0].Disagreement, findings[1].Disagreement)
+	}
+	if !strings.Contains(client.prompt, "1. [b] Map race (cache.go:5)") || strings.Contains(client.prompt, "SQL injection") {
+		t.Errorf("prompt doesn't list just the disagreement:\n%s", client.prompt)
+	}
+
+	rendered := renderFindings(findings, groupBySeverity)
+	if !strings.Contains(rendered, "Low confidence: only raised by b") || !strings.Contains(rendered, "Why the models may differ: Depends") {
+		t.Errorf("renderFindings() doesn't show the disagreement:\n%s", rendered)
+	}
+}
+
+// TestCompareModels tests reviewing with several models at once, including
+// one that fails
+func TestCompareModels(t *testing.T) {
+	client := &modelsProvider{responses: map[string]string{
+		"a":       "Looks fine.\n<findings>[{\"file\": \"db.go\", \"line\": 3, \"severity\": \"high\", \"category\": \"security\", \"title\": \"SQL injection\"}]</findings>",
+		"b":       "Two issues.\n<findings>[{\"file\": \"db.go\", \"line\": 4, \"severity\": \"medium\", \"category\": \"security\", \"title\": \"Unescaped query\"}, {\"file\": \"db.go\", \"line\": 20, \"severity\": \"low\", \"title\": \"Unused variable\"}]</findings>",
+		"explain": "<notes>[{\"finding\": 1, \"note\": \"Style call.\"}]</notes>",
+	}}
+	models := []string{"a", "b", "broken"}
+	reviews, merged, usage, err := compareModels(client, CompletionOptions{}, nil, models, "prompt", "diff", &Config{}, false)
+	if err != nil {
+		t.Fatalf("compareModels() returned error: %v", err)
+	}
+	if len(merged) != 2 || merged[0].Severity != SeverityHigh || merged[1].Confidence != confidenceLow || merged[1].Disagreement != "Style call." {
+		t.Errorf("merged findings = %+v", merged)
+	}
+	if reviews[2].Err == nil {
+		t.Error("the broken model's review has no error")
+	}
+	// Both reviews and the disagreement pass are counted
+	if usage.InputTokens != 300 {
+		t.Errorf("usage = %+v, want three requests", usage)
+	}
+
+	report := renderModelComparison(reviews, merged, groupBySeverity)
+	for _, want := range []string{"| a | 1 | 0 | 100 | 20 |", "| b | 2 | 1 | 100 | 20 |", "| broken | failed: no such model", "1 of 2 findings were raised by every model.", "## Review by b\n\nTwo issues."} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report doesn't contain %q:\n%s", want, report)
+		}
+	}
+
+	if _, _, _, err := compareModels(client, CompletionOptions{}, nil, []string{"broken", "gone"}, "prompt", "diff", &Config{}, false); err == nil {
+		t.Error("compareModels() with every model failing returned no error")
+	}
+	if _, _, _, err := compareModels(client, CompletionOptions{}, nil, models, "prompt", "diff", &Config{}, true); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("compareModels() with -fail-fast error = %v, want the broken model's", err)
+	}
+	units := modelUnits(reviews)
+	if succeeded, failed, _ := countUnits(units); succeeded != 2 || failed != 1 {
+		t.Errorf("units = %+v", units)
+	}
+	if _, err := parseModelList("a, a,"); err == nil {
+		t.Error("parseModelList() with one model returned no error")
+	}
+}
+
+// fakeProvider returns a canned response and records the prompt it was sent
+type fakeProvider struct {
+	response string
+	prompt   string
+}
+
+func (p *fakeProvider) Name() string { return "Fake" }
+
+func (p *fakeProvider) Complete(prompt string, opts CompletionOptions) (string, Usage, error) {
+	p.prompt = prompt
+	return p.response, Usage{InputTokens: 100, OutputTokens: 20}, nil
+}
+
+// modelsProvider answers each model with its own response, and the
+// disagreement pass with the "explain" response
+type modelsProvider struct {
+	responses map[string]string
+}
+
+func (p *modelsProvider) Name() string { return "Fake" }
+
+func (p *modelsProvider) Complete(prompt string, opts CompletionOptions) (string, Usage, error) {
+	if strings.Contains(prompt, notesStartTag) {
+		return p.responses["explain"], Usage{InputTokens: 100, OutputTokens: 20}, nil
+	}
+	response, ok := p.responses[opts.Model]
+	if !ok {
+		return "", Usage{}, errors.New("no such model")
+	}
+	return response, Usage{InputTokens: 100, OutputTokens: 20}, nil
+}
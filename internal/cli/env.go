@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix starts the environment variables that set flags
+const envPrefix = "PR_REVIEW_"
+
+// sharedFlags are the flags of every command that takes the common flags.
+// Their variables apply to all commands, e.g. PR_REVIEW_MODEL; a
+// subcommand's own flags have the subcommand in theirs.
+var sharedFlags = func() map[string]bool {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	addCommonFlags(fs)
+	names := make(map[string]bool)
+	fs.VisitAll(func(f *flag.Flag) { names[f.Name] = true })
+	return names
+}()
+
+// flagEnvName returns the environment variable that sets a flag: the
+// review's -format is PR_REVIEW_FORMAT, and `history export -format` is
+// PR_REVIEW_HISTORY_EXPORT_FORMAT
+func flagEnvName(command, name string) string {
+	if command != "" && !sharedFlags[name] {
+		name = command + "_" + name
+	}
+	return envPrefix + strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(name))
+}
+
+// parseFlags parses args into fs, then sets the flags not given from their
+// environment variables, so CI jobs can configure a run without building a
+// command line. command is the subcommand, or "" for the review.
+// Precedence is flags, then the environment, then the repository's config
+// and the profile.
+func parseFlags(fs *flag.FlagSet, command string, args []string) {
+	fs.Parse(args)
+	envFlags(fs, command)
+}
+
+// envFlags sets the flags of fs not given from their environment
+// variables, exiting if a value is invalid
+func envFlags(fs *flag.FlagSet, command string) {
+	if _, err := applyEnv(fs, command); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+}
+
+// applyEnv sets each flag of fs not given on the command line whose
+// environment variable is set, returning the names of the flags it set
+func applyEnv(fs *flag.FlagSet, command string) ([]string, error) {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var set []string
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if given[f.Name] || err != nil {
+			return
+		}
+		env := flagEnvName(command, f.Name)
+		value, ok := os.LookupEnv(env)
+		if !ok || value == "" {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid $%s: %w", env, setErr)
+			return
+		}
+		set = append(set, f.Name)
+	})
+	return set, err
+}
+
+// ciTargetVars are the variables CI systems set to the branch a pull or
+// merge request targets, only in pipelines for one
+var ciTargetVars = []string{
+	"GITHUB_BASE_REF",                     // GitHub Actions
+	"CI_MERGE_REQUEST_TARGET_BRANCH_NAME", // GitLab CI
+	"BITBUCKET_PR_DESTINATION_BRANCH",     // Bitbucket Pipelines
+	"SYSTEM_PULLREQUEST_TARGETBRANCH",     // Azure Pipelines
+	"BUILDKITE_PULL_REQUEST_BASE_BRANCH",  // Buildkite
+	"CHANGE_TARGET",                       // Jenkins multibranch pipelines
+}
+
+// ciTargetBranch returns the branch the pull request a CI job runs for
+// targets, and the variable it came from, or "" outside such a job. CI
+// checkouts often have the target only as a remote-tracking branch, which
+// is used when there is no local branch of that name.
+func ciTargetBranch() (branch, source string) {
+	for _, name := range ciTargetVars {
+		branch = strings.TrimPrefix(os.Getenv(name), "refs/heads/")
+		if branch == "" {
+			continue
+		}
+		if gitCommand("rev-parse", "--verify", "-q", "refs/heads/"+branch).Run() != nil &&
+			gitCommand("rev-parse", "--verify", "-q", "refs/remotes/origin/"+branch).Run() == nil {
+			branch = "origin/" + branch
+		}
+		return branch, name
+	}
+	return "", ""
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestFlagEnvName tests the variables that set flags
+func TestFlagEnvName(t *testing.T) {
+	tests := []struct {
+		command, flag, want string
+	}{
+		{"", "format", "PR_REVIEW_FORMAT"},
+		{"", "max-input-tokens", "PR_REVIEW_MAX_INPUT_TOKENS"},
+		{"postmortem", "model", "PR_REVIEW_MODEL"},
+		{"postmortem", "data-dir", "PR_REVIEW_DATA_DIR"},
+		{"history export", "format", "PR_REVIEW_HISTORY_EXPORT_FORMAT"},
+	}
+	for _, tt := range tests {
+		if got := flagEnvName(tt.command, tt.flag); got != tt.want {
+			t.Errorf("flagEnvName(%q, %q) = %q, want %q", tt.command, tt.flag, got, tt.want)
+		}
+	}
+}
+
+// TestApplyEnv tests setting flags from the environment, with the command
+// line taking precedence
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("PR_REVIEW_MODEL", "claude-opus-4-1")
+	t.Setenv("PR_REVIEW_BASE", "origin/release")
+	t.Setenv("PR_REVIEW_FORCE", "true")
+	t.Setenv("PR_REVIEW_MAX_COST", "0.5")
+
+	fs := flag.NewFlagSet("review", flag.ContinueOnError)
+	c := addCommonFlags(fs)
+	force := fs.Bool("force", false, "")
+	maxCost := fs.Float64("max-cost", 0, "")
+	if err := fs.Parse([]string{"-base", "main"}); err != nil {
+		t.Fatal(err)
+	}
+	set, err := applyEnv(fs, "")
+	if err != nil {
+		t.Fatalf("applyEnv() returned error: %v", err)
+	}
+	if *c.model != "claude-opus-4-1" || *c.base != "main" || !*force || *maxCost != 0.5 {
+		t.Errorf("model=%q base=%q force=%v max-cost=%v", *c.model, *c.base, *force, *maxCost)
+	}
+	if strings.Join(set, ",") != "force,max-cost,model" {
+		t.Errorf("applyEnv() set %v", set)
+	}
+
+	// A subcommand's own flags don't take the review's variables
+	t.Setenv("PR_REVIEW_OUTPUT", "REVIEW.md")
+	t.Setenv("PR_REVIEW_POSTMORTEM_OUTPUT", "PM.md")
+	fs = flag.NewFlagSet("postmortem", flag.ContinueOnError)
+	c = addCommonFlags(fs)
+	output := fs.String("output", "POSTMORTEM.md", "")
+	fs.Parse(nil)
+	if _, err := applyEnv(fs, fs.Name()); err != nil || *output != "PM.md" || *c.model != "claude-opus-4-1" {
+		t.Errorf("applyEnv(postmortem) = %v; output=%q model=%q", err, *output, *c.model)
+	}
+
+	t.Setenv("PR_REVIEW_MAX_COST", "lots")
+	fs = flag.NewFlagSet("review", flag.ContinueOnError)
+	fs.Float64("max-cost", 0, "")
+	fs.Parse(nil)
+	if _, err := applyEnv(fs, ""); err == nil || !strings.Contains(err.Error(), "$PR_REVIEW_MAX_COST") {
+		t.Errorf("applyEnv() with an invalid value = %v", err)
+	}
+}
+
+// TestCITargetBranch tests finding the target branch of a CI job for a pull
+// request
+func TestCITargetBranch(t *testing.T) {
+	for _, name := range ciTargetVars {
+		t.Setenv(name, "")
+	}
+	t.Chdir(t.TempDir())
+	git := func(args ...string) {
+		t.Helper()
+		cmd := gitCommand(args...)
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("commit", "-q", "--allow-empty", "-m", "base")
+	git("update-ref", "refs/remotes/origin/release", "HEAD")
+
+	if branch, source := ciTargetBranch(); branch != "" || source != "" {
+		t.Errorf("ciTargetBranch() outside CI = %q, %q", branch, source)
+	}
+	t.Setenv("GITHUB_BASE_REF", "main")
+	if branch, source := ciTargetBranch(); branch != "main" || source != "GITHUB_BASE_REF" {
+		t.Errorf("ciTargetBranch() = %q, %q", branch, source)
+	}
+	// Only the remote-tracking branch exists, as in a shallow CI checkout
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("SYSTEM_PULLREQUEST_TARGETBRANCH", "refs/heads/release")
+	if branch, _ := ciTargetBranch(); branch != "origin/release" {
+		t.Errorf("ciTargetBranch() = %q, want origin/release", branch)
+	}
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+// checkEvidence marks findings whose quoted evidence can't be found in the
+// diff, so a claim the model can't back with the code it saw stands out.
+// Evidence is looked for in the finding's file, or anywhere in the diff if
+// the file isn't in it, ignoring diff markers and differences in whitespace.
+// It returns the number of ungrounded findings.
+func checkEvidence(findings []Finding, diff string) int {
+	files := gitdiff.Split(diff)
+	whole := normalizeEvidence(diff, true)
+	ungrounded := 0
+	for i := range findings {
+		f := &findings[i]
+		quote := normalizeEvidence(f.Evidence, false)
+		if quote == "" {
+			continue
+		}
+		haystack := whole
+		for _, file := range files {
+			if file.Path == f.File {
+				haystack = normalizeEvidence(file.Text, true)
+				break
+			}
+		}
+		f.Ungrounded = !strings.Contains(haystack, quote)
+		if f.Ungrounded {
+			ungrounded++
+		}
+	}
+	return ungrounded
+}
+
+// normalizeEvidence drops diff markers, collapses whitespace within lines
+// and drops blank lines. Every diff line has a marker; quoted evidence may
+// or may not.
+func normalizeEvidence(text string, isDiff bool) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if isDiff && line != "" {
+			line = line[1:]
+		} else if !isDiff && (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")) {
+			line = line[1:]
+		}
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ungroundedNotice flags a finding whose evidence isn't in the diff
+const ungroundedNotice = "The quoted evidence was not found in the diff; verify this finding before acting on it."
+
+// renderEvidence formats a finding's evidence as a collapsible block within
+// its list item
+func renderEvidence(f Finding) string {
+	if f.Evidence == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n  <details><summary>" + tr("Evidence") + "</summary>\n\n  ```diff\n")
+	for _, line := range strings.Split(strings.Trim(f.Evidence, "\n"), "\n") {
+		b.WriteString("  " + line + "\n")
+	}
+	b.WriteString("  ```\n\n  </details>\n")
+	if f.Ungrounded {
+		b.WriteString("\n  ⚠️ " + tr(ungroundedNotice) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCheckEvidence tests matching quoted evidence against the diff
+func TestCheckEvidence(t *testing.T) {
+	diff := `diff --git a/auth/login.go b/auth/login.go
+--- a/auth/login.go
++++ b/auth/login.go
+@@ -10,3 +10,4 @@ func login(user, pass string) bool {
+ 	stored := lookup(user)
+-	return stored == hash(pass)
++	if pass == stored {
++		return true
++	}
+diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-Old
++New title`
+
+	findings := []Finding{
+		// Quoted with markers and different indentation
+		{File: "auth/login.go", Evidence: "+    if pass == stored {\n+        return true"},
+		// Quoted without markers
+		{File: "auth/login.go", Evidence: "return stored == hash(pass)"},
+		// Not in the diff
+		{File: "auth/login.go", Evidence: "if subtle.ConstantTimeCompare(a, b) == 1 {"},
+		// In another file than the one named
+		{File: "auth/login.go", Evidence: "+New title"},
+		// File not in the diff: anywhere in it counts
+		{File: "docs/guide.md", Evidence: "New title"},
+		// No evidence
+		{File: "auth/login.go"},
+	}
+
+	if n := checkEvidence(findings, diff); n != 2 {
+		t.Errorf("checkEvidence() = %d ungrounded, want 2", n)
+	}
+	want := []bool{false, false, true, true, false, false}
+	for i, w := range want {
+		if findings[i].Ungrounded != w {
+			t.Errorf("finding %d ungrounded = %v, want %v", i, findings[i].Ungrounded, w)
+		}
+	}
+}
+
+// TestRenderEvidence tests the collapsible evidence block in the report
+func TestRenderEvidence(t *testing.T) {
+	if renderEvidence(Finding{}) != "" {
+		t.Error("renderEvidence() without evidence should be empty")
+	}
+
+	got := renderEvidence(Finding{Evidence: "+x := 1\n", Ungrounded: true})
+	for _, want := range []string{"<details><summary>Evidence</summary>", "  ```diff\n  +x := 1\n  ```", "</details>", "not found in the diff"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderEvidence() missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/marete/pr-review/pkg/llm"
+)
+
+// fileCache maps the SHA-256 of uploaded content to its file, so unchanged
+// context is uploaded once rather than on every run. With shared storage
+// the map is kept there, so every machine reuses the others' uploads.
+type fileCache struct {
+	path   string
+	Files  map[string]llm.UploadedFile `json:"files"`
+	shared cacheStore
+}
+
+// loadFileCache reads the upload cache, starting an empty one if it is
+// missing or unreadable
+func loadFileCache() *fileCache {
+	cache := &fileCache{Files: make(map[string]llm.UploadedFile)}
+	if storage, err := openStorage(); err == nil {
+		if cache.shared, err = storage.OpenCache(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Shared upload cache unavailable: %v\n", err)
+		}
+		if cache.shared != nil {
+			return cache
+		}
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		return cache
+	}
+	cache.path = filepath.Join(dir, "files.json")
+	if data, err := os.ReadFile(cache.path); err == nil {
+		if err := json.Unmarshal(data, cache); err != nil || cache.Files == nil {
+			cache.Files = make(map[string]llm.UploadedFile)
+		}
+	}
+	return cache
+}
+
+// LookupUpload implements llm.UploadCache
+func (fc *fileCache) LookupUpload(key string) (llm.UploadedFile, bool) {
+	if fc.shared == nil {
+		f, ok := fc.Files[key]
+		return f, ok
+	}
+	var f llm.UploadedFile
+	data, err := fc.shared.Get("files:" + key)
+	if err != nil || data == nil || json.Unmarshal(data, &f) != nil {
+		return f, false
+	}
+	return f, true
+}
+
+// RememberUpload implements llm.UploadCache
+func (fc *fileCache) RememberUpload(key string, f llm.UploadedFile) error {
+	if fc.shared == nil {
+		fc.Files[key] = f
+		return fc.save()
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return fc.shared.Put("files:"+key, data, 0)
+}
+
+func (fc *fileCache) save() error {
+	if fc.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fc.path), 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	data, err := json.MarshalIndent(fc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fc.path, data, 0600)
+}
+
+// close releases the shared cache
+func (fc *fileCache) close() {
+	if fc.shared != nil {
+		fc.shared.Close()
+	}
+}
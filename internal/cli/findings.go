@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// The findings model is in pkg/review, for programs that embed the review
+// engine
+type (
+	Finding  = review.Finding
+	Severity = review.Severity
+)
+
+const (
+	SeverityInfo     = review.SeverityInfo
+	SeverityLow      = review.SeverityLow
+	SeverityMedium   = review.SeverityMedium
+	SeverityHigh     = review.SeverityHigh
+	SeverityCritical = review.SeverityCritical
+)
+
+// Ways of grouping findings in the rendered report
+const (
+	groupBySeverity = "severity"
+	groupByFile     = "file"
+	groupByCategory = "category"
+)
+
+var groupByModes = []string{groupBySeverity, groupByFile, groupByCategory}
+
+// validateGroupBy checks that mode is a supported -group-by value
+func validateGroupBy(mode string) error {
+	for _, m := range groupByModes {
+		if m == mode {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid group-by %q (want one of: %s)", mode, strings.Join(groupByModes, ", "))
+}
+
+// findingGroup is a titled set of findings in the rendered report
+type findingGroup struct {
+	title    string
+	findings []Finding
+}
+
+// renderFindings formats findings as a markdown section, grouped by severity
+// (most severe first), file or category
+func renderFindings(findings []Finding, groupBy string) string {
+	if len(findings) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("## " + tr("Findings") + "\n")
+	for _, g := range groupFindings(findings, groupBy) {
+		fmt.Fprintf(&b, "\n### %s (%d)\n\n", g.title, len(g.findings))
+		for _, f := range g.findings {
+			fmt.Fprintf(&b, "- **[%s]** %s", strings.ToUpper(f.Severity.String()), f.Title)
+			if loc := f.Location(); loc != "" {
+				fmt.Fprintf(&b, " (`%s`)", loc)
+			}
+			if f.CriticalPath {
+				b.WriteString(" — " + tr("critical path"))
+			}
+			b.WriteString("\n")
+			if f.Message != "" {
+				fmt.Fprintf(&b, "  %s\n", f.Message)
+			}
+			if f.Suggestion != "" {
+				b.WriteString("  " + tr("Suggestion: %s", f.Suggestion) + "\n")
+			}
+			if f.EscalatedBy != "" {
+				b.WriteString("  " + tr("Escalated by rule: %s", f.EscalatedBy) + "\n")
+			}
+			if f.Commit != "" {
+				b.WriteString("  " + tr("Introduced in: %s", f.IntroducedIn()) + "\n")
+			}
+			if f.Confidence == confidenceLow {
+				b.WriteString("  " + tr("Low confidence: only raised by %s", strings.Join(f.RaisedBy, ", ")) + "\n")
+				if f.Disagreement != "" {
+					b.WriteString("  " + tr("Why the models may differ: %s", f.Disagreement) + "\n")
+				}
+			}
+			b.WriteString(renderPatch(f))
+			b.WriteString(renderEvidence(f))
+		}
+	}
+	return b.String()
+}
+
+// renderPatch formats a finding's suggested patch as a collapsible block
+// within its list item
+func renderPatch(f Finding) string {
+	if f.Patch == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n  <details><summary>" + tr("Suggested patch") + "</summary>\n\n  ```diff\n")
+	for _, line := range strings.Split(strings.Trim(f.Patch, "\n"), "\n") {
+		b.WriteString("  " + line + "\n")
+	}
+	b.WriteString("  ```\n\n  </details>\n")
+	return b.String()
+}
+
+// groupFindings partitions findings by the given mode. Groups are ordered by
+// severity (descending) or by name; within a group findings are ordered by
+// severity, then location.
+func groupFindings(findings []Finding, groupBy string) []findingGroup {
+	sorted := make([]Finding, len(findings))
+	copy(sorted, findings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Severity != sorted[j].Severity {
+			return sorted[i].Severity > sorted[j].Severity
+		}
+		if sorted[i].File != sorted[j].File {
+			return sorted[i].File < sorted[j].File
+		}
+		return sorted[i].Line < sorted[j].Line
+	})
+
+	var key func(Finding) string
+	switch groupBy {
+	case groupByFile:
+		key = func(f Finding) string {
+			if f.File == "" {
+				return tr("General")
+			}
+			return "`" + f.File + "`"
+		}
+	case groupByCategory:
+		key = func(f Finding) string {
+			if f.Category == "" {
+				return tr("Uncategorized")
+			}
+			return f.Category
+		}
+	default:
+		key = func(f Finding) string {
+			return tr(strings.ToUpper(f.Severity.String()[:1]) + f.Severity.String()[1:])
+		}
+	}
+
+	var groups []findingGroup
+	index := make(map[string]int)
+	for _, f := range sorted {
+		k := key(f)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, findingGroup{title: k})
+		}
+		groups[i].findings = append(groups[i].findings, f)
+	}
+
+	// Severity groups are already in severity order; others read best sorted by name
+	if groupBy == groupByFile || groupBy == groupByCategory {
+		sort.SliceStable(groups, func(i, j int) bool {
+			return groups[i].title < groups[j].title
+		})
+	}
+	return groups
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRenderFindings tests that findings are rendered most severe first
+func TestRenderFindings(t *testing.T) {
+	findings := []Finding{
+		{File: "a.go", Severity: SeverityLow, Title: "Naming"},
+		{File: "b.go", Line: 7, Severity: SeverityCritical, Title: "SQL injection", Message: "Query is built from user input"},
+	}
+
+	got := renderFindings(findings, groupBySeverity)
+	critical := strings.Index(got, "SQL injection")
+	low := strings.Index(got, "Naming")
+	if critical == -1 || low == -1 || critical > low {
+		t.Errorf("renderFindings() did not order by severity:\n%s", got)
+	}
+	if !strings.Contains(got, "### Critical (1)") || !strings.Contains(got, "### Low (1)") {
+		t.Errorf("renderFindings() missing severity headings:\n%s", got)
+	}
+	if !strings.Contains(got, "`b.go:7`") {
+		t.Errorf("renderFindings() missing location:\n%s", got)
+	}
+
+	if renderFindings(nil, groupBySeverity) != "" {
+		t.Errorf("renderFindings(nil) should be empty")
+	}
+}
+
+// TestGroupFindings tests grouping findings by file and by category
+func TestGroupFindings(t *testing.T) {
+	findings := []Finding{
+		{File: "z.go", Line: 3, Severity: SeverityLow, Category: "style", Title: "A"},
+		{File: "a.go", Line: 9, Severity: SeverityMedium, Category: "testing", Title: "B"},
+		{File: "z.go", Line: 1, Severity: SeverityHigh, Category: "style", Title: "C"},
+		{Severity: SeverityInfo, Title: "D"},
+	}
+
+	tests := []struct {
+		groupBy string
+		titles  []string
+		counts  []int
+	}{
+		{groupByFile, []string{"General", "`a.go`", "`z.go`"}, []int{1, 1, 2}},
+		{groupByCategory, []string{"Uncategorized", "style", "testing"}, []int{1, 2, 1}},
+		{groupBySeverity, []string{"High", "Medium", "Low", "Info"}, []int{1, 1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		groups := groupFindings(findings, tt.groupBy)
+		if len(groups) != len(tt.titles) {
+			t.Errorf("groupFindings(%s) returned %d groups, want %d", tt.groupBy, len(groups), len(tt.titles))
+			continue
+		}
+		for i, g := range groups {
+			if g.title != tt.titles[i] || len(g.findings) != tt.counts[i] {
+				t.Errorf("groupFindings(%s) group %d = %q (%d), want %q (%d)",
+					tt.groupBy, i, g.title, len(g.findings), tt.titles[i], tt.counts[i])
+			}
+		}
+	}
+
+	// Within a file, the most severe finding comes first
+	groups := groupFindings(findings, groupByFile)
+	if groups[2].findings[0].Title != "C" {
+		t.Errorf("expected most severe finding first in z.go, got %q", groups[2].findings[0].Title)
+	}
+}
+
+// TestValidateGroupBy tests -group-by validation
+func TestValidateGroupBy(t *testing.T) {
+	for _, mode := range []string{"severity", "file", "category"} {
+		if err := validateGroupBy(mode); err != nil {
+			t.Errorf("validateGroupBy(%q) returned error: %v", mode, err)
+		}
+	}
+	if err := validateGroupBy("author"); err == nil {
+		t.Error("validateGroupBy(\"author\") expected error")
+	}
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// flakyPassMaxTokens bounds the dedicated flaky-test pass, which only looks
+// at test changes and does not use extended thinking
+const flakyPassMaxTokens = 8000
+
+// flakyPattern is a deterministic check for an obvious flakiness cause in
+// added test code. With remote set, the first group of re is the host a
+// call connects to, and calls to the machine itself don't count.
+type flakyPattern struct {
+	re       *regexp.Regexp
+	remote   bool
+	severity Severity
+	title    string
+	advice   string
+}
+
+var flakyPatterns = []flakyPattern{
+	{
+		re:       regexp.MustCompile(`\btime\.Sleep\(|\btime\.sleep\(|\bThread\.sleep\(|\bsetTimeout\(`),
+		severity: SeverityMedium,
+		title:    "Sleep-based synchronization in test",
+		advice:   "Sleeping to wait for work makes the test slow on fast machines and flaky on loaded CI runners. Wait on a channel, condition or polling helper with a deadline, or inject a fake clock.",
+	},
+	{
+		re:       regexp.MustCompile(`\b(?:http\.(?:Get|Head|Post|PostForm|NewRequest|NewRequestWithContext)|http\.DefaultClient\.(?:Get|Head|Post)|requests\.(?:get|head|post|put|patch|delete)|fetch)\(.*?["'\x60]https?://([^/"'\x60\s]+)`),
+		remote:   true,
+		severity: SeverityMedium,
+		title:    "Test calls a real network endpoint",
+		advice:   "Tests that reach external hosts fail when the network or the service is unavailable. Use an in-process test server (e.g. httptest.NewServer) or a fake client.",
+	},
+	{
+		re:       regexp.MustCompile(`\bnet\.Dial(?:Timeout)?\(\s*"[a-z0-9]+"\s*,\s*"([^"]+)"`),
+		remote:   true,
+		severity: SeverityMedium,
+		title:    "Test calls a real network endpoint",
+		advice:   "Tests that reach external hosts fail when the network or the service is unavailable. Dial a listener the test starts on 127.0.0.1:0 instead.",
+	},
+	{
+		re:       regexp.MustCompile(`\b(?:Listen|ListenAndServe|ListenPacket)\([^)]*":\d{2,5}"`),
+		severity: SeverityMedium,
+		title:    "Test listens on a fixed port",
+		advice:   "A hard-coded port collides with other tests or processes. Listen on port 0 and read the assigned address back.",
+	},
+	{
+		re:       regexp.MustCompile(`\bos\.(?:Setenv|Unsetenv|Chdir)\(`),
+		severity: SeverityLow,
+		title:    "Test mutates process-wide state",
+		advice:   "Environment variables and the working directory are shared by every test in the process and leak between tests. Use t.Setenv / t.Chdir, which restore the previous value and forbid parallel use.",
+	},
+	{
+		re:       regexp.MustCompile(`\brand\.(?:Int|Intn|Int63|Float64|Perm|Shuffle)\(`),
+		severity: SeverityLow,
+		title:    "Test uses unseeded randomness",
+		advice:   "Random inputs make failures hard to reproduce. Use a fixed seed (rand.New(rand.NewSource(1))) or log the seed on failure.",
+	},
+}
+
+// localHosts are hosts, with or without a port, that do not leave the
+// machine or are reserved for documentation, so calls to them do not
+// indicate a real network dependency
+var localHosts = regexp.MustCompile(`^(?:|localhost|127(?:\.\d+){3}|0\.0\.0\.0|\[::1\]|(?:[a-zA-Z0-9-]+\.)*example\.(?:com|org|net)|[a-zA-Z0-9.-]+\.(?:test|invalid|localhost))(?::\d*)?$`)
+
+// isTestFile reports whether path looks like a test source file
+func isTestFile(p string) bool {
+	base := path.Base(p)
+	switch {
+	case strings.HasSuffix(base, "_test.go"):
+		return true
+	case strings.Contains(base, ".test.") || strings.Contains(base, ".spec."):
+		return true
+	case strings.HasSuffix(base, ".py") && (strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_test.py")):
+		return true
+	case strings.HasSuffix(base, "Test.java") || strings.HasSuffix(base, "Tests.java"):
+		return true
+	}
+	return strings.Contains(p, "__tests__/")
+}
+
+// testDiff returns the part of a diff that changes test files
+func testDiff(diff string) string {
+	var tests []gitdiff.File
+	for _, f := range gitdiff.Split(diff) {
+		if isTestFile(f.Path) {
+			tests = append(tests, f)
+		}
+	}
+	return gitdiff.Join(tests)
+}
+
+// flakyStaticFindings runs the deterministic flakiness checks over the lines
+// the diff adds to test files
+func flakyStaticFindings(diff string) []Finding {
+	var findings []Finding
+	for _, f := range gitdiff.Split(diff) {
+		if !isTestFile(f.Path) {
+			continue
+		}
+		for _, line := range gitdiff.Added(f) {
+			for _, p := range flakyPatterns {
+				match := p.re.FindStringSubmatch(line.Text)
+				if match == nil || p.remote && localHosts.MatchString(match[1]) {
+					continue
+				}
+				findings = append(findings, Finding{
+					File:     line.File,
+					Line:     line.Line,
+					Severity: p.severity,
+					Category: "flaky-test",
+					Title:    p.title,
+					Message:  p.advice,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// buildFlakyPrompt asks for a dedicated assessment of the flakiness risk of
+// the test changes, seeded with the static check results
+func buildFlakyPrompt(tests string, static []Finding) string {
+	prompt := `You are an expert in test reliability. Review ONLY the test changes below for
+flakiness risk: timing and sleeps, real network or filesystem dependencies,
+shared global or package-level state, test ordering and parallelism, map or
+goroutine scheduling order assumptions, wall-clock and time zone dependencies,
+and unseeded randomness. For each risk, explain how the test could fail
+intermittently and give a concrete fix. If the tests look deterministic, say so
+briefly.
+
+`
+	if len(static) > 0 {
+		prompt += "## Static Check Results\n\nAutomated checks already flagged these lines; confirm or dismiss each one:\n\n"
+		for _, f := range static {
+			prompt += fmt.Sprintf("- `%s`: %s\n", f.Location(), f.Title)
+		}
+		prompt += "\n"
+	}
+
+	prompt += "## Test Changes\n```diff\n" + tests + "\n```\n\n" + review.FindingsInstructions
+	return prompt
+}
+
+// mergeFlakyFindings combines static and model findings, dropping model
+// findings at a line the static checks already flagged
+func mergeFlakyFindings(static, model []Finding) []Finding {
+	flagged := make(map[string]bool)
+	for _, f := range static {
+		flagged[f.Location()] = true
+	}
+	merged := append([]Finding(nil), static...)
+	for _, f := range model {
+		if f.Line > 0 && flagged[f.Location()] {
+			continue
+		}
+		merged = append(merged, f)
+	}
+	return merged
+}
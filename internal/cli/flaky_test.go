@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marete/pr-review/pkg/review"
+)
+
+const flakyDiff = `diff --git a/server.go b/server.go
+--- a/server.go
++++ b/server.go
+@@ -1 +1,2 @@
+ package app
++func wait() { time.Sleep(time.Second) }
+diff --git a/server_test.go b/server_test.go
+--- a/server_test.go
++++ b/server_test.go
+@@ -5,2 +5,12 @@ func TestServer(t *testing.T) {
+ 	srv := start()
++	time.Sleep(100 * time.Millisecond)
++	resp, err := http.Get("https://api.github.com/repos")
++	local, _ := http.Get("http://127.0.0.1:8080/health")
++	docs, _ := http.Get("https://docs.example.com/x")
++	ln, _ := net.Listen("tcp", ":8080")
++	os.Setenv("MODE", "test")
++	keys := slices.Sorted(maps.Keys(m))
++	u, _ := url.Parse("https://codeberg.org/owner/repo")
++	conn, _ := net.Dial("tcp", "db.internal:5432")
++	loopback, _ := net.Dial("tcp", "127.0.0.1:5432")
+ 	defer srv.Close()
+`
+
+// TestIsTestFile tests recognizing test files across languages
+func TestIsTestFile(t *testing.T) {
+	tests := map[string]bool{
+		"pkg/server_test.go":         true,
+		"web/app.test.ts":            true,
+		"web/app.spec.js":            true,
+		"tests/test_api.py":          true,
+		"api_test.py":                true,
+		"src/FooTest.java":           true,
+		"web/__tests__/button.js":    true,
+		"pkg/server.go":              false,
+		"testdata/fixture.json":      false,
+		"docs/testing-guidelines.md": false,
+	}
+	for p, want := range tests {
+		if got := isTestFile(p); got != want {
+			t.Errorf("isTestFile(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+// TestTestDiff tests that only test file changes are kept
+func TestTestDiff(t *testing.T) {
+	got := testDiff(flakyDiff)
+	if !strings.HasPrefix(got, "diff --git a/server_test.go") || strings.Contains(got, "server.go b/server.go") {
+		t.Errorf("testDiff() = %q", got)
+	}
+	if testDiff("diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n") != "" {
+		t.Error("testDiff() should be empty without test changes")
+	}
+}
+
+// TestFlakyStaticFindings tests the deterministic flakiness checks
+func TestFlakyStaticFindings(t *testing.T) {
+	findings := flakyStaticFindings(flakyDiff)
+
+	want := map[int]string{
+		6:  "Sleep-based synchronization in test",
+		7:  "Test calls a real network endpoint",
+		10: "Test listens on a fixed port",
+		11: "Test mutates process-wide state",
+		14: "Test calls a real network endpoint",
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("flakyStaticFindings() = %+v, want %d findings", findings, len(want))
+	}
+	for _, f := range findings {
+		if f.File != "server_test.go" || f.Category != "flaky-test" {
+			t.Errorf("unexpected finding %+v", f)
+		}
+		if want[f.Line] != f.Title {
+			t.Errorf("line %d: got %q, want %q", f.Line, f.Title, want[f.Line])
+		}
+	}
+}
+
+// TestMergeFlakyFindings tests that model findings duplicating static ones are dropped
+func TestMergeFlakyFindings(t *testing.T) {
+	static := []Finding{{File: "a_test.go", Line: 3, Title: "Sleep"}}
+	model := []Finding{
+		{File: "a_test.go", Line: 3, Title: "Sleep again"},
+		{File: "a_test.go", Line: 9, Title: "Shared global"},
+		{File: "a_test.go", Title: "General ordering concern"},
+	}
+
+	merged := mergeFlakyFindings(static, model)
+	var titles []string
+	for _, f := range merged {
+		titles = append(titles, f.Title)
+	}
+	if strings.Join(titles, ",") != "Sleep,Shared global,General ordering concern" {
+		t.Errorf("mergeFlakyFindings() = %v", titles)
+	}
+}
+
+// TestBuildFlakyPrompt tests that static results are passed to the model
+func TestBuildFlakyPrompt(t *testing.T) {
+	prompt := buildFlakyPrompt("+time.Sleep(1)", []Finding{{File: "a_test.go", Line: 3, Title: "Sleep-based synchronization in test"}})
+	for _, want := range []string{"## Static Check Results", "`a_test.go:3`: Sleep-based", "## Test Changes", "+time.Sleep(1)", review.FindingsStartTag} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("flaky prompt is missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Report formats
+const (
+	formatMarkdown    = "markdown"
+	formatJSON        = "json"
+	formatSARIF       = "sarif"
+	formatAnnotations = "gh-annotations"
+	formatCodeClimate = "codeclimate"
+	formatJUnit       = "junit"
+)
+
+var formats = []string{formatMarkdown, formatJSON, formatSARIF, formatAnnotations, formatCodeClimate, formatJUnit}
+
+// validateFormat checks that format is a supported -format value
+func validateFormat(format string) error {
+	for _, f := range formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format %q (want one of: %s)", format, strings.Join(formats, ", "))
+}
+
+// writeMachineReport writes a review in one of the machine-readable formats
+func writeMachineReport(out io.Writer, format string, doc *reviewDocument) error {
+	switch format {
+	case formatSARIF:
+		return writeSARIF(out, doc)
+	case formatAnnotations:
+		return writeAnnotations(out, doc)
+	case formatCodeClimate:
+		return writeCodeClimate(out, doc)
+	case formatJUnit:
+		return writeJUnit(out, doc)
+	}
+	return writeReviewDocument(out, doc)
+}
+
+// jsonFormatInstructions is added to the prompt in the machine-readable
+// formats, where the findings are the output and the prose only summarizes
+// them
+const jsonFormatInstructions = `Your review will be consumed by tools rather than read as a report. Keep the
+prose to a short summary of the change and your overall assessment (a few
+sentences, no headings); put every issue in the findings instead, with a
+"suggestion" for each one you can propose a fix for.`
+
+// reviewDocument is the machine-readable review emitted with -format json
+type reviewDocument struct {
+	Tool      string    `json:"tool"`
+	Repo      string    `json:"repo"`
+	Branch    string    `json:"branch"`
+	BaseRef   string    `json:"base_ref"`
+	BaseSHA   string    `json:"base_sha"`
+	HeadSHA   string    `json:"head_sha"`
+	Model     string    `json:"model"`
+	Summary   string    `json:"summary"`
+	Findings  []Finding `json:"findings"`
+	Checklist []string  `json:"checklist,omitempty"`
+	Usage     Usage     `json:"usage"`
+
+	// failOn is the review gate, for the formats that report which
+	// findings fail it
+	failOn *Severity
+}
+
+// newReviewDocument builds the JSON document for a review record
+func newReviewDocument(r *reviewRecord, summary string, checklist []string) *reviewDocument {
+	findings := r.Findings
+	if findings == nil {
+		findings = []Finding{}
+	}
+	return &reviewDocument{
+		Tool:      "pr-review",
+		Repo:      r.Repo,
+		Branch:    r.Branch,
+		BaseRef:   r.BaseRef,
+		BaseSHA:   r.BaseSHA,
+		HeadSHA:   r.HeadSHA,
+		Model:     r.Model,
+		Summary:   strings.TrimSpace(summary),
+		Findings:  findings,
+		Checklist: checklist,
+		Usage:     Usage{InputTokens: r.InputTokens, OutputTokens: r.OutputTokens},
+	}
+}
+
+// writeReviewDocument writes doc as indented JSON
+func writeReviewDocument(out io.Writer, doc *reviewDocument) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestValidateFormat tests accepted -format values
+func TestValidateFormat(t *testing.T) {
+	for _, f := range formats {
+		if err := validateFormat(f); err != nil {
+			t.Errorf("validateFormat(%q) returned error: %v", f, err)
+		}
+	}
+	if err := validateFormat("xml"); err == nil {
+		t.Error("validateFormat(\"xml\") expected error")
+	}
+}
+
+// TestWriteReviewDocument tests the JSON document emitted with -format json
+func TestWriteReviewDocument(t *testing.T) {
+	record := &reviewRecord{
+		Repo: "github.com/example/repo", Branch: "feature", BaseRef: "main",
+		BaseSHA: "aaa", HeadSHA: "bbb", Model: "claude-sonnet-4-5",
+		Findings: []Finding{{
+			File: "auth/login.go", Line: 42, Severity: SeverityHigh, Category: "security",
+			Title: "Password compared with ==", Message: "Timing attack", Suggestion: "Use subtle.ConstantTimeCompare",
+		}},
+		InputTokens: 100, OutputTokens: 20,
+	}
+
+	var buf bytes.Buffer
+	if err := writeReviewDocument(&buf, newReviewDocument(record, "  Looks risky.\n", []string{"Rotate the test keys"})); err != nil {
+		t.Fatalf("writeReviewDocument() returned error: %v", err)
+	}
+
+	var doc reviewDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if doc.Summary != "Looks risky." || doc.HeadSHA != "bbb" || doc.Usage.OutputTokens != 20 {
+		t.Errorf("document = %+v", doc)
+	}
+	if len(doc.Findings) != 1 || !reflect.DeepEqual(doc.Findings[0], record.Findings[0]) {
+		t.Errorf("findings = %+v", doc.Findings)
+	}
+	if len(doc.Checklist) != 1 {
+		t.Errorf("checklist = %q", doc.Checklist)
+	}
+	for _, want := range []string{`"severity": "high"`, `"suggestion": "Use subtle.ConstantTimeCompare"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %s", want)
+		}
+	}
+}
+
+// TestNewReviewDocument_NoFindings tests that a clean review has an empty
+// findings array rather than null
+func TestNewReviewDocument_NoFindings(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeReviewDocument(&buf, newReviewDocument(&reviewRecord{}, "", nil)); err != nil {
+		t.Fatalf("writeReviewDocument() returned error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"findings": []`) {
+		t.Errorf("output = %s", buf.String())
+	}
+}
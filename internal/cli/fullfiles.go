@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+// defaultFullFilesMax is how much of the changed files' contents
+// -full-files adds to the prompt at most
+const defaultFullFilesMax = 512 << 10
+
+// fullFiles returns the contents after the change of the files a diff
+// modifies, as additional context, for as many of them as fit in maxBytes
+// in diff order. New files are whole in the diff already; deleted, binary
+// and summarized files have no contents to show. It also returns the files
+// left out for lack of room or because show failed.
+func fullFiles(diff string, maxBytes ByteSize, show func(path string) (string, error)) (string, []string) {
+	var b strings.Builder
+	var skipped []string
+	used := ByteSize(0)
+	for _, f := range gitdiff.Split(diff) {
+		header, hunks := gitdiff.Hunks(f)
+		if hunks == nil || strings.Contains(header, "\nnew file mode ") || strings.Contains(header, "\ndeleted file mode ") {
+			continue
+		}
+		content, err := show(f.Path)
+		if err != nil || used+ByteSize(len(content)) > maxBytes {
+			skipped = append(skipped, f.Path)
+			continue
+		}
+		used += ByteSize(len(content))
+		fmt.Fprintf(&b, "\n\n--- Full contents of %s after the change ---\n%s\n", f.Path, content)
+	}
+	return b.String(), skipped
+}
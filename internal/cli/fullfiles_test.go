@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestFullFiles tests adding the contents of modified files up to the cap
+func TestFullFiles(t *testing.T) {
+	diff := fileDiff("a.go", 10) + fileDiff("big.go", 10) + fileDiff("gone.go", 10) +
+		"diff --git a/new.go b/new.go\nnew file mode 100644\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package new\n" +
+		"diff --git a/logo.png b/logo.png\nBinary files a/logo.png and b/logo.png differ\n" + fileDiff("b.go", 10)
+	contents := map[string]string{"a.go": "package a\n", "big.go": strings.Repeat("x", 100), "b.go": "package b\n", "new.go": "package new\n"}
+	var shown []string
+	show := func(path string) (string, error) {
+		shown = append(shown, path)
+		if c, ok := contents[path]; ok {
+			return c, nil
+		}
+		return "", errors.New("not found")
+	}
+	context, skipped := fullFiles(diff, 50, show)
+	if want := "\n\n--- Full contents of a.go after the change ---\npackage a\n\n\n\n--- Full contents of b.go after the change ---\npackage b\n\n"; context != want {
+		t.Errorf("fullFiles() context = %q, want %q", context, want)
+	}
+	if !reflect.DeepEqual(skipped, []string{"big.go", "gone.go"}) {
+		t.Errorf("fullFiles() skipped = %q", skipped)
+	}
+	if !reflect.DeepEqual(shown, []string{"a.go", "big.go", "gone.go", "b.go"}) {
+		t.Errorf("fullFiles() looked up %q, want the modified files", shown)
+	}
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+// generatedNames are globs for files that are generated under well-known
+// names: lock files, checksums, compiled protobufs and minified bundles
+var generatedNames = []string{
+	"go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.lock",
+	"Gemfile.lock", "composer.lock", "poetry.lock", "Pipfile.lock",
+	"*.pb.go", "*.pb.gw.go", "*_pb2.py", "*_pb2_grpc.py", "*.pb.cc", "*.pb.h",
+	"zz_generated*.go", "*_generated.go", "*.min.js", "*.min.css", "*.map",
+}
+
+// generatedMarker matches the comments generators put at the top of the
+// files they write, such as Go's "Code generated ... DO NOT EDIT."
+var generatedMarker = regexp.MustCompile(`(?i)code generated .*do not edit|@generated|auto-?generated .*do not (edit|modify)`)
+
+// generatedMarkerLines is how far from the top of a file the marker is
+// looked for
+const generatedMarkerLines = 10
+
+// generatedFiles returns the files of a diff that are generated: marked
+// linguist-generated in .gitattributes, as attrs reports the attribute,
+// with a generated-code marker near their top, or with a well-known
+// generated name. attrs may be nil, e.g. for a change of another
+// repository.
+func generatedFiles(diff string, attrs func(paths []string) (map[string]string, error)) []string {
+	files := gitdiff.Split(diff)
+	linguist := map[string]string{}
+	if attrs != nil {
+		paths := make([]string, len(files))
+		for i, f := range files {
+			paths[i] = f.Path
+		}
+		if values, err := attrs(paths); err == nil {
+			linguist = values
+		}
+	}
+
+	var generated []string
+	for _, f := range files {
+		switch linguist[f.Path] {
+		case "set", "true":
+			generated = append(generated, f.Path)
+			continue
+		case "unset", "false":
+			// Marked as not generated, whatever its name
+			continue
+		}
+		if matchAnyGlob(generatedNames, f.Path) || hasGeneratedMarker(f) {
+			generated = append(generated, f.Path)
+		}
+	}
+	return generated
+}
+
+// hasGeneratedMarker reports whether a file section shows a generated-code
+// marker in the first lines of the new file
+func hasGeneratedMarker(f gitdiff.File) bool {
+	_, hunks := gitdiff.Hunks(f)
+	for _, h := range hunks {
+		if h.NewStart > generatedMarkerLines {
+			break
+		}
+		line := h.NewStart
+		for _, text := range h.Lines {
+			if line > generatedMarkerLines {
+				break
+			}
+			if strings.HasPrefix(text, "-") || strings.HasPrefix(text, `\`) {
+				continue
+			}
+			if generatedMarker.MatchString(text) {
+				return true
+			}
+			line++
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGeneratedFiles tests detecting generated files by attribute, marker
+// and name
+func TestGeneratedFiles(t *testing.T) {
+	diff := fileDiff("app.go", 10) + fileDiff("go.sum", 10) + fileDiff("api/types.pb.go", 10) +
+		"diff --git a/mocks/db.go b/mocks/db.go\n--- a/mocks/db.go\n+++ b/mocks/db.go\n@@ -1,3 +1,3 @@\n // Code generated by MockGen. DO NOT EDIT.\n-package old\n+package mocks\n" +
+		"diff --git a/late.go b/late.go\n--- a/late.go\n+++ b/late.go\n@@ -40,1 +40,1 @@\n-x\n+// Code generated by hand. DO NOT EDIT.\n" +
+		fileDiff("schema/gen.sql", 10) + fileDiff("vendor/keep.lock", 10) + fileDiff("web/yarn.lock", 10)
+	attrs := func(paths []string) (map[string]string, error) {
+		if !reflect.DeepEqual(paths[:2], []string{"app.go", "go.sum"}) {
+			t.Errorf("attrs() paths = %q", paths)
+		}
+		return map[string]string{"schema/gen.sql": "set", "web/yarn.lock": "unset", "app.go": "unspecified"}, nil
+	}
+	got := generatedFiles(diff, attrs)
+	if want := []string{"go.sum", "api/types.pb.go", "mocks/db.go", "schema/gen.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("generatedFiles() = %q, want %q", got, want)
+	}
+	if got := generatedFiles(diff, nil); !reflect.DeepEqual(got, []string{"go.sum", "api/types.pb.go", "mocks/db.go", "web/yarn.lock"}) {
+		t.Errorf("generatedFiles() without attributes = %q", got)
+	}
+	if got := generatedFiles(fileDiff("app.go", 10), nil); got != nil {
+		t.Errorf("generatedFiles() of hand-written code = %q", strings.Join(got, ", "))
+	}
+}
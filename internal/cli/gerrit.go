@@ -0,0 +1,306 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const postGerrit = "gerrit"
+
+// gerritRobotID identifies the tool's robot comments on a change
+const gerritRobotID = "pr-review"
+
+// gerritClient calls the Gerrit REST API
+type gerritClient struct {
+	url      string // Gerrit web URL, without a trailing slash
+	user     string
+	password string // HTTP password; without one, requests are anonymous
+}
+
+// newGerritClient returns a client for the Gerrit server at GERRIT_URL,
+// authenticated with GERRIT_USERNAME and GERRIT_PASSWORD (the HTTP password
+// from the user's settings) if they are set
+func newGerritClient() (*gerritClient, error) {
+	base := strings.TrimSuffix(os.Getenv("GERRIT_URL"), "/")
+	if base == "" {
+		return nil, fmt.Errorf("GERRIT_URL environment variable not set")
+	}
+	return &gerritClient{url: base, user: os.Getenv("GERRIT_USERNAME"), password: os.Getenv("GERRIT_PASSWORD")}, nil
+}
+
+// gerritJSONPrefix guards Gerrit's JSON responses against XSSI
+const gerritJSONPrefix = ")]}'"
+
+// do sends an API request and decodes a JSON response into out, if set.
+// Authenticated requests go through Gerrit's /a/ prefix.
+func (g *gerritClient) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("error marshaling request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	endpoint := g.url + path
+	if g.password != "" {
+		endpoint = g.url + "/a" + path
+	}
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if g.password != "" {
+		req.SetBasicAuth(g.user, g.password)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("API error from Gerrit (status %d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		data = bytes.TrimPrefix(data, []byte(gerritJSONPrefix))
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("error unmarshaling response: %w", err)
+		}
+	}
+	return nil
+}
+
+// gerritChange is what the tool needs of a change
+type gerritChange struct {
+	Number          int    `json:"_number"`
+	Project         string `json:"project"`
+	Branch          string `json:"branch"`
+	ChangeID        string `json:"change_id"`
+	CurrentRevision string `json:"current_revision"`
+	Revisions       map[string]struct {
+		Ref    string `json:"ref"`
+		Number int    `json:"_number"`
+	} `json:"revisions"`
+}
+
+// change looks up a change by number or Change-Id, with its current patch
+// set
+func (g *gerritClient) change(id string) (*gerritChange, error) {
+	var change gerritChange
+	if err := g.do("GET", "/changes/"+url.PathEscape(id)+"?o=CURRENT_REVISION", nil, &change); err != nil {
+		return nil, fmt.Errorf("failed to get change %s: %w", id, err)
+	}
+	return &change, nil
+}
+
+// changeByCommit finds the change that has commit as a patch set
+func (g *gerritClient) changeByCommit(commit string) (*gerritChange, error) {
+	var changes []gerritChange
+	if err := g.do("GET", "/changes/?q=commit:"+url.QueryEscape(commit), nil, &changes); err != nil {
+		return nil, fmt.Errorf("failed to find change for %s: %w", shortSHA(commit), err)
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("no change has %s as a patch set; push it for review or pass -change", shortSHA(commit))
+	}
+	return &changes[0], nil
+}
+
+// files returns the paths a patch set changes, which are the only files
+// comments can be placed on
+func (g *gerritClient) files(change int, revision string) (map[string]bool, error) {
+	var files map[string]json.RawMessage
+	if err := g.do("GET", fmt.Sprintf("/changes/%d/revisions/%s/files", change, revision), nil, &files); err != nil {
+		return nil, fmt.Errorf("failed to list files of change %d: %w", change, err)
+	}
+	paths := make(map[string]bool, len(files))
+	for path := range files {
+		// Gerrit lists the commit message as a file too
+		if !strings.HasPrefix(path, "/") {
+			paths[path] = true
+		}
+	}
+	return paths, nil
+}
+
+// gerritRange is a span of a file; characters are 0-based columns
+type gerritRange struct {
+	StartLine      int `json:"start_line"`
+	StartCharacter int `json:"start_character"`
+	EndLine        int `json:"end_line"`
+	EndCharacter   int `json:"end_character"`
+}
+
+// gerritReplacement is one edit of a fix suggestion
+type gerritReplacement struct {
+	Path        string      `json:"path"`
+	Range       gerritRange `json:"range"`
+	Replacement string      `json:"replacement"`
+}
+
+// gerritFixSuggestion is a fix the author can preview and apply
+type gerritFixSuggestion struct {
+	Description  string              `json:"description"`
+	Replacements []gerritReplacement `json:"replacements"`
+}
+
+// robotComment is a finding posted as a Gerrit robot comment
+type robotComment struct {
+	RobotID        string                `json:"robot_id"`
+	RobotRunID     string                `json:"robot_run_id"`
+	Line           int                   `json:"line,omitempty"`
+	Message        string                `json:"message"`
+	Properties     map[string]string     `json:"properties,omitempty"`
+	FixSuggestions []gerritFixSuggestion `json:"fix_suggestions,omitempty"`
+}
+
+// robotComments turns findings on files of the change into robot comments
+// by path, returning the findings that can't be placed
+func robotComments(findings []Finding, files map[string]bool, runID string) (map[string][]robotComment, []Finding) {
+	comments := make(map[string][]robotComment)
+	var rest []Finding
+	for _, f := range findings {
+		if f.File == "" || !files[f.File] {
+			rest = append(rest, f)
+			continue
+		}
+		comment := robotComment{
+			RobotID:    gerritRobotID,
+			RobotRunID: runID,
+			Line:       f.Line,
+			Message:    inlineCommentBody(f, false),
+			Properties: map[string]string{"severity": f.Severity.String()},
+		}
+		if f.Category != "" {
+			comment.Properties["category"] = f.Category
+		}
+		if f.Replacement != "" && f.Line > 0 {
+			// Replace whole lines: from the start of the first to the start
+			// of the line after the last
+			end := max(f.EndLine, f.Line) + 1
+			comment.FixSuggestions = []gerritFixSuggestion{{
+				Description: f.Title,
+				Replacements: []gerritReplacement{{
+					Path:        f.File,
+					Range:       gerritRange{StartLine: f.Line, EndLine: end},
+					Replacement: strings.TrimSuffix(f.Replacement, "\n") + "\n",
+				}},
+			}}
+		}
+		comments[f.File] = append(comments[f.File], comment)
+	}
+	return comments, rest
+}
+
+// postRobotReview posts a review of a patch set: the review text is its
+// message and each finding on a file of the change a robot comment
+func (g *gerritClient) postRobotReview(change *gerritChange, revision, review string, findings []Finding) error {
+	files, err := g.files(change.Number, revision)
+	if err != nil {
+		return err
+	}
+	comments, rest := robotComments(findings, files, time.Now().UTC().Format("20060102T150405Z"))
+	if len(rest) > 0 {
+		fmt.Printf("   %s outside the change's files are in the review message only\n", plural(len(rest), "finding"))
+	}
+
+	request := struct {
+		Message       string                    `json:"message"`
+		Tag           string                    `json:"tag"`
+		RobotComments map[string][]robotComment `json:"robot_comments,omitempty"`
+	}{review, "autogenerated:pr-review", comments}
+	path := fmt.Sprintf("/changes/%d/revisions/%s/review", change.Number, revision)
+	if err := g.do("POST", path, request, nil); err != nil {
+		return fmt.Errorf("failed to post review on change %d: %w", change.Number, err)
+	}
+	return nil
+}
+
+// changeURL returns the web URL of a change
+func (g *gerritClient) changeURL(change *gerritChange) string {
+	return fmt.Sprintf("%s/c/%s/+/%d", g.url, change.Project, change.Number)
+}
+
+// postGerritReview posts a review to change number pr, or if it is zero to
+// the change that has the reviewed commit as a patch set
+func postGerritReview(pr int, review string, findings []Finding, headSHA string) error {
+	g, err := newGerritClient()
+	if err != nil {
+		return err
+	}
+	var change *gerritChange
+	if pr != 0 {
+		change, err = g.change(strconv.Itoa(pr))
+	} else {
+		change, err = g.changeByCommit(headSHA)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Comments go on the reviewed patch set, which may not be the latest
+	revision := headSHA
+	if revision == "" {
+		revision = change.CurrentRevision
+	} else if change.CurrentRevision != "" && change.CurrentRevision != headSHA {
+		fmt.Fprintf(os.Stderr, "Warning: Change %d has a newer patch set than the reviewed %s; posting on the reviewed one\n",
+			change.Number, shortSHA(headSHA))
+	}
+	if err := g.postRobotReview(change, revision, review, findings); err != nil {
+		return err
+	}
+	fmt.Printf("💬 Review posted to change %d: %s\n\n", change.Number, g.changeURL(change))
+	return nil
+}
+
+// fetchGerritChange fetches the current patch set of a change (by number or
+// Change-Id) from origin into private refs under refs/pr-review/, like
+// fetchPullRequest. The base is base if given, else the change's branch.
+func fetchGerritChange(id, base string) (*pullTarget, error) {
+	g, err := newGerritClient()
+	if err != nil {
+		return nil, err
+	}
+	change, err := g.change(id)
+	if err != nil {
+		return nil, err
+	}
+	revision, ok := change.Revisions[change.CurrentRevision]
+	if !ok || revision.Ref == "" {
+		return nil, fmt.Errorf("change %s has no current patch set", id)
+	}
+
+	target := &pullTarget{
+		Number: change.Number,
+		Head:   fmt.Sprintf("refs/pr-review/change/%d/head", change.Number),
+		Branch: fmt.Sprintf("change/%d/%d", change.Number, revision.Number),
+	}
+	refspecs := []string{fmt.Sprintf("+%s:%s", revision.Ref, target.Head)}
+	if base == "" {
+		base = fmt.Sprintf("refs/pr-review/change/%d/base", change.Number)
+		refspecs = append(refspecs, fmt.Sprintf("+refs/heads/%s:%s", change.Branch, base))
+	}
+	target.Base = base
+
+	if err := fetchRefs(fmt.Sprintf("change %d", change.Number), map[string][]string{"origin": refspecs}); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
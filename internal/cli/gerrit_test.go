@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRobotComments tests placing findings on files of the change and
+// turning replacements into fix suggestions
+func TestRobotComments(t *testing.T) {
+	findings := []Finding{
+		{File: "db.go", Line: 10, EndLine: 11, Severity: SeverityHigh, Category: "security", Title: "Unescaped query", Replacement: "q := escape(input)"},
+		{File: "db.go", Severity: SeverityLow, Title: "File-level note"},
+		{File: "other.go", Line: 3, Severity: SeverityMedium, Title: "Not in the change"},
+		{Severity: SeverityInfo, Title: "No file"},
+	}
+	comments, rest := robotComments(findings, map[string]bool{"db.go": true}, "run1")
+
+	if len(rest) != 2 {
+		t.Errorf("got %d unplaced findings, want 2", len(rest))
+	}
+	got := comments["db.go"]
+	if len(got) != 2 {
+		t.Fatalf("got %d comments on db.go, want 2", len(got))
+	}
+	first := got[0]
+	if first.RobotID != gerritRobotID || first.RobotRunID != "run1" || first.Line != 10 || first.Properties["category"] != "security" {
+		t.Errorf("comment = %+v", first)
+	}
+	if len(first.FixSuggestions) != 1 {
+		t.Fatalf("got %d fix suggestions, want 1", len(first.FixSuggestions))
+	}
+	r := first.FixSuggestions[0].Replacements[0]
+	if r.Range != (gerritRange{StartLine: 10, EndLine: 12}) || r.Replacement != "q := escape(input)\n" {
+		t.Errorf("replacement = %+v", r)
+	}
+	if got[1].Line != 0 || got[1].FixSuggestions != nil {
+		t.Errorf("file-level comment = %+v", got[1])
+	}
+}
+
+// TestPostGerritReview tests finding the change of the reviewed commit and
+// posting robot comments through the authenticated API
+func TestPostGerritReview(t *testing.T) {
+	var posted struct {
+		Message       string                    `json:"message"`
+		Tag           string                    `json:"tag"`
+		RobotComments map[string][]robotComment `json:"robot_comments"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me" || pass != "secret" {
+			t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/a/changes/" && r.URL.Query().Get("q") == "commit:abc123":
+			fmt.Fprint(w, ")]}'\n"+`[{"_number": 42, "project": "app", "branch": "main", "current_revision": "abc123"}]`)
+		case r.Method == "GET" && r.URL.Path == "/a/changes/42/revisions/abc123/files":
+			fmt.Fprint(w, ")]}'\n"+`{"/COMMIT_MSG": {}, "main.go": {"lines_inserted": 2}}`)
+		case r.Method == "POST" && r.URL.Path == "/a/changes/42/revisions/abc123/review":
+			json.NewDecoder(r.Body).Decode(&posted)
+			fmt.Fprint(w, ")]}'\n{}")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GERRIT_URL", server.URL+"/")
+	t.Setenv("GERRIT_USERNAME", "me")
+	t.Setenv("GERRIT_PASSWORD", "secret")
+
+	findings := []Finding{
+		{File: "main.go", Line: 5, Severity: SeverityMedium, Title: "Unchecked error"},
+		{File: "/COMMIT_MSG", Line: 1, Severity: SeverityLow, Title: "Subject too long"},
+	}
+	if err := postGerritReview(0, "Summary", findings, "abc123"); err != nil {
+		t.Fatalf("postGerritReview() returned error: %v", err)
+	}
+	if posted.Message != "Summary" || posted.Tag != "autogenerated:pr-review" {
+		t.Errorf("posted = %+v", posted)
+	}
+	if len(posted.RobotComments) != 1 || len(posted.RobotComments["main.go"]) != 1 {
+		t.Errorf("robot comments = %+v", posted.RobotComments)
+	}
+
+	if err := postGerritReview(0, "Summary", nil, "unknown"); err == nil {
+		t.Error("postGerritReview() of a commit without a change expected error")
+	}
+}
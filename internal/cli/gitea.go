@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+const postGitea = "gitea"
+
+// giteaClient calls the API of a Gitea or Forgejo instance. It follows
+// GitHub's closely enough to share its client for everything but finding
+// pull requests and posting inline reviews.
+type giteaClient struct {
+	*githubClient
+}
+
+// newGiteaClient returns a client for the repository at remote (as
+// normalized by normalizeRemoteURL), authenticated with GITEA_TOKEN
+func newGiteaClient(remote string) (*giteaClient, error) {
+	token := giteaToken()
+	if token == "" {
+		return nil, fmt.Errorf("GITEA_TOKEN environment variable not set")
+	}
+	return giteaRepoClient(remote, token)
+}
+
+// giteaRepoClient returns a client for the repository at remote; without a
+// token it can only read public repositories. The instance is served from
+// the remote's host unless GITEA_URL says otherwise, as it must when clones
+// go through a separate SSH host.
+func giteaRepoClient(remote, token string) (*giteaClient, error) {
+	parts := strings.Split(remote, "/")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("origin %q is not a Gitea repository URL", remote)
+	}
+	base := strings.TrimSuffix(os.Getenv("GITEA_URL"), "/")
+	if base == "" {
+		// SSH remotes may name a port, which the web server doesn't use
+		host, _, _ := strings.Cut(parts[0], ":")
+		base = "https://" + host
+	}
+	return &giteaClient{&githubClient{token: token, api: base + "/api/v1", owner: parts[1], repo: parts[2], service: "Gitea"}}, nil
+}
+
+// giteaToken returns the Gitea or Forgejo token from GITEA_TOKEN
+func giteaToken() string {
+	return os.Getenv("GITEA_TOKEN")
+}
+
+// findPullRequest returns the number of the open pull request for branch.
+// Gitea can't filter pull requests by head, so they are listed page by page.
+func (g *giteaClient) findPullRequest(branch string) (int, error) {
+	const limit = 50
+	for page := 1; ; page++ {
+		var pulls []struct {
+			Number int `json:"number"`
+			Head   struct {
+				Ref string `json:"ref"`
+			} `json:"head"`
+		}
+		path := fmt.Sprintf("/repos/%s/%s/pulls?state=open&limit=%d&page=%d", g.owner, g.repo, limit, page)
+		if err := g.do("GET", path, nil, &pulls); err != nil {
+			return 0, fmt.Errorf("failed to find pull request for %s: %w", branch, err)
+		}
+		for _, p := range pulls {
+			if p.Head.Ref == branch {
+				return p.Number, nil
+			}
+		}
+		if len(pulls) < limit {
+			return 0, fmt.Errorf("no open pull request for branch %s in %s/%s; pass -pr", branch, g.owner, g.repo)
+		}
+	}
+}
+
+// commentableLines returns, for each file a pull request changes, the lines
+// its diff shows
+func (g *giteaClient) commentableLines(pr int) (map[string]map[int]bool, error) {
+	data, err := g.send("GET", fmt.Sprintf("/repos/%s/%s/pulls/%d.diff", g.owner, g.repo, pr), "text/plain", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the diff of pull request #%d: %w", pr, err)
+	}
+	lines := make(map[string]map[int]bool)
+	for _, f := range gitdiff.Split(string(data)) {
+		lines[f.Path] = gitdiff.HunkLines(f.Text)
+	}
+	return lines, nil
+}
+
+// giteaReviewComment is an inline comment of a Gitea pull request review,
+// on a line of the new version of a file
+type giteaReviewComment struct {
+	Path        string `json:"path"`
+	Body        string `json:"body"`
+	NewPosition int    `json:"new_position"`
+}
+
+// postInlineReview posts a review whose findings on lines of the pull
+// request's diff are inline comments, like the GitHub one. Gitea has no
+// suggested changes, so fixes are described in words.
+func (g *giteaClient) postInlineReview(pr int, headSHA, review string, findings []Finding) (string, error) {
+	commit, err := g.pullRequestHead(pr)
+	if err != nil {
+		return "", err
+	}
+	if headSHA != "" && commit != headSHA {
+		fmt.Fprintf(os.Stderr, "Warning: Pull request #%d is at %s but the review is of %s; inline comments may be on the wrong lines\n",
+			pr, shortSHA(commit), shortSHA(headSHA))
+	}
+	lines, err := g.commentableLines(pr)
+	if err != nil {
+		return "", err
+	}
+
+	var comments []giteaReviewComment
+	var rest int
+	for _, f := range findings {
+		if f.File == "" || f.Line == 0 || !lines[f.File][f.Line] {
+			rest++
+			continue
+		}
+		comments = append(comments, giteaReviewComment{Path: f.File, Body: inlineCommentBody(f, false), NewPosition: f.Line})
+	}
+	if rest > 0 {
+		fmt.Printf("   %s outside the pull request's diff are in the review body only\n", plural(rest, "finding"))
+	}
+
+	parts := splitComment(review, githubCommentLimit)
+	request := struct {
+		CommitID string               `json:"commit_id"`
+		Body     string               `json:"body"`
+		Event    string               `json:"event"`
+		Comments []giteaReviewComment `json:"comments"`
+	}{commit, parts[0], "COMMENT", comments}
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := g.do("POST", fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", g.owner, g.repo, pr), request, &created); err != nil {
+		return "", fmt.Errorf("failed to create review on #%d: %w", pr, err)
+	}
+	if _, err := g.postComments(pr, parts[1:]); err != nil {
+		return created.HTMLURL, err
+	}
+	return created.HTMLURL, nil
+}
+
+// giteaPullSource returns pull request pr's head ref on origin, which Gitea
+// publishes as GitHub does, and its branches from the API if GITEA_TOKEN is
+// set
+func giteaPullSource(pr int) (*pullSource, error) {
+	src := &pullSource{Remote: "origin", Ref: fmt.Sprintf("refs/pull/%d/head", pr)}
+	if giteaToken() != "" {
+		gt, err := newGiteaClient(getRepoIdentity())
+		if err != nil {
+			return nil, err
+		}
+		head, base, labels, err := gt.pullRequestInfo(pr)
+		if err != nil {
+			return nil, err
+		}
+		src.Branch, src.BaseBranch, src.Labels = head, base, labels
+	}
+	return src, nil
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestGiteaRepoClient tests deriving the API root from the remote
+func TestGiteaRepoClient(t *testing.T) {
+	t.Setenv("GITEA_URL", "")
+	gt, err := giteaRepoClient("codeberg.org:2222/org/app", "secret")
+	if err != nil {
+		t.Fatalf("giteaRepoClient() returned error: %v", err)
+	}
+	if gt.api != "https://codeberg.org/api/v1" || gt.owner != "org" || gt.repo != "app" {
+		t.Errorf("client = %+v", gt.githubClient)
+	}
+
+	t.Setenv("GITEA_URL", "https://git.example.com/forgejo/")
+	if gt, _ := giteaRepoClient("ssh.example.com/org/app", "secret"); gt.api != "https://git.example.com/forgejo/api/v1" {
+		t.Errorf("api = %q with GITEA_URL set", gt.api)
+	}
+	if _, err := giteaRepoClient("example.com/app", "secret"); err == nil {
+		t.Error("giteaRepoClient() of a malformed remote expected error")
+	}
+}
+
+// TestGiteaPostInlineReview tests finding a pull request across pages and
+// posting findings on lines of its diff as review comments
+func TestGiteaPostInlineReview(t *testing.T) {
+	var review struct {
+		CommitID string               `json:"commit_id"`
+		Body     string               `json:"body"`
+		Comments []giteaReviewComment `json:"comments"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v1/repos/org/app/pulls":
+			if r.URL.Query().Get("page") == "1" {
+				pulls := make([]string, 50)
+				for i := range pulls {
+					pulls[i] = fmt.Sprintf(`{"number": %d, "head": {"ref": "other-%d"}}`, 100+i, i)
+				}
+				fmt.Fprint(w, "["+strings.Join(pulls, ",")+"]")
+				return
+			}
+			fmt.Fprint(w, `[{"number": 7, "head": {"ref": "feature"}}]`)
+		case r.Method == "GET" && r.URL.Path == "/api/v1/repos/org/app/pulls/7":
+			fmt.Fprint(w, `{"head": {"sha": "abc123"}}`)
+		case r.Method == "GET" && r.URL.Path == "/api/v1/repos/org/app/pulls/7.diff":
+			fmt.Fprint(w, "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -10,2 +10,3 @@\n ctx := ctx\n+err := run()\n return nil\n")
+		case r.Method == "POST" && r.URL.Path == "/api/v1/repos/org/app/pulls/7/reviews":
+			json.NewDecoder(r.Body).Decode(&review)
+			fmt.Fprint(w, `{"html_url": "https://codeberg.org/org/app/pulls/7#review-1"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GITEA_URL", server.URL)
+	t.Setenv("GITEA_TOKEN", "secret")
+
+	gt, err := newGiteaClient("codeberg.org/org/app")
+	if err != nil {
+		t.Fatalf("newGiteaClient() returned error: %v", err)
+	}
+	pr, err := gt.findPullRequest("feature")
+	if err != nil || pr != 7 {
+		t.Fatalf("findPullRequest() = %d, %v", pr, err)
+	}
+	if _, err := gt.findPullRequest("missing"); err == nil {
+		t.Error("findPullRequest() of a branch without a pull request expected error")
+	}
+
+	findings := []Finding{
+		{File: "main.go", Line: 11, Severity: SeverityHigh, Title: "Error ignored", Replacement: "if err := run(); err != nil {"},
+		{File: "main.go", Line: 40, Severity: SeverityLow, Title: "Outside the diff"},
+	}
+	link, err := gt.postInlineReview(pr, "abc123", "Summary", findings)
+	if err != nil {
+		t.Fatalf("postInlineReview() returned error: %v", err)
+	}
+	if link != "https://codeberg.org/org/app/pulls/7#review-1" || review.CommitID != "abc123" || review.Body != "Summary" {
+		t.Errorf("link = %q, review = %+v", link, review)
+	}
+	if len(review.Comments) != 1 || review.Comments[0].NewPosition != 11 || strings.Contains(review.Comments[0].Body, "```suggestion") {
+		t.Errorf("comments = %+v", review.Comments)
+	}
+}
@@ -0,0 +1,523 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+// githubCommentLimit is the maximum length of a GitHub comment body
+const githubCommentLimit = 65536
+
+// commentPartOverhead is reserved in each part for the part header, the
+// continuation footer and closing/re-opening a split code fence
+const commentPartOverhead = 256
+
+// splitComment splits a review into comment bodies of at most limit bytes.
+// It breaks between paragraphs where possible, then between lines, and closes
+// and re-opens code fences that span a break so each part renders on its
+// own. Multi-part bodies are labelled "part i/n" so readers can follow them.
+func splitComment(body string, limit int) []string {
+	if len(body) <= limit {
+		return []string{body}
+	}
+
+	budget := limit - commentPartOverhead
+	if budget < 1 {
+		budget = 1
+	}
+
+	var parts []string
+	current := ""
+	for _, block := range splitBlocks(body) {
+		if current != "" && len(current)+2+len(block) <= budget {
+			current += "\n\n" + block
+			continue
+		}
+		if current != "" {
+			parts = append(parts, current)
+			current = ""
+		}
+		if len(block) <= budget {
+			current = block
+			continue
+		}
+		pieces := splitBlock(block, budget)
+		parts = append(parts, pieces[:len(pieces)-1]...)
+		current = pieces[len(pieces)-1]
+	}
+	if current != "" {
+		parts = append(parts, current)
+	}
+
+	for i := range parts {
+		parts[i] = fmt.Sprintf("**Review (part %d/%d)**\n\n", i+1, len(parts)) + parts[i]
+		if i < len(parts)-1 {
+			parts[i] += fmt.Sprintf("\n\n_Continued in part %d/%d._", i+2, len(parts))
+		}
+	}
+	return parts
+}
+
+// splitBlocks splits markdown into blank-line separated blocks, keeping each
+// code fence (which may itself contain blank lines) within a single block
+func splitBlocks(body string) []string {
+	var blocks []string
+	var current []string
+	inFence := false
+	for _, line := range strings.Split(body, "\n") {
+		if isFenceLine(line) {
+			inFence = !inFence
+		}
+		if !inFence && strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, strings.Join(current, "\n"))
+				current = nil
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, strings.Join(current, "\n"))
+	}
+	return blocks
+}
+
+// splitBlock breaks a single oversized block into pieces of at most budget
+// bytes at line boundaries, closing and re-opening any code fence it cuts
+// through. Lines longer than a piece are cut at a rune boundary.
+func splitBlock(block string, budget int) []string {
+	var pieces []string
+	var current []string
+	size := 0
+	fence := "" // opening line of the fence we are inside, if any
+
+	flush := func() {
+		piece := strings.Join(current, "\n")
+		if fence != "" {
+			piece += "\n```"
+		}
+		pieces = append(pieces, piece)
+		current, size = nil, 0
+		if fence != "" {
+			current, size = []string{fence}, len(fence)
+		}
+	}
+
+	// Keep room to close a fence at the end of every piece
+	room := budget - len("\n```")
+	for _, line := range strings.Split(block, "\n") {
+		for line != "" && size+1+len(line) > room {
+			if len(current) > 0 && (fence == "" || len(current) > 1) {
+				flush()
+				continue
+			}
+			cut := room - size - 1
+			if cut < 1 {
+				cut = 1
+			}
+			for cut > 1 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			current = append(current, line[:cut])
+			line = line[cut:]
+			flush()
+		}
+
+		current = append(current, line)
+		size += 1 + len(line)
+		if isFenceLine(line) {
+			if fence == "" {
+				fence = strings.TrimSpace(line)
+			} else {
+				fence = ""
+			}
+		}
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, strings.Join(current, "\n"))
+	}
+	return pieces
+}
+
+func isFenceLine(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "```")
+}
+
+// Review destinations for -post
+const postGitHub = "github"
+
+// githubClient calls the GitHub REST API for one repository
+type githubClient struct {
+	token   string
+	api     string // API root, e.g. https://api.github.com
+	owner   string
+	repo    string
+	service string // names the API in errors; GitHub if empty
+}
+
+// newGitHubClient returns a client for the repository at remote (as
+// normalized by normalizeRemoteURL), authenticated with GITHUB_TOKEN or
+// GH_TOKEN. GitHub Enterprise hosts use their /api/v3 root; GITHUB_API_URL,
+// which Actions sets, overrides it.
+func newGitHubClient(remote string) (*githubClient, error) {
+	token := githubToken()
+	if token == "" {
+		return nil, fmt.Errorf("GITHUB_TOKEN environment variable not set")
+	}
+	return githubRepoClient(remote, token)
+}
+
+// githubRepoClient returns a client for the repository at remote; without a
+// token it can only read public repositories
+func githubRepoClient(remote, token string) (*githubClient, error) {
+	parts := strings.Split(remote, "/")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("origin %q is not a GitHub repository URL", remote)
+	}
+	host := parts[0]
+	api := "https://" + host + "/api/v3"
+	if host == "github.com" {
+		api = "https://api.github.com"
+	}
+	if env := os.Getenv("GITHUB_API_URL"); env != "" {
+		api = strings.TrimSuffix(env, "/")
+	}
+	return &githubClient{token: token, api: api, owner: parts[1], repo: parts[2]}, nil
+}
+
+// githubToken returns the GitHub token from GITHUB_TOKEN or GH_TOKEN
+func githubToken() string {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token
+	}
+	return os.Getenv("GH_TOKEN")
+}
+
+// do sends an API request and decodes a JSON response into out, if set
+func (g *githubClient) do(method, path string, in, out any) error {
+	data, err := g.send(method, path, "application/vnd.github+json", in)
+	if err != nil {
+		return err
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("error unmarshaling response: %w", err)
+		}
+	}
+	return nil
+}
+
+// send sends an API request asking for the accept media type and returns
+// the response body
+func (g *githubClient) send(method, path, accept string, in any) ([]byte, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, g.api+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", accept)
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		service := g.service
+		if service == "" {
+			service = "GitHub"
+		}
+		return nil, fmt.Errorf("%s API error (status %d): %s", service, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// findPullRequest returns the number of the open pull request for branch
+func (g *githubClient) findPullRequest(branch string) (int, error) {
+	var pulls []struct {
+		Number int `json:"number"`
+	}
+	query := url.Values{"head": {g.owner + ":" + branch}, "state": {"open"}}
+	if err := g.do("GET", fmt.Sprintf("/repos/%s/%s/pulls?%s", g.owner, g.repo, query.Encode()), nil, &pulls); err != nil {
+		return 0, fmt.Errorf("failed to find pull request for %s: %w", branch, err)
+	}
+	if len(pulls) == 0 {
+		return 0, fmt.Errorf("no open pull request for branch %s in %s/%s; pass -pr", branch, g.owner, g.repo)
+	}
+	return pulls[0].Number, nil
+}
+
+// postComment posts body on a pull request, split into several comments if
+// it is over GitHub's size limit, and returns the URL of the first
+func (g *githubClient) postComment(pr int, body string) (string, error) {
+	return g.postComments(pr, splitComment(body, githubCommentLimit))
+}
+
+// postComments posts each body as a comment on a pull request and returns
+// the URL of the first
+func (g *githubClient) postComments(pr int, bodies []string) (string, error) {
+	var first string
+	for _, body := range bodies {
+		var comment struct {
+			HTMLURL string `json:"html_url"`
+		}
+		path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments", g.owner, g.repo, pr)
+		if err := g.do("POST", path, map[string]string{"body": body}, &comment); err != nil {
+			return first, fmt.Errorf("failed to post comment on #%d: %w", pr, err)
+		}
+		if first == "" {
+			first = comment.HTMLURL
+		}
+	}
+	return first, nil
+}
+
+// reviewComment is an inline comment of a pull request review, anchored to a
+// line of the new version of a file
+type reviewComment struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line,omitempty"`
+	StartSide string `json:"start_side,omitempty"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	Body      string `json:"body"`
+}
+
+// pullRequestHead returns the SHA of a pull request's head commit
+func (g *githubClient) pullRequestHead(pr int) (string, error) {
+	var pull struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	if err := g.do("GET", fmt.Sprintf("/repos/%s/%s/pulls/%d", g.owner, g.repo, pr), nil, &pull); err != nil {
+		return "", fmt.Errorf("failed to get pull request #%d: %w", pr, err)
+	}
+	return pull.Head.SHA, nil
+}
+
+// commentableLines returns, for each file a pull request changes, the lines
+// its diff shows, which are the lines inline comments can be placed on
+func (g *githubClient) commentableLines(pr int) (map[string]map[int]bool, error) {
+	lines := make(map[string]map[int]bool)
+	for page := 1; ; page++ {
+		var files []struct {
+			Filename string `json:"filename"`
+			Patch    string `json:"patch"`
+		}
+		path := fmt.Sprintf("/repos/%s/%s/pulls/%d/files?per_page=100&page=%d", g.owner, g.repo, pr, page)
+		if err := g.do("GET", path, nil, &files); err != nil {
+			return nil, fmt.Errorf("failed to list files of pull request #%d: %w", pr, err)
+		}
+		for _, f := range files {
+			lines[f.Filename] = gitdiff.HunkLines(f.Patch)
+		}
+		if len(files) < 100 {
+			return lines, nil
+		}
+	}
+}
+
+// inlineComments turns findings on lines the pull request's diff shows into
+// inline comments, returning the findings that can't be placed
+func inlineComments(findings []Finding, lines map[string]map[int]bool) ([]reviewComment, []Finding) {
+	var comments []reviewComment
+	var rest []Finding
+	for _, f := range findings {
+		if f.File == "" || f.Line == 0 || !lines[f.File][f.Line] {
+			rest = append(rest, f)
+			continue
+		}
+		comment := reviewComment{Path: f.File, Line: f.Line, Side: "RIGHT"}
+		suggest := f.Replacement != "" && suggestionFits(f, lines[f.File])
+		if suggest && f.EndLine > f.Line {
+			comment.StartLine, comment.StartSide = f.Line, "RIGHT"
+			comment.Line = f.EndLine
+		}
+		comment.Body = inlineCommentBody(f, suggest)
+		comments = append(comments, comment)
+	}
+	return comments, rest
+}
+
+// suggestionFits reports whether every line a finding's replacement covers
+// is shown in the diff, which GitHub requires of a suggested change; since
+// git merges adjacent hunks, the lines are then in the same hunk
+func suggestionFits(f Finding, shown map[int]bool) bool {
+	end := max(f.EndLine, f.Line)
+	for line := f.Line; line <= end; line++ {
+		if !shown[line] {
+			return false
+		}
+	}
+	return true
+}
+
+// suggestionBlock formats code as a suggested change the author can apply
+// from the pull request, with a fence longer than any inside the code
+func suggestionBlock(code string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + "suggestion\n" + strings.TrimSuffix(code, "\n") + "\n" + fence
+}
+
+// inlineCommentBody formats a finding as an inline comment, with its
+// replacement as a suggested change if suggest is set
+func inlineCommentBody(f Finding, suggest bool) string {
+	body := fmt.Sprintf("**[%s]** %s", strings.ToUpper(f.Severity.String()), f.Title)
+	if f.CriticalPath {
+		body += " — " + tr("critical path")
+	}
+	if f.Message != "" {
+		body += "\n\n" + f.Message
+	}
+	if f.Suggestion != "" {
+		body += "\n\n" + tr("Suggestion: %s", f.Suggestion)
+	}
+	if f.Commit != "" {
+		body += "\n\n" + tr("Introduced in: %s", f.IntroducedIn())
+	}
+	if suggest {
+		body += "\n\n" + suggestionBlock(f.Replacement)
+	} else if f.Patch != "" {
+		body += "\n\n" + tr("Suggested patch") + ":\n\n```diff\n" + strings.Trim(f.Patch, "\n") + "\n```"
+	}
+	if f.Ungrounded {
+		body += "\n\n⚠️ " + tr(ungroundedNotice)
+	}
+	return body
+}
+
+// createReview posts a pull request review with inline comments on commit
+// and returns its URL
+func (g *githubClient) createReview(pr int, commit, body string, comments []reviewComment) (string, error) {
+	request := struct {
+		CommitID string          `json:"commit_id"`
+		Body     string          `json:"body"`
+		Event    string          `json:"event"`
+		Comments []reviewComment `json:"comments"`
+	}{commit, body, "COMMENT", comments}
+	var review struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := g.do("POST", fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", g.owner, g.repo, pr), request, &review); err != nil {
+		return "", fmt.Errorf("failed to create review on #%d: %w", pr, err)
+	}
+	return review.HTMLURL, nil
+}
+
+// postInlineReview posts a review whose findings on lines of the pull
+// request's diff are inline comments; the review text is its body, with any
+// overflow posted as comments
+func (g *githubClient) postInlineReview(pr int, headSHA, review string, findings []Finding) (string, error) {
+	commit, err := g.pullRequestHead(pr)
+	if err != nil {
+		return "", err
+	}
+	if headSHA != "" && commit != headSHA {
+		fmt.Fprintf(os.Stderr, "Warning: Pull request #%d is at %s but the review is of %s; inline comments may be on the wrong lines\n",
+			pr, shortSHA(commit), shortSHA(headSHA))
+	}
+	lines, err := g.commentableLines(pr)
+	if err != nil {
+		return "", err
+	}
+	comments, rest := inlineComments(findings, lines)
+	if len(rest) > 0 {
+		fmt.Printf("   %s outside the pull request's diff are in the review body only\n", plural(len(rest), "finding"))
+	}
+
+	parts := splitComment(review, githubCommentLimit)
+	link, err := g.createReview(pr, commit, parts[0], comments)
+	if err != nil {
+		return "", err
+	}
+	if _, err := g.postComments(pr, parts[1:]); err != nil {
+		return link, err
+	}
+	return link, nil
+}
+
+// pullRequestPoster posts reviews to the pull requests of a GitHub-like
+// code host
+type pullRequestPoster interface {
+	findPullRequest(branch string) (int, error)
+	postComment(pr int, body string) (string, error)
+	postInlineReview(pr int, headSHA, review string, findings []Finding) (string, error)
+}
+
+// postReview posts a review to dest's pull request for branch, or to pr if
+// it is set (a change number on Gerrit). On GitHub and Gitea it is a
+// comment, or with inline set a pull request review with the findings as
+// inline comments.
+func postReview(dest, remote, branch string, pr int, review string, findings []Finding, headSHA string, inline bool) error {
+	var gh *githubClient
+	var poster pullRequestPoster
+	var err error
+	switch dest {
+	case postBitbucket:
+		return postBitbucketReview(remote, branch, pr, review, findings, headSHA)
+	case postGerrit:
+		return postGerritReview(pr, review, findings, headSHA)
+	case postGitea:
+		gt, err := newGiteaClient(remote)
+		if err != nil {
+			return err
+		}
+		gh, poster = gt.githubClient, gt
+	default:
+		if gh, err = newGitHubClient(remote); err != nil {
+			return err
+		}
+		poster = gh
+	}
+	if pr == 0 {
+		if pr, err = poster.findPullRequest(branch); err != nil {
+			return err
+		}
+	}
+
+	var link string
+	if inline {
+		link, err = poster.postInlineReview(pr, headSHA, review, findings)
+	} else {
+		link, err = poster.postComment(pr, review)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("💬 Review posted to %s/%s#%d: %s\n\n", gh.owner, gh.repo, pr, link)
+	return nil
+}
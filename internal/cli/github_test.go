@@ -0,0 +1,275 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestSplitComment_Short tests that short reviews are posted as a single comment
+func TestSplitComment_Short(t *testing.T) {
+	body := "# Review\n\nAll good."
+	parts := splitComment(body, githubCommentLimit)
+	if len(parts) != 1 || parts[0] != body {
+		t.Errorf("splitComment() = %q, want the body unchanged", parts)
+	}
+}
+
+// TestSplitComment_Paragraphs tests splitting at paragraph boundaries within the limit
+func TestSplitComment_Paragraphs(t *testing.T) {
+	paragraph := strings.Repeat("word ", 80) // 400 bytes
+	var paragraphs []string
+	for i := 0; i < 10; i++ {
+		paragraphs = append(paragraphs, paragraph)
+	}
+	body := strings.Join(paragraphs, "\n\n")
+	limit := 1200
+
+	parts := splitComment(body, limit)
+	if len(parts) < 2 {
+		t.Fatalf("splitComment() returned %d parts, want several", len(parts))
+	}
+	for i, part := range parts {
+		if len(part) > limit {
+			t.Errorf("part %d is %d bytes, over the %d limit", i+1, len(part), limit)
+		}
+		if !strings.HasPrefix(part, "**Review (part ") {
+			t.Errorf("part %d is missing its header", i+1)
+		}
+	}
+	if !strings.Contains(parts[0], "_Continued in part 2/") {
+		t.Errorf("first part does not link to the next:\n%s", parts[0])
+	}
+
+	// No paragraph is cut in half
+	joined := strings.Join(parts, "\n")
+	if strings.Count(joined, paragraph) != 10 {
+		t.Errorf("paragraphs were split across parts")
+	}
+}
+
+// TestSplitComment_CodeFence tests that code fences cut by a split are closed and re-opened
+func TestSplitComment_CodeFence(t *testing.T) {
+	var code []string
+	for i := 0; i < 200; i++ {
+		code = append(code, "    fmt.Println(\"line of generated code\")")
+	}
+	body := "Intro\n\n```go\n" + strings.Join(code, "\n") + "\n```\n\nOutro"
+	limit := 2000
+
+	parts := splitComment(body, limit)
+	if len(parts) < 2 {
+		t.Fatalf("splitComment() returned %d parts, want several", len(parts))
+	}
+	for i, part := range parts {
+		if len(part) > limit {
+			t.Errorf("part %d is %d bytes, over the %d limit", i+1, len(part), limit)
+		}
+		fences := 0
+		for _, line := range strings.Split(part, "\n") {
+			if isFenceLine(line) {
+				fences++
+			}
+		}
+		if fences%2 != 0 {
+			t.Errorf("part %d has an unbalanced code fence:\n%s", i+1, part)
+		}
+	}
+	if !strings.Contains(parts[1], "```go") {
+		t.Errorf("second part did not re-open the go fence:\n%s", parts[1])
+	}
+}
+
+// TestSplitComment_LongLine tests that a single huge line is cut without breaking UTF-8
+func TestSplitComment_LongLine(t *testing.T) {
+	body := strings.Repeat("é", 3000) // 6000 bytes, one line
+	limit := 1000
+
+	parts := splitComment(body, limit)
+	var rebuilt strings.Builder
+	for i, part := range parts {
+		if len(part) > limit {
+			t.Errorf("part %d is %d bytes, over the %d limit", i+1, len(part), limit)
+		}
+		if !strings.ContainsRune(part, 'é') {
+			t.Errorf("part %d has no content", i+1)
+		}
+		lines := strings.Split(part, "\n")
+		rebuilt.WriteString(lines[2])
+	}
+	if rebuilt.String() != body {
+		t.Errorf("rebuilt body does not match the original")
+	}
+}
+
+// TestNewGitHubClient tests deriving the API root and repository from origin
+func TestNewGitHubClient(t *testing.T) {
+	t.Setenv("GITHUB_API_URL", "")
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	if _, err := newGitHubClient("github.com/org/repo"); err == nil {
+		t.Error("newGitHubClient() without a token expected error")
+	}
+
+	t.Setenv("GH_TOKEN", "secret")
+	tests := []struct {
+		remote, api string
+	}{
+		{"github.com/org/repo", "https://api.github.com"},
+		{"git.example.com/org/repo", "https://git.example.com/api/v3"},
+	}
+	for _, tt := range tests {
+		gh, err := newGitHubClient(tt.remote)
+		if err != nil {
+			t.Fatalf("newGitHubClient(%q) returned error: %v", tt.remote, err)
+		}
+		if gh.api != tt.api || gh.owner != "org" || gh.repo != "repo" || gh.token != "secret" {
+			t.Errorf("newGitHubClient(%q) = %+v", tt.remote, gh)
+		}
+	}
+	if _, err := newGitHubClient("/home/me/repo"); err == nil {
+		t.Error("newGitHubClient() of a local path expected error")
+	}
+}
+
+// TestPostComment tests finding a branch's pull request and posting a long
+// review as several comments
+func TestPostComment(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/repos/org/repo/pulls":
+			if r.URL.Query().Get("head") != "org:feature/login" {
+				fmt.Fprint(w, `[]`)
+				return
+			}
+			fmt.Fprint(w, `[{"number": 42}]`)
+		case r.Method == "POST" && r.URL.Path == "/repos/org/repo/issues/42/comments":
+			var c struct{ Body string }
+			json.NewDecoder(r.Body).Decode(&c)
+			bodies = append(bodies, c.Body)
+			fmt.Fprintf(w, `{"html_url": "https://github.com/org/repo/pull/42#issuecomment-%d"}`, len(bodies))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	gh := &githubClient{token: "secret", api: server.URL, owner: "org", repo: "repo"}
+	pr, err := gh.findPullRequest("feature/login")
+	if err != nil || pr != 42 {
+		t.Fatalf("findPullRequest() = %d, %v", pr, err)
+	}
+
+	review := strings.Repeat(strings.Repeat("x", 1000)+"\n\n", 100)
+	link, err := gh.postComment(pr, review)
+	if err != nil {
+		t.Fatalf("postComment() returned error: %v", err)
+	}
+	if link != "https://github.com/org/repo/pull/42#issuecomment-1" {
+		t.Errorf("link = %q", link)
+	}
+	if len(bodies) != 2 || !strings.HasPrefix(bodies[1], "**Review (part 2/2)**") {
+		t.Errorf("posted %d comments", len(bodies))
+	}
+
+	if _, err := gh.findPullRequest("missing"); err == nil {
+		t.Error("findPullRequest() of a branch without a pull request expected error")
+	}
+}
+
+// TestPostInlineReview tests anchoring findings to lines of the pull
+// request's diff and leaving the rest in the review body
+func TestPostInlineReview(t *testing.T) {
+	var review struct {
+		CommitID string          `json:"commit_id"`
+		Body     string          `json:"body"`
+		Event    string          `json:"event"`
+		Comments []reviewComment `json:"comments"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/repos/org/repo/pulls/7":
+			fmt.Fprint(w, `{"head": {"sha": "abc123"}}`)
+		case r.Method == "GET" && r.URL.Path == "/repos/org/repo/pulls/7/files":
+			fmt.Fprint(w, `[{"filename": "auth/login.go", "patch": "@@ -10,2 +10,3 @@\n ctx\n+added\n ctx"}]`)
+		case r.Method == "POST" && r.URL.Path == "/repos/org/repo/pulls/7/reviews":
+			json.NewDecoder(r.Body).Decode(&review)
+			fmt.Fprint(w, `{"html_url": "https://github.com/org/repo/pull/7#pullrequestreview-1"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	findings := []Finding{
+		{File: "auth/login.go", Line: 11, Severity: SeverityHigh, Title: "Unchecked error", Message: "m"},
+		{File: "auth/login.go", Line: 40, Severity: SeverityLow, Title: "Outside the diff"},
+		{File: "other.go", Line: 1, Severity: SeverityLow, Title: "File not in the PR"},
+		{Severity: SeverityInfo, Title: "General"},
+	}
+	gh := &githubClient{token: "secret", api: server.URL, owner: "org", repo: "repo"}
+	link, err := gh.postInlineReview(7, "abc123", "Review text", findings)
+	if err != nil {
+		t.Fatalf("postInlineReview() returned error: %v", err)
+	}
+	if link != "https://github.com/org/repo/pull/7#pullrequestreview-1" {
+		t.Errorf("link = %q", link)
+	}
+	if review.CommitID != "abc123" || review.Body != "Review text" || review.Event != "COMMENT" {
+		t.Errorf("review = %+v", review)
+	}
+	if len(review.Comments) != 1 {
+		t.Fatalf("comments = %+v, want only the finding on a diff line", review.Comments)
+	}
+	c := review.Comments[0]
+	if c.Path != "auth/login.go" || c.Line != 11 || c.Side != "RIGHT" || !strings.HasPrefix(c.Body, "**[HIGH]** Unchecked error") {
+		t.Errorf("comment = %+v", c)
+	}
+}
+
+// TestInlineComments_Suggestions tests emitting replacements as suggested
+// changes only when every line they cover is in the diff
+func TestInlineComments_Suggestions(t *testing.T) {
+	shown := map[string]map[int]bool{"a.go": {10: true, 11: true, 12: true, 20: true}}
+	findings := []Finding{
+		{File: "a.go", Line: 10, EndLine: 12, Title: "Multi-line", Replacement: "x := 1\ny := 2\n"},
+		{File: "a.go", Line: 20, Title: "Single line", Replacement: "return nil"},
+		{File: "a.go", Line: 12, EndLine: 14, Title: "Runs past the hunk", Replacement: "z()"},
+		{File: "a.go", Line: 11, Title: "No replacement"},
+	}
+
+	comments, rest := inlineComments(findings, shown)
+	if len(comments) != 4 || len(rest) != 0 {
+		t.Fatalf("got %d comments and %d unplaced findings", len(comments), len(rest))
+	}
+
+	multi := comments[0]
+	if multi.StartLine != 10 || multi.StartSide != "RIGHT" || multi.Line != 12 ||
+		!strings.HasSuffix(multi.Body, "```suggestion\nx := 1\ny := 2\n```") {
+		t.Errorf("multi-line comment = %+v", multi)
+	}
+	if single := comments[1]; single.StartLine != 0 || single.Line != 20 || !strings.Contains(single.Body, "```suggestion\nreturn nil\n```") {
+		t.Errorf("single-line comment = %+v", single)
+	}
+	if past := comments[2]; past.StartLine != 0 || past.Line != 12 || strings.Contains(past.Body, "suggestion") {
+		t.Errorf("comment past the hunk = %+v, want a plain comment on its first line", past)
+	}
+	if plain := comments[3]; strings.Contains(plain.Body, "```") {
+		t.Errorf("comment without replacement = %+v", plain)
+	}
+}
+
+// TestSuggestionBlock tests fencing replacements that contain fences
+func TestSuggestionBlock(t *testing.T) {
+	got := suggestionBlock("doc := `\n```go\n```\n`")
+	if !strings.HasPrefix(got, "````suggestion\n") || !strings.HasSuffix(got, "\n````") {
+		t.Errorf("suggestionBlock() = %q", got)
+	}
+}
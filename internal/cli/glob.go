@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether the slash-separated, repository-relative path
+// name matches pattern. Patterns follow .gitignore conventions: "*" and "?"
+// match within one path segment, "**" matches any number of directories, a
+// trailing "/" matches everything under a directory, and a pattern with no
+// other "/" matches at any depth.
+func matchGlob(pattern, name string) bool {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "/")
+	if pattern == "" {
+		return false
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	if !strings.Contains(strings.TrimSuffix(pattern, "/**"), "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try every split point, including matching nothing
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchAnyGlob reports whether name matches any of the patterns
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitGlobs splits a comma-separated list of glob patterns, dropping
+// empty ones
+func splitGlobs(list string) []string {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
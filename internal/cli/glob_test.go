@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestMatchGlob tests gitignore-style path patterns
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"auth/", "auth/login.go", true},
+		{"auth/", "internal/auth/token/jwt.go", true},
+		{"auth/", "authz/policy.go", false},
+		{"/payments/", "payments/charge.go", true},
+		{"payments/**", "services/payments/charge.go", true},
+		{"db/migrations/*.sql", "db/migrations/001_init.sql", true},
+		{"db/migrations/*.sql", "db/migrations/old/001_init.sql", false},
+		{"db/**/*.sql", "db/migrations/old/001_init.sql", true},
+		{"db/**/*.sql", "db/001_init.sql", true},
+		{"*.pem", "certs/server.pem", true},
+		{"go.sum", "go.sum", true},
+		{"go.sum", "go.sum.bak", false},
+		{"", "anything", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestSplitGlobs tests parsing a comma-separated list of patterns
+func TestSplitGlobs(t *testing.T) {
+	if got := splitGlobs(" vendor/**, ,**/*.min.js"); !reflect.DeepEqual(got, []string{"vendor/**", "**/*.min.js"}) {
+		t.Errorf("splitGlobs() = %q", got)
+	}
+	if splitGlobs("") != nil {
+		t.Error("splitGlobs(\"\") isn't empty")
+	}
+}
@@ -0,0 +1,338 @@
+package cli
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// riskWeights are how much a finding of each severity adds to the risk
+// score of a handoff
+var riskWeights = map[Severity]int{
+	SeverityCritical: 40,
+	SeverityHigh:     20,
+	SeverityMedium:   8,
+	SeverityLow:      2,
+}
+
+// handoffRisk is a rough 0-100 score of how risky a change is to merge,
+// from its findings and size, to help a reviewer decide how closely to look
+type handoffRisk struct {
+	Score   int      `json:"score"`
+	Level   string   `json:"level"`
+	Reasons []string `json:"reasons"`
+}
+
+// linkedIssue is an issue the commits under review say they fix or refer to
+type linkedIssue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title,omitempty"`
+	State   string `json:"state,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// handoff is everything a human reviewer taking over a change needs: the
+// review, the diff it was based on, and what to check
+type handoff struct {
+	Record    *reviewRecord `json:"review"`
+	Diff      string        `json:"-"`
+	Risk      handoffRisk   `json:"risk"`
+	Issues    []linkedIssue `json:"issues"`
+	Checklist []string      `json:"checklist"`
+}
+
+// issueReference matches the closing keywords GitHub recognizes, and Refs,
+// followed by an issue number
+var issueReference = regexp.MustCompile(`(?i)\b(?:fix(?:e[sd])?|close[sd]?|resolve[sd]?|refs?)\s*:?\s+#(\d+)\b`)
+
+// maxIssueSummary bounds the excerpt of an issue's description
+const maxIssueSummary = 500
+
+// riskScore scores a reviewed change: each finding adds its severity's
+// weight, and every 50 changed lines add a point up to 20, capped at 100
+func riskScore(findings []Finding, linesChanged int) handoffRisk {
+	score := 0
+	counts := map[Severity]int{}
+	for _, f := range findings {
+		score += riskWeights[f.Severity]
+		counts[f.Severity]++
+	}
+	var reasons []string
+	for s := SeverityCritical; s >= SeverityLow; s-- {
+		if counts[s] > 0 {
+			reasons = append(reasons, plural(counts[s], s.String()+" finding"))
+		}
+	}
+	if size := min(linesChanged/50, 20); size > 0 {
+		score += size
+		reasons = append(reasons, plural(linesChanged, "changed line"))
+	}
+	risk := handoffRisk{Score: min(score, 100), Level: "low", Reasons: reasons}
+	switch {
+	case risk.Score >= 60:
+		risk.Level = "high"
+	case risk.Score >= 25:
+		risk.Level = "medium"
+	}
+	return risk
+}
+
+// issueNumbers returns the issues commit messages fix or refer to, in the
+// order they are first mentioned
+func issueNumbers(commitMessages string) []int {
+	var numbers []int
+	seen := map[int]bool{}
+	for _, m := range issueReference.FindAllStringSubmatch(commitMessages, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err == nil && !seen[n] {
+			seen[n] = true
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// fetchIssue looks up an issue's title, state and the start of its
+// description
+func (g *githubClient) fetchIssue(number int) (linkedIssue, error) {
+	var issue struct {
+		Title   string `json:"title"`
+		State   string `json:"state"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := g.do("GET", fmt.Sprintf("/repos/%s/%s/issues/%d", g.owner, g.repo, number), nil, &issue); err != nil {
+		return linkedIssue{Number: number}, err
+	}
+	summary, _, _ := strings.Cut(strings.TrimSpace(strings.ReplaceAll(issue.Body, "\r\n", "\n")), "\n\n")
+	if len(summary) > maxIssueSummary {
+		summary = strings.ToValidUTF8(summary[:maxIssueSummary], "") + "..."
+	}
+	return linkedIssue{Number: number, Title: issue.Title, State: issue.State, URL: issue.HTMLURL, Summary: summary}, nil
+}
+
+// linkedIssues returns the issues commit messages refer to, with their
+// details from GitHub where they can be fetched
+func linkedIssues(repo, commitMessages string) []linkedIssue {
+	numbers := issueNumbers(commitMessages)
+	if len(numbers) == 0 {
+		return nil
+	}
+	client, err := githubRepoClient(repo, githubToken())
+	issues := make([]linkedIssue, len(numbers))
+	for i, n := range numbers {
+		issues[i] = linkedIssue{Number: n}
+		if err != nil {
+			continue
+		}
+		issue, fetchErr := client.fetchIssue(n)
+		if fetchErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not fetch issue #%d: %v\n", n, fetchErr)
+			continue
+		}
+		issues[i] = issue
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not look up linked issues: %v\n", err)
+	}
+	return issues
+}
+
+// reviewChecklist returns the items of a saved review's reviewer
+// checklist, whose heading may be in any of the built-in languages
+func reviewChecklist(review string) []string {
+	headings := map[string]bool{"Reviewer Checklist": true}
+	for _, name := range locales() {
+		if c, err := loadCatalog(name); err == nil && c != nil && c.Messages["Reviewer Checklist"] != "" {
+			headings[c.Messages["Reviewer Checklist"]] = true
+		}
+	}
+
+	var items []string
+	inChecklist := false
+	for _, line := range strings.Split(review, "\n") {
+		if heading, ok := strings.CutPrefix(line, "## "); ok {
+			inChecklist = headings[strings.TrimSpace(heading)]
+			continue
+		}
+		if item, ok := strings.CutPrefix(strings.TrimSpace(line), "- [ ] "); ok && inChecklist {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// handoffPage is the self-contained HTML page of a handoff
+var handoffPage = template.Must(template.New("handoff").Funcs(template.FuncMap{
+	"short": shortSHA,
+	"diffClass": func(line string) string {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "diff --git"):
+			return "file"
+		case strings.HasPrefix(line, "+"):
+			return "add"
+		case strings.HasPrefix(line, "-"):
+			return "del"
+		case strings.HasPrefix(line, "@@"):
+			return "hunk"
+		}
+		return ""
+	},
+	"lines": func(s string) []string { return strings.Split(strings.TrimRight(s, "\n"), "\n") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Review handoff: {{.Record.Branch}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 1100px; margin: 2em auto; padding: 0 1em; color: #1f2328; }
+.notice { background: #fff8c5; border: 1px solid #d4a72c; padding: .75em 1em; border-radius: 6px; }
+.risk-high { color: #cf222e; } .risk-medium { color: #9a6700; } .risk-low { color: #1a7f37; }
+table { border-collapse: collapse; width: 100%; } td, th { border: 1px solid #d0d7de; padding: .3em .6em; text-align: left; vertical-align: top; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; white-space: pre-wrap; }
+pre.diff span { display: block; min-height: 1.2em; } .add { background: #dafbe1; } .del { background: #ffebe9; } .hunk { color: #8250df; } .file { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Review handoff: {{.Record.Branch}}</h1>
+<p class="notice">This review was written by {{.Record.Model}} and is advisory. The merge decision, and verifying the checklist below, rest with the human reviewer.</p>
+<table>
+<tr><th>Repository</th><td>{{.Record.Repo}}</td></tr>
+<tr><th>Changes</th><td>{{short .Record.HeadSHA}} against {{.Record.BaseRef}} ({{short .Record.BaseSHA}}), {{.Record.LinesChanged}} lines</td></tr>
+<tr><th>Reviewed</th><td>{{.Record.CreatedAt.Format "2006-01-02 15:04 MST"}} with {{.Record.Model}}</td></tr>
+<tr><th>Risk</th><td class="risk-{{.Risk.Level}}"><strong>{{.Risk.Score}}/100 ({{.Risk.Level}})</strong>{{range .Risk.Reasons}}<br>{{.}}{{end}}</td></tr>
+</table>
+{{if .Issues}}<h2>Linked Issues</h2>
+<ul>{{range .Issues}}
+<li>{{if .URL}}<a href="{{.URL}}">#{{.Number}}</a>{{else}}#{{.Number}}{{end}}{{if .Title}} {{.Title}}{{end}}{{if .State}} ({{.State}}){{end}}{{if .Summary}}<br>{{.Summary}}{{end}}</li>{{end}}
+</ul>{{end}}
+<h2>Reviewer Checklist</h2>
+{{if .Checklist}}<ul>{{range .Checklist}}
+<li><label><input type="checkbox"> {{.}}</label></li>{{end}}
+</ul>{{else}}<p>The review has no checklist.</p>{{end}}
+{{if .Record.Findings}}<h2>Findings</h2>
+<table>
+<tr><th>Severity</th><th>Location</th><th>Finding</th></tr>{{range .Record.Findings}}
+<tr><td>{{.Severity}}</td><td>{{.File}}{{if .Line}}:{{.Line}}{{end}}</td><td><strong>{{.Title}}</strong><br>{{.Message}}</td></tr>{{end}}
+</table>{{end}}
+<h2>Review</h2>
+<pre>{{.Record.Review}}</pre>
+<h2>Diff</h2>
+<pre class="diff">{{range lines .Diff}}<span class="{{diffClass .}}">{{.}}</span>{{end}}</pre>
+</body>
+</html>
+`))
+
+// writeHandoffHTML writes the handoff as a single HTML page
+func writeHandoffHTML(w io.Writer, h *handoff) error {
+	return handoffPage.Execute(w, h)
+}
+
+// writeHandoffZip writes the handoff as a zip of the HTML page, the review,
+// the diff, the checklist and a JSON manifest with the findings, risk
+// score and linked issues
+func writeHandoffZip(w io.Writer, h *handoff) error {
+	var page bytes.Buffer
+	if err := writeHandoffHTML(&page, h); err != nil {
+		return err
+	}
+	manifest, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"index.html", page.Bytes()},
+		{"review.md", []byte(h.Record.Review + "\n")},
+		{"changes.diff", []byte(h.Diff)},
+		{"checklist.md", []byte(formatChecklist(h.Checklist))},
+		{"handoff.json", append(manifest, '\n')},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: h.Record.CreatedAt})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// runHandoff implements `pr-review handoff`, packaging the latest review of
+// the current branch for a human reviewer taking it over
+func runHandoff(args []string) {
+	fs := flag.NewFlagSet("handoff", flag.ExitOnError)
+	addPathFlags(fs)
+	base := fs.String("base", "", "Base branch/commit the review compared against (default: main or master)")
+	head := fs.String("head", "HEAD", "Commit that was reviewed")
+	outputFile := fs.String("output", "HANDOFF.zip", "Package to write: a .zip with the HTML page, review, diff, checklist and a JSON manifest, or a .html page alone (will create numbered backups if exists)")
+	noIssues := fs.Bool("no-issues", false, "Don't look up the issues the commit messages refer to on GitHub")
+	parseFlags(fs, fs.Name(), args)
+
+	baseRef := *base
+	if baseRef == "" {
+		baseRef = getDefaultBranch()
+	}
+	if err := checkRefs(baseRef, *head); err != nil {
+		fail(exitGit, "Error: %v", err)
+	}
+	repo, baseSHA, headSHA := getRepoIdentity(), resolveRef(baseRef), resolveRef(*head)
+
+	history, err := openHistoryFor(repo)
+	if err != nil {
+		fail(exitUsage, "Error opening review history: %v", err)
+	}
+	defer history.Close()
+	record, err := history.FindLatest(repo, baseSHA, headSHA)
+	if err != nil {
+		fail(exitUsage, "Error reading review history: %v", err)
+	}
+	if record == nil {
+		fail(exitUsage, "Error: %s hasn't been reviewed against %s; run pr-review first", shortSHA(headSHA), baseRef)
+	}
+
+	changes, err := collectChanges(baseRef, *head)
+	if err != nil {
+		fail(exitGit, "Error getting diff: %v", err)
+	}
+	h := &handoff{
+		Record:    record,
+		Diff:      changes.Diff,
+		Risk:      riskScore(record.Findings, record.LinesChanged),
+		Checklist: reviewChecklist(record.Review),
+	}
+	if !*noIssues {
+		h.Issues = linkedIssues(repo, changes.CommitMessages)
+	}
+
+	var out bytes.Buffer
+	if strings.HasSuffix(strings.ToLower(*outputFile), ".html") {
+		err = writeHandoffHTML(&out, h)
+	} else {
+		err = writeHandoffZip(&out, h)
+	}
+	if err != nil {
+		fail(exitUsage, "Error building handoff: %v", err)
+	}
+	if err := writeReviewToFile(*outputFile, out.String()); err != nil {
+		fail(exitUsage, "Error writing handoff: %v", err)
+	}
+	fmt.Printf("📦 Handoff of %s (reviewed %s ago, risk %d/100 %s) written to: %s\n",
+		shortSHA(headSHA), formatAge(time.Since(record.CreatedAt)), h.Risk.Score, h.Risk.Level, *outputFile)
+}
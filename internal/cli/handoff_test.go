@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRiskScore tests scoring a change from its findings and size
+func TestRiskScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		findings []Finding
+		lines    int
+		score    int
+		level    string
+	}{
+		{"clean and small", nil, 30, 0, "low"},
+		{"one medium", []Finding{{Severity: SeverityMedium}}, 120, 10, "low"},
+		{"one high", []Finding{{Severity: SeverityHigh}, {Severity: SeverityInfo}}, 300, 26, "medium"},
+		{"critical", []Finding{{Severity: SeverityCritical}, {Severity: SeverityHigh}}, 0, 60, "high"},
+		{"capped", []Finding{{Severity: SeverityCritical}, {Severity: SeverityCritical}, {Severity: SeverityCritical}}, 5000, 100, "high"},
+	}
+	for _, tt := range tests {
+		risk := riskScore(tt.findings, tt.lines)
+		if risk.Score != tt.score || risk.Level != tt.level {
+			t.Errorf("%s: riskScore() = %d (%s), want %d (%s)", tt.name, risk.Score, risk.Level, tt.score, tt.level)
+		}
+	}
+	if risk := riskScore([]Finding{{Severity: SeverityHigh}, {Severity: SeverityHigh}, {Severity: SeverityLow}}, 100); strings.Join(risk.Reasons, "; ") != "2 high findings; 1 low finding; 100 changed lines" {
+		t.Errorf("reasons = %q", risk.Reasons)
+	}
+}
+
+// TestLinkedIssues tests finding the issues commit messages refer to and
+// fetching their summaries
+func TestLinkedIssues(t *testing.T) {
+	messages := "Retry uploads\n\nFixes #12, refs #7.\nCloses: #12\nSee issue #99 and PR #3 for background."
+	if got := fmt.Sprint(issueNumbers(messages)); got != "[12 7]" {
+		t.Errorf("issueNumbers() = %s, want [12 7]", got)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/app/issues/12" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"title": "Uploads fail on flaky networks", "state": "open", "html_url": "https://github.com/acme/app/issues/12", "body": "Large uploads give up after one timeout.\r\n\r\nSteps to reproduce..."}`)
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_API_URL", server.URL)
+
+	issues := linkedIssues("github.com/acme/app", messages)
+	if len(issues) != 2 {
+		t.Fatalf("linkedIssues() = %+v", issues)
+	}
+	if issues[0].Title != "Uploads fail on flaky networks" || issues[0].Summary != "Large uploads give up after one timeout." {
+		t.Errorf("issue #12 = %+v", issues[0])
+	}
+	// An issue that can't be fetched is still listed
+	if issues[1].Number != 7 || issues[1].Title != "" {
+		t.Errorf("issue #7 = %+v", issues[1])
+	}
+}
+
+// TestWriteHandoff tests the contents of the handoff package and page
+func TestWriteHandoff(t *testing.T) {
+	review := "The retry loop looks right.\n\n## Reviewer Checklist\n\n- [ ] Check the upload timeout in staging\n- [ ] Confirm <b>retries</b> are logged\n\n## Benchmark Delta\n\n- [ ] not a checklist item"
+	record := &reviewRecord{
+		CreatedAt: time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC), Repo: "github.com/acme/app", Branch: "retry-uploads",
+		BaseRef: "main", BaseSHA: "1111111111", HeadSHA: "2222222222", Model: "claude-sonnet-4-5", Review: review, LinesChanged: 40,
+		Findings: []Finding{{File: "upload.go", Line: 12, Severity: SeverityHigh, Title: "Retries POST", Message: "Not idempotent."}},
+	}
+	h := &handoff{
+		Record:    record,
+		Diff:      "diff --git a/upload.go b/upload.go\n--- a/upload.go\n+++ b/upload.go\n@@ -1 +1 @@\n-upload()\n+retry(upload)\n",
+		Risk:      riskScore(record.Findings, record.LinesChanged),
+		Issues:    []linkedIssue{{Number: 12, Title: "Uploads fail", URL: "https://github.com/acme/app/issues/12"}},
+		Checklist: reviewChecklist(review),
+	}
+	if len(h.Checklist) != 2 || h.Checklist[1] != "Confirm <b>retries</b> are logged" {
+		t.Fatalf("reviewChecklist() = %q", h.Checklist)
+	}
+	if got := reviewChecklist("## Checkliste für Reviewer\n\n- [ ] Staging prüfen\n"); len(got) != 1 {
+		t.Errorf("reviewChecklist() of a German review = %q", got)
+	}
+
+	var buf bytes.Buffer
+	if err := writeHandoffZip(&buf, h); err != nil {
+		t.Fatalf("writeHandoffZip() returned error: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("package isn't a zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	if files["changes.diff"] != h.Diff || !strings.HasPrefix(files["review.md"], "The retry loop") {
+		t.Errorf("package files = %v", files)
+	}
+	if files["checklist.md"] != "- [ ] Check the upload timeout in staging\n- [ ] Confirm <b>retries</b> are logged\n" {
+		t.Errorf("checklist.md = %q", files["checklist.md"])
+	}
+	if !strings.Contains(files["handoff.json"], `"level": "low"`) || !strings.Contains(files["handoff.json"], `"number": 12`) {
+		t.Errorf("handoff.json = %s", files["handoff.json"])
+	}
+
+	page := files["index.html"]
+	for _, want := range []string{
+		"claude-sonnet-4-5 and is advisory",
+		`<strong>20/100 (low)</strong><br>1 high finding`,
+		`<a href="https://github.com/acme/app/issues/12">#12</a> Uploads fail`,
+		"Confirm &lt;b&gt;retries&lt;/b&gt; are logged",
+		`<span class="del">-upload()</span><span class="add">&#43;retry(upload)</span>`,
+		"<td>upload.go:12</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page doesn't contain %q:\n%s", want, page)
+		}
+	}
+}
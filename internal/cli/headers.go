@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// headerFlags collects the "Name: value" headers of a repeated -header flag
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	name, _, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("want \"Name: value\", got %q", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// requestHeaders returns the headers for a provider's API requests: those of
+// the global config, replaced by any given with -header
+func requestHeaders(cfg *GlobalConfig, provider string, flags headerFlags) http.Header {
+	headers := make(http.Header)
+	for name, value := range cfg.Providers[provider].Headers {
+		headers.Set(name, value)
+	}
+	for _, h := range flags {
+		name, value, _ := strings.Cut(h, ":")
+		headers.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return headers
+}
+
+// secretHeader reports whether a header carries credentials and so is
+// left out of transcripts: the providers' own, and any whose name
+// suggests a key or token, such as a gateway's
+func secretHeader(name string) bool {
+	for _, secret := range secretHeaders {
+		if strings.EqualFold(name, secret) {
+			return true
+		}
+	}
+	lower := strings.ToLower(name)
+	for _, word := range []string{"key", "token", "secret", "auth", "password"} {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marete/pr-review/pkg/llm"
+)
+
+// TestRequestHeaders tests that API requests carry the configured headers
+// and user ID, and that credential-like headers stay out of transcripts
+func TestRequestHeaders(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(config, []byte(`user_id: team-ci
+providers:
+  anthropic:
+    headers:
+      anthropic-beta: context-1m-2025-08-07
+      X-Cost-Center: "42"
+      X-Gateway-Key: secret
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setPathFlags(t, config, "", "")
+	global, err := loadGlobalConfig()
+	if err != nil {
+		t.Fatalf("loadGlobalConfig() returned error: %v", err)
+	}
+	var flags headerFlags
+	if err := flags.Set("X-Cost-Center: 7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Set("no colon"); err == nil {
+		t.Error("headerFlags.Set() without a colon returned no error")
+	}
+
+	headers := requestHeaders(global, providerAnthropic, flags)
+	if got := headers.Get("X-Cost-Center"); got != "7" {
+		t.Errorf("X-Cost-Center = %q, want -header to win", got)
+	}
+	if len(requestHeaders(global, providerOpenAI, nil)) != 0 {
+		t.Error("anthropic headers were used for openai")
+	}
+
+	var got http.Header
+	var body struct {
+		Metadata *struct {
+			UserID string `json:"user_id"`
+		} `json:"metadata"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		io.WriteString(w, `{"content": [{"type": "text", "text": "ok"}], "stop_reason": "end_turn"}`)
+	}))
+	defer server.Close()
+	log := newTranscript(filepath.Join(t.TempDir(), "transcript.json"), nil)
+	client, err := llm.New(providerAnthropic, llm.Options{APIKey: "test", BaseURL: server.URL, Headers: headers, UserID: global.UserID, Transcript: log})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.Complete("Review this diff.", CompletionOptions{Model: "claude", MaxTokens: 100}); err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+	if got.Get("anthropic-beta") != "context-1m-2025-08-07" || got.Get("X-Gateway-Key") != "secret" {
+		t.Errorf("request headers = %v", got)
+	}
+	if body.Metadata == nil || body.Metadata.UserID != "team-ci" {
+		t.Errorf("request metadata = %+v, want the user ID", body.Metadata)
+	}
+
+	recorded := log.Exchanges[0].Request.Headers
+	if recorded.Get("X-Gateway-Key") != "" || recorded.Get("X-Api-Key") != "" || recorded.Get("X-Cost-Center") != "7" {
+		t.Errorf("transcript headers = %v, want the gateway key and API key left out", recorded)
+	}
+}
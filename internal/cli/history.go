@@ -0,0 +1,334 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// historyStore persists completed reviews in a SQLite database, one per
+// repository, or in a Postgres database shared by every repository
+type historyStore struct {
+	db       *sql.DB
+	path     string // the SQLite database file
+	postgres bool
+
+	// repo limits a store in a shared database to one repository's reviews;
+	// "" is every review in the database
+	repo string
+}
+
+// reviewRecord is a single review saved in the history store
+type reviewRecord struct {
+	ID           int64     `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Repo         string    `json:"repo"`
+	Branch       string    `json:"branch"`
+	BaseRef      string    `json:"base_ref"`
+	BaseSHA      string    `json:"base_sha"`
+	HeadSHA      string    `json:"head_sha"`
+	Model        string    `json:"model"`
+	Review       string    `json:"review"`
+	Findings     []Finding `json:"findings"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	LinesChanged int       `json:"lines_changed"`
+	DurationMS   int64     `json:"duration_ms"`
+	Team         string    `json:"team,omitempty"`
+
+	// Kind is "" for a review of a change before it merged, or
+	// reviewKindPostmortem for a look back at a merged one
+	Kind string `json:"kind,omitempty"`
+
+	// DiffHash is the SHA-256 of the diff reviewed, so reviews of the same
+	// changes can be found whichever commits they were on
+	DiffHash string `json:"diff_hash,omitempty"`
+
+	// CostUSD is the review's cost at list prices when it ran, including
+	// prompt cache writes and reads; 0 if the model's price was unknown
+	CostUSD float64 `json:"cost_usd,omitempty"`
+}
+
+// reviewKindPostmortem marks a review of an already-merged change made by
+// `pr-review postmortem`
+const reviewKindPostmortem = "postmortem"
+
+// diffHash identifies a diff's content for reviewRecord.DiffHash
+func diffHash(diff string) string {
+	sum := sha256.Sum256([]byte(diff))
+	return hex.EncodeToString(sum[:])
+}
+
+// historyMigrations are applied in order; the database's user_version records
+// how many have run. Only ever append to this list.
+var historyMigrations = []string{
+	`CREATE TABLE reviews (
+		id            INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at    TEXT NOT NULL,
+		repo          TEXT NOT NULL,
+		branch        TEXT NOT NULL,
+		base_ref      TEXT NOT NULL,
+		base_sha      TEXT NOT NULL,
+		head_sha      TEXT NOT NULL,
+		model         TEXT NOT NULL,
+		review        TEXT NOT NULL,
+		findings      TEXT NOT NULL,
+		input_tokens  INTEGER NOT NULL,
+		output_tokens INTEGER NOT NULL
+	);
+	CREATE INDEX reviews_range ON reviews (repo, base_sha, head_sha);`,
+	`ALTER TABLE reviews ADD COLUMN lines_changed INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE reviews ADD COLUMN duration_ms INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE reviews ADD COLUMN team TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE reviews ADD COLUMN compressed INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE reviews ADD COLUMN kind TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE reviews ADD COLUMN diff_hash TEXT NOT NULL DEFAULT '';
+	ALTER TABLE reviews ADD COLUMN cost_usd REAL NOT NULL DEFAULT 0;
+	CREATE INDEX reviews_diff ON reviews (repo, diff_hash);`,
+}
+
+// repoKey is the directory name a repository's data is kept under: a hash of
+// its identity, so names are filesystem-safe and don't reveal the remote
+func repoKey(repo string) string {
+	sum := sha256.Sum256([]byte(repo))
+	return hex.EncodeToString(sum[:8])
+}
+
+// repoHistoryPath returns the history database of one repository. Each
+// repository gets its own database so histories never mix.
+func repoHistoryPath(dir, repo string) string {
+	return filepath.Join(dir, "repos", repoKey(repo), "history.db")
+}
+
+// openRepoHistory opens the history store of repo under the data directory
+func openRepoHistory(dir, repo string) (*historyStore, error) {
+	return openHistory(repoHistoryPath(dir, repo))
+}
+
+// allHistoryPaths returns the history databases of every repository under
+// the data directory
+func allHistoryPaths(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "repos", "*", "history.db"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// openHistory opens (creating if necessary) the history database at path
+func openHistory(path string) (*historyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history %s: %w", path, err)
+	}
+	// SQLite allows a single writer; serializing through one connection
+	// avoids "database is locked" errors between goroutines
+	db.SetMaxOpenConns(1)
+
+	h := &historyStore{db: db, path: path}
+	if err := h.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate history %s: %w", path, err)
+	}
+	return h, nil
+}
+
+func (h *historyStore) migrate() error {
+	if h.postgres {
+		return h.migratePostgres()
+	}
+	var version int
+	if err := h.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(historyMigrations); i++ {
+		tx, err := h.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(historyMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the database
+func (h *historyStore) Close() error {
+	return h.db.Close()
+}
+
+// Record saves a review, filling in its ID and creation time
+func (h *historyStore) Record(r *reviewRecord) error {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+	findings, err := json.Marshal(r.Findings)
+	if err != nil {
+		return fmt.Errorf("error marshaling findings: %w", err)
+	}
+	review, compressed, err := packText([]byte(r.Review), findings)
+	if err != nil {
+		return fmt.Errorf("error compressing review: %w", err)
+	}
+
+	review = h.text(review)
+
+	err = h.db.QueryRow(h.bind(`INSERT INTO reviews
+		(created_at, repo, branch, base_ref, base_sha, head_sha, model, review, findings, input_tokens, output_tokens,
+		 lines_changed, duration_ms, team, compressed, kind, diff_hash, cost_usd)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		r.CreatedAt.UTC().Format(time.RFC3339Nano), r.Repo, r.Branch, r.BaseRef, r.BaseSHA, r.HeadSHA,
+		r.Model, review[0], review[1], r.InputTokens, r.OutputTokens, r.LinesChanged, r.DurationMS, r.Team, compressed, r.Kind,
+		r.DiffHash, r.CostUSD).Scan(&r.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record review: %w", err)
+	}
+	return nil
+}
+
+// FindLatest returns the most recent review of headSHA against baseSHA in
+// repo, or nil if there is none. Postmortems aren't reviews to reuse.
+func (h *historyStore) FindLatest(repo, baseSHA, headSHA string) (*reviewRecord, error) {
+	row := h.db.QueryRow(h.bind(`SELECT `+reviewColumns+` FROM reviews
+		WHERE repo = ? AND base_sha = ? AND head_sha = ? AND kind = ''
+		ORDER BY id DESC LIMIT 1`), repo, baseSHA, headSHA)
+	r, err := scanReview(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return r, err
+}
+
+// List returns every review in the store, oldest first
+func (h *historyStore) List() ([]*reviewRecord, error) {
+	where, args := h.where("")
+	rows, err := h.db.Query(h.bind(`SELECT `+reviewColumns+` FROM reviews `+where+` ORDER BY id`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviews: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*reviewRecord
+	for rows.Next() {
+		r, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// Contains reports whether the store already has a review of the same
+// commits by the same model made at the same time, so imports are idempotent
+func (h *historyStore) Contains(r *reviewRecord) (bool, error) {
+	var n int
+	where, args := h.where("created_at = ? AND base_sha = ? AND head_sha = ? AND model = ?",
+		r.CreatedAt.UTC().Format(time.RFC3339Nano), r.BaseSHA, r.HeadSHA, r.Model)
+	err := h.db.QueryRow(h.bind(`SELECT COUNT(*) FROM reviews `+where), args...).Scan(&n)
+	return n > 0, err
+}
+
+// where builds a WHERE clause from cond, limited to the store's repository
+// in a shared database
+func (h *historyStore) where(cond string, args ...any) (string, []any) {
+	if h.repo != "" {
+		if cond != "" {
+			cond += " AND "
+		}
+		cond += "repo = ?"
+		args = append(args, h.repo)
+	}
+	if cond == "" {
+		return "", args
+	}
+	return "WHERE " + cond, args
+}
+
+// bind rewrites a query's ? placeholders as the $1, $2, ... Postgres uses
+func (h *historyStore) bind(query string) string {
+	if !h.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// text adapts the columns packText returns to the database: Postgres keeps
+// them as bytea, which mustn't be given strings it would parse for escapes
+func (h *historyStore) text(values [2]any) [2]any {
+	if h.postgres {
+		for i, v := range values {
+			if s, ok := v.(string); ok {
+				values[i] = []byte(s)
+			}
+		}
+	}
+	return values
+}
+
+const reviewColumns = `id, created_at, repo, branch, base_ref, base_sha, head_sha, model, review, findings, input_tokens, output_tokens,
+	lines_changed, duration_ms, team, compressed, kind, diff_hash, cost_usd`
+
+// scanReview reads a row selected with reviewColumns
+func scanReview(row interface{ Scan(...any) error }) (*reviewRecord, error) {
+	var r reviewRecord
+	var createdAt string
+	var review, findings []byte
+	var compressed bool
+	err := row.Scan(&r.ID, &createdAt, &r.Repo, &r.Branch, &r.BaseRef, &r.BaseSHA, &r.HeadSHA,
+		&r.Model, &review, &findings, &r.InputTokens, &r.OutputTokens, &r.LinesChanged, &r.DurationMS, &r.Team, &compressed, &r.Kind,
+		&r.DiffHash, &r.CostUSD)
+	if err != nil {
+		return nil, err
+	}
+	if r.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, fmt.Errorf("review %d has invalid timestamp: %w", r.ID, err)
+	}
+	if compressed {
+		if review, err = gunzip(review); err != nil {
+			return nil, fmt.Errorf("review %d has corrupt text: %w", r.ID, err)
+		}
+		if findings, err = gunzip(findings); err != nil {
+			return nil, fmt.Errorf("review %d has corrupt findings: %w", r.ID, err)
+		}
+	}
+	r.Review = string(review)
+	if err := json.Unmarshal(findings, &r.Findings); err != nil {
+		return nil, fmt.Errorf("review %d has invalid findings: %w", r.ID, err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHistoryStore_RecordAndFind tests saving a review and finding it by range
+func TestHistoryStore_RecordAndFind(t *testing.T) {
+	h, err := openHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("openHistory() returned error: %v", err)
+	}
+	defer h.Close()
+
+	first := &reviewRecord{
+		Repo: "/src/app", Branch: "feature", BaseRef: "main",
+		BaseSHA: "aaa", HeadSHA: "bbb", Model: "m1", Review: "first",
+		Findings:    []Finding{{File: "a.go", Severity: SeverityHigh, Title: "Bug"}},
+		InputTokens: 10, OutputTokens: 20,
+	}
+	second := &reviewRecord{
+		Repo: "/src/app", Branch: "feature", BaseRef: "main",
+		BaseSHA: "aaa", HeadSHA: "bbb", Model: "m2", Review: "second",
+		DiffHash: diffHash("+fix\n"), CostUSD: 0.0425,
+	}
+	for _, r := range []*reviewRecord{first, second} {
+		if err := h.Record(r); err != nil {
+			t.Fatalf("Record() returned error: %v", err)
+		}
+	}
+	if first.ID == 0 || second.ID <= first.ID {
+		t.Errorf("unexpected IDs: %d, %d", first.ID, second.ID)
+	}
+
+	got, err := h.FindLatest("/src/app", "aaa", "bbb")
+	if err != nil {
+		t.Fatalf("FindLatest() returned error: %v", err)
+	}
+	if got == nil || got.Review != "second" || got.Model != "m2" || got.DiffHash != diffHash("+fix\n") || got.CostUSD != 0.0425 {
+		t.Fatalf("FindLatest() = %+v, want the second review", got)
+	}
+	if time.Since(got.CreatedAt) > time.Minute {
+		t.Errorf("CreatedAt = %v, want recent", got.CreatedAt)
+	}
+
+	// A postmortem of the same range isn't a review to reuse
+	if err := h.Record(&reviewRecord{Repo: "/src/app", BaseSHA: "aaa", HeadSHA: "bbb", Review: "postmortem", Kind: reviewKindPostmortem}); err != nil {
+		t.Fatalf("Record() returned error: %v", err)
+	}
+	if got, err := h.FindLatest("/src/app", "aaa", "bbb"); err != nil || got == nil || got.Review != "second" {
+		t.Errorf("FindLatest() after a postmortem = %+v, %v; want the second review", got, err)
+	}
+	if list, _ := h.List(); len(list) != 3 || list[2].Kind != reviewKindPostmortem {
+		t.Errorf("List() = %+v, want the postmortem last", list)
+	}
+
+	for _, tc := range []struct{ repo, base, head string }{
+		{"/src/other", "aaa", "bbb"},
+		{"/src/app", "aaa", "ccc"},
+		{"/src/app", "zzz", "bbb"},
+	} {
+		got, err := h.FindLatest(tc.repo, tc.base, tc.head)
+		if err != nil || got != nil {
+			t.Errorf("FindLatest(%q, %q, %q) = %v, %v; want nil, nil", tc.repo, tc.base, tc.head, got, err)
+		}
+	}
+}
+
+// TestHistoryStore_Reopen tests that reviews persist and migrations are idempotent
+func TestHistoryStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history.db")
+	h, err := openHistory(path)
+	if err != nil {
+		t.Fatalf("openHistory() returned error: %v", err)
+	}
+	record := &reviewRecord{Repo: "r", BaseSHA: "a", HeadSHA: "b", Review: "kept",
+		Findings: []Finding{{Title: "Issue", Severity: SeverityCritical}}}
+	if err := h.Record(record); err != nil {
+		t.Fatalf("Record() returned error: %v", err)
+	}
+	h.Close()
+
+	h, err = openHistory(path)
+	if err != nil {
+		t.Fatalf("reopening history returned error: %v", err)
+	}
+	defer h.Close()
+
+	got, err := h.FindLatest("r", "a", "b")
+	if err != nil || got == nil {
+		t.Fatalf("FindLatest() = %v, %v", got, err)
+	}
+	if got.Review != "kept" || len(got.Findings) != 1 || got.Findings[0].Severity != SeverityCritical {
+		t.Errorf("FindLatest() = %+v", got)
+	}
+}
+
+// TestFormatAge tests coarse age formatting
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{10 * time.Second, "less than a minute"},
+		{time.Minute, "1 minute"},
+		{45 * time.Minute, "45 minutes"},
+		{3 * time.Hour, "3 hours"},
+		{50 * time.Hour, "2 days"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+// TestHistoryStore_ReviewMetrics tests that diff size, latency and team are stored
+func TestHistoryStore_ReviewMetrics(t *testing.T) {
+	h, err := openHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("openHistory() returned error: %v", err)
+	}
+	defer h.Close()
+
+	r := &reviewRecord{Repo: "app", BaseSHA: "a", HeadSHA: "b", LinesChanged: 120, DurationMS: 4500, Team: "payments"}
+	if err := h.Record(r); err != nil {
+		t.Fatalf("Record() returned error: %v", err)
+	}
+	got, err := h.FindLatest("app", "a", "b")
+	if err != nil || got == nil {
+		t.Fatalf("FindLatest() = %v, %v", got, err)
+	}
+	if got.LinesChanged != 120 || got.DurationMS != 4500 || got.Team != "payments" {
+		t.Errorf("FindLatest() = %+v", got)
+	}
+}
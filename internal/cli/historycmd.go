@@ -0,0 +1,467 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// History export formats
+const (
+	exportJSONL   = "jsonl"
+	exportCSV     = "csv"
+	exportParquet = "parquet"
+)
+
+// openHistoryFor opens the history of repo in the configured storage
+func openHistoryFor(repo string) (reviewHistory, error) {
+	storage, err := openStorage()
+	if err != nil {
+		return nil, err
+	}
+	return storage.OpenHistory(repo)
+}
+
+// runHistory implements `pr-review history [list]|export|import`. Without
+// a command, or with only flags, it lists the reviews.
+func runHistory(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: pr-review history [list] [-repo repo | -all] [-branch branch] [-n count] | export [-all] [-format jsonl|csv|parquet] [-o file] | import <file>...")
+		os.Exit(2)
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runHistoryList(args)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		runHistoryList(args[1:])
+	case "export":
+		runHistoryExport(args[1:])
+	case "import":
+		runHistoryImport(args[1:])
+	default:
+		usage()
+	}
+}
+
+// runHistoryExport writes the review history as JSON lines, one review per
+// line, so it can be moved to another machine or a shared instance, or as a
+// CSV or Parquet table for analytics
+func runHistoryExport(args []string) {
+	fs := flag.NewFlagSet("history export", flag.ExitOnError)
+	addPathFlags(fs)
+	all := fs.Bool("all", false, "Export the history of every repository, not just the current one")
+	outFile := fs.String("o", "", "Write to this file instead of stdout")
+	format := fs.String("format", exportJSONL, "Output format: jsonl (re-importable), csv or parquet (one row per finding)")
+	parseFlags(fs, fs.Name(), args)
+
+	switch *format {
+	case exportJSONL, exportCSV, exportParquet:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid -format %q (want jsonl, csv or parquet)\n", *format)
+		os.Exit(2)
+	}
+
+	storage, err := openStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening history: %v\n", err)
+		os.Exit(1)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", *outFile, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	count, err := exportHistory(out, storage, historyScope(*all), *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting history: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "✅ Exported %d review(s)\n", count)
+}
+
+// loadHistory reads every review of repo, or of every repository if repo
+// is ""
+func loadHistory(storage storageBackend, repo string) ([]*reviewRecord, error) {
+	var records []*reviewRecord
+	err := storage.EachHistory(repo, func(h reviewHistory) error {
+		list, err := h.List()
+		records = append(records, list...)
+		return err
+	})
+	return records, err
+}
+
+// exportHistory writes every review of repo, or of every repository if
+// repo is "", in format, returning the number of reviews written
+func exportHistory(out io.Writer, storage storageBackend, repo, format string) (int, error) {
+	records, err := loadHistory(storage, repo)
+	if err != nil {
+		return 0, err
+	}
+
+	switch format {
+	case exportCSV:
+		return len(records), writeHistoryCSV(out, historyTable(records))
+	case exportParquet:
+		return len(records), writeParquet(out, historyTable(records))
+	}
+	enc := json.NewEncoder(out)
+	for i, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return i, err
+		}
+	}
+	return len(records), nil
+}
+
+// historyTable flattens reviews into analytics columns with one row per
+// finding. Reviews without findings get a single row with empty finding
+// columns, so every run is counted. Feedback verdicts are not recorded yet;
+// the verdict column keeps the schema stable for when they are.
+func historyTable(records []*reviewRecord) []*parquetColumn {
+	text := func(name string) *parquetColumn { return &parquetColumn{name: name, text: true, strs: []string{}} }
+	number := func(name string) *parquetColumn { return &parquetColumn{name: name, ints: []int64{}} }
+
+	runID, createdAt := number("run_id"), &parquetColumn{name: "created_at", timestamp: true, ints: []int64{}}
+	repo, branch, baseRef := text("repo"), text("branch"), text("base_ref")
+	baseSHA, headSHA, model := text("base_sha"), text("head_sha"), text("model")
+	inputTokens, outputTokens, findingCount := number("input_tokens"), number("output_tokens"), number("finding_count")
+	team, linesChanged, durationMS := text("team"), number("lines_changed"), number("duration_ms")
+	kind, hash, costMicroUSD := text("kind"), text("diff_hash"), number("cost_micro_usd")
+	file, line, severity := text("file"), number("line"), text("severity")
+	category, title, verdict := text("category"), text("title"), text("verdict")
+
+	for _, r := range records {
+		findings := r.Findings
+		if len(findings) == 0 {
+			findings = []Finding{{}}
+		}
+		for _, f := range findings {
+			runID.ints = append(runID.ints, r.ID)
+			createdAt.ints = append(createdAt.ints, r.CreatedAt.UnixMilli())
+			repo.strs = append(repo.strs, r.Repo)
+			branch.strs = append(branch.strs, r.Branch)
+			baseRef.strs = append(baseRef.strs, r.BaseRef)
+			baseSHA.strs = append(baseSHA.strs, r.BaseSHA)
+			headSHA.strs = append(headSHA.strs, r.HeadSHA)
+			model.strs = append(model.strs, r.Model)
+			inputTokens.ints = append(inputTokens.ints, int64(r.InputTokens))
+			outputTokens.ints = append(outputTokens.ints, int64(r.OutputTokens))
+			findingCount.ints = append(findingCount.ints, int64(len(r.Findings)))
+			team.strs = append(team.strs, r.Team)
+			kind.strs = append(kind.strs, r.Kind)
+			linesChanged.ints = append(linesChanged.ints, int64(r.LinesChanged))
+			durationMS.ints = append(durationMS.ints, r.DurationMS)
+			hash.strs = append(hash.strs, r.DiffHash)
+			costMicroUSD.ints = append(costMicroUSD.ints, int64(math.Round(r.CostUSD*1e6)))
+			file.strs = append(file.strs, f.File)
+			line.ints = append(line.ints, int64(f.Line))
+			sev := ""
+			if len(r.Findings) > 0 {
+				sev = f.Severity.String()
+			}
+			severity.strs = append(severity.strs, sev)
+			category.strs = append(category.strs, f.Category)
+			title.strs = append(title.strs, f.Title)
+			verdict.strs = append(verdict.strs, "")
+		}
+	}
+	return []*parquetColumn{runID, createdAt, kind, repo, team, branch, baseRef, baseSHA, headSHA, model,
+		inputTokens, outputTokens, costMicroUSD, linesChanged, durationMS, hash, findingCount, file, line, severity, category, title, verdict}
+}
+
+// writeHistoryCSV writes a history table as CSV with a header row
+func writeHistoryCSV(out io.Writer, columns []*parquetColumn) error {
+	w := csv.NewWriter(out)
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.name
+	}
+	if err := w.Write(record); err != nil {
+		return err
+	}
+
+	rows := 0
+	if len(columns) > 0 {
+		rows = columns[0].len()
+	}
+	for row := 0; row < rows; row++ {
+		for i, c := range columns {
+			switch {
+			case c.text:
+				record[i] = c.strs[row]
+			case c.timestamp:
+				record[i] = time.UnixMilli(c.ints[row]).UTC().Format(time.RFC3339)
+			default:
+				record[i] = strconv.FormatInt(c.ints[row], 10)
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// runHistoryImport reads JSON lines written by `history export` into the
+// history of each review's repository
+func runHistoryImport(args []string) {
+	fs := flag.NewFlagSet("history import", flag.ExitOnError)
+	addPathFlags(fs)
+	parseFlags(fs, fs.Name(), args)
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: pr-review history import <file>... (use - for stdin)")
+		os.Exit(2)
+	}
+
+	storage, err := openStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening history: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, file := range fs.Args() {
+		in := os.Stdin
+		if file != "-" {
+			f, err := os.Open(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", file, err)
+				os.Exit(1)
+			}
+			in = f
+		}
+		imported, skipped, err := importHistory(in, storage)
+		in.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Imported %d review(s) from %s (%d already present)\n", imported, file, skipped)
+	}
+}
+
+// importHistory adds exported reviews to the history of their repositories,
+// skipping reviews that are already present
+func importHistory(in io.Reader, storage storageBackend) (imported, skipped int, err error) {
+	stores := make(map[string]reviewHistory)
+	defer func() {
+		for _, h := range stores {
+			h.Close()
+		}
+	}()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r reviewRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return imported, skipped, fmt.Errorf("line %d: %w", line, err)
+		}
+		if r.Repo == "" {
+			return imported, skipped, fmt.Errorf("line %d: review has no repo", line)
+		}
+
+		h, ok := stores[r.Repo]
+		if !ok {
+			if h, err = storage.OpenHistory(r.Repo); err != nil {
+				return imported, skipped, err
+			}
+			stores[r.Repo] = h
+		}
+		exists, err := h.Contains(&r)
+		if err != nil {
+			return imported, skipped, err
+		}
+		if exists {
+			skipped++
+			continue
+		}
+		r.ID = 0
+		if err := h.Record(&r); err != nil {
+			return imported, skipped, err
+		}
+		imported++
+	}
+	return imported, skipped, scanner.Err()
+}
+
+// historyRepo returns the repository whose history -repo names: the current
+// one by default, or the identity of the remote URL or path given
+func historyRepo(repo string) string {
+	switch {
+	case repo == "":
+		return getRepoIdentity()
+	case filepath.IsAbs(repo):
+		return filepath.Clean(repo)
+	}
+	return normalizeRemoteURL(repo)
+}
+
+// reviewCost returns a review's cost in USD: as recorded when it ran, or at
+// today's list prices for reviews saved before costs were. It returns false
+// if neither is known.
+func reviewCost(r *reviewRecord) (float64, bool) {
+	if r.CostUSD > 0 {
+		return r.CostUSD, true
+	}
+	return estimateCost(r.Model, r.InputTokens, r.OutputTokens)
+}
+
+// runHistoryList implements `pr-review history [list]`, listing the reviews
+// in the history newest first, so one can be found and shown again
+func runHistoryList(args []string) {
+	fs := flag.NewFlagSet("history list", flag.ExitOnError)
+	addPathFlags(fs)
+	repo := fs.String("repo", "", "List the reviews of this repository, by its remote URL (e.g. github.com/org/app) or path (default: the current one)")
+	all := fs.Bool("all", false, "List the reviews of every repository")
+	branch := fs.String("branch", "", "List only the reviews of this branch")
+	limit := fs.Int("n", 20, "Most reviews to list (0: all)")
+	parseFlags(fs, fs.Name(), args)
+
+	if *all && *repo != "" {
+		fmt.Fprintln(os.Stderr, "Error: -repo and -all can't be used together")
+		os.Exit(2)
+	}
+	storage, err := openStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening history: %v\n", err)
+		os.Exit(1)
+	}
+	scope := ""
+	if !*all {
+		scope = historyRepo(*repo)
+	}
+	records, err := loadHistory(storage, scope)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		os.Exit(1)
+	}
+	records = selectHistory(records, *branch, *limit)
+	if len(records) == 0 {
+		fmt.Println("No reviews in history yet.")
+		return
+	}
+	writeHistoryList(os.Stdout, records, *all)
+}
+
+// selectHistory returns the reviews of branch, or of every branch if it is
+// "", newest first and at most limit of them unless limit is 0
+func selectHistory(records []*reviewRecord, branch string, limit int) []*reviewRecord {
+	var selected []*reviewRecord
+	for _, r := range records {
+		if branch == "" || r.Branch == branch {
+			selected = append(selected, r)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].CreatedAt.After(selected[j].CreatedAt) })
+	if limit > 0 && len(selected) > limit {
+		selected = selected[:limit]
+	}
+	return selected
+}
+
+// writeHistoryList writes reviews as a table, with their repositories when
+// they come from more than one
+func writeHistoryList(out io.Writer, records []*reviewRecord, withRepo bool) {
+	header, separator := "| ID | Date | ", "|---:|---|"
+	if withRepo {
+		header, separator = header+"Repository | ", separator+"---|"
+	}
+	fmt.Fprintln(out, header+"Branch | Head | Model | Findings | Cost (USD) |")
+	fmt.Fprintln(out, separator+"---|---|---|---:|---:|")
+	for _, r := range records {
+		row := []string{strconv.FormatInt(r.ID, 10), r.CreatedAt.Local().Format("2006-01-02 15:04")}
+		if withRepo {
+			row = append(row, r.Repo)
+		}
+		branch := r.Branch
+		if r.Kind != "" {
+			branch += " (" + r.Kind + ")"
+		}
+		cost := "n/a"
+		if c, ok := reviewCost(r); ok {
+			cost = fmt.Sprintf("%.2f", c)
+		}
+		row = append(row, branch, shortSHA(r.HeadSHA), r.Model, strconv.Itoa(len(r.Findings)), cost)
+		fmt.Fprintf(out, "| %s |\n", strings.Join(row, " | "))
+	}
+}
+
+// runShow implements `pr-review show <id>`, printing a review from the
+// history again, or writing it to a file
+func runShow(args []string) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	addPathFlags(fs)
+	repo := fs.String("repo", "", "Repository the review is in, by its remote URL (e.g. github.com/org/app) or path (default: the current one)")
+	outputFile := fs.String("o", "", "Write the review to this file instead of printing it (will create numbered backups if exists)")
+	parseFlags(fs, fs.Name(), args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: pr-review show [-repo repo] [-o file] <id>   (IDs are listed by pr-review history)")
+		os.Exit(2)
+	}
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid review ID %q\n", fs.Arg(0))
+		os.Exit(2)
+	}
+
+	history, err := openHistoryFor(historyRepo(*repo))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening history: %v\n", err)
+		os.Exit(1)
+	}
+	defer history.Close()
+	records, err := history.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		os.Exit(1)
+	}
+	var record *reviewRecord
+	for _, r := range records {
+		if r.ID == id {
+			record = r
+		}
+	}
+	if record == nil {
+		fmt.Fprintf(os.Stderr, "Error: no review %d in the history of %s\n", id, historyRepo(*repo))
+		os.Exit(1)
+	}
+
+	if *outputFile != "" {
+		if err := writeReviewToFile(*outputFile, record.Review); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing review: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Review %d written to: %s\n", id, *outputFile)
+		return
+	}
+	fmt.Printf("📜 Review %d of %s (%s) against %s with %s, %s ago\n\n", id, record.Branch, shortSHA(record.HeadSHA),
+		record.BaseRef, record.Model, formatAge(time.Since(record.CreatedAt)))
+	printReview(record.Review, Usage{InputTokens: record.InputTokens, OutputTokens: record.OutputTokens})
+}
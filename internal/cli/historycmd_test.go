@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExportImportHistory tests moving history between data directories
+func TestExportImportHistory(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, r := range []*reviewRecord{
+		{Repo: "github.com/org/app", BaseSHA: "a", HeadSHA: "b", Model: "m", Review: "app review", CreatedAt: created},
+		{Repo: "github.com/org/lib", BaseSHA: "c", HeadSHA: "d", Model: "m", Review: "lib review", CreatedAt: created,
+			Findings: []Finding{{File: "x.go", Severity: SeverityHigh, Title: "Bug"}}},
+	} {
+		h, err := openRepoHistory(src, r.Repo)
+		if err != nil {
+			t.Fatalf("openRepoHistory() returned error: %v", err)
+		}
+		if err := h.Record(r); err != nil {
+			t.Fatalf("Record() returned error: %v", err)
+		}
+		h.Close()
+	}
+
+	paths, err := allHistoryPaths(src)
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("allHistoryPaths() = %v, %v; want one database per repo", paths, err)
+	}
+
+	var exported bytes.Buffer
+	count, err := exportHistory(&exported, sqliteStorage{dir: src}, "", exportJSONL)
+	if err != nil || count != 2 {
+		t.Fatalf("exportHistory() = %d, %v", count, err)
+	}
+
+	// Importing twice only adds the reviews once
+	for i, wantImported := range []int{2, 0} {
+		imported, skipped, err := importHistory(strings.NewReader(exported.String()), sqliteStorage{dir: dst})
+		if err != nil {
+			t.Fatalf("importHistory() returned error: %v", err)
+		}
+		if imported != wantImported || skipped != 2-wantImported {
+			t.Errorf("import %d: imported %d, skipped %d", i+1, imported, skipped)
+		}
+	}
+
+	h, err := openRepoHistory(dst, "github.com/org/lib")
+	if err != nil {
+		t.Fatalf("openRepoHistory() returned error: %v", err)
+	}
+	defer h.Close()
+	got, err := h.FindLatest("github.com/org/lib", "c", "d")
+	if err != nil || got == nil {
+		t.Fatalf("FindLatest() = %v, %v", got, err)
+	}
+	if got.Review != "lib review" || !got.CreatedAt.Equal(created) || len(got.Findings) != 1 {
+		t.Errorf("imported review = %+v", got)
+	}
+
+	// The other repository's review stays in its own store
+	if other, _ := h.FindLatest("github.com/org/app", "a", "b"); other != nil {
+		t.Error("reviews of different repositories share a store")
+	}
+}
+
+// TestImportHistory_Invalid tests that malformed input is rejected
+func TestImportHistory_Invalid(t *testing.T) {
+	for _, input := range []string{"not json\n", `{"review": "no repo"}` + "\n"} {
+		if _, _, err := importHistory(strings.NewReader(input), sqliteStorage{dir: t.TempDir()}); err == nil {
+			t.Errorf("importHistory(%q) expected error", input)
+		}
+	}
+}
+
+// TestExportHistory_CSV tests the analytics table: one row per finding, and
+// one row for a review without findings
+func TestExportHistory_CSV(t *testing.T) {
+	dir := t.TempDir()
+	h, err := openRepoHistory(dir, "github.com/org/app")
+	if err != nil {
+		t.Fatalf("openRepoHistory() returned error: %v", err)
+	}
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, r := range []*reviewRecord{
+		{Repo: "github.com/org/app", Model: "m", CreatedAt: created, InputTokens: 10, Findings: []Finding{
+			{File: "a.go", Line: 3, Severity: SeverityHigh, Category: "security", Title: "Injection, via \"name\""},
+			{File: "b.go", Severity: SeverityLow, Title: "Naming"},
+		}},
+		{Repo: "github.com/org/app", Model: "m", CreatedAt: created},
+	} {
+		if err := h.Record(r); err != nil {
+			t.Fatalf("Record() returned error: %v", err)
+		}
+	}
+	h.Close()
+
+	var out bytes.Buffer
+	count, err := exportHistory(&out, sqliteStorage{dir: dir}, "github.com/org/app", exportCSV)
+	if err != nil || count != 2 {
+		t.Fatalf("exportHistory() = %d, %v", count, err)
+	}
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("exported CSV does not parse: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want header + 2 findings + 1 empty review", len(rows))
+	}
+	col := make(map[string]int)
+	for i, name := range rows[0] {
+		col[name] = i
+	}
+	first, empty := rows[1], rows[3]
+	if first[col["created_at"]] != "2025-03-01T12:00:00Z" || first[col["severity"]] != "high" ||
+		first[col["title"]] != `Injection, via "name"` || first[col["finding_count"]] != "2" || first[col["line"]] != "3" {
+		t.Errorf("first finding row = %v", first)
+	}
+	if empty[col["finding_count"]] != "0" || empty[col["severity"]] != "" || empty[col["run_id"]] != "2" {
+		t.Errorf("empty review row = %v", empty)
+	}
+	if _, ok := col["verdict"]; !ok {
+		t.Error("table has no verdict column")
+	}
+}
+
+// TestHistoryList tests listing reviews newest first, filtered by branch,
+// with their costs
+func TestHistoryList(t *testing.T) {
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	records := []*reviewRecord{
+		{ID: 1, Repo: "github.com/org/app", Branch: "feature", HeadSHA: "1111111111111111", Model: "claude-sonnet-4-5", InputTokens: 1_000_000, CreatedAt: day},
+		{ID: 2, Repo: "github.com/org/app", Branch: "fix", HeadSHA: "2222", Model: "unknown-model", CreatedAt: day.Add(time.Hour)},
+		{ID: 3, Repo: "github.com/org/app", Branch: "feature", HeadSHA: "3333", Model: "unknown-model", CostUSD: 0.25, CreatedAt: day.Add(2 * time.Hour),
+			Findings: []Finding{{Severity: SeverityHigh}}},
+		{ID: 4, Repo: "github.com/org/app", Branch: "feature", HeadSHA: "4444", Kind: reviewKindPostmortem, CreatedAt: day.Add(3 * time.Hour)},
+	}
+
+	selected := selectHistory(records, "feature", 2)
+	if len(selected) != 2 || selected[0].ID != 4 || selected[1].ID != 3 {
+		t.Fatalf("selectHistory(feature, 2) = %+v, want 4 and 3", selected)
+	}
+	if all := selectHistory(records, "", 0); len(all) != 4 || all[3].ID != 1 {
+		t.Errorf("selectHistory(all) = %+v", all)
+	}
+
+	var out bytes.Buffer
+	writeHistoryList(&out, selectHistory(records, "feature", 0), false)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || strings.Contains(lines[0], "Repository") {
+		t.Fatalf("list =\n%s", out.String())
+	}
+	if !strings.HasPrefix(lines[2], "| 4 |") || !strings.HasPrefix(lines[3], "| 3 |") {
+		t.Errorf("list isn't newest first:\n%s", out.String())
+	}
+	for _, want := range []string{"| feature (postmortem) | 4444 |", "| 3333 | unknown-model | 1 | 0.25 |", "| 111111111111 | claude-sonnet-4-5 | 0 | 3.00 |"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("list missing %q:\n%s", want, out.String())
+		}
+	}
+	out.Reset()
+	writeHistoryList(&out, records[:1], true)
+	if !strings.Contains(out.String(), "| Repository |") || !strings.Contains(out.String(), "| github.com/org/app | feature |") {
+		t.Errorf("list with repositories =\n%s", out.String())
+	}
+
+	if got := historyRepo("git@github.com:org/app.git"); got != "github.com/org/app" {
+		t.Errorf("historyRepo(scp URL) = %q", got)
+	}
+}
@@ -0,0 +1,333 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hookMarker identifies a git hook pr-review installed, so uninstalling
+// never removes someone else's
+const hookMarker = "# Installed by pr-review hook install"
+
+// hookTimeout bounds a -hook review unless -timeout is given, so a commit
+// isn't held up for minutes
+const hookTimeout = 60 * time.Second
+
+// hookReviewFile is where the hook name writes its review, in the git
+// directory so committing or pushing doesn't leave files in the working tree
+func hookReviewFile(name string) string {
+	return "pr-review-" + name + ".md"
+}
+
+// applyHookMode sets up a review run as the git hook name, for the flags
+// that weren't given on the command line
+func applyHookMode(fs *flag.FlagSet, name string) error {
+	if name != "pre-commit" {
+		return fmt.Errorf("%q isn't a hook pr-review runs as; use pre-commit", name)
+	}
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	defaults := map[string]string{
+		"staged":    "true",
+		"quick":     "true",
+		"yes":       "true",
+		"no-stream": "true",
+		"fail-on":   "critical",
+		"timeout":   hookTimeout.String(),
+		// A second model call or the repository's commands would eat into
+		// the timeout
+		"no-flaky-check": "true",
+		"no-pre-review":  "true",
+	}
+	if gitDir, err := gitCommand("rev-parse", "--git-dir").Output(); err == nil {
+		defaults["output"] = filepath.Join(strings.TrimSpace(string(gitDir)), hookReviewFile(name))
+	}
+	for flagName, value := range defaults {
+		if !given[flagName] {
+			if err := fs.Set(flagName, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// printHookSummary prints what a hook's review found at high or critical
+// severity, and where the whole review is
+func printHookSummary(w io.Writer, findings []Finding, output string) {
+	var serious []Finding
+	for _, f := range findings {
+		if f.Severity >= SeverityHigh {
+			serious = append(serious, f)
+		}
+	}
+	if len(serious) == 0 {
+		fmt.Fprintln(w, "✅ "+tr("No serious issues found; the review is in %s", output))
+		return
+	}
+	for _, f := range serious {
+		if loc := f.Location(); loc != "" {
+			fmt.Fprintf(w, "- [%s] `%s` %s\n", f.Severity, loc, f.Title)
+		} else {
+			fmt.Fprintf(w, "- [%s] %s\n", f.Severity, f.Title)
+		}
+	}
+	fmt.Fprintln(w, tr("The whole review is in %s", output))
+}
+
+// prePushHook returns the pre-push hook running a -quick review with exe of
+// each branch pushed to, as git lists them on the hook's input: the commits
+// the remote doesn't have yet, or, for a new branch or one whose history
+// was rewritten, the whole branch against the base. Each review is written
+// to pr-review-pre-push-<remote branch>.md in the git directory. With block
+// set, critical findings in any of them stop the push; a review that can't
+// run, e.g. for want of network, never does.
+func prePushHook(exe string, block bool) string {
+	// Without block only the org policy's gate can fail a review
+	failOn, gate := "none", `	if [ $status -eq 1 ]; then
+		echo "pr-review: findings in $branch fail the review gate, see $out" >&2
+		continue
+	fi
+`
+	if block {
+		failOn, gate = "critical", `	if [ $status -eq 1 ]; then
+		echo "pr-review: critical findings in $branch, see $out; push with --no-verify to skip the review" >&2
+		blocked=1
+		continue
+	fi
+`
+	}
+	return `#!/bin/sh
+` + hookMarker + `; remove with pr-review hook uninstall.
+# git push --no-verify skips it.
+gitdir="$(git rev-parse --git-dir)"
+blocked=0
+while read -r local_ref local_sha remote_ref remote_sha; do
+	# Deleting a branch pushes no commits, and tags aren't reviewed
+	case "$local_sha" in *[!0]*) ;; *) continue ;; esac
+	case "$remote_ref" in refs/heads/*) ;; *) continue ;; esac
+	branch="${remote_ref#refs/heads/}"
+	# The local branch pushed, by name, or the commit
+	head="$local_sha"
+	case "$local_ref" in
+	refs/heads/*)
+		if [ "$(git rev-parse -q --verify "$local_ref")" = "$local_sha" ]; then
+			head="${local_ref#refs/heads/}"
+		fi
+		;;
+	esac
+	out="$gitdir/pr-review-pre-push-$(printf '%s' "$branch" | tr -c 'A-Za-z0-9._-' '-').md"
+	# Only the new commits, unless the remote's are unknown or rewritten
+	case "$remote_sha" in
+	*[!0]*)
+		if git merge-base --is-ancestor "$remote_sha" "$local_sha" 2>/dev/null; then
+			set -- -range "$remote_sha..$head"
+		else
+			set -- -head "$head"
+		fi
+		;;
+	*) set -- -head "$head" ;;
+	esac
+	` + shellQuote(exe) + ` -quick -yes -fail-on ` + failOn + ` -output "$out" "$@" </dev/null
+	status=$?
+` + gate + `	if [ $status -ne 0 ]; then
+		echo "pr-review: the review of $branch didn't finish (status $status); going ahead" >&2
+	fi
+done
+exit $blocked
+`
+}
+
+// preCommitHook returns the pre-commit hook running exe as a -hook
+// pre-commit review of the staged changes, whose critical findings stop the
+// commit
+func preCommitHook(exe string) string {
+	return hookScript("pre-commit", "commit", shellQuote(exe)+" -hook pre-commit", true)
+}
+
+// hookScript returns the hook name, which runs command with the review
+// written to the git directory before git does action. With block set, a
+// failed gate stops it; a review that can't run, e.g. for want of network,
+// never does.
+func hookScript(name, action, command string, block bool) string {
+	gate := ""
+	if block {
+		gate = `if [ $status -eq 1 ]; then
+	echo "pr-review: critical findings, see $out; ` + action + ` with --no-verify to skip the review" >&2
+	exit 1
+fi
+`
+	}
+	return `#!/bin/sh
+` + hookMarker + `; remove with pr-review hook uninstall.
+# git ` + action + ` --no-verify skips it.
+out="$(git rev-parse --git-dir)/` + hookReviewFile(name) + `"
+` + command + ` -output "$out" </dev/null
+status=$?
+` + gate + `if [ $status -ne 0 ]; then
+	echo "pr-review: the review didn't finish (status $status); going ahead" >&2
+fi
+exit 0
+`
+}
+
+// shellQuote quotes s for sh, leaving plain words as they are
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:", r))
+	}) == -1 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// installHook writes script as the hook name in dir. A hook that is already
+// there is only replaced with force, after backing it up, unless pr-review
+// installed it.
+func installHook(dir, name, script string, force bool) error {
+	path := filepath.Join(dir, name)
+	if existing, err := os.ReadFile(path); err == nil && !strings.Contains(string(existing), hookMarker) {
+		if !force {
+			return fmt.Errorf("%s already exists; pass -force to back it up and replace it", path)
+		}
+		if err := backupFile(path); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	return os.WriteFile(path, []byte(script), 0755)
+}
+
+// uninstallHook removes the hook name in dir if pr-review installed it,
+// reporting whether there was one to remove
+func uninstallHook(dir, name string) (bool, error) {
+	path := filepath.Join(dir, name)
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !strings.Contains(string(existing), hookMarker) {
+		return false, fmt.Errorf("%s wasn't installed by pr-review; leaving it", path)
+	}
+	return true, os.Remove(path)
+}
+
+// hooksDir returns the repository's hooks directory, honoring core.hooksPath
+func hooksDir() (string, error) {
+	output, err := gitCommand("rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository")
+	}
+	dir := filepath.FromSlash(strings.TrimSpace(string(output)))
+	if !filepath.IsAbs(dir) {
+		if wd, err := os.Getwd(); err == nil {
+			dir = filepath.Join(wd, dir)
+		}
+	}
+	return dir, nil
+}
+
+// hookExecutable is how the hook runs pr-review: by name if that finds this
+// binary on the PATH, so upgrades are picked up, otherwise by its path
+func hookExecutable() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return "pr-review"
+	}
+	if onPath, err := exec.LookPath("pr-review"); err == nil {
+		a, errA := filepath.EvalSymlinks(onPath)
+		b, errB := filepath.EvalSymlinks(exe)
+		if errA == nil && errB == nil && a == b {
+			return "pr-review"
+		}
+	}
+	return exe
+}
+
+// runHook implements `pr-review hook install|uninstall`
+func runHook(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: pr-review hook install [-pre-commit] [-pre-push [-block]] [-force] | uninstall [-pre-commit] [-pre-push]")
+		os.Exit(2)
+	}
+	if len(args) == 0 || args[0] != "install" && args[0] != "uninstall" {
+		usage()
+	}
+	fs := flag.NewFlagSet("hook "+args[0], flag.ExitOnError)
+	addPathFlags(fs)
+	preCommit := fs.Bool("pre-commit", false, "The pre-commit hook, giving the staged changes a -hook pre-commit review before each commit and stopping it on critical findings (git commit --no-verify skips the hook)")
+	prePush := fs.Bool("pre-push", false, "The pre-push hook, giving the branch a -quick review before each push")
+	var block, force *bool
+	if args[0] == "install" {
+		block = fs.Bool("block", false, "Stop the push when the pre-push review has critical findings (git push --no-verify skips the hook)")
+		force = fs.Bool("force", false, "Replace a hook that is already there, backing it up first")
+	}
+	parseFlags(fs, fs.Name(), args[1:])
+
+	dir, err := hooksDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if args[0] == "uninstall" {
+		// Without a hook named, remove whichever of ours are there
+		named := *preCommit || *prePush
+		removedAny := false
+		for _, h := range []struct {
+			name string
+			on   bool
+		}{{"pre-commit", *preCommit}, {"pre-push", *prePush}} {
+			if named && !h.on {
+				continue
+			}
+			removed, err := uninstallHook(dir, h.name)
+			if err != nil && named {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if removed {
+				fmt.Printf("✅ Removed the %s hook\n", h.name)
+				removedAny = true
+			}
+		}
+		if !removedAny {
+			fmt.Println("No pr-review hook is installed.")
+		}
+		return
+	}
+
+	if !*preCommit && !*prePush {
+		fmt.Fprintln(os.Stderr, "Error: say which hook to install: -pre-commit or -pre-push")
+		os.Exit(2)
+	}
+	exe := hookExecutable()
+	if *preCommit {
+		if err := installHook(dir, "pre-commit", preCommitHook(exe), *force); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Installed the pre-commit hook in %s: each commit gets a quick review of the staged changes, and critical findings stop it\n", dir)
+	}
+	if *prePush {
+		if err := installHook(dir, "pre-push", prePushHook(exe, *block), *force); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *block {
+			fmt.Printf("✅ Installed the pre-push hook in %s: each push gets a quick review, and critical findings stop it\n", dir)
+		} else {
+			fmt.Printf("✅ Installed the pre-push hook in %s: each push gets a quick review\n", dir)
+		}
+	}
+}
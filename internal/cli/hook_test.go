@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestInstallHook tests installing and removing the pre-push hook without
+// clobbering someone else's
+func TestInstallHook(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hooks")
+	if err := installHook(dir, "pre-push", prePushHook("pr-review", false), false); err != nil {
+		t.Fatalf("installHook() returned error: %v", err)
+	}
+	// Reinstalling ours needs no -force
+	if err := installHook(dir, "pre-push", prePushHook("pr-review", true), false); err != nil {
+		t.Fatalf("installHook() over its own hook returned error: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "pre-push"))
+	if !strings.Contains(string(data), "pr-review -quick -yes -fail-on critical ") {
+		t.Errorf("hook =\n%s", data)
+	}
+	if removed, err := uninstallHook(dir, "pre-push"); !removed || err != nil {
+		t.Errorf("uninstallHook() = %v, %v", removed, err)
+	}
+	if removed, err := uninstallHook(dir, "pre-push"); removed || err != nil {
+		t.Errorf("uninstallHook() without a hook = %v, %v", removed, err)
+	}
+
+	theirs := filepath.Join(dir, "pre-push")
+	if err := os.WriteFile(theirs, []byte("#!/bin/sh\nmake lint\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := installHook(dir, "pre-push", prePushHook("pr-review", false), false); err == nil {
+		t.Error("installHook() replaced another hook without -force")
+	}
+	if _, err := uninstallHook(dir, "pre-push"); err == nil {
+		t.Error("uninstallHook() removed another hook")
+	}
+	if err := installHook(dir, "pre-push", prePushHook("pr-review", false), true); err != nil {
+		t.Fatalf("installHook() with force returned error: %v", err)
+	}
+	if backup, err := os.ReadFile(theirs + ".~1~"); err != nil || string(backup) != "#!/bin/sh\nmake lint\n" {
+		t.Errorf("backup = %q, %v", backup, err)
+	}
+}
+
+// TestPrePushHook tests that only a failed gate stops the push
+func TestPrePushHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks run under sh")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := initHookRepo(t)
+	head := gitOutput(t, "rev-parse", "HEAD")
+	push := "refs/heads/feature " + head + " refs/heads/feature " + strings.Repeat("0", 40) + "\n"
+
+	for _, tc := range []struct {
+		status  int
+		block   bool
+		pushing bool
+	}{
+		{0, true, true},
+		{1, true, false},
+		{1, false, true},
+		{3, true, true}, // the API failed
+		{5, true, true}, // timed out
+	} {
+		// A stand-in for pr-review that exits with the given status
+		exe := filepath.Join(dir, "fake review")
+		script := "#!/bin/sh\nexit " + strconv.Itoa(tc.status) + "\n"
+		if err := os.WriteFile(exe, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		hook := filepath.Join(dir, "pre-push")
+		if err := os.WriteFile(hook, []byte(prePushHook(exe, tc.block)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command("sh", hook)
+		cmd.Stdin = strings.NewReader(push)
+		if err := cmd.Run(); (err == nil) != tc.pushing {
+			t.Errorf("review status %d, block %v: hook returned %v, want the push to go ahead: %v", tc.status, tc.block, err, tc.pushing)
+		}
+		if tc.block {
+			if err := os.WriteFile(hook, []byte(preCommitHook(exe)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := exec.Command("sh", hook).Run(); (err == nil) != tc.pushing {
+				t.Errorf("review status %d: pre-commit hook returned %v, want the commit to go ahead: %v", tc.status, err, tc.pushing)
+			}
+		}
+	}
+}
+
+// TestPrePushHook_Refs tests that the hook reviews what each pushed branch
+// brings, whichever branch is checked out
+func TestPrePushHook_Refs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks run under sh")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := initHookRepo(t)
+	first, second := gitOutput(t, "rev-parse", "HEAD~1"), gitOutput(t, "rev-parse", "HEAD")
+	gitOutput(t, "checkout", "-q", "main")
+	zero := strings.Repeat("0", 40)
+
+	// A stand-in for pr-review that logs its arguments
+	exe := filepath.Join(dir, "fake review")
+	log := filepath.Join(dir, "calls")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\necho \"$*\" >> '"+log+"'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	hook := filepath.Join(dir, "pre-push")
+	if err := os.WriteFile(hook, []byte(prePushHook(exe, true)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitDir := gitOutput(t, "rev-parse", "--git-dir")
+	out := func(branch string) string { return gitDir + "/pr-review-pre-push-" + branch + ".md" }
+
+	for _, tc := range []struct {
+		name, push, want string
+	}{
+		{"new branch", "refs/heads/feature " + second + " refs/heads/feature " + zero,
+			"-quick -yes -fail-on critical -output " + out("feature") + " -head feature"},
+		{"new commits", "refs/heads/feature " + second + " refs/heads/feature " + first,
+			"-quick -yes -fail-on critical -output " + out("feature") + " -range " + first + "..feature"},
+		{"rewritten", "refs/heads/feature " + second + " refs/heads/feature " + strings.Repeat("1", 40),
+			"-quick -yes -fail-on critical -output " + out("feature") + " -head feature"},
+		{"a commit pushed to a branch", second + " " + second + " refs/heads/fix/x " + zero,
+			"-quick -yes -fail-on critical -output " + out("fix-x") + " -head " + second},
+		{"branch by commit", "refs/heads/fix/x " + first + " refs/heads/fix/x " + zero,
+			"-quick -yes -fail-on critical -output " + out("fix-x") + " -head " + first},
+		{"deleted branch", "(delete) " + zero + " refs/heads/old " + first, ""},
+		{"tag", "refs/tags/v1 " + first + " refs/tags/v1 " + zero, ""},
+	} {
+		os.Remove(log)
+		cmd := exec.Command("sh", hook)
+		cmd.Stdin = strings.NewReader(tc.push + "\n")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s: hook returned %v\n%s", tc.name, err, out)
+		}
+		got, _ := os.ReadFile(log)
+		if strings.TrimSpace(string(got)) != tc.want {
+			t.Errorf("%s: pr-review ran with %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// initHookRepo creates a repository with a main branch and a feature branch
+// two commits ahead of it, checked out, and changes to its directory
+func initHookRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	gitOutput(t, "init", "-q", "-b", "main")
+	gitOutput(t, "config", "user.email", "test@example.com")
+	gitOutput(t, "config", "user.name", "Test")
+	gitOutput(t, "commit", "-q", "--allow-empty", "-m", "base")
+	gitOutput(t, "checkout", "-q", "-b", "feature")
+	gitOutput(t, "commit", "-q", "--allow-empty", "-m", "one")
+	gitOutput(t, "commit", "-q", "--allow-empty", "-m", "two")
+	return dir
+}
+
+// gitOutput runs git, failing the test if it fails, and returns its output
+func gitOutput(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := gitCommand(args...).Output()
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// TestApplyHookMode tests the defaults of a -hook pre-commit review, which
+// flags on the command line override
+func TestApplyHookMode(t *testing.T) {
+	fs := flag.NewFlagSet("pr-review", flag.ContinueOnError)
+	staged := fs.Bool("staged", false, "")
+	quick := fs.Bool("quick", false, "")
+	yes := fs.Bool("yes", false, "")
+	noStream := fs.Bool("no-stream", false, "")
+	failOn := fs.String("fail-on", "", "")
+	output := fs.String("output", "REQUESTED_CHANGES.md", "")
+	timeout := fs.Duration("timeout", 0, "")
+	noFlakyCheck := fs.Bool("no-flaky-check", false, "")
+	noPreReview := fs.Bool("no-pre-review", false, "")
+	if err := fs.Parse([]string{"-timeout", "2m", "-output", "review.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyHookMode(fs, "pre-commit"); err != nil {
+		t.Fatalf("applyHookMode() returned error: %v", err)
+	}
+	if !*staged || !*quick || !*yes || !*noStream || *failOn != "critical" {
+		t.Errorf("applyHookMode() left staged %v, quick %v, yes %v, no-stream %v, fail-on %q", *staged, *quick, *yes, *noStream, *failOn)
+	}
+	if !*noFlakyCheck || !*noPreReview {
+		t.Errorf("applyHookMode() left no-flaky-check %v, no-pre-review %v", *noFlakyCheck, *noPreReview)
+	}
+	if *timeout != 2*time.Minute || *output != "review.md" {
+		t.Errorf("applyHookMode() overrode -timeout %v, -output %q", *timeout, *output)
+	}
+	if err := applyHookMode(fs, "pre-receive"); err == nil {
+		t.Error("applyHookMode(pre-receive) returned no error")
+	}
+}
+
+// TestPrintHookSummary tests that a hook prints only the serious findings
+func TestPrintHookSummary(t *testing.T) {
+	var b strings.Builder
+	printHookSummary(&b, []Finding{
+		{File: "auth.go", Line: 40, Severity: SeverityCritical, Title: "Token logged"},
+		{File: "auth.go", Line: 52, Severity: SeverityLow, Title: "Typo in comment"},
+		{Severity: SeverityHigh, Title: "No test for the new path"},
+	}, ".git/pr-review-pre-commit.md")
+	want := "- [critical] `auth.go:40` Token logged\n- [high] No test for the new path\nThe whole review is in .git/pr-review-pre-commit.md\n"
+	if b.String() != want {
+		t.Errorf("printHookSummary() =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	printHookSummary(&b, []Finding{{Severity: SeverityMedium, Title: "Long function"}}, "review.md")
+	if !strings.Contains(b.String(), "No serious issues") {
+		t.Errorf("printHookSummary() without serious findings = %q", b.String())
+	}
+}
+
+// TestShellQuote tests quoting the path of pr-review for the hook
+func TestShellQuote(t *testing.T) {
+	for in, want := range map[string]string{
+		"pr-review":                 "pr-review",
+		"/usr/local/bin/pr-review":  "/usr/local/bin/pr-review",
+		"/Users/me/My Tools/review": "'/Users/me/My Tools/review'",
+		"/tmp/it's":                 `'/tmp/it'\''s'`,
+	} {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
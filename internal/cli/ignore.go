@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// reviewIgnoreFile lists, at the top level of the repository, the paths
+// never reviewed, such as fixtures, snapshots and generated clients
+const reviewIgnoreFile = ".prreviewignore"
+
+// loadReviewIgnore reads the patterns of a .prreviewignore file, one per
+// line as in .gitignore: blank lines and lines starting with "#" are
+// skipped, and "\#" and "\!" escape a pattern starting with "#" or "!". A
+// missing file has no patterns.
+func loadReviewIgnore(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// matchIgnore reports whether the file at name is excluded by patterns
+// applied in order as in .gitignore: the last pattern matching the file or
+// one of its directories decides, and a pattern starting with "!" includes
+// the files it matches again. Later patterns, such as -exclude after
+// .prreviewignore, override earlier ones.
+func matchIgnore(patterns []string, name string) bool {
+	ignored := false
+	for _, p := range patterns {
+		negated := strings.HasPrefix(p, "!")
+		if negated {
+			p = p[1:]
+		} else if strings.HasPrefix(p, `\#`) || strings.HasPrefix(p, `\!`) {
+			p = p[1:]
+		}
+		for dir := name; dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if matchGlob(p, dir) {
+				ignored = !negated
+				break
+			}
+		}
+	}
+	return ignored
+}
+
+// defaultExcludes are left out of every review: vendored and installed
+// dependencies, build output, and lock and checksum files
+var defaultExcludes = []string{"vendor/", "node_modules/", "dist/", "*.lock", "*.sum"}
+
+// skippedByDefault reports whether defaultExcludes leave the file at name
+// out of the review. A file matching a pattern of include, or a "!"
+// pattern of excludes (from .prreviewignore and -exclude), is reviewed.
+func skippedByDefault(name string, include, excludes []string) bool {
+	if !matchIgnore(defaultExcludes, name) || matchAnyGlob(include, name) {
+		return false
+	}
+	for _, p := range excludes {
+		if strings.HasPrefix(p, "!") && matchIgnore([]string{p[1:]}, name) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestLoadReviewIgnore tests reading the patterns of a .prreviewignore file
+func TestLoadReviewIgnore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), reviewIgnoreFile)
+	if patterns, err := loadReviewIgnore(file); err != nil || patterns != nil {
+		t.Errorf("loadReviewIgnore() of a missing file = %q, %v", patterns, err)
+	}
+	os.WriteFile(file, []byte("# Generated\nclients/\n\n  **/__snapshots__/**  \n!clients/README.md\n\\#notes.txt\n"), 0o644)
+	patterns, err := loadReviewIgnore(file)
+	if err != nil {
+		t.Fatalf("loadReviewIgnore() returned error: %v", err)
+	}
+	if want := []string{"clients/", "**/__snapshots__/**", "!clients/README.md", `\#notes.txt`}; !reflect.DeepEqual(patterns, want) {
+		t.Errorf("loadReviewIgnore() = %q, want %q", patterns, want)
+	}
+}
+
+// TestMatchIgnore tests applying patterns in order, as .gitignore does
+func TestMatchIgnore(t *testing.T) {
+	patterns := []string{"clients/", "fixtures", "!clients/README.md", `\#notes.txt`, "!fixtures/keep.json"}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"clients/api/gen.go", true},
+		{"clients/README.md", false},
+		{"test/fixtures/a.json", true},
+		{"fixtures/keep.json", false},
+		{"#notes.txt", true},
+		{"src/app.go", false},
+	}
+	for _, tt := range tests {
+		if got := matchIgnore(patterns, tt.name); got != tt.want {
+			t.Errorf("matchIgnore(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	// A later pattern, such as from -exclude, overrides an earlier one
+	if !matchIgnore(append(patterns, "clients/README.md"), "clients/README.md") {
+		t.Error("a later pattern didn't override an earlier one")
+	}
+}
+
+// TestSkippedByDefault tests the default exclusions and opting back in
+func TestSkippedByDefault(t *testing.T) {
+	for _, name := range []string{"vendor/github.com/x/y.go", "web/node_modules/a/index.js", "dist/app.js", "Cargo.lock", "tools/go.sum"} {
+		if !skippedByDefault(name, nil, nil) {
+			t.Errorf("skippedByDefault(%q) = false", name)
+		}
+	}
+	if skippedByDefault("src/app.go", nil, nil) {
+		t.Error("skippedByDefault(src/app.go) = true")
+	}
+	if skippedByDefault("vendor/patched/fix.go", nil, []string{"docs/", "!vendor/patched/"}) {
+		t.Error("a ! pattern didn't review a vendored file")
+	}
+	if skippedByDefault("dist/app.js", []string{"dist/**"}, nil) {
+		t.Error("-include didn't review a file under dist/")
+	}
+}
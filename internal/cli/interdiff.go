@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sinceReview is the earlier reviewed state -since reviews the changes after
+type sinceReview struct {
+	// SHA is the commit the earlier review saw
+	SHA string
+
+	// Record is the latest review of SHA in the history, nil if there is none
+	Record *reviewRecord
+
+	// Rewritten is set when head doesn't descend from SHA, e.g. after a
+	// rebase or an amended commit
+	Rewritten bool
+}
+
+// resolveSince finds the state -since names: a review's ID in the history,
+// whose head it takes, or a commit, whose latest review it looks up. resolve
+// returns a ref's commit SHA, or false if it doesn't exist.
+func resolveSince(since string, records []*reviewRecord, resolve func(ref string) (string, bool)) (*sinceReview, error) {
+	if id, err := strconv.ParseInt(since, 10, 64); err == nil {
+		for _, r := range records {
+			if r.ID == id && r.Kind == "" {
+				return &sinceReview{SHA: r.HeadSHA, Record: r}, nil
+			}
+		}
+	}
+	sha, ok := resolve(since)
+	if !ok {
+		return nil, fmt.Errorf("%s is neither a commit nor the ID of a review in the history", since)
+	}
+	s := &sinceReview{SHA: sha}
+	for _, r := range records {
+		if r.HeadSHA == sha && r.Kind == "" && (s.Record == nil || !r.CreatedAt.Before(s.Record.CreatedAt)) {
+			s.Record = r
+		}
+	}
+	return s, nil
+}
+
+// lastReviewed returns the latest review of branch in repo whose head is
+// reachable, i.e. the commit being reviewed or one of its ancestors, for
+// -incremental; nil if there is none
+func lastReviewed(records []*reviewRecord, repo, branch string, reachable func(sha string) bool) *reviewRecord {
+	var candidates []*reviewRecord
+	for _, r := range records {
+		if r.Repo == repo && r.Branch == branch && r.Kind == "" && r.HeadSHA != "" {
+			candidates = append(candidates, r)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].CreatedAt.After(candidates[j].CreatedAt) })
+	for _, r := range candidates {
+		if reachable(r.HeadSHA) {
+			return r
+		}
+	}
+	return nil
+}
+
+// sinceInstructions tells the review that the diff only has what changed
+// since the earlier review, and what that review found
+func sinceInstructions(s *sinceReview) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "This is a follow-up review: the diff shows only what changed since commit %s was reviewed, not the whole change. ", shortSHA(s.SHA))
+	if s.Rewritten {
+		b.WriteString("The branch was rewritten since, e.g. rebased, so the diff compares the two versions of the files the change touches, and may include changes the base branch made to them. ")
+	}
+	b.WriteString("Review just these changes.")
+	if s.Record == nil {
+		b.WriteString(" The earlier review isn't in the history, so there are no earlier findings to refer to.\n")
+		return b.String()
+	}
+	if len(s.Record.Findings) == 0 {
+		b.WriteString(" The earlier review had no findings.\n")
+		return b.String()
+	}
+	b.WriteString(" The earlier review's findings are below: say briefly which of them these changes resolve, and don't repeat the others unless the changes bear on them.\n\n")
+	for _, f := range s.Record.Findings {
+		location := f.File
+		if f.Line > 0 {
+			location += ":" + strconv.Itoa(f.Line)
+		}
+		if location != "" {
+			location = " `" + location + "`"
+		}
+		fmt.Fprintf(&b, "- [%s]%s %s\n", f.Severity, location, f.Title)
+	}
+	return b.String()
+}
+
+// changedPaths lists the paths of git diff --name-status output, the new
+// path of a rename
+func changedPaths(nameStatus string) []string {
+	var paths []string
+	for _, line := range strings.Split(nameStatus, "\n") {
+		if fields := strings.Split(line, "\t"); len(fields) > 1 {
+			paths = append(paths, fields[len(fields)-1])
+		}
+	}
+	return paths
+}
+
+// appendReviewToFile adds the review of the commits since an earlier review
+// to the end of the file holding the reviews so far, under a heading
+func appendReviewToFile(filename, heading, content string) error {
+	existing, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return writeReviewToFile(filename, content)
+	}
+	if err != nil {
+		return err
+	}
+	text := strings.TrimRight(string(existing), "\n") + "\n\n---\n\n## " + heading + "\n\n" + content
+	if err := os.WriteFile(filename, []byte(text), 0644); err != nil {
+		return fmt.Errorf("failed to append review to %s: %w", filename, err)
+	}
+	return nil
+}
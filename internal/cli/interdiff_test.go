@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestResolveSince tests finding the earlier review -since names by ID or
+// by commit
+func TestResolveSince(t *testing.T) {
+	day := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	records := []*reviewRecord{
+		{ID: 1, HeadSHA: "aaa111", CreatedAt: day},
+		{ID: 2, HeadSHA: "bbb222", CreatedAt: day.Add(time.Hour), Findings: []Finding{{File: "cache.go", Line: 12, Severity: SeverityHigh, Title: "Unbounded cache"}}},
+		{ID: 3, HeadSHA: "bbb222", CreatedAt: day.Add(2 * time.Hour), Kind: reviewKindPostmortem},
+		{ID: 4, HeadSHA: "aaa111", CreatedAt: day.Add(3 * time.Hour)},
+	}
+	resolve := func(ref string) (string, bool) {
+		switch ref {
+		case "fixup~1", "aaa111":
+			return "aaa111", true
+		case "bbb222":
+			return "bbb222", true
+		}
+		return "", false
+	}
+
+	s, err := resolveSince("2", records, resolve)
+	if err != nil || s.SHA != "bbb222" || s.Record != records[1] {
+		t.Errorf("resolveSince(2) = %+v, %v; want review 2", s, err)
+	}
+	// A postmortem isn't a review of the branch to follow up on
+	if _, err := resolveSince("3", records, resolve); err == nil {
+		t.Error("resolveSince(3) found the postmortem")
+	}
+	if s, err = resolveSince("fixup~1", records, resolve); err != nil || s.SHA != "aaa111" || s.Record != records[3] {
+		t.Errorf("resolveSince(fixup~1) = %+v, %v; want the latest review of aaa111", s, err)
+	}
+	if s, err = resolveSince("bbb222", records[:1], resolve); err != nil || s.Record != nil {
+		t.Errorf("resolveSince(bbb222) without its review = %+v, %v", s, err)
+	}
+	if _, err := resolveSince("nope", records, resolve); err == nil {
+		t.Error("resolveSince(nope) returned no error")
+	}
+}
+
+// TestSinceInstructions tests the prompt section of a follow-up review
+func TestSinceInstructions(t *testing.T) {
+	s := &sinceReview{SHA: "bbb2220000", Record: &reviewRecord{Findings: []Finding{
+		{File: "cache.go", Line: 12, Severity: SeverityHigh, Title: "Unbounded cache"},
+		{Severity: SeverityLow, Title: "Missing changelog entry"},
+	}}}
+	got := sinceInstructions(s)
+	for _, want := range []string{"since commit bbb2220000 was reviewed", "- [high] `cache.go:12` Unbounded cache\n", "- [low] Missing changelog entry\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("sinceInstructions missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "rewritten") {
+		t.Errorf("sinceInstructions mentions a rewrite that didn't happen:\n%s", got)
+	}
+
+	s = &sinceReview{SHA: "bbb2220000", Rewritten: true}
+	got = sinceInstructions(s)
+	if !strings.Contains(got, "rewritten") || !strings.Contains(got, "no earlier findings") {
+		t.Errorf("sinceInstructions(rewritten, no review) = %q", got)
+	}
+}
+
+// TestLastReviewed tests finding the review -incremental follows up on
+func TestLastReviewed(t *testing.T) {
+	day := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	records := []*reviewRecord{
+		{ID: 1, Repo: "r", Branch: "feature", HeadSHA: "aaa", CreatedAt: day},
+		{ID: 2, Repo: "r", Branch: "feature", HeadSHA: "bbb", CreatedAt: day.Add(time.Hour)},
+		{ID: 3, Repo: "r", Branch: "feature", HeadSHA: "gone", CreatedAt: day.Add(2 * time.Hour)},
+		{ID: 4, Repo: "r", Branch: "other", HeadSHA: "ccc", CreatedAt: day.Add(3 * time.Hour)},
+		{ID: 5, Repo: "r", Branch: "feature", HeadSHA: "ddd", CreatedAt: day.Add(4 * time.Hour), Kind: reviewKindPostmortem},
+	}
+	// "gone" was rebased away, so it isn't an ancestor any more
+	reachable := func(sha string) bool { return sha != "gone" }
+	if r := lastReviewed(records, "r", "feature", reachable); r == nil || r.ID != 2 {
+		t.Errorf("lastReviewed() = %+v, want review 2", r)
+	}
+	if r := lastReviewed(records, "r", "main", reachable); r != nil {
+		t.Errorf("lastReviewed(main) = %+v, want none", r)
+	}
+}
+
+// TestAppendReviewToFile tests adding a follow-up review to the reviews so
+// far
+func TestAppendReviewToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "REVIEW.md")
+	if err := appendReviewToFile(path, "Commits Since aaa", "First review.\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendReviewToFile(path, "Commits Since bbb", "Second review.\n"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "First review.\n\n---\n\n## Commits Since bbb\n\nSecond review.\n"; string(data) != want {
+		t.Errorf("file = %q, want %q", data, want)
+	}
+}
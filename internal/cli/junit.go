@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// JUnit XML report, limited to what Jenkins, CircleCI and Buildkite read
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// buildJUnit converts a review to a JUnit test suite: a test case for the
+// review itself, carrying its summary, and one for each finding, which
+// fails if the finding fails the review gate. Without a gate no finding
+// fails, as the review's exit status doesn't; findings in the baseline are
+// left out.
+func buildJUnit(doc *reviewDocument) *junitTestSuites {
+	suite := junitTestSuite{
+		Name:      "pr-review",
+		TestCases: []junitTestCase{{ClassName: "pr-review", Name: "Review", SystemOut: doc.Summary}},
+	}
+	for _, f := range doc.Findings {
+		if f.Baselined {
+			continue
+		}
+		class := "pr-review"
+		if f.File != "" {
+			class += "." + f.File
+		}
+		text := f.Title
+		if f.Message != "" {
+			text += "\n\n" + f.Message
+		}
+		if f.Suggestion != "" {
+			text += "\n\nSuggestion: " + f.Suggestion
+		}
+		tc := junitTestCase{ClassName: class, File: f.File, Line: f.Line, Name: fmt.Sprintf("[%s] %s", f.Severity, f.Title)}
+		if loc := f.Location(); loc != "" {
+			tc.Name += " (" + loc + ")"
+		}
+		if doc.failOn != nil && f.Severity >= *doc.failOn {
+			tc.Failure = &junitFailure{Message: f.Title, Type: f.Severity.String(), Text: text}
+			suite.Failures++
+		} else {
+			tc.SystemOut = text
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suite.Tests = len(suite.TestCases)
+	return &junitTestSuites{Name: "pr-review", Tests: suite.Tests, Failures: suite.Failures, Suites: []junitTestSuite{suite}}
+}
+
+// writeJUnit writes a review as a JUnit XML report
+func writeJUnit(out io.Writer, doc *reviewDocument) error {
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(buildJUnit(doc)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
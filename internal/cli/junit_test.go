@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// TestBuildJUnit tests mapping findings to test cases that fail the gate
+func TestBuildJUnit(t *testing.T) {
+	high := SeverityHigh
+	doc := &reviewDocument{
+		Summary: "Adds login throttling.",
+		Findings: []Finding{
+			{File: "auth/login.go", Line: 42, Severity: SeverityCritical, Title: "SQL injection", Message: "Built with Sprintf"},
+			{File: "cache.go", Severity: SeverityLow, Title: "Ignored error"},
+			{Severity: SeverityHigh, Title: "No test for lockout"},
+			{File: "old.go", Line: 3, Severity: SeverityCritical, Title: "Accepted", Baselined: true},
+		},
+		failOn: &high,
+	}
+	suites := buildJUnit(doc)
+	if suites.Tests != 4 || suites.Failures != 2 {
+		t.Errorf("tests = %d, failures = %d; want 4 and 2", suites.Tests, suites.Failures)
+	}
+	cases := suites.Suites[0].TestCases
+	if cases[0].Name != "Review" || cases[0].SystemOut != "Adds login throttling." {
+		t.Errorf("review test case = %+v", cases[0])
+	}
+	if c := cases[1]; c.Name != "[critical] SQL injection (auth/login.go:42)" || c.ClassName != "pr-review.auth/login.go" || c.Failure == nil || c.Failure.Type != "critical" {
+		t.Errorf("critical test case = %+v", c)
+	}
+	if c := cases[2]; c.Failure != nil || c.SystemOut != "Ignored error" {
+		t.Errorf("test case below the gate = %+v", c)
+	}
+	if c := cases[3]; c.ClassName != "pr-review" || c.Failure == nil {
+		t.Errorf("test case without a file = %+v", c)
+	}
+
+	// Without a gate nothing fails
+	doc.failOn = nil
+	if suites := buildJUnit(doc); suites.Failures != 0 {
+		t.Errorf("failures without a gate = %d", suites.Failures)
+	}
+}
+
+// TestWriteJUnit tests that the report is well-formed XML
+func TestWriteJUnit(t *testing.T) {
+	var b strings.Builder
+	doc := &reviewDocument{Findings: []Finding{{File: "a.go", Line: 1, Severity: SeverityMedium, Title: "x < y && <script>"}}}
+	if err := writeJUnit(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "<?xml") {
+		t.Errorf("report has no XML header:\n%s", b.String())
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal([]byte(b.String()), &suites); err != nil {
+		t.Fatalf("report isn't valid XML: %v\n%s", err, b.String())
+	}
+	if len(suites.Suites) != 1 || len(suites.Suites[0].TestCases) != 2 || suites.Suites[0].TestCases[1].SystemOut != "x < y && <script>" {
+		t.Errorf("report = %+v", suites)
+	}
+}
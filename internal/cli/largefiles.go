@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+// defaultMaxFileDiff is how large a file's diff can be before the prompt
+// summarizes it instead of showing it
+const defaultMaxFileDiff = 256 << 10
+
+// blobSizes returns the size of a file before and after the change; a size
+// is -1 where the file doesn't exist or its size isn't known
+type blobSizes func(path string) (before, after int64)
+
+// summarizeLargeFiles replaces the sections of binary files, and of files
+// whose diff is larger than maxDiff bytes (0: no limit), with their header
+// and a one-line summary, e.g. "logo.png: binary, +12.0 KB", returning the
+// summaries. sizes may be nil when the file sizes can't be looked up.
+func summarizeLargeFiles(changes *branchChanges, maxDiff ByteSize, sizes blobSizes) []string {
+	files := gitdiff.Split(changes.Diff)
+	var summaries []string
+	for i, f := range files {
+		header, binary := binaryHeader(f.Text)
+		var summary string
+		switch {
+		case binary:
+			summary = fmt.Sprintf("%s: binary", f.Path)
+			if sizes != nil {
+				summary += sizeChange(sizes(f.Path))
+			}
+		case maxDiff > 0 && ByteSize(len(f.Text)) > maxDiff:
+			var hunks []gitdiff.Hunk
+			header, hunks = gitdiff.Hunks(f)
+			added, removed := changedLines(hunks...)
+			summary = fmt.Sprintf("%s: +%d -%d lines, a diff of %s", f.Path, added, removed, ByteSize(len(f.Text)))
+		default:
+			continue
+		}
+		files[i].Text = header + "\n" + encodingNotePrefix + summary + ", omitted\n"
+		summaries = append(summaries, summary)
+	}
+	if summaries != nil {
+		changes.Diff = gitdiff.Join(files)
+	}
+	return summaries
+}
+
+// changedLines counts the lines hunks add and remove
+func changedLines(hunks ...gitdiff.Hunk) (added, removed int) {
+	for _, h := range hunks {
+		for _, line := range h.Lines {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				added++
+			case strings.HasPrefix(line, "-"):
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
+
+// binaryHeader reports whether a file section is of a binary file and
+// returns its lines before git's "Binary files ... differ" or binary patch
+func binaryHeader(text string) (string, bool) {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(line, "@@") {
+			return "", false
+		}
+		if line == "GIT binary patch" || strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ") {
+			return strings.Join(lines[:i], "\n"), true
+		}
+	}
+	return "", false
+}
+
+// sizeChange describes how a file's size changes, e.g. ", +12.0 KB" for a
+// new file or ", 10.0 KB → 12.0 KB"
+func sizeChange(before, after int64) string {
+	switch {
+	case before < 0 && after < 0:
+		return ""
+	case before < 0:
+		return ", +" + ByteSize(after).String()
+	case after < 0:
+		return ", -" + ByteSize(before).String()
+	}
+	return fmt.Sprintf(", %s → %s", ByteSize(before), ByteSize(after))
+}
+
+// omittedInstructions tells the review which files' diffs were left out
+// and why
+func omittedInstructions(summaries []string) string {
+	var b strings.Builder
+	b.WriteString("The diffs of the files below are binary or too large to show, so the diff only has their header and a one-line summary. Review them from the summary and the rest of the change; don't ask for their content.\n\n")
+	for _, s := range summaries {
+		fmt.Fprintf(&b, "- %s\n", s)
+	}
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSummarizeLargeFiles tests replacing binary and huge files' diffs
+// with a summary
+func TestSummarizeLargeFiles(t *testing.T) {
+	changes := &branchChanges{Diff: fileDiff("app.go", 10) +
+		"diff --git a/logo.png b/logo.png\nnew file mode 100644\nindex 0000000..1111111\nBinary files /dev/null and b/logo.png differ\n" +
+		"diff --git a/data.json b/data.json\n--- a/data.json\n+++ b/data.json\n@@ -1,2 +1,2 @@\n-" + strings.Repeat("a", 600) + "\n+" + strings.Repeat("b", 600) + "\n+{}\n"}
+	sizes := func(path string) (int64, int64) { return -1, 12 << 10 }
+	summaries := summarizeLargeFiles(changes, 1024, sizes)
+	want := []string{"logo.png: binary, +12.0 KB", "data.json: +2 -1 lines, a diff of 1.3 KB"}
+	if !reflect.DeepEqual(summaries, want) {
+		t.Errorf("summarizeLargeFiles() = %q, want %q", summaries, want)
+	}
+	if !strings.HasPrefix(changes.Diff, fileDiff("app.go", 10)) ||
+		!strings.Contains(changes.Diff, "diff --git a/logo.png b/logo.png\nnew file mode 100644\nindex 0000000..1111111\n# pr-review: logo.png: binary, +12.0 KB, omitted\n") ||
+		!strings.HasSuffix(changes.Diff, "+++ b/data.json\n# pr-review: data.json: +2 -1 lines, a diff of 1.3 KB, omitted\n") {
+		t.Errorf("diff after summarizing:\n%s", changes.Diff)
+	}
+
+	changes = &branchChanges{Diff: fileDiff("app.go", 2000)}
+	if summarizeLargeFiles(changes, 0, nil) != nil || changes.Diff != fileDiff("app.go", 2000) {
+		t.Error("summarizeLargeFiles() without a limit changed a text file")
+	}
+}
+
+// TestSizeChange tests describing a binary file's change in size
+func TestSizeChange(t *testing.T) {
+	for _, tt := range []struct {
+		before, after int64
+		want          string
+	}{
+		{-1, 2048, ", +2.0 KB"},
+		{2048, -1, ", -2.0 KB"},
+		{1024, 2048, ", 1.0 KB → 2.0 KB"},
+		{-1, -1, ""},
+	} {
+		if got := sizeChange(tt.before, tt.after); got != tt.want {
+			t.Errorf("sizeChange(%d, %d) = %q, want %q", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ledgerFile is the usage ledger in the data directory
+const ledgerFile = "usage.jsonl"
+
+// Usage rollups
+const (
+	usageByDay   = "day"
+	usageByWeek  = "week"
+	usageByMonth = "month"
+	usageByRepo  = "repo"
+	usageByModel = "model"
+)
+
+// ledgerEntry is one model request in the usage ledger
+type ledgerEntry struct {
+	Time                     time.Time `json:"time"`
+	Command                  string    `json:"command"`
+	Repo                     string    `json:"repo,omitempty"`
+	Branch                   string    `json:"branch,omitempty"`
+	Provider                 string    `json:"provider"`
+	Model                    string    `json:"model"`
+	InputTokens              int       `json:"input_tokens"`
+	OutputTokens             int       `json:"output_tokens"`
+	ThinkingTokens           int       `json:"thinking_tokens,omitempty"`
+	CacheCreationInputTokens int       `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int       `json:"cache_read_input_tokens,omitempty"`
+
+	// Cost is the list-price cost in USD, if the model's price is known
+	Cost *float64 `json:"cost_usd,omitempty"`
+}
+
+// usageLedger appends every model request of a run to the ledger
+type usageLedger struct {
+	mu       sync.Mutex
+	path     string
+	command  string
+	provider string
+	repo     string
+	branch   string
+	failed   bool // a write failed and was warned about
+}
+
+// ledger is the usage ledger of this run, set up with the provider; nil
+// records nothing
+var ledger *usageLedger
+
+// openLedger returns the ledger in the data directory for the requests of
+// command to provider, or nil if there is no data directory
+func openLedger(command, provider string) *usageLedger {
+	dir, err := dataDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Usage ledger unavailable: %v\n", err)
+		return nil
+	}
+	return &usageLedger{
+		path:     filepath.Join(dir, ledgerFile),
+		command:  command,
+		provider: provider,
+		repo:     getRepoIdentity(),
+		branch:   getCurrentBranch(),
+	}
+}
+
+// setTarget sets the repository and branch under review, when it isn't the
+// checkout the tool runs in
+func (l *usageLedger) setTarget(repo, branch string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.repo, l.branch = repo, branch
+}
+
+// record appends a request's usage of model to the ledger. A ledger that
+// can't be written is warned about once and doesn't fail the run.
+func (l *usageLedger) record(model string, usage Usage) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := ledgerEntry{
+		Time:                     time.Now().UTC(),
+		Command:                  l.command,
+		Repo:                     l.repo,
+		Branch:                   l.branch,
+		Provider:                 l.provider,
+		Model:                    model,
+		InputTokens:              usage.InputTokens,
+		OutputTokens:             usage.OutputTokens,
+		ThinkingTokens:           usage.ThinkingTokens,
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+	}
+	if cost, ok := usageCost(model, usage); ok {
+		entry.Cost = &cost
+	}
+	if err := appendLedger(l.path, entry); err != nil && !l.failed {
+		l.failed = true
+		fmt.Fprintf(os.Stderr, "Warning: Could not record usage in %s: %v\n", l.path, err)
+	}
+}
+
+// appendLedger appends an entry to the ledger file as a JSON line
+func appendLedger(path string, entry ledgerEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readLedger reads the entries of a ledger, skipping lines that aren't
+// entries; a missing ledger has none
+func readLedger(path string) ([]ledgerEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []ledgerEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e ledgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping line %d of %s: %v\n", line, path, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// usageRollup is the usage of a group of requests
+type usageRollup struct {
+	Group    string
+	Requests int
+	Usage    Usage
+
+	// Cost of the requests by models with a known price
+	PricedRequests int
+	Cost           float64
+}
+
+func (r *usageRollup) add(e ledgerEntry) {
+	r.Requests++
+	r.Usage.Add(Usage{
+		InputTokens:              e.InputTokens,
+		OutputTokens:             e.OutputTokens,
+		ThinkingTokens:           e.ThinkingTokens,
+		CacheCreationInputTokens: e.CacheCreationInputTokens,
+		CacheReadInputTokens:     e.CacheReadInputTokens,
+	})
+	if e.Cost != nil {
+		r.PricedRequests++
+		r.Cost += *e.Cost
+	}
+}
+
+// usageKey returns the group of an entry in a rollup by day, week, month,
+// repository or model
+func usageKey(e ledgerEntry, by string) string {
+	switch by {
+	case usageByDay:
+		return e.Time.UTC().Format("2006-01-02")
+	case usageByWeek:
+		return periodKey(e.Time, periodWeek)
+	case usageByMonth:
+		return periodKey(e.Time, periodMonth)
+	case usageByRepo:
+		if e.Repo == "" {
+			return "(no repository)"
+		}
+		return e.Repo
+	}
+	return e.Model
+}
+
+// rollupUsage groups entries made since the given time, sorted by group,
+// followed by the total
+func rollupUsage(entries []ledgerEntry, by string, since time.Time) []*usageRollup {
+	groups := make(map[string]*usageRollup)
+	total := &usageRollup{Group: "total"}
+	for _, e := range entries {
+		if e.Time.Before(since) {
+			continue
+		}
+		key := usageKey(e, by)
+		if groups[key] == nil {
+			groups[key] = &usageRollup{Group: key}
+		}
+		groups[key].add(e)
+		total.add(e)
+	}
+
+	rollups := make([]*usageRollup, 0, len(groups)+1)
+	for _, g := range groups {
+		rollups = append(rollups, g)
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Group < rollups[j].Group })
+	return append(rollups, total)
+}
+
+// writeUsage renders a rollup as a Markdown table
+func writeUsage(out io.Writer, rollups []*usageRollup, by string) {
+	fmt.Fprintf(out, "| %s | Requests | Input tokens | Output tokens | Thinking tokens | Cache writes | Cache reads | Cost (USD) |\n", by)
+	fmt.Fprintln(out, "|---|---:|---:|---:|---:|---:|---:|---:|")
+	for _, r := range rollups {
+		cost := "n/a"
+		if r.PricedRequests > 0 {
+			cost = fmt.Sprintf("%.2f", r.Cost)
+			if r.PricedRequests < r.Requests {
+				cost += fmt.Sprintf(" (%d of %d priced)", r.PricedRequests, r.Requests)
+			}
+		}
+		u := r.Usage
+		fmt.Fprintf(out, "| %s | %d | %d | %d | %d | %d | %d | %s |\n", r.Group, r.Requests,
+			u.InputTokens, u.OutputTokens, u.ThinkingTokens, u.CacheCreationInputTokens, u.CacheReadInputTokens, cost)
+	}
+}
+
+// parseSince parses -since: a number of days ("30d"), a duration ("12h")
+// or a date ("2026-01-31"), relative to now
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -since %q (want e.g. 30d, 12h or 2026-01-31)", value)
+}
+
+// runUsage implements `pr-review usage`, rolling up the usage ledger
+func runUsage(args []string) {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	addPathFlags(fs)
+	by := fs.String("by", usageByDay, "Group requests by: day, week, month, repo or model")
+	since := fs.String("since", "", "Only count requests since this long ago (30d, 12h) or this date (2026-01-31)")
+	repoOnly := fs.Bool("repo", false, "Only count requests for the current repository")
+	parseFlags(fs, fs.Name(), args)
+
+	switch *by {
+	case usageByDay, usageByWeek, usageByMonth, usageByRepo, usageByModel:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid -by %q (want day, week, month, repo or model)\n", *by)
+		os.Exit(2)
+	}
+	from, err := parseSince(*since, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	dir, err := dataDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding usage ledger: %v\n", err)
+		os.Exit(1)
+	}
+	entries, err := readLedger(filepath.Join(dir, ledgerFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading usage ledger: %v\n", err)
+		os.Exit(1)
+	}
+	if *repoOnly {
+		repo := getRepoIdentity()
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.Repo == repo {
+				kept = append(kept, e)
+			}
+		}
+		entries = kept
+	}
+	rollups := rollupUsage(entries, *by, from)
+	if rollups[len(rollups)-1].Requests == 0 {
+		fmt.Println("No usage recorded yet.")
+		return
+	}
+	writeUsage(os.Stdout, rollups, *by)
+}
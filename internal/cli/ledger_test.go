@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestUsageLedger tests recording each successful request in the ledger
+func TestUsageLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", ledgerFile)
+	l := &usageLedger{path: path, command: "review", provider: "anthropic", repo: "github.com/acme/app", branch: "retry"}
+
+	usage := Usage{InputTokens: 1000000, OutputTokens: 200000, ThinkingTokens: 50000}
+	l.record("claude-sonnet-4-5", usage)
+	l.setTarget("github.com/acme/lib", "main")
+	l.record("claude-sonnet-4-5", usage)
+
+	entries, err := readLedger(path)
+	if err != nil {
+		t.Fatalf("readLedger() returned error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("ledger has %d entries, want 2", len(entries))
+	}
+	e := entries[0]
+	if e.Command != "review" || e.Provider != "anthropic" || e.Model != "claude-sonnet-4-5" || e.Repo != "github.com/acme/app" || e.ThinkingTokens != 50000 {
+		t.Errorf("entry = %+v", e)
+	}
+	// 1M input tokens at $3/M and 200k output tokens at $15/M
+	if e.Cost == nil || *e.Cost < 5.99 || *e.Cost > 6.01 {
+		t.Errorf("cost = %v, want 6", e.Cost)
+	}
+	if entries[1].Repo != "github.com/acme/lib" || entries[1].Branch != "main" {
+		t.Errorf("entry after setTarget = %+v", entries[1])
+	}
+
+	// A nil ledger records nothing
+	var none *usageLedger
+	none.record("m", usage)
+	if entries, err := readLedger(filepath.Join(t.TempDir(), ledgerFile)); err != nil || entries != nil {
+		t.Errorf("readLedger() of a missing ledger = %v, %v", entries, err)
+	}
+}
+
+// TestRollupUsage tests grouping ledger entries and rendering the table
+func TestRollupUsage(t *testing.T) {
+	cost := func(c float64) *float64 { return &c }
+	entries := []ledgerEntry{
+		{Time: time.Date(2026, 9, 28, 10, 0, 0, 0, time.UTC), Repo: "github.com/acme/app", Model: "claude-sonnet-4-5", InputTokens: 100, OutputTokens: 10, Cost: cost(0.5)},
+		{Time: time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC), Repo: "github.com/acme/app", Model: "local-model", InputTokens: 200, OutputTokens: 20},
+		{Time: time.Date(2026, 10, 2, 10, 0, 0, 0, time.UTC), Repo: "github.com/acme/lib", Model: "claude-sonnet-4-5", InputTokens: 300, OutputTokens: 30, Cost: cost(1.25)},
+	}
+
+	rollups := rollupUsage(entries, usageByRepo, time.Time{})
+	if len(rollups) != 3 || rollups[0].Group != "github.com/acme/app" || rollups[0].Requests != 2 || rollups[0].PricedRequests != 1 {
+		t.Fatalf("rollupUsage() by repo = %+v", rollups)
+	}
+	if total := rollups[2]; total.Group != "total" || total.Requests != 3 || total.Usage.InputTokens != 600 || total.Cost != 1.75 {
+		t.Errorf("total = %+v", total)
+	}
+	if rollups := rollupUsage(entries, usageByMonth, time.Time{}); rollups[0].Group != "2026-09" || rollups[1].Group != "2026-10" {
+		t.Errorf("rollupUsage() by month = %+v", rollups)
+	}
+	if rollups := rollupUsage(entries, usageByDay, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)); len(rollups) != 3 || rollups[2].Requests != 2 {
+		t.Errorf("rollupUsage() since Oct 1 = %+v", rollups)
+	}
+
+	var buf bytes.Buffer
+	writeUsage(&buf, rollups, usageByRepo)
+	for _, want := range []string{
+		"| github.com/acme/app | 2 | 300 | 30 | 0 | 0 | 0 | 0.50 (1 of 2 priced) |",
+		"| github.com/acme/lib | 1 | 300 | 30 | 0 | 0 | 0 | 1.25 |",
+		"| total | 3 | 600 | 60 | 0 | 0 | 0 | 1.75 (2 of 3 priced) |",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("table doesn't contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+// TestParseSince tests the forms -since takes
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"", time.Time{}},
+		{"7d", time.Date(2026, 10, 9, 12, 0, 0, 0, time.UTC)},
+		{"36h", time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{"2026-10-01", time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		if got, err := parseSince(tt.value, now); err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+	if _, err := parseSince("last week", now); err == nil {
+		t.Error("parseSince(\"last week\") didn't return an error")
+	}
+}
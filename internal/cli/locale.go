@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// builtinCatalogs are the translations shipped with the tool, one
+// <locale>.json per language
+//
+//go:embed locales/*.json
+var builtinCatalogs embed.FS
+
+// catalog translates the tool's messages and report headings into one
+// language. Messages are keyed by their English format string, so a
+// message missing from the catalog is shown in English.
+type catalog struct {
+	// Language is the language's English name, used to ask the model to
+	// write the review in it
+	Language string            `json:"language"`
+	Messages map[string]string `json:"messages"`
+}
+
+// messages is the catalog selected with -locale; nil means English
+var messages *catalog
+
+// locales returns the built-in locales
+func locales() []string {
+	entries, _ := builtinCatalogs.ReadDir("locales")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadCatalog returns the catalog for locale: a built-in locale such as
+// "de" or "de_DE.UTF-8" (the region and encoding are ignored unless there
+// is a catalog for the region), or the path of a JSON catalog file. English
+// has no catalog, so it returns nil.
+func loadCatalog(locale string) (*catalog, error) {
+	var data []byte
+	if strings.HasSuffix(locale, ".json") {
+		var err error
+		if data, err = os.ReadFile(locale); err != nil {
+			return nil, fmt.Errorf("failed to read catalog: %w", err)
+		}
+	} else {
+		name, _, _ := strings.Cut(strings.ReplaceAll(locale, "-", "_"), ".")
+		lang, region, _ := strings.Cut(name, "_")
+		lang = strings.ToLower(lang)
+		if lang == "en" || lang == "c" || lang == "posix" {
+			return nil, nil
+		}
+		candidates := []string{lang}
+		if region != "" {
+			candidates = []string{lang + "_" + strings.ToUpper(region), lang}
+		}
+		for _, candidate := range candidates {
+			if data, _ = builtinCatalogs.ReadFile(path.Join("locales", candidate+".json")); data != nil {
+				break
+			}
+		}
+		if data == nil {
+			return nil, fmt.Errorf("unknown locale %q (want en, %s, or a .json catalog)", locale, strings.Join(locales(), ", "))
+		}
+	}
+
+	var c catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("error parsing catalog: %w", err)
+	}
+	return &c, nil
+}
+
+// tr formats a message in the selected language
+func tr(format string, args ...any) string {
+	if messages != nil {
+		if translated, ok := messages.Messages[format]; ok {
+			format = translated
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// trPlural is plural in the selected language. Catalogs translate the
+// "%d unit" and "%d units" forms.
+func trPlural(n int, unit string) string {
+	if n == 1 {
+		return tr("%d "+unit, n)
+	}
+	return tr("%d "+unit+"s", n)
+}
+
+// languageSection asks the model to write the review in the catalog's
+// language, so the report reads in one language throughout
+func (c *catalog) languageSection() promptSection {
+	return promptSection{Title: "Review Language", Body: fmt.Sprintf(
+		"Write the review in %s, including the titles, messages and suggestions of the findings. Keep code, identifiers, the findings' JSON keys and the severity values as they are.",
+		c.Language)}
+}
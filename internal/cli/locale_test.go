@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// translatedMessages returns the messages passed to tr and trPlural in the
+// package's source
+func translatedMessages(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	consts := map[string]string{"ungroundedNotice": ungroundedNotice}
+	seen := map[string]bool{}
+	var keys []string
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			fn, ok := call.Fun.(*ast.Ident)
+			if !ok || (fn.Name != "tr" && fn.Name != "trPlural") {
+				return true
+			}
+			arg := call.Args[0]
+			if fn.Name == "trPlural" {
+				arg = call.Args[1]
+			}
+			switch arg := arg.(type) {
+			case *ast.BasicLit:
+				s, _ := strconv.Unquote(arg.Value)
+				if fn.Name == "trPlural" {
+					add("%d " + s)
+					add("%d " + s + "s")
+				} else {
+					add(s)
+				}
+			case *ast.Ident:
+				if s, ok := consts[arg.Name]; ok {
+					add(s)
+				}
+			}
+			return true
+		})
+	}
+	// Severity group titles are built from the severity names
+	for s := SeverityInfo; s <= SeverityCritical; s++ {
+		name := s.String()
+		add(strings.ToUpper(name[:1]) + name[1:])
+	}
+	return keys
+}
+
+var verbPattern = regexp.MustCompile(`%(\[\d+\])?[sd]`)
+
+// sampleArgs returns a distinct argument for each verb of format
+func sampleArgs(format string) []any {
+	var args []any
+	for i, verb := range verbPattern.FindAllString(format, -1) {
+		if strings.HasSuffix(verb, "d") {
+			args = append(args, 1000+i)
+		} else {
+			args = append(args, fmt.Sprintf("ARG%d", i))
+		}
+	}
+	return args
+}
+
+// TestBuiltinCatalogs tests that every built-in catalog translates every
+// message, using each of its arguments
+func TestBuiltinCatalogs(t *testing.T) {
+	keys := translatedMessages(t)
+	if len(keys) < 20 {
+		t.Fatalf("found only %d translated messages in the source", len(keys))
+	}
+	for _, locale := range locales() {
+		c, err := loadCatalog(locale)
+		if err != nil {
+			t.Fatalf("loadCatalog(%q) returned error: %v", locale, err)
+		}
+		if c.Language == "" {
+			t.Errorf("%s: no language", locale)
+		}
+		for _, key := range keys {
+			translated, ok := c.Messages[key]
+			if !ok {
+				t.Errorf("%s: %q not translated", locale, key)
+				continue
+			}
+			args := sampleArgs(key)
+			got := fmt.Sprintf(translated, args...)
+			if strings.Contains(got, "%!") {
+				t.Errorf("%s: %q formats as %q", locale, key, got)
+			}
+			for _, arg := range args {
+				if !strings.Contains(got, fmt.Sprint(arg)) {
+					t.Errorf("%s: %q drops argument %v: %q", locale, key, arg, got)
+				}
+			}
+		}
+	}
+}
+
+// TestLoadCatalog tests locale names, catalog files and falling back to
+// English
+func TestLoadCatalog(t *testing.T) {
+	for _, locale := range []string{"en", "en_US.UTF-8", "C"} {
+		if c, err := loadCatalog(locale); c != nil || err != nil {
+			t.Errorf("loadCatalog(%q) = %v, %v, want English", locale, c, err)
+		}
+	}
+	for _, locale := range []string{"de", "de_DE.UTF-8", "de-AT"} {
+		if c, err := loadCatalog(locale); err != nil || c.Language != "German" {
+			t.Errorf("loadCatalog(%q) = %v, %v, want German", locale, c, err)
+		}
+	}
+	if _, err := loadCatalog("xx"); err == nil || !strings.Contains(err.Error(), "de, es, fr, ja") {
+		t.Errorf("loadCatalog(xx) error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "pirate.json")
+	catalogJSON := `{"language": "Pirate", "messages": {"Findings": "Plunder", "%d finding": "%d doubloon", "%d findings": "%d doubloons"}}`
+	if err := os.WriteFile(path, []byte(catalogJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadCatalog(path)
+	if err != nil {
+		t.Fatalf("loadCatalog(%q) returned error: %v", path, err)
+	}
+	messages = c
+	t.Cleanup(func() { messages = nil })
+	if got := tr("Findings"); got != "Plunder" {
+		t.Errorf("tr(Findings) = %q", got)
+	}
+	if got := trPlural(3, "finding"); got != "3 doubloons" {
+		t.Errorf("trPlural(3, finding) = %q", got)
+	}
+	// Messages the catalog lacks stay in English
+	if got := tr("Suggestion: %s", "x"); got != "Suggestion: x" {
+		t.Errorf("tr(Suggestion) = %q", got)
+	}
+	if got := renderFindings([]Finding{{Severity: SeverityHigh, Title: "t"}}, groupBySeverity); !strings.HasPrefix(got, "## Plunder\n") {
+		t.Errorf("renderFindings() = %q", got)
+	}
+	if section := c.languageSection(); !strings.Contains(section.Body, "in Pirate") {
+		t.Errorf("languageSection() = %q", section.Body)
+	}
+}
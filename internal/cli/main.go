@@ -0,0 +1,1583 @@
+// Package cli is the pr-review command: its flags, subcommands and the
+// orchestration around a review. cmd/pr-review runs it.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+	"github.com/marete/pr-review/pkg/llm"
+	"github.com/marete/pr-review/pkg/prompt"
+	"github.com/marete/pr-review/pkg/review"
+)
+
+const (
+	// staleReviewAge is how old a previous review of the same commits can be
+	// before we warn that re-running it might give a different result
+	staleReviewAge = 7 * 24 * time.Hour
+)
+
+// subcommands are dispatched on the first argument; anything else runs a review
+var subcommands = map[string]func(args []string){
+	"bisect":     runBisect,
+	"compare":    runCompare,
+	"clean":      runClean,
+	"completion": runCompletion,
+	"baseline":   runBaseline,
+	"config":     runConfig,
+	"handoff":    runHandoff,
+	"history":    runHistory,
+	"hook":       runHook,
+	"usage":      runUsage,
+	"paths":      runPaths,
+	"policy":     runPolicy,
+	"postmortem": runPostmortem,
+	"series":     runSeries,
+	"show":       runShow,
+	"stats":      runStats,
+	"triage":     runTriage,
+}
+
+// Main runs pr-review with the command line in os.Args
+func Main() {
+	if len(os.Args) > 1 {
+		// The completion scripts' hidden command lists the subcommands, so
+		// it can't be one of them
+		if os.Args[1] == "__complete" {
+			runComplete(os.Args[2:])
+			return
+		}
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+	runReview()
+}
+
+// commonFlags are the options shared by the review and its subcommands
+type commonFlags struct {
+	branch         *string
+	base           *string
+	model          *string
+	noThinking     *bool
+	thinkingBudget *int
+	maxTokens      *int
+	contextFiles   *string
+	transcript     *string
+	continuations  *int
+	uploadOver     *int
+	providerName   *string
+	maxAttempts    *int
+	userID         *string
+	noPromptCache  *bool
+	headers        headerFlags
+
+	// command names the command in the usage ledger
+	command string
+
+	// profileName is -profile; profile is the profile applied, if any
+	fs             *flag.FlagSet
+	profileName    *string
+	profile        *Profile
+	profileUsed    string
+	profileApplied bool
+
+	// log is the transcript recorded with -transcript, once the provider is
+	// set up
+	log *transcript
+
+	// streamIdleTimeout is -stream-idle-timeout, for the review, which
+	// streams
+	streamIdleTimeout time.Duration
+}
+
+func addCommonFlags(fs *flag.FlagSet) *commonFlags {
+	addPathFlags(fs)
+	c := &commonFlags{
+		command:        fs.Name(),
+		fs:             fs,
+		profileName:    fs.String("profile", "", "Profile from the global config to use: its provider, model, API key source and prompt sections, unless set otherwise (default: default_profile in the global config)"),
+		branch:         fs.String("branch", "", "Target branch to compare against (default: the pull request's target branch in CI, otherwise main or master)"),
+		base:           fs.String("base", "", "Base branch/commit to compare from"),
+		model:          fs.String("model", "", "Model to use (default: claude-sonnet-4-5-20250929; gpt-4o with -provider openai; us.anthropic.claude-sonnet-4-5-20250929-v1:0 with -provider bedrock); the deployment name with -provider azure"),
+		providerName:   fs.String("provider", providerAnthropic, "LLM provider: anthropic (ANTHROPIC_API_KEY), openai (OPENAI_API_KEY, and OPENAI_BASE_URL for compatible servers), azure (AZURE_OPENAI_ENDPOINT, and AZURE_OPENAI_API_KEY or Entra ID) or bedrock (AWS credentials and AWS_REGION)"),
+		noThinking:     fs.Bool("no-ultrathink", false, "Disable extended thinking mode"),
+		thinkingBudget: fs.Int("thinking-budget", 10000, "Extended thinking token budget"),
+		maxTokens:      fs.Int("max-tokens", 64000, "Maximum output tokens (default: 64000, max: 64000)"),
+		contextFiles:   fs.String("context", "", "Comma-separated list of additional context files to include"),
+		continuations:  fs.Int("max-continuations", 3, "Follow-up requests allowed to fetch the rest of a response cut off at -max-tokens (0 disables)"),
+		transcript:     fs.String("transcript", "", "Save every API request and response (redacted) to this JSON file for audit; gzipped if it ends in .gz"),
+		maxAttempts:    fs.Int("max-attempts", 4, "Times to try an API request that fails with a rate limit, overload or server error, backing off between attempts (1 disables retries)"),
+		uploadOver:     fs.Int("upload-context-over", 100, "Upload -context files larger than this many KiB with the Files API instead of inlining them (0 disables)"),
+		noPromptCache:  fs.Bool("no-prompt-cache", false, "Don't cache the diff and context with Anthropic prompt caching (on Anthropic and Bedrock)"),
+		userID:         fs.String("user-id", "", "Opaque identifier of the user or run sent with API requests for usage attribution (default: user_id in the global config)"),
+	}
+	fs.Var(&c.headers, "header", "Extra HTTP header for API requests, as \"Name: value\" (repeatable; adds to the provider's headers in the global config)")
+	return c
+}
+
+// targetBranch returns -branch, or in a CI job for a pull request the
+// branch it targets, or the repository's default branch
+func (c *commonFlags) targetBranch() string {
+	if *c.branch != "" {
+		return *c.branch
+	}
+	if branch, _ := ciTargetBranch(); branch != "" {
+		return branch
+	}
+	return getDefaultBranch()
+}
+
+// baseRef returns the ref the changes are compared against: -base if given,
+// otherwise the target branch
+func (c *commonFlags) baseRef() string {
+	if *c.base != "" {
+		return *c.base
+	}
+	return c.targetBranch()
+}
+
+// branchChanges is the diff under review with its surrounding git context
+type branchChanges struct {
+	BaseRef        string
+	Diff           string
+	ChangedFiles   string
+	CommitMessages string
+}
+
+// defaultContextLines is how many lines of context git diff shows around
+// each change by default
+const defaultContextLines = 3
+
+// collectChanges gathers the diff, changed files and commit log of head
+// against baseRef. diffOptions are passed on to git diff.
+func collectChanges(baseRef, head string, diffOptions ...string) (*branchChanges, error) {
+	return collectRange(baseRef, "...", head, diffOptions...)
+}
+
+// parseRevisionRange splits a revision range, "A..B" or "A...B", into its
+// ends and dots. An end left out is HEAD, as in git.
+func parseRevisionRange(spec string) (base, dots, head string, err error) {
+	i := strings.Index(spec, "..")
+	if i == -1 {
+		return "", "", "", fmt.Errorf("invalid range %q (want A..B or A...B)", spec)
+	}
+	base, dots, head = spec[:i], "..", spec[i+2:]
+	if strings.HasPrefix(head, ".") {
+		dots, head = "...", head[1:]
+	}
+	if strings.Contains(head, "..") || strings.HasPrefix(head, ".") || base == "" && head == "" {
+		return "", "", "", fmt.Errorf("invalid range %q (want A..B or A...B)", spec)
+	}
+	if base == "" {
+		base = "HEAD"
+	}
+	if head == "" {
+		head = "HEAD"
+	}
+	return base, dots, head, nil
+}
+
+// collectRange gathers the diff, changed files and commit log of a
+// revision range: with dots "..", the diff between baseRef and head, with
+// "...", of head against their merge base. The log lists the commits on
+// head that aren't on baseRef either way.
+func collectRange(baseRef, dots, head string, diffOptions ...string) (*branchChanges, error) {
+	git := gitdiff.Git(gitCommand)
+	diff, err := git.DiffRange(baseRef+dots+head, diffOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Legacy-encoded files would otherwise reach the model as mojibake
+	diff, notes := sanitizeDiff(diff)
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", note)
+	}
+	return &branchChanges{
+		BaseRef:        baseRef,
+		Diff:           diff,
+		ChangedFiles:   git.ChangedFilesRange(baseRef + dots + head),
+		CommitMessages: git.Log(baseRef, head),
+	}, nil
+}
+
+// collectUncommitted gathers the changes staged in the index or, with staged
+// false, the working tree's changes not yet staged. diffOptions are passed
+// on to git diff.
+func collectUncommitted(staged bool, diffOptions ...string) (*branchChanges, error) {
+	diff, files, err := gitdiff.Git(gitCommand).Uncommitted(staged, diffOptions...)
+	if err != nil {
+		return nil, err
+	}
+	diff, notes := sanitizeDiff(diff)
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", note)
+	}
+	return &branchChanges{BaseRef: "HEAD", Diff: diff, ChangedFiles: files}, nil
+}
+
+// readContext reads the -context files. Files larger than
+// -upload-context-over are uploaded with the Files API, redacted, and
+// attached to the client's requests instead of being inlined; an upload
+// is reused for as long as the file's content doesn't change.
+func (c *commonFlags) readContext(provider Provider, policy *Policy) string {
+	limit := *c.uploadOver * 1024
+	client, canUpload := provider.(llm.FileAttacher)
+	var cache *fileCache
+	defer func() {
+		if cache != nil {
+			cache.close()
+		}
+	}()
+	return readContextFiles(*c.contextFiles, func(file string, content []byte) bool {
+		if !canUpload || limit <= 0 || len(content) <= limit {
+			return false
+		}
+		if cache == nil {
+			cache = loadFileCache()
+		}
+		reused, err := client.AttachFile(file, []byte(policy.redact(string(content))), cache)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not upload context file %s, including it inline: %v\n", file, err)
+			return false
+		}
+		how := "uploaded"
+		if reused {
+			how = "reusing earlier upload"
+		}
+		fmt.Printf("📎 Attached %s with the Files API (%s)\n", file, how)
+		return true
+	})
+}
+
+// readContextFiles reads a comma-separated list of files to include as
+// additional context, warning about (and skipping) unreadable ones. Files
+// that attach takes (if set) are referenced rather than inlined.
+func readContextFiles(list string, attach func(file string, content []byte) bool) string {
+	if list == "" {
+		return ""
+	}
+	additionalContext := ""
+	for _, file := range strings.Split(list, ",") {
+		file = strings.TrimSpace(file)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not read context file %s: %v\n", file, err)
+			continue
+		}
+		if attach != nil && attach(file, content) {
+			additionalContext += fmt.Sprintf("\n\n--- Context from %s is attached as a document ---\n", file)
+			continue
+		}
+		additionalContext += fmt.Sprintf("\n\n--- Context from %s ---\n%s\n", file, string(content))
+	}
+	return additionalContext
+}
+
+func runReview() {
+	// Command line flags
+	common := addCommonFlags(flag.CommandLine)
+	outputFile := flag.String("output", "REQUESTED_CHANGES.md", "Output file for review (will create numbered backups if exists)")
+	format := flag.String("format", formatMarkdown, "Report format: markdown, or json, sarif, gh-annotations (GitHub Actions workflow commands) codeclimate (GitLab Code Quality) or junit to print a machine-readable review to stdout")
+	post := flag.String("post", "", "Also post the review: github or gitea (as a pull request comment, using GITHUB_TOKEN or GITEA_TOKEN), bitbucket (as a comment and Code Insights report, using BITBUCKET_TOKEN) or gerrit (with findings as robot comments, on GERRIT_URL)")
+	inline := flag.Bool("inline", false, "With -post github or gitea, post a pull request review with each finding as an inline comment on its line")
+	prNumber := flag.Int("pr", 0, "Fetch and review this GitHub, Gitea or Bitbucket pull request instead of the current branch (also where -post posts)")
+	changeID := flag.String("change", "", "Fetch and review this Gerrit change (number or Change-Id) instead of the current branch (also where -post gerrit posts)")
+	offline := flag.Bool("offline-git", false, "Never fetch from git remotes; -pr and -change use refs fetched beforehand, and missing refs are reported with the command to fetch them")
+	checklistFile := flag.String("checklist", "", "Also write the reviewer checklist to this file, e.g. to paste into the PR description")
+	include := flag.String("include", "", "Only review the files matching one of these comma-separated globs, e.g. 'src/**'")
+	exclude := flag.String("exclude", "", "Leave the files matching these comma-separated globs out of the review, e.g. 'vendor/**,**/*.min.js', as well as those excluded in "+repoConfigFile+" and "+reviewIgnoreFile+" (a glob starting with ! reviews files "+reviewIgnoreFile+" leaves out)")
+	includeGenerated := flag.Bool("include-generated", false, "Review generated files too: those marked linguist-generated in .gitattributes, with a \"Code generated ... DO NOT EDIT\" marker, or with well-known names such as go.sum, package-lock.json and *.pb.go")
+	maxFileDiff := ByteSize(defaultMaxFileDiff)
+	flag.Var(&maxFileDiff, "max-file-diff", "Show a file whose diff is larger than this (e.g. 256KB, the default) as a one-line summary, as binary files are (0: no limit)")
+	maxDiffTokens := flag.Int("max-diff-tokens", 0, "Leave parts of a diff of more than this many estimated tokens out, as -truncate says, and list them in the report (0: no limit)")
+	truncateFlag := flag.String("truncate", defaultTruncate, "Comma-separated steps to shrink a diff over -max-diff-tokens, taken in order until it fits: context (trim context lines), hunks (drop the largest hunks) or files (drop the largest files)")
+	withFullFiles := flag.Bool("full-files", false, "Add the full contents after the change of each file the diff modifies to the prompt, so the model sees how changed code is used elsewhere in the file")
+	fullFilesMax := ByteSize(defaultFullFilesMax)
+	flag.Var(&fullFilesMax, "full-files-max", "Most contents -full-files adds to the prompt, e.g. 512KB (the default); files that don't fit are left out")
+	contextLines := flag.Int("context-lines", defaultContextLines, "Lines of context to show around each change in the diff (git diff -U<n>); context_lines in "+repoConfigFile+" sets the default")
+	since := flag.String("since", "", "Review only what changed since this reviewed state: a commit, or the ID of a review in the history (the run_id of history export), and refer to that review's findings")
+	incremental := flag.Bool("incremental", false, "Review only the commits added since the branch was last reviewed, referring to that review's findings, and append the review to -output instead of replacing it")
+	patchFile := flag.String("patch", "", "Review the unified diff or format-patch mail in this file instead of the branch; no git repository is needed")
+	fromStdin := flag.Bool("stdin", false, "Review a unified diff or format-patch mail read from standard input instead of the branch, as -patch does")
+	revisions := flag.String("range", "", "Review this revision range instead of the branch: A..B for the diff between A and B (e.g. two release tags), A...B for B's changes since it forked from A")
+	headRev := flag.String("head", "", "Review this branch or commit against the base instead of HEAD, e.g. a branch that isn't checked out")
+	commit := flag.String("commit", "", "Review just this commit against its first parent, with its full commit message, instead of the branch")
+	staged := flag.Bool("staged", false, "Review the changes staged for the next commit (git diff --cached) instead of the branch")
+	workingTree := flag.Bool("working-tree", false, "Review the working tree's changes not yet staged (git diff) instead of the branch")
+	functionContext := flag.Bool("function-context", false, "Show the whole function around each change in the diff (git diff --function-context), instead of 3 lines of context")
+	groupBy := flag.String("group-by", groupBySeverity, "Group findings in the report by: severity, file or category")
+	force := flag.Bool("force", false, "Review even if this head and base were already reviewed")
+	quick := flag.Bool("quick", false, "Give a fast, cheap summary-level review of the serious issues only, e.g. before a push: the provider's small model unless -model is given, no extended thinking and a smaller -max-tokens")
+	noCache := flag.Bool("no-cache", false, "Call the model even if it reviewed the same diff, context and instructions in the last 30 days, instead of returning the cached review")
+	benchOld := flag.String("bench-old", "", "Benchmark results (go test -bench output) from before the change")
+	benchNew := flag.String("bench-new", "", "Benchmark results (go test -bench output) from after the change")
+	pprofFile := flag.String("pprof", "", "CPU or heap profile (pprof format) used to assess performance risk on hot paths")
+	goVerify := flag.Bool("go-verify", false, "Run go build and go vet on the head commit and include any errors in the review")
+	noPreReview := flag.Bool("no-pre-review", false, "Do not run the pre_review commands from the repository config")
+	noRolloutPlan := flag.Bool("no-rollout-plan", false, "Skip the rollout and revert plan assessment when migration, config or flag files change")
+	noBaseline := flag.Bool("no-baseline", false, "Report and gate on the findings in "+baselineFile+" too")
+	noFlakyCheck := flag.Bool("no-flaky-check", false, "Skip the dedicated flaky-test pass when tests change (static checks still run)")
+	failOnFlag := flag.String("fail-on", "", "Exit with status 1 if a finding is at least this severe: info, low, medium, high or critical, or a comma-separated list of them to gate on the least severe (e.g. critical,high), or none; overrides fail_on in "+repoConfigFile)
+	changeTypeFlag := flag.String("change-type", "", "Review as this type of change instead of inferring it: "+strings.Join(changeTypes, ", "))
+	prescreenFlag := flag.Bool("prescreen", false, "Rate each changed file's risk with a cheap model first, and only give the files rated high risk the deep review")
+	prescreenModel := flag.String("prescreen-model", "", "Model for -prescreen (default: claude-haiku-4-5-20251001; gpt-4o-mini with -provider openai; us.anthropic.claude-haiku-4-5-20251001-v1:0 with -provider bedrock); required with -provider azure")
+	timeout := flag.Duration("timeout", 0, "Give up on the review if it takes longer than this, exiting with status 5 as if cancelled (0: no limit)")
+	hookName := flag.String("hook", "", "Run as the git hook named: pre-commit gives the staged changes a -quick review, within a -timeout of 60s and gating on critical findings unless those flags are given, and prints only the serious findings")
+	noStream := flag.Bool("no-stream", false, "Don't show the review in the terminal as it is written; wait for the whole response")
+	idleTimeout := flag.Duration("stream-idle-timeout", defaultStreamIdleTimeout, "Give up on a streamed review that sends nothing for this long and send it again without streaming (0: wait up to the 30-minute limit)")
+	compare := flag.String("compare", "", "Review with each of these comma-separated models at once (on -provider) and report where they agree and differ, with each model's token usage")
+	splitOver := flag.Int("split-over", defaultSplitOver, "Review a diff of more than this many estimated tokens in parts of at most -max-chunk-tokens, split by -chunk-strategy, then merge the parts' reviews (0: never split)")
+	splitWorkers := flag.Int("split-workers", defaultSplitWorkers, "Number of parts of a split review to review at once")
+	chunkStrategy := flag.String("chunk-strategy", chunkByFile, "How to split a diff of more than -split-over tokens into parts: file, hunk (also splitting large files by hunk) or none (never split)")
+	maxChunkTokens := flag.Int("max-chunk-tokens", 0, "Most estimated tokens of diff in each part of a split review (0: -split-over)")
+	maxCost := flag.Float64("max-cost", 0, "Stop before sending the review if its estimated cost exceeds this many US dollars (0: no limit)")
+	maxInputTokens := flag.Int("max-input-tokens", 0, "Stop before sending the review if its prompt exceeds this many input tokens (0: no limit)")
+	budgetModel := flag.String("budget-model", "", "Cheaper model to review with when the review would exceed -max-cost or -max-input-tokens, instead of stopping")
+	yes := flag.Bool("yes", false, "Don't ask to confirm the estimated cost before sending the review (it is only asked in a terminal, and always shown)")
+	failFast := addUnitFlags(flag.CommandLine)
+	statusFile := flag.String("status-file", "", "Write the outcome of the run (status, exit code, findings by severity, token usage) to this JSON file, whether or not it succeeds")
+	locale := flag.String("locale", "en", "Language of the report and progress messages: en, "+strings.Join(locales(), ", ")+", or a .json message catalog; the model is asked to write the review in it too")
+	parseFlags(flag.CommandLine, "", os.Args[1:])
+	started := time.Now()
+	offlineGit = *offline
+	common.streamIdleTimeout = *idleTimeout
+	run.path = *statusFile
+	exitOnCancel()
+	if *hookName != "" {
+		if err := applyHookMode(flag.CommandLine, *hookName); err != nil {
+			fail(exitUsage, "Error: invalid -hook: %v", err)
+		}
+	}
+	if *timeout < 0 {
+		fail(exitUsage, "Error: -timeout must not be negative")
+	}
+	exitOnTimeout(*timeout)
+
+	var err error
+	if messages, err = loadCatalog(*locale); err != nil {
+		fail(exitUsage, "Error: invalid -locale: %v", err)
+	}
+
+	// The repository's config has defaults for flags not given on the
+	// command line, unless a commit or comparison of another repository is
+	// under review
+	if root := getRepoRoot(); root != "" && flag.NArg() == 0 {
+		repoCfg, err := loadConfig(filepath.Join(root, repoConfigFile))
+		if err != nil {
+			fail(exitUsage, "Error loading config: %v", err)
+		}
+		if err := repoCfg.applyDefaults(flag.CommandLine, root); err != nil {
+			fail(exitUsage, "Error: %s: %v", repoConfigFile, err)
+		}
+	}
+	common.applyProfile()
+
+	if err := validateGroupBy(*groupBy); err != nil {
+		fail(exitUsage, "Error: %v", err)
+	}
+	if *idleTimeout < 0 {
+		fail(exitUsage, "Error: -stream-idle-timeout must not be negative")
+	}
+	if err := validateFormat(*format); err != nil {
+		fail(exitUsage, "Error: %v", err)
+	}
+	var failOn *Severity
+	if *failOnFlag != "" {
+		if failOn, err = parseFailOn(*failOnFlag); err != nil {
+			fail(exitUsage, "Error: invalid -fail-on: %v", err)
+		}
+	}
+	if *changeTypeFlag != "" {
+		if err := validateChangeType(*changeTypeFlag); err != nil {
+			fail(exitUsage, "Error: invalid -change-type: %v", err)
+		}
+	}
+	if *contextLines < 0 {
+		fail(exitUsage, "Error: invalid -context-lines %d (want 0 or more lines)", *contextLines)
+	}
+	if *maxDiffTokens < 0 {
+		fail(exitUsage, "Error: invalid -max-diff-tokens %d (want 0 or more tokens)", *maxDiffTokens)
+	}
+	truncate, err := parseTruncateSteps(*truncateFlag)
+	if err != nil {
+		fail(exitUsage, "Error: %v", err)
+	}
+	if *splitOver < 0 {
+		fail(exitUsage, "Error: invalid -split-over %d (want 0 or more tokens)", *splitOver)
+	}
+	if *splitWorkers < 1 {
+		fail(exitUsage, "Error: invalid -split-workers %d (want at least 1)", *splitWorkers)
+	}
+	if err := validateChunkStrategy(*chunkStrategy); err != nil {
+		fail(exitUsage, "Error: %v", err)
+	}
+	if *maxChunkTokens < 0 {
+		fail(exitUsage, "Error: invalid -max-chunk-tokens %d (want 0 or more tokens)", *maxChunkTokens)
+	}
+	if *maxChunkTokens == 0 {
+		*maxChunkTokens = *splitOver
+	}
+	var compareList []string
+	if *compare != "" {
+		if compareList, err = parseModelList(*compare); err != nil {
+			fail(exitUsage, "Error: invalid -compare: %v", err)
+		}
+		if *post != "" || *format != formatMarkdown {
+			fail(exitUsage, "Error: -compare writes a Markdown report and can't be combined with -post or -format")
+		}
+		if *prescreenFlag {
+			fail(exitUsage, "Error: -compare gives every model the whole change and can't be combined with -prescreen")
+		}
+	}
+	if *post != "" && *post != postGitHub && *post != postBitbucket && *post != postGerrit && *post != postGitea {
+		fail(exitUsage, "Error: invalid -post %q (want github, bitbucket, gerrit or gitea)", *post)
+	}
+	if *post == postGitHub && githubToken() == "" {
+		fail(exitUsage, "Error: -post github requires the GITHUB_TOKEN environment variable")
+	}
+	if *post == postGitea && giteaToken() == "" {
+		fail(exitUsage, "Error: -post gitea requires the GITEA_TOKEN environment variable")
+	}
+	if *post == postBitbucket && bitbucketToken() == "" {
+		fail(exitUsage, "Error: -post bitbucket requires the BITBUCKET_TOKEN environment variable")
+	}
+	if (*post == postGerrit || *changeID != "") && os.Getenv("GERRIT_URL") == "" {
+		fail(exitUsage, "Error: -post gerrit and -change require the GERRIT_URL environment variable")
+	}
+	if *inline && (*post == postBitbucket || *post == postGerrit) {
+		fail(exitUsage, "Error: -inline is only supported with -post github or gitea; Bitbucket and Gerrit always get findings on their lines")
+	}
+	if *changeID != "" && *prNumber != 0 {
+		fail(exitUsage, "Error: -change and -pr can't be used together")
+	}
+	if (*changeID != "" && *post != "" && *post != postGerrit) || (*prNumber != 0 && *post == postGerrit) {
+		fail(exitUsage, "Error: Gerrit changes are reviewed with -change and posted with -post gerrit; pull requests use -pr")
+	}
+	uncommitted := *staged || *workingTree
+	if (*commit != "" || *revisions != "" || *headRev != "") && (uncommitted || flag.NArg() > 0 || *prNumber != 0 || *changeID != "" || *post != "") {
+		fail(exitUsage, "Error: -commit, -range and -head can't be combined with -staged, -working-tree, a URL, -pr, -change or -post")
+	}
+	patchMode := *patchFile != "" || *fromStdin
+	if patchMode && (*commit != "" || *revisions != "" || *headRev != "" || uncommitted || flag.NArg() > 0 || *prNumber != 0 || *changeID != "" || *post != "") {
+		fail(exitUsage, "Error: -patch and -stdin can't be combined with -commit, -range, -head, -staged, -working-tree, a URL, -pr, -change or -post")
+	}
+	if *since != "" && *incremental {
+		fail(exitUsage, "Error: -since and -incremental can't be used together")
+	}
+	if *incremental && (patchMode || uncommitted || *commit != "" || *revisions != "" || flag.NArg() > 0 || compareList != nil) {
+		fail(exitUsage, "Error: -incremental can't be combined with -patch, -stdin, -staged, -working-tree, -commit, -range, -compare or a URL")
+	}
+	if *since != "" && (patchMode || uncommitted || *commit != "" || *revisions != "" || flag.NArg() > 0) {
+		fail(exitUsage, "Error: -since can't be combined with -patch, -stdin, -staged, -working-tree, -commit, -range or a URL")
+	}
+	if *patchFile != "" && *fromStdin {
+		fail(exitUsage, "Error: -patch and -stdin can't be used together")
+	}
+	if patchMode && *withFullFiles {
+		fail(exitUsage, "Error: -full-files needs the changed files, which a patch doesn't include")
+	}
+	if *commit != "" && *revisions != "" || *headRev != "" && (*commit != "" || *revisions != "") {
+		fail(exitUsage, "Error: only one of -commit, -range and -head can be used")
+	}
+	rangeDots := "..."
+	var rangeBase, rangeHead string
+	if *revisions != "" {
+		if rangeBase, rangeDots, rangeHead, err = parseRevisionRange(*revisions); err != nil {
+			fail(exitUsage, "Error: -range: %v", err)
+		}
+	}
+	if *staged && *workingTree {
+		fail(exitUsage, "Error: -staged and -working-tree can't be used together")
+	}
+	if uncommitted && (flag.NArg() > 0 || *prNumber != 0 || *changeID != "" || *post != "") {
+		fail(exitUsage, "Error: -staged and -working-tree review local changes, so they can't be combined with a URL, -pr, -change or -post")
+	}
+	if flag.NArg() > 1 {
+		fail(exitUsage, "Error: expected at most one commit or compare URL to review, got %d arguments", flag.NArg())
+	}
+	if flag.NArg() == 1 && (*prNumber != 0 || *changeID != "" || *post != "") {
+		fail(exitUsage, "Error: a commit or compare URL can't be combined with -pr, -change or -post")
+	}
+
+	// In the machine-readable formats stdout carries only the document, so it
+	// can be piped; progress goes to stderr
+	stdout := os.Stdout
+	if *format != formatMarkdown {
+		os.Stdout = os.Stderr
+	}
+
+	client, policy := common.provider()
+	if *quick {
+		applyQuick(flag.CommandLine, common, policy)
+	}
+	if *prescreenFlag {
+		if *prescreenModel == "" {
+			*prescreenModel = prescreenModels[strings.ToLower(*common.providerName)]
+		}
+		if *prescreenModel == "" {
+			fail(exitUsage, "Error: -prescreen with -provider azure requires the deployment name in -prescreen-model")
+		}
+		if err := policy.checkModel(*prescreenModel); err != nil {
+			fail(exitUsage, "Error: %v", err)
+		}
+	}
+	for _, model := range compareList {
+		if err := policy.checkModel(model); err != nil {
+			fail(exitUsage, "Error: %v", err)
+		}
+	}
+	if *maxCost < 0 || *maxInputTokens < 0 {
+		fail(exitUsage, "Error: -max-cost and -max-input-tokens must not be negative")
+	}
+	if *budgetModel != "" {
+		if err := policy.checkModel(*budgetModel); err != nil {
+			fail(exitUsage, "Error: %v", err)
+		}
+	}
+	limits := budgetFor(*maxCost, *maxInputTokens, policy)
+
+	// Review the current branch, a pull request fetched without checking it
+	// out, or a commit or comparison given by its GitHub URL
+	currentBranch, baseRef, head := getCurrentBranch(), common.baseRef(), "HEAD"
+	if branch, source := ciTargetBranch(); branch != "" && *common.base == "" && *common.branch == "" && flag.NArg() == 0 && *prNumber == 0 && *changeID == "" {
+		fmt.Println("🎯 " + tr("Comparing against %s, the target branch in $%s", branch, source))
+	}
+	repoRoot, repo := getRepoRoot(), getRepoIdentity()
+	var changes *branchChanges
+	var labels []string
+	if flag.NArg() == 1 {
+		fmt.Println("📥 " + tr("Fetching %s...", flag.Arg(0)))
+		target, err := fetchTargetURL(flag.Arg(0))
+		if err != nil {
+			fail(exitGit, "Error: %v", err)
+		}
+		currentBranch, baseRef, head, changes = target.Label, target.Base, target.Head, target.Changes
+		// The working directory's config only applies if it is a checkout
+		// of the same repository
+		if target.Remote != repo {
+			repoRoot, repo = "", target.Remote
+		}
+	}
+	if *prNumber != 0 {
+		fmt.Println("📥 " + tr("Fetching pull request #%d...", *prNumber))
+		target, err := fetchPullRequest(pullRequestHost(*post), *prNumber, *common.base)
+		if err != nil {
+			fail(exitGit, "Error: %v", err)
+		}
+		currentBranch, baseRef, head, labels = target.Branch, target.Base, target.Head, target.Labels
+	}
+	pr := *prNumber
+	if *changeID != "" {
+		fmt.Println("📥 " + tr("Fetching change %s...", *changeID))
+		target, err := fetchGerritChange(*changeID, *common.base)
+		if err != nil {
+			fail(exitGit, "Error: %v", err)
+		}
+		currentBranch, baseRef, head, pr = target.Branch, target.Base, target.Head, target.Number
+	}
+	if *commit != "" {
+		currentBranch, baseRef, head = "commit "+*commit, *commit+"^", *commit
+	}
+	if *revisions != "" {
+		currentBranch, baseRef, head = *revisions, rangeBase, rangeHead
+	}
+	if *headRev != "" {
+		currentBranch, head = *headRev, *headRev
+	}
+	if patchMode {
+		var data []byte
+		if *fromStdin {
+			currentBranch = "stdin"
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			currentBranch = filepath.Base(*patchFile)
+			data, err = os.ReadFile(*patchFile)
+		}
+		if err != nil {
+			fail(exitUsage, "Error reading patch: %v", err)
+		}
+		if changes, err = parsePatchFile(string(data)); err != nil {
+			fail(exitUsage, "Error reading patch %s: %v", currentBranch, err)
+		}
+		baseRef, head = "", ""
+	}
+	// Only what changed since the earlier review is diffed, against the
+	// commit it saw
+	var sinceState *sinceReview
+	prBase := baseRef
+	if *since != "" || *incremental {
+		var records []*reviewRecord
+		if history, err := openHistoryFor(repo); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Review history unavailable: %v\n", err)
+		} else {
+			if records, err = history.List(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Could not search review history: %v\n", err)
+			}
+			history.Close()
+		}
+		git := gitdiff.Git(gitCommand)
+		if *incremental {
+			reachable := func(sha string) bool { return git.IsAncestor(sha, head) }
+			if last := lastReviewed(records, repo, currentBranch, reachable); last != nil {
+				if last.HeadSHA == resolveRef(head) && !*force {
+					fmt.Println(tr("No new commits on '%s' since it was last reviewed; use -force to review it again.", currentBranch))
+					exitWith(exitOK)
+				}
+				if last.HeadSHA != resolveRef(head) {
+					sinceState = &sinceReview{SHA: last.HeadSHA, Record: last}
+				}
+			}
+		} else {
+			resolve := func(ref string) (string, bool) { return resolveRef(ref), refExists(ref) }
+			if sinceState, err = resolveSince(*since, records, resolve); err != nil {
+				fail(exitGit, "Error: -since: %v", err)
+			}
+			sinceState.Rewritten = !git.IsAncestor(sinceState.SHA, head)
+		}
+		if sinceState != nil {
+			baseRef, rangeDots = sinceState.SHA, ".."
+		}
+	}
+	switch {
+	case *staged:
+		baseRef = "HEAD"
+		fmt.Printf("🔍 %s\n\n", tr("Reviewing the changes staged on '%s'", currentBranch))
+	case *workingTree:
+		baseRef = "HEAD"
+		fmt.Printf("🔍 %s\n\n", tr("Reviewing the changes not yet staged on '%s'", currentBranch))
+	case patchMode:
+		fmt.Printf("🔍 %s\n\n", tr("Reviewing the patch %s", currentBranch))
+	case sinceState != nil:
+		fmt.Printf("🔍 %s\n\n", tr("Reviewing the changes on '%s' since %s was reviewed", currentBranch, shortSHA(sinceState.SHA)))
+		if sinceState.Record == nil {
+			fmt.Fprintf(os.Stderr, "Warning: No review of %s is in the history; reviewing the changes since it without earlier findings\n", shortSHA(sinceState.SHA))
+		}
+	default:
+		fmt.Printf("🔍 %s\n\n", tr("Reviewing changes on '%s' against '%s'", currentBranch, baseRef))
+	}
+	run.Repo, run.Branch, run.Model = repo, currentBranch, *common.model
+	ledger.setTarget(repo, currentBranch)
+
+	// Get the diff and its git context
+	var diffOptions []string
+	if *contextLines != defaultContextLines {
+		diffOptions = append(diffOptions, fmt.Sprintf("-U%d", *contextLines))
+	}
+	if *functionContext {
+		diffOptions = append(diffOptions, "--function-context")
+	}
+	git := gitdiff.Git(gitCommand)
+	// show reads a changed file as the review sees it
+	show := func(path string) (string, error) { return git.Show(head, path) }
+	switch {
+	case uncommitted:
+		if changes, err = collectUncommitted(*staged, diffOptions...); err != nil {
+			fail(exitGit, "Error getting diff: %v", err)
+		}
+		if *staged {
+			show = func(path string) (string, error) { return git.Show("", path) }
+		} else {
+			show = func(path string) (string, error) {
+				data, err := os.ReadFile(filepath.Join(repoRoot, filepath.FromSlash(path)))
+				return string(data), err
+			}
+		}
+	case changes == nil:
+		if *commit != "" && refExists(*commit) && !refExists(baseRef) {
+			fail(exitGit, "Error: commit %s has no parent to review it against", *commit)
+		}
+		if err := checkRefs(baseRef, head); err != nil {
+			fail(exitGit, "Error: %v", err)
+		}
+		changes, err = collectRange(baseRef, rangeDots, head, diffOptions...)
+		if err != nil {
+			fail(exitGit, "Error getting diff: %v", err)
+		}
+		// After a rebase the two versions differ in the base branch's
+		// changes too; only the change's own files are compared
+		if sinceState != nil && sinceState.Rewritten {
+			files := append(changedPaths(git.ChangedFilesRange(prBase+"..."+sinceState.SHA)), changedPaths(git.ChangedFilesRange(prBase+"..."+head))...)
+			filterFiles(changes, func(path string) bool { return slices.Contains(files, path) })
+		}
+		// The whole message of a single commit explains it best
+		if *commit != "" {
+			if message, err := git.Message(*commit); err == nil {
+				changes.CommitMessages += "\n\n" + message
+			}
+		}
+	}
+
+	if changes.Diff == "" {
+		fmt.Println(tr("No changes found."))
+		exitWith(exitOK)
+	}
+
+	// Load the repository config and tailor it to the type of change
+	cfg := &Config{}
+	if repoRoot != "" {
+		cfg, err = loadConfig(filepath.Join(repoRoot, repoConfigFile))
+		if err != nil {
+			fail(exitUsage, "Error loading config: %v", err)
+		}
+	}
+	policy.enforce(cfg)
+	excluded := excludeFiles(changes, cfg.Exclude)
+	if len(excluded) > 0 {
+		fmt.Println("🙈 " + tr("Leaving out %s excluded by %s", trPlural(len(excluded), "file"), repoConfigFile))
+	}
+	// .prreviewignore comes first, so -exclude can override it
+	var ignored []string
+	if repoRoot != "" {
+		if ignored, err = loadReviewIgnore(filepath.Join(repoRoot, reviewIgnoreFile)); err != nil {
+			fail(exitUsage, "Error loading %s: %v", reviewIgnoreFile, err)
+		}
+	}
+	filter := pathFilter{Include: splitGlobs(*include), Exclude: append(ignored, splitGlobs(*exclude)...)}
+	skipped := func(path string) bool { return skippedByDefault(path, filter.Include, filter.Exclude) }
+	if defaults := filterFiles(changes, func(path string) bool { return !skipped(path) }); len(defaults) > 0 {
+		fmt.Println("🙈 " + tr("%s skipped (lockfiles/vendor)", trPlural(len(defaults), "file")))
+		excluded = append(excluded, defaults...)
+	}
+	if filtered := filterFiles(changes, filter.keeps); len(filtered) > 0 {
+		var sources []string
+		if ignored != nil {
+			sources = append(sources, reviewIgnoreFile)
+		}
+		if *include != "" {
+			sources = append(sources, "-include")
+		}
+		if *exclude != "" {
+			sources = append(sources, "-exclude")
+		}
+		fmt.Println("🙈 " + tr("Leaving out %s excluded by %s", trPlural(len(filtered), "file"), strings.Join(sources, ", ")))
+		excluded = append(excluded, filtered...)
+	}
+	var generated []string
+	if !*includeGenerated {
+		var attrs func([]string) (map[string]string, error)
+		if repoRoot != "" {
+			attrs = func(paths []string) (map[string]string, error) {
+				return gitdiff.Git(gitCommand).Attr("linguist-generated", paths)
+			}
+		}
+		if generated = generatedFiles(changes.Diff, attrs); generated != nil {
+			filterFiles(changes, func(path string) bool { return !slices.Contains(generated, path) })
+			fmt.Println("🙈 " + tr("Leaving out %s detected as generated (-include-generated reviews them)", trPlural(len(generated), "file")))
+			excluded = append(excluded, generated...)
+		}
+	}
+	if len(excluded) > 0 {
+		reviewed := func(path string) bool {
+			return filter.keeps(path) && !skipped(path) && !matchIgnore(cfg.Exclude, path) && !slices.Contains(generated, path)
+		}
+		// A patch's message isn't a log of commits to drop
+		if !patchMode {
+			changes.CommitMessages = filterCommits(changes.CommitMessages, reviewed, gitdiff.Git(gitCommand).CommitFiles)
+		}
+		if changes.Diff == "" {
+			fmt.Println(tr("No changes found."))
+			exitWith(exitOK)
+		}
+	}
+
+	// Binary files, and files whose diff is too large to be of use, are
+	// summarized in a line
+	var sizes blobSizes
+	if repoRoot != "" && !patchMode {
+		// The working tree's changes are against the index
+		beforeRev := baseRef
+		if *workingTree {
+			beforeRev = ""
+		}
+		sizes = func(path string) (int64, int64) {
+			before, err := git.BlobSize(beforeRev, path)
+			if err != nil {
+				before = -1
+			}
+			after := int64(-1)
+			if !uncommitted {
+				if size, err := git.BlobSize(head, path); err == nil {
+					after = size
+				}
+			} else if content, err := show(path); err == nil {
+				after = int64(len(content))
+			}
+			return before, after
+		}
+	}
+	omitted := summarizeLargeFiles(changes, maxFileDiff, sizes)
+	if len(omitted) > 0 {
+		fmt.Println("📦 " + tr("Showing %s as a summary, binary or too large to show:", trPlural(len(omitted), "file")))
+		for _, summary := range omitted {
+			fmt.Println("   " + summary)
+		}
+	}
+
+	// Shrink a diff too large to send, saying what was left out
+	var truncated []string
+	if tokens := diffTokens(changes.Diff); *maxDiffTokens > 0 && tokens > *maxDiffTokens {
+		var fits bool
+		truncated, fits = truncateDiff(changes, *maxDiffTokens, truncate)
+		if !fits {
+			fail(exitBudget, "Error: the diff is still about %d tokens after -truncate %s, over -max-diff-tokens %d; nothing was sent", diffTokens(changes.Diff), *truncateFlag, *maxDiffTokens)
+		}
+		fmt.Println("✂️  " + tr("Leaving parts of the diff of about %d tokens out to fit -max-diff-tokens %d:", tokens, *maxDiffTokens))
+		for _, o := range truncated {
+			fmt.Println("   " + o)
+		}
+	}
+
+	changeType := *changeTypeFlag
+	if changeType == "" {
+		changeType = detectChangeType(changeSignals{
+			Branch: currentBranch, Labels: labels, CommitMessages: changes.CommitMessages, Files: gitdiff.Files(changes.Diff),
+		})
+	}
+	changeTypeSection := cfg.applyChangeType(changeType)
+	if *failOnFlag != "" {
+		cfg.FailOn = failOn
+	}
+	if gate := cfg.gate(); gate != cfg.FailOn {
+		fmt.Fprintf(os.Stderr, "Note: The org policy fails reviews on %s findings and above, which the repository's fail_on and -fail-on can't relax\n", *gate)
+	}
+	if changeType != "" {
+		fmt.Println("🏷️  " + tr("Reviewing as a %s change", changeType))
+	}
+
+	// Reuse an earlier review of exactly this head and base rather than
+	// spending tokens on it again
+	baseSHA, headSHA := resolveRef(baseRef), resolveRef(head)
+	run.BaseSHA, run.HeadSHA = baseSHA, headSHA
+	history, err := openHistoryFor(repo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Review history unavailable: %v\n", err)
+	} else {
+		defer history.Close()
+		// Uncommitted changes and patches have no commit to tell them apart by
+		if !*force && compareList == nil && !uncommitted && !patchMode {
+			previous, err := history.FindLatest(repo, baseSHA, headSHA)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Could not search review history: %v\n", err)
+			} else if previous != nil {
+				age := time.Since(previous.CreatedAt)
+				fmt.Println("♻️  " + tr("%s was already reviewed against %s %s ago with %s.",
+					shortSHA(headSHA), baseRef, formatAge(age), previous.Model))
+				if age > staleReviewAge || previous.Model != *common.model {
+					fmt.Println("⚠️  " + tr("That review may be stale: it predates recent prompt changes or used a different model."))
+				}
+				fmt.Println("   " + tr("Showing that review instead; use -force to review again."))
+				fmt.Println()
+				run.Reused, run.Model = true, previous.Model
+				// The baseline may have changed since
+				reused := previous.Findings
+				if flag.NArg() == 0 && !*noBaseline {
+					var known []Finding
+					reused, known = applyBaseline(getRepoRoot(), reused)
+					previous.Findings = append(append([]Finding(nil), reused...), known...)
+				}
+				if *post != "" {
+					if err := postReview(*post, repo, currentBranch, pr, previous.Review, reused, headSHA, *inline); err != nil {
+						fail(exitProvider, "Error posting review: %v", err)
+					}
+				}
+				if *format != formatMarkdown {
+					doc := newReviewDocument(previous, previous.Review, nil)
+					doc.failOn = cfg.gate()
+					if err := writeMachineReport(stdout, *format, doc); err != nil {
+						fail(exitUsage, "Error writing review: %v", err)
+					}
+				} else {
+					printReview(previous.Review, Usage{InputTokens: previous.InputTokens, OutputTokens: previous.OutputTokens})
+				}
+				exitOnGate(cfg, reused)
+				return
+			}
+		}
+	}
+
+	// Get additional context files if specified
+	additionalContext := common.readContext(client, policy)
+	if *withFullFiles {
+		contents, skipped := fullFiles(changes.Diff, fullFilesMax, show)
+		if contents != "" {
+			fmt.Println("📄 " + tr("Adding the full contents of the changed files to the prompt"))
+		}
+		if len(skipped) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: Not adding the full contents of %s, over -full-files-max or not found: %s\n", plural(len(skipped), "file"), strings.Join(skipped, ", "))
+		}
+		additionalContext += contents
+	}
+
+	// Compare benchmark runs if provided, so performance claims are grounded
+	// in measurements
+	sections := append([]promptSection(nil), cfg.PromptSections...)
+	if common.profile != nil {
+		sections = append(sections, common.profile.PromptSections...)
+	}
+	if len(excluded) > 0 {
+		sections = append(sections, promptSection{Title: "Excluded Files", Body: excludedInstructions(excluded)})
+	}
+	if *quick {
+		sections = append(sections, promptSection{Title: "Quick Review", Body: quickInstructions})
+	}
+	if sinceState != nil {
+		sections = append(sections, promptSection{Title: "Changes Since the Last Review", Body: sinceInstructions(sinceState)})
+	}
+	if len(omitted) > 0 {
+		sections = append(sections, promptSection{Title: "Omitted Files", Body: omittedInstructions(omitted)})
+	}
+	if len(truncated) > 0 {
+		sections = append(sections, promptSection{Title: "Truncated Diff", Body: truncatedInstructions(truncated)})
+	}
+	if changeTypeSection != nil {
+		sections = append(sections, *changeTypeSection)
+	}
+	var benchTable string
+	if *benchOld != "" || *benchNew != "" {
+		if *benchOld == "" || *benchNew == "" {
+			fail(exitUsage, "Error: -bench-old and -bench-new must be used together")
+		}
+		benchTable, err = benchmarkSection(*benchOld, *benchNew)
+		if err != nil {
+			fail(exitUsage, "Error comparing benchmarks: %v", err)
+		}
+		sections = append(sections, promptSection{Title: "Benchmark Results", Body: benchmarkInstructions + benchTable})
+	}
+
+	// Changes to migrations, deployment config and feature flags get a
+	// rollout and revert assessment
+	if deploy := deploymentFiles(gitdiff.Files(changes.Diff)); len(deploy) > 0 && !*noRolloutPlan {
+		sections = append(sections, promptSection{Title: "Deployment Risk", Body: rolloutSection(deploy)})
+	}
+
+	// Summarize the hot functions of a profile so the review can check
+	// whether the diff touches them
+	if *pprofFile != "" {
+		summary, err := profileSection(*pprofFile, repoRoot, gitdiff.Files(changes.Diff))
+		if err != nil {
+			fail(exitUsage, "Error reading profile: %v", err)
+		}
+		sections = append(sections, promptSection{Title: "Performance Profile", Body: summary})
+	}
+
+	// Checks run in a disposable worktree of the head, so they see exactly
+	// the reviewed commit and never touch the user's working tree. For
+	// uncommitted changes the head is HEAD, so the changes are applied on top
+	worktree, removeWorktree := "", func() {}
+	checkout := func() (string, error) {
+		if worktree == "" {
+			dir, cleanup, err := addWorktree(head)
+			if err != nil {
+				return "", err
+			}
+			if uncommitted {
+				if err := applyUncommitted(dir, *staged); err != nil {
+					cleanup()
+					return "", err
+				}
+			}
+			worktree, removeWorktree = dir, cleanup
+		}
+		return worktree, nil
+	}
+
+	// Check that the code builds and vets cleanly so the review can focus on
+	// fixing errors rather than reviewing code that doesn't compile
+	if *goVerify {
+		fmt.Println("🔨 " + tr("Running go build and go vet..."))
+		dir, err := checkout()
+		var results []checkResult
+		if err == nil {
+			results, err = goVerifyChecks(dir)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping Go verification: %v\n", err)
+		} else {
+			if checksFailed(results) {
+				fmt.Println("⚠️  " + tr("Go verification failed; the errors will be included in the review."))
+			}
+			sections = append(sections, promptSection{Title: "Build Verification", Body: verificationInstructions + formatChecks(results)})
+		}
+	}
+
+	// Point the review at changes to code the repository marks as critical
+	critical := criticalFiles(gitdiff.Files(changes.Diff), cfg.CriticalPaths)
+	if len(critical) > 0 {
+		sections = append(sections, promptSection{Title: "Critical Paths", Body: criticalPathInstructions(critical)})
+	}
+
+	// Run the repository's own pre-review commands and include their results
+	if len(cfg.PreReview) > 0 && !*noPreReview {
+		fmt.Println("🔧 " + tr("Running %d pre-review command(s)...", len(cfg.PreReview)))
+		if dir, err := checkout(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping pre-review commands: %v\n", err)
+		} else {
+			results := preReviewChecks(dir, cfg.PreReview)
+			for _, r := range results {
+				if !r.Passed {
+					fmt.Println("⚠️  " + tr("Pre-review command failed: %s", r.Command))
+				}
+			}
+			sections = append(sections, promptSection{Title: "Pre-Review Checks", Body: preReviewInstructions + formatChecks(results)})
+		}
+	}
+	removeWorktree()
+
+	if messages != nil {
+		sections = append(sections, messages.languageSection())
+	}
+
+	// Let a cheap model pick the files that need the deep review
+	reviewDiff := changes.Diff
+	var screens []fileScreen
+	var screenUsage Usage
+	if *prescreenFlag {
+		fmt.Println("🔎 " + tr("Pre-screening %s with %s...", trPlural(len(gitdiff.Files(changes.Diff)), "file"), *prescreenModel))
+		screens, screenUsage, err = prescreen(client, *prescreenModel, policy, changes.Diff, changes.CommitMessages)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Pre-screen failed, reviewing every file: %v\n", err)
+			screens = nil
+		} else {
+			reviewDiff = highRiskDiff(changes.Diff, screens)
+			high := len(gitdiff.Split(reviewDiff))
+			fmt.Println("   " + tr("%d of %d files need the deep review", high, len(screens)))
+			if high < len(screens) {
+				sections = append(sections, promptSection{Title: "Pre-Screen", Body: prescreenInstructions(screens)})
+			}
+		}
+	}
+
+	// Build the prompt
+	prompt := buildReviewPrompt(reviewDiff, changes.ChangedFiles, changes.CommitMessages, additionalContext, sections, cfg)
+	if *format != formatMarkdown {
+		prompt += "\n\n" + jsonFormatInstructions
+	}
+	prompt = policy.redact(prompt)
+
+	// A diff too large to review well at once is reviewed in parts
+	var parts []splitPart
+	var partPrompts []string
+	if compareList == nil && *chunkStrategy != chunkNone && *splitOver > 0 && diffTokens(reviewDiff) > *splitOver {
+		if parts = splitDiff(reviewDiff, *chunkStrategy, *maxChunkTokens); len(parts) > 1 {
+			for i, part := range parts {
+				partPrompts = append(partPrompts, policy.redact(partPrompt(part, i, len(parts), changes, additionalContext, sections, cfg)))
+			}
+		} else {
+			parts = nil
+		}
+	}
+
+	// The same diff and context reviewed the same way by the same model
+	// gets the cached review back, for free
+	var cacheKey func(model string) string
+	var cached *cachedReview
+	cache := openReviewCache()
+	defer cache.close()
+	if reviewDiff != "" && compareList == nil {
+		context := changes.ChangedFiles + "\x00" + changes.CommitMessages + "\x00" + additionalContext
+		instructions := reviewInstructions(sections, cfg, *format, parts)
+		cacheKey = func(model string) string {
+			return reviewCacheKey(model, reviewDiff, context, instructions)
+		}
+		if !*noCache {
+			cached = cache.lookup(cacheKey(*common.model))
+		}
+	}
+
+	// Say what the review will cost before spending it
+	if reviewDiff != "" && cached == nil {
+		models := compareList
+		if models == nil {
+			models = []string{*common.model}
+		}
+		estimated := prompt
+		if parts != nil {
+			estimated = strings.Join(partPrompts, "\n\n")
+		}
+		est, model, err := enforceBudget(client, estimated, common.completionOptions(), models, limits, *budgetModel)
+		if err != nil {
+			fail(exitBudget, "Error: %v; nothing was sent", err)
+		}
+		*common.model, run.Model = model, model
+		if !confirmCost(est, os.Stdin, isTerminal(os.Stdin), *yes) {
+			fmt.Println(tr("Review cancelled; nothing was sent."))
+			exitWith(exitCancelled)
+		}
+	}
+
+	if compareList != nil {
+		fmt.Println("🤖 " + tr("Comparing %s on %s...", strings.Join(compareList, ", "), client.Name()))
+		fmt.Println("⏳ " + tr("This may take a moment for deep analysis..."))
+		fmt.Println()
+		reviews, findings, usage, err := compareModels(client, common.completionOptions(), policy, compareList, prompt, changes.Diff, cfg, *failFast)
+		run.Units = modelUnits(reviews)
+		if err != nil {
+			fail(exitProvider, "Error calling %s API: %v", client.Name(), err)
+		}
+		if flag.NArg() == 0 {
+			attributeFindings(findings, baseRef, head)
+		}
+		report := renderModelComparison(reviews, findings, *groupBy)
+		if err := writeReviewToFile(*outputFile, report); err != nil {
+			fail(exitUsage, "Error writing review to file: %v", err)
+		}
+		fmt.Printf("✅ %s\n\n", tr("Review written to: %s", *outputFile))
+		common.printTranscript()
+		printReport(tr("MODEL COMPARISON"), report, usage)
+		run.Model, run.Output = strings.Join(compareList, ","), *outputFile
+		run.InputTokens, run.OutputTokens = usage.InputTokens, usage.OutputTokens
+		exitOnGate(cfg, findings)
+		return
+	}
+
+	var response string
+	var result *review.Result
+	var usage Usage
+	streamed := false
+	if cached != nil {
+		fmt.Println("♻️  " + tr("Using the cached review of this prompt by %s from %s ago; use -no-cache to review again.", cached.Model, formatAge(time.Since(cached.CreatedAt))))
+		fmt.Println()
+		response = cached.Response
+	} else if reviewDiff == "" {
+		response = tr("The pre-screen rated every file low or medium risk, so there was no deep review.")
+	} else if parts != nil {
+		fmt.Println("🧩 " + tr("Reviewing the diff of about %d tokens in %d parts with %s, %d at a time...",
+			diffTokens(reviewDiff), len(parts), client.Name(), min(*splitWorkers, len(parts))))
+		fmt.Println("⏳ " + tr("This may take a moment for deep analysis..."))
+		fmt.Println()
+		// Parts whose diff hasn't changed since they were last reviewed
+		// aren't reviewed again
+		var partsCache *partCache
+		if cacheKey != nil {
+			partsCache = &partCache{cache: cache, reuse: !*noCache}
+			instructions := reviewInstructions(sections, cfg, formatMarkdown, nil)
+			for _, part := range parts {
+				partsCache.keys = append(partsCache.keys, partCacheKey(*common.model, part, additionalContext, instructions))
+			}
+		}
+		response, run.Units, usage, err = reviewSplit(client, common.completionOptions(), policy, parts, partPrompts, partsCache, changes, *splitWorkers, *failFast, *format != formatMarkdown)
+		if err != nil {
+			fail(exitProvider, "Error calling %s API: %v", client.Name(), err)
+		}
+	} else {
+		if *common.noThinking {
+			fmt.Println("🤖 " + tr("Analyzing PR with %s...", client.Name()))
+		} else {
+			fmt.Println("🤖 " + tr("Analyzing PR with %s (ultrathink mode: enabled)...", client.Name()))
+		}
+		fmt.Println("⏳ " + tr("This may take a moment for deep analysis..."))
+		fmt.Println()
+
+		// Show the review as it is written rather than only at the end
+		reviewer, live := client, (*liveReview)(nil)
+		if !*noStream && *format == formatMarkdown && isTerminal(os.Stdout) {
+			live = newLiveReview(os.Stdout)
+			reviewer, streamed = llm.Streaming(client, live)
+			if !streamed {
+				live = nil
+			}
+		}
+		result, err = review.Run(reviewer, prompt, common.completionOptions())
+		live.Close()
+		if err != nil {
+			fail(exitProvider, "Error calling %s API: %v", client.Name(), err)
+		}
+		response, usage = result.Response, result.Usage
+	}
+	// A review missing parts that failed isn't one to keep. It is kept
+	// under the model that wrote it, which -budget-model may have changed.
+	if _, failed, _ := countUnits(run.Units); cacheKey != nil && cached == nil && failed == 0 {
+		if err := cache.store(cacheKey(*common.model), &cachedReview{Model: *common.model, Response: response, CreatedAt: time.Now().UTC()}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not cache the review: %v\n", err)
+		}
+	}
+	// The pre-screen is priced at its own model's rate, not the deep model's
+	usageByModel := modelUsage{}
+	usageByModel.add(*common.model, usage)
+	if *prescreenFlag {
+		usageByModel.add(*prescreenModel, screenUsage)
+	}
+	usage.Add(screenUsage)
+
+	// Separate the structured findings from the prose and enforce the
+	// repository's severity calibration on them
+	if result == nil {
+		result = review.Parse(response)
+	}
+	if result.FindingsErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not parse structured findings: %v\n", result.FindingsErr)
+	}
+	report, findings, checklist := result.Review, result.Findings, result.Checklist
+	summary := report
+
+	// Changed tests get a dedicated flakiness pass on top of the static checks
+	if tests := testDiff(changes.Diff); tests != "" {
+		static := flakyStaticFindings(changes.Diff)
+		var flakyFindings []Finding
+		if !*noFlakyCheck {
+			fmt.Println("🧪 " + tr("Checking changed tests for flakiness..."))
+			flaky, err := review.Run(llm.WithoutDocuments(client), policy.redact(buildFlakyPrompt(tests, static)),
+				CompletionOptions{Model: *common.model, MaxTokens: flakyPassMaxTokens})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Flaky-test pass failed: %v\n", err)
+			} else {
+				usage.Add(flaky.Usage)
+				usageByModel.add(*common.model, flaky.Usage)
+				if flaky.FindingsErr != nil {
+					fmt.Fprintf(os.Stderr, "Warning: Could not parse flaky-test findings: %v\n", flaky.FindingsErr)
+				}
+				flakyFindings = flaky.Findings
+				report += "\n\n## " + tr("Flaky-Test Risk") + "\n\n" + flaky.Review
+			}
+		}
+		findings = append(findings, mergeFlakyFindings(static, flakyFindings)...)
+	}
+
+	if n := checkEvidence(findings, changes.Diff); n > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s quote evidence that is not in the diff\n", plural(n, "finding"))
+	}
+	applyCalibration(findings, cfg.SeverityCalibration)
+	escalateCritical(findings, cfg.CriticalPaths)
+	applySeverityRules(findings, cfg.SeverityRules)
+	if flag.NArg() == 0 {
+		attributeFindings(findings, baseRef, head)
+	}
+	// Known findings are recorded, but only new ones are reported and gated
+	var known []Finding
+	if flag.NArg() == 0 && !*noBaseline {
+		findings, known = applyBaseline(getRepoRoot(), findings)
+	}
+	if section := criticalPathSummary(critical, findings); section != "" {
+		report += "\n\n" + section
+	}
+	if rendered := renderFindings(findings, *groupBy); rendered != "" {
+		report += "\n\n" + rendered
+	}
+	if units := renderUnits(run.Units); units != "" {
+		report += "\n\n" + units
+	}
+	if truncated != nil {
+		report += "\n\n" + renderTruncation(truncated, *maxDiffTokens)
+	}
+	if screens != nil {
+		report += "\n\n" + renderPrescreen(screens)
+	}
+	if benchTable != "" {
+		report += "\n\n## " + tr("Benchmark Delta") + "\n\n" + benchTable
+	}
+	if len(checklist) > 0 {
+		report += "\n\n## " + tr("Reviewer Checklist") + "\n\n" + formatChecklist(checklist)
+	}
+
+	// Write review to file; in the machine-readable formats the document on
+	// stdout is the output
+	if *format == formatMarkdown && *incremental && sinceState != nil {
+		heading := tr("Commits Since %s", shortSHA(sinceState.SHA))
+		if err := appendReviewToFile(*outputFile, heading, report); err != nil {
+			fail(exitUsage, "Error writing review to file: %v", err)
+		}
+		fmt.Printf("✅ %s\n\n", tr("Review of the new commits appended to: %s", *outputFile))
+		run.Output = *outputFile
+	} else if *format == formatMarkdown {
+		if err := writeReviewToFile(*outputFile, report); err != nil {
+			fail(exitUsage, "Error writing review to file: %v", err)
+		}
+		fmt.Printf("✅ %s\n\n", tr("Review written to: %s", *outputFile))
+		run.Output = *outputFile
+	}
+	if *checklistFile != "" && len(checklist) > 0 {
+		if err := writeReviewToFile(*checklistFile, formatChecklist(checklist)); err != nil {
+			fail(exitUsage, "Error writing checklist to file: %v", err)
+		}
+		fmt.Printf("✅ %s\n\n", tr("Reviewer checklist written to: %s", *checklistFile))
+	}
+
+	record := &reviewRecord{
+		Repo:         repo,
+		Branch:       currentBranch,
+		BaseRef:      baseRef,
+		BaseSHA:      baseSHA,
+		HeadSHA:      headSHA,
+		Model:        *common.model,
+		Review:       report,
+		Findings:     append(append([]Finding(nil), findings...), known...),
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		LinesChanged: gitdiff.Size(changes.Diff),
+		DurationMS:   time.Since(started).Milliseconds(),
+		Team:         cfg.Team,
+		DiffHash:     diffHash(changes.Diff),
+	}
+	record.CostUSD, _ = usageByModel.cost()
+	run.InputTokens, run.OutputTokens = usage.InputTokens, usage.OutputTokens
+	if history != nil {
+		if err := history.Record(record); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not save review to history: %v\n", err)
+		}
+		applyRetention(history, cfg.Retention)
+	}
+
+	if *post != "" {
+		if err := postReview(*post, repo, currentBranch, pr, report, findings, headSHA, *inline); err != nil {
+			fail(exitProvider, "Error posting review: %v", err)
+		}
+	}
+
+	common.printTranscript()
+
+	if *format != formatMarkdown {
+		doc := newReviewDocument(record, summary, checklist)
+		doc.failOn = cfg.gate()
+		if err := writeMachineReport(stdout, *format, doc); err != nil {
+			fail(exitUsage, "Error writing review: %v", err)
+		}
+	} else if *hookName != "" {
+		printHookSummary(os.Stdout, findings, *outputFile)
+	} else if streamed {
+		// The prose was shown as it was written; add what the tool added
+		printReview(strings.TrimSpace(strings.TrimPrefix(report, summary)), usage)
+	} else {
+		printReview(report, usage)
+	}
+	exitOnGate(cfg, findings)
+}
+
+// printReview prints a review and its token usage to the terminal
+func printReview(review string, usage Usage) {
+	printReport(tr("CODE REVIEW"), review, usage)
+}
+
+// printReport prints a titled model response and its token usage
+func printReport(title, text string, usage Usage) {
+	fmt.Println("=" + strings.Repeat("=", 78))
+	fmt.Println(title)
+	fmt.Println("=" + strings.Repeat("=", 78))
+	fmt.Println()
+	fmt.Println(text)
+	fmt.Println()
+	fmt.Println("=" + strings.Repeat("=", 78))
+	fmt.Println("📊 " + tr("Token Usage: Input: %d | Output: %d | Total: %d",
+		usage.InputTokens, usage.OutputTokens, usage.InputTokens+usage.OutputTokens))
+	if usage.CacheCreationInputTokens > 0 || usage.CacheReadInputTokens > 0 {
+		fmt.Println("   " + tr("Prompt Cache: Written: %d | Read: %d", usage.CacheCreationInputTokens, usage.CacheReadInputTokens))
+	}
+	fmt.Println("=" + strings.Repeat("=", 78))
+}
+
+// promptSection is a titled block of tool-gathered context added to the prompt
+type promptSection = prompt.Section
+
+// buildReviewPrompt builds the review prompt with the rubric and severity
+// calibration of cfg
+func buildReviewPrompt(diff, changedFiles, commitMessages, additionalContext string, sections []promptSection, cfg *Config) string {
+	return prompt.Build(prompt.Review{
+		Rubric:         cfg.rubric,
+		Diff:           diff,
+		ChangedFiles:   changedFiles,
+		CommitMessages: commitMessages,
+		Context:        additionalContext,
+		Sections:       sections,
+		Calibration:    calibrationPrompt(cfg.SeverityCalibration),
+	})
+}
+
+// truncatedNotice is appended to a response that was cut off, so an
+// incomplete report is never mistaken for a complete one
+const truncatedNotice = "\n\n---\n\n⚠️ **This output was cut off at the output token limit and is incomplete.** Raise `-max-tokens` or allow `-max-continuations` to fetch the rest."
+
+// gitCommand returns a command running git. The executable is looked up
+// once with exec.LookPath, which honors PATHEXT on Windows so git.exe and
+// git.cmd wrappers are both found.
+func gitCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command(gitPath(), args...)
+	if offlineGit {
+		cmd.Env = append(os.Environ(), offlineGitEnv...)
+	}
+	return cmd
+}
+
+var gitPath = sync.OnceValue(func() string {
+	if path, err := exec.LookPath("git"); err == nil {
+		return path
+	}
+	// Let exec report the lookup failure when the command runs
+	return "git"
+})
+
+func getCurrentBranch() string {
+	cmd := gitCommand("branch", "--show-current")
+	output, err := cmd.Output()
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// getRepoRoot returns the top-level directory of the repository, or "" when
+// not inside one
+func getRepoRoot() string {
+	cmd := gitCommand("rev-parse", "--show-toplevel")
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	// git prints forward slashes even on Windows (C:/src/app)
+	return filepath.FromSlash(strings.TrimSpace(string(output)))
+}
+
+// getRepoIdentity identifies the repository independently of where it is
+// checked out: its normalized origin URL, or its top-level path if it has no
+// origin remote. Outside a repository, e.g. reviewing a -patch, it is ".",
+// so the review isn't taken for one of every repository's in a shared
+// history.
+func getRepoIdentity() string {
+	cmd := gitCommand("remote", "get-url", "origin")
+	output, err := cmd.Output()
+	if err == nil {
+		if url := strings.TrimSpace(string(output)); url != "" {
+			return normalizeRemoteURL(url)
+		}
+	}
+	if root := getRepoRoot(); root != "" {
+		return root
+	}
+	return "."
+}
+
+// normalizeRemoteURL reduces the different spellings of a remote URL to
+// host/path, so https://github.com/org/repo.git and git@github.com:org/repo
+// identify the same repository
+func normalizeRemoteURL(url string) string {
+	url = strings.TrimSpace(url)
+	if i := strings.Index(url, "://"); i != -1 {
+		url = url[i+3:]
+	} else if at := strings.Index(url, "@"); at != -1 {
+		// scp-like syntax: user@host:path
+		url = strings.Replace(url[at+1:], ":", "/", 1)
+	}
+	if at := strings.Index(url, "@"); at != -1 && at < strings.Index(url+"/", "/") {
+		url = url[at+1:]
+	}
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	host, path, _ := strings.Cut(url, "/")
+	if path == "" {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(host) + "/" + path
+}
+
+// resolveRef returns the commit SHA that ref points to, or ref itself if it
+// cannot be resolved
+func resolveRef(ref string) string {
+	cmd := gitCommand("rev-parse", "--verify", ref+"^{commit}")
+	output, err := cmd.Output()
+	if err != nil {
+		return ref
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// shortSHA abbreviates a commit SHA for display
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// formatAge renders a duration coarsely, e.g. "3 days" or "5 minutes"
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "less than a minute"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(int(d/(24*time.Hour)), "day")
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func getDefaultBranch() string {
+	// Try to get the default branch from remote
+	cmd := gitCommand("symbolic-ref", "refs/remotes/origin/HEAD")
+	output, err := cmd.Output()
+	if err == nil {
+		branch := strings.TrimSpace(string(output))
+		branch = strings.TrimPrefix(branch, "refs/remotes/origin/")
+		if branch != "" {
+			return branch
+		}
+	}
+
+	// Fallback: check if main exists, otherwise use master
+	cmd = gitCommand("rev-parse", "--verify", "main")
+	if cmd.Run() == nil {
+		return "main"
+	}
+
+	return "master"
+}
+
+// backupFile creates a GNU-style numbered backup of the file if it exists
+// foo.txt -> foo.txt.~1~, foo.txt.~1~ -> foo.txt.~2~, etc.
+func backupFile(filename string) error {
+	// Check if the file exists
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil // Nothing to backup
+	}
+
+	// Find the highest numbered backup
+	highestBackup := 0
+	for i := 1; ; i++ {
+		backupName := fmt.Sprintf("%s.~%d~", filename, i)
+		if _, err := os.Stat(backupName); os.IsNotExist(err) {
+			highestBackup = i
+			break
+		}
+	}
+
+	// Rename the current file to the next backup number
+	backupName := fmt.Sprintf("%s.~%d~", filename, highestBackup)
+	if err := os.Rename(filename, backupName); err != nil {
+		return fmt.Errorf("failed to create backup %s: %w", backupName, err)
+	}
+
+	fmt.Printf("📦 Created backup: %s\n", backupName)
+	return nil
+}
+
+// writeReviewToFile writes the review content to a file, creating a backup if needed
+func writeReviewToFile(filename, content string) error {
+	// Create backup if file exists
+	if err := backupFile(filename); err != nil {
+		return err
+	}
+
+	// Write the review to the file
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write review to %s: %w", filename, err)
+	}
+
+	return nil
+}
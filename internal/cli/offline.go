@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// offlineGit forbids git network operations, for runners that can't reach
+// the remote: nothing is fetched, and refs the review needs must already
+// exist locally
+var offlineGit bool
+
+// offlineGitEnv stops git from reaching a remote on its own: lazy fetches of
+// missing objects in partial clones, and any transport but local files
+var offlineGitEnv = []string{"GIT_NO_LAZY_FETCH=1", "GIT_ALLOW_PROTOCOL=file", "GIT_TERMINAL_PROMPT=0"}
+
+// shaPattern matches a full or abbreviated commit SHA
+var shaPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// refExists reports whether ref names a commit in the local repository
+func refExists(ref string) bool {
+	return gitCommand("rev-parse", "--verify", "--quiet", ref+"^{commit}").Run() == nil
+}
+
+// fetchRefs runs a fetch per remote of the given refspecs. With
+// -offline-git nothing is fetched: the destination refs must exist from an
+// earlier fetch, and if they don't, the error gives the commands that would
+// create them.
+func fetchRefs(what string, fetches map[string][]string) error {
+	remotes := make([]string, 0, len(fetches))
+	for remote := range fetches {
+		remotes = append(remotes, remote)
+	}
+	sort.Strings(remotes)
+
+	if offlineGit {
+		var missing []string
+		for _, remote := range remotes {
+			for _, spec := range fetches[remote] {
+				_, dst, _ := strings.Cut(spec, ":")
+				if !refExists(dst) {
+					missing = append(missing, fmt.Sprintf("git fetch --no-tags %s %s", remote, spec))
+					break
+				}
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("-offline-git is set and %s has not been fetched; fetch it first with:\n  %s",
+				what, strings.Join(missing, "\n  "))
+		}
+		return nil
+	}
+
+	// A pull request from a fork is fetched from the fork, its base from
+	// origin
+	for _, remote := range remotes {
+		args := append([]string{"fetch", "--quiet", "--no-tags", remote}, fetches[remote]...)
+		if output, err := gitCommand(args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to fetch %s: %w: %s", what, err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// checkRefs verifies that base and head, and a merge base between them,
+// exist locally, explaining what to fetch if not. CI runners often check out
+// a single shallow commit, where the diff would otherwise fail obscurely.
+func checkRefs(base, head string) error {
+	for _, ref := range []string{head, base} {
+		if refExists(ref) {
+			continue
+		}
+		if shaPattern.MatchString(ref) {
+			return fmt.Errorf("commit %s does not exist in this repository; fetch it first with:\n  git fetch --no-tags origin %s", ref, ref)
+		}
+		if refExists("origin/" + ref) {
+			return fmt.Errorf("%s is not a local branch; pass -base origin/%s, or create it with:\n  git fetch --no-tags origin +refs/heads/%s:refs/heads/%s",
+				ref, ref, ref, ref)
+		}
+		return fmt.Errorf("%s does not exist in this repository; fetch it first, e.g. with:\n  git fetch --no-tags origin +refs/heads/%s:refs/remotes/origin/%s\nand pass -base origin/%s",
+			ref, ref, ref, ref)
+	}
+	if gitCommand("merge-base", base, head).Run() == nil {
+		return nil
+	}
+	if out, err := gitCommand("rev-parse", "--is-shallow-repository").Output(); err == nil && strings.TrimSpace(string(out)) == "true" {
+		return fmt.Errorf("this is a shallow clone without the history joining %s and %s; deepen it first with:\n  git fetch --deepen=100 origin %s\nor fetch the full history with:\n  git fetch --unshallow origin",
+			base, head, base)
+	}
+	return fmt.Errorf("%s and %s have no common history to diff", base, head)
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestOfflineGit tests that -offline-git never fetches a pull request but
+// uses refs fetched beforehand, and explains how to fetch missing ones
+func TestOfflineGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	origin, clone, shallow := t.TempDir(), t.TempDir(), t.TempDir()
+
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := gitCommand(append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	git(origin, "init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(origin, "app.go"), []byte("package app\n"), 0644)
+	git(origin, "add", ".")
+	git(origin, "commit", "-q", "-m", "base")
+	git(origin, "checkout", "-q", "-b", "contributor")
+	os.WriteFile(filepath.Join(origin, "app.go"), []byte("package app\n\nfunc New() {}\n"), 0644)
+	git(origin, "commit", "-q", "-am", "add New")
+	git(origin, "update-ref", "refs/pull/5/head", "HEAD")
+	git(origin, "checkout", "-q", "main")
+	git(clone, "clone", "-q", origin, ".")
+	git(shallow, "clone", "-q", "--depth", "1", "--branch", "contributor", "file://"+origin, ".")
+
+	offlineGit = true
+	t.Cleanup(func() { offlineGit = false })
+	t.Chdir(clone)
+
+	_, err := fetchPullRequest(postGitHub, 5, "")
+	if err == nil || !strings.Contains(err.Error(), "git fetch --no-tags origin +refs/pull/5/head:refs/pr-review/pull/5/head") {
+		t.Fatalf("fetchPullRequest() error = %v, want the fetch command", err)
+	}
+	if refExists("refs/pr-review/pull/5/head") {
+		t.Fatal("fetchPullRequest() fetched with -offline-git")
+	}
+
+	// Once fetched by the CI job, the refs are used as they are
+	git(clone, "fetch", "-q", "--no-tags", "origin", "+refs/pull/5/head:refs/pr-review/pull/5/head", "+refs/heads/main:refs/pr-review/pull/5/base")
+	target, err := fetchPullRequest(postGitHub, 5, "")
+	if err != nil {
+		t.Fatalf("fetchPullRequest() with fetched refs returned error: %v", err)
+	}
+	if err := checkRefs(target.Base, target.Head); err != nil {
+		t.Errorf("checkRefs() returned error: %v", err)
+	}
+
+	if err := checkRefs("contributor", "HEAD"); err == nil || !strings.Contains(err.Error(), "-base origin/contributor") {
+		t.Errorf("checkRefs() of a remote-only branch error = %v", err)
+	}
+	if err := checkRefs("release", "HEAD"); err == nil || !strings.Contains(err.Error(), "git fetch --no-tags origin +refs/heads/release:refs/remotes/origin/release") {
+		t.Errorf("checkRefs() of a missing branch error = %v", err)
+	}
+
+	// A single-commit checkout has no merge base with anything
+	t.Chdir(shallow)
+	git(shallow, "fetch", "-q", "--depth", "1", "origin", "+refs/heads/main:refs/remotes/origin/main")
+	if err := checkRefs("origin/main", "HEAD"); err == nil || !strings.Contains(err.Error(), "git fetch --deepen") {
+		t.Errorf("checkRefs() in a shallow clone error = %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// This file implements just enough of the Parquet format to export history
+// for analytics: a single row group of required INT64 and UTF-8 columns,
+// PLAIN-encoded and uncompressed, which every Parquet reader understands.
+
+// parquetMagic starts and ends every Parquet file
+const parquetMagic = "PAR1"
+
+// Parquet physical types, converted types and enums used by the writer
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetRequired     = 0
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+)
+
+// parquetColumn is one column of a Parquet table. Text columns hold strs,
+// integer and timestamp columns hold ints.
+type parquetColumn struct {
+	name      string
+	text      bool
+	timestamp bool // ints are milliseconds since the Unix epoch
+	ints      []int64
+	strs      []string
+}
+
+// plain returns the PLAIN encoding of the column's values
+func (c *parquetColumn) plain() []byte {
+	var b []byte
+	if c.text {
+		for _, s := range c.strs {
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+			b = append(b, s...)
+		}
+		return b
+	}
+	for _, v := range c.ints {
+		b = binary.LittleEndian.AppendUint64(b, uint64(v))
+	}
+	return b
+}
+
+func (c *parquetColumn) len() int {
+	if c.text {
+		return len(c.strs)
+	}
+	return len(c.ints)
+}
+
+// writeParquet writes columns, which must all have the same length, as a
+// Parquet file
+func writeParquet(out io.Writer, columns []*parquetColumn) error {
+	rows := 0
+	if len(columns) > 0 {
+		rows = columns[0].len()
+	}
+
+	file := []byte(parquetMagic)
+	var chunks []thriftCompact
+	var total int64
+	for _, c := range columns {
+		data := c.plain()
+		total += int64(len(data))
+
+		var page thriftCompact
+		page.i32(1, parquetDataPage)
+		page.i32(2, int32(len(data)))
+		page.i32(3, int32(len(data)))
+		page.beginStruct(5)
+		page.i32(1, int32(rows))
+		page.i32(2, parquetPlain)
+		page.i32(3, parquetRLE)
+		page.i32(4, parquetRLE)
+		page.endStruct()
+		page.stop()
+
+		offset := int64(len(file))
+		size := int64(len(page.b) + len(data))
+		file = append(append(file, page.b...), data...)
+
+		typ := int32(parquetInt64)
+		if c.text {
+			typ = parquetByteArray
+		}
+		var chunk thriftCompact
+		chunk.i64(2, offset)
+		chunk.beginStruct(3)
+		chunk.i32(1, typ)
+		chunk.listI32(2, []int32{parquetPlain})
+		chunk.listString(3, []string{c.name})
+		chunk.i32(4, parquetUncompressed)
+		chunk.i64(5, int64(rows))
+		chunk.i64(6, size)
+		chunk.i64(7, size)
+		chunk.i64(9, offset)
+		chunk.endStruct()
+		chunks = append(chunks, chunk)
+	}
+
+	var meta thriftCompact
+	meta.i32(1, 1)
+	meta.beginList(2, thriftStruct, len(columns)+1)
+	meta.beginElem()
+	meta.str(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.endStruct()
+	for _, c := range columns {
+		meta.beginElem()
+		if c.text {
+			meta.i32(1, parquetByteArray)
+		} else {
+			meta.i32(1, parquetInt64)
+		}
+		meta.i32(3, parquetRequired)
+		meta.str(4, c.name)
+		if c.text {
+			meta.i32(6, parquetUTF8)
+		} else if c.timestamp {
+			meta.i32(6, parquetTimestampMillis)
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, int64(rows))
+	meta.beginList(4, thriftStruct, 1)
+	meta.beginElem()
+	meta.beginList(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		meta.beginElem()
+		meta.b = append(meta.b, chunk.b...)
+		meta.endStruct()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(rows))
+	meta.endStruct()
+	meta.str(6, "pr-review")
+	meta.stop()
+
+	file = append(file, meta.b...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(meta.b)))
+	file = append(file, parquetMagic...)
+	_, err := out.Write(file)
+	return err
+}
+
+// Thrift compact protocol type codes
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftCompact encodes a struct in the Thrift compact protocol, which
+// Parquet uses for its metadata. Field IDs must be written in increasing
+// order within each struct.
+type thriftCompact struct {
+	b      []byte
+	lastID int16
+	stack  []int16
+}
+
+func (t *thriftCompact) field(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.b = append(t.b, byte(delta)<<4|typ)
+	} else {
+		t.b = append(t.b, typ)
+		t.b = binary.AppendVarint(t.b, int64(id))
+	}
+	t.lastID = id
+}
+
+func (t *thriftCompact) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.b = binary.AppendVarint(t.b, int64(v))
+}
+
+func (t *thriftCompact) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.b = binary.AppendVarint(t.b, v)
+}
+
+func (t *thriftCompact) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.b = binary.AppendUvarint(t.b, uint64(len(s)))
+	t.b = append(t.b, s...)
+}
+
+func (t *thriftCompact) beginList(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.b = append(t.b, byte(n)<<4|elem)
+	} else {
+		t.b = append(t.b, 0xf0|elem)
+		t.b = binary.AppendUvarint(t.b, uint64(n))
+	}
+}
+
+func (t *thriftCompact) listI32(id int16, vs []int32) {
+	t.beginList(id, thriftI32, len(vs))
+	for _, v := range vs {
+		t.b = binary.AppendVarint(t.b, int64(v))
+	}
+}
+
+func (t *thriftCompact) listString(id int16, vs []string) {
+	t.beginList(id, thriftBinary, len(vs))
+	for _, s := range vs {
+		t.b = binary.AppendUvarint(t.b, uint64(len(s)))
+		t.b = append(t.b, s...)
+	}
+}
+
+// beginStruct starts a struct-valued field; beginElem starts a struct list
+// element. Both are closed by endStruct.
+func (t *thriftCompact) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElem()
+}
+
+func (t *thriftCompact) beginElem() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftCompact) endStruct() {
+	t.stop()
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// stop ends the current struct
+func (t *thriftCompact) stop() {
+	t.b = append(t.b, 0)
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// thriftReader decodes Thrift compact structs into maps keyed by field ID
+type thriftReader struct {
+	b   []byte
+	pos int
+	t   *testing.T
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.b[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("bad varint at %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("bad uvarint at %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		header := r.b[r.pos]
+		r.pos++
+		n := int(header >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	r.t.Fatalf("unsupported thrift type %d at %d", typ, r.pos)
+	return nil
+}
+
+func (r *thriftReader) readStruct() map[int16]any {
+	fields := make(map[int16]any)
+	var id int16
+	for {
+		header := r.b[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+// TestWriteParquet tests that the file layout, metadata and PLAIN-encoded
+// column data can be read back
+func TestWriteParquet(t *testing.T) {
+	columns := []*parquetColumn{
+		{name: "id", ints: []int64{1, -2, 300}},
+		{name: "created_at", timestamp: true, ints: []int64{1700000000000, 0, 5}},
+		{name: "title", text: true, strs: []string{"a", "", "héllo"}},
+	}
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, columns); err != nil {
+		t.Fatalf("writeParquet() returned error: %v", err)
+	}
+
+	file := buf.Bytes()
+	if string(file[:4]) != parquetMagic || string(file[len(file)-4:]) != parquetMagic {
+		t.Fatal("file does not start and end with PAR1")
+	}
+	metaLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	metaStart := len(file) - 8 - metaLen
+	r := &thriftReader{b: file[:len(file)-8], pos: metaStart, t: t}
+	meta := r.readStruct()
+	if r.pos != len(file)-8 {
+		t.Fatalf("metadata decoded to %d, want %d", r.pos, len(file)-8)
+	}
+
+	if meta[3] != int64(3) {
+		t.Errorf("num_rows = %v, want 3", meta[3])
+	}
+	schema := meta[2].([]any)
+	if len(schema) != 4 || schema[0].(map[int16]any)[5] != int64(3) {
+		t.Fatalf("schema = %v, want a root with 3 children", schema)
+	}
+	wantTypes := []int64{parquetInt64, parquetInt64, parquetByteArray}
+	for i, c := range columns {
+		el := schema[i+1].(map[int16]any)
+		if el[4] != c.name || el[1] != wantTypes[i] || el[3] != int64(parquetRequired) {
+			t.Errorf("schema element %d = %v", i+1, el)
+		}
+	}
+	if el := schema[2].(map[int16]any); el[6] != int64(parquetTimestampMillis) {
+		t.Errorf("created_at converted type = %v, want TIMESTAMP_MILLIS", el[6])
+	}
+
+	chunks := meta[4].([]any)[0].(map[int16]any)[1].([]any)
+	if len(chunks) != len(columns) {
+		t.Fatalf("got %d column chunks, want %d", len(chunks), len(columns))
+	}
+	for i, c := range columns {
+		md := chunks[i].(map[int16]any)[3].(map[int16]any)
+		if path := md[3].([]any); len(path) != 1 || path[0] != c.name {
+			t.Errorf("column %d path = %v", i, path)
+		}
+
+		// Decode the page header, then compare the data to the values
+		page := &thriftReader{b: file, pos: int(md[9].(int64)), t: t}
+		header := page.readStruct()
+		size := int(header[2].(int64))
+		if n := header[5].(map[int16]any)[1]; n != int64(3) {
+			t.Errorf("column %d page has %v values, want 3", i, n)
+		}
+		if int64(page.pos-int(md[9].(int64))+size) != md[6] {
+			t.Errorf("column %d chunk size = %v, want header plus data", i, md[6])
+		}
+		if data := file[page.pos : page.pos+size]; !bytes.Equal(data, c.plain()) {
+			t.Errorf("column %d data = %x, want %x", i, data, c.plain())
+		}
+	}
+}
+
+// TestWriteParquet_Empty tests that a table with no rows is still valid
+func TestWriteParquet_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, []*parquetColumn{{name: "id", ints: []int64{}}, {name: "s", text: true}}); err != nil {
+		t.Fatalf("writeParquet() returned error: %v", err)
+	}
+	file := buf.Bytes()
+	r := &thriftReader{b: file, pos: len(file) - 8 - int(binary.LittleEndian.Uint32(file[len(file)-8:])), t: t}
+	if meta := r.readStruct(); meta[3] != int64(0) {
+		t.Errorf("num_rows = %v, want 0", meta[3])
+	}
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mailSubject matches the Subject header of a patch sent as an email
+var mailSubject = regexp.MustCompile(`(?im)^subject:`)
+
+// hunkCounts matches the line counts of a hunk header, e.g.
+// "@@ -10,3 +10,4 @@"; a count left out is 1
+var hunkCounts = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// parsePatchFile reads the changes in a patch file, for -patch and -stdin:
+// one or more format-patch messages, a git diff or a plain unified diff as
+// diff -u writes it. Text above a diff, such as the description on a
+// ticket it was attached to, is kept as its commit message.
+func parsePatchFile(data string) (*branchChanges, error) {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	first, _, _ := strings.Cut(data, "\n")
+	headers, _, _ := strings.Cut(data, "\n\n")
+	var patches []*patch
+	if mboxSeparator.MatchString(first) || isHeaderLine(first) && mailSubject.MatchString(headers) {
+		for _, m := range splitMbox(data) {
+			patches = append(patches, parsePatch(m))
+		}
+	} else {
+		message, diff := splitPreamble(data)
+		patches = []*patch{{Message: strings.TrimSpace(message), Diff: gitHeaders(diff)}}
+	}
+
+	changes := &branchChanges{}
+	var diffs, files, messages []string
+	for _, p := range patches {
+		c := p.changes()
+		if c.Diff != "" {
+			diffs, files = append(diffs, strings.TrimSuffix(c.Diff, "\n")), append(files, c.ChangedFiles)
+		}
+		if c.CommitMessages = strings.TrimSpace(c.CommitMessages); c.CommitMessages != "" {
+			messages = append(messages, c.CommitMessages)
+		}
+	}
+	if diffs == nil {
+		return nil, fmt.Errorf("no diff found")
+	}
+	changes.Diff = strings.Join(diffs, "\n") + "\n"
+	changes.ChangedFiles = strings.Join(files, "\n")
+	changes.CommitMessages = strings.Join(messages, "\n\n")
+	return changes, nil
+}
+
+// splitPreamble splits the text above a diff from the diff, which starts
+// at its first diff, Index: or ---/+++ header line
+func splitPreamble(data string) (string, string) {
+	lines := strings.Split(data, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "Index: ") ||
+			strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			return strings.Join(lines[:i], "\n"), strings.Join(lines[i:], "\n")
+		}
+	}
+	return data, ""
+}
+
+// gitHeaders gives each file of a plain unified diff the headers git diff
+// would have written, so the rest of the review reads it as a git diff.
+// Lines between files, such as Index: and diff -u command lines, are
+// dropped, and the first directory of each path is stripped, as patch -p1
+// would. A git diff is returned as it is.
+func gitHeaders(diff string) string {
+	if strings.HasPrefix(diff, "diff --git ") || strings.Contains(diff, "\ndiff --git ") {
+		return diff
+	}
+	lines := strings.Split(diff, "\n")
+	var out []string
+	oldLeft, newLeft := 0, 0
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case oldLeft > 0 || newLeft > 0:
+			// Inside a hunk, where "--- " is a removed line
+			switch {
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, "+"):
+				newLeft--
+			case strings.HasPrefix(line, `\`):
+			default:
+				oldLeft, newLeft = oldLeft-1, newLeft-1
+			}
+			out = append(out, line)
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			oldPath, newPath := patchPath(line[4:]), patchPath(lines[i+1][4:])
+			switch {
+			case oldPath == "/dev/null":
+				out = append(out, "diff --git a/"+newPath+" b/"+newPath, "new file mode 100644", "--- /dev/null", "+++ b/"+newPath)
+			case newPath == "/dev/null":
+				out = append(out, "diff --git a/"+oldPath+" b/"+oldPath, "deleted file mode 100644", "--- a/"+oldPath, "+++ /dev/null")
+			default:
+				out = append(out, "diff --git a/"+oldPath+" b/"+newPath, "--- a/"+oldPath, "+++ b/"+newPath)
+			}
+			i++
+		case strings.HasPrefix(line, "@@"):
+			oldLeft, newLeft = 1, 1
+			if m := hunkCounts.FindStringSubmatch(line); m != nil {
+				if m[1] != "" {
+					oldLeft, _ = strconv.Atoi(m[1])
+				}
+				if m[2] != "" {
+					newLeft, _ = strconv.Atoi(m[2])
+				}
+			}
+			out = append(out, line)
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" after a hunk's last line
+			out = append(out, line)
+		}
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// patchPath reads the path of a ---/+++ line of a plain unified diff,
+// without the timestamp diff -u appends after a tab or the first directory
+func patchPath(name string) string {
+	name, _, _ = strings.Cut(name, "\t")
+	name = strings.TrimSpace(name)
+	if name == "/dev/null" {
+		return name
+	}
+	if _, rest, ok := strings.Cut(name, "/"); ok && rest != "" {
+		return rest
+	}
+	return name
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParsePatchFile tests reading the changes of a plain unified diff, a
+// git diff and a format-patch series
+func TestParsePatchFile(t *testing.T) {
+	// diff -ruN output attached to a ticket, with a removed "-- " comment
+	// that must not be read as a file header
+	plain := "Fixes the off-by-one in the pager.\n\n" +
+		"diff -ruN old/pager.c new/pager.c\n" +
+		"--- old/pager.c\t2024-10-01 10:00:00.000000000 +0000\n" +
+		"+++ new/pager.c\t2024-10-02 10:00:00.000000000 +0000\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" int pages(int n) {\n" +
+		"--- n counts from one\n" +
+		"++++ n counts from zero\n" +
+		" }\n" +
+		"diff -ruN old/NEWS new/NEWS\n" +
+		"--- old/NEWS\t1970-01-01 00:00:00.000000000 +0000\n" +
+		"+++ new/NEWS\t2024-10-02 10:00:00.000000000 +0000\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+Pager fix\n" +
+		"\\ No newline at end of file\n"
+	changes, err := parsePatchFile(strings.ReplaceAll(plain, "old/NEWS\t1970", "/dev/null\t1970"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.CommitMessages != "Fixes the off-by-one in the pager." {
+		t.Errorf("CommitMessages = %q", changes.CommitMessages)
+	}
+	if changes.ChangedFiles != "M\tpager.c\nA\tNEWS" {
+		t.Errorf("ChangedFiles = %q", changes.ChangedFiles)
+	}
+	for _, want := range []string{"diff --git a/pager.c b/pager.c\n--- a/pager.c\n+++ b/pager.c\n", "\n--- n counts from one\n", "diff --git a/NEWS b/NEWS\nnew file mode 100644\n--- /dev/null\n", "\\ No newline at end of file\n"} {
+		if !strings.Contains(changes.Diff, want) {
+			t.Errorf("Diff missing %q:\n%s", want, changes.Diff)
+		}
+	}
+	if strings.Contains(changes.Diff, "diff -ruN") {
+		t.Errorf("Diff kept the diff -ruN lines:\n%s", changes.Diff)
+	}
+
+	// A git diff is kept as it is
+	git := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-x\n+y\n"
+	if changes, err = parsePatchFile(git); err != nil || changes.Diff != git || changes.CommitMessages != "" {
+		t.Errorf("parsePatchFile(git diff) = %+v, %v", changes, err)
+	}
+
+	// A series' patches are reviewed together, with their messages
+	if changes, err = parsePatchFile(seriesMbox); err != nil {
+		t.Fatal(err)
+	}
+	if changes.ChangedFiles != "A\tretry.go\nM\tclient.go" {
+		t.Errorf("series ChangedFiles = %q", changes.ChangedFiles)
+	}
+	for _, want := range []string{"Add a retry helper", "retry: add Do", "client: retry requests"} {
+		if !strings.Contains(changes.CommitMessages, want) {
+			t.Errorf("series CommitMessages missing %q:\n%s", want, changes.CommitMessages)
+		}
+	}
+
+	if _, err := parsePatchFile("Just some text.\n"); err == nil {
+		t.Error("parsePatchFile(text) returned no error")
+	}
+}
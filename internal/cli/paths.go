@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Locations set with the -config, -cache-dir and -data-dir flags every
+// subcommand takes; empty means the default
+var (
+	configFlag   string
+	cacheDirFlag string
+	dataDirFlag  string
+)
+
+// pathOS is the platform whose conventions the default locations follow
+// (for tests)
+var pathOS = runtime.GOOS
+
+func addPathFlags(fs *flag.FlagSet) {
+	fs.StringVar(&configFlag, "config", "", "Global config file (default: $XDG_CONFIG_HOME/pr-review/config.yaml, or %APPDATA%\\pr-review\\config.yaml on Windows)")
+	fs.StringVar(&cacheDirFlag, "cache-dir", "", "Directory for cached uploads and policies (default: $XDG_CACHE_HOME/pr-review, or the platform cache directory)")
+	fs.StringVar(&dataDirFlag, "data-dir", "", "Directory for review history (default: $XDG_DATA_HOME/pr-review, or %LOCALAPPDATA%\\pr-review\\data on Windows)")
+}
+
+// resolvedPath is a location and what set it, e.g. "-data-dir" or
+// "$XDG_DATA_HOME"
+type resolvedPath struct {
+	Path   string
+	Source string
+}
+
+// configPath returns the global config file: -config, then
+// $XDG_CONFIG_HOME/pr-review, then %APPDATA%\pr-review on Windows or
+// ~/.config/pr-review elsewhere
+func configPath() (resolvedPath, error) {
+	if configFlag != "" {
+		return resolvedPath{configFlag, "-config"}, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return resolvedPath{filepath.Join(dir, "pr-review", globalConfigFile), "$XDG_CONFIG_HOME"}, nil
+	}
+	if dir := os.Getenv("APPDATA"); pathOS == "windows" && dir != "" {
+		return resolvedPath{filepath.Join(dir, "pr-review", globalConfigFile), "%APPDATA%"}, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return resolvedPath{}, fmt.Errorf("failed to locate home directory: %w", err)
+	}
+	return resolvedPath{filepath.Join(home, ".config", "pr-review", globalConfigFile), "default"}, nil
+}
+
+// dataPath returns the directory for persistent data: -data-dir, then
+// data_dir in the global config, then the XDG base directory spec
+// ($XDG_DATA_HOME/pr-review), then %LOCALAPPDATA%\pr-review\data on Windows
+// or ~/.local/share/pr-review elsewhere
+func dataPath() (resolvedPath, error) {
+	if dataDirFlag != "" {
+		return resolvedPath{dataDirFlag, "-data-dir"}, nil
+	}
+	cfg, err := loadGlobalConfig()
+	if err != nil {
+		return resolvedPath{}, err
+	}
+	if cfg.DataDir != "" {
+		return resolvedPath{cfg.DataDir, "data_dir in the global config"}, nil
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return resolvedPath{filepath.Join(dir, "pr-review"), "$XDG_DATA_HOME"}, nil
+	}
+	if dir := os.Getenv("LOCALAPPDATA"); pathOS == "windows" && dir != "" {
+		return resolvedPath{filepath.Join(dir, "pr-review", "data"), "%LOCALAPPDATA%"}, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return resolvedPath{}, fmt.Errorf("failed to locate home directory: %w", err)
+	}
+	return resolvedPath{filepath.Join(home, ".local", "share", "pr-review"), "default"}, nil
+}
+
+// cachePath returns the directory for cached data: -cache-dir, then
+// cache_dir in the global config, then the XDG base directory spec
+// ($XDG_CACHE_HOME/pr-review), then %LOCALAPPDATA%\pr-review\cache on
+// Windows or the platform cache directory elsewhere
+func cachePath() (resolvedPath, error) {
+	if cacheDirFlag != "" {
+		return resolvedPath{cacheDirFlag, "-cache-dir"}, nil
+	}
+	cfg, err := loadGlobalConfig()
+	if err != nil {
+		return resolvedPath{}, err
+	}
+	if cfg.CacheDir != "" {
+		return resolvedPath{cfg.CacheDir, "cache_dir in the global config"}, nil
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return resolvedPath{filepath.Join(dir, "pr-review"), "$XDG_CACHE_HOME"}, nil
+	}
+	if dir := os.Getenv("LOCALAPPDATA"); pathOS == "windows" && dir != "" {
+		return resolvedPath{filepath.Join(dir, "pr-review", "cache"), "%LOCALAPPDATA%"}, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return resolvedPath{}, fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return resolvedPath{filepath.Join(dir, "pr-review"), "default"}, nil
+}
+
+// dataDir returns the directory for persistent data (see dataPath)
+func dataDir() (string, error) {
+	p, err := dataPath()
+	return p.Path, err
+}
+
+// cacheDir returns the directory for cached data (see cachePath)
+func cacheDir() (string, error) {
+	p, err := cachePath()
+	return p.Path, err
+}
+
+// expandPath resolves a path from a config file: ~/ is the home directory,
+// and relative paths are relative to the file's directory
+func expandPath(path, base string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+// runPaths implements `pr-review paths`, printing the effective config file
+// and data and cache directories and what set each
+func runPaths(args []string) {
+	fs := flag.NewFlagSet("paths", flag.ExitOnError)
+	addPathFlags(fs)
+	parseFlags(fs, fs.Name(), args)
+
+	config, err := configPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := dataPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	cache, err := cachePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	configSource := config.Source
+	if _, err := os.Stat(config.Path); os.IsNotExist(err) {
+		configSource += "; not found"
+	}
+	fmt.Printf("config  %s (%s)\n", config.Path, configSource)
+	fmt.Printf("data    %s (%s)\n", data.Path, data.Source)
+	fmt.Printf("cache   %s (%s)\n", cache.Path, cache.Source)
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setPathFlags sets the path flags for the rest of the test
+func setPathFlags(t *testing.T, config, cache, data string) {
+	t.Helper()
+	configFlag, cacheDirFlag, dataDirFlag = config, cache, data
+	t.Cleanup(func() { configFlag, cacheDirFlag, dataDirFlag = "", "", "" })
+}
+
+// TestPathResolution tests the precedence of flags, the global config, the
+// XDG variables and the platform defaults
+func TestPathResolution(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+
+	// Defaults
+	if p, err := configPath(); err != nil || p.Path != filepath.Join(home, ".config", "pr-review", "config.yaml") {
+		t.Errorf("configPath() = %+v, %v", p, err)
+	}
+	if p, err := dataPath(); err != nil || p.Path != filepath.Join(home, ".local", "share", "pr-review") || p.Source != "default" {
+		t.Errorf("dataPath() = %+v, %v", p, err)
+	}
+
+	// XDG variables
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(xdg, "config"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(xdg, "data"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(xdg, "cache"))
+	if p, _ := dataPath(); p.Path != filepath.Join(xdg, "data", "pr-review") || p.Source != "$XDG_DATA_HOME" {
+		t.Errorf("dataPath() = %+v, want $XDG_DATA_HOME", p)
+	}
+	if p, _ := cachePath(); p.Path != filepath.Join(xdg, "cache", "pr-review") {
+		t.Errorf("cachePath() = %+v, want $XDG_CACHE_HOME", p)
+	}
+
+	// The global config's directories win over XDG, relative to the file
+	config := filepath.Join(xdg, "config", "pr-review", "config.yaml")
+	os.MkdirAll(filepath.Dir(config), 0755)
+	if err := os.WriteFile(config, []byte("data_dir: history\ncache_dir: ~/cache\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := dataPath(); p.Path != filepath.Join(filepath.Dir(config), "history") || p.Source != "data_dir in the global config" {
+		t.Errorf("dataPath() = %+v, want the global config's", p)
+	}
+	if p, _ := cachePath(); p.Path != filepath.Join(home, "cache") {
+		t.Errorf("cachePath() = %+v, want the global config's", p)
+	}
+
+	// Flags win over everything
+	setPathFlags(t, "", filepath.Join(xdg, "c"), filepath.Join(xdg, "d"))
+	if dir, _ := dataDir(); dir != filepath.Join(xdg, "d") {
+		t.Errorf("dataDir() = %q, want -data-dir", dir)
+	}
+	if dir, _ := cacheDir(); dir != filepath.Join(xdg, "c") {
+		t.Errorf("cacheDir() = %q, want -cache-dir", dir)
+	}
+
+	// A config file named with -config must exist
+	setPathFlags(t, filepath.Join(xdg, "missing.yaml"), "", "")
+	if _, err := dataPath(); err == nil || !strings.Contains(err.Error(), "missing.yaml") {
+		t.Errorf("dataPath() with a missing -config error = %v", err)
+	}
+}
+
+// TestPathResolution_Windows tests the Windows defaults
+func TestPathResolution_Windows(t *testing.T) {
+	goos := pathOS
+	pathOS = "windows"
+	t.Cleanup(func() { pathOS = goos })
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("APPDATA", filepath.Join(dir, "Roaming"))
+	t.Setenv("LOCALAPPDATA", filepath.Join(dir, "Local"))
+
+	if p, _ := configPath(); p.Path != filepath.Join(dir, "Roaming", "pr-review", "config.yaml") || p.Source != "%APPDATA%" {
+		t.Errorf("configPath() = %+v", p)
+	}
+	if p, _ := dataPath(); p.Path != filepath.Join(dir, "Local", "pr-review", "data") {
+		t.Errorf("dataPath() = %+v", p)
+	}
+	if p, _ := cachePath(); p.Path != filepath.Join(dir, "Local", "pr-review", "cache") {
+		t.Errorf("cachePath() = %+v", p)
+	}
+}
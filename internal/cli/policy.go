@@ -0,0 +1,360 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// policyEnv names the org policy location: an http(s) URL or a file path
+	policyEnv = "PR_REVIEW_POLICY"
+
+	// policyKeyEnv holds the base64 ed25519 public key the policy must be signed with
+	policyKeyEnv = "PR_REVIEW_POLICY_KEY"
+
+	// policyFetchTimeout bounds fetching the policy and its signature
+	policyFetchTimeout = 30 * time.Second
+)
+
+// redactedText replaces text matched by a policy redaction rule
+const redactedText = "[REDACTED]"
+
+// Policy is a centrally distributed, signed org policy. Its settings take
+// precedence over repository config and flags, which cannot weaken them.
+type Policy struct {
+	// AllowedModels are glob patterns (e.g. "claude-sonnet-4-5*") for the
+	// models reviews may use; empty allows any model
+	AllowedModels []string `yaml:"allowed_models"`
+
+	// AllowedProviders lists the API providers reviews may use; empty allows any
+	AllowedProviders []string `yaml:"allowed_providers"`
+
+	// Redact lists regular expressions whose matches are replaced before
+	// anything is sent to a provider
+	Redact []string `yaml:"redact"`
+
+	// SeverityCalibration rules are applied before the repository's own
+	// rules, so a repository cannot downgrade them
+	SeverityCalibration []CalibrationRule `yaml:"severity_calibration"`
+
+	// SeverityRules are applied along with the repository's own; since
+	// rules only escalate, a repository can't weaken them
+	SeverityRules []SeverityRule `yaml:"severity_rules"`
+
+	// MaxCost (USD) and MaxInputTokens cap what a review may spend;
+	// -max-cost and -max-input-tokens can only lower them
+	MaxCost        float64 `yaml:"max_cost,omitempty"`
+	MaxInputTokens int     `yaml:"max_input_tokens,omitempty"`
+
+	// FailOn is the least strict review gate allowed: reviews fail on
+	// findings at least this severe, whatever fail_on or -fail-on say
+	FailOn *Severity `yaml:"fail_on,omitempty"`
+
+	redactPatterns []*regexp.Regexp
+}
+
+// parsePolicy parses and validates a policy document
+func parsePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	for _, pattern := range p.AllowedModels {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid allowed_models pattern %q: %w", pattern, err)
+		}
+	}
+	for _, expr := range p.Redact {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", expr, err)
+		}
+		p.redactPatterns = append(p.redactPatterns, re)
+	}
+	for i, rule := range p.SeverityRules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid severity_rules[%d]: %w", i, err)
+		}
+	}
+	if p.MaxCost < 0 || p.MaxInputTokens < 0 {
+		return nil, fmt.Errorf("max_cost and max_input_tokens must not be negative")
+	}
+	return &p, nil
+}
+
+// loadPolicy loads the org policy named by $PR_REVIEW_POLICY, verifying its
+// signature against $PR_REVIEW_POLICY_KEY. It returns nil if no policy is
+// configured. If the policy cannot be fetched, the last verified copy in the
+// cache is used; otherwise loading fails, since running without a mandated
+// policy would silently weaken it.
+func loadPolicy() (*Policy, error) {
+	source := os.Getenv(policyEnv)
+	if source == "" {
+		return nil, nil
+	}
+	key, err := decodePolicyKey(os.Getenv(policyKeyEnv))
+	if err != nil {
+		return nil, err
+	}
+
+	cache := ""
+	if dir, err := cacheDir(); err == nil {
+		sum := sha256.Sum256([]byte(source))
+		cache = filepath.Join(dir, "policy", hex.EncodeToString(sum[:8])+".yaml")
+	}
+
+	data, sig, fetchErr := fetchPolicy(source)
+	if fetchErr == nil {
+		if err := verifyPolicy(key, data, sig); err != nil {
+			return nil, fmt.Errorf("policy %s: %w", source, err)
+		}
+		if cache != "" {
+			if err := cachePolicy(cache, data, sig); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Could not cache policy: %v\n", err)
+			}
+		}
+		return parsePolicy(data)
+	}
+
+	if cache == "" {
+		return nil, fmt.Errorf("failed to fetch policy %s: %w", source, fetchErr)
+	}
+	data, sig, err = readPolicyFiles(cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch policy %s and no cached copy is available: %w", source, fetchErr)
+	}
+	if err := verifyPolicy(key, data, sig); err != nil {
+		return nil, fmt.Errorf("cached policy %s: %w", cache, err)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: Could not fetch policy (%v); using cached copy\n", fetchErr)
+	return parsePolicy(data)
+}
+
+// decodePolicyKey decodes a base64 ed25519 public key
+func decodePolicyKey(encoded string) (ed25519.PublicKey, error) {
+	if encoded == "" {
+		return nil, fmt.Errorf("%s is set but %s is not; the policy signature cannot be checked", policyEnv, policyKeyEnv)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%s is not a base64 ed25519 public key", policyKeyEnv)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// verifyPolicy checks a base64 detached ed25519 signature of data
+func verifyPolicy(key ed25519.PublicKey, data, sig []byte) error {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	if !ed25519.Verify(key, data, raw) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+// fetchPolicy reads a policy and its detached signature (the same location
+// with ".sig" appended) from a URL or the filesystem
+func fetchPolicy(source string) (data, sig []byte, err error) {
+	if !strings.HasPrefix(source, "https://") && !strings.HasPrefix(source, "http://") {
+		return readPolicyFiles(source)
+	}
+
+	client := &http.Client{Timeout: policyFetchTimeout}
+	get := func(url string) ([]byte, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	}
+	if data, err = get(source); err != nil {
+		return nil, nil, err
+	}
+	if sig, err = get(source + ".sig"); err != nil {
+		return nil, nil, err
+	}
+	return data, sig, nil
+}
+
+func readPolicyFiles(file string) (data, sig []byte, err error) {
+	if data, err = os.ReadFile(file); err != nil {
+		return nil, nil, err
+	}
+	if sig, err = os.ReadFile(file + ".sig"); err != nil {
+		return nil, nil, err
+	}
+	return data, sig, nil
+}
+
+func cachePolicy(file string, data, sig []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(file+".sig", sig, 0644)
+}
+
+// checkModel returns an error if the policy does not allow model
+func (p *Policy) checkModel(model string) error {
+	if p == nil || len(p.AllowedModels) == 0 {
+		return nil
+	}
+	for _, pattern := range p.AllowedModels {
+		if ok, _ := path.Match(pattern, model); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %q is not allowed by the org policy (allowed: %s)", model, strings.Join(p.AllowedModels, ", "))
+}
+
+// checkProvider returns an error if the policy does not allow provider
+func (p *Policy) checkProvider(provider string) error {
+	if p == nil || len(p.AllowedProviders) == 0 {
+		return nil
+	}
+	for _, allowed := range p.AllowedProviders {
+		if strings.EqualFold(allowed, provider) {
+			return nil
+		}
+	}
+	return fmt.Errorf("provider %q is not allowed by the org policy (allowed: %s)", provider, strings.Join(p.AllowedProviders, ", "))
+}
+
+// redact replaces everything matched by the policy's redaction rules
+func (p *Policy) redact(text string) string {
+	if p == nil {
+		return text
+	}
+	for _, re := range p.redactPatterns {
+		text = re.ReplaceAllString(text, redactedText)
+	}
+	return text
+}
+
+// enforce applies the policy on top of the repository config: policy
+// calibration rules come first, so they win over the repository's own, and
+// the policy's gate holds however the config's is set, now or later
+func (p *Policy) enforce(cfg *Config) {
+	if p == nil {
+		return
+	}
+	cfg.SeverityCalibration = append(append([]CalibrationRule(nil), p.SeverityCalibration...), cfg.SeverityCalibration...)
+	cfg.SeverityRules = append(append([]SeverityRule(nil), p.SeverityRules...), cfg.SeverityRules...)
+	cfg.policyFailOn = p.FailOn
+}
+
+// mustLoadPolicy loads the org policy and checks the requested provider and
+// model against it, exiting with exitUsage on failure
+func mustLoadPolicy(provider, model string) *Policy {
+	policy, err := loadPolicy()
+	if err != nil {
+		fail(exitUsage, "Error loading org policy: %v", err)
+	}
+	if err := policy.checkProvider(provider); err != nil {
+		fail(exitUsage, "Error: %v", err)
+	}
+	if err := policy.checkModel(model); err != nil {
+		fail(exitUsage, "Error: %v", err)
+	}
+	return policy
+}
+
+// runPolicy implements `pr-review policy keygen|sign|show` for maintaining
+// the org policy
+func runPolicy(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: pr-review policy keygen | sign -key <private-key-file> <policy.yaml> | show")
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+
+	switch args[0] {
+	case "keygen":
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating key: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Public key (set %s to this):\n%s\n\n", policyKeyEnv, base64.StdEncoding.EncodeToString(public))
+		fmt.Printf("Private key (keep secret; used by policy sign):\n%s\n", base64.StdEncoding.EncodeToString(private))
+
+	case "sign":
+		fs := flag.NewFlagSet("policy sign", flag.ExitOnError)
+		keyFile := fs.String("key", "", "File containing the base64 ed25519 private key")
+		parseFlags(fs, fs.Name(), args[1:])
+		if *keyFile == "" || fs.NArg() != 1 {
+			usage()
+		}
+		sig, err := signPolicyFile(*keyFile, fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error signing policy: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Signature written to: %s\n", sig)
+
+	case "show":
+		policy, err := loadPolicy()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading org policy: %v\n", err)
+			os.Exit(1)
+		}
+		if policy == nil {
+			fmt.Printf("No org policy configured (%s is not set).\n", policyEnv)
+			return
+		}
+		out, _ := yaml.Marshal(policy)
+		fmt.Printf("Org policy from %s:\n\n%s", os.Getenv(policyEnv), out)
+
+	default:
+		usage()
+	}
+}
+
+// signPolicyFile writes policyFile+".sig" signed with the private key in
+// keyFile, returning the signature's path
+func signPolicyFile(keyFile, policyFile string) (string, error) {
+	encoded, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(raw) != ed25519.PrivateKeySize {
+		return "", fmt.Errorf("%s is not a base64 ed25519 private key", keyFile)
+	}
+	data, err := os.ReadFile(policyFile)
+	if err != nil {
+		return "", err
+	}
+	if _, err := parsePolicy(data); err != nil {
+		return "", err
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(ed25519.PrivateKey(raw), data))
+	sigFile := policyFile + ".sig"
+	return sigFile, os.WriteFile(sigFile, []byte(sig+"\n"), 0644)
+}
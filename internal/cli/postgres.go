@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresMigrations are applied in order to a Postgres database; the
+// pr_review_schema table records how many have run. Postgres history
+// started with the SQLite schema's fourth version. Only ever append to this
+// list.
+var postgresMigrations = []string{
+	`CREATE TABLE reviews (
+		id            BIGSERIAL PRIMARY KEY,
+		created_at    TEXT NOT NULL,
+		repo          TEXT NOT NULL,
+		branch        TEXT NOT NULL,
+		base_ref      TEXT NOT NULL,
+		base_sha      TEXT NOT NULL,
+		head_sha      TEXT NOT NULL,
+		model         TEXT NOT NULL,
+		review        BYTEA NOT NULL,
+		findings      BYTEA NOT NULL,
+		input_tokens  INTEGER NOT NULL,
+		output_tokens INTEGER NOT NULL,
+		lines_changed INTEGER NOT NULL DEFAULT 0,
+		duration_ms   BIGINT NOT NULL DEFAULT 0,
+		team          TEXT NOT NULL DEFAULT '',
+		compressed    BOOLEAN NOT NULL DEFAULT FALSE,
+		kind          TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX reviews_range ON reviews (repo, base_sha, head_sha);
+	CREATE TABLE cache (
+		key        TEXT PRIMARY KEY,
+		value      BYTEA NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`,
+	`ALTER TABLE reviews ADD COLUMN diff_hash TEXT NOT NULL DEFAULT '';
+	ALTER TABLE reviews ADD COLUMN cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0;
+	CREATE INDEX reviews_diff ON reviews (repo, diff_hash);`,
+	`ALTER TABLE cache ADD COLUMN expires_at TIMESTAMPTZ;
+	CREATE INDEX cache_expiry ON cache (expires_at);`,
+}
+
+// migratePostgres brings a Postgres database's schema up to date. Replicas
+// starting together take turns through an advisory lock, and a migration
+// that fails leaves no trace, since Postgres DDL is transactional.
+func (h *historyStore) migratePostgres() error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('pr-review schema'))`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS pr_review_schema (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	var version int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM pr_review_schema`).Scan(&version); err != nil {
+		return err
+	}
+	if version >= len(postgresMigrations) {
+		return nil
+	}
+	for i := version; i < len(postgresMigrations); i++ {
+		if _, err := tx.Exec(postgresMigrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM pr_review_schema`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO pr_review_schema (version) VALUES ($1)`, len(postgresMigrations)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// postgresStorage keeps the history of every repository, and the shared
+// cache, in one Postgres database
+type postgresStorage struct {
+	url string
+}
+
+// open connects to the database, limiting the store to repo's reviews
+// unless repo is ""
+func (s *postgresStorage) open(repo string) (*historyStore, error) {
+	db, err := sql.Open("postgres", s.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Postgres history: %w", err)
+	}
+	h := &historyStore{db: db, postgres: true, repo: repo}
+	if err := h.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate Postgres history: %w", err)
+	}
+	return h, nil
+}
+
+func (s *postgresStorage) OpenHistory(repo string) (reviewHistory, error) {
+	h, err := s.open(repo)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (s *postgresStorage) EachHistory(repo string, fn func(reviewHistory) error) error {
+	h, err := s.open("")
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	repos := []string{repo}
+	if repo == "" {
+		if repos, err = postgresRepos(h.db); err != nil {
+			return err
+		}
+	}
+	for _, repo := range repos {
+		view := *h
+		view.repo = repo
+		if err := fn(&view); err != nil {
+			return fmt.Errorf("%s: %w", repo, err)
+		}
+	}
+	return nil
+}
+
+// postgresRepos lists the repositories with reviews in the database
+func postgresRepos(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT DISTINCT repo FROM reviews ORDER BY repo`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	}
+	defer rows.Close()
+	var repos []string
+	for rows.Next() {
+		var repo string
+		if err := rows.Scan(&repo); err != nil {
+			return nil, err
+		}
+		repos = append(repos, repo)
+	}
+	return repos, rows.Err()
+}
+
+func (s *postgresStorage) OpenCache() (cacheStore, error) {
+	h, err := s.open("")
+	if err != nil {
+		return nil, err
+	}
+	return postgresCache{db: h.db}, nil
+}
+
+// postgresCache is the cache table of a Postgres database
+type postgresCache struct {
+	db *sql.DB
+}
+
+func (c postgresCache) Get(key string) ([]byte, error) {
+	var value []byte
+	err := c.db.QueryRow(`SELECT value FROM cache WHERE key = $1 AND (expires_at IS NULL OR expires_at > now())`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return value, err
+}
+
+// Put also removes the values that have expired, which nothing else would
+func (c postgresCache) Put(key string, value []byte, ttl time.Duration) error {
+	var expires *time.Time
+	if ttl > 0 {
+		t := time.Now().Add(ttl)
+		expires = &t
+	}
+	if _, err := c.db.Exec(`INSERT INTO cache (key, value, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = now(), expires_at = excluded.expires_at`, key, value, expires); err != nil {
+		return err
+	}
+	_, err := c.db.Exec(`DELETE FROM cache WHERE expires_at < now()`)
+	return err
+}
+
+func (c postgresCache) Close() error {
+	return c.db.Close()
+}
@@ -0,0 +1,259 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// maxFollowUpCommits bounds how many later commits to the merged files are
+// listed in a postmortem prompt
+const maxFollowUpCommits = 30
+
+// mergeSubjectPattern finds the branch name in the subjects git, GitHub and
+// GitLab give merge commits
+var mergeSubjectPattern = regexp.MustCompile(`^Merge (?:branch '([^']+)'|pull request #\d+ from \S+?/(\S+)|remote-tracking branch '[^/']+/([^']+)')`)
+
+// mergedChange is the branch a merge commit brought in
+type mergedChange struct {
+	Merge   string // the merge commit
+	Subject string
+	Branch  string // the merged branch, if the subject names it
+	Base    string // where the branch forked from its target
+	Head    string // the branch's last commit
+	Squash  bool   // the change was squashed or rebased into one commit
+}
+
+// resolveMerge reconstructs the branch a merge commit merged: the diff
+// reviewers saw is from the merge base of its parents to its second parent.
+// A commit with one parent is taken to be a squash or rebase merge of
+// everything it changes.
+func resolveMerge(commit string) (*mergedChange, error) {
+	output, err := gitCommand("rev-list", "--parents", "-n", "1", commit+"^{commit}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("unknown commit %q", commit)
+	}
+	shas := strings.Fields(string(output))
+	if len(shas) < 2 {
+		return nil, fmt.Errorf("%s is a root commit; there is no change to look back at", shortSHA(shas[0]))
+	}
+
+	m := &mergedChange{Merge: shas[0]}
+	subject, err := gitCommand("log", "-1", "--format=%s", m.Merge).Output()
+	if err == nil {
+		m.Subject = strings.TrimSpace(string(subject))
+	}
+	if len(shas) == 2 {
+		m.Base, m.Head, m.Squash = shas[1], m.Merge, true
+		return m, nil
+	}
+
+	base, err := gitCommand("merge-base", shas[1], shas[2]).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find where the merged branch forked: %w", err)
+	}
+	m.Base, m.Head = strings.TrimSpace(string(base)), shas[2]
+	if match := mergeSubjectPattern.FindStringSubmatch(m.Subject); match != nil {
+		m.Branch = match[1] + match[2] + match[3]
+	}
+	return m, nil
+}
+
+// followUpCommits lists commits made after the merge that touched the
+// merged files, newest first; fixes among them often point at what escaped
+func followUpCommits(merge string, files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	args := append([]string{"log", "--no-merges", fmt.Sprintf("-%d", maxFollowUpCommits),
+		"--format=%h - %s (%an, %as)", merge + "..HEAD", "--"}, files...)
+	output, err := gitCommand(args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// postmortemRubric asks what review should have caught, rather than for a
+// review of the change as if it were still open
+const postmortemRubric = `You are conducting a postmortem of a code change that has already been
+merged. The diff below is exactly what reviewers saw before it merged. With
+the benefit of hindsight, identify what a careful code review should have
+caught:
+
+1. **Escaped Defects**: Bugs, regressions, security issues, data loss, race
+   conditions, or broken edge cases the change introduced. Point to the lines
+   responsible.
+2. **Why It Was Missable**: For each, explain what made it easy to overlook:
+   a large diff, code outside the diff, a missing test, a misleading name.
+3. **What Would Have Caught It**: The test, lint rule, type, checklist item
+   or review practice that would have stopped it before merge.
+4. **Process Lessons**: Patterns across the findings that the team's review
+   process should change.
+
+Only report issues that matter in production; leave out style nits. If later
+commits to the same files are listed, use them as hints — fixes and reverts
+among them often mark defects that escaped — but judge the original diff.`
+
+// buildPostmortemPrompt asks for a postmortem of a merged change
+func buildPostmortemPrompt(m *mergedChange, changes *branchChanges, followUps, defect, additionalContext string) string {
+	prompt := postmortemRubric + "\n\n---\n\n"
+
+	prompt += "## Merge\n" + shortSHA(m.Merge) + " " + m.Subject + "\n\n"
+	if defect != "" {
+		prompt += "## Known Defect\nThis defect was found after the change merged. Trace it to the lines that introduced it and explain how review could have caught it:\n\n" + defect + "\n\n"
+	}
+	prompt += "## Changed Files\n```\n" + changes.ChangedFiles + "\n```\n\n"
+	if changes.CommitMessages != "" {
+		prompt += "## Commit Messages\n```\n" + changes.CommitMessages + "\n```\n\n"
+	}
+	prompt += "## Full Diff\n```diff\n" + changes.Diff + "\n```\n"
+	if followUps != "" {
+		prompt += "\n## Later Commits to These Files\n```\n" + followUps + "\n```\n"
+	}
+	if additionalContext != "" {
+		prompt += "\n## Additional Context\n" + additionalContext + "\n"
+	}
+
+	prompt += "\n\nPlease provide your postmortem.\n\n" + review.FindingsInstructions
+	return prompt
+}
+
+// formatPostmortemReport renders the postmortem of a merged change
+func formatPostmortemReport(m *mergedChange, linesChanged int, assessment string, findings []Finding) string {
+	var b strings.Builder
+	title := m.Subject
+	if title == "" {
+		title = shortSHA(m.Merge)
+	}
+	fmt.Fprintf(&b, "# Postmortem: %s\n\n", title)
+	if m.Squash {
+		fmt.Fprintf(&b, "`%s` has a single parent, so it is reviewed as a squashed change against `%s`", shortSHA(m.Merge), shortSHA(m.Base))
+	} else {
+		fmt.Fprintf(&b, "Merge `%s` brought in `%s..%s`", shortSHA(m.Merge), shortSHA(m.Base), shortSHA(m.Head))
+		if m.Branch != "" {
+			fmt.Fprintf(&b, " from `%s`", m.Branch)
+		}
+	}
+	fmt.Fprintf(&b, " (%s). This is a look back at what review could have caught, with hindsight the original reviewers didn't have.\n\n", plural(linesChanged, "changed line"))
+	b.WriteString(assessment)
+	if rendered := renderFindings(findings, groupBySeverity); rendered != "" {
+		b.WriteString("\n\n" + rendered)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// runPostmortem implements `pr-review postmortem`, reviewing an already
+// merged change for the defects that escaped its review
+func runPostmortem(args []string) {
+	fs := flag.NewFlagSet("postmortem", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	defect := fs.String("defect", "", "Description of a defect found after the merge, to trace back to the change")
+	outputFile := fs.String("output", "POSTMORTEM.md", "Output file for the postmortem (will create numbered backups if exists)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: pr-review postmortem [flags] <merge-commit>")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, fs.Name(), args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Error: postmortem requires a merge commit")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	client, policy := common.provider()
+	started := time.Now()
+
+	m, err := resolveMerge(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	changes, err := collectChanges(m.Base, m.Head)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting diff: %v\n", err)
+		os.Exit(1)
+	}
+	if changes.Diff == "" {
+		fmt.Printf("%s merged no changes.\n", shortSHA(m.Merge))
+		return
+	}
+	fmt.Printf("🔍 Looking back at %s %s (%s..%s)\n\n", shortSHA(m.Merge), m.Subject, shortSHA(m.Base), shortSHA(m.Head))
+
+	repoRoot, repo := getRepoRoot(), getRepoIdentity()
+	cfg := &Config{}
+	if repoRoot != "" {
+		if cfg, err = loadConfig(filepath.Join(repoRoot, repoConfigFile)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	followUps := followUpCommits(m.Merge, gitdiff.Files(changes.Diff))
+	prompt := policy.redact(buildPostmortemPrompt(m, changes, followUps, *defect, common.readContext(client, policy)))
+
+	fmt.Printf("🤖 Asking %s what review should have caught...\n\n", client.Name())
+	response, usage, err := client.Complete(prompt, common.completionOptions())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error calling %s API: %v\n", client.Name(), err)
+		os.Exit(1)
+	}
+	assessment, findings, err := review.ExtractFindings(response)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not parse structured findings: %v\n", err)
+	}
+	if n := checkEvidence(findings, changes.Diff); n > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s quote evidence that is not in the diff\n", plural(n, "finding"))
+	}
+
+	linesChanged := gitdiff.Size(changes.Diff)
+	report := formatPostmortemReport(m, linesChanged, assessment, findings)
+	if err := writeReviewToFile(*outputFile, report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing postmortem to file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Postmortem written to: %s\n\n", *outputFile)
+
+	// Keep the postmortem with the reviews, tagged so escaped defects can be
+	// analyzed apart from what was found before merge
+	history, err := openHistoryFor(repo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Review history unavailable: %v\n", err)
+	} else {
+		cost, _ := usageCost(*common.model, usage)
+		err := history.Record(&reviewRecord{
+			Repo:         repo,
+			Branch:       m.Branch,
+			BaseRef:      m.Merge,
+			BaseSHA:      m.Base,
+			HeadSHA:      m.Head,
+			Model:        *common.model,
+			Review:       report,
+			Findings:     findings,
+			InputTokens:  usage.InputTokens,
+			OutputTokens: usage.OutputTokens,
+			LinesChanged: linesChanged,
+			DurationMS:   time.Since(started).Milliseconds(),
+			Team:         cfg.Team,
+			Kind:         reviewKindPostmortem,
+			DiffHash:     diffHash(changes.Diff),
+			CostUSD:      cost,
+		})
+		history.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not save postmortem to history: %v\n", err)
+		}
+	}
+	common.printTranscript()
+
+	printReport("POSTMORTEM", report, usage)
+}
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// TestResolveMerge tests reconstructing the branch a merge commit merged,
+// and treating a single-parent commit as a squash merge
+func TestResolveMerge(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := gitCommand(args...)
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q", "-b", "main")
+	write("app.go", "package app\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	fork := git("rev-parse", "HEAD")
+	git("checkout", "-q", "-b", "retry-uploads")
+	write("app.go", "package app\n\nfunc Upload() { retry(upload) }\n")
+	git("commit", "-q", "-am", "Retry uploads")
+	branchHead := git("rev-parse", "HEAD")
+	git("checkout", "-q", "main")
+	write("other.go", "package app\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "Unrelated change on main")
+	git("merge", "-q", "--no-ff", "retry-uploads", "-m", "Merge branch 'retry-uploads'")
+	merge := git("rev-parse", "HEAD")
+	write("app.go", "package app\n\nfunc Upload() { retry(upload, 3) }\n")
+	git("commit", "-q", "-am", "Fix unbounded upload retries")
+
+	m, err := resolveMerge(merge)
+	if err != nil {
+		t.Fatalf("resolveMerge() returned error: %v", err)
+	}
+	if m.Squash || m.Base != fork || m.Head != branchHead || m.Branch != "retry-uploads" || m.Subject != "Merge branch 'retry-uploads'" {
+		t.Errorf("resolveMerge() = %+v", m)
+	}
+	changes, err := collectChanges(m.Base, m.Head)
+	if err != nil {
+		t.Fatalf("collectChanges() returned error: %v", err)
+	}
+	if !strings.Contains(changes.Diff, "+func Upload() { retry(upload) }") || strings.Contains(changes.Diff, "other.go") {
+		t.Errorf("branch diff includes the wrong changes:\n%s", changes.Diff)
+	}
+	if got := followUpCommits(m.Merge, gitdiff.Files(changes.Diff)); !strings.HasSuffix(strings.Split(got, " (")[0], "Fix unbounded upload retries") {
+		t.Errorf("followUpCommits() = %q", got)
+	}
+
+	m, err = resolveMerge("HEAD")
+	if err != nil || !m.Squash || m.Base != merge || m.Branch != "" {
+		t.Errorf("resolveMerge() of a single-parent commit = %+v, %v", m, err)
+	}
+	if _, err := resolveMerge(fork); err == nil {
+		t.Error("resolveMerge() of the root commit didn't return an error")
+	}
+	if _, err := resolveMerge("no-such-commit"); err == nil {
+		t.Error("resolveMerge() of an unknown commit didn't return an error")
+	}
+}
+
+// TestMergeSubjectPattern tests finding the merged branch in merge commit
+// subjects
+func TestMergeSubjectPattern(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    string
+	}{
+		{"Merge branch 'retry-uploads'", "retry-uploads"},
+		{"Merge branch 'feature/x' into main", "feature/x"},
+		{"Merge pull request #42 from acme/fix/timeouts", "fix/timeouts"},
+		{"Merge remote-tracking branch 'origin/release-1.2'", "release-1.2"},
+		{"Retry uploads (#42)", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if match := mergeSubjectPattern.FindStringSubmatch(tt.subject); match != nil {
+			got = match[1] + match[2] + match[3]
+		}
+		if got != tt.want {
+			t.Errorf("branch of %q = %q, want %q", tt.subject, got, tt.want)
+		}
+	}
+}
+
+// TestPostmortemReport tests the postmortem prompt and report
+func TestPostmortemReport(t *testing.T) {
+	m := &mergedChange{Merge: "3333333333", Subject: "Merge branch 'retry-uploads'", Branch: "retry-uploads", Base: "1111111111", Head: "2222222222"}
+	changes := &branchChanges{ChangedFiles: "M\tapp.go", Diff: "+retry(upload)\n"}
+
+	prompt := buildPostmortemPrompt(m, changes, "abc1234 - Fix unbounded upload retries", "Uploads retry forever when the server is down", "")
+	for _, want := range []string{
+		"already been\nmerged",
+		"## Known Defect\n",
+		"Uploads retry forever",
+		"## Later Commits to These Files\n```\nabc1234 - Fix unbounded upload retries",
+		review.FindingsStartTag,
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt doesn't contain %q", want)
+		}
+	}
+	if strings.Contains(buildPostmortemPrompt(m, changes, "", "", ""), "## Known Defect") {
+		t.Error("prompt without -defect has a Known Defect section")
+	}
+
+	report := formatPostmortemReport(m, 12, "Retries are unbounded.", []Finding{{File: "app.go", Line: 3, Severity: SeverityHigh, Title: "Unbounded retries"}})
+	for _, want := range []string{
+		"# Postmortem: Merge branch 'retry-uploads'",
+		"Merge `3333333333` brought in `1111111111..2222222222` from `retry-uploads` (12 changed lines)",
+		"Retries are unbounded.",
+		"Unbounded retries",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report doesn't contain %q:\n%s", want, report)
+		}
+	}
+}
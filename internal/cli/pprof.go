@@ -0,0 +1,419 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// profile is the subset of a pprof profile (profile.proto) needed to find
+// hot functions
+type profile struct {
+	sampleTypes       []valueType
+	defaultSampleType string
+	samples           []profileSample
+	locations         map[uint64][]uint64 // location ID -> function IDs, innermost first
+	functions         map[uint64]profileFunction
+}
+
+type valueType struct {
+	Type string
+	Unit string
+}
+
+type profileSample struct {
+	locationIDs []uint64 // leaf first
+	values      []int64
+}
+
+type profileFunction struct {
+	Name     string
+	Filename string
+}
+
+// hotFunction is a function's share of a profile's samples
+type hotFunction struct {
+	Name     string
+	Filename string
+	Flat     int64 // samples where the function is the leaf
+	Cum      int64 // samples where the function is anywhere on the stack
+}
+
+// loadProfile reads a pprof profile, gzip-compressed or not
+func loadProfile(filename string) (*profile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress profile %s: %w", filename, err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("failed to decompress profile %s: %w", filename, err)
+		}
+	}
+
+	p, err := parseProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile %s: %w", filename, err)
+	}
+	return p, nil
+}
+
+// parseProfile decodes the protobuf encoding of a profile
+func parseProfile(data []byte) (*profile, error) {
+	p := &profile{
+		locations: make(map[uint64][]uint64),
+		functions: make(map[uint64]profileFunction),
+	}
+
+	// Strings are referenced by index into the string table, which may come
+	// after the messages that use it, so resolve them once decoding is done
+	var stringTable []string
+	type rawValueType struct{ typ, unit int64 }
+	var rawSampleTypes []rawValueType
+	var defaultSampleType int64
+	type rawFunction struct{ name, filename int64 }
+	rawFunctions := make(map[uint64]rawFunction)
+
+	err := decodeMessage(data, func(field int, value uint64, buf []byte) error {
+		switch field {
+		case 1: // sample_type
+			var vt rawValueType
+			err := decodeMessage(buf, func(field int, value uint64, _ []byte) error {
+				switch field {
+				case 1:
+					vt.typ = int64(value)
+				case 2:
+					vt.unit = int64(value)
+				}
+				return nil
+			})
+			rawSampleTypes = append(rawSampleTypes, vt)
+			return err
+		case 2: // sample
+			var s profileSample
+			err := decodeMessage(buf, func(field int, value uint64, buf []byte) error {
+				switch field {
+				case 1:
+					return appendPacked(&s.locationIDs, value, buf, func(v uint64) uint64 { return v })
+				case 2:
+					return appendPacked(&s.values, value, buf, func(v uint64) int64 { return int64(v) })
+				}
+				return nil
+			})
+			p.samples = append(p.samples, s)
+			return err
+		case 4: // location
+			var id uint64
+			var functionIDs []uint64
+			err := decodeMessage(buf, func(field int, value uint64, buf []byte) error {
+				switch field {
+				case 1:
+					id = value
+				case 4: // line
+					return decodeMessage(buf, func(field int, value uint64, _ []byte) error {
+						if field == 1 {
+							functionIDs = append(functionIDs, value)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			p.locations[id] = functionIDs
+			return err
+		case 5: // function
+			var id uint64
+			var fn rawFunction
+			err := decodeMessage(buf, func(field int, value uint64, _ []byte) error {
+				switch field {
+				case 1:
+					id = value
+				case 2:
+					fn.name = int64(value)
+				case 4:
+					fn.filename = int64(value)
+				}
+				return nil
+			})
+			rawFunctions[id] = fn
+			return err
+		case 6: // string_table
+			stringTable = append(stringTable, string(buf))
+		case 14: // default_sample_type
+			defaultSampleType = int64(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	str := func(i int64) string {
+		if i < 0 || i >= int64(len(stringTable)) {
+			return ""
+		}
+		return stringTable[i]
+	}
+	for _, vt := range rawSampleTypes {
+		p.sampleTypes = append(p.sampleTypes, valueType{Type: str(vt.typ), Unit: str(vt.unit)})
+	}
+	p.defaultSampleType = str(defaultSampleType)
+	for id, fn := range rawFunctions {
+		p.functions[id] = profileFunction{Name: str(fn.name), Filename: str(fn.filename)}
+	}
+	if len(p.sampleTypes) == 0 {
+		return nil, errors.New("profile has no sample types")
+	}
+	return p, nil
+}
+
+// decodeMessage walks the fields of a protobuf message. Varint fields are
+// passed as value; length-delimited fields as buf. Fixed-width fields are
+// skipped since profile.proto does not use them.
+func decodeMessage(data []byte, fn func(field int, value uint64, buf []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("malformed protobuf field key")
+		}
+		data = data[n:]
+		field, wireType := int(key>>3), key&7
+
+		switch wireType {
+		case 0: // varint
+			value, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errors.New("malformed protobuf varint")
+			}
+			data = data[n:]
+			if err := fn(field, value, nil); err != nil {
+				return err
+			}
+		case 1: // 64-bit
+			if len(data) < 8 {
+				return errors.New("truncated protobuf field")
+			}
+			data = data[8:]
+		case 2: // length-delimited
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errors.New("truncated protobuf field")
+			}
+			buf := data[n : n+int(length)]
+			data = data[n+int(length):]
+			if err := fn(field, 0, buf); err != nil {
+				return err
+			}
+		case 5: // 32-bit
+			if len(data) < 4 {
+				return errors.New("truncated protobuf field")
+			}
+			data = data[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wireType)
+		}
+	}
+	return nil
+}
+
+// appendPacked appends a repeated varint field, which encoders may write
+// either packed (buf) or as individual values
+func appendPacked[T any](dst *[]T, value uint64, buf []byte, conv func(uint64) T) error {
+	if buf == nil {
+		*dst = append(*dst, conv(value))
+		return nil
+	}
+	for len(buf) > 0 {
+		v, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return errors.New("malformed packed varint")
+		}
+		*dst = append(*dst, conv(v))
+		buf = buf[n:]
+	}
+	return nil
+}
+
+// sampleIndex picks the value to rank functions by: the profile's default
+// sample type, or else the last one (e.g. cpu nanoseconds, inuse_space)
+func (p *profile) sampleIndex() int {
+	for i, st := range p.sampleTypes {
+		if st.Type == p.defaultSampleType && p.defaultSampleType != "" {
+			return i
+		}
+	}
+	return len(p.sampleTypes) - 1
+}
+
+// hotFunctions returns the n functions with the highest cumulative value
+func (p *profile) hotFunctions(n int) (valueType, int64, []hotFunction) {
+	idx := p.sampleIndex()
+	stats := make(map[uint64]*hotFunction)
+	var total int64
+
+	for _, s := range p.samples {
+		if idx >= len(s.values) {
+			continue
+		}
+		v := s.values[idx]
+		total += v
+
+		seen := make(map[uint64]bool)
+		for i, locID := range s.locationIDs {
+			for j, fnID := range p.locations[locID] {
+				h, ok := stats[fnID]
+				if !ok {
+					fn := p.functions[fnID]
+					h = &hotFunction{Name: fn.Name, Filename: fn.Filename}
+					stats[fnID] = h
+				}
+				if i == 0 && j == 0 {
+					h.Flat += v
+				}
+				if !seen[fnID] {
+					h.Cum += v
+					seen[fnID] = true
+				}
+			}
+		}
+	}
+
+	hot := make([]hotFunction, 0, len(stats))
+	for _, h := range stats {
+		hot = append(hot, *h)
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].Cum != hot[j].Cum {
+			return hot[i].Cum > hot[j].Cum
+		}
+		return hot[i].Name < hot[j].Name
+	})
+	if len(hot) > n {
+		hot = hot[:n]
+	}
+	return p.sampleTypes[idx], total, hot
+}
+
+// hotPathFiles returns the changed files, relative to the repository at
+// root, that contain a hot function. A file matches a hot function's file
+// name when that is the file's own path under root; when it is the file's
+// path within its Go module, qualified by the module path, as -trimpath
+// and GOPATH builds record it; or when it ends with the file's path within
+// the module and the function is in the file's package, for profiles
+// recorded in another checkout. Files outside a Go module only match their
+// path under root, or, below the top directory, a file name ending with
+// their path.
+func hotPathFiles(hot []hotFunction, root string, changed []string) []string {
+	root = strings.TrimSuffix(filepath.ToSlash(root), "/")
+	modules := make(map[string]goModule)
+	var matched []string
+	for _, file := range changed {
+		mod := findGoModule(root, path.Dir(file), modules)
+		for _, h := range hot {
+			// Profiles recorded on Windows use backslashes
+			name := strings.ReplaceAll(h.Filename, "\\", "/")
+			if root != "" && name == root+"/"+file {
+				matched = append(matched, file)
+				break
+			}
+			if mod.path != "" {
+				rel := strings.TrimPrefix(strings.TrimPrefix(file, mod.dir), "/")
+				qualified := mod.path + "/" + rel
+				pkg := strings.TrimSuffix(mod.path+"/"+path.Dir(rel), "/.")
+				if name == qualified || strings.HasSuffix(name, "/"+qualified) ||
+					strings.HasSuffix(name, "/"+rel) && strings.HasPrefix(h.Name, pkg+".") {
+					matched = append(matched, file)
+					break
+				}
+			} else if strings.Contains(file, "/") && strings.HasSuffix(name, "/"+file) {
+				matched = append(matched, file)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// goModule is the Go module a file is in: its module path, and its
+// directory relative to the repository
+type goModule struct {
+	path string
+	dir  string
+}
+
+var goModuleLine = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)"?`)
+
+// findGoModule returns the module of the directory dir of the repository at
+// root, from the nearest go.mod at or above it, remembering each
+// directory's in modules. Without one, or without a root, it is the zero
+// goModule.
+func findGoModule(root, dir string, modules map[string]goModule) goModule {
+	if root == "" {
+		return goModule{}
+	}
+	if mod, ok := modules[dir]; ok {
+		return mod
+	}
+	var mod goModule
+	if data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(dir), "go.mod")); err == nil {
+		if m := goModuleLine.FindSubmatch(data); m != nil {
+			mod = goModule{path: string(m[1]), dir: strings.TrimPrefix(dir, ".")}
+		}
+	} else if dir != "." {
+		mod = findGoModule(root, path.Dir(dir), modules)
+	}
+	modules[dir] = mod
+	return mod
+}
+
+// maxHotFunctions is how many of a profile's hottest functions are summarized
+const maxHotFunctions = 25
+
+// profileSection summarizes a profile's hot functions for the prompt and
+// flags the changed files, relative to the repository at root, that contain
+// them
+func profileSection(filename, root string, changed []string) (string, error) {
+	p, err := loadProfile(filename)
+	if err != nil {
+		return "", err
+	}
+	st, total, hot := p.hotFunctions(maxHotFunctions)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Profile `%s` (%s, %s). Check whether the diff touches any of these hot\n", filepath.Base(filename), st.Type, st.Unit)
+	b.WriteString("functions and assess the performance risk of changes on those paths.\n\n")
+	b.WriteString("| Function | File | Flat | Cum |\n")
+	b.WriteString("|---|---|---:|---:|\n")
+	for _, h := range hot {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", h.Name, h.Filename, percent(h.Flat, total), percent(h.Cum, total))
+	}
+
+	if files := hotPathFiles(hot, root, changed); len(files) > 0 {
+		b.WriteString("\nChanged files containing hot functions: ")
+		b.WriteString(strings.Join(files, ", "))
+		b.WriteString("\n")
+	} else {
+		b.WriteString("\nNo changed file contains one of these hot functions.\n")
+	}
+	return b.String(), nil
+}
+
+func percent(v, total int64) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(v)*100/float64(total))
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// pbBuilder encodes protobuf messages for test profiles
+type pbBuilder struct{ buf []byte }
+
+func (b *pbBuilder) varint(field int, v uint64) *pbBuilder {
+	b.buf = binary.AppendUvarint(b.buf, uint64(field)<<3)
+	b.buf = binary.AppendUvarint(b.buf, v)
+	return b
+}
+
+func (b *pbBuilder) bytes(field int, data []byte) *pbBuilder {
+	b.buf = binary.AppendUvarint(b.buf, uint64(field)<<3|2)
+	b.buf = binary.AppendUvarint(b.buf, uint64(len(data)))
+	b.buf = append(b.buf, data...)
+	return b
+}
+
+func packed(values ...uint64) []byte {
+	var buf []byte
+	for _, v := range values {
+		buf = binary.AppendUvarint(buf, v)
+	}
+	return buf
+}
+
+// testProfile builds a CPU profile where main calls parse (hot) and render:
+//
+//	main -> parse: 70 samples, main -> render: 30 samples
+func testProfile() []byte {
+	strs := []string{"", "samples", "count", "cpu", "nanoseconds",
+		"main.main", "/home/dev/app/main.go",
+		"main.parse", "/home/dev/app/parser/parse.go",
+		"main.render", "/home/dev/app/render.go"}
+
+	p := &pbBuilder{}
+	p.bytes(1, (&pbBuilder{}).varint(1, 1).varint(2, 2).buf) // samples/count
+	p.bytes(1, (&pbBuilder{}).varint(1, 3).varint(2, 4).buf) // cpu/nanoseconds
+	p.bytes(2, (&pbBuilder{}).bytes(1, packed(2, 1)).bytes(2, packed(7, 70)).buf)
+	// Unpacked repeated fields must decode too
+	p.bytes(2, (&pbBuilder{}).varint(1, 3).varint(1, 1).varint(2, 3).varint(2, 30).buf)
+	for id, fn := range []uint64{1, 2, 3} {
+		line := (&pbBuilder{}).varint(1, fn).buf
+		p.bytes(4, (&pbBuilder{}).varint(1, uint64(id+1)).bytes(4, line).buf)
+	}
+	for id, name := range []uint64{5, 7, 9} {
+		p.bytes(5, (&pbBuilder{}).varint(1, uint64(id+1)).varint(2, name).varint(4, name+1).buf)
+	}
+	for _, s := range strs {
+		p.bytes(6, []byte(s))
+	}
+	return p.buf
+}
+
+// TestProfile_HotFunctions tests ranking functions from a decoded profile
+func TestProfile_HotFunctions(t *testing.T) {
+	p, err := parseProfile(testProfile())
+	if err != nil {
+		t.Fatalf("parseProfile() returned error: %v", err)
+	}
+
+	st, total, hot := p.hotFunctions(10)
+	if st.Type != "cpu" || st.Unit != "nanoseconds" {
+		t.Errorf("sample type = %+v, want cpu/nanoseconds", st)
+	}
+	if total != 100 {
+		t.Errorf("total = %d, want 100", total)
+	}
+
+	want := []hotFunction{
+		{"main.main", "/home/dev/app/main.go", 0, 100},
+		{"main.parse", "/home/dev/app/parser/parse.go", 70, 70},
+		{"main.render", "/home/dev/app/render.go", 30, 30},
+	}
+	if len(hot) != len(want) {
+		t.Fatalf("hotFunctions() = %+v", hot)
+	}
+	for i := range want {
+		if hot[i] != want[i] {
+			t.Errorf("hot[%d] = %+v, want %+v", i, hot[i], want[i])
+		}
+	}
+}
+
+// TestProfileSection tests the prompt summary of a gzipped profile file
+func TestProfileSection(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(testProfile())
+	zw.Close()
+
+	path := filepath.Join(t.TempDir(), "cpu.pb.gz")
+	if err := os.WriteFile(path, gz.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	got, err := profileSection(path, "/home/dev/app", []string{"parser/parse.go", "README.md"})
+	if err != nil {
+		t.Fatalf("profileSection() returned error: %v", err)
+	}
+	if !strings.Contains(got, "| main.parse | /home/dev/app/parser/parse.go | 70.0% | 70.0% |") {
+		t.Errorf("profileSection() missing hot function row:\n%s", got)
+	}
+	if !strings.Contains(got, "Changed files containing hot functions: parser/parse.go\n") {
+		t.Errorf("profileSection() did not flag the hot changed file:\n%s", got)
+	}
+}
+
+// TestParseProfile_Invalid tests that garbage input is rejected
+func TestParseProfile_Invalid(t *testing.T) {
+	if _, err := parseProfile([]byte{0xff, 0xff, 0xff}); err == nil {
+		t.Error("parseProfile() expected error for malformed data")
+	}
+	if _, err := parseProfile(nil); err == nil {
+		t.Error("parseProfile() expected error for a profile without sample types")
+	}
+}
+
+// TestHotPathFiles_WindowsPaths tests matching profiles recorded on Windows
+func TestHotPathFiles_WindowsPaths(t *testing.T) {
+	hot := []hotFunction{{Filename: `C:\src\app\parser\parse.go`}}
+	got := hotPathFiles(hot, "", []string{"parser/parse.go", "main.go"})
+	if len(got) != 1 || got[0] != "parser/parse.go" {
+		t.Errorf("hotPathFiles() = %v, want [parser/parse.go]", got)
+	}
+}
+
+// TestHotPathFiles_Modules tests that a changed file only matches hot
+// functions of its own module, not files of the same name elsewhere
+func TestHotPathFiles_Modules(t *testing.T) {
+	root := t.TempDir()
+	for file, content := range map[string]string{
+		"go.mod":       "module example.com/app\n",
+		"tools/go.mod": "module example.com/app/tools\n",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slashed := filepath.ToSlash(root)
+
+	for _, tc := range []struct {
+		name string
+		hot  hotFunction
+		want []string
+	}{
+		{"another module's main.go", hotFunction{Name: "main.main", Filename: "/home/ci/go/pkg/mod/other.com/cmd@v1/main.go"}, nil},
+		{"main.go under the root", hotFunction{Name: "main.main", Filename: slashed + "/main.go"}, []string{"main.go"}},
+		{"trimpath", hotFunction{Name: "example.com/app/parser.Parse", Filename: "example.com/app/parser/parse.go"}, []string{"parser/parse.go"}},
+		{"another checkout", hotFunction{Name: "example.com/app/parser.Parse", Filename: "/build/src/parser/parse.go"}, []string{"parser/parse.go"}},
+		{"another module's package of the same name", hotFunction{Name: "other.com/lib/parser.Parse", Filename: "/build/lib/parser/parse.go"}, nil},
+		{"nested module", hotFunction{Name: "example.com/app/tools/gen.Run", Filename: "example.com/app/tools/gen/run.go"}, []string{"tools/gen/run.go"}},
+	} {
+		got := hotPathFiles([]hotFunction{tc.hot}, root, []string{"main.go", "parser/parse.go", "tools/gen/run.go"})
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: hotPathFiles() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
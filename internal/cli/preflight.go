@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/llm"
+)
+
+const (
+	// preflightOutputTokens is the output a review is expected to write on
+	// top of its thinking, for the cost estimate; the whole of -max-tokens
+	// is rarely used
+	preflightOutputTokens = 4000
+
+	// bytesPerToken is roughly how many bytes of code and English make a
+	// token, to estimate prompts that can't be counted
+	bytesPerToken = 4
+)
+
+// costEstimate is what a review is expected to cost before it is sent
+type costEstimate struct {
+	InputTokens  int
+	OutputTokens int
+	Counted      bool // InputTokens were counted by the provider, not estimated
+	Cost         float64
+	Known        bool // every model's price is known
+}
+
+// estimateReview estimates the cost of sending prompt to each of models
+// (one review each), counting its tokens with the provider where it can.
+// The output is expected to be the thinking budget and a few thousand
+// tokens of review, up to -max-tokens.
+func estimateReview(client Provider, prompt string, opts CompletionOptions, models []string) costEstimate {
+	var est costEstimate
+	if counter, ok := client.(llm.TokenCounter); ok {
+		n, err := counter.CountTokens(prompt, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not count the prompt's tokens, estimating them: %v\n", err)
+		} else {
+			est.InputTokens, est.Counted = n, true
+		}
+	}
+	if !est.Counted {
+		est.InputTokens = len(llm.WithoutCacheBreakpoint(prompt)) / bytesPerToken
+	}
+	est.OutputTokens = preflightOutputTokens
+	if opts.Thinking {
+		est.OutputTokens += opts.ThinkingBudget
+	}
+	est.OutputTokens = min(est.OutputTokens, opts.MaxTokens)
+
+	est.Known = true
+	for _, model := range models {
+		cost, ok := estimateCost(model, est.InputTokens, est.OutputTokens)
+		est.Cost += cost
+		est.Known = est.Known && ok
+	}
+	return est
+}
+
+// String describes the estimate for the confirmation prompt
+func (e costEstimate) String() string {
+	approx := ""
+	if !e.Counted {
+		approx = "~"
+	}
+	if !e.Known {
+		return tr("This review will send %s%d input tokens and expects ~%d output tokens, at an unknown price", approx, e.InputTokens, e.OutputTokens)
+	}
+	return tr("This review will cost ~$%s (%s%d input and ~%d output tokens)", fmt.Sprintf("%.2f", e.Cost), approx, e.InputTokens, e.OutputTokens)
+}
+
+// confirmCost shows the estimated cost of a review and, when in reads from
+// a terminal and -yes isn't set, asks whether to go ahead
+func confirmCost(est costEstimate, in io.Reader, interactive, yes bool) bool {
+	if yes || !interactive {
+		fmt.Println("💰 " + est.String())
+		return true
+	}
+	fmt.Print("💰 " + est.String() + " — " + tr("proceed? [y/N]") + " ")
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		fmt.Println()
+		return true
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marete/pr-review/pkg/llm"
+)
+
+// TestEstimateReview tests counting a prompt's tokens with the token
+// counting endpoint and pricing the review
+func TestEstimateReview(t *testing.T) {
+	var path string
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		io.WriteString(w, `{"input_tokens": 100000}`)
+	}))
+	defer server.Close()
+
+	client, err := llm.New(providerAnthropic, llm.Options{APIKey: "test", BaseURL: server.URL + "/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := CompletionOptions{Model: "claude-sonnet-4-5", Thinking: true, ThinkingBudget: 10000, MaxTokens: 64000}
+	est := estimateReview(client, "Review this diff."+llm.CacheBreakpoint+"Be brief.", opts, []string{"claude-sonnet-4-5"})
+	if path != "/v1/messages/count_tokens" {
+		t.Errorf("counted tokens at %s", path)
+	}
+	if _, ok := body["max_tokens"]; ok || body["thinking"] == nil {
+		t.Errorf("count_tokens request = %v", body)
+	}
+	// 100k input tokens at $3/M and 14k output tokens at $15/M
+	if !est.Counted || est.InputTokens != 100000 || est.OutputTokens != 14000 || !est.Known || est.Cost < 0.509 || est.Cost > 0.511 {
+		t.Errorf("estimateReview() = %+v", est)
+	}
+	if got := est.String(); got != "This review will cost ~$0.51 (100000 input and ~14000 output tokens)" {
+		t.Errorf("String() = %q", got)
+	}
+
+	// Providers that can't count tokens get an estimate from the prompt's size
+	est = estimateReview(&fakeProvider{}, strings.Repeat("x", 8000), CompletionOptions{MaxTokens: 2000}, []string{"gpt-4o", "local-model"})
+	if est.Counted || est.InputTokens != 2000 || est.OutputTokens != 2000 || est.Known {
+		t.Errorf("estimateReview() without counting = %+v", est)
+	}
+	if got := est.String(); !strings.Contains(got, "~2000 input tokens") || !strings.Contains(got, "unknown price") {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+// TestConfirmCost tests asking to go ahead with a review
+func TestConfirmCost(t *testing.T) {
+	est := costEstimate{InputTokens: 1000, OutputTokens: 100, Counted: true, Known: true, Cost: 0.42}
+	tests := []struct {
+		answer      string
+		interactive bool
+		yes         bool
+		want        bool
+	}{
+		{"y\n", true, false, true},
+		{"YES\n", true, false, true},
+		{"\n", true, false, false},
+		{"n\n", true, false, false},
+		{"", true, false, false},
+		{"", true, true, true},
+		{"", false, false, true},
+	}
+	for _, tt := range tests {
+		if got := confirmCost(est, strings.NewReader(tt.answer), tt.interactive, tt.yes); got != tt.want {
+			t.Errorf("confirmCost(%q, interactive %v, yes %v) = %v, want %v", tt.answer, tt.interactive, tt.yes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+	"github.com/marete/pr-review/pkg/llm"
+)
+
+const (
+	// prescreenMaxTokens bounds the pre-screen pass, which classifies files
+	// without extended thinking
+	prescreenMaxTokens = 4000
+
+	// prescreenFileBytes bounds each file's diff in the pre-screen prompt;
+	// the start of a change is enough to judge its risk
+	prescreenFileBytes = 8 * 1024
+)
+
+// prescreenModels are the cheap models that pre-screen files with each
+// provider when -prescreen-model isn't set. Azure deployments are named by
+// their owners, so there is no default.
+var prescreenModels = map[string]string{
+	providerAnthropic: "claude-haiku-4-5-20251001",
+	providerOpenAI:    "gpt-4o-mini",
+	providerBedrock:   "us.anthropic.claude-haiku-4-5-20251001-v1:0",
+	providerAzure:     "",
+}
+
+// Risk ratings of the pre-screen
+const (
+	riskHigh   = "high"
+	riskMedium = "medium"
+	riskLow    = "low"
+)
+
+const (
+	screenStartTag = "<screen>"
+	screenEndTag   = "</screen>"
+)
+
+// fileScreen is the pre-screen's rating of one changed file
+type fileScreen struct {
+	File   string `json:"file"`
+	Risk   string `json:"risk"`
+	Reason string `json:"reason"`
+}
+
+// buildPrescreenPrompt asks for the risk of each changed file, to decide
+// which ones get the deep review
+func buildPrescreenPrompt(files []gitdiff.File, commitMessages string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `You are triaging a Pull Request before an in-depth code review. Rate how much each changed file below needs that review:
+
+- high: logic, security, concurrency, data handling, public APIs, or anything where a bug would matter
+- medium: ordinary code changes with limited blast radius
+- low: documentation, comments, formatting, renames, generated files, lock files, simple test or config tweaks
+
+When unsure, rate higher. Return a JSON array enclosed in %s and %s tags with one element per file: {"file": "<path>", "risk": "high|medium|low", "reason": "<one short sentence>"}.
+
+## Commits
+`+"```\n%s\n```\n", screenStartTag, screenEndTag, commitMessages)
+	for _, f := range files {
+		text := f.Text
+		if len(text) > prescreenFileBytes {
+			text = text[:prescreenFileBytes] + "\n[... truncated ...]"
+		}
+		fmt.Fprintf(&b, "\n## %s\n```diff\n%s\n```\n", f.Path, text)
+	}
+	return b.String()
+}
+
+// parsePrescreen reads the ratings from the screen section of a response,
+// in the order of files. Files the response leaves out, or rates with
+// anything but low or medium, are rated high so they are still reviewed.
+func parsePrescreen(response string, files []gitdiff.File) ([]fileScreen, error) {
+	start := strings.LastIndex(response, screenStartTag)
+	if start == -1 {
+		return nil, fmt.Errorf("response has no %s section", screenStartTag)
+	}
+	end := strings.Index(response[start:], screenEndTag)
+	if end == -1 {
+		return nil, fmt.Errorf("screen section is not terminated by %s", screenEndTag)
+	}
+	body := strings.TrimSpace(response[start+len(screenStartTag) : start+end])
+	body = strings.TrimPrefix(body, "```json")
+	body = strings.TrimPrefix(body, "```")
+	body = strings.TrimSuffix(body, "```")
+
+	var rated []fileScreen
+	if err := json.Unmarshal([]byte(body), &rated); err != nil {
+		return nil, fmt.Errorf("error parsing screen: %w", err)
+	}
+	byFile := make(map[string]fileScreen)
+	for _, r := range rated {
+		r.Risk = strings.ToLower(strings.TrimSpace(r.Risk))
+		if r.Risk != riskLow && r.Risk != riskMedium {
+			r.Risk = riskHigh
+		}
+		byFile[r.File] = r
+	}
+
+	screens := make([]fileScreen, len(files))
+	for i, f := range files {
+		s, ok := byFile[f.Path]
+		if !ok {
+			s = fileScreen{Risk: riskHigh, Reason: "Not rated by the pre-screen"}
+		}
+		s.File = f.Path
+		screens[i] = s
+	}
+	return screens, nil
+}
+
+// prescreen rates the risk of each file of diff with a cheap model
+func prescreen(client Provider, model string, policy *Policy, diff, commitMessages string) ([]fileScreen, Usage, error) {
+	files := gitdiff.Split(diff)
+	prompt := policy.redact(buildPrescreenPrompt(files, commitMessages))
+	response, usage, err := llm.WithoutDocuments(client).Complete(prompt, CompletionOptions{Model: model, MaxTokens: prescreenMaxTokens})
+	if err != nil {
+		return nil, usage, err
+	}
+	screens, err := parsePrescreen(response, files)
+	return screens, usage, err
+}
+
+// highRiskDiff returns the part of diff changing the files screened as high
+// risk, which is all the deep review sees
+func highRiskDiff(diff string, screens []fileScreen) string {
+	high := make(map[string]bool)
+	for _, s := range screens {
+		if s.Risk == riskHigh {
+			high[s.File] = true
+		}
+	}
+	var files []gitdiff.File
+	for _, f := range gitdiff.Split(diff) {
+		if high[f.Path] {
+			files = append(files, f)
+		}
+	}
+	return gitdiff.Join(files)
+}
+
+// prescreenInstructions tells the deep review which files were left out
+func prescreenInstructions(screens []fileScreen) string {
+	var b strings.Builder
+	b.WriteString("A pre-screen rated the files below as lower risk, so their changes are left out of the diff. Only review the files in the diff; mention a skipped file only if a change in the diff affects it.\n\n")
+	for _, s := range screens {
+		if s.Risk != riskHigh {
+			fmt.Fprintf(&b, "- `%s` (%s): %s\n", s.File, s.Risk, s.Reason)
+		}
+	}
+	return b.String()
+}
+
+// renderPrescreen formats the pre-screen's ratings as a markdown section,
+// so the report says which files had the deep review and why
+func renderPrescreen(screens []fileScreen) string {
+	var b strings.Builder
+	b.WriteString("## " + tr("Pre-Screen Triage") + "\n\n")
+	fmt.Fprintf(&b, "| %s | %s | %s |\n|---|---|---|\n", tr("File"), tr("Risk"), tr("Reason"))
+	for _, s := range screens {
+		risk := tr(strings.ToUpper(s.Risk[:1]) + s.Risk[1:])
+		if s.Risk == riskHigh {
+			risk = "**" + risk + "**"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s |\n", s.File, risk, strings.ReplaceAll(s.Reason, "|", "\\|"))
+	}
+	b.WriteString("\n" + tr("Only files rated high risk had the deep review.") + "\n")
+	return b.String()
+}
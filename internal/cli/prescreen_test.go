@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+const prescreenDiff = `diff --git a/auth.go b/auth.go
+--- a/auth.go
++++ b/auth.go
+@@ -1 +1 @@
+-func check() bool { return true }
++func check() bool { return token != "" }
+diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-Hello
++Hello, world
+diff --git a/db.go b/db.go
+--- a/db.go
++++ b/db.go
+@@ -1 +1 @@
+-var x = 1
++var x = 2
+`
+
+// TestPrescreen tests rating files with the cheap model and keeping only
+// the high-risk ones for the deep review
+func TestPrescreen(t *testing.T) {
+	client := &fakeProvider{response: "<screen>\n```json\n" + `[
+		{"file": "auth.go", "risk": "High", "reason": "Changes the auth check."},
+		{"file": "README.md", "risk": "low", "reason": "Docs only."}
+	]` + "\n```\n</screen>"}
+	screens, usage, err := prescreen(client, "cheap", nil, prescreenDiff, "abc1234 - Fix auth")
+	if err != nil {
+		t.Fatalf("prescreen() returned error: %v", err)
+	}
+	if usage.InputTokens == 0 || !strings.Contains(client.prompt, "## README.md") {
+		t.Errorf("prescreen() usage %+v, prompt:\n%s", usage, client.prompt)
+	}
+	want := []fileScreen{
+		{"auth.go", riskHigh, "Changes the auth check."},
+		{"README.md", riskLow, "Docs only."},
+		{"db.go", riskHigh, "Not rated by the pre-screen"},
+	}
+	for i, s := range screens {
+		if s != want[i] {
+			t.Errorf("screens[%d] = %+v, want %+v", i, s, want[i])
+		}
+	}
+
+	diff := highRiskDiff(prescreenDiff, screens)
+	if files := gitdiff.Files(diff); len(files) != 2 || files[0] != "auth.go" || files[1] != "db.go" {
+		t.Errorf("highRiskDiff() files = %v, want auth.go and db.go", files)
+	}
+	if got := prescreenInstructions(screens); !strings.Contains(got, "`README.md` (low): Docs only.") || strings.Contains(got, "auth.go") {
+		t.Errorf("prescreenInstructions() = %q", got)
+	}
+	if got := renderPrescreen(screens); !strings.Contains(got, "| `auth.go` | **High** | Changes the auth check. |") {
+		t.Errorf("renderPrescreen() = %q", got)
+	}
+
+	client.response = "I can't rate these."
+	if _, _, err := prescreen(client, "cheap", nil, prescreenDiff, ""); err == nil {
+		t.Error("prescreen() without a screen section returned no error")
+	}
+}
@@ -0,0 +1,347 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Profile bundles settings chosen together with -profile, e.g. one for work
+// reviews, one for open source, and one for security audits. Flags and the
+// repository's config take precedence over a profile.
+type Profile struct {
+	Provider       string `yaml:"provider"`
+	Model          string `yaml:"model"`
+	ThinkingBudget int    `yaml:"thinking_budget"`
+	Format         string `yaml:"format"`
+
+	// APIKeyEnv names the environment variable holding the provider's API
+	// key, or APIKeyCommand prints it, e.g. from a password manager. Without
+	// either, the provider's usual variable is used.
+	APIKeyEnv     string `yaml:"api_key_env"`
+	APIKeyCommand string `yaml:"api_key_command"`
+
+	// PromptSections are added to the review prompt, e.g. a security
+	// audit's focus
+	PromptSections []promptSection `yaml:"prompt_sections"`
+}
+
+// validate checks a profile's settings
+func (p *Profile) validate() error {
+	if p.Provider != "" {
+		if _, ok := defaultModels[p.Provider]; !ok {
+			return fmt.Errorf("invalid provider %q (want anthropic, openai, azure or bedrock)", p.Provider)
+		}
+	}
+	if p.APIKeyEnv != "" && p.APIKeyCommand != "" {
+		return fmt.Errorf("api_key_env and api_key_command can't both be set")
+	}
+	if (p.APIKeyEnv != "" || p.APIKeyCommand != "") && (p.Provider == providerAzure || p.Provider == providerBedrock) {
+		return fmt.Errorf("provider %s takes its credentials from its own environment variables, not api_key_env or api_key_command", p.Provider)
+	}
+	if p.ThinkingBudget < 0 {
+		return fmt.Errorf("thinking_budget must not be negative")
+	}
+	if p.Format != "" {
+		if err := validateFormat(p.Format); err != nil {
+			return err
+		}
+	}
+	for i, section := range p.PromptSections {
+		if strings.TrimSpace(section.Title) == "" || strings.TrimSpace(section.Body) == "" {
+			return fmt.Errorf("prompt_sections[%d] needs a title and a body", i)
+		}
+	}
+	return nil
+}
+
+// validateProfiles checks every profile, and that the default one exists
+func (g *GlobalConfig) validateProfiles() error {
+	for name, p := range g.Profiles {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("profiles: %s: %w", name, err)
+		}
+	}
+	if _, ok := g.Profiles[g.DefaultProfile]; g.DefaultProfile != "" && !ok {
+		return fmt.Errorf("default_profile %q is not one of the profiles", g.DefaultProfile)
+	}
+	return nil
+}
+
+// profile returns the profile called name, or if name is "", the default
+// profile. It returns nil if no profile is selected.
+func (g *GlobalConfig) profile(name string) (*Profile, string, error) {
+	if name == "" {
+		name = g.DefaultProfile
+	}
+	if name == "" {
+		return nil, "", nil
+	}
+	p, ok := g.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(g.Profiles))
+		for n := range g.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, "", fmt.Errorf("unknown profile %q; the global config has no profiles", name)
+		}
+		return nil, "", fmt.Errorf("unknown profile %q (have %s)", name, strings.Join(names, ", "))
+	}
+	return &p, name, nil
+}
+
+// applyDefaults sets the flags of fs the profile has defaults for, unless
+// they were given on the command line or set by the repository's config.
+// The provider and model go together: if either is set already, the
+// profile's are both left out, so a model never meets another provider.
+func (p *Profile) applyDefaults(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	defaults := map[string]string{"format": p.Format}
+	if !given["provider"] && !given["model"] {
+		defaults["provider"], defaults["model"] = p.Provider, p.Model
+	}
+	if p.ThinkingBudget > 0 {
+		defaults["thinking-budget"] = strconv.Itoa(p.ThinkingBudget)
+	}
+
+	for name, value := range defaults {
+		// Subcommands don't all have every flag
+		if value == "" || given[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", strings.ReplaceAll(name, "-", "_"), err)
+		}
+	}
+	return nil
+}
+
+// apiKey returns the profile's API key for provider, or "" if the profile
+// doesn't say where to find one for it
+func (p *Profile) apiKey(provider string) (string, error) {
+	if p == nil || (p.Provider != "" && p.Provider != provider) {
+		return "", nil
+	}
+	if p.APIKeyEnv != "" {
+		key := os.Getenv(p.APIKeyEnv)
+		if key == "" {
+			return "", fmt.Errorf("%s environment variable not set", p.APIKeyEnv)
+		}
+		return key, nil
+	}
+	if p.APIKeyCommand == "" {
+		return "", nil
+	}
+	cmd := exec.Command("sh", "-c", p.APIKeyCommand)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("api_key_command failed: %w", err)
+	}
+	key := strings.TrimSpace(string(out))
+	if key == "" {
+		return "", fmt.Errorf("api_key_command printed no key")
+	}
+	return key, nil
+}
+
+// keySource describes where the profile's API key comes from, for
+// `config show`
+func (p *Profile) keySource() string {
+	switch {
+	case p.APIKeyEnv != "":
+		return "$" + p.APIKeyEnv
+	case p.APIKeyCommand != "":
+		return "api_key_command"
+	}
+	return ""
+}
+
+// applyProfile applies the profile selected with -profile, or the global
+// config's default profile, to the flags not already set. It runs once,
+// after the repository's config has been applied.
+func (c *commonFlags) applyProfile() {
+	if c.profileApplied {
+		return
+	}
+	c.profileApplied = true
+	global, err := loadGlobalConfig()
+	if err != nil {
+		fail(exitUsage, "Error loading config: %v", err)
+	}
+	p, name, err := global.profile(*c.profileName)
+	if err != nil {
+		fail(exitUsage, "Error: -profile: %v", err)
+	}
+	if p == nil {
+		return
+	}
+	if err := p.applyDefaults(c.fs); err != nil {
+		fail(exitUsage, "Error: profile %s: %v", name, err)
+	}
+	c.profile, c.profileUsed = p, name
+}
+
+// apiKey returns the API key for provider: from the profile if it says
+// where to find one, otherwise from the provider's environment variable
+func (c *commonFlags) apiKey(provider, env string) string {
+	key, err := c.profile.apiKey(provider)
+	if err != nil {
+		fail(exitUsage, "Error: profile %s: %v", c.profileUsed, err)
+	}
+	if key != "" {
+		return key
+	}
+	return requireEnv(env)
+}
+
+// runConfig implements `pr-review config show`
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintln(os.Stderr, "Usage: pr-review config show [-profile name] [flags]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	fs.String("format", formatMarkdown, "Report format")
+	fs.Int("context-lines", defaultContextLines, "Lines of context around each change")
+	fs.Parse(args[1:])
+
+	settings, err := effectiveSettings(fs, common)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	for _, s := range settings {
+		fmt.Printf("%-16s %s (%s)\n", s.Name, s.Value, s.Source)
+	}
+}
+
+// setting is one effective setting and what set it
+type setting struct {
+	Name, Value, Source string
+}
+
+// effectiveSettings resolves the settings a review would run with, going
+// through the same precedence: flags, then PR_REVIEW_* variables, then the
+// repository's config, then the profile, then the defaults
+func effectiveSettings(fs *flag.FlagSet, common *commonFlags) ([]setting, error) {
+	sources := make(map[string]string)
+	mark := func(source string) {
+		fs.Visit(func(f *flag.Flag) {
+			if sources[f.Name] == "" {
+				sources[f.Name] = source
+			}
+		})
+	}
+	mark("flag")
+	fromEnv, err := applyEnv(fs, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range fromEnv {
+		sources[name] = "$" + flagEnvName("", name)
+	}
+
+	global, err := loadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	config, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	configSource := config.Source
+	if _, err := os.Stat(config.Path); os.IsNotExist(err) {
+		configSource += "; not found"
+	}
+	settings := []setting{{"config", config.Path, configSource}}
+	if global.Storage.Backend != "" {
+		settings = append(settings, setting{"storage", global.Storage.Backend, "storage in the global config"})
+	}
+
+	if root := getRepoRoot(); root != "" {
+		path := filepath.Join(root, repoConfigFile)
+		cfg, err := loadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.applyDefaults(fs, root); err != nil {
+			return nil, fmt.Errorf("%s: %w", repoConfigFile, err)
+		}
+		mark(repoConfigFile)
+	}
+
+	p, name, err := global.profile(*common.profileName)
+	if err != nil {
+		return nil, fmt.Errorf("-profile: %w", err)
+	}
+	switch {
+	case p == nil:
+		settings = append(settings, setting{"profile", "none", "default"})
+	case *common.profileName != "":
+		settings = append(settings, setting{"profile", name, "-profile"})
+	default:
+		settings = append(settings, setting{"profile", name, "default_profile in the global config"})
+	}
+	if p != nil {
+		if err := p.applyDefaults(fs); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		mark("profile " + name)
+	}
+
+	provider := strings.ToLower(*common.providerName)
+	model, modelSource := *common.model, sources["model"]
+	if model == "" {
+		model, modelSource = defaultModels[provider], "the provider's default"
+	}
+	keySource := map[string]string{
+		providerAnthropic: "$ANTHROPIC_API_KEY",
+		providerOpenAI:    "$OPENAI_API_KEY",
+		providerAzure:     "$AZURE_OPENAI_API_KEY or Entra ID",
+		providerBedrock:   "AWS credentials",
+	}[provider]
+	keyFrom := "default"
+	if p != nil && p.keySource() != "" && (p.Provider == "" || p.Provider == provider) {
+		keySource, keyFrom = p.keySource(), "profile "+name
+	}
+
+	value := func(name string) string { return fs.Lookup(name).Value.String() }
+	source := func(name string) string {
+		if s := sources[name]; s != "" {
+			return s
+		}
+		return "default"
+	}
+	settings = append(settings,
+		setting{"provider", provider, source("provider")},
+		setting{"model", model, modelSource},
+		setting{"api key", keySource, keyFrom},
+		setting{"thinking-budget", value("thinking-budget"), source("thinking-budget")},
+		setting{"format", value("format"), source("format")},
+	)
+	if fs.Lookup("context-lines") != nil {
+		settings = append(settings, setting{"context-lines", value("context-lines"), source("context-lines")})
+	}
+	if ctx := value("context"); ctx != "" {
+		settings = append(settings, setting{"context", ctx, source("context")})
+	}
+	if p != nil && len(p.PromptSections) > 0 {
+		titles := make([]string, len(p.PromptSections))
+		for i, s := range p.PromptSections {
+			titles[i] = s.Title
+		}
+		settings = append(settings, setting{"prompt sections", strings.Join(titles, ", "), "profile " + name})
+	}
+	return settings, nil
+}
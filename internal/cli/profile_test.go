@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestProfile_ApplyDefaults tests that flags and the repository's config
+// take precedence over a profile, and that its provider and model go
+// together
+func TestProfile_ApplyDefaults(t *testing.T) {
+	p := &Profile{Provider: providerOpenAI, Model: "gpt-4.1", ThinkingBudget: 16000, Format: formatJSON}
+	tests := []struct {
+		name  string
+		args  []string
+		repo  *Config
+		check func(c *commonFlags, format string) bool
+	}{
+		{"profile only", nil, nil, func(c *commonFlags, format string) bool {
+			return *c.providerName == providerOpenAI && *c.model == "gpt-4.1" && *c.thinkingBudget == 16000 && format == formatJSON
+		}},
+		{"flag wins", []string{"-format", "sarif", "-thinking-budget", "2000"}, nil, func(c *commonFlags, format string) bool {
+			return format == "sarif" && *c.thinkingBudget == 2000 && *c.model == "gpt-4.1"
+		}},
+		{"repo config wins", nil, &Config{Model: "claude-opus-4-1"}, func(c *commonFlags, format string) bool {
+			// The profile's provider would not go with the repository's model
+			return *c.providerName == providerAnthropic && *c.model == "claude-opus-4-1" && format == formatJSON
+		}},
+		{"-provider leaves out the profile's model", []string{"-provider", "anthropic"}, nil, func(c *commonFlags, format string) bool {
+			return *c.providerName == providerAnthropic && *c.model == ""
+		}},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("review", flag.ContinueOnError)
+		c := addCommonFlags(fs)
+		format := fs.String("format", formatMarkdown, "")
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if tt.repo != nil {
+			if err := tt.repo.applyDefaults(fs, t.TempDir()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := p.applyDefaults(fs); err != nil {
+			t.Fatalf("%s: applyDefaults() returned error: %v", tt.name, err)
+		}
+		if !tt.check(c, *format) {
+			t.Errorf("%s: provider=%s model=%q thinking=%d format=%s", tt.name, *c.providerName, *c.model, *c.thinkingBudget, *format)
+		}
+	}
+
+	// Subcommands without -format still take the rest
+	fs := flag.NewFlagSet("postmortem", flag.ContinueOnError)
+	c := addCommonFlags(fs)
+	fs.Parse(nil)
+	if err := p.applyDefaults(fs); err != nil || *c.model != "gpt-4.1" {
+		t.Errorf("applyDefaults() without -format = %v, model %q", err, *c.model)
+	}
+}
+
+// TestGlobalConfig_Profiles tests selecting and validating profiles
+func TestGlobalConfig_Profiles(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(config, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setPathFlags(t, config, "", "")
+
+	write(`default_profile: work
+profiles:
+  work:
+    model: claude-sonnet-4-5
+  security-audit:
+    model: claude-opus-4-1
+    thinking_budget: 32000
+    prompt_sections:
+      - title: Security Focus
+        body: Concentrate on authentication, injection and secrets.
+`)
+	global, err := loadGlobalConfig()
+	if err != nil {
+		t.Fatalf("loadGlobalConfig() returned error: %v", err)
+	}
+	if p, name, err := global.profile(""); err != nil || name != "work" || p.Model != "claude-sonnet-4-5" {
+		t.Errorf("profile(\"\") = %+v, %q, %v; want the default", p, name, err)
+	}
+	if p, _, err := global.profile("security-audit"); err != nil || len(p.PromptSections) != 1 {
+		t.Errorf("profile(security-audit) = %+v, %v", p, err)
+	}
+	if _, _, err := global.profile("oss"); err == nil || !strings.Contains(err.Error(), "have security-audit, work") {
+		t.Errorf("profile(oss) = %v", err)
+	}
+	if p, _, err := (&GlobalConfig{}).profile(""); p != nil || err != nil {
+		t.Errorf("profile(\"\") without profiles = %+v, %v", p, err)
+	}
+
+	for _, bad := range []string{
+		"default_profile: oss\n",
+		"profiles:\n  work:\n    provider: mistral\n",
+		"profiles:\n  work:\n    provider: bedrock\n    api_key_env: WORK_KEY\n",
+		"profiles:\n  work:\n    api_key_env: A\n    api_key_command: echo b\n",
+		"profiles:\n  work:\n    format: html\n",
+		"profiles:\n  work:\n    prompt_sections:\n      - title: Empty\n",
+	} {
+		write(bad)
+		if _, err := loadGlobalConfig(); err == nil {
+			t.Errorf("loadGlobalConfig() accepted %q", bad)
+		}
+	}
+}
+
+// TestProfile_APIKey tests the sources of a profile's API key
+func TestProfile_APIKey(t *testing.T) {
+	t.Setenv("WORK_ANTHROPIC_KEY", "sk-work")
+	p := &Profile{Provider: providerAnthropic, APIKeyEnv: "WORK_ANTHROPIC_KEY"}
+	if key, err := p.apiKey(providerAnthropic); key != "sk-work" || err != nil {
+		t.Errorf("apiKey() from api_key_env = %q, %v", key, err)
+	}
+	// The key belongs to the profile's provider
+	if key, err := p.apiKey(providerOpenAI); key != "" || err != nil {
+		t.Errorf("apiKey() for another provider = %q, %v", key, err)
+	}
+	if _, err := (&Profile{APIKeyEnv: "UNSET_KEY_FOR_TEST"}).apiKey(providerOpenAI); err == nil {
+		t.Error("apiKey() with an unset api_key_env returned no error")
+	}
+
+	if key, err := (&Profile{APIKeyCommand: "echo sk-oss"}).apiKey(providerOpenAI); key != "sk-oss" || err != nil {
+		t.Errorf("apiKey() from api_key_command = %q, %v", key, err)
+	}
+	if _, err := (&Profile{APIKeyCommand: "exit 1"}).apiKey(providerOpenAI); err == nil {
+		t.Error("apiKey() with a failing api_key_command returned no error")
+	}
+	var none *Profile
+	if key, err := none.apiKey(providerAnthropic); key != "" || err != nil {
+		t.Errorf("apiKey() without a profile = %q, %v", key, err)
+	}
+}
+
+// TestEffectiveSettings tests reporting each setting with what set it
+func TestEffectiveSettings(t *testing.T) {
+	t.Chdir(t.TempDir())
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(config, []byte(`default_profile: oss
+profiles:
+  oss:
+    provider: openai
+    model: gpt-4.1
+    api_key_command: pass show openai
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	common := addCommonFlags(fs)
+	fs.String("format", formatMarkdown, "")
+	if err := fs.Parse([]string{"-config", config, "-thinking-budget", "5000"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { configFlag = "" })
+
+	settings, err := effectiveSettings(fs, common)
+	if err != nil {
+		t.Fatalf("effectiveSettings() returned error: %v", err)
+	}
+	got := make(map[string]string)
+	for _, s := range settings {
+		got[s.Name] = s.Value + " (" + s.Source + ")"
+	}
+	for name, want := range map[string]string{
+		"profile":         "oss (default_profile in the global config)",
+		"provider":        "openai (profile oss)",
+		"model":           "gpt-4.1 (profile oss)",
+		"api key":         "api_key_command (profile oss)",
+		"thinking-budget": "5000 (flag)",
+		"format":          "markdown (default)",
+	} {
+		if got[name] != want {
+			t.Errorf("%s = %q, want %q", name, got[name], want)
+		}
+	}
+}
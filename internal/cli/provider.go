@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/llm"
+)
+
+// The provider interface and its types are in pkg/llm, for programs that
+// embed the review engine
+type (
+	Provider          = llm.Provider
+	CompletionOptions = llm.CompletionOptions
+	Usage             = llm.Usage
+)
+
+// Providers selectable with -provider
+const (
+	providerAnthropic = llm.ProviderAnthropic
+	providerOpenAI    = llm.ProviderOpenAI
+	providerAzure     = llm.ProviderAzure
+	providerBedrock   = llm.ProviderBedrock
+)
+
+// defaultModels is the model used with each provider when -model isn't set.
+// Azure deployments are named by their owners, so there is no default;
+// AZURE_OPENAI_DEPLOYMENT can set one.
+var defaultModels = llm.DefaultModels
+
+// completionOptions returns the model settings from the flags
+func (c *commonFlags) completionOptions() CompletionOptions {
+	return CompletionOptions{
+		Model:          *c.model,
+		Thinking:       !*c.noThinking,
+		ThinkingBudget: *c.thinkingBudget,
+		MaxTokens:      *c.maxTokens,
+	}
+}
+
+// provider returns the backend selected with -provider, exiting if it is
+// unknown, its API key is not set, or the org policy doesn't allow it or
+// the model. -model defaults to the provider's default model. A transcript
+// is recorded if -transcript is set, with the policy's redaction rules
+// applied to it too. The profile, if one is selected, is applied first.
+func (c *commonFlags) provider() (Provider, *Policy) {
+	c.applyProfile()
+	name := strings.ToLower(*c.providerName)
+	if _, ok := defaultModels[name]; !ok {
+		fail(exitUsage, "Error: invalid -provider %q (want anthropic, openai, azure or bedrock)", *c.providerName)
+	}
+	if *c.model == "" {
+		*c.model = defaultModels[name]
+		if name == providerAzure {
+			*c.model = os.Getenv("AZURE_OPENAI_DEPLOYMENT")
+		}
+	}
+	if *c.model == "" {
+		fail(exitUsage, "Error: -provider azure requires the deployment name in -model or AZURE_OPENAI_DEPLOYMENT")
+	}
+
+	global, err := loadGlobalConfig()
+	if err != nil {
+		fail(exitUsage, "Error loading config: %v", err)
+	}
+	opts := llm.Options{
+		MaxContinuations:  *c.continuations,
+		MaxAttempts:       max(*c.maxAttempts, 1),
+		Headers:           requestHeaders(global, name, c.headers),
+		UserID:            *c.userID,
+		NoPromptCache:     *c.noPromptCache,
+		StreamIdleTimeout: c.streamIdleTimeout,
+		Log:               os.Stderr,
+		TruncatedNotice:   truncatedNotice,
+		ThinkingLabel:     tr("Thinking"),
+	}
+	if opts.UserID == "" {
+		opts.UserID = global.UserID
+	}
+
+	policy := mustLoadPolicy(name, *c.model)
+	ledger = openLedger(c.command, name)
+	opts.OnUsage = ledger.record
+	if *c.transcript != "" {
+		c.log = newTranscript(*c.transcript, policy)
+		opts.Transcript = c.log
+	}
+
+	switch name {
+	case providerOpenAI:
+		opts.APIKey = c.apiKey(name, "OPENAI_API_KEY")
+		opts.BaseURL = os.Getenv("OPENAI_BASE_URL")
+	case providerAnthropic:
+		opts.APIKey = c.apiKey(name, "ANTHROPIC_API_KEY")
+	}
+	client, err := llm.New(name, opts)
+	switch {
+	case err != nil && name == providerAzure:
+		fail(exitUsage, "Error: -provider azure: %v", err)
+	case err != nil && name == providerBedrock:
+		fail(exitUsage, "Error: -provider bedrock requires AWS credentials: %v", err)
+	case err != nil:
+		fail(exitUsage, "Error: %v", err)
+	}
+	return client, policy
+}
+
+// printTranscript says where the transcript was written, if one was
+func (c *commonFlags) printTranscript() {
+	if c.log != nil {
+		fmt.Printf("📝 Transcript written to: %s\n\n", c.log)
+	}
+}
+
+// requireEnv returns the value of an environment variable holding an API
+// key, or exits with exitUsage if it is not set
+func requireEnv(name string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		fail(exitUsage, "Error: %s environment variable not set", name)
+	}
+	return value
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// pullTarget is a pull request fetched for review without checking it out
+type pullTarget struct {
+	Number int
+	Head   string // local ref of the fetched head
+	Base   string // ref to diff against
+	Branch string // the pull request's branch, for display and history
+	Labels []string
+}
+
+// pullRequestInfo returns a pull request's head branch, base branch and
+// labels from the GitHub API
+func (g *githubClient) pullRequestInfo(pr int) (head, base string, labels []string, err error) {
+	var pull struct {
+		Head struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+	if err := g.do("GET", fmt.Sprintf("/repos/%s/%s/pulls/%d", g.owner, g.repo, pr), nil, &pull); err != nil {
+		return "", "", nil, fmt.Errorf("failed to get pull request #%d: %w", pr, err)
+	}
+	for _, l := range pull.Labels {
+		labels = append(labels, l.Name)
+	}
+	return pull.Head.Ref, pull.Base.Ref, labels, nil
+}
+
+// pullSource is where a pull request's head can be fetched from, and its
+// branches if the code host's API was available to look them up
+type pullSource struct {
+	Remote     string // remote name or URL
+	Ref        string
+	Branch     string
+	BaseBranch string
+	Labels     []string
+}
+
+// pullRequestHost returns the code host -pr fetches from: the -post
+// destination if set, else Bitbucket for bitbucket.org remotes or when
+// BITBUCKET_URL names a Bitbucket Server, Gitea for codeberg.org remotes or
+// when GITEA_URL is set, else GitHub
+func pullRequestHost(post string) string {
+	if post != "" {
+		return post
+	}
+	remote := getRepoIdentity()
+	switch {
+	case strings.HasPrefix(remote, "bitbucket.org/") || os.Getenv("BITBUCKET_URL") != "":
+		return postBitbucket
+	case strings.HasPrefix(remote, "codeberg.org/") || os.Getenv("GITEA_URL") != "":
+		return postGitea
+	}
+	return postGitHub
+}
+
+// githubPullSource returns pull request pr's head ref on origin, and its
+// branches from the API if a GitHub token is set
+func githubPullSource(pr int) (*pullSource, error) {
+	src := &pullSource{Remote: "origin", Ref: fmt.Sprintf("refs/pull/%d/head", pr)}
+	if githubToken() != "" {
+		if gh, err := newGitHubClient(getRepoIdentity()); err == nil {
+			head, base, labels, err := gh.pullRequestInfo(pr)
+			if err != nil {
+				return nil, err
+			}
+			src.Branch, src.BaseBranch, src.Labels = head, base, labels
+		}
+	}
+	return src, nil
+}
+
+// fetchPullRequest fetches pull request pr from host into private refs
+// under refs/pr-review/, leaving the working tree and branches alone. The
+// base is base if given; otherwise the pull request's base branch (from
+// the API if a token is set, else the default branch) is fetched too.
+func fetchPullRequest(host string, pr int, base string) (*pullTarget, error) {
+	var src *pullSource
+	var err error
+	switch host {
+	case postBitbucket:
+		src, err = bitbucketPullSource(pr)
+	case postGitea:
+		src, err = giteaPullSource(pr)
+	default:
+		src, err = githubPullSource(pr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	target := &pullTarget{
+		Number: pr,
+		Head:   fmt.Sprintf("refs/pr-review/pull/%d/head", pr),
+		Branch: src.Branch,
+		Labels: src.Labels,
+	}
+	if target.Branch == "" {
+		target.Branch = fmt.Sprintf("pull/%d", pr)
+	}
+	fetches := map[string][]string{src.Remote: {fmt.Sprintf("+%s:%s", src.Ref, target.Head)}}
+
+	if base == "" {
+		baseBranch := src.BaseBranch
+		if baseBranch == "" {
+			baseBranch = getDefaultBranch()
+			fmt.Fprintf(os.Stderr, "Warning: Without an API token the base of #%d is unknown; assuming %s (set -base to override)\n", pr, baseBranch)
+		}
+		base = fmt.Sprintf("refs/pr-review/pull/%d/base", pr)
+		fetches["origin"] = append(fetches["origin"], fmt.Sprintf("+refs/heads/%s:%s", baseBranch, base))
+	}
+	target.Base = base
+
+	if err := fetchRefs(fmt.Sprintf("pull request #%d", pr), fetches); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
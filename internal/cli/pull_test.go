@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+// TestFetchPullRequest tests fetching a pull request's head and base from
+// origin without touching the checked-out branch
+func TestFetchPullRequest(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	origin, clone := t.TempDir(), t.TempDir()
+
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := gitCommand(append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	// The "server" has main and a pull request ref, as GitHub does
+	git(origin, "init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(origin, "app.go"), []byte("package app\n"), 0644)
+	git(origin, "add", ".")
+	git(origin, "commit", "-q", "-m", "base")
+	git(origin, "checkout", "-q", "-b", "contributor")
+	os.WriteFile(filepath.Join(origin, "app.go"), []byte("package app\n\nfunc New() {}\n"), 0644)
+	git(origin, "commit", "-q", "-am", "add New")
+	git(origin, "update-ref", "refs/pull/5/head", "HEAD")
+	git(origin, "checkout", "-q", "main")
+
+	git(clone, "clone", "-q", origin, ".")
+	t.Chdir(clone)
+
+	target, err := fetchPullRequest(postGitHub, 5, "")
+	if err != nil {
+		t.Fatalf("fetchPullRequest() returned error: %v", err)
+	}
+	if target.Branch != "pull/5" || target.Base != "refs/pr-review/pull/5/base" {
+		t.Errorf("target = %+v", target)
+	}
+	changes, err := collectChanges(target.Base, target.Head)
+	if err != nil {
+		t.Fatalf("collectChanges() returned error: %v", err)
+	}
+	if files := gitdiff.Files(changes.Diff); len(files) != 1 || files[0] != "app.go" {
+		t.Errorf("diffFiles() = %v", files)
+	}
+	if branch := getCurrentBranch(); branch != "main" {
+		t.Errorf("checked-out branch = %q, want main untouched", branch)
+	}
+
+	if _, err := fetchPullRequest(postGitHub, 6, ""); err == nil {
+		t.Error("fetchPullRequest() of a missing pull request expected error")
+	}
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"flag"
+	"strings"
+)
+
+// quickMaxTokens caps the output of a -quick review, which is a summary
+const quickMaxTokens = 8000
+
+// quickInstructions asks for the short review -quick gives, e.g. before a
+// push
+const quickInstructions = `This is a quick review, run before the change is pushed. Give a short summary of the change and report only the issues worth stopping for: bugs, security problems, data loss and broken builds, rated high or critical when they are. Keep each finding brief, and leave out style, naming and other minor suggestions.`
+
+// applyQuick sets up a -quick review once the provider is known: the
+// provider's cheap model unless one was chosen, no extended thinking, a
+// smaller output limit unless -max-tokens was given, and neither the
+// flaky-test pass nor the pre_review commands unless asked for
+func applyQuick(fs *flag.FlagSet, c *commonFlags, policy *Policy) {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if model := prescreenModels[strings.ToLower(*c.providerName)]; !given["model"] && model != "" && policy.checkModel(model) == nil {
+		*c.model = model
+	}
+	if !given["max-tokens"] {
+		*c.maxTokens = quickMaxTokens
+	}
+	*c.noThinking = true
+	for _, name := range []string{"no-flaky-check", "no-pre-review"} {
+		if !given[name] {
+			fs.Set(name, "true")
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRedisPrefix namespaces the keys pr-review keeps in Redis
+const defaultRedisPrefix = "pr-review:"
+
+// redisTimeout bounds connecting to Redis and each command
+const redisTimeout = 30 * time.Second
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisConn is a connection speaking RESP, Redis's protocol; the few
+// commands history and the cache need don't call for a client library
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialRedis connects to the server at a redis:// or (for TLS) rediss://
+// URL, authenticating with the URL's user and password and selecting the
+// database in its path
+func dialRedis(rawURL string) (*redisConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Redis URL (want redis://[user:password@]host[:port][/db])")
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	if u.Scheme == "rediss" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+
+	if password, ok := u.User.Password(); ok {
+		args := []string{"AUTH", password}
+		if user := u.User.Username(); user != "" {
+			args = []string{"AUTH", user, password}
+		}
+		if _, err := c.do(args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" && db != "0" {
+		if _, err := c.do("SELECT", db); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+// do sends a command and returns its reply: a string, an int64, a []byte,
+// a []any, or nil
+func (c *redisConn) do(args ...string) (any, error) {
+	c.conn.SetDeadline(time.Now().Add(redisTimeout))
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write(b.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to send Redis command: %w", err)
+	}
+	return c.read()
+}
+
+// read reads one reply
+func (c *redisConn) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Redis reply: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("malformed Redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, fmt.Errorf("failed to read Redis reply: %w", err)
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		// Read every item, even after an error reply, so the next reply
+		// starts where it should
+		items := make([]any, n)
+		var first error
+		for i := range items {
+			if items[i], err = c.read(); err != nil && first == nil {
+				first = err
+			}
+		}
+		return items, first
+	}
+	return nil, fmt.Errorf("malformed Redis reply %q", line)
+}
+
+// bulk sends a command that replies with a string, returning nil if the
+// reply is nil
+func (c *redisConn) bulk(args ...string) ([]byte, error) {
+	reply, err := c.do(args...)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := reply.([]byte)
+	return data, nil
+}
+
+// list sends a command that replies with an array of strings
+func (c *redisConn) list(args ...string) ([]string, error) {
+	reply, err := c.do(args...)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]any)
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		data, _ := item.([]byte)
+		values = append(values, string(data))
+	}
+	return values, nil
+}
+
+// transaction runs commands atomically with MULTI and EXEC
+func (c *redisConn) transaction(commands ...[]string) error {
+	if _, err := c.do("MULTI"); err != nil {
+		return err
+	}
+	for _, args := range commands {
+		if _, err := c.do(args...); err != nil {
+			c.do("DISCARD")
+			return err
+		}
+	}
+	_, err := c.do("EXEC")
+	return err
+}
+
+// redisStorage keeps the history of every repository, and the shared
+// cache, in Redis. Each repository's reviews are a hash from ID to the
+// review as JSON, gzipped if it is large.
+type redisStorage struct {
+	url    string
+	prefix string
+}
+
+func (s *redisStorage) OpenHistory(repo string) (reviewHistory, error) {
+	c, err := dialRedis(s.url)
+	if err != nil {
+		return nil, err
+	}
+	return &redisHistory{c: c, prefix: s.prefix, repo: repo, owned: true}, nil
+}
+
+func (s *redisStorage) EachHistory(repo string, fn func(reviewHistory) error) error {
+	c, err := dialRedis(s.url)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	repos := []string{repo}
+	if repo == "" {
+		if repos, err = c.list("SMEMBERS", s.prefix+"repos"); err != nil {
+			return fmt.Errorf("failed to list repositories: %w", err)
+		}
+		sort.Strings(repos)
+	}
+	for _, repo := range repos {
+		h := &redisHistory{c: c, prefix: s.prefix, repo: repo}
+		reply, err := c.do("HLEN", h.key("reviews"))
+		if err != nil {
+			return err
+		}
+		if n, _ := reply.(int64); n == 0 {
+			continue
+		}
+		if err := fn(h); err != nil {
+			return fmt.Errorf("%s: %w", repo, err)
+		}
+	}
+	return nil
+}
+
+func (s *redisStorage) OpenCache() (cacheStore, error) {
+	c, err := dialRedis(s.url)
+	if err != nil {
+		return nil, err
+	}
+	return &redisCache{c: c, prefix: s.prefix + "cache:"}, nil
+}
+
+// redisHistory is one repository's history in Redis
+type redisHistory struct {
+	c      *redisConn
+	prefix string
+	repo   string
+	owned  bool // Close closes the connection
+
+	// seen holds the reviewIdentity of each of the repository's reviews,
+	// once Contains has read them
+	seen map[string]bool
+}
+
+// key returns the name of one of the repository's keys
+func (h *redisHistory) key(parts ...string) string {
+	return h.prefix + "repo:" + repoKey(h.repo) + ":" + strings.Join(parts, ":")
+}
+
+// reviewIdentity is what Contains compares reviews by
+func reviewIdentity(r *reviewRecord) string {
+	return strings.Join([]string{r.CreatedAt.UTC().Format(time.RFC3339Nano), r.BaseSHA, r.HeadSHA, r.Model}, "\x00")
+}
+
+// Record saves a review, filling in its ID and creation time. The ID comes
+// from a counter every repository shares, as in a SQL table.
+func (h *redisHistory) Record(r *reviewRecord) error {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+	reply, err := h.c.do("INCR", h.prefix+"next-id")
+	if err != nil {
+		return fmt.Errorf("failed to record review: %w", err)
+	}
+	r.ID, _ = reply.(int64)
+	value, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error marshaling review: %w", err)
+	}
+	if len(value) >= compressMinSize {
+		if value, err = gzipBytes(value); err != nil {
+			return fmt.Errorf("error compressing review: %w", err)
+		}
+	}
+
+	id := strconv.FormatInt(r.ID, 10)
+	commands := [][]string{
+		{"HSET", h.key("reviews"), id, string(value)},
+		{"SADD", h.prefix + "repos", h.repo},
+	}
+	if r.Kind == "" {
+		commands = append(commands, []string{"SET", h.key("latest", r.BaseSHA, r.HeadSHA), id})
+	}
+	if err := h.c.transaction(commands...); err != nil {
+		return fmt.Errorf("failed to record review: %w", err)
+	}
+	if h.seen != nil {
+		h.seen[reviewIdentity(r)] = true
+	}
+	return nil
+}
+
+// decodeRedisReview reads a review saved by Record
+func decodeRedisReview(value []byte) (*reviewRecord, error) {
+	if bytes.HasPrefix(value, []byte{0x1f, 0x8b}) {
+		var err error
+		if value, err = gunzip(value); err != nil {
+			return nil, fmt.Errorf("corrupt review: %w", err)
+		}
+	}
+	var r reviewRecord
+	if err := json.Unmarshal(value, &r); err != nil {
+		return nil, fmt.Errorf("invalid review: %w", err)
+	}
+	return &r, nil
+}
+
+// FindLatest returns the most recent review of headSHA against baseSHA in
+// repo, or nil if there is none. Postmortems aren't reviews to reuse.
+func (h *redisHistory) FindLatest(repo, baseSHA, headSHA string) (*reviewRecord, error) {
+	if repo != h.repo {
+		return nil, nil
+	}
+	id, err := h.c.bulk("GET", h.key("latest", baseSHA, headSHA))
+	if err != nil || id == nil {
+		return nil, err
+	}
+	// The review may since have been pruned
+	value, err := h.c.bulk("HGET", h.key("reviews"), string(id))
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return decodeRedisReview(value)
+}
+
+// redisEntry is a review as stored, for measuring it
+type redisEntry struct {
+	review *reviewRecord
+	size   int
+}
+
+// entries returns the repository's reviews, oldest first
+func (h *redisHistory) entries() ([]redisEntry, error) {
+	reply, err := h.c.do("HVALS", h.key("reviews"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviews: %w", err)
+	}
+	values, _ := reply.([]any)
+	entries := make([]redisEntry, 0, len(values))
+	for _, v := range values {
+		value, _ := v.([]byte)
+		r, err := decodeRedisReview(value)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, redisEntry{review: r, size: len(value)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].review.ID < entries[j].review.ID })
+	return entries, nil
+}
+
+// List returns every review of the repository, oldest first
+func (h *redisHistory) List() ([]*reviewRecord, error) {
+	entries, err := h.entries()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*reviewRecord, len(entries))
+	for i, e := range entries {
+		records[i] = e.review
+	}
+	return records, nil
+}
+
+// Contains reports whether the repository already has a review of the same
+// commits by the same model made at the same time, so imports are
+// idempotent. The reviews are read once, on the first call.
+func (h *redisHistory) Contains(r *reviewRecord) (bool, error) {
+	if h.seen == nil {
+		records, err := h.List()
+		if err != nil {
+			return false, err
+		}
+		h.seen = make(map[string]bool, len(records))
+		for _, existing := range records {
+			h.seen[reviewIdentity(existing)] = true
+		}
+	}
+	return h.seen[reviewIdentity(r)], nil
+}
+
+// Prune removes the reviews the retention policy doesn't keep, returning how
+// many it removed, going by the size of reviews as stored
+func (h *redisHistory) Prune(keep Retention, now time.Time) (int, error) {
+	entries, err := h.entries()
+	if err != nil {
+		return 0, err
+	}
+	var remove []string
+	if keep.MaxAge > 0 {
+		cutoff := now.Add(-time.Duration(keep.MaxAge))
+		kept := entries[:0]
+		for _, e := range entries {
+			if e.review.CreatedAt.Before(cutoff) {
+				remove = append(remove, strconv.FormatInt(e.review.ID, 10))
+			} else {
+				kept = append(kept, e)
+			}
+		}
+		entries = kept
+	}
+	if keep.MaxSize > 0 {
+		// Keep the newest reviews that fit, and always the latest one
+		var total ByteSize
+		i := len(entries) - 1
+		for ; i >= 0; i-- {
+			size := ByteSize(entries[i].size + 256)
+			if i < len(entries)-1 && total+size > keep.MaxSize {
+				break
+			}
+			total += size
+		}
+		for ; i >= 0; i-- {
+			remove = append(remove, strconv.FormatInt(entries[i].review.ID, 10))
+		}
+	}
+	if len(remove) == 0 {
+		return 0, nil
+	}
+	if _, err := h.c.do(append([]string{"HDEL", h.key("reviews")}, remove...)...); err != nil {
+		return 0, fmt.Errorf("failed to remove reviews: %w", err)
+	}
+	h.seen = nil
+	return len(remove), nil
+}
+
+// Compact does nothing: reviews are compressed as they are saved
+func (h *redisHistory) Compact() error {
+	return nil
+}
+
+// Vacuum does nothing: Redis frees memory as reviews are removed
+func (h *redisHistory) Vacuum() error {
+	return nil
+}
+
+// Size returns about how much the repository's reviews take up
+func (h *redisHistory) Size() (ByteSize, error) {
+	entries, err := h.entries()
+	if err != nil {
+		return 0, err
+	}
+	var size ByteSize
+	for _, e := range entries {
+		size += ByteSize(e.size + 256)
+	}
+	return size, nil
+}
+
+func (h *redisHistory) Close() error {
+	if !h.owned {
+		return nil
+	}
+	return h.c.Close()
+}
+
+// redisCache keeps cached values as Redis strings
+type redisCache struct {
+	c      *redisConn
+	prefix string
+}
+
+func (c *redisCache) Get(key string) ([]byte, error) {
+	return c.c.bulk("GET", c.prefix+key)
+}
+
+func (c *redisCache) Put(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", c.prefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "EX", strconv.Itoa(int(ttl/time.Second)))
+	}
+	_, err := c.c.do(args...)
+	return err
+}
+
+func (c *redisCache) Close() error {
+	return c.c.Close()
+}
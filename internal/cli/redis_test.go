@@ -0,0 +1,327 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves the commands the Redis storage uses from memory
+type fakeRedis struct {
+	mu       sync.Mutex
+	password string
+	strs     map[string]string
+	expiry   map[string]string
+	hashes   map[string]map[string]string
+	sets     map[string]map[string]bool
+	commands []string
+}
+
+// startFakeRedis listens on a local port and returns its redis:// URL
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{password: password, strs: map[string]string{}, expiry: map[string]string{}, hashes: map[string]map[string]string{}, sets: map[string]map[string]bool{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	auth := ""
+	if password != "" {
+		auth = ":" + password + "@"
+	}
+	return f, "redis://" + auth + ln.Addr().String() + "/2"
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readFakeCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+		f.mu.Lock()
+		f.commands = append(f.commands, name)
+		f.mu.Unlock()
+
+		switch {
+		case name == "AUTH":
+			authed = args[len(args)-1] == f.password
+			if !authed {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			io.WriteString(conn, "+OK\r\n")
+		case !authed:
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+		case name == "MULTI":
+			inMulti, queued = true, nil
+			io.WriteString(conn, "+OK\r\n")
+		case name == "EXEC":
+			fmt.Fprintf(conn, "*%d\r\n", len(queued))
+			for _, q := range queued {
+				io.WriteString(conn, f.exec(q))
+			}
+			inMulti = false
+		case inMulti:
+			queued = append(queued, args)
+			io.WriteString(conn, "+QUEUED\r\n")
+		default:
+			io.WriteString(conn, f.exec(args))
+		}
+	}
+}
+
+func readFakeCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// exec runs a command and returns its encoded reply
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bulk := func(s string, ok bool) string {
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+	}
+	array := func(values []string) string {
+		out := fmt.Sprintf("*%d\r\n", len(values))
+		for _, v := range values {
+			out += bulk(v, true)
+		}
+		return out
+	}
+	hash := func(key string) map[string]string {
+		if f.hashes[key] == nil {
+			f.hashes[key] = map[string]string{}
+		}
+		return f.hashes[key]
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		v, ok := f.strs[args[1]]
+		return bulk(v, ok)
+	case "SET":
+		f.strs[args[1]] = args[2]
+		delete(f.expiry, args[1])
+		if len(args) == 5 && strings.ToUpper(args[3]) == "EX" {
+			f.expiry[args[1]] = args[4]
+		}
+		return "+OK\r\n"
+	case "INCR":
+		n, _ := strconv.Atoi(f.strs[args[1]])
+		f.strs[args[1]] = strconv.Itoa(n + 1)
+		return fmt.Sprintf(":%d\r\n", n+1)
+	case "HSET":
+		hash(args[1])[args[2]] = args[3]
+		return ":1\r\n"
+	case "HGET":
+		v, ok := hash(args[1])[args[2]]
+		return bulk(v, ok)
+	case "HLEN":
+		return fmt.Sprintf(":%d\r\n", len(hash(args[1])))
+	case "HVALS":
+		var values []string
+		for _, v := range hash(args[1]) {
+			values = append(values, v)
+		}
+		return array(values)
+	case "HDEL":
+		for _, field := range args[2:] {
+			delete(hash(args[1]), field)
+		}
+		return fmt.Sprintf(":%d\r\n", len(args)-2)
+	case "SADD":
+		if f.sets[args[1]] == nil {
+			f.sets[args[1]] = map[string]bool{}
+		}
+		f.sets[args[1]][args[2]] = true
+		return ":1\r\n"
+	case "SMEMBERS":
+		var members []string
+		for m := range f.sets[args[1]] {
+			members = append(members, m)
+		}
+		sort.Strings(members)
+		return array(members)
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+// TestRedisHistory tests keeping review history in Redis
+func TestRedisHistory(t *testing.T) {
+	fake, url := startFakeRedis(t, "secret")
+	storage := &redisStorage{url: url, prefix: defaultRedisPrefix}
+
+	h, err := storage.OpenHistory("github.com/org/app")
+	if err != nil {
+		t.Fatalf("OpenHistory() returned error: %v", err)
+	}
+	defer h.Close()
+
+	now := time.Now()
+	long := strings.Repeat("The error from Close is ignored.\n", 200)
+	records := []*reviewRecord{
+		{Repo: "github.com/org/app", BaseSHA: "a", HeadSHA: "b", Model: "m", Review: "old", CreatedAt: now.AddDate(0, 0, -100)},
+		{Repo: "github.com/org/app", BaseSHA: "a", HeadSHA: "b", Model: "m", Review: long, Findings: []Finding{{File: "a.go", Title: "Bug"}}},
+		{Repo: "github.com/org/app", BaseSHA: "a", HeadSHA: "b", Model: "m", Review: "look back", Kind: reviewKindPostmortem},
+	}
+	for _, r := range records {
+		if err := h.Record(r); err != nil {
+			t.Fatalf("Record() returned error: %v", err)
+		}
+	}
+	if records[2].ID != 3 {
+		t.Errorf("third review has ID %d", records[2].ID)
+	}
+
+	got, err := h.FindLatest("github.com/org/app", "a", "b")
+	if err != nil || got == nil || got.ID != 2 || got.Review != long || len(got.Findings) != 1 {
+		t.Fatalf("FindLatest() = %+v, %v; want the latest review, not the postmortem", got, err)
+	}
+	if other, _ := h.FindLatest("github.com/org/lib", "a", "b"); other != nil {
+		t.Error("FindLatest() found another repository's review")
+	}
+
+	if ok, err := h.Contains(records[0]); !ok || err != nil {
+		t.Errorf("Contains() of a recorded review = %v, %v", ok, err)
+	}
+	if ok, _ := h.Contains(&reviewRecord{CreatedAt: now, BaseSHA: "x", HeadSHA: "y", Model: "m"}); ok {
+		t.Error("Contains() of a new review = true")
+	}
+
+	removed, err := h.Prune(Retention{MaxAge: Age(30 * 24 * time.Hour)}, now)
+	if err != nil || removed != 1 {
+		t.Errorf("Prune() = %d, %v; want the 100-day-old review removed", removed, err)
+	}
+	list, err := h.List()
+	if err != nil || len(list) != 2 || list[0].ID != 2 || list[1].ID != 3 {
+		t.Errorf("List() after Prune() = %+v, %v", list, err)
+	}
+
+	// Every repository with history is visited, with no new connections per
+	// repository
+	other, err := storage.OpenHistory("github.com/org/lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Record(&reviewRecord{Repo: "github.com/org/lib", Model: "m", Review: "lib"})
+	other.Close()
+	var repos []string
+	err = storage.EachHistory("", func(h reviewHistory) error {
+		list, err := h.List()
+		if err == nil {
+			repos = append(repos, list[0].Repo)
+		}
+		return err
+	})
+	if err != nil || strings.Join(repos, ",") != "github.com/org/app,github.com/org/lib" {
+		t.Errorf("EachHistory() visited %v, %v", repos, err)
+	}
+
+	// Recording is one transaction
+	fake.mu.Lock()
+	commands := strings.Join(fake.commands, " ")
+	fake.mu.Unlock()
+	if !strings.Contains(commands, "AUTH SELECT INCR MULTI HSET SADD SET EXEC") {
+		t.Errorf("commands = %s", commands)
+	}
+}
+
+// TestRedisCache tests that cached values expire after their TTL in Redis
+func TestRedisCache(t *testing.T) {
+	fake, url := startFakeRedis(t, "")
+	cache, err := (&redisStorage{url: url, prefix: defaultRedisPrefix}).OpenCache()
+	if err != nil {
+		t.Fatalf("OpenCache() returned error: %v", err)
+	}
+	defer cache.Close()
+
+	if err := cache.Put("reviews:abc", []byte("Looks good."), reviewCacheTTL); err != nil {
+		t.Fatalf("Put() returned error: %v", err)
+	}
+	if err := cache.Put("files:abc", []byte("file_1"), 0); err != nil {
+		t.Fatalf("Put() returned error: %v", err)
+	}
+	if got, err := cache.Get("reviews:abc"); string(got) != "Looks good." || err != nil {
+		t.Errorf("Get() = %q, %v", got, err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if ttl := fake.expiry[defaultRedisPrefix+"cache:reviews:abc"]; ttl != "2592000" {
+		t.Errorf("review expires after %q seconds, want 30 days", ttl)
+	}
+	if ttl, ok := fake.expiry[defaultRedisPrefix+"cache:files:abc"]; ok {
+		t.Errorf("value without a TTL expires after %q seconds", ttl)
+	}
+}
+
+// TestRedisConn_Errors tests error replies and bad URLs
+func TestRedisConn_Errors(t *testing.T) {
+	_, url := startFakeRedis(t, "secret")
+	if _, err := dialRedis(strings.Replace(url, "secret", "wrong", 1)); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("dialRedis() with a wrong password = %v", err)
+	}
+	for _, bad := range []string{"http://localhost", "redis://", "localhost:6379"} {
+		if _, err := dialRedis(bad); err == nil {
+			t.Errorf("dialRedis(%q) returned no error", bad)
+		}
+	}
+
+	c, err := dialRedis(url)
+	if err != nil {
+		t.Fatalf("dialRedis() returned error: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.do("FLUSHALL"); err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("do() of an unknown command = %v", err)
+	}
+	// The connection is still usable after an error
+	if reply, err := c.bulk("GET", "missing"); reply != nil || err != nil {
+		t.Errorf("bulk() of a missing key = %q, %v", reply, err)
+	}
+}
@@ -0,0 +1,392 @@
+package cli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// compressMinSize is the size of review text and findings below which they
+// are stored as they are; gzip saves little on short text
+const compressMinSize = 1024
+
+// packText returns the review and findings columns of a review, gzipped if
+// they are large enough to be worth it, and whether they were
+func packText(review, findings []byte) ([2]any, bool, error) {
+	if len(review)+len(findings) < compressMinSize {
+		return [2]any{string(review), string(findings)}, false, nil
+	}
+	zr, err := gzipBytes(review)
+	if err != nil {
+		return [2]any{}, false, err
+	}
+	zf, err := gzipBytes(findings)
+	if err != nil {
+		return [2]any{}, false, err
+	}
+	return [2]any{zr, zf}, true, nil
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// ByteSize is a size such as "500MB" or "2GiB"; units are powers of 1024
+type ByteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   ByteSize
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+func parseByteSize(s string) (ByteSize, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	unit := ByteSize(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(text, u.suffix) {
+			text, unit = strings.TrimSpace(strings.TrimSuffix(text, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 500MB or 2GB)", s)
+	}
+	return ByteSize(n * float64(unit)), nil
+}
+
+func (b ByteSize) String() string {
+	switch {
+	case b >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(b)/(1<<30))
+	case b >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(b)/(1<<20))
+	case b >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(b)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", int64(b))
+	}
+}
+
+// Set implements flag.Value
+func (b *ByteSize) Set(s string) error {
+	parsed, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	return b.Set(string(text))
+}
+
+// Age is a duration that also accepts days and weeks, e.g. "90d" or "2w"
+type Age time.Duration
+
+func parseAge(s string) (Age, error) {
+	text := strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(text, suffix); ok {
+			days, err := strconv.Atoi(n)
+			if err != nil || days < 0 {
+				return 0, fmt.Errorf("invalid age %q (want e.g. 90d, 2w or 72h)", s)
+			}
+			return Age(time.Duration(days) * unit), nil
+		}
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (want e.g. 90d, 2w or 72h)", s)
+	}
+	return Age(d), nil
+}
+
+func (a Age) String() string {
+	if a == 0 {
+		return "0"
+	}
+	if d := time.Duration(a); d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return time.Duration(a).String()
+}
+
+// Set implements flag.Value
+func (a *Age) Set(s string) error {
+	parsed, err := parseAge(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a *Age) UnmarshalText(text []byte) error {
+	return a.Set(string(text))
+}
+
+// Retention limits how much review history is kept; zero fields don't limit
+type Retention struct {
+	// MaxAge removes reviews older than this
+	MaxAge Age `yaml:"max_age"`
+
+	// MaxSize removes the oldest reviews until the rest take about this
+	// much space
+	MaxSize ByteSize `yaml:"max_size"`
+}
+
+func (r Retention) enabled() bool {
+	return r.MaxAge > 0 || r.MaxSize > 0
+}
+
+// Prune removes the reviews the retention policy doesn't keep, returning how
+// many it removed. The database file only shrinks when it is vacuumed.
+func (h *historyStore) Prune(keep Retention, now time.Time) (int, error) {
+	removed := 0
+	if keep.MaxAge > 0 {
+		cutoff := now.Add(-time.Duration(keep.MaxAge)).UTC().Format(time.RFC3339Nano)
+		where, args := h.where("created_at < ?", cutoff)
+		res, err := h.db.Exec(h.bind(`DELETE FROM reviews `+where), args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to remove old reviews: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		removed += int(n)
+	}
+	if keep.MaxSize > 0 {
+		// Keep the newest reviews that fit, going by the size of their text,
+		// and always the latest one
+		where, args := h.where("")
+		rows, err := h.db.Query(h.bind(`SELECT id, length(review) + length(findings) + 256 FROM reviews `+where+` ORDER BY id DESC`), args...)
+		if err != nil {
+			return removed, fmt.Errorf("failed to measure reviews: %w", err)
+		}
+		var total ByteSize
+		var oldestKept int64
+		for rows.Next() {
+			var id int64
+			var size ByteSize
+			if err := rows.Scan(&id, &size); err != nil {
+				rows.Close()
+				return removed, err
+			}
+			if oldestKept != 0 && total+size > keep.MaxSize {
+				break
+			}
+			total += size
+			oldestKept = id
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return removed, err
+		}
+
+		where, args = h.where("id < ?", oldestKept)
+		res, err := h.db.Exec(h.bind(`DELETE FROM reviews `+where), args...)
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove reviews over the size limit: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		removed += int(n)
+	}
+	return removed, nil
+}
+
+// Compact compresses reviews saved before history was compressed and
+// rebuilds the database file so the space of removed reviews is returned to
+// the filesystem
+func (h *historyStore) Compact() error {
+	where, args := h.where("compressed = ? AND length(review) + length(findings) >= ?", false, compressMinSize)
+	rows, err := h.db.Query(h.bind(`SELECT id, review, findings FROM reviews `+where), args...)
+	if err != nil {
+		return fmt.Errorf("failed to read reviews: %w", err)
+	}
+	type pending struct {
+		id               int64
+		review, findings []byte
+	}
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.review, &p.findings); err != nil {
+			rows.Close()
+			return err
+		}
+		todo = append(todo, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range todo {
+		values, compressed, err := packText(p.review, p.findings)
+		if err != nil {
+			return fmt.Errorf("error compressing review %d: %w", p.id, err)
+		}
+		values = h.text(values)
+		if _, err := h.db.Exec(h.bind(`UPDATE reviews SET review = ?, findings = ?, compressed = ? WHERE id = ?`),
+			values[0], values[1], compressed, p.id); err != nil {
+			return fmt.Errorf("failed to compress review %d: %w", p.id, err)
+		}
+	}
+	return h.Vacuum()
+}
+
+// Vacuum rebuilds the database file so the space of removed reviews is
+// returned to the filesystem. Postgres reclaims it with autovacuum.
+func (h *historyStore) Vacuum() error {
+	if h.postgres {
+		return nil
+	}
+	if _, err := h.db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("failed to vacuum history: %w", err)
+	}
+	return nil
+}
+
+// Size returns the size of the SQLite database file, or in Postgres about
+// how much the store's reviews take up
+func (h *historyStore) Size() (ByteSize, error) {
+	if !h.postgres {
+		return fileSize(h.path), nil
+	}
+	var size ByteSize
+	where, args := h.where("")
+	err := h.db.QueryRow(h.bind(`SELECT COALESCE(SUM(length(review) + length(findings) + 256), 0) FROM reviews `+where), args...).Scan(&size)
+	return size, err
+}
+
+// applyRetention prunes a repository's history after a review. The file is
+// only rebuilt when reviews were removed, which keeps most runs fast.
+func applyRetention(h reviewHistory, keep Retention) {
+	if h == nil || !keep.enabled() {
+		return
+	}
+	removed, err := h.Prune(keep, time.Now())
+	if err == nil && removed > 0 {
+		err = h.Vacuum()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not apply history retention: %v\n", err)
+	}
+}
+
+// fileSize returns the size of the file at path, or zero if it is missing
+func fileSize(path string) ByteSize {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return ByteSize(info.Size())
+}
+
+// runClean implements `pr-review clean`: it applies a retention policy to
+// the review history and compacts it, and removes old cached reviews, for
+// long-lived installs
+func runClean(args []string) {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	addPathFlags(fs)
+	all := fs.Bool("all", false, "Clean the history of every repository, not just the current one")
+	var keep Retention
+	fs.Var(&keep.MaxAge, "max-age", "Remove reviews older than this, e.g. 90d, 2w or 72h (default: keep all)")
+	fs.Var(&keep.MaxSize, "max-size", "Remove the oldest reviews until each repository's history is about this size, e.g. 500MB (default: no limit)")
+	parseFlags(fs, fs.Name(), args)
+
+	// Without flags, the repository config's retention applies
+	if !*all && !keep.enabled() {
+		cfg, err := loadConfig(filepath.Join(getRepoRoot(), repoConfigFile))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		keep = cfg.Retention
+	}
+
+	storage, err := openStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening history: %v\n", err)
+		os.Exit(1)
+	}
+	var before, after ByteSize
+	removed := 0
+	err = storage.EachHistory(historyScope(*all), func(h reviewHistory) error {
+		size, err := h.Size()
+		if err != nil {
+			return err
+		}
+		before += size
+		n, err := cleanHistory(h, keep)
+		if err != nil {
+			return err
+		}
+		removed += n
+		size, err = h.Size()
+		after += size
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error cleaning history: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("🧹 Removed %s; history is %s (was %s)\n", plural(removed, "review"), after, before)
+
+	// Cached reviews go once they expire, or sooner with a shorter max age
+	maxAge := reviewCacheTTL
+	if keep.MaxAge > 0 {
+		maxAge = min(maxAge, time.Duration(keep.MaxAge))
+	}
+	if dir, err := cacheDir(); err == nil {
+		n, err := pruneReviewCache(filepath.Join(dir, "reviews"), maxAge, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error cleaning the review cache: %v\n", err)
+			os.Exit(1)
+		}
+		if n > 0 {
+			fmt.Printf("🧹 Removed %s from the review cache\n", plural(n, "cached review"))
+		}
+	}
+}
+
+// cleanHistory prunes and compacts a history store
+func cleanHistory(h reviewHistory, keep Retention) (int, error) {
+	removed, err := h.Prune(keep, time.Now())
+	if err != nil {
+		return removed, err
+	}
+	return removed, h.Compact()
+}
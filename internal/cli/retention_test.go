@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseByteSize tests parsing sizes with and without units
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]ByteSize{
+		"512":    512,
+		"2KB":    2 << 10,
+		"500mb":  500 << 20,
+		"1.5GiB": 3 << 29,
+		"10 M":   10 << 20,
+	}
+	for in, want := range tests {
+		if got, err := parseByteSize(in); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "MB", "-1GB", "lots"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q) expected error", in)
+		}
+	}
+}
+
+// TestParseAge tests parsing ages in days, weeks and Go durations
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"90d": 90 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"72h": 72 * time.Hour,
+	}
+	for in, want := range tests {
+		if got, err := parseAge(in); err != nil || time.Duration(got) != want {
+			t.Errorf("parseAge(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "d", "-3d", "soon"} {
+		if _, err := parseAge(in); err == nil {
+			t.Errorf("parseAge(%q) expected error", in)
+		}
+	}
+}
+
+// TestHistoryStore_Compressed tests that large reviews are stored compressed
+// and read back unchanged
+func TestHistoryStore_Compressed(t *testing.T) {
+	h, err := openHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("openHistory() returned error: %v", err)
+	}
+	defer h.Close()
+
+	long := strings.Repeat("The error from Close is ignored.\n", 200)
+	for _, text := range []string{"short", long} {
+		r := &reviewRecord{Repo: "app", Model: "m", Review: text, Findings: []Finding{{File: "a.go", Title: "Bug"}}}
+		if err := h.Record(r); err != nil {
+			t.Fatalf("Record() returned error: %v", err)
+		}
+	}
+
+	var compressed []bool
+	var sizes []int
+	rows, err := h.db.Query(`SELECT compressed, length(review) FROM reviews ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var c bool
+		var n int
+		rows.Scan(&c, &n)
+		compressed, sizes = append(compressed, c), append(sizes, n)
+	}
+	rows.Close()
+	if len(compressed) != 2 || compressed[0] || !compressed[1] || sizes[1] >= len(long)/10 {
+		t.Errorf("compressed = %v, sizes = %v", compressed, sizes)
+	}
+
+	records, err := h.List()
+	if err != nil {
+		t.Fatalf("List() returned error: %v", err)
+	}
+	if records[0].Review != "short" || records[1].Review != long || len(records[1].Findings) != 1 {
+		t.Errorf("List() = %+v", records)
+	}
+}
+
+// TestHistoryStore_PruneAndCompact tests removing reviews by age and size and
+// compressing reviews saved before compression
+func TestHistoryStore_PruneAndCompact(t *testing.T) {
+	h, err := openHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("openHistory() returned error: %v", err)
+	}
+	defer h.Close()
+
+	now := time.Now()
+	text := strings.Repeat("x", 4000)
+	for _, days := range []int{100, 40, 3, 2, 1} {
+		r := &reviewRecord{Repo: "app", Model: "m", Review: text, CreatedAt: now.AddDate(0, 0, -days)}
+		if err := h.Record(r); err != nil {
+			t.Fatalf("Record() returned error: %v", err)
+		}
+	}
+	// A review from before compression, stored as plain text
+	if _, err := h.db.Exec(`UPDATE reviews SET review = ?, findings = 'null', compressed = 0 WHERE id = 5`, text); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := h.Prune(Retention{MaxAge: Age(90 * 24 * time.Hour)}, now)
+	if err != nil || removed != 1 {
+		t.Fatalf("Prune(max_age) = %d, %v; want 1 removed", removed, err)
+	}
+	if err := h.Compact(); err != nil {
+		t.Fatalf("Compact() returned error: %v", err)
+	}
+	var plain int
+	h.db.QueryRow(`SELECT COUNT(*) FROM reviews WHERE compressed = 0`).Scan(&plain)
+	if plain != 0 {
+		t.Errorf("%d reviews left uncompressed by Compact()", plain)
+	}
+
+	// Compressed, each review takes a few hundred bytes of the budget
+	removed, err = h.Prune(Retention{MaxSize: 700}, now)
+	if err != nil || removed != 2 {
+		t.Fatalf("Prune(max_size) = %d, %v; want 2 removed", removed, err)
+	}
+	records, _ := h.List()
+	if len(records) != 2 || records[1].ID != 5 || records[1].Review != text {
+		t.Errorf("kept %+v, want the two newest", records)
+	}
+
+	// The newest review is kept even if it alone is over the limit
+	if removed, _ := h.Prune(Retention{MaxSize: 1}, now); removed != 1 {
+		t.Errorf("Prune(max_size: 1) removed %d, want 1", removed)
+	}
+}
+
+// TestTranscript_Gzip tests that a transcript path ending in .gz is written
+// compressed
+func TestTranscript_Gzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcript.json.gz")
+	tr := newTranscript(path, nil)
+	if err := tr.save(); err != nil {
+		t.Fatalf("save() returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := gunzip(data)
+	if err != nil {
+		t.Fatalf("transcript is not gzipped: %v", err)
+	}
+	var got struct{ Tool string }
+	if err := json.Unmarshal(plain, &got); err != nil || got.Tool != "pr-review" {
+		t.Errorf("transcript = %s", plain)
+	}
+}
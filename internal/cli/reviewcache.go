@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// promptVersion identifies the review prompt's wording. Bump it when the
+// rubric or instructions change, so cached reviews of the old prompt aren't
+// returned for the new one.
+const promptVersion = "1"
+
+// reviewCacheTTL is how long a cached review is returned. Reviews older
+// than this are removed, so the cache doesn't grow without bound.
+const reviewCacheTTL = 30 * 24 * time.Hour
+
+// cachedReview is a model's response to a review prompt, kept so the same
+// prompt isn't paid for twice
+type cachedReview struct {
+	Model     string    `json:"model"`
+	Response  string    `json:"response"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// reviewCache keeps responses by reviewCacheKey, one file each in the cache
+// directory, or in the shared cache with shared storage
+type reviewCache struct {
+	dir    string
+	shared cacheStore
+}
+
+// reviewCacheKey identifies a review by the model, the prompt version and
+// the hashes of the diff, of its context (the changed files, commit messages
+// and context files) and of the instructions it was reviewed with
+func reviewCacheKey(model, diff, context, instructions string) string {
+	h := sha256.New()
+	for _, s := range []string{model, promptVersion, diff, context, instructions} {
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// reviewInstructions is what a review is asked to do, as opposed to what it
+// reviews: the rubric, the prompt's sections, the response format and, for a
+// diff reviewed in parts, the files of each part
+func reviewInstructions(sections []promptSection, cfg *Config, format string, parts []splitPart) string {
+	var b strings.Builder
+	b.WriteString(cfg.rubric + "\x00" + calibrationPrompt(cfg.SeverityCalibration) + "\x00" + format)
+	for _, section := range sections {
+		b.WriteString("\x00" + section.Title + "\n" + section.Body)
+	}
+	for _, part := range parts {
+		b.WriteString("\x00" + strings.Join(part.Files, "\n"))
+	}
+	return b.String()
+}
+
+// openReviewCache opens the review cache in the configured storage. With
+// no storage at all, lookups miss and nothing is stored.
+func openReviewCache() *reviewCache {
+	cache := &reviewCache{}
+	if storage, err := openStorage(); err == nil {
+		if cache.shared, err = storage.OpenCache(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Shared review cache unavailable: %v\n", err)
+		}
+		if cache.shared != nil {
+			return cache
+		}
+	}
+	if dir, err := cacheDir(); err == nil {
+		cache.dir = filepath.Join(dir, "reviews")
+	}
+	return cache
+}
+
+// lookup returns the cached review under key, or nil if there is none
+func (rc *reviewCache) lookup(key string) *cachedReview {
+	var data []byte
+	var err error
+	switch {
+	case rc.shared != nil:
+		data, err = rc.shared.Get("reviews:" + key)
+	case rc.dir != "":
+		data, err = os.ReadFile(filepath.Join(rc.dir, key+".json"))
+	default:
+		return nil
+	}
+	var r cachedReview
+	if err != nil || data == nil || json.Unmarshal(data, &r) != nil {
+		return nil
+	}
+	if time.Since(r.CreatedAt) > reviewCacheTTL {
+		return nil
+	}
+	return &r
+}
+
+// store caches a review under key
+func (rc *reviewCache) store(key string, r *cachedReview) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	switch {
+	case rc.shared != nil:
+		return rc.shared.Put("reviews:"+key, data, reviewCacheTTL)
+	case rc.dir != "":
+		if err := os.MkdirAll(rc.dir, 0700); err != nil {
+			return fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(rc.dir, key+".json"), data, 0600); err != nil {
+			return err
+		}
+		_, err := pruneReviewCache(rc.dir, reviewCacheTTL, time.Now())
+		return err
+	}
+	return nil
+}
+
+// pruneReviewCache removes the reviews cached in dir more than maxAge ago,
+// returning how many it removed
+func pruneReviewCache(dir string, maxAge time.Duration, now time.Time) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read the review cache: %w", err)
+	}
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.Type().IsRegular() || filepath.Ext(entry.Name()) != ".json" || now.Sub(info.ModTime()) <= maxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, fmt.Errorf("failed to remove a cached review: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// close releases the shared cache
+func (rc *reviewCache) close() {
+	if rc.shared != nil {
+		rc.shared.Close()
+	}
+}
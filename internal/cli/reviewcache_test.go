@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReviewCacheKey tests that a review is cached per model, diff, context
+// and instructions
+func TestReviewCacheKey(t *testing.T) {
+	key := reviewCacheKey("claude-opus-4-1", "+x := 1", "main.go", "Review this")
+	if key != reviewCacheKey("claude-opus-4-1", "+x := 1", "main.go", "Review this") {
+		t.Error("reviewCacheKey() differs for the same review")
+	}
+	for name, other := range map[string]string{
+		"model":        reviewCacheKey("claude-sonnet-4-5", "+x := 1", "main.go", "Review this"),
+		"diff":         reviewCacheKey("claude-opus-4-1", "+x := 2", "main.go", "Review this"),
+		"context":      reviewCacheKey("claude-opus-4-1", "+x := 1", "util.go", "Review this"),
+		"instructions": reviewCacheKey("claude-opus-4-1", "+x := 1", "main.go", "Review that"),
+		"boundaries":   reviewCacheKey("claude-opus-4-1", "+x := 1main.go", "", "Review this"),
+	} {
+		if key == other {
+			t.Errorf("reviewCacheKey() ignores the %s", name)
+		}
+	}
+
+	// The instructions cover the format and how the diff was split
+	cfg := &Config{}
+	sections := []promptSection{{Title: "Focus", Body: "Security"}}
+	markdown := reviewInstructions(sections, cfg, formatMarkdown, nil)
+	if markdown == reviewInstructions(sections, cfg, formatJSON, nil) {
+		t.Error("reviewInstructions() ignores the format")
+	}
+	if markdown == reviewInstructions(nil, cfg, formatMarkdown, nil) {
+		t.Error("reviewInstructions() ignores the sections")
+	}
+	if markdown == reviewInstructions(sections, cfg, formatMarkdown, []splitPart{{Files: []string{"a.go"}}, {Files: []string{"b.go"}}}) {
+		t.Error("reviewInstructions() ignores the parts")
+	}
+}
+
+// TestReviewCache tests caching reviews in the cache directory and in a
+// shared cache
+func TestReviewCache(t *testing.T) {
+	shared := memoryCache{}
+	for _, cache := range []*reviewCache{{dir: t.TempDir()}, {shared: shared}} {
+		if r := cache.lookup("abc"); r != nil {
+			t.Errorf("lookup() found %+v in an empty cache", r)
+		}
+		if err := cache.store("abc", &cachedReview{Model: "claude-opus-4-1", Response: "Looks good.", CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("store() returned error: %v", err)
+		}
+		if r := cache.lookup("abc"); r == nil || r.Response != "Looks good." || r.Model != "claude-opus-4-1" {
+			t.Errorf("lookup() = %+v", r)
+		}
+	}
+	if shared["reviews:abc"] == nil {
+		t.Error("review wasn't kept in the shared cache")
+	}
+
+	// An expired review isn't returned
+	old := &reviewCache{shared: shared}
+	old.store("old", &cachedReview{Response: "Stale.", CreatedAt: time.Now().Add(-reviewCacheTTL - time.Hour)})
+	if r := old.lookup("old"); r != nil {
+		t.Errorf("lookup() returned an expired review: %+v", r)
+	}
+
+	// Without a cache directory nothing is cached
+	none := &reviewCache{}
+	if err := none.store("abc", &cachedReview{}); err != nil || none.lookup("abc") != nil {
+		t.Errorf("a cache without storage stored the review: %v", err)
+	}
+}
+
+// TestPruneReviewCache tests removing old reviews from the cache directory
+func TestPruneReviewCache(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for name, age := range map[string]time.Duration{"old.json": 40 * 24 * time.Hour, "new.json": time.Hour, "notes.txt": 40 * 24 * time.Hour} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := pruneReviewCache(dir, reviewCacheTTL, now)
+	if err != nil || removed != 1 {
+		t.Errorf("pruneReviewCache() = %d, %v; want the old review removed", removed, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if strings.Join(left, ",") != "new.json,notes.txt" {
+		t.Errorf("left %v", left)
+	}
+
+	if removed, err := pruneReviewCache(filepath.Join(dir, "missing"), reviewCacheTTL, now); removed != 0 || err != nil {
+		t.Errorf("pruneReviewCache() of a missing directory = %d, %v", removed, err)
+	}
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// deployKind is a kind of file whose changes carry deployment risk
+type deployKind struct {
+	name     string
+	patterns []string
+}
+
+// deployKinds recognizes schema migrations, deployment configuration and
+// feature flag definitions by path (matched in lower case)
+var deployKinds = []deployKind{
+	{"migration", []string{"migrations/", "migrate/", "alembic/", "flyway/", "liquibase/", "*.sql", "db/schema.rb", "schema.prisma"}},
+	{"config", []string{"config/", "configs/", "deploy/", "k8s/", "kubernetes/", "helm/", "charts/", "terraform/", "*.tf", "dockerfile", "docker-compose*.y*ml", ".env*", "*.env", "*.properties", "application*.y*ml"}},
+	{"feature flag", []string{"*feature_flag*", "*feature-flag*", "*featureflag*", "*feature_toggle*", "*toggles*", "features.y*ml", "features.json", "flags.y*ml", "flags.json"}},
+}
+
+// deployFile is a changed file that affects how the change must be rolled out
+type deployFile struct {
+	Kind string
+	Path string
+}
+
+// deploymentFiles returns the changed files that are migrations, deployment
+// config or feature flag definitions. A file counts as the first kind it
+// matches.
+func deploymentFiles(files []string) []deployFile {
+	var found []deployFile
+	for _, f := range files {
+		lower := strings.ToLower(f)
+		for _, k := range deployKinds {
+			if matchAnyGlob(k.patterns, lower) {
+				found = append(found, deployFile{Kind: k.name, Path: f})
+				break
+			}
+		}
+	}
+	return found
+}
+
+// rolloutInstructions asks for a deployment risk assessment of a change that
+// touches the given files
+const rolloutInstructions = `This change touches files that affect how it must be deployed (listed below).
+In addition to the code review, include a "Rollout and Revert Plan" section
+that assesses:
+
+- **Feature flags**: Is new behavior behind a flag? What is the flag's default,
+  and is the old path still intact while the flag is off?
+- **Ordering**: Must schema migrations or config changes be applied before or
+  after the code is deployed? Can old and new code run side by side against
+  the new schema and config during the rollout?
+- **Revertability**: Can the change be reverted by redeploying the previous
+  version? Call out anything irreversible (dropped columns or tables, data
+  rewrites, renamed keys) and whether a down migration exists and is safe.
+- **Plan**: Suggest concrete rollout steps (e.g. migrate, deploy, enable the
+  flag gradually) and rollback steps, with what to monitor at each step.
+
+`
+
+// rolloutSection lists the deployment-relevant files for the prompt
+func rolloutSection(files []deployFile) string {
+	var b strings.Builder
+	b.WriteString(rolloutInstructions)
+	for _, f := range files {
+		fmt.Fprintf(&b, "- %s (%s)\n", f.Path, f.Kind)
+	}
+	return b.String()
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDeploymentFiles tests detecting migrations, config and flag files
+func TestDeploymentFiles(t *testing.T) {
+	files := []string{
+		"db/migrations/0042_add_index.sql",
+		"internal/store/schema.sql",
+		"deploy/prod/values.yaml",
+		"Dockerfile",
+		"infra/main.tf",
+		"config/feature_flags.yaml",
+		"src/FeatureFlags.java",
+		"internal/server/handler.go",
+		"README.md",
+	}
+	want := []deployFile{
+		{"migration", "db/migrations/0042_add_index.sql"},
+		{"migration", "internal/store/schema.sql"},
+		{"config", "deploy/prod/values.yaml"},
+		{"config", "Dockerfile"},
+		{"config", "infra/main.tf"},
+		{"config", "config/feature_flags.yaml"},
+		{"feature flag", "src/FeatureFlags.java"},
+	}
+
+	got := deploymentFiles(files)
+	if len(got) != len(want) {
+		t.Fatalf("deploymentFiles() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("file %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestRolloutSection tests the prompt section for deployment-relevant files
+func TestRolloutSection(t *testing.T) {
+	got := rolloutSection([]deployFile{{"migration", "db/migrations/0042.sql"}})
+	for _, want := range []string{"Rollout and Revert Plan", "Revertability", "- db/migrations/0042.sql (migration)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rolloutSection() missing %q", want)
+		}
+	}
+}
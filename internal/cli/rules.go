@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SeverityRule raises the severity of findings that meet all of its
+// conditions, e.g. security findings under auth/** are always critical.
+// Rules only escalate: a finding already more severe keeps its severity.
+type SeverityRule struct {
+	// Category matches the finding's category exactly (case-insensitive)
+	Category string `yaml:"category"`
+
+	// Path is a glob pattern (as in critical_paths) for the finding's file
+	Path string `yaml:"path"`
+
+	// Contains matches text in the finding's title or message
+	// (case-insensitive)
+	Contains string `yaml:"contains"`
+
+	// MinSeverity limits the rule to findings at least this severe
+	MinSeverity *Severity `yaml:"min_severity"`
+
+	Severity Severity `yaml:"severity"`
+}
+
+// validate checks that the rule has a condition, so it can't escalate every
+// finding by accident
+func (r SeverityRule) validate() error {
+	if strings.TrimSpace(r.Category) == "" && strings.TrimSpace(r.Path) == "" && strings.TrimSpace(r.Contains) == "" && r.MinSeverity == nil {
+		return fmt.Errorf("rule has no condition (want category, path, contains or min_severity)")
+	}
+	return nil
+}
+
+func (r SeverityRule) matches(f Finding) bool {
+	if category := strings.TrimSpace(r.Category); category != "" && !strings.EqualFold(category, strings.TrimSpace(f.Category)) {
+		return false
+	}
+	if r.Path != "" && (f.File == "" || !matchGlob(r.Path, f.File)) {
+		return false
+	}
+	if text := strings.ToLower(strings.TrimSpace(r.Contains)); text != "" &&
+		!strings.Contains(strings.ToLower(f.Title), text) && !strings.Contains(strings.ToLower(f.Message), text) {
+		return false
+	}
+	return r.MinSeverity == nil || f.Severity >= *r.MinSeverity
+}
+
+// String describes the rule's conditions for the report
+func (r SeverityRule) String() string {
+	var conds []string
+	if r.Category != "" {
+		conds = append(conds, "category="+r.Category)
+	}
+	if r.Path != "" {
+		conds = append(conds, "path matches "+r.Path)
+	}
+	if r.Contains != "" {
+		conds = append(conds, fmt.Sprintf("mentions %q", r.Contains))
+	}
+	if r.MinSeverity != nil {
+		conds = append(conds, "severity>="+r.MinSeverity.String())
+	}
+	return strings.Join(conds, " AND ") + " -> " + r.Severity.String()
+}
+
+// applySeverityRules escalates findings to the most severe of the rules
+// they match. It runs after calibration and critical-path escalation, so
+// the rules have the last word on the severities any gate sees.
+func applySeverityRules(findings []Finding, rules []SeverityRule) {
+	for i := range findings {
+		for _, rule := range rules {
+			if rule.matches(findings[i]) && rule.Severity > findings[i].Severity {
+				findings[i].Severity = rule.Severity
+				findings[i].EscalatedBy = rule.String()
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestApplySeverityRules tests escalating findings that meet every
+// condition of a rule, and never lowering a severity
+func TestApplySeverityRules(t *testing.T) {
+	medium := SeverityMedium
+	rules := []SeverityRule{
+		{Category: "security", Path: "auth/**", Severity: SeverityCritical},
+		{Contains: "nil pointer", Severity: SeverityHigh},
+		{Path: "db/migrations/**", MinSeverity: &medium, Severity: SeverityHigh},
+	}
+	findings := []Finding{
+		{File: "auth/session/token.go", Category: "Security", Severity: SeverityMedium, Title: "Token not rotated"},
+		{File: "api/handler.go", Category: "security", Severity: SeverityLow, Title: "Weak check"},
+		{File: "api/handler.go", Severity: SeverityLow, Title: "Possible crash", Message: "A NIL POINTER dereference if the user is missing."},
+		{File: "db/migrations/001.sql", Severity: SeverityLow, Title: "Style"},
+		{File: "db/migrations/002.sql", Severity: SeverityMedium, Title: "Lock held"},
+		{File: "auth/login.go", Category: "security", Severity: SeverityCritical, Title: "Already critical", Message: "nil pointer"},
+	}
+	applySeverityRules(findings, rules)
+
+	want := []Severity{SeverityCritical, SeverityLow, SeverityHigh, SeverityLow, SeverityHigh, SeverityCritical}
+	for i, f := range findings {
+		if f.Severity != want[i] {
+			t.Errorf("finding %d (%s) severity = %s, want %s", i, f.Title, f.Severity, want[i])
+		}
+	}
+	if findings[0].EscalatedBy != "category=security AND path matches auth/** -> critical" {
+		t.Errorf("EscalatedBy = %q", findings[0].EscalatedBy)
+	}
+	if findings[1].EscalatedBy != "" || findings[5].EscalatedBy != "" {
+		t.Error("EscalatedBy set on a finding no rule raised")
+	}
+}
+
+// TestLoadConfig_SeverityRules tests parsing rules and rejecting one
+// without conditions
+func TestLoadConfig_SeverityRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), repoConfigFile)
+	os.WriteFile(path, []byte("severity_rules:\n  - contains: nil pointer\n    min_severity: low\n    severity: high\n"), 0644)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() returned error: %v", err)
+	}
+	if len(cfg.SeverityRules) != 1 || cfg.SeverityRules[0].Severity != SeverityHigh || *cfg.SeverityRules[0].MinSeverity != SeverityLow {
+		t.Errorf("SeverityRules = %+v", cfg.SeverityRules)
+	}
+
+	os.WriteFile(path, []byte("severity_rules:\n  - severity: critical\n"), 0644)
+	if _, err := loadConfig(path); err == nil || !strings.Contains(err.Error(), "no condition") {
+		t.Errorf("loadConfig() of a rule without conditions = %v, want error", err)
+	}
+}
@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// SARIF 2.1.0 log, limited to the parts code scanning uses
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	ShortDescription sarifMessage    `json:"shortDescription"`
+	Properties       *sarifRuleProps `json:"properties,omitempty"`
+}
+
+// sarifRuleProps carries the score GitHub uses to rank security alerts
+type sarifRuleProps struct {
+	SecuritySeverity string   `json:"security-severity,omitempty"`
+	Tags             []string `json:"tags,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+
+	// PartialFingerprints let code scanning track a finding across runs;
+	// Suppressions mark the findings in the repository's baseline
+	PartialFingerprints map[string]string  `json:"partialFingerprints,omitempty"`
+	Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+}
+
+// sarifSuppression records why a result is suppressed
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifLevel maps a severity to a SARIF result level
+func sarifLevel(s Severity) string {
+	switch {
+	case s >= SeverityHigh:
+		return "error"
+	case s == SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity maps a severity to the CVSS-like score GitHub shows as
+// critical (9.0+), high (7.0+), medium (4.0+) or low for security rules
+var securitySeverity = map[Severity]string{
+	SeverityCritical: "9.5",
+	SeverityHigh:     "8.0",
+	SeverityMedium:   "5.5",
+	SeverityLow:      "3.0",
+	SeverityInfo:     "1.0",
+}
+
+var nonRuleChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// sarifRuleID derives a stable rule ID from a finding's category, since the
+// model's findings have no rules of their own
+func sarifRuleID(category string) string {
+	slug := strings.Trim(nonRuleChars.ReplaceAllString(strings.ToLower(category), "-"), "-")
+	if slug == "" {
+		slug = "general"
+	}
+	return "pr-review/" + slug
+}
+
+// buildSARIF converts a review's findings to a SARIF log with one rule per
+// category. Findings without a file are left out, since code scanning needs
+// a location; it returns how many were.
+func buildSARIF(doc *reviewDocument) (*sarifLog, int) {
+	type ruleInfo struct {
+		rule     sarifRule
+		severity Severity
+	}
+	rules := make(map[string]*ruleInfo)
+	var results []sarifResult
+	skipped := 0
+	for _, f := range doc.Findings {
+		if f.File == "" {
+			skipped++
+			continue
+		}
+		id := sarifRuleID(f.Category)
+		info, ok := rules[id]
+		if !ok {
+			name := f.Category
+			if name == "" {
+				name = "general"
+			}
+			info = &ruleInfo{rule: sarifRule{ID: id, Name: name, ShortDescription: sarifMessage{Text: "Review finding: " + name}}}
+			rules[id] = info
+		}
+		// A security rule is scored by its most severe finding
+		info.severity = max(info.severity, f.Severity)
+
+		text := f.Title
+		if f.Message != "" {
+			text += "\n\n" + f.Message
+		}
+		if f.Suggestion != "" {
+			text += "\n\nSuggestion: " + f.Suggestion
+		}
+		if f.CriticalPath {
+			text += "\n\n(Escalated: critical path)"
+		}
+		result := sarifResult{
+			RuleID:  id,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifMessage{Text: text},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: f.File, URIBaseID: "%SRCROOT%"},
+				Region:           sarifRegion{StartLine: max(f.Line, 1)},
+			}}},
+			PartialFingerprints: map[string]string{"prReviewFinding/v1": f.Fingerprint()},
+		}
+		if f.Baselined {
+			result.Suppressions = []sarifSuppression{{Kind: "external", Justification: "In " + baselineFile}}
+		}
+		results = append(results, result)
+	}
+
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	index := make(map[string]int, len(ids))
+	driver := sarifDriver{Name: "pr-review", InformationURI: "https://github.com/marete/pr-review", Rules: []sarifRule{}}
+	for i, id := range ids {
+		info := rules[id]
+		if strings.Contains(id, "security") {
+			info.rule.Properties = &sarifRuleProps{SecuritySeverity: securitySeverity[info.severity], Tags: []string{"security"}}
+		}
+		driver.Rules = append(driver.Rules, info.rule)
+		index[id] = i
+	}
+	for i := range results {
+		results[i].RuleIndex = index[results[i].RuleID]
+	}
+	if results == nil {
+		results = []sarifResult{}
+	}
+
+	return &sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}, skipped
+}
+
+// writeSARIF writes a review's findings as SARIF for code scanning upload
+func writeSARIF(out io.Writer, doc *reviewDocument) error {
+	log, skipped := buildSARIF(doc)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s without a file left out of the SARIF output\n", plural(skipped, "finding"))
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(log)
+}
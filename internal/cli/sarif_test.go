@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// TestBuildSARIF tests converting findings to SARIF rules and results
+func TestBuildSARIF(t *testing.T) {
+	doc := &reviewDocument{Findings: []Finding{
+		{File: "auth/login.go", Line: 42, Severity: SeverityCritical, Category: "Security", Title: "SQL injection", Suggestion: "Use a prepared statement"},
+		{File: "auth/token.go", Severity: SeverityMedium, Category: "security", Title: "Weak token"},
+		{File: "cache.go", Line: 7, Severity: SeverityLow, Category: "Error Handling", Title: "Ignored error"},
+		{Severity: SeverityHigh, Category: "design", Title: "No location"},
+	}}
+
+	log, skipped := buildSARIF(doc)
+	if skipped != 1 {
+		t.Errorf("skipped = %d, want 1", skipped)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 {
+		t.Fatalf("rules = %+v, want error-handling and security", run.Tool.Driver.Rules)
+	}
+	rule := run.Tool.Driver.Rules[1]
+	if rule.ID != "pr-review/security" || rule.Properties == nil || rule.Properties.SecuritySeverity != "9.5" {
+		t.Errorf("security rule = %+v", rule)
+	}
+	if run.Tool.Driver.Rules[0].ID != "pr-review/error-handling" || run.Tool.Driver.Rules[0].Properties != nil {
+		t.Errorf("error handling rule = %+v", run.Tool.Driver.Rules[0])
+	}
+
+	if len(run.Results) != 3 {
+		t.Fatalf("got %d results, want 3", len(run.Results))
+	}
+	want := []struct {
+		level string
+		line  int
+		index int
+	}{
+		{"error", 42, 1},
+		{"warning", 1, 1},
+		{"note", 7, 0},
+	}
+	for i, w := range want {
+		r := run.Results[i]
+		loc := r.Locations[0].PhysicalLocation
+		if r.Level != w.level || loc.Region.StartLine != w.line || r.RuleIndex != w.index {
+			t.Errorf("result %d = %s line %d rule %d, want %s line %d rule %d",
+				i, r.Level, loc.Region.StartLine, r.RuleIndex, w.level, w.line, w.index)
+		}
+	}
+	if run.Results[0].Message.Text != "SQL injection\n\nSuggestion: Use a prepared statement" {
+		t.Errorf("message = %q", run.Results[0].Message.Text)
+	}
+}
+
+// TestWriteSARIF_Empty tests that a clean review is valid SARIF with no
+// results
+func TestWriteSARIF_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeSARIF(&buf, &reviewDocument{}); err != nil {
+		t.Fatalf("writeSARIF() returned error: %v", err)
+	}
+	var log map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if log["version"] != "2.1.0" {
+		t.Errorf("version = %v", log["version"])
+	}
+	run := log["runs"].([]any)[0].(map[string]any)
+	if results, ok := run["results"].([]any); !ok || len(results) != 0 {
+		t.Errorf("results = %v, want an empty array", run["results"])
+	}
+}
+
+// TestBuildSARIF_Baselined tests marking known findings as suppressed
+func TestBuildSARIF_Baselined(t *testing.T) {
+	doc := &reviewDocument{Findings: []Finding{
+		{File: "a.go", Line: 1, Severity: SeverityHigh, Category: "bug", Title: "New"},
+		{File: "b.go", Line: 2, Severity: SeverityHigh, Category: "bug", Title: "Known", Baselined: true},
+	}}
+	log, _ := buildSARIF(doc)
+	results := log.Runs[0].Results
+	if len(results[0].Suppressions) != 0 || len(results[1].Suppressions) != 1 || results[1].Suppressions[0].Kind != "external" {
+		t.Errorf("suppressions = %+v, %+v", results[0].Suppressions, results[1].Suppressions)
+	}
+	if results[0].PartialFingerprints["prReviewFinding/v1"] != doc.Findings[0].Fingerprint() {
+		t.Errorf("partialFingerprints = %v", results[0].PartialFingerprints)
+	}
+}
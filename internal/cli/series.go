@@ -0,0 +1,400 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+// patch is one message of a git format-patch series
+type patch struct {
+	Subject string // without the [PATCH n/m] prefix
+	Prefix  string // e.g. "PATCH v2 3/7", empty if there was none
+	Author  string
+	Message string // commit message body, below the subject
+	Diff    string
+}
+
+// Label names a patch in progress output and the report
+func (p *patch) Label() string {
+	if p.Prefix == "" {
+		return p.Subject
+	}
+	return "[" + p.Prefix + "] " + p.Subject
+}
+
+// IsCoverLetter reports whether the patch is a series' 0/n cover letter
+func (p *patch) IsCoverLetter() bool {
+	return p.Diff == "" && coverLetterNumber.MatchString(p.Prefix)
+}
+
+// mboxSeparator matches the From_ line that starts each message of an mbox,
+// e.g. "From 1a2b... Mon Sep 17 00:00:00 2001" as format-patch writes it
+var mboxSeparator = regexp.MustCompile(`^From \S+ +\S`)
+
+// coverLetterNumber matches the 0/n numbering of a cover letter's prefix
+var coverLetterNumber = regexp.MustCompile(`(^|\s)0+/\d+$`)
+
+// patchPrefix matches a subject's [PATCH ...] or [RFC PATCH ...] tag
+var patchPrefix = regexp.MustCompile(`^\[([^\]]*PATCH[^\]]*)\]\s*`)
+
+// readPatchSeries reads the patches of a series from a directory of
+// format-patch output (*.patch, in name order) or an mbox file
+func readPatchSeries(path string) ([]*patch, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var messages []string
+	if info.IsDir() {
+		files, err := filepath.Glob(filepath.Join(path, "*.patch"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, splitMbox(string(data))...)
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		messages = splitMbox(string(data))
+	}
+
+	var series []*patch
+	for _, m := range messages {
+		series = append(series, parsePatch(m))
+	}
+	if len(series) == 0 {
+		return nil, fmt.Errorf("no patches found in %s", path)
+	}
+	return series, nil
+}
+
+// splitMbox splits an mbox into its messages. A From_ line only starts a
+// message when a header follows it, so commit messages quoting one don't
+// split a patch.
+func splitMbox(data string) []string {
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	var messages []string
+	start := -1
+	for i, line := range lines {
+		if !mboxSeparator.MatchString(line) || i+1 >= len(lines) || !isHeaderLine(lines[i+1]) {
+			continue
+		}
+		if start >= 0 {
+			messages = append(messages, strings.Join(lines[start:i], "\n"))
+		}
+		start = i + 1
+	}
+	if start >= 0 {
+		messages = append(messages, strings.Join(lines[start:], "\n"))
+	} else if strings.TrimSpace(data) != "" {
+		// A single message saved without its From_ line
+		messages = append(messages, strings.Join(lines, "\n"))
+	}
+	return messages
+}
+
+// isHeaderLine reports whether line looks like an email header
+func isHeaderLine(line string) bool {
+	name, _, ok := strings.Cut(line, ":")
+	return ok && name != "" && !strings.ContainsAny(name, " \t")
+}
+
+// parsePatch splits a format-patch message into its subject, commit message
+// and diff
+func parsePatch(message string) *patch {
+	p := &patch{}
+	headers, body, _ := strings.Cut(message, "\n\n")
+
+	// Long headers are folded onto indented continuation lines
+	var current string
+	for _, line := range append(strings.Split(headers, "\n"), "") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			current += " " + strings.TrimSpace(line)
+			continue
+		}
+		if name, value, ok := strings.Cut(current, ":"); ok {
+			switch strings.ToLower(name) {
+			case "subject":
+				p.Subject = strings.TrimSpace(value)
+			case "from":
+				p.Author = strings.TrimSpace(value)
+			}
+		}
+		current = line
+	}
+	if m := patchPrefix.FindStringSubmatch(p.Subject); m != nil {
+		p.Prefix = m[1]
+		p.Subject = p.Subject[len(m[0]):]
+	}
+
+	// The commit message ends at the "---" above the diffstat; the diff runs
+	// up to the "-- " signature format-patch appends
+	diffStart := -1
+	if strings.HasPrefix(body, "diff --git ") {
+		diffStart = 0
+	} else if i := strings.Index(body, "\ndiff --git "); i >= 0 {
+		diffStart = i + 1
+	}
+	if diffStart < 0 {
+		p.Message = strings.TrimSpace(body)
+		return p
+	}
+	text := body[:diffStart]
+	if i := strings.Index(text, "\n---\n"); i >= 0 {
+		text = text[:i]
+	} else if strings.HasPrefix(text, "---\n") {
+		text = ""
+	}
+	p.Message = strings.TrimSpace(text)
+
+	diff := body[diffStart:]
+	if i := strings.Index(diff, "\n-- \n"); i >= 0 {
+		diff = diff[:i+1]
+	}
+	p.Diff = diff
+	return p
+}
+
+// changes returns the patch in the form the review prompt takes
+func (p *patch) changes() *branchChanges {
+	diff, notes := sanitizeDiff(p.Diff)
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", note)
+	}
+
+	var files []string
+	for _, f := range gitdiff.Split(diff) {
+		status := "M"
+		switch {
+		case strings.Contains(f.Text, "\nnew file mode"):
+			status = "A"
+		case strings.Contains(f.Text, "\ndeleted file mode"):
+			status = "D"
+		case strings.Contains(f.Text, "\nrename from "):
+			status = "R"
+		}
+		files = append(files, status+"\t"+f.Path)
+	}
+
+	commit := p.Subject
+	if p.Author != "" {
+		commit += " (" + p.Author + ")"
+	}
+	if p.Message != "" {
+		commit += "\n\n" + p.Message
+	}
+	return &branchChanges{Diff: diff, ChangedFiles: strings.Join(files, "\n"), CommitMessages: commit}
+}
+
+// patchCount formats a number of patches
+func patchCount(n int) string {
+	if n == 1 {
+		return "1 patch"
+	}
+	return fmt.Sprintf("%d patches", n)
+}
+
+// buildSeriesPrompt asks for a cover-letter-style assessment of a patch
+// series, given each patch's independent review and the patches that
+// couldn't be reviewed
+func buildSeriesPrompt(cover *patch, reviews []*headReview, missing []string) string {
+	prompt := fmt.Sprintf(`You are an expert code reviewer on a mailing-list project. The %s
+below form a series sent with git format-patch, and each has already been
+reviewed on its own. Write the reply a maintainer would send to the cover
+letter:
+
+1. **Summary**: What the series does and whether the approach is sound.
+2. **Series structure**: Whether each patch does one logical thing, the order
+   makes sense, every patch should build and pass tests on its own (so the
+   series stays bisectable), and the commit messages explain why.
+3. **Per-patch verdict**: A table with each patch, a verdict (ready, needs
+   changes, or should be dropped) and the main reason.
+4. **Next version**: What must change before the series can be applied,
+   including issues that span patches (e.g. a bug introduced in one patch
+   and fixed in a later one).
+
+Don't repeat the individual reviews beyond what the assessment needs.
+
+---
+`, patchCount(len(reviews)))
+
+	if cover != nil {
+		prompt += "\n## Cover Letter: " + cover.Subject + "\n\n" + cover.Message + "\n"
+	}
+	if len(missing) > 0 {
+		prompt += "\n## Patches Not Reviewed\n\nThese patches couldn't be reviewed and are left out below; " +
+			"assess the series without them and say so:\n\n- " + strings.Join(missing, "\n- ") + "\n"
+	}
+	for _, r := range reviews {
+		prompt += "\n## " + r.Head + "\n\n"
+		if r.Changes.CommitMessages != "" {
+			prompt += "### Commit Message\n```\n" + r.Changes.CommitMessages + "\n```\n\n"
+		}
+		prompt += "### Changed Files\n```\n" + r.Changes.ChangedFiles + "\n```\n\n### Review\n\n" + r.Review + "\n"
+		if rendered := renderFindings(r.Findings, groupBySeverity); rendered != "" {
+			prompt += "\n" + strings.Replace(rendered, "## Findings", "### Findings", 1)
+		}
+	}
+	return prompt + "\n\nPlease provide your assessment of the series."
+}
+
+// formatSeriesReport puts the assessment of the series first, followed by
+// the patches that failed or were skipped, if any, and each patch's own
+// review
+func formatSeriesReport(title, assessment string, reviews []*headReview, units []unitResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Series Review: %s\n\n%s.\n\n", title, patchCount(len(units)))
+	if assessment != "" {
+		b.WriteString("## Assessment\n\n" + assessment + "\n")
+	}
+	if summary := renderUnits(units); summary != "" {
+		b.WriteString("\n" + summary)
+	}
+	for _, r := range reviews {
+		fmt.Fprintf(&b, "\n---\n\n# %s\n\n%s\n", r.Head, r.Review)
+		if rendered := renderFindings(r.Findings, groupBySeverity); rendered != "" {
+			b.WriteString("\n" + rendered)
+		}
+	}
+	return b.String()
+}
+
+// runSeries implements `pr-review series`, reviewing a git format-patch
+// series patch by patch and then as a whole
+func runSeries(args []string) {
+	fs := flag.NewFlagSet("series", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	outputFile := fs.String("output", "SERIES_REVIEW.md", "Output file for the series review (will create numbered backups if exists)")
+	failFast := addUnitFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pr-review series [flags] <patch-directory|mbox>")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, fs.Name(), args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Error: series requires a directory of patches or an mbox file")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	series, err := readPatchSeries(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading patches: %v\n", err)
+		os.Exit(1)
+	}
+	var cover *patch
+	var patches []*patch
+	for _, p := range series {
+		switch {
+		case p.IsCoverLetter():
+			cover = p
+		case p.Diff == "":
+			fmt.Fprintf(os.Stderr, "Warning: Skipping %q, which has no diff\n", p.Label())
+		default:
+			patches = append(patches, p)
+		}
+	}
+	if len(patches) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no patches with changes in %s\n", fs.Arg(0))
+		os.Exit(1)
+	}
+
+	client, policy := common.provider()
+
+	cfg, err := loadConfig(filepath.Join(getRepoRoot(), repoConfigFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	policy.enforce(cfg)
+
+	title := patches[0].Subject
+	additionalContext := common.readContext(client, policy)
+	if cover != nil {
+		title = cover.Subject
+		// Every patch is reviewed knowing what the series is for
+		additionalContext += "\n\n--- Cover letter of the patch series: " + cover.Subject + " ---\n" + cover.Message + "\n"
+	}
+	fmt.Printf("🔍 Reviewing a series of %s: %s\n\n", patchCount(len(patches)), title)
+
+	c := &comparer{
+		client:  client,
+		opts:    common.completionOptions(),
+		policy:  policy,
+		cfg:     cfg,
+		context: additionalContext,
+	}
+
+	// A patch whose review fails is listed in the report rather than
+	// losing the reviews of the others, unless -fail-fast
+	var reviews []*headReview
+	var units []unitResult
+	var missing []string
+	var failure error
+	var usage Usage
+	for _, p := range patches {
+		if failure != nil && *failFast {
+			units = append(units, unitResult{Name: p.Label(), Status: unitSkipped})
+			missing = append(missing, p.Label())
+			continue
+		}
+		fmt.Printf("🤖 Reviewing %s...\n", p.Label())
+		r, callUsage, err := c.review(p.Label(), p.changes())
+		usage.Add(callUsage)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Review of %s failed: %v\n", p.Label(), err)
+			units = append(units, unitResult{Name: p.Label(), Status: unitFailed, Error: err.Error()})
+			missing = append(missing, p.Label())
+			failure = err
+			continue
+		}
+		units = append(units, unitResult{Name: p.Label(), Status: unitSucceeded})
+		reviews = append(reviews, r)
+	}
+	if len(reviews) == 0 {
+		fail(exitProvider, "Error calling %s API: %v", client.Name(), failure)
+	}
+
+	// Stopping early leaves too little of the series to assess
+	var assessment string
+	if failure == nil || !*failFast {
+		fmt.Println("📨 Assessing the series...")
+		fmt.Println()
+		prompt := policy.redact(buildSeriesPrompt(cover, reviews, missing))
+		var callUsage Usage
+		assessment, callUsage, err = client.Complete(prompt, c.opts)
+		if err != nil {
+			fail(exitProvider, "Error calling %s API: %v", client.Name(), err)
+		}
+		usage.Add(callUsage)
+	}
+
+	report := formatSeriesReport(title, assessment, reviews, units)
+	if err := writeReviewToFile(*outputFile, report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing series review to file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Series review written to: %s\n\n", *outputFile)
+	common.printTranscript()
+
+	printReport("PATCH SERIES REVIEW", report, usage)
+	if _, failed, _ := countUnits(units); failed > 0 {
+		fmt.Fprintf(os.Stderr, "❌ %d of %s failed; the report covers the rest\n", failed, patchCount(len(units)))
+		exitWith(exitPartial)
+	}
+}
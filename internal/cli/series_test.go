@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// seriesMbox is a two-patch series with a cover letter, as git
+// format-patch --cover-letter --stdout writes it
+const seriesMbox = `From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001
+From: Ada Lovelace <ada@example.com>
+Date: Tue, 1 Oct 2024 10:00:00 +0000
+Subject: [PATCH v2 0/2] Add a
+ retry helper
+
+This series adds a retry helper and uses it.
+
+Ada Lovelace (2):
+  retry: add Do
+  client: retry requests
+
+-- 
+2.45.0
+
+From 1111111111111111111111111111111111111111 Mon Sep 17 00:00:00 2001
+From: Ada Lovelace <ada@example.com>
+Subject: [PATCH v2 1/2] retry: add Do
+
+Do calls a function until it succeeds.
+From the docs: retries are capped.
+
+Signed-off-by: Ada Lovelace <ada@example.com>
+---
+ retry.go | 3 +++
+ 1 file changed, 3 insertions(+)
+ create mode 100644 retry.go
+
+diff --git a/retry.go b/retry.go
+new file mode 100644
+--- /dev/null
++++ b/retry.go
+@@ -0,0 +1,3 @@
++package retry
++
++func Do(f func() error) error { return f() }
+-- 
+2.45.0
+
+From 2222222222222222222222222222222222222222 Mon Sep 17 00:00:00 2001
+From: Ada Lovelace <ada@example.com>
+Subject: [PATCH v2 2/2] client: retry requests
+
+---
+ client.go | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+diff --git a/client.go b/client.go
+--- a/client.go
++++ b/client.go
+@@ -1 +1 @@
+-func Get() error { return get() }
++func Get() error { return retry.Do(get) }
+-- 
+2.45.0
+`
+
+// TestReadPatchSeries_Mbox tests splitting an mbox into the cover letter
+// and patches, each with its subject, commit message and diff
+func TestReadPatchSeries_Mbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "series.mbox")
+	os.WriteFile(path, []byte(seriesMbox), 0644)
+
+	series, err := readPatchSeries(path)
+	if err != nil {
+		t.Fatalf("readPatchSeries() returned error: %v", err)
+	}
+	if len(series) != 3 {
+		t.Fatalf("got %d messages, want 3", len(series))
+	}
+
+	cover, first, second := series[0], series[1], series[2]
+	if !cover.IsCoverLetter() || cover.Subject != "Add a retry helper" {
+		t.Errorf("cover letter = %+v", cover)
+	}
+	if first.IsCoverLetter() || first.Label() != "[PATCH v2 1/2] retry: add Do" || first.Author != "Ada Lovelace <ada@example.com>" {
+		t.Errorf("first patch = %+v", first)
+	}
+	if !strings.HasPrefix(first.Message, "Do calls a function") || !strings.HasSuffix(first.Message, "Signed-off-by: Ada Lovelace <ada@example.com>") {
+		t.Errorf("commit message = %q", first.Message)
+	}
+	if !strings.HasPrefix(first.Diff, "diff --git a/retry.go") || strings.Contains(first.Diff, "2.45.0") {
+		t.Errorf("diff = %q", first.Diff)
+	}
+	if changes := first.changes(); changes.ChangedFiles != "A\tretry.go" {
+		t.Errorf("ChangedFiles = %q", changes.ChangedFiles)
+	}
+	if second.Message != "" || second.changes().ChangedFiles != "M\tclient.go" {
+		t.Errorf("second patch = %+v", second)
+	}
+}
+
+// TestReadPatchSeries_Directory tests reading format-patch files in order
+func TestReadPatchSeries_Directory(t *testing.T) {
+	dir := t.TempDir()
+	messages := strings.SplitAfter(seriesMbox, "-- \n2.45.0\n")
+	for i, m := range messages[:3] {
+		name := filepath.Join(dir, []string{"0000-cover-letter.patch", "0001-retry-add-Do.patch", "0002-client-retry-requests.patch"}[i])
+		os.WriteFile(name, []byte(strings.TrimPrefix(m, "\n")), 0644)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a patch"), 0644)
+
+	series, err := readPatchSeries(dir)
+	if err != nil {
+		t.Fatalf("readPatchSeries() returned error: %v", err)
+	}
+	if len(series) != 3 || series[2].Subject != "client: retry requests" {
+		t.Errorf("got %d patches: %+v", len(series), series)
+	}
+
+	if _, err := readPatchSeries(t.TempDir()); err == nil {
+		t.Error("readPatchSeries() of an empty directory expected error")
+	}
+}
+
+// TestBuildSeriesPrompt tests that the assessment sees the cover letter and
+// every patch's review
+func TestBuildSeriesPrompt(t *testing.T) {
+	cover := &patch{Subject: "Add a retry helper", Message: "This series adds a retry helper."}
+	reviews := []*headReview{
+		{Head: "[PATCH 1/2] retry: add Do", Changes: &branchChanges{ChangedFiles: "A\tretry.go"}, Review: "Looks fine."},
+		{Head: "[PATCH 2/2] client: retry requests", Changes: &branchChanges{ChangedFiles: "M\tclient.go"}, Review: "Retries non-idempotent calls.",
+			Findings: []Finding{{File: "client.go", Line: 1, Severity: SeverityHigh, Title: "POST is retried"}}},
+	}
+	prompt := buildSeriesPrompt(cover, reviews, nil)
+	for _, want := range []string{"The 2 patches", "## Cover Letter: Add a retry helper", "## [PATCH 2/2] client: retry requests", "Retries non-idempotent calls.", "POST is retried", "bisectable"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q", want)
+		}
+	}
+}
+
+// TestFormatSeriesReport_Partial tests that patches that failed or were
+// skipped are listed in the report and the assessment prompt
+func TestFormatSeriesReport_Partial(t *testing.T) {
+	reviews := []*headReview{{Head: "[PATCH 1/3] retry: add Do", Changes: &branchChanges{}, Review: "Looks fine."}}
+	units := []unitResult{
+		{Name: "[PATCH 1/3] retry: add Do", Status: unitSucceeded},
+		{Name: "[PATCH 2/3] client: retry requests", Status: unitFailed, Error: "API error (status 400)"},
+		{Name: "[PATCH 3/3] docs", Status: unitSkipped},
+	}
+	report := formatSeriesReport("Add a retry helper", "", reviews, units)
+	for _, want := range []string{"3 patches.", "## Run Summary", "1 succeeded, 1 failed, 1 skipped.", "- ❌ [PATCH 2/3] client: retry requests: failed: API error (status 400)", "- ⏭️ [PATCH 3/3] docs", "# [PATCH 1/3] retry: add Do"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report doesn't contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "## Assessment") {
+		t.Error("report has an assessment that wasn't made")
+	}
+	if renderUnits(units[:1]) != "" {
+		t.Error("renderUnits() lists a run where every unit succeeded")
+	}
+
+	prompt := buildSeriesPrompt(nil, reviews, []string{"[PATCH 2/3] client: retry requests"})
+	if !strings.Contains(prompt, "## Patches Not Reviewed") || !strings.Contains(prompt, "- [PATCH 2/3] client: retry requests") {
+		t.Errorf("prompt doesn't list the missing patch:\n%s", prompt)
+	}
+}
+
+// TestAddUnitFlags tests that the last of -fail-fast and -keep-going wins
+func TestAddUnitFlags(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"-fail-fast"}, true},
+		{[]string{"-fail-fast", "-keep-going"}, false},
+		{[]string{"-keep-going", "-fail-fast"}, true},
+		{[]string{"-keep-going=false"}, true},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		failFast := addUnitFlags(fs)
+		if err := fs.Parse(tt.args); err != nil || *failFast != tt.want {
+			t.Errorf("%v: fail fast = %v, %v, want %v", tt.args, *failFast, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+	"github.com/marete/pr-review/pkg/llm"
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// Defaults for reviewing a large diff in parts
+const (
+	defaultSplitOver    = 50000 // estimated tokens of diff
+	defaultSplitWorkers = 4
+
+	// chunkOverlap is how many lines of a hunk cut across parts the
+	// second part repeats, so the model sees the code around the cut
+	chunkOverlap = 5
+)
+
+// Ways of splitting a large diff into parts (-chunk-strategy)
+const (
+	chunkByFile = "file"
+	chunkByHunk = "hunk"
+	chunkNone   = "none"
+)
+
+var chunkStrategies = []string{chunkByFile, chunkByHunk, chunkNone}
+
+// validateChunkStrategy checks that strategy is a supported -chunk-strategy
+func validateChunkStrategy(strategy string) error {
+	for _, s := range chunkStrategies {
+		if s == strategy {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid chunk strategy %q (want one of: %s)", strategy, strings.Join(chunkStrategies, ", "))
+}
+
+// splitPart is a part of a large diff, reviewed on its own. Files names
+// its files, with the lines it shows of a file split by hunk.
+type splitPart struct {
+	Files []string
+	Diff  string
+}
+
+// name describes the part in progress messages and the run summary
+func (p splitPart) name() string {
+	if len(p.Files) <= 3 {
+		return strings.Join(p.Files, ", ")
+	}
+	return strings.Join(p.Files[:2], ", ") + " " + tr("and %d more", len(p.Files)-2)
+}
+
+// estimateTokens estimates the tokens of text the way tokenizers split
+// it: a token per 4 letters or digits of a word, per 2 symbols in a row
+// and per 4 spaces of indentation (a single space joins the next word),
+// and one per newline and non-ASCII character. Code dense with symbols has
+// more tokens than its bytes/4 would suggest.
+func estimateTokens(text string) int {
+	n, word, symbols, space := 0, 0, 0, 0
+	flush := func() {
+		n += (word+3)/4 + (symbols+1)/2 + space/4
+		word, symbols, space = 0, 0, 0
+	}
+	for _, r := range text {
+		switch {
+		case r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9':
+			if word == 0 {
+				flush()
+			}
+			word++
+		case r == ' ' || r == '\t':
+			if space == 0 {
+				flush()
+			}
+			space++
+		case r < 0x80 && r != '\n' && r != '\r':
+			if symbols == 0 {
+				flush()
+			}
+			symbols++
+		default:
+			flush()
+			n++
+		}
+	}
+	flush()
+	return n
+}
+
+// diffTokens estimates the tokens of a diff
+func diffTokens(diff string) int {
+	return estimateTokens(diff)
+}
+
+// splitDiff splits a diff into parts of at most maxTokens each with the
+// strategy: by file, or by file and the hunks of files larger than that
+func splitDiff(diff, strategy string, maxTokens int) []splitPart {
+	var chunks []chunk
+	for _, f := range gitdiff.Split(diff) {
+		if strategy == chunkByHunk && diffTokens(f.Text) > maxTokens {
+			chunks = append(chunks, hunkChunks(f, maxTokens)...)
+			continue
+		}
+		chunks = append(chunks, chunk{File: f, Name: f.Path})
+	}
+	return packChunks(chunks, maxTokens)
+}
+
+// splitByFile packs the files of a diff, in order, into parts of at most
+// maxTokens each. A file larger than that is a part of its own.
+func splitByFile(diff string, maxTokens int) []splitPart {
+	return splitDiff(diff, chunkByFile, maxTokens)
+}
+
+// chunk is what parts are packed from: a file's section of the diff, or
+// some of the hunks of a large file
+type chunk struct {
+	File gitdiff.File
+	Name string
+}
+
+// packChunks packs chunks, in order, into parts of at most maxTokens each.
+// A chunk larger than that is a part of its own.
+func packChunks(chunks []chunk, maxTokens int) []splitPart {
+	var parts []splitPart
+	var current []chunk
+	tokens := 0
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		var files []gitdiff.File
+		var part splitPart
+		for _, c := range current {
+			files = append(files, c.File)
+			part.Files = append(part.Files, c.Name)
+		}
+		part.Diff = gitdiff.Join(files)
+		parts = append(parts, part)
+		current, tokens = nil, 0
+	}
+	for _, c := range chunks {
+		n := diffTokens(c.File.Text)
+		if tokens > 0 && tokens+n > maxTokens {
+			flush()
+		}
+		current = append(current, c)
+		tokens += n
+	}
+	flush()
+	return parts
+}
+
+// hunkChunks splits the section of a file larger than maxTokens into
+// chunks of its hunks, each with the file's header. A hunk larger than
+// that is cut into pieces by line, each repeating the last chunkOverlap
+// lines of the one before.
+func hunkChunks(f gitdiff.File, maxTokens int) []chunk {
+	header, hunks := gitdiff.Hunks(f)
+	if len(hunks) == 0 {
+		return []chunk{{File: f, Name: f.Path}}
+	}
+	budget := max(maxTokens-diffTokens(header), 1)
+
+	// Cut the hunks too large for a chunk
+	var pieces []gitdiff.Hunk
+	for _, h := range hunks {
+		if diffTokens(h.String()) <= budget {
+			pieces = append(pieces, h)
+			continue
+		}
+		for i := 0; i < len(h.Lines); {
+			j, tokens := i, diffTokens(h.Slice(i, i).String())
+			for j < len(h.Lines) && (j == i || tokens+diffTokens(h.Lines[j])+1 <= budget) {
+				tokens += diffTokens(h.Lines[j]) + 1
+				j++
+			}
+			pieces = append(pieces, h.Slice(i, j))
+			if j == len(h.Lines) {
+				break
+			}
+			i = max(j-chunkOverlap, i+1)
+		}
+	}
+
+	// Pack them into chunks
+	var chunks []chunk
+	var current []string
+	first, last, tokens := 0, 0, 0
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		text := header + "\n" + strings.Join(current, "\n") + "\n"
+		chunks = append(chunks, chunk{File: gitdiff.File{Path: f.Path, Text: text}, Name: fmt.Sprintf("%s (%s)", f.Path, tr("lines %d-%d", first, last))})
+		current, tokens = nil, 0
+	}
+	for _, h := range pieces {
+		text := h.String()
+		n := diffTokens(text)
+		if tokens > 0 && tokens+n > budget {
+			flush()
+		}
+		if len(current) == 0 {
+			first = h.NewStart
+		}
+		current = append(current, text)
+		last = h.NewEnd()
+		tokens += n
+	}
+	flush()
+	return chunks
+}
+
+// dedupeFindings combines findings that describe the same issue, as the
+// findings of overlapping parts can, into the most severe of them, in the
+// order they were first raised
+func dedupeFindings(findings []Finding) []Finding {
+	kept := []Finding{}
+	for _, f := range findings {
+		i := slices.IndexFunc(kept, func(k Finding) bool { return sameIssue(k, f) })
+		switch {
+		case i == -1:
+			kept = append(kept, f)
+		case f.Severity > kept[i].Severity:
+			kept[i] = f
+		}
+	}
+	return kept
+}
+
+// partInstructions tells the model it sees one part of a larger change
+const partInstructions = `This change is too large to review at once, so it is reviewed in parts,
+and this is part %d of %d: the diff above only shows %s. The other parts
+are reviewed separately and the reviews merged afterwards. Review this
+part's diff; the list of changed files and the commit messages cover the
+whole change, for context. A large file may be split across parts by
+hunk, each part showing some of its lines. Don't report code as missing
+only because it isn't in this part's diff.`
+
+// partPrompt builds the review prompt for part i (from 0) of n
+func partPrompt(part splitPart, i, n int, changes *branchChanges, additionalContext string, sections []promptSection, cfg *Config) string {
+	scope := promptSection{Title: "Review Scope", Body: fmt.Sprintf(partInstructions, i+1, n, plural(len(part.Files), "file"))}
+	sections = append(append([]promptSection(nil), sections...), scope)
+	return buildReviewPrompt(part.Diff, changes.ChangedFiles, changes.CommitMessages, additionalContext, sections, cfg)
+}
+
+// partReview is the review of one part
+type partReview struct {
+	Part     splitPart
+	Review   string
+	Findings []Finding
+	Usage    Usage
+	Err      error
+	Skipped  bool
+	Cached   bool
+}
+
+// partCacheKey identifies the review of a part by its diff, the context
+// files and the review's instructions. Unlike the key of a whole review it
+// leaves out the commit messages and where the part falls among the others,
+// which change with every new commit, so when a branch gets one more commit
+// only the parts whose files it changes are reviewed again.
+func partCacheKey(model string, part splitPart, additionalContext, instructions string) string {
+	return "part-" + reviewCacheKey(model, part.Diff, additionalContext, instructions)
+}
+
+// partCache keeps the reviews of a split diff's parts under keys, one per
+// part. With reuse unset (-no-cache) the parts are all reviewed again, and
+// their reviews replace those kept.
+type partCache struct {
+	cache *reviewCache
+	keys  []string
+	reuse bool
+}
+
+// lookup returns the cached response to part i, or nil
+func (pc *partCache) lookup(i int) *cachedReview {
+	if pc == nil || !pc.reuse {
+		return nil
+	}
+	return pc.cache.lookup(pc.keys[i])
+}
+
+// store caches the response to part i
+func (pc *partCache) store(i int, model, response string) {
+	if pc == nil {
+		return
+	}
+	if err := pc.cache.store(pc.keys[i], &cachedReview{Model: model, Response: response, CreatedAt: time.Now().UTC()}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not cache the review of part %d: %v\n", i+1, err)
+	}
+}
+
+// reviewParts sends each part's prompt with up to workers requests at once,
+// except for the parts with a review in cache. A part that fails has its
+// error in its review; with failFast, the parts not yet started when one
+// fails are skipped.
+func reviewParts(client Provider, opts CompletionOptions, parts []splitPart, prompts []string, cache *partCache, workers int, failFast bool) []partReview {
+	reviews := make([]partReview, len(parts))
+	jobs := make(chan int)
+	var mu sync.Mutex
+	failed, done := false, 0
+
+	var wg sync.WaitGroup
+	for range min(workers, len(parts)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				r := &reviews[i]
+				r.Part = parts[i]
+				cached := cache.lookup(i)
+				mu.Lock()
+				skip := failFast && failed && cached == nil
+				mu.Unlock()
+				if skip {
+					r.Skipped = true
+					continue
+				}
+
+				var result *review.Result
+				if cached != nil {
+					result, r.Cached = review.Parse(cached.Response), true
+				} else if result, r.Err = review.Run(client, prompts[i], opts); r.Err == nil {
+					r.Usage = result.Usage
+					cache.store(i, opts.Model, result.Response)
+				}
+				if r.Err == nil {
+					r.Review, r.Findings = result.Review, result.Findings
+					if result.FindingsErr != nil {
+						fmt.Fprintf(os.Stderr, "Warning: Could not parse structured findings of part %d: %v\n", i+1, result.FindingsErr)
+					}
+				}
+
+				mu.Lock()
+				done++
+				if r.Err != nil {
+					failed = true
+					fmt.Fprintf(os.Stderr, "Warning: Review of part %d (%s) failed: %v\n", i+1, r.Part.name(), r.Err)
+				} else if r.Cached {
+					fmt.Println("   " + tr("Reused the review of part %d of %d (%d done), unchanged since it was cached: %s", i+1, len(parts), done, r.Part.name()))
+				} else {
+					fmt.Println("   " + tr("Reviewed part %d of %d (%d done): %s", i+1, len(parts), done, r.Part.name()))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range parts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return reviews
+}
+
+// partUnits returns the outcome of each part's review
+func partUnits(reviews []partReview) []unitResult {
+	units := make([]unitResult, len(reviews))
+	for i, r := range reviews {
+		units[i] = unitResult{Name: tr("Part %d: %s", i+1, r.Part.name()), Status: unitSucceeded}
+		switch {
+		case r.Skipped:
+			units[i].Status = unitSkipped
+		case r.Err != nil:
+			units[i].Status, units[i].Error = unitFailed, r.Err.Error()
+		}
+	}
+	return units
+}
+
+// synthesisInstructions asks the model to merge the reviews of the parts
+const synthesisInstructions = `You are an expert code reviewer. A Pull Request too large to review at once
+was reviewed in parts, each seeing only its own files. Below are the
+changed files and commit messages of the whole change, then each part's
+review, then the parts' findings, those of overlapping parts that
+describe the same issue already combined. Write one coherent review of the whole change from
+them: start with an overall assessment, then raise the concerns that span
+parts (interfaces changed on one side but not the other, inconsistent
+error handling, missing tests for code in another part), then the most
+important points of the parts' reviews. Don't repeat every detail.
+
+In the findings, merge the parts' findings into one list: combine
+duplicates, drop findings another part shows to be wrong (e.g. code a part
+reported missing that another part adds), and add the issues that span
+parts. Keep the file, line, end_line, evidence, replacement and patch of
+the findings you keep as they are.`
+
+// buildSynthesisPrompt builds the prompt that merges the parts' reviews
+func buildSynthesisPrompt(reviews []partReview, changes *branchChanges, jsonFormat bool) string {
+	var b strings.Builder
+	b.WriteString(synthesisInstructions + "\n\n---\n\n## Changed Files\n```\n" + changes.ChangedFiles + "\n```\n\n")
+	if changes.CommitMessages != "" {
+		b.WriteString("## Recent Commit Messages\n```\n" + changes.CommitMessages + "\n```\n\n")
+	}
+	var all []Finding
+	for i, r := range reviews {
+		if r.Err != nil || r.Skipped {
+			continue
+		}
+		fmt.Fprintf(&b, "## Part %d: %s\n\n%s\n\n", i+1, strings.Join(r.Part.Files, ", "), strings.TrimSpace(r.Review))
+		all = append(all, r.Findings...)
+	}
+	findings, _ := json.MarshalIndent(dedupeFindings(all), "", "  ")
+	b.WriteString("## Findings of the Parts\n```json\n" + string(findings) + "\n```\n\n")
+	b.WriteString("Please provide the merged code review.\n\n" + review.ChecklistInstructions + "\n\n" + review.FindingsInstructions)
+	if jsonFormat {
+		b.WriteString("\n\n" + jsonFormatInstructions)
+	}
+	return b.String()
+}
+
+// joinPartReviews is the response when the reviews can't be merged: each
+// part's review in turn, with all of their findings
+func joinPartReviews(reviews []partReview) string {
+	var b strings.Builder
+	var findings []Finding
+	for i, r := range reviews {
+		if r.Err != nil || r.Skipped {
+			continue
+		}
+		fmt.Fprintf(&b, "## %s\n\n%s\n\n", tr("Part %d: %s", i+1, r.Part.name()), strings.TrimSpace(r.Review))
+		findings = append(findings, r.Findings...)
+	}
+	data, _ := json.Marshal(dedupeFindings(findings))
+	b.WriteString(review.FindingsStartTag + string(data) + review.FindingsEndTag)
+	return b.String()
+}
+
+// reviewSplit reviews a large diff in parts, at most workers at once, then
+// merges the parts' reviews into the response a single review would have
+// given. Parts reviewed before, as cache has them, aren't reviewed again,
+// but the merge always is. It fails if every part does, or with failFast if
+// any does; if only the merge fails, the parts' reviews are joined instead.
+func reviewSplit(client Provider, opts CompletionOptions, policy *Policy, parts []splitPart, prompts []string, cache *partCache, changes *branchChanges, workers int, failFast, jsonFormat bool) (string, []unitResult, Usage, error) {
+	reviews := reviewParts(client, opts, parts, prompts, cache, workers, failFast)
+	units := partUnits(reviews)
+	var total Usage
+	succeeded := 0
+	for _, r := range reviews {
+		total.Add(r.Usage)
+		if r.Err != nil && failFast {
+			return "", units, total, fmt.Errorf("review of %s failed: %w", r.Part.name(), r.Err)
+		}
+		if r.Err == nil && !r.Skipped {
+			succeeded++
+		}
+	}
+	if succeeded == 0 {
+		return "", units, total, fmt.Errorf("every part failed, the first with: %w", reviews[0].Err)
+	}
+
+	fmt.Println("🧵 " + tr("Merging the reviews of %s...", trPlural(succeeded, "part")))
+	response, usage, err := llm.WithoutDocuments(client).Complete(policy.redact(buildSynthesisPrompt(reviews, changes, jsonFormat)), opts)
+	total.Add(usage)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not merge the reviews of the parts, showing them one by one: %v\n", err)
+		return joinPartReviews(reviews), units, total, nil
+	}
+	return response, units, total, nil
+}
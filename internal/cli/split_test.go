@@ -0,0 +1,233 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// fileDiff returns the diff of a file adding n bytes
+func fileDiff(path string, n int) string {
+	return "diff --git a/" + path + " b/" + path + "\n--- a/" + path + "\n+++ b/" + path + "\n@@ -0,0 +1 @@\n+" + strings.Repeat("x", n) + "\n"
+}
+
+// TestSplitByFile tests packing a diff's files into parts
+func TestSplitByFile(t *testing.T) {
+	diff := fileDiff("a.go", 4000) + fileDiff("b.go", 4000) + fileDiff("huge.go", 40000) + fileDiff("c.go", 100)
+	var got [][]string
+	for _, part := range splitByFile(diff, 3000) {
+		got = append(got, part.Files)
+		if !strings.HasPrefix(part.Diff, "diff --git a/"+part.Files[0]) {
+			t.Errorf("part %v doesn't start with its first file:\n%s", part.Files, part.Diff)
+		}
+	}
+	want := [][]string{{"a.go", "b.go"}, {"huge.go"}, {"c.go"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitByFile() parts = %v, want %v", got, want)
+	}
+	if parts := splitByFile(diff, 100000); len(parts) != 1 || len(parts[0].Files) != 4 {
+		t.Errorf("splitByFile() of a small diff = %d parts, want 1", len(parts))
+	}
+}
+
+// partsProvider reviews each part, failing those of broken.go, and merges
+// them with the merge response or error
+type partsProvider struct {
+	merge    string
+	mergeErr error
+	mu       sync.Mutex
+	prompts  []string
+}
+
+func (p *partsProvider) Name() string { return "Fake" }
+
+func (p *partsProvider) Complete(prompt string, opts CompletionOptions) (string, Usage, error) {
+	p.mu.Lock()
+	p.prompts = append(p.prompts, prompt)
+	p.mu.Unlock()
+	switch {
+	case strings.Contains(prompt, "reviewed in parts, each"):
+		return p.merge, Usage{InputTokens: 50, OutputTokens: 10}, p.mergeErr
+	case strings.Contains(prompt, "b/broken.go"):
+		return "", Usage{}, errors.New("overloaded")
+	case strings.Contains(prompt, "b/a.go"):
+		return "A is fine.\n<findings>[{\"file\": \"a.go\", \"line\": 1, \"severity\": \"low\", \"title\": \"Long line\"}]</findings>", Usage{InputTokens: 100, OutputTokens: 20}, nil
+	}
+	return "B is fine.\n<findings>[]</findings>", Usage{InputTokens: 100, OutputTokens: 20}, nil
+}
+
+// TestReviewSplit tests reviewing the parts of a diff and merging them
+func TestReviewSplit(t *testing.T) {
+	changes := &branchChanges{ChangedFiles: "a.go\nb.go\nbroken.go"}
+	parts := splitByFile(fileDiff("a.go", 10)+fileDiff("b.go", 10)+fileDiff("broken.go", 10), 1)
+	var prompts []string
+	for i, part := range parts {
+		prompts = append(prompts, partPrompt(part, i, len(parts), changes, "", nil, &Config{}))
+	}
+	if !strings.Contains(prompts[1], "this is part 2 of 3") || strings.Contains(prompts[1], "b/a.go") {
+		t.Errorf("part 2's prompt:\n%s", prompts[1])
+	}
+
+	client := &partsProvider{merge: "Overall fine.\n<findings>[]</findings>"}
+	response, units, usage, err := reviewSplit(client, CompletionOptions{}, nil, parts, prompts, nil, changes, 2, false, false)
+	if err != nil {
+		t.Fatalf("reviewSplit() returned error: %v", err)
+	}
+	if response != client.merge {
+		t.Errorf("reviewSplit() response = %q, want the merged review", response)
+	}
+	if succeeded, failed, _ := countUnits(units); succeeded != 2 || failed != 1 || units[2].Error != "overloaded" {
+		t.Errorf("units = %+v", units)
+	}
+	if usage.InputTokens != 250 {
+		t.Errorf("usage = %+v, want two parts and the merge", usage)
+	}
+	merge := client.prompts[len(client.prompts)-1]
+	for _, want := range []string{"## Part 1: a.go\n\nA is fine.", "\"title\": \"Long line\"", "## Part 2: b.go"} {
+		if !strings.Contains(merge, want) {
+			t.Errorf("merge prompt doesn't contain %q:\n%s", want, merge)
+		}
+	}
+	if strings.Contains(merge, "## Part 3") {
+		t.Errorf("merge prompt has the failed part:\n%s", merge)
+	}
+
+	// A failed merge shows the parts' reviews with all of their findings
+	client = &partsProvider{mergeErr: errors.New("overloaded")}
+	response, _, _, err = reviewSplit(client, CompletionOptions{}, nil, parts, prompts, nil, changes, 2, false, false)
+	if err != nil {
+		t.Fatalf("reviewSplit() with a failed merge returned error: %v", err)
+	}
+	review, findings, err := review.ExtractFindings(response)
+	if err != nil || len(findings) != 1 || !strings.Contains(review, "A is fine.") || !strings.Contains(review, "B is fine.") {
+		t.Errorf("joined response = %q, %+v, %v", review, findings, err)
+	}
+
+	if _, _, _, err := reviewSplit(client, CompletionOptions{}, nil, parts, prompts, nil, changes, 1, true, false); err == nil || !strings.Contains(err.Error(), "broken.go") {
+		t.Errorf("reviewSplit() with -fail-fast error = %v, want broken.go's", err)
+	}
+	if _, _, _, err := reviewSplit(client, CompletionOptions{}, nil, parts[2:], prompts[2:], nil, changes, 2, false, false); err == nil {
+		t.Error("reviewSplit() with every part failing returned no error")
+	}
+}
+
+// TestReviewSplit_Cache tests that a re-run only reviews the parts whose
+// diff changed, and always merges them again
+func TestReviewSplit_Cache(t *testing.T) {
+	cache := &reviewCache{dir: t.TempDir()}
+	review := func(diff, commits string, reuse bool) *partsProvider {
+		t.Helper()
+		changes := &branchChanges{ChangedFiles: "a.go\nb.go", CommitMessages: commits}
+		parts := splitByFile(diff, 1)
+		pc := &partCache{cache: cache, reuse: reuse}
+		var prompts []string
+		for i, part := range parts {
+			prompts = append(prompts, partPrompt(part, i, len(parts), changes, "", nil, &Config{}))
+			pc.keys = append(pc.keys, partCacheKey("claude-opus-4-1", part, "", "Review this"))
+		}
+		client := &partsProvider{merge: "Overall fine.\n<findings>[]</findings>"}
+		if _, units, _, err := reviewSplit(client, CompletionOptions{Model: "claude-opus-4-1"}, nil, parts, prompts, pc, changes, 2, false, false); err != nil {
+			t.Fatalf("reviewSplit() returned error: %v", err)
+		} else if succeeded, _, _ := countUnits(units); succeeded != len(parts) {
+			t.Errorf("units = %+v", units)
+		}
+		return client
+	}
+	reviewed := func(client *partsProvider) []string {
+		var files []string
+		for _, prompt := range client.prompts {
+			for _, file := range []string{"a.go", "b.go"} {
+				if strings.Contains(prompt, "+++ b/"+file) {
+					files = append(files, file)
+				}
+			}
+		}
+		slices.Sort(files)
+		return files
+	}
+
+	if got := reviewed(review(fileDiff("a.go", 10)+fileDiff("b.go", 10), "abc1234 - Add a and b", true)); !slices.Equal(got, []string{"a.go", "b.go"}) {
+		t.Errorf("first run reviewed %v", got)
+	}
+
+	// One more commit changes b.go only
+	client := review(fileDiff("a.go", 10)+fileDiff("b.go", 20), "def5678 - Grow b\nabc1234 - Add a and b", true)
+	if got := reviewed(client); !slices.Equal(got, []string{"b.go"}) {
+		t.Errorf("second run reviewed %v, want only the changed b.go", got)
+	}
+	merge := client.prompts[len(client.prompts)-1]
+	if !strings.Contains(merge, "reviewed in parts, each") || !strings.Contains(merge, "A is fine.") {
+		t.Errorf("the merge wasn't done again with the cached part:\n%s", merge)
+	}
+
+	// -no-cache reviews every part again
+	if got := reviewed(review(fileDiff("a.go", 10)+fileDiff("b.go", 20), "def5678 - Grow b", false)); !slices.Equal(got, []string{"a.go", "b.go"}) {
+		t.Errorf("run without reuse reviewed %v", got)
+	}
+}
+
+// TestSplitDiffByHunk tests splitting a large file across parts by hunk
+func TestSplitDiffByHunk(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("diff --git a/big.go b/big.go\n--- a/big.go\n+++ b/big.go\n")
+	for h := range 3 {
+		fmt.Fprintf(&b, "@@ -%d,0 +%d,40 @@\n", h*100, h*100+1)
+		for i := range 40 {
+			fmt.Fprintf(&b, "+line%02d%s\n", i, strings.Repeat("x", 32))
+		}
+	}
+	diff := b.String() + fileDiff("small.go", 10)
+
+	if parts := splitDiff(diff, chunkByFile, 500); len(parts) != 2 {
+		t.Errorf("splitDiff() by file = %d parts, want big.go and small.go", len(parts))
+	}
+	parts := splitDiff(diff, chunkByHunk, 500)
+	if len(parts) < 4 {
+		t.Fatalf("splitDiff() by hunk = %d parts, want the hunks of big.go over several", len(parts))
+	}
+	for _, part := range parts[:len(parts)-1] {
+		if !strings.HasPrefix(part.Diff, "diff --git a/big.go b/big.go\n--- a/big.go\n+++ b/big.go\n@@ -") || !strings.HasPrefix(part.Files[0], "big.go (lines ") {
+			t.Errorf("part %v:\n%s", part.Files, part.Diff)
+		}
+		if n := diffTokens(part.Diff); n > 500 {
+			t.Errorf("part %v has %d tokens, want at most 500", part.Files, n)
+		}
+	}
+	if got := parts[0].Files[0]; !strings.HasPrefix(got, "big.go (lines 1-") {
+		t.Errorf("first part = %q", got)
+	}
+	// The second part repeats the end of the first around the cut
+	first, second := strings.Split(parts[0].Diff, "\n"), strings.Split(parts[1].Diff, "\n")
+	if overlap := first[len(first)-chunkOverlap-1]; second[4] != overlap {
+		t.Errorf("second part starts with %q, want %q:\n%s", second[4], overlap, parts[1].Diff)
+	}
+}
+
+// TestEstimateTokens tests that symbols count for more than letters
+func TestEstimateTokens(t *testing.T) {
+	if n := estimateTokens("if err != nil"); n != 4 {
+		t.Errorf("estimateTokens(words) = %d, want 4", n)
+	}
+	if words, symbols := estimateTokens(strings.Repeat("abcd", 10)), estimateTokens(strings.Repeat("{});", 10)); symbols <= words {
+		t.Errorf("estimateTokens() of symbols = %d, of letters = %d", symbols, words)
+	}
+}
+
+// TestDedupeFindings tests combining findings of overlapping parts
+func TestDedupeFindings(t *testing.T) {
+	findings := []Finding{
+		{File: "a.go", Line: 10, Severity: SeverityLow, Category: "bug", Title: "Nil map write"},
+		{File: "b.go", Line: 3, Severity: SeverityLow, Title: "Typo"},
+		{File: "a.go", Line: 11, Severity: SeverityHigh, Category: "bug", Title: "Write to nil map"},
+	}
+	got := dedupeFindings(findings)
+	if len(got) != 2 || got[0].Severity != SeverityHigh || got[1].File != "b.go" {
+		t.Errorf("dedupeFindings() = %+v", got)
+	}
+}
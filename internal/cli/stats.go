@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats grouping and period options
+const (
+	statsByRepo = "repo"
+	statsByTeam = "team"
+
+	periodWeek  = "week"
+	periodMonth = "month"
+)
+
+// groupStats aggregates review quality metrics over a set of reviews
+type groupStats struct {
+	Group    string
+	Reviews  int
+	Findings int
+
+	// Findings and lines of reviews that recorded their diff size, so
+	// reviews saved before sizes were tracked don't skew the density
+	SizedFindings int
+	LinesChanged  int
+
+	// Latency of reviews that recorded it
+	TimedReviews int
+	TotalLatency time.Duration
+
+	// Cost of reviews by models with a known price
+	PricedReviews int
+	Cost          float64
+
+	BySeverity [SeverityCritical + 1]int
+}
+
+// FindingsPerKLoC is the number of findings per thousand changed lines
+func (s *groupStats) FindingsPerKLoC() (float64, bool) {
+	if s.LinesChanged == 0 {
+		return 0, false
+	}
+	return float64(s.SizedFindings) / (float64(s.LinesChanged) / 1000), true
+}
+
+// MeanLatency is the average time from starting a review to its report
+func (s *groupStats) MeanLatency() (time.Duration, bool) {
+	if s.TimedReviews == 0 {
+		return 0, false
+	}
+	return s.TotalLatency / time.Duration(s.TimedReviews), true
+}
+
+func (s *groupStats) add(r *reviewRecord) {
+	s.Reviews++
+	s.Findings += len(r.Findings)
+	for _, f := range r.Findings {
+		if f.Severity >= SeverityInfo && f.Severity <= SeverityCritical {
+			s.BySeverity[f.Severity]++
+		}
+	}
+	if r.LinesChanged > 0 {
+		s.SizedFindings += len(r.Findings)
+		s.LinesChanged += r.LinesChanged
+	}
+	if r.DurationMS > 0 {
+		s.TimedReviews++
+		s.TotalLatency += time.Duration(r.DurationMS) * time.Millisecond
+	}
+	if cost, ok := reviewCost(r); ok {
+		s.PricedReviews++
+		s.Cost += cost
+	}
+}
+
+// computeStats aggregates reviews by repository or team, sorted by group,
+// followed by the total over all reviews
+func computeStats(records []*reviewRecord, by string) []*groupStats {
+	groups := make(map[string]*groupStats)
+	total := &groupStats{Group: "total"}
+	for _, r := range records {
+		key := r.Repo
+		if by == statsByTeam {
+			key = r.Team
+			if key == "" {
+				key = "(no team)"
+			}
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &groupStats{Group: key}
+			groups[key] = g
+		}
+		g.add(r)
+		total.add(r)
+	}
+
+	stats := make([]*groupStats, 0, len(groups)+1)
+	for _, g := range groups {
+		stats = append(stats, g)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Group < stats[j].Group })
+	return append(stats, total)
+}
+
+// periodKey returns the calendar week (ISO) or month t falls in
+func periodKey(t time.Time, period string) string {
+	t = t.UTC()
+	if period == periodWeek {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return t.Format("2006-01")
+}
+
+// severityOverTime counts findings of each severity per period, oldest first
+func severityOverTime(records []*reviewRecord, period string) ([]string, map[string]*groupStats) {
+	periods := make(map[string]*groupStats)
+	for _, r := range records {
+		key := periodKey(r.CreatedAt, period)
+		if periods[key] == nil {
+			periods[key] = &groupStats{Group: key}
+		}
+		periods[key].add(r)
+	}
+	keys := make([]string, 0, len(periods))
+	for k := range periods {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, periods
+}
+
+// writeStats renders the metrics as Markdown tables
+func writeStats(out io.Writer, records []*reviewRecord, by, period string) {
+	fmt.Fprintf(out, "## Review Quality by %s\n\n", by)
+	fmt.Fprintf(out, "| %s | Reviews | Findings | KLoC changed | Findings/KLoC | Mean latency | Cost (USD) |\n", by)
+	fmt.Fprintln(out, "|---|---:|---:|---:|---:|---:|---:|")
+	for _, s := range computeStats(records, by) {
+		density, latency, cost := "n/a", "n/a", "n/a"
+		if d, ok := s.FindingsPerKLoC(); ok {
+			density = fmt.Sprintf("%.1f", d)
+		}
+		if l, ok := s.MeanLatency(); ok {
+			latency = l.Round(time.Second).String()
+		}
+		if s.PricedReviews > 0 {
+			cost = fmt.Sprintf("%.2f", s.Cost)
+			if s.PricedReviews < s.Reviews {
+				cost += fmt.Sprintf(" (%d of %d priced)", s.PricedReviews, s.Reviews)
+			}
+		}
+		fmt.Fprintf(out, "| %s | %d | %d | %.1f | %s | %s | %s |\n",
+			s.Group, s.Reviews, s.Findings, float64(s.LinesChanged)/1000, density, latency, cost)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Suggestion acceptance rate: n/a (no reviewer feedback has been recorded)")
+	fmt.Fprintln(out)
+
+	fmt.Fprintf(out, "## Severity Distribution by %s\n\n", period)
+	header := []string{period}
+	for sev := SeverityCritical; sev >= SeverityInfo; sev-- {
+		header = append(header, sev.String())
+	}
+	fmt.Fprintf(out, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(out, "|---%s|\n", strings.Repeat("|---:", len(header)-1))
+	keys, periods := severityOverTime(records, period)
+	for _, key := range keys {
+		row := []string{key}
+		for sev := SeverityCritical; sev >= SeverityInfo; sev-- {
+			row = append(row, fmt.Sprint(periods[key].BySeverity[sev]))
+		}
+		fmt.Fprintf(out, "| %s |\n", strings.Join(row, " | "))
+	}
+}
+
+// runStats implements `pr-review stats`, reporting review quality metrics
+// computed from the review history
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	addPathFlags(fs)
+	all := fs.Bool("all", false, "Include every repository, not just the current one")
+	by := fs.String("by", statsByRepo, "Group metrics by: repo or team")
+	period := fs.String("period", periodMonth, "Period for the severity distribution: week or month")
+	parseFlags(fs, fs.Name(), args)
+
+	if *by != statsByRepo && *by != statsByTeam {
+		fmt.Fprintf(os.Stderr, "Error: invalid -by %q (want repo or team)\n", *by)
+		os.Exit(2)
+	}
+	if *period != periodWeek && *period != periodMonth {
+		fmt.Fprintf(os.Stderr, "Error: invalid -period %q (want week or month)\n", *period)
+		os.Exit(2)
+	}
+
+	storage, err := openStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening history: %v\n", err)
+		os.Exit(1)
+	}
+	records, err := loadHistory(storage, historyScope(*all))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		os.Exit(1)
+	}
+	// Postmortems look back at merged changes; they aren't reviews
+	reviews := records[:0]
+	for _, r := range records {
+		if r.Kind == "" {
+			reviews = append(reviews, r)
+		}
+	}
+	records = reviews
+	if len(records) == 0 {
+		fmt.Println("No reviews in history yet.")
+		return
+	}
+	writeStats(os.Stdout, records, *by, *period)
+}
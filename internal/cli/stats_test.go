@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestComputeStats tests per-group and total metrics
+func TestComputeStats(t *testing.T) {
+	jan := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	records := []*reviewRecord{
+		{Repo: "app", Team: "payments", CreatedAt: jan, Model: "claude-sonnet-4-5-20250929",
+			InputTokens: 1_000_000, LinesChanged: 500, DurationMS: 30_000,
+			Findings: []Finding{{Severity: SeverityHigh}, {Severity: SeverityLow}}},
+		// Recorded with its cost, which counts though the price is unknown
+		{Repo: "app", Team: "payments", CreatedAt: jan, Model: "unknown-model",
+			LinesChanged: 1500, DurationMS: 90_000, CostUSD: 0.5,
+			Findings: []Finding{{Severity: SeverityCritical}}},
+		// Saved before sizes and latency were recorded
+		{Repo: "lib", CreatedAt: jan, Model: "claude-sonnet-4-5-20250929",
+			Findings: []Finding{{Severity: SeverityLow}}},
+	}
+
+	stats := computeStats(records, statsByRepo)
+	if len(stats) != 3 || stats[0].Group != "app" || stats[1].Group != "lib" || stats[2].Group != "total" {
+		t.Fatalf("groups = %v, want app, lib, total", stats)
+	}
+	app, lib, total := stats[0], stats[1], stats[2]
+	if d, ok := app.FindingsPerKLoC(); !ok || d != 1.5 {
+		t.Errorf("app findings/KLoC = %v, %v; want 1.5", d, ok)
+	}
+	if l, ok := app.MeanLatency(); !ok || l != time.Minute {
+		t.Errorf("app mean latency = %v, %v; want 1m", l, ok)
+	}
+	if app.PricedReviews != 2 || app.Cost != 3.5 {
+		t.Errorf("app cost = %v over %d reviews, want 3.5 over 2", app.Cost, app.PricedReviews)
+	}
+	if _, ok := lib.FindingsPerKLoC(); ok {
+		t.Error("lib has findings/KLoC without recorded sizes")
+	}
+	if total.Reviews != 3 || total.Findings != 4 || total.BySeverity[SeverityLow] != 2 {
+		t.Errorf("total = %+v", total)
+	}
+	if d, _ := total.FindingsPerKLoC(); d != 1.5 {
+		t.Errorf("total findings/KLoC = %v; reviews without sizes should be excluded", d)
+	}
+
+	byTeam := computeStats(records, statsByTeam)
+	if byTeam[0].Group != "(no team)" || byTeam[1].Group != "payments" || byTeam[1].Reviews != 2 {
+		t.Errorf("team groups = %+v, %+v", byTeam[0], byTeam[1])
+	}
+}
+
+// TestWriteStats tests the severity distribution over time
+func TestWriteStats(t *testing.T) {
+	records := []*reviewRecord{
+		{Repo: "app", CreatedAt: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), Findings: []Finding{{Severity: SeverityHigh}}},
+		{Repo: "app", CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Findings: []Finding{{Severity: SeverityHigh}, {Severity: SeverityInfo}}},
+	}
+	var out bytes.Buffer
+	writeStats(&out, records, statsByRepo, periodMonth)
+	text := out.String()
+
+	jan, feb := strings.Index(text, "| 2025-01 | 0 | 1 | 0 | 0 | 1 |"), strings.Index(text, "| 2025-02 | 0 | 1 | 0 | 0 | 0 |")
+	if jan < 0 || feb < jan {
+		t.Errorf("severity distribution missing or out of order:\n%s", text)
+	}
+	if !strings.Contains(text, "acceptance rate: n/a") {
+		t.Error("report does not explain the missing acceptance rate")
+	}
+}
+
+// TestPeriodKey tests week and month bucketing
+func TestPeriodKey(t *testing.T) {
+	day := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	if got := periodKey(day, periodMonth); got != "2025-01" {
+		t.Errorf("month = %q", got)
+	}
+	if got := periodKey(day, periodWeek); got != "2025-W01" {
+		t.Errorf("week = %q", got)
+	}
+}
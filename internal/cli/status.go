@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Exit codes of a review, so CI scripts can branch on the outcome without
+// parsing its output
+const (
+	exitOK        = 0 // reviewed, and no finding failed the gate
+	exitGate      = 1 // a finding is at or above -fail-on or the repository's fail_on
+	exitUsage     = 2 // invalid flags, arguments, config or policy
+	exitProvider  = 3 // the model provider's or code host's API failed
+	exitGit       = 4 // a git command failed or a ref couldn't be fetched
+	exitCancelled = 5 // interrupted by SIGINT or SIGTERM
+	exitPartial   = 6 // some units of a run of several failed
+	exitBudget    = 7 // the review would exceed its budget; nothing was sent
+)
+
+// exitStatuses name the exit codes in the status file
+var exitStatuses = map[int]string{
+	exitOK:        "ok",
+	exitGate:      "gate_failed",
+	exitUsage:     "usage_error",
+	exitProvider:  "provider_error",
+	exitGit:       "git_error",
+	exitCancelled: "cancelled",
+	exitPartial:   "partial_failure",
+	exitBudget:    "over_budget",
+}
+
+// runStatus is the outcome of a review, written to -status-file as JSON
+type runStatus struct {
+	Status       string         `json:"status"`
+	ExitCode     int            `json:"exit_code"`
+	Error        string         `json:"error,omitempty"`
+	Repo         string         `json:"repo,omitempty"`
+	Branch       string         `json:"branch,omitempty"`
+	BaseSHA      string         `json:"base_sha,omitempty"`
+	HeadSHA      string         `json:"head_sha,omitempty"`
+	Model        string         `json:"model,omitempty"`
+	Reused       bool           `json:"reused,omitempty"`
+	Output       string         `json:"output,omitempty"`
+	Findings     int            `json:"findings"`
+	BySeverity   map[string]int `json:"by_severity,omitempty"`
+	GateFailures int            `json:"gate_failures"`
+	Units        []unitResult   `json:"units,omitempty"`
+	InputTokens  int            `json:"input_tokens"`
+	OutputTokens int            `json:"output_tokens"`
+	StartedAt    time.Time      `json:"started_at"`
+	DurationMS   int64          `json:"duration_ms"`
+
+	path string
+}
+
+// run is the status of the review in progress, written by exitWith if
+// -status-file is set
+var run = &runStatus{StartedAt: time.Now()}
+
+// setFindings records the findings of the review and how many failed the
+// gate
+func (s *runStatus) setFindings(findings, failed []Finding) {
+	s.Findings, s.GateFailures = len(findings), len(failed)
+	s.BySeverity = nil
+	for _, f := range findings {
+		if s.BySeverity == nil {
+			s.BySeverity = map[string]int{}
+		}
+		s.BySeverity[f.Severity.String()]++
+	}
+}
+
+// write writes the status file, if -status-file is set
+func (s *runStatus) write(code int) error {
+	if s.path == "" {
+		return nil
+	}
+	s.Status, s.ExitCode = exitStatuses[code], code
+	s.DurationMS = time.Since(s.StartedAt).Milliseconds()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append(data, '\n'), 0644)
+}
+
+// exitWith writes the status file and exits with code
+func exitWith(code int) {
+	if err := run.write(code); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not write status file: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+// fail prints an error to stderr, records it in the status file and exits
+// with code
+func fail(code int, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintln(os.Stderr, msg)
+	run.Error = strings.TrimPrefix(msg, "Error: ")
+	exitWith(code)
+}
+
+// exitOnCancel exits with exitCancelled, after writing the status file, when
+// the run is interrupted
+func exitOnCancel() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		fail(exitCancelled, "Error: cancelled by %s", sig)
+	}()
+}
+
+// exitOnTimeout exits with exitCancelled, as a cancelled run does, once the
+// run has taken longer than d; 0 means no limit
+func exitOnTimeout(d time.Duration) {
+	if d > 0 {
+		time.AfterFunc(d, func() {
+			fail(exitCancelled, "Error: timed out after %s", d)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRunStatus tests the status file written for a review that passes the
+// gate
+func TestRunStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	saved := run
+	run = &runStatus{path: path, Repo: "acme/app", HeadSHA: "abc123", InputTokens: 1200, OutputTokens: 300}
+	t.Cleanup(func() { run = saved })
+
+	high := SeverityHigh
+	exitOnGate(&Config{FailOn: &high}, []Finding{
+		{Title: "Typo", Severity: SeverityLow},
+		{Title: "Unused variable", Severity: SeverityLow},
+		{Title: "Slow query", Severity: SeverityMedium},
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("status file not written: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("status file isn't JSON: %v\n%s", err, data)
+	}
+	if got["status"] != "ok" || got["exit_code"] != 0.0 || got["repo"] != "acme/app" || got["input_tokens"] != 1200.0 {
+		t.Errorf("status = %s", data)
+	}
+	if got["findings"] != 3.0 || got["gate_failures"] != 0.0 {
+		t.Errorf("findings = %v, gate failures = %v", got["findings"], got["gate_failures"])
+	}
+	if bySeverity, _ := got["by_severity"].(map[string]any); bySeverity["low"] != 2.0 || bySeverity["medium"] != 1.0 {
+		t.Errorf("by_severity = %v", got["by_severity"])
+	}
+	if _, ok := got["error"]; ok {
+		t.Error("successful run has an error")
+	}
+}
+
+// TestRunStatus_Error tests the status recorded for a failed run
+func TestRunStatus_Error(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	status := &runStatus{path: path, Error: "API error (status 401)"}
+	if err := status.write(exitProvider); err != nil {
+		t.Fatalf("write() returned error: %v", err)
+	}
+	var got runStatus
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &got); err != nil || got.Status != "provider_error" || got.ExitCode != exitProvider || got.Error != "API error (status 401)" {
+		t.Errorf("status = %s (%v)", data, err)
+	}
+
+	if err := (&runStatus{}).write(exitOK); err != nil {
+		t.Errorf("write() without -status-file returned error: %v", err)
+	}
+}
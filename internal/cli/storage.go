@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Storage backends, selected with storage.backend in the global config
+const (
+	storageSQLite   = "sqlite"
+	storagePostgres = "postgres"
+	storageRedis    = "redis"
+)
+
+// storageURLEnv holds the storage URL when the global config doesn't, so
+// credentials can stay out of the file
+const storageURLEnv = "PR_REVIEW_STORAGE_URL"
+
+// StorageConfig selects where review history and the upload cache are kept.
+// SQLite in the data directory is the default; Postgres and Redis let
+// several machines, such as replicas of a shared deployment, share them.
+type StorageConfig struct {
+	Backend string `yaml:"backend"`
+
+	// URL locates the Postgres or Redis server, e.g.
+	// postgres://pr-review@db/pr_review or rediss://cache:6380/0
+	URL string `yaml:"url"`
+
+	// KeyPrefix namespaces Redis keys (default "pr-review:")
+	KeyPrefix string `yaml:"key_prefix"`
+}
+
+// validate checks the backend is known and has the URL it needs
+func (s StorageConfig) validate() error {
+	switch s.Backend {
+	case "", storageSQLite:
+		if s.URL != "" {
+			return fmt.Errorf("storage.url is only used by the postgres and redis backends")
+		}
+	case storagePostgres, storageRedis:
+		if s.URL == "" {
+			return fmt.Errorf("storage backend %s needs storage.url or $%s", s.Backend, storageURLEnv)
+		}
+	default:
+		return fmt.Errorf("unknown storage backend %q (want sqlite, postgres or redis)", s.Backend)
+	}
+	return nil
+}
+
+// reviewHistory is the review history of one repository, or of every
+// repository in a shared database
+type reviewHistory interface {
+	Record(r *reviewRecord) error
+	FindLatest(repo, baseSHA, headSHA string) (*reviewRecord, error)
+	List() ([]*reviewRecord, error)
+	Contains(r *reviewRecord) (bool, error)
+	Prune(keep Retention, now time.Time) (int, error)
+	Compact() error
+	Vacuum() error
+	Size() (ByteSize, error)
+	Close() error
+}
+
+// cacheStore keeps small values between runs where every machine using the
+// same storage can see them
+type cacheStore interface {
+	// Get returns the value of key, or nil if it isn't cached or has expired
+	Get(key string) ([]byte, error)
+
+	// Put sets the value of key, expiring it after ttl; with a ttl of zero
+	// it is kept until it is replaced
+	Put(key string, value []byte, ttl time.Duration) error
+	Close() error
+}
+
+// storageBackend opens review history and the shared cache
+type storageBackend interface {
+	// OpenHistory opens the history of repo, creating it if needed
+	OpenHistory(repo string) (reviewHistory, error)
+
+	// EachHistory calls fn with the history of repo, or of every repository
+	// if repo is "", one repository at a time; repositories without history
+	// are skipped. fn mustn't close the history.
+	EachHistory(repo string, fn func(reviewHistory) error) error
+
+	// OpenCache opens the shared cache, or returns nil if the cache is the
+	// cache directory
+	OpenCache() (cacheStore, error)
+}
+
+// openStorage returns the storage backend the global config selects
+func openStorage() (storageBackend, error) {
+	cfg, err := loadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	s := cfg.Storage
+	if s.URL == "" && (s.Backend == storagePostgres || s.Backend == storageRedis) {
+		s.URL = os.Getenv(storageURLEnv)
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	switch s.Backend {
+	case storagePostgres:
+		return &postgresStorage{url: s.URL}, nil
+	case storageRedis:
+		prefix := s.KeyPrefix
+		if prefix == "" {
+			prefix = defaultRedisPrefix
+		}
+		return &redisStorage{url: s.URL, prefix: prefix}, nil
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	return sqliteStorage{dir: dir}, nil
+}
+
+// historyScope is the repository whose history a subcommand works on: the
+// current one, or with all set, every repository ("")
+func historyScope(all bool) string {
+	if all {
+		return ""
+	}
+	return getRepoIdentity()
+}
+
+// sqliteStorage keeps each repository's history in its own SQLite database
+// under the data directory, and leaves caching to the cache directory
+type sqliteStorage struct {
+	dir string
+}
+
+func (s sqliteStorage) OpenHistory(repo string) (reviewHistory, error) {
+	h, err := openRepoHistory(s.dir, repo)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (s sqliteStorage) EachHistory(repo string, fn func(reviewHistory) error) error {
+	paths := []string{repoHistoryPath(s.dir, repo)}
+	if repo == "" {
+		var err error
+		if paths, err = allHistoryPaths(s.dir); err != nil {
+			return err
+		}
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		h, err := openHistory(path)
+		if err != nil {
+			return err
+		}
+		err = fn(h)
+		h.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (s sqliteStorage) OpenCache() (cacheStore, error) {
+	return nil, nil
+}
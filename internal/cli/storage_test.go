@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marete/pr-review/pkg/llm"
+)
+
+// TestOpenStorage tests selecting the storage backend in the global config
+func TestOpenStorage(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(config, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setPathFlags(t, config, "", filepath.Join(dir, "data"))
+	t.Setenv(storageURLEnv, "")
+
+	write("")
+	if s, err := openStorage(); err != nil || s != (sqliteStorage{dir: filepath.Join(dir, "data")}) {
+		t.Errorf("openStorage() by default = %#v, %v", s, err)
+	}
+
+	write("storage:\n  backend: redis\n  url: redis://cache:6379/1\n")
+	if s, err := openStorage(); err != nil || *s.(*redisStorage) != (redisStorage{url: "redis://cache:6379/1", prefix: defaultRedisPrefix}) {
+		t.Errorf("openStorage() with redis = %#v, %v", s, err)
+	}
+
+	// The URL can come from the environment, keeping credentials out of the file
+	write("storage:\n  backend: postgres\n")
+	if _, err := openStorage(); err == nil || !strings.Contains(err.Error(), storageURLEnv) {
+		t.Errorf("openStorage() without a URL = %v", err)
+	}
+	t.Setenv(storageURLEnv, "postgres://pr-review:secret@db/pr_review")
+	if s, err := openStorage(); err != nil || s.(*postgresStorage).url != "postgres://pr-review:secret@db/pr_review" {
+		t.Errorf("openStorage() with $%s = %#v, %v", storageURLEnv, s, err)
+	}
+
+	for _, bad := range []string{"storage:\n  backend: mongo\n", "storage:\n  url: redis://cache\n"} {
+		write(bad)
+		if _, err := openStorage(); err == nil {
+			t.Errorf("openStorage() accepted %q", bad)
+		}
+	}
+}
+
+// TestHistoryStore_Postgres tests adapting queries and values to Postgres
+// and limiting a shared database to one repository
+func TestHistoryStore_Postgres(t *testing.T) {
+	h := &historyStore{postgres: true, repo: "github.com/org/app"}
+	where, args := h.where("created_at < ?", "2026-01-01")
+	if got := h.bind(`DELETE FROM reviews ` + where); got != `DELETE FROM reviews WHERE created_at < $1 AND repo = $2` {
+		t.Errorf("bind() = %q", got)
+	}
+	if len(args) != 2 || args[1] != "github.com/org/app" {
+		t.Errorf("where() args = %v", args)
+	}
+	if values := h.text([2]any{"short", []byte{0x1f}}); values[0].([]byte) == nil {
+		t.Errorf("text() = %#v, want bytes", values)
+	}
+
+	sqlite := &historyStore{}
+	if where, args := sqlite.where(""); where != "" || args != nil {
+		t.Errorf("where() of a SQLite store = %q, %v", where, args)
+	}
+	if got := sqlite.bind("id = ?"); got != "id = ?" {
+		t.Errorf("bind() of a SQLite store = %q", got)
+	}
+}
+
+// memoryCache is a cacheStore in memory
+type memoryCache map[string][]byte
+
+func (c memoryCache) Get(key string) ([]byte, error) { return c[key], nil }
+func (c memoryCache) Close() error                   { return nil }
+
+func (c memoryCache) Put(key string, value []byte, ttl time.Duration) error {
+	c[key] = value
+	return nil
+}
+
+// TestFileCache_Shared tests keeping uploads in a shared cache rather than
+// the cache directory
+func TestFileCache_Shared(t *testing.T) {
+	shared := memoryCache{}
+	cache := &fileCache{Files: map[string]llm.UploadedFile{}, shared: shared}
+	if _, ok := cache.LookupUpload("abc"); ok {
+		t.Error("LookupUpload() found an upload in an empty cache")
+	}
+	if err := cache.RememberUpload("abc", llm.UploadedFile{ID: "file_1", Name: "schema.sql"}); err != nil {
+		t.Fatalf("RememberUpload() returned error: %v", err)
+	}
+	if f, ok := (&fileCache{shared: shared}).LookupUpload("abc"); !ok || f.ID != "file_1" {
+		t.Errorf("LookupUpload() from another machine = %+v, %v", f, ok)
+	}
+	if len(cache.Files) != 0 || shared["files:abc"] == nil {
+		t.Errorf("upload was cached locally: %v", cache.Files)
+	}
+}
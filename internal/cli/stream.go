@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// defaultStreamIdleTimeout is how long a streamed review may go without
+// sending anything before it is given up on, unless -stream-idle-timeout is
+// given. The API sends ping events while the model is busy, so a silent
+// stream has stalled.
+const defaultStreamIdleTimeout = 2 * time.Minute
+
+// liveReview shows a review in the terminal as it is written, leaving out
+// the findings and checklist sections, which are meant for the tool rather
+// than the reader and are rendered in the report
+type liveReview struct {
+	out     io.Writer
+	pending string // text that may be the start of a tag
+	hiding  string // the end tag of the section being left out
+}
+
+// liveSections are the tags of the sections liveReview leaves out
+var liveSections = [][2]string{
+	{review.FindingsStartTag, review.FindingsEndTag},
+	{review.ChecklistStartTag, review.ChecklistEndTag},
+}
+
+func newLiveReview(out io.Writer) *liveReview {
+	return &liveReview{out: out}
+}
+
+// Write implements io.Writer
+func (l *liveReview) Write(p []byte) (int, error) {
+	text := l.pending + string(p)
+	l.pending = ""
+	for text != "" {
+		if l.hiding != "" {
+			i := strings.Index(text, l.hiding)
+			if i == -1 {
+				l.pending = tagPrefixSuffix(text, []string{l.hiding})
+				return len(p), nil
+			}
+			text, l.hiding = text[i+len(l.hiding):], ""
+			continue
+		}
+
+		start, end := -1, ""
+		for _, s := range liveSections {
+			if i := strings.Index(text, s[0]); i != -1 && (start == -1 || i < start) {
+				start, end = i, s[1]
+			}
+		}
+		if start == -1 {
+			starts := make([]string, len(liveSections))
+			for i, s := range liveSections {
+				starts[i] = s[0]
+			}
+			l.pending = tagPrefixSuffix(text, starts)
+			_, err := io.WriteString(l.out, text[:len(text)-len(l.pending)])
+			return len(p), err
+		}
+		if _, err := io.WriteString(l.out, text[:start]); err != nil {
+			return len(p), err
+		}
+		text, l.hiding = text[start:], end
+	}
+	return len(p), nil
+}
+
+// Close writes out any text held back and ends the review with a blank line
+func (l *liveReview) Close() error {
+	if l == nil {
+		return nil
+	}
+	if l.hiding == "" {
+		io.WriteString(l.out, l.pending)
+	}
+	l.pending = ""
+	_, err := io.WriteString(l.out, "\n\n")
+	return err
+}
+
+// tagPrefixSuffix returns the longest end of text that is the start of
+// one of tags, which has to be held back until the next write shows
+// whether it is the tag
+func tagPrefixSuffix(text string, tags []string) string {
+	longest := ""
+	for _, tag := range tags {
+		for n := min(len(text), len(tag)-1); n > len(longest); n-- {
+			if strings.HasSuffix(text, tag[:n]) {
+				longest = text[len(text)-n:]
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLiveReview tests that the findings and checklist sections are left
+// out of the live review, even when their tags are split across writes
+func TestLiveReview(t *testing.T) {
+	var out strings.Builder
+	live := newLiveReview(&out)
+	for _, chunk := range []string{"Use a <b>mutex</b>.\n<fin", "dings>[{\"title\": \"Race\"}]</find", "ings>\n<checklist>- [ ] Run it</checklist>Done <"} {
+		live.Write([]byte(chunk))
+	}
+	live.Close()
+	if got, want := out.String(), "Use a <b>mutex</b>.\n\nDone <\n\n"; got != want {
+		t.Errorf("live review = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// urlTarget is a GitHub commit or comparison reviewed from its URL, with
+// the patch fetched through the API rather than from a local checkout
+type urlTarget struct {
+	Remote  string // repository, as normalized by normalizeRemoteURL
+	Label   string // shown in place of a branch, e.g. org/repo@abc1234
+	Base    string // commit SHA the changes are against
+	Head    string // commit SHA of the changes
+	Changes *branchChanges
+}
+
+// githubTargetPattern matches .../org/repo/commit/<sha> and
+// .../org/repo/compare/<base>...<head> URLs
+var githubTargetPattern = regexp.MustCompile(`^([^/]+/[^/]+/[^/]+)/(commit|compare)/(.+)$`)
+
+// parseTargetURL splits a GitHub commit or compare URL into the repository,
+// the kind of target and the commit or range
+func parseTargetURL(raw string) (remote, kind, spec string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", "", "", fmt.Errorf("%q is not a GitHub commit or compare URL", raw)
+	}
+	m := githubTargetPattern.FindStringSubmatch(strings.ToLower(u.Host) + strings.TrimSuffix(u.Path, "/"))
+	if m == nil {
+		return "", "", "", fmt.Errorf("%q is not a GitHub commit or compare URL (want .../commit/<sha> or .../compare/<base>...<head>)", raw)
+	}
+	remote, kind, spec = m[1], m[2], m[3]
+	// The .diff and .patch views name the same target
+	spec = strings.TrimSuffix(strings.TrimSuffix(spec, ".diff"), ".patch")
+	if kind == "compare" && !strings.Contains(spec, "..") {
+		return "", "", "", fmt.Errorf("compare URL %q has no range (want <base>...<head>)", raw)
+	}
+	return remote, kind, spec, nil
+}
+
+// githubCommitFile is a changed file as the commits and compare APIs list it
+type githubCommitFile struct {
+	Filename         string `json:"filename"`
+	Status           string `json:"status"`
+	PreviousFilename string `json:"previous_filename"`
+}
+
+// githubCommit is a commit as the commits and compare APIs return it
+type githubCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name string    `json:"name"`
+			Date time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+	Parents []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+	Files []githubCommitFile `json:"files"`
+}
+
+// fetchTargetURL fetches the patch, changed files and commits of a GitHub
+// commit or compare URL. GITHUB_TOKEN is used if set, which private
+// repositories need.
+func fetchTargetURL(raw string) (*urlTarget, error) {
+	remote, kind, spec, err := parseTargetURL(raw)
+	if err != nil {
+		return nil, err
+	}
+	gh, err := githubRepoClient(remote, githubToken())
+	if err != nil {
+		return nil, err
+	}
+	target := &urlTarget{Remote: remote}
+
+	var commits []githubCommit
+	var files []githubCommitFile
+	path := fmt.Sprintf("/repos/%s/%s/commits/%s", gh.owner, gh.repo, spec)
+	if kind == "commit" {
+		var commit githubCommit
+		if err := gh.do("GET", path, nil, &commit); err != nil {
+			return nil, fmt.Errorf("failed to get commit %s: %w", spec, err)
+		}
+		if len(commit.Parents) == 0 {
+			return nil, fmt.Errorf("commit %s has no parent to review it against", shortSHA(commit.SHA))
+		}
+		commits, files = []githubCommit{commit}, commit.Files
+		target.Base, target.Head = commit.Parents[0].SHA, commit.SHA
+		target.Label = fmt.Sprintf("%s/%s@%s", gh.owner, gh.repo, shortSHA(commit.SHA))
+	} else {
+		var comparison struct {
+			MergeBase struct {
+				SHA string `json:"sha"`
+			} `json:"merge_base_commit"`
+			Commits []githubCommit     `json:"commits"`
+			Files   []githubCommitFile `json:"files"`
+		}
+		path = fmt.Sprintf("/repos/%s/%s/compare/%s", gh.owner, gh.repo, spec)
+		if err := gh.do("GET", path, nil, &comparison); err != nil {
+			return nil, fmt.Errorf("failed to compare %s: %w", spec, err)
+		}
+		commits, files = comparison.Commits, comparison.Files
+		target.Base, target.Head = comparison.MergeBase.SHA, comparison.MergeBase.SHA
+		if len(commits) > 0 {
+			target.Head = commits[len(commits)-1].SHA
+		}
+		target.Label = fmt.Sprintf("%s/%s %s", gh.owner, gh.repo, spec)
+	}
+
+	// The same endpoint serves the patch as a unified diff
+	data, err := gh.send("GET", path, "application/vnd.github.diff", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the diff of %s: %w", spec, err)
+	}
+	diff, notes := sanitizeDiff(string(data))
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", note)
+	}
+
+	target.Changes = &branchChanges{
+		BaseRef:        target.Base,
+		Diff:           diff,
+		ChangedFiles:   formatCommitFiles(files),
+		CommitMessages: formatCommits(commits),
+	}
+	return target, nil
+}
+
+// githubFileStatus maps the API's file statuses to git's --name-status letters
+var githubFileStatus = map[string]string{
+	"added":    "A",
+	"removed":  "D",
+	"modified": "M",
+	"changed":  "M",
+	"renamed":  "R",
+	"copied":   "C",
+}
+
+// formatCommitFiles lists changed files like git diff --name-status
+func formatCommitFiles(files []githubCommitFile) string {
+	lines := make([]string, 0, len(files))
+	for _, f := range files {
+		status := githubFileStatus[f.Status]
+		if status == "" {
+			status = "M"
+		}
+		if f.PreviousFilename != "" {
+			lines = append(lines, fmt.Sprintf("%s\t%s\t%s", status, f.PreviousFilename, f.Filename))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s\t%s", status, f.Filename))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatCommits lists commits like gitdiff.Git.Log, newest first
+func formatCommits(commits []githubCommit) string {
+	lines := make([]string, 0, len(commits))
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		subject, _, _ := strings.Cut(c.Commit.Message, "\n")
+		lines = append(lines, fmt.Sprintf("%.7s - %s (%s, %s)", c.SHA, subject, c.Commit.Author.Name, c.Commit.Author.Date.Format(time.DateOnly)))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestParseTargetURL tests recognizing GitHub commit and compare URLs
+func TestParseTargetURL(t *testing.T) {
+	tests := []struct {
+		url                string
+		remote, kind, spec string
+		wantErr            bool
+	}{
+		{url: "https://github.com/org/repo/commit/1a2b3c", remote: "github.com/org/repo", kind: "commit", spec: "1a2b3c"},
+		{url: "https://GitHub.com/org/repo/commit/1a2b3c.diff", remote: "github.com/org/repo", kind: "commit", spec: "1a2b3c"},
+		{url: "https://github.com/org/repo/compare/main...someone:fix/typo", remote: "github.com/org/repo", kind: "compare", spec: "main...someone:fix/typo"},
+		{url: "https://ghe.example.com/org/repo/compare/v1.0..v1.1/", remote: "ghe.example.com/org/repo", kind: "compare", spec: "v1.0..v1.1"},
+		{url: "https://github.com/org/repo/compare/main", wantErr: true},
+		{url: "https://github.com/org/repo/pull/5", wantErr: true},
+		{url: "github.com/org/repo/commit/1a2b3c", wantErr: true},
+	}
+	for _, tt := range tests {
+		remote, kind, spec, err := parseTargetURL(tt.url)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseTargetURL(%q) expected error", tt.url)
+			}
+			continue
+		}
+		if err != nil || remote != tt.remote || kind != tt.kind || spec != tt.spec {
+			t.Errorf("parseTargetURL(%q) = %q, %q, %q, %v", tt.url, remote, kind, spec, err)
+		}
+	}
+}
+
+// TestFetchTargetURL tests fetching the patch and git context of a commit
+// and a comparison from the API
+func TestFetchTargetURL(t *testing.T) {
+	const diff = "diff --git a/app.go b/app.go\n--- a/app.go\n+++ b/app.go\n@@ -1 +1 @@\n-package old\n+package app\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("Authorization sent without a token: %q", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("Accept") == "application/vnd.github.diff" {
+			fmt.Fprint(w, diff)
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/org/repo/commits/1a2b3c":
+			fmt.Fprint(w, `{"sha": "1a2b3c4d5e6f7a8b9c0d", "parents": [{"sha": "0f0f0f"}],
+				"commit": {"message": "Rename package\n\nDetails.", "author": {"name": "Ada", "date": "2020-01-01T00:00:00Z"}},
+				"files": [{"filename": "app.go", "status": "modified"}]}`)
+		case "/repos/org/repo/compare/main...someone:fix":
+			fmt.Fprint(w, `{"merge_base_commit": {"sha": "0f0f0f"},
+				"commits": [{"sha": "aaaaaaa1", "commit": {"message": "First"}}, {"sha": "bbbbbbb2", "commit": {"message": "Second"}}],
+				"files": [{"filename": "new.go", "previous_filename": "old.go", "status": "renamed"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITHUB_API_URL", server.URL)
+
+	target, err := fetchTargetURL("https://github.com/org/repo/commit/1a2b3c")
+	if err != nil {
+		t.Fatalf("fetchTargetURL() returned error: %v", err)
+	}
+	if target.Remote != "github.com/org/repo" || target.Base != "0f0f0f" || target.Head != "1a2b3c4d5e6f7a8b9c0d" {
+		t.Errorf("target = %+v", target)
+	}
+	if target.Changes.Diff != diff || target.Changes.ChangedFiles != "M\tapp.go" {
+		t.Errorf("changes = %+v", target.Changes)
+	}
+	if !strings.HasPrefix(target.Changes.CommitMessages, "1a2b3c4 - Rename package (Ada, ") {
+		t.Errorf("CommitMessages = %q", target.Changes.CommitMessages)
+	}
+
+	target, err = fetchTargetURL("https://github.com/org/repo/compare/main...someone:fix")
+	if err != nil {
+		t.Fatalf("fetchTargetURL() returned error: %v", err)
+	}
+	if target.Base != "0f0f0f" || target.Head != "bbbbbbb2" {
+		t.Errorf("compare target = %+v", target)
+	}
+	if target.Changes.ChangedFiles != "R\told.go\tnew.go" {
+		t.Errorf("ChangedFiles = %q", target.Changes.ChangedFiles)
+	}
+	if lines := strings.Split(target.Changes.CommitMessages, "\n"); len(lines) != 2 || !strings.HasPrefix(lines[0], "bbbbbbb - Second") {
+		t.Errorf("CommitMessages = %q, want newest first", target.Changes.CommitMessages)
+	}
+
+	if _, err := fetchTargetURL("https://github.com/org/repo/commit/missing"); err == nil {
+		t.Error("fetchTargetURL() of a missing commit expected error")
+	}
+}
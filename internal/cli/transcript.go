@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transcript records the exact API exchanges of a run for compliance audits.
+// Everything is passed through the policy's redaction rules, and credentials
+// are never written.
+type transcript struct {
+	path   string
+	policy *Policy
+
+	// mu serializes exchanges recorded by concurrent requests
+	mu sync.Mutex
+
+	Tool      string               `json:"tool"`
+	CreatedAt time.Time            `json:"created_at"`
+	Exchanges []transcriptExchange `json:"exchanges"`
+}
+
+// transcriptExchange is one request to the API and its response
+type transcriptExchange struct {
+	Time       time.Time          `json:"time"`
+	DurationMS int64              `json:"duration_ms"`
+	Request    transcriptMessage  `json:"request"`
+	Response   *transcriptMessage `json:"response,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// transcriptMessage is an HTTP request or response. The body is kept as the
+// JSON that was sent or received, thinking blocks and all.
+type transcriptMessage struct {
+	Method  string          `json:"method,omitempty"`
+	URL     string          `json:"url,omitempty"`
+	Status  int             `json:"status,omitempty"`
+	Headers http.Header     `json:"headers"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// secretHeaders are never written to a transcript
+var secretHeaders = []string{"X-Api-Key", "Api-Key", "Authorization", "X-Amz-Security-Token"}
+
+func newTranscript(path string, policy *Policy) *transcript {
+	return &transcript{path: path, policy: policy, Tool: "pr-review", CreatedAt: time.Now().UTC()}
+}
+
+// Record implements llm.Recorder: it adds an exchange and rewrites the
+// transcript file, so it is complete even if the run fails afterwards. resp
+// is nil if the request could not be sent.
+func (t *transcript) Record(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, started time.Time, err error) {
+	if t == nil {
+		return
+	}
+
+	ex := transcriptExchange{
+		Time:       started.UTC(),
+		DurationMS: time.Since(started).Milliseconds(),
+		Request: transcriptMessage{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: t.headers(req.Header),
+			Body:    t.body(reqBody),
+		},
+	}
+	if resp != nil {
+		ex.Response = &transcriptMessage{
+			Status:  resp.StatusCode,
+			Headers: t.headers(resp.Header),
+			Body:    t.body(respBody),
+		}
+	}
+	if err != nil {
+		ex.Error = t.policy.redact(err.Error())
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Exchanges = append(t.Exchanges, ex)
+
+	if err := t.save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not write transcript: %v\n", err)
+	}
+}
+
+func (t *transcript) save() error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	// Transcripts of long sessions are large; a .gz path compresses them
+	if strings.HasSuffix(t.path, ".gz") {
+		if data, err = gzipBytes(data); err != nil {
+			return err
+		}
+	}
+	// Transcripts hold source code and review output, so keep them private
+	return os.WriteFile(t.path, data, 0600)
+}
+
+// headers returns a redacted copy of h without credentials
+func (t *transcript) headers(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		if secretHeader(name) {
+			continue
+		}
+		for _, v := range values {
+			out.Add(name, t.policy.redact(v))
+		}
+	}
+	return out
+}
+
+// body returns a JSON body with the redaction rules applied to every string
+// in it. A body that isn't JSON (e.g. a proxy error page) is stored as a
+// JSON string.
+func (t *transcript) body(data []byte) json.RawMessage {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		quoted, _ := json.Marshal(t.policy.redact(string(data)))
+		return quoted
+	}
+	redacted, err := json.Marshal(t.redactValue(v))
+	if err != nil {
+		quoted, _ := json.Marshal(t.policy.redact(string(data)))
+		return quoted
+	}
+	return redacted
+}
+
+func (t *transcript) redactValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return t.policy.redact(v)
+	case []any:
+		for i := range v {
+			v[i] = t.redactValue(v[i])
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = t.redactValue(v[k])
+		}
+	}
+	return v
+}
+
+// String summarizes the transcript for progress output
+func (t *transcript) String() string {
+	return fmt.Sprintf("%s (%s)", t.path, plural(len(t.Exchanges), "exchange"))
+}
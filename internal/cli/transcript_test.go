@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTranscript_Record tests that exchanges are saved with redaction applied
+// and without credentials
+func TestTranscript_Record(t *testing.T) {
+	policy, err := parsePolicy([]byte(`redact: ["secret-[0-9]+"]`))
+	if err != nil {
+		t.Fatalf("parsePolicy() returned error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "transcript.json")
+	tr := newTranscript(path, policy)
+
+	req, _ := http.NewRequest("POST", "https://api.anthropic.com/v1/messages", nil)
+	req.Header.Set("x-api-key", "sk-ant-do-not-log")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	reqBody := []byte(`{"model":"m","messages":[{"role":"user","content":"token secret-123"}]}`)
+	resp := &http.Response{StatusCode: 200, Header: http.Header{"Request-Id": {"req_abc"}}}
+	respBody := []byte(`{"content":[{"type":"thinking","thinking":"saw secret-456"},{"type":"text","text":"ok"}],"extra":1}`)
+
+	tr.Record(req, reqBody, resp, respBody, time.Now(), nil)
+	tr.Record(req, reqBody, nil, nil, time.Now(), errors.New("dial secret-789: refused"))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("transcript not written: %v", err)
+	}
+	text := string(data)
+	for _, leaked := range []string{"sk-ant-do-not-log", "secret-123", "secret-456", "secret-789"} {
+		if strings.Contains(text, leaked) {
+			t.Errorf("transcript contains %q", leaked)
+		}
+	}
+
+	var saved transcript
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("transcript is not valid JSON: %v", err)
+	}
+	if len(saved.Exchanges) != 2 {
+		t.Fatalf("got %d exchanges, want 2", len(saved.Exchanges))
+	}
+	first := saved.Exchanges[0]
+	if first.Response == nil || first.Response.Headers.Get("Request-Id") != "req_abc" {
+		t.Errorf("response request-id missing: %+v", first.Response)
+	}
+	if first.Request.Headers.Get("Anthropic-Version") != "2023-06-01" {
+		t.Error("request headers missing")
+	}
+	var body bytes.Buffer
+	json.Compact(&body, first.Response.Body)
+	if !strings.Contains(body.String(), `"thinking":"saw [REDACTED]"`) || !strings.Contains(body.String(), `"extra":1`) {
+		t.Errorf("response body not kept verbatim apart from redaction: %s", body.String())
+	}
+	if second := saved.Exchanges[1]; second.Response != nil || second.Error != "dial [REDACTED]: refused" {
+		t.Errorf("failed exchange = %+v", second)
+	}
+
+	// Windows has no Unix permission bits to check
+	if info, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("transcript mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+// TestTranscript_NonJSONBody tests that a non-JSON body is kept as a string
+func TestTranscript_NonJSONBody(t *testing.T) {
+	tr := newTranscript(filepath.Join(t.TempDir(), "t.json"), nil)
+	var s string
+	if err := json.Unmarshal(tr.body([]byte("<html>bad gateway</html>")), &s); err != nil || s != "<html>bad gateway</html>" {
+		t.Errorf("body() = %q, %v", s, err)
+	}
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// maxLogBytes bounds how much of a CI log is sent to the model
+const maxLogBytes = 100 * 1024
+
+// logErrorPattern matches lines that typically explain a build or test failure
+var logErrorPattern = regexp.MustCompile(`(?i)(\berror\b|\bfail(ed|ure)?\b|panic:|fatal|exception|undefined:|cannot |--- FAIL)`)
+
+// runTriage implements `pr-review triage`: given a failing CI log, identify
+// which change on the branch most likely broke the build and how to fix it
+func runTriage(args []string) {
+	fs := flag.NewFlagSet("triage", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	logFile := fs.String("log", "", "Failing CI build/test log to triage (required)")
+	outputFile := fs.String("output", "TRIAGE.md", "Output file for the triage report (will create numbered backups if exists)")
+	parseFlags(fs, fs.Name(), args)
+
+	if *logFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: triage requires -log")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	logData, err := os.ReadFile(*logFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading log: %v\n", err)
+		os.Exit(1)
+	}
+
+	client, policy := common.provider()
+
+	baseRef := common.baseRef()
+	fmt.Printf("🔍 Triaging %s against changes on '%s' since '%s'\n\n", *logFile, getCurrentBranch(), baseRef)
+
+	changes, err := collectChanges(baseRef, "HEAD")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting diff: %v\n", err)
+		os.Exit(1)
+	}
+	if changes.Diff == "" {
+		fmt.Fprintln(os.Stderr, "Warning: No changes found on this branch; the failure may not be caused by it.")
+	}
+
+	prompt := policy.redact(buildTriagePrompt(logExcerpt(string(logData), maxLogBytes), changes, common.readContext(client, policy)))
+
+	fmt.Printf("🤖 Asking %s which change broke the build...\n", client.Name())
+	fmt.Println()
+
+	report, usage, err := client.Complete(prompt, common.completionOptions())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error calling %s API: %v\n", client.Name(), err)
+		os.Exit(1)
+	}
+
+	if err := writeReviewToFile(*outputFile, report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing triage report to file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Triage report written to: %s\n\n", *outputFile)
+	common.printTranscript()
+
+	printReport("CI FAILURE TRIAGE", report, usage)
+}
+
+func buildTriagePrompt(log string, changes *branchChanges, additionalContext string) string {
+	prompt := `You are an expert at diagnosing CI failures. A CI build or test run failed on
+this branch. Using the failure log and the branch's changes below:
+
+1. **Root Cause**: Identify the error(s) in the log that caused the failure.
+2. **Culprit Change**: Name the file(s), hunk(s) and commit(s) in the diff that most
+   likely introduced the failure, explaining the causal link. If the failure looks
+   unrelated to the changes (flaky test, infrastructure, dependency outage), say so.
+3. **Fix**: Propose a concrete fix, with code where possible.
+4. **Confidence**: State how confident you are and what would confirm the diagnosis.
+
+---
+
+## CI Log
+` + "```\n" + log + "\n```\n\n"
+
+	prompt += "## Changed Files\n```\n" + changes.ChangedFiles + "\n```\n\n"
+	if changes.CommitMessages != "" {
+		prompt += "## Recent Commit Messages\n```\n" + changes.CommitMessages + "\n```\n\n"
+	}
+	prompt += "## Full Diff\n```diff\n" + changes.Diff + "\n```\n"
+
+	if additionalContext != "" {
+		prompt += "\n## Additional Context\n" + additionalContext + "\n"
+	}
+
+	prompt += "\n\nPlease provide your diagnosis."
+	return prompt
+}
+
+// logExcerpt trims a CI log to at most maxBytes. Failures are usually
+// reported at the end of a log, so the tail is kept; error-looking lines from
+// the dropped head are kept too, since the first error is often the cause.
+func logExcerpt(log string, maxBytes int) string {
+	if len(log) <= maxBytes {
+		return log
+	}
+
+	tailBudget := maxBytes * 3 / 4
+	tail := log[len(log)-tailBudget:]
+	if i := strings.IndexByte(tail, '\n'); i != -1 {
+		tail = tail[i+1:]
+	}
+	head := log[:len(log)-len(tail)]
+
+	var errors []string
+	size := 0
+	for _, line := range strings.Split(head, "\n") {
+		if !logErrorPattern.MatchString(line) {
+			continue
+		}
+		if size+len(line)+1 > maxBytes-tailBudget {
+			break
+		}
+		errors = append(errors, line)
+		size += len(line) + 1
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[log truncated: %d of %d bytes omitted", len(head), len(log))
+	if len(errors) > 0 {
+		b.WriteString("; error lines from the omitted part follow]\n")
+		b.WriteString(strings.Join(errors, "\n"))
+		b.WriteString("\n[end of log follows]\n")
+	} else {
+		b.WriteString("]\n")
+	}
+	b.WriteString(tail)
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLogExcerpt_Short tests that short logs are kept whole
+func TestLogExcerpt_Short(t *testing.T) {
+	log := "go build ./...\nok\n"
+	if got := logExcerpt(log, 1024); got != log {
+		t.Errorf("logExcerpt() = %q, want the log unchanged", got)
+	}
+}
+
+// TestLogExcerpt_Long tests that long logs keep their tail and earlier errors
+func TestLogExcerpt_Long(t *testing.T) {
+	var lines []string
+	lines = append(lines, "step 1: compile")
+	lines = append(lines, "main.go:12:2: undefined: frobnicate")
+	for i := 0; i < 500; i++ {
+		lines = append(lines, "downloading dependency github.com/example/module v1.0.0")
+	}
+	lines = append(lines, "--- FAIL: TestParse (0.00s)")
+	lines = append(lines, "FAIL\texample.com/app\t0.2s")
+	log := strings.Join(lines, "\n")
+
+	got := logExcerpt(log, 4096)
+	if len(got) > 4096+200 {
+		t.Errorf("logExcerpt() returned %d bytes, want about 4096", len(got))
+	}
+	if !strings.HasPrefix(got, "[log truncated:") {
+		t.Errorf("logExcerpt() does not note the truncation:\n%s", got[:100])
+	}
+	if !strings.Contains(got, "undefined: frobnicate") {
+		t.Error("logExcerpt() dropped the first error from the head of the log")
+	}
+	if !strings.HasSuffix(got, "FAIL\texample.com/app\t0.2s") {
+		t.Error("logExcerpt() did not keep the end of the log")
+	}
+}
+
+// TestBuildTriagePrompt tests the sections of the triage prompt
+func TestBuildTriagePrompt(t *testing.T) {
+	changes := &branchChanges{
+		Diff:           "+broken()",
+		ChangedFiles:   "M\tmain.go",
+		CommitMessages: "abc123 - Add broken call",
+	}
+	prompt := buildTriagePrompt("undefined: broken", changes, "")
+
+	for _, want := range []string{"## CI Log", "undefined: broken", "## Changed Files", "M\tmain.go",
+		"## Recent Commit Messages", "## Full Diff", "+broken()", "Culprit Change"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("triage prompt is missing %q", want)
+		}
+	}
+	if strings.Contains(prompt, "## Additional Context") {
+		t.Error("triage prompt has an empty Additional Context section")
+	}
+}
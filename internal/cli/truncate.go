@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/marete/pr-review/pkg/gitdiff"
+)
+
+// Steps that shrink a diff larger than -max-diff-tokens (-truncate), each
+// losing more than the one before
+const (
+	// truncateContext keeps only the context line next to each change
+	truncateContext = "context"
+	// truncateHunks drops the largest hunks, leaving a note in their place
+	truncateHunks = "hunks"
+	// truncateFiles drops the largest files, leaving their header and a
+	// note
+	truncateFiles = "files"
+)
+
+var truncateSteps = []string{truncateContext, truncateHunks, truncateFiles}
+
+// defaultTruncate are the steps taken by default, in order
+const defaultTruncate = truncateContext + "," + truncateFiles
+
+// truncatedContext is how many lines of context truncateContext keeps on
+// each side of a change
+const truncatedContext = 1
+
+// parseTruncateSteps parses a comma-separated list of -truncate steps
+func parseTruncateSteps(list string) ([]string, error) {
+	var steps []string
+	for _, step := range strings.Split(list, ",") {
+		step = strings.TrimSpace(step)
+		if !containsString(truncateSteps, step) {
+			return nil, fmt.Errorf("invalid truncation step %q (want a comma-separated list of: %s)", step, strings.Join(truncateSteps, ", "))
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// truncateDiff shrinks the diff of changes to at most maxTokens estimated
+// tokens by taking steps in order until it fits. It returns what each step
+// left out, and whether the diff fits.
+func truncateDiff(changes *branchChanges, maxTokens int, steps []string) ([]string, bool) {
+	files := gitdiff.Split(changes.Diff)
+	var omitted []string
+	for _, step := range steps {
+		if diffTokens(gitdiff.Join(files)) <= maxTokens {
+			break
+		}
+		var notes []string
+		switch step {
+		case truncateContext:
+			notes = trimContext(files)
+		case truncateHunks:
+			notes = dropHunks(files, maxTokens)
+		case truncateFiles:
+			notes = dropFiles(files, maxTokens)
+		}
+		omitted = append(omitted, notes...)
+	}
+	diff := gitdiff.Join(files)
+	if diff != "" && strings.HasSuffix(changes.Diff, "\n") && !strings.HasSuffix(diff, "\n") {
+		diff += "\n"
+	}
+	changes.Diff = diff
+	return omitted, diffTokens(diff) <= maxTokens
+}
+
+// joinHunks reassembles a file section from its header and hunks, each
+// hunk replaced by its note if it has one
+func joinHunks(header string, hunks []gitdiff.Hunk, notes map[int]string) string {
+	parts := []string{header}
+	for i, h := range hunks {
+		if note, ok := notes[i]; ok {
+			parts = append(parts, encodingNotePrefix+note)
+			continue
+		}
+		parts = append(parts, h.String())
+	}
+	return strings.Join(parts, "\n") + "\n"
+}
+
+// trimContext keeps only truncatedContext lines of context around each
+// change in files, splitting hunks where it drops lines
+func trimContext(files []gitdiff.File) []string {
+	trimmed := 0
+	for i, f := range files {
+		header, hunks := gitdiff.Hunks(f)
+		if hunks == nil {
+			continue
+		}
+		var kept []gitdiff.Hunk
+		for _, h := range hunks {
+			near := make([]bool, len(h.Lines))
+			for j, line := range h.Lines {
+				if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+					for k := max(j-truncatedContext, 0); k <= min(j+truncatedContext, len(h.Lines)-1); k++ {
+						near[k] = true
+					}
+				}
+			}
+			for j := 0; j < len(h.Lines); {
+				if !near[j] && !strings.HasPrefix(h.Lines[j], `\`) {
+					trimmed++
+					j++
+					continue
+				}
+				k := j
+				for k < len(h.Lines) && (near[k] || strings.HasPrefix(h.Lines[k], `\`)) {
+					k++
+				}
+				kept = append(kept, h.Slice(j, k))
+				j = k
+			}
+		}
+		files[i].Text = joinHunks(header, kept, nil)
+	}
+	if trimmed == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("%d context lines more than %d line from a change", trimmed, truncatedContext)}
+}
+
+// dropHunks replaces the largest hunks of files with a note until the diff
+// is at most maxTokens, returning the notes
+func dropHunks(files []gitdiff.File, maxTokens int) []string {
+	type hunkRef struct {
+		file, hunk, tokens int
+	}
+	headers := make([]string, len(files))
+	hunks := make([][]gitdiff.Hunk, len(files))
+	var refs []hunkRef
+	for i, f := range files {
+		headers[i], hunks[i] = gitdiff.Hunks(f)
+		for j, h := range hunks[i] {
+			refs = append(refs, hunkRef{i, j, diffTokens(h.String())})
+		}
+	}
+	sort.SliceStable(refs, func(a, b int) bool { return refs[a].tokens > refs[b].tokens })
+
+	total := diffTokens(gitdiff.Join(files))
+	dropped := make([]map[int]string, len(files))
+	var omitted []string
+	for _, r := range refs {
+		if total <= maxTokens {
+			break
+		}
+		h := hunks[r.file][r.hunk]
+		added, removed := changedLines(h)
+		note := fmt.Sprintf("%s: the hunk at line %d, +%d -%d lines", files[r.file].Path, h.NewStart, added, removed)
+		if dropped[r.file] == nil {
+			dropped[r.file] = make(map[int]string)
+		}
+		dropped[r.file][r.hunk] = note + ", omitted"
+		omitted = append(omitted, note)
+		total -= r.tokens - diffTokens(encodingNotePrefix+note)
+	}
+	for i := range files {
+		if dropped[i] != nil {
+			files[i].Text = joinHunks(headers[i], hunks[i], dropped[i])
+		}
+	}
+	return omitted
+}
+
+// dropFiles replaces the largest files' sections with their header and a
+// note until the diff is at most maxTokens, returning the notes
+func dropFiles(files []gitdiff.File, maxTokens int) []string {
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return len(files[order[a]].Text) > len(files[order[b]].Text) })
+
+	total := diffTokens(gitdiff.Join(files))
+	var omitted []string
+	for _, i := range order {
+		if total <= maxTokens {
+			break
+		}
+		header, hunks := gitdiff.Hunks(files[i])
+		if hunks == nil {
+			continue
+		}
+		added, removed := changedLines(hunks...)
+		note := fmt.Sprintf("%s: +%d -%d lines", files[i].Path, added, removed)
+		text := header + "\n" + encodingNotePrefix + note + ", omitted\n"
+		total -= diffTokens(files[i].Text) - diffTokens(text)
+		files[i].Text = text
+		omitted = append(omitted, note)
+	}
+	return omitted
+}
+
+// truncatedInstructions tells the review what was left out of the diff
+// to fit it in -max-diff-tokens
+func truncatedInstructions(omitted []string) string {
+	var b strings.Builder
+	b.WriteString("The diff was too large to send whole, so these parts of it were left out; notes starting with \"" + strings.TrimSpace(encodingNotePrefix) + "\" mark where. Don't report issues only because of code you can't see.\n\n")
+	for _, o := range omitted {
+		fmt.Fprintf(&b, "- %s\n", o)
+	}
+	return b.String()
+}
+
+// renderTruncation formats what was left out of the diff as a markdown
+// section of the report
+func renderTruncation(omitted []string, maxTokens int) string {
+	var b strings.Builder
+	b.WriteString("## " + tr("Left Out of the Diff") + "\n\n")
+	b.WriteString(tr("The diff was over -max-diff-tokens (%d), so the review didn't see:", maxTokens) + "\n\n")
+	for _, o := range omitted {
+		fmt.Fprintf(&b, "- %s\n", o)
+	}
+	return b.String()
+}
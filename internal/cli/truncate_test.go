@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// hunkDiff returns the diff of a file with a hunk per entry of changed,
+// each changing that many lines amid 10 lines of context
+func hunkDiff(path string, changed ...int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", path, path, path, path)
+	for i, n := range changed {
+		start := i*1000 + 1
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", start, 10+n, start, 10+n)
+		for j := range 5 {
+			fmt.Fprintf(&b, " context line %d of the code around the change\n", j)
+		}
+		for j := range n {
+			fmt.Fprintf(&b, "-removed line %d\n+added line %d\n", j, j)
+		}
+		for j := range 5 {
+			fmt.Fprintf(&b, " context line %d after the change\n", j)
+		}
+	}
+	return b.String()
+}
+
+// TestTruncateDiff tests shrinking a diff step by step until it fits
+func TestTruncateDiff(t *testing.T) {
+	diff := hunkDiff("big.go", 40, 2) + hunkDiff("small.go", 1)
+
+	// Trimming context is enough for a limit a little under the diff
+	changes := &branchChanges{Diff: diff}
+	omitted, fits := truncateDiff(changes, diffTokens(diff)-50, []string{truncateContext, truncateFiles})
+	if !fits || len(omitted) != 1 || !strings.HasPrefix(omitted[0], "24 context lines") {
+		t.Errorf("truncateDiff() = %q, %v", omitted, fits)
+	}
+	if !strings.Contains(changes.Diff, "@@ -5,42 +5,42 @@\n context line 4") || strings.Contains(changes.Diff, "context line 3") {
+		t.Errorf("diff with trimmed context:\n%s", changes.Diff)
+	}
+
+	// Dropping hunks drops the largest first
+	changes = &branchChanges{Diff: diff}
+	omitted, fits = truncateDiff(changes, 400, []string{truncateHunks})
+	if !fits || len(omitted) != 1 || omitted[0] != "big.go: the hunk at line 1, +40 -40 lines" {
+		t.Errorf("truncateDiff() by hunk = %q, %v", omitted, fits)
+	}
+	if !strings.Contains(changes.Diff, "+++ b/big.go\n# pr-review: big.go: the hunk at line 1, +40 -40 lines, omitted\n@@ -1001,12") {
+		t.Errorf("diff with a dropped hunk:\n%s", changes.Diff)
+	}
+
+	// Dropping files keeps their header
+	changes = &branchChanges{Diff: diff}
+	omitted, fits = truncateDiff(changes, 400, []string{truncateFiles})
+	if !fits || len(omitted) != 1 || omitted[0] != "big.go: +42 -42 lines" || !strings.Contains(changes.Diff, "diff --git a/small.go") {
+		t.Errorf("truncateDiff() by file = %q, %v:\n%s", omitted, fits, changes.Diff)
+	}
+
+	// A diff that can't be made to fit is reported
+	changes = &branchChanges{Diff: diff}
+	if _, fits := truncateDiff(changes, 10, []string{truncateContext}); fits {
+		t.Error("truncateDiff() fit a diff into 10 tokens by trimming context")
+	}
+}
+
+// TestParseTruncateSteps tests parsing -truncate
+func TestParseTruncateSteps(t *testing.T) {
+	if steps, err := parseTruncateSteps(defaultTruncate); err != nil || strings.Join(steps, ",") != "context,files" {
+		t.Errorf("parseTruncateSteps(%q) = %q, %v", defaultTruncate, steps, err)
+	}
+	if _, err := parseTruncateSteps("context,lines"); err == nil {
+		t.Error("parseTruncateSteps() accepted an unknown step")
+	}
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Outcomes of one unit of a run made of several: a patch of a series, a
+// model of -compare or a part of a split review
+const (
+	unitSucceeded = "succeeded"
+	unitFailed    = "failed"
+	unitSkipped   = "skipped"
+)
+
+// unitResult is the outcome of one unit, listed in the report and the
+// status file
+type unitResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// addUnitFlags adds -fail-fast and -keep-going, which set whether a run of
+// several units stops at the first that fails. Keeping going is the
+// default; the flag that comes last wins.
+func addUnitFlags(fs *flag.FlagSet) *bool {
+	failFast := fs.Bool("fail-fast", false, "Stop at the first patch, -compare model or part of a split review that fails and skip the rest, instead of reporting on those that succeed")
+	fs.BoolFunc("keep-going", "Review the remaining patches, -compare models or parts of a split review when one fails and list the failures in the report (the default; undoes -fail-fast)", func(value string) error {
+		keep, err := strconv.ParseBool(value)
+		*failFast = !keep
+		return err
+	})
+	return failFast
+}
+
+// countUnits returns how many units succeeded, failed and were skipped
+func countUnits(units []unitResult) (succeeded, failed, skipped int) {
+	for _, u := range units {
+		switch u.Status {
+		case unitSucceeded:
+			succeeded++
+		case unitFailed:
+			failed++
+		case unitSkipped:
+			skipped++
+		}
+	}
+	return succeeded, failed, skipped
+}
+
+// renderUnits formats the outcome of each unit as a report section, or
+// returns "" if every unit succeeded
+func renderUnits(units []unitResult) string {
+	succeeded, failed, skipped := countUnits(units)
+	if failed == 0 && skipped == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("## " + tr("Run Summary") + "\n\n")
+	b.WriteString(tr("%d succeeded, %d failed, %d skipped.", succeeded, failed, skipped) + "\n\n")
+	for _, u := range units {
+		switch u.Status {
+		case unitFailed:
+			fmt.Fprintf(&b, "- ❌ %s: %s\n", u.Name, tr("failed: %s", u.Error))
+		case unitSkipped:
+			fmt.Fprintf(&b, "- ⏭️ %s: %s\n", u.Name, tr("skipped after an earlier failure"))
+		default:
+			fmt.Fprintf(&b, "- ✅ %s\n", u.Name)
+		}
+	}
+	return b.String()
+}
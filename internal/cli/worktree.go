@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// addWorktree checks out ref in a new detached worktree under the temp
+// directory, so commands can run against exactly the reviewed commit without
+// touching the user's working tree or another run's checkout. cleanup
+// removes the worktree and must be called once the commands are done.
+func addWorktree(ref string) (dir string, cleanup func(), err error) {
+	dir, err = os.MkdirTemp("", "pr-review-worktree-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+
+	cmd := gitCommand("worktree", "add", "--detach", "--quiet", dir, ref)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("failed to add worktree for %s: %s", ref, strings.TrimSpace(string(output)))
+	}
+
+	cleanup = func() {
+		if output, err := gitCommand("worktree", "remove", "--force", dir).CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not remove worktree %s: %s\n", dir, strings.TrimSpace(string(output)))
+			// Drop the directory and git's record of it anyway, so a
+			// failed removal doesn't leave a stale worktree registered
+			os.RemoveAll(dir)
+			gitCommand("worktree", "prune").Run()
+		}
+	}
+	return dir, cleanup, nil
+}
+
+// applyUncommitted applies the uncommitted changes to the worktree in dir,
+// which holds HEAD, so it matches what a review of -staged or -working-tree
+// covers: the index with staged, or otherwise the whole working tree.
+// Untracked files aren't part of either review and aren't copied.
+func applyUncommitted(dir string, staged bool) error {
+	args := []string{"diff", "--binary", "HEAD"}
+	if staged {
+		args = []string{"diff", "--binary", "--cached"}
+	}
+	patch, err := gitCommand(args...).Output()
+	if err != nil {
+		return fmt.Errorf("failed to get the uncommitted changes: %w", err)
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	cmd := gitCommand("-C", dir, "apply", "--whitespace=nowarn")
+	cmd.Stdin = bytes.NewReader(patch)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply the uncommitted changes to the worktree: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAddWorktree tests that a worktree holds the committed head rather than
+// the dirty working tree, and that cleanup unregisters it
+func TestAddWorktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := gitCommand(append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+
+	git("init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(repo, "app.go"), []byte("package app\n"), 0644)
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	// Uncommitted edits must not leak into the checks
+	os.WriteFile(filepath.Join(repo, "app.go"), []byte("package app\n\nsyntax error\n"), 0644)
+	t.Chdir(repo)
+
+	dir, cleanup, err := addWorktree("HEAD")
+	if err != nil {
+		t.Fatalf("addWorktree() returned error: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "app.go"))
+	if err != nil {
+		t.Fatalf("reading worktree: %v", err)
+	}
+	if string(content) != "package app\n" {
+		t.Errorf("worktree app.go = %q, want the committed content", content)
+	}
+	if status := git("status", "--porcelain"); !strings.Contains(status, "app.go") {
+		t.Errorf("working tree edits were lost: status = %q", status)
+	}
+
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("worktree directory still exists after cleanup: %v", err)
+	}
+	if list := git("worktree", "list", "--porcelain"); strings.Count(list, "worktree ") != 1 {
+		t.Errorf("worktree still registered after cleanup:\n%s", list)
+	}
+
+	if _, _, err := addWorktree("no-such-ref"); err == nil {
+		t.Error("addWorktree() with an unknown ref returned nil error")
+	}
+}
+
+// TestApplyUncommitted tests that a worktree of HEAD gets the staged changes,
+// or with the unstaged ones the whole working tree, as the review sees them
+func TestApplyUncommitted(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := gitCommand(append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_CONFIG_NOSYSTEM=1",
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	git("init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(repo, "app.go"), []byte("package app\n\nfunc f() {}\n"), 0644)
+	git("add", ".")
+	git("commit", "-q", "-m", "base")
+	os.WriteFile(filepath.Join(repo, "app.go"), []byte("package app\n\nfunc g() {}\n"), 0644)
+	git("add", "app.go")
+	os.WriteFile(filepath.Join(repo, "app.go"), []byte("package app\n\nfunc h() {}\n"), 0644)
+	t.Chdir(repo)
+
+	for _, tt := range []struct {
+		staged bool
+		want   string
+	}{
+		{true, "package app\n\nfunc g() {}\n"},
+		{false, "package app\n\nfunc h() {}\n"},
+	} {
+		dir, cleanup, err := addWorktree("HEAD")
+		if err != nil {
+			t.Fatalf("addWorktree() returned error: %v", err)
+		}
+		if err := applyUncommitted(dir, tt.staged); err != nil {
+			t.Errorf("applyUncommitted(staged %v) returned error: %v", tt.staged, err)
+		} else if content, _ := os.ReadFile(filepath.Join(dir, "app.go")); string(content) != tt.want {
+			t.Errorf("applyUncommitted(staged %v): app.go = %q, want %q", tt.staged, content, tt.want)
+		}
+		cleanup()
+	}
+}
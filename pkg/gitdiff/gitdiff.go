@@ -0,0 +1,389 @@
+// Package gitdiff collects the changes under review from git and splits
+// unified diffs into the files and lines they change
+package gitdiff
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Git returns a command running git with args. It lets the caller choose
+// the executable, working directory and environment, e.g.
+//
+//	gitdiff.Git(func(args ...string) *exec.Cmd { return exec.Command("git", args...) })
+type Git func(args ...string) *exec.Cmd
+
+// Diff returns the diff of head against its merge base with base. Options
+// such as --function-context are passed on to git diff.
+func (g Git) Diff(base, head string, options ...string) (string, error) {
+	return g.DiffRange(base+"..."+head, options...)
+}
+
+// DiffRange returns the diff of a revision range as git diff reads it:
+// "A..B" is the diff between A and B, "A...B" of B against its merge base
+// with A
+func (g Git) DiffRange(revisions string, options ...string) (string, error) {
+	args := append(append([]string{"diff"}, options...), revisions)
+	output, err := g(args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// Uncommitted returns the diff of the changes staged in the index against
+// HEAD, as git diff --cached shows them, or with staged false of the
+// working tree's changes not yet staged, as git diff does, and the files
+// they change with their status
+func (g Git) Uncommitted(staged bool, options ...string) (diff, changedFiles string, err error) {
+	args := []string{"diff"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	output, err := g(append(args, options...)...).Output()
+	if err != nil {
+		return "", "", err
+	}
+	files, err := g(append(args, "--name-status")...).Output()
+	if err != nil {
+		return "", "", err
+	}
+	return string(output), strings.TrimSpace(string(files)), nil
+}
+
+// ChangedFiles lists the files head changes since its merge base with base,
+// with their status, as git diff --name-status does
+func (g Git) ChangedFiles(base, head string) string {
+	return g.ChangedFilesRange(base + "..." + head)
+}
+
+// ChangedFilesRange lists the files a revision range changes, as read by
+// DiffRange, with their status
+func (g Git) ChangedFilesRange(revisions string) string {
+	output, err := g("diff", "--name-status", revisions).Output()
+	if err != nil {
+		return "Error getting changed files"
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Log lists the commits on head that aren't on base, newest first, one per
+// line as "<sha> - <subject> (<author>, <date>)". The date is the day the
+// commit was authored rather than how long ago, so the log of the same
+// commits reads the same on every run.
+func (g Git) Log(base, head string) string {
+	output, err := g("log", base+".."+head, "--pretty=format:%h - %s (%an, %as)").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// CommitFiles lists the files a commit changes. It lists none for a merge.
+func (g Git) CommitFiles(sha string) ([]string, error) {
+	output, err := g("diff-tree", "--no-commit-id", "--name-only", "-r", "--root", sha).Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// Show returns the contents of the file at path in rev
+func (g Git) Show(rev, path string) (string, error) {
+	output, err := g("show", rev+":"+path).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// BlobSize returns the size in bytes of the file at path in rev
+func (g Git) BlobSize(rev, path string) (int64, error) {
+	output, err := g("cat-file", "-s", rev+":"+path).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+}
+
+// Attr returns the value of a git attribute, as set in .gitattributes, for
+// each of paths: "set", "unset", "unspecified" or the value it is set to
+func (g Git) Attr(attr string, paths []string) (map[string]string, error) {
+	values := make(map[string]string)
+	if len(paths) == 0 {
+		return values, nil
+	}
+	output, err := g(append([]string{"check-attr", "-z", attr, "--"}, paths...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	// Each path is reported as path NUL attribute NUL value NUL
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		values[fields[i]] = fields[i+2]
+	}
+	return values, nil
+}
+
+// Message returns the full commit message of rev
+func (g Git) Message(rev string) (string, error) {
+	output, err := g("log", "-1", "--format=%B", rev).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// IsAncestor reports whether ancestor is an ancestor of rev, or rev itself
+func (g Git) IsAncestor(ancestor, rev string) bool {
+	return g("merge-base", "--is-ancestor", ancestor, rev).Run() == nil
+}
+
+// File is the part of a unified git diff that changes one file
+type File struct {
+	Path string
+	Text string
+}
+
+// Split splits a git diff into per-file sections, in order
+func Split(diff string) []File {
+	var files []File
+	var current []string
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		text := strings.Join(current, "\n")
+		files = append(files, File{Path: sectionPath(current), Text: text})
+		current = nil
+	}
+
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	// Keep the trailing newline on the final section so sections join back
+	// into the original diff
+	if len(files) > 0 && strings.HasSuffix(diff, "\n") {
+		files[len(files)-1].Text += "\n"
+	}
+	return files
+}
+
+// Join reassembles per-file sections into a single diff
+func Join(files []File) string {
+	var b strings.Builder
+	for i, f := range files {
+		b.WriteString(f.Text)
+		if i < len(files)-1 && !strings.HasSuffix(f.Text, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// sectionPath returns the path a file section changes, from its ---/+++ lines
+// or, for binary and mode-only changes, its "diff --git" header. Deleted
+// files are reported by their old path.
+func sectionPath(lines []string) string {
+	oldPath, newPath := "", ""
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			oldPath = strings.TrimPrefix(strings.TrimPrefix(line, "--- "), "a/")
+		case strings.HasPrefix(line, "+++ "):
+			newPath = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+		case strings.HasPrefix(line, "@@"):
+			// Past the header; a "--- " line here is a removed line
+			if newPath == "/dev/null" {
+				return oldPath
+			}
+			if newPath != "" {
+				return newPath
+			}
+		}
+	}
+	if newPath == "/dev/null" {
+		return oldPath
+	}
+	if newPath != "" {
+		return newPath
+	}
+	if len(lines) > 0 {
+		header := strings.TrimPrefix(strings.TrimSuffix(lines[0], "\r"), "diff --git ")
+		if i := strings.LastIndex(header, " b/"); i != -1 {
+			return header[i+3:]
+		}
+	}
+	return ""
+}
+
+// Files returns the paths of the files changed in a unified git diff, in
+// the order they appear. Deleted files are reported by their old path.
+func Files(diff string) []string {
+	var files []string
+	for _, f := range Split(diff) {
+		files = append(files, f.Path)
+	}
+	return files
+}
+
+// Line is a line added by a diff, with its line number in the new file
+type Line struct {
+	File string
+	Line int
+	Text string
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// Added returns the lines a file section adds, numbered as in the new
+// version of the file
+func Added(f File) []Line {
+	var added []Line
+	line := 0
+	inHunk := false
+	for _, text := range strings.Split(f.Text, "\n") {
+		// Files with CRLF line endings keep the \r in the diff
+		text = strings.TrimSuffix(text, "\r")
+		if m := hunkHeader.FindStringSubmatch(text); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			inHunk = true
+			continue
+		}
+		if !inHunk {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(text, "+"):
+			added = append(added, Line{File: f.Path, Line: line, Text: text[1:]})
+			line++
+		case strings.HasPrefix(text, " "):
+			line++
+		}
+	}
+	return added
+}
+
+// HunkLines returns the line numbers of the new version of a file that a
+// patch shows (added and context lines), which are the lines a review
+// comment can be anchored to
+func HunkLines(patch string) map[int]bool {
+	lines := make(map[int]bool)
+	line := 0
+	inHunk := false
+	for _, text := range strings.Split(patch, "\n") {
+		text = strings.TrimSuffix(text, "\r")
+		if m := hunkHeader.FindStringSubmatch(text); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			inHunk = true
+			continue
+		}
+		if !inHunk {
+			continue
+		}
+		if strings.HasPrefix(text, "+") || strings.HasPrefix(text, " ") {
+			lines[line] = true
+			line++
+		}
+	}
+	return lines
+}
+
+// Size counts the lines a diff adds and removes
+func Size(diff string) int {
+	n, inHunk := 0, false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			inHunk = false
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case inHunk && (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")):
+			n++
+		}
+	}
+	return n
+}
+
+// Hunk is a hunk of a file section: a run of changed lines with their
+// context, starting at OldStart in the old version of the file and
+// NewStart in the new one
+type Hunk struct {
+	OldStart, NewStart int
+	// Section is the text git shows after the closing @@, usually the
+	// enclosing function
+	Section string
+	// Lines are the hunk's context (" "), removed ("-") and added ("+")
+	// lines, and any "\ No newline at end of file" markers
+	Lines []string
+}
+
+var hunkRange = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@(.*)$`)
+
+// Hunks splits a file section into its header, the lines before the first
+// hunk, and its hunks
+func Hunks(f File) (header string, hunks []Hunk) {
+	var head []string
+	for _, line := range strings.Split(strings.TrimSuffix(f.Text, "\n"), "\n") {
+		if m := hunkRange.FindStringSubmatch(strings.TrimSuffix(line, "\r")); m != nil {
+			h := Hunk{Section: m[3]}
+			h.OldStart, _ = strconv.Atoi(m[1])
+			h.NewStart, _ = strconv.Atoi(m[2])
+			hunks = append(hunks, h)
+			continue
+		}
+		if len(hunks) == 0 {
+			head = append(head, line)
+			continue
+		}
+		h := &hunks[len(hunks)-1]
+		h.Lines = append(h.Lines, line)
+	}
+	return strings.Join(head, "\n"), hunks
+}
+
+// counts returns how many lines of the old and new file the hunk covers
+func (h Hunk) counts() (old, new int) {
+	for _, line := range h.Lines {
+		switch {
+		case strings.HasPrefix(line, " "):
+			old++
+			new++
+		case strings.HasPrefix(line, "-"):
+			old++
+		case strings.HasPrefix(line, "+"):
+			new++
+		}
+	}
+	return old, new
+}
+
+// NewEnd returns the last line of the new file the hunk covers
+func (h Hunk) NewEnd() int {
+	_, n := h.counts()
+	return h.NewStart + max(n, 1) - 1
+}
+
+// String formats the hunk as in a diff, its header counting its lines
+func (h Hunk) String() string {
+	old, new := h.counts()
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@%s\n", h.OldStart, old, h.NewStart, new, h.Section) + strings.Join(h.Lines, "\n")
+}
+
+// Slice returns the hunk made of lines [i, j) of h, numbered where they
+// start in the old and new file
+func (h Hunk) Slice(i, j int) Hunk {
+	skipped := Hunk{Lines: h.Lines[:i]}
+	old, new := skipped.counts()
+	return Hunk{OldStart: h.OldStart + old, NewStart: h.NewStart + new, Section: h.Section, Lines: h.Lines[i:j]}
+}
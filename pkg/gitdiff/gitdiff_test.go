@@ -0,0 +1,158 @@
+package gitdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestFiles tests extracting changed paths from a diff
+func TestFiles(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+-old
++new
+diff --git a/docs/new.md b/docs/new.md
+new file mode 100644
+--- /dev/null
++++ b/docs/new.md
+@@ -0,0 +1 @@
++hello
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`
+	want := []string{"main.go", "docs/new.md", "gone.txt"}
+	if got := Files(diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffFiles() = %v, want %v", got, want)
+	}
+}
+
+// TestSplit tests splitting a diff into per-file sections and back
+func TestSplit(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+--- not a header, a removed line
++new
+diff --git a/logo.png b/logo.png
+index 3333333..4444444 100644
+Binary files a/logo.png and b/logo.png differ
+`
+	files := Split(diff)
+	if len(files) != 2 {
+		t.Fatalf("splitDiff() returned %d files, want 2", len(files))
+	}
+	if files[0].Path != "main.go" || files[1].Path != "logo.png" {
+		t.Errorf("paths = %q, %q", files[0].Path, files[1].Path)
+	}
+	if !strings.HasPrefix(files[1].Text, "diff --git a/logo.png") {
+		t.Errorf("second section = %q", files[1].Text)
+	}
+	if got := Join(files); got != diff {
+		t.Errorf("joinDiff(splitDiff()) = %q, want the original diff", got)
+	}
+}
+
+// TestAdded tests numbering added lines from hunk headers
+func TestAdded(t *testing.T) {
+	f := File{Path: "a_test.go", Text: `diff --git a/a_test.go b/a_test.go
+--- a/a_test.go
++++ b/a_test.go
+@@ -10,4 +10,5 @@ func TestA(t *testing.T) {
+ 	a := 1
+-	b := 2
++	b := 3
++	c := 4
+ 	use(a, b)
+@@ -40 +41,2 @@
+ }
++// trailing
+`}
+	want := []Line{
+		{"a_test.go", 11, "\tb := 3"},
+		{"a_test.go", 12, "\tc := 4"},
+		{"a_test.go", 42, "// trailing"},
+	}
+	if got := Added(f); !reflect.DeepEqual(got, want) {
+		t.Errorf("addedLines() = %v, want %v", got, want)
+	}
+}
+
+// TestSize tests counting added and removed lines, ignoring headers
+func TestSize(t *testing.T) {
+	diff := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,3 +1,3 @@
+ package a
+-var x = 1
++var x = 2
++var y = 3
+diff --git a/b.go b/b.go
+new file mode 100644
+--- /dev/null
++++ b/b.go
+@@ -0,0 +1 @@
++package b
+`
+	if got := Size(diff); got != 4 {
+		t.Errorf("diffSize() = %d, want 4", got)
+	}
+}
+
+// TestAdded_CRLF tests diffs of files with Windows line endings
+func TestAdded_CRLF(t *testing.T) {
+	diff := "diff --git a/win.txt b/win.txt\r\n--- a/win.txt\r\n+++ b/win.txt\r\n@@ -1,1 +1,2 @@\r\n line one\r\n+line two\r\n"
+	files := Split(diff)
+	if len(files) != 1 || files[0].Path != "win.txt" {
+		t.Fatalf("splitDiff() = %+v, want win.txt", files)
+	}
+	added := Added(files[0])
+	if len(added) != 1 || added[0].Text != "line two" || added[0].Line != 2 {
+		t.Errorf("addedLines() = %+v", added)
+	}
+}
+
+// TestHunkLines tests collecting the new-side lines a patch shows
+func TestHunkLines(t *testing.T) {
+	patch := "@@ -1,3 +1,4 @@\n context\n-removed\n+added one\n+added two\n context\n@@ -20,2 +21,2 @@\n-old\n+new\n tail"
+	got := HunkLines(patch)
+	for _, line := range []int{1, 2, 3, 4, 21, 22} {
+		if !got[line] {
+			t.Errorf("line %d missing from %v", line, got)
+		}
+	}
+	if len(got) != 6 {
+		t.Errorf("hunkLines() = %v, want 6 lines", got)
+	}
+}
+
+// TestHunks tests splitting a file section into hunks and slicing one
+func TestHunks(t *testing.T) {
+	f := File{Path: "a.go", Text: "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -10,4 +10,5 @@ func A() {\n \ta := 1\n-\tb := 2\n+\tb := 3\n+\tc := 4\n \tuse(a, b)\n@@ -40 +41,2 @@\n }\n+// trailing\n"}
+	header, hunks := Hunks(f)
+	if header != "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go" {
+		t.Errorf("header = %q", header)
+	}
+	if len(hunks) != 2 || hunks[0].Section != " func A() {" || hunks[1].NewStart != 41 {
+		t.Fatalf("Hunks() = %+v", hunks)
+	}
+	if got := hunks[0].String(); got != "@@ -10,3 +10,4 @@ func A() {\n \ta := 1\n-\tb := 2\n+\tb := 3\n+\tc := 4\n \tuse(a, b)" {
+		t.Errorf("String() = %q", got)
+	}
+	if end := hunks[0].NewEnd(); end != 13 {
+		t.Errorf("NewEnd() = %d, want 13", end)
+	}
+	if got := hunks[0].Slice(2, 5).String(); got != "@@ -12,1 +11,3 @@ func A() {\n+\tb := 3\n+\tc := 4\n \tuse(a, b)" {
+		t.Errorf("Slice(2, 5) = %q", got)
+	}
+}
@@ -0,0 +1,269 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	anthropicAPIURL = "https://api.anthropic.com/v1"
+	apiVersion      = "2023-06-01"
+)
+
+type claudeRequest struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature float64   `json:"temperature,omitempty"`
+	Messages    []message `json:"messages"`
+	Thinking    *thinking `json:"thinking,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+	Metadata    *metadata `json:"metadata,omitempty"`
+}
+
+// metadata identifies the user of a request for usage attribution
+type metadata struct {
+	UserID string `json:"user_id"`
+}
+
+type thinking struct {
+	Type   string `json:"type"`
+	Budget int    `json:"budget_tokens"`
+}
+
+// message is a conversation turn. Documents uploaded with the Files API are
+// sent ahead of the text (see MarshalJSON).
+type message struct {
+	Role      string
+	Content   string
+	Documents []documentRef
+
+	// Cached is text ahead of Content, after the documents, that ends a
+	// prompt caching breakpoint
+	Cached string
+}
+
+type claudeResponse struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Role       string         `json:"role"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      Usage          `json:"usage"`
+}
+
+type contentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// claudeClient calls the Messages API
+type claudeClient struct {
+	opts Options
+
+	// documents are uploaded files attached to every request
+	documents []documentRef
+
+	// stream, if set, gets the response text as it is written
+	stream io.Writer
+
+	// url and filesURL are the endpoints of the Messages and Files APIs
+	url      string
+	filesURL string
+}
+
+// newClaudeClient returns a client for the Anthropic API at opts.BaseURL,
+// or Anthropic's own
+func newClaudeClient(opts Options) *claudeClient {
+	root := strings.TrimSuffix(opts.BaseURL, "/")
+	if root == "" {
+		root = anthropicAPIURL
+	}
+	return &claudeClient{opts: opts, url: root + "/messages", filesURL: root + "/files"}
+}
+
+// stopMaxTokens is the stop_reason of a response cut off at max_tokens
+const stopMaxTokens = "max_tokens"
+
+// Name implements Provider
+func (c *claudeClient) Name() string {
+	return "Claude"
+}
+
+// Complete implements Provider
+func (c *claudeClient) Complete(prompt string, opts CompletionOptions) (string, Usage, error) {
+	req := newClaudeRequest(opts.Model, opts.Thinking, opts.ThinkingBudget, opts.MaxTokens, c.opts.UserID)
+	return c.opts.complete(opts.Model, prompt, opts.MaxTokens, func(turns []string) (*Completion, error) {
+		req.Messages = claudeMessages(turns, c.documents, !c.opts.NoPromptCache)
+		resp, err := c.send(req)
+		if err != nil {
+			return nil, err
+		}
+		return resp.completion(), nil
+	})
+}
+
+// newClaudeRequest returns a Messages API request without its messages
+func newClaudeRequest(model string, useThinking bool, thinkingBudget, maxTokens int, userID string) claudeRequest {
+	req := claudeRequest{
+		Model:       model,
+		MaxTokens:   maxTokens,
+		Temperature: 1.0,
+	}
+	if userID != "" {
+		req.Metadata = &metadata{UserID: userID}
+	}
+
+	// Enable extended thinking if requested
+	if useThinking {
+		req.Thinking = &thinking{
+			Type:   "enabled",
+			Budget: thinkingBudget,
+		}
+	}
+	return req
+}
+
+// claudeMessages turns a conversation of alternating user and assistant
+// turns into messages, with documents attached to the first. With cache,
+// the first turn up to its cache breakpoint is cached, documents and all.
+func claudeMessages(turns []string, documents []documentRef, cache bool) []message {
+	messages := make([]message, len(turns))
+	for i, turn := range turns {
+		messages[i] = message{Role: "user", Content: WithoutCacheBreakpoint(turn)}
+		if i%2 == 1 {
+			messages[i].Role = "assistant"
+		}
+	}
+	messages[0].Documents = documents
+	if cached, rest, ok := strings.Cut(turns[0], CacheBreakpoint); ok && cache {
+		messages[0].Cached, messages[0].Content = cached, rest
+	}
+	return messages
+}
+
+// send makes one Messages API request
+func (c *claudeClient) send(req claudeRequest) (*claudeResponse, error) {
+	req.Stream = c.stream != nil
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", c.url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if len(c.documents) > 0 {
+		httpReq.Header.Set("anthropic-beta", filesAPIBeta)
+	}
+	if c.stream != nil {
+		resp, err := c.sendStreamed(httpReq, jsonData)
+		var idle *streamIdleError
+		if !errors.As(err, &idle) {
+			return resp, err
+		}
+		// A stalled stream is sent again without streaming, and the whole
+		// response shown once it is ready
+		c.opts.warnf("\nWarning: %v; retrying without streaming\n", err)
+		unstreamed := *c
+		unstreamed.stream = nil
+		resp, err = unstreamed.send(req)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(c.stream, "\n\n---\n\n")
+		io.WriteString(c.stream, resp.text())
+		return resp, nil
+	}
+	body, err := c.roundTrip(httpReq, jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	var claudeResp claudeResponse
+	if err := json.Unmarshal(body, &claudeResp); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: %w", err)
+	}
+	return &claudeResp, nil
+}
+
+// roundTrip sends an API request with the credentials and version headers,
+// records it in the transcript, and returns the body of a successful response
+func (c *claudeClient) roundTrip(httpReq *http.Request, reqBody []byte) ([]byte, error) {
+	httpReq.Header.Set("x-api-key", c.opts.APIKey)
+	httpReq.Header.Set("anthropic-version", apiVersion)
+
+	body, status, err := c.opts.sendRecorded(httpReq, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("API error (status %d): %s", status, string(body))
+	}
+	return body, nil
+}
+
+// completion returns the response's text, usage and whether it was cut off
+func (r *claudeResponse) completion() *Completion {
+	return &Completion{Text: r.text(), Usage: r.Usage, Truncated: r.StopReason == stopMaxTokens}
+}
+
+// text combines all text content blocks
+func (r *claudeResponse) text() string {
+	var b strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			b.WriteString(block.Text)
+		}
+	}
+	return b.String()
+}
+
+// countTokensRequest is a Messages API request as the token counting
+// endpoint takes it
+type countTokensRequest struct {
+	Model    string    `json:"model"`
+	Messages []message `json:"messages"`
+	Thinking *thinking `json:"thinking,omitempty"`
+}
+
+// CountTokens implements TokenCounter with the token counting endpoint,
+// which is free and doesn't run the model
+func (c *claudeClient) CountTokens(prompt string, opts CompletionOptions) (int, error) {
+	req := newClaudeRequest(opts.Model, opts.Thinking, opts.ThinkingBudget, opts.MaxTokens, "")
+	jsonData, err := json.Marshal(countTokensRequest{
+		Model:    req.Model,
+		Messages: claudeMessages([]string{prompt}, c.documents, !c.opts.NoPromptCache),
+		Thinking: req.Thinking,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", c.url+"/count_tokens", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if len(c.documents) > 0 {
+		httpReq.Header.Set("anthropic-beta", filesAPIBeta)
+	}
+	body, err := c.roundTrip(httpReq, jsonData)
+	if err != nil {
+		return 0, err
+	}
+	var resp struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, fmt.Errorf("error unmarshaling response: %w", err)
+	}
+	return resp.InputTokens, nil
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeClaude serves canned Messages API responses in order and records the
+// requests it receives
+type fakeClaude struct {
+	responses []string
+	requests  []claudeRequest
+}
+
+func (f *fakeClaude) serve(t *testing.T) *claudeClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req claudeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		f.requests = append(f.requests, req)
+		if len(f.responses) == 0 {
+			http.Error(w, "no more responses", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, f.responses[0])
+		f.responses = f.responses[1:]
+	}))
+	t.Cleanup(server.Close)
+	return &claudeClient{opts: Options{APIKey: "test"}, url: server.URL}
+}
+
+// TestClaudeClient_Continuation tests fetching and stitching the rest of a
+// truncated response
+func TestClaudeClient_Continuation(t *testing.T) {
+	fake := &fakeClaude{responses: []string{
+		`{"content":[{"type":"thinking","thinking":"..."},{"type":"text","text":"First part, "}],"stop_reason":"max_tokens","usage":{"input_tokens":10,"output_tokens":100}}`,
+		`{"content":[{"type":"text","text":"second part, "}],"stop_reason":"max_tokens","usage":{"input_tokens":20,"output_tokens":100}}`,
+		`{"content":[{"type":"text","text":"second part, and the end."}],"stop_reason":"end_turn","usage":{"input_tokens":30,"output_tokens":5}}`,
+	}}
+	client := fake.serve(t)
+	client.opts.MaxContinuations = 3
+
+	text, usage, err := client.Complete("review this", CompletionOptions{Model: "m", Thinking: true, ThinkingBudget: 1000, MaxTokens: 100})
+	if err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+	if text != "First part, second part, and the end." {
+		t.Errorf("text = %q", text)
+	}
+	if usage.InputTokens != 60 || usage.OutputTokens != 205 {
+		t.Errorf("usage = %+v, want all requests counted", usage)
+	}
+	if len(fake.requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(fake.requests))
+	}
+	last := fake.requests[2]
+	if len(last.Messages) != 3 || last.Messages[0].Content != "review this" ||
+		last.Messages[1].Role != "assistant" || last.Messages[1].Content != "First part, second part, " ||
+		last.Messages[2].Content != ContinuePrompt {
+		t.Errorf("continuation messages = %+v", last.Messages)
+	}
+	if last.Thinking == nil {
+		t.Error("continuation dropped extended thinking")
+	}
+}
+
+// TestClaudeClient_ContinuationLimit tests that continuations stop at the limit
+func TestClaudeClient_ContinuationLimit(t *testing.T) {
+	truncated := `{"content":[{"type":"text","text":"more "}],"stop_reason":"max_tokens"}`
+	fake := &fakeClaude{responses: []string{truncated, truncated, truncated}}
+	client := fake.serve(t)
+	client.opts.MaxContinuations = 1
+
+	text, _, err := client.Complete("review this", CompletionOptions{Model: "m", MaxTokens: 100})
+	if err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+	if len(fake.requests) != 2 || !strings.HasSuffix(text, DefaultTruncatedNotice) {
+		t.Errorf("got %d requests and text %q; want 2 and the truncation notice", len(fake.requests), text)
+	}
+}
+
+// TestClaudeClient_Truncated tests that a cut-off response is marked as such
+func TestClaudeClient_Truncated(t *testing.T) {
+	fake := &fakeClaude{responses: []string{
+		`{"content":[{"type":"text","text":"Partial"}],"stop_reason":"max_tokens"}`,
+	}}
+	text, _, err := fake.serve(t).Complete("review this", CompletionOptions{Model: "m", MaxTokens: 100})
+	if err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+	if !strings.HasPrefix(text, "Partial") || !strings.HasSuffix(text, DefaultTruncatedNotice) {
+		t.Errorf("text = %q, want the truncation notice appended", text)
+	}
+	if len(fake.requests) != 1 {
+		t.Errorf("got %d requests, want no continuation when disabled", len(fake.requests))
+	}
+}
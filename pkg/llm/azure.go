@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// azureAPIVersion is the Azure OpenAI API version used unless
+// AZURE_OPENAI_API_VERSION sets another
+const azureAPIVersion = "2024-10-21"
+
+// azureCognitiveResource is the resource Microsoft Entra ID (AAD) tokens
+// for Azure OpenAI are issued for
+const azureCognitiveResource = "https://cognitiveservices.azure.com"
+
+// newAzureOpenAIClient returns a client for the deployments of an Azure
+// OpenAI resource, named as the model of each request. Azure takes the same
+// requests as OpenAI, but addressed to the deployment and API version in the
+// URL, and authenticated with an API key or an Entra ID token:
+// AZURE_OPENAI_API_KEY, AZURE_OPENAI_AD_TOKEN, or a token from the Azure
+// CLI's signed-in account, in that order.
+func newAzureOpenAIClient(opts Options) (*openAIClient, error) {
+	endpoint := strings.TrimSuffix(os.Getenv("AZURE_OPENAI_ENDPOINT"), "/")
+	if endpoint == "" {
+		return nil, fmt.Errorf("AZURE_OPENAI_ENDPOINT environment variable not set")
+	}
+	version := os.Getenv("AZURE_OPENAI_API_VERSION")
+	if version == "" {
+		version = azureAPIVersion
+	}
+
+	authHeader, authValue := "Api-Key", os.Getenv("AZURE_OPENAI_API_KEY")
+	if authValue == "" {
+		token := os.Getenv("AZURE_OPENAI_AD_TOKEN")
+		if token == "" {
+			var err error
+			if token, err = azureCLIToken(); err != nil {
+				return nil, fmt.Errorf("set AZURE_OPENAI_API_KEY or AZURE_OPENAI_AD_TOKEN, or sign in with az login: %w", err)
+			}
+		}
+		authHeader, authValue = "Authorization", "Bearer "+token
+	}
+
+	return &openAIClient{
+		opts: opts,
+		name: "Azure OpenAI",
+		newRequest: func(deployment string, body []byte) (*http.Request, error) {
+			chatURL := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+				endpoint, url.PathEscape(deployment), url.QueryEscape(version))
+			req, err := http.NewRequest("POST", chatURL, bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set(authHeader, authValue)
+			return req, nil
+		},
+	}, nil
+}
+
+// azureCLIToken gets an Entra ID token for Azure OpenAI from the Azure CLI
+func azureCLIToken() (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("az", "account", "get-access-token", "--resource", azureCognitiveResource,
+		"--query", "accessToken", "--output", "tsv")
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("failed to get a token from the Azure CLI: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("failed to get a token from the Azure CLI: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestAzureOpenAIClient tests that requests go to the deployment with the
+// API version and either an API key or an Entra ID token
+func TestAzureOpenAIClient(t *testing.T) {
+	var gotPath, gotVersion, gotKey, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotVersion = r.URL.Path, r.URL.Query().Get("api-version")
+		gotKey, gotAuth = r.Header.Get("Api-Key"), r.Header.Get("Authorization")
+		var req openAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) != 1 {
+			t.Errorf("request body = %+v, %v", req, err)
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "LGTM"}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 12, "completion_tokens": 3}}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AZURE_OPENAI_ENDPOINT", server.URL+"/")
+	t.Setenv("AZURE_OPENAI_API_VERSION", "")
+	t.Setenv("AZURE_OPENAI_API_KEY", "key")
+	t.Setenv("AZURE_OPENAI_AD_TOKEN", "")
+	client, err := newAzureOpenAIClient(Options{})
+	if err != nil {
+		t.Fatalf("newAzureOpenAIClient() returned error: %v", err)
+	}
+	text, usage, err := client.Complete("Review this diff.", CompletionOptions{Model: "review-gpt4o", MaxTokens: 100})
+	if err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+	if text != "LGTM" || usage.InputTokens != 12 || usage.OutputTokens != 3 {
+		t.Errorf("Complete() = %q, %+v", text, usage)
+	}
+	if gotPath != "/openai/deployments/review-gpt4o/chat/completions" || gotVersion != azureAPIVersion {
+		t.Errorf("request to %s?api-version=%s", gotPath, gotVersion)
+	}
+	if gotKey != "key" || gotAuth != "" {
+		t.Errorf("request with Api-Key %q and Authorization %q, want the key", gotKey, gotAuth)
+	}
+
+	t.Setenv("AZURE_OPENAI_API_KEY", "")
+	t.Setenv("AZURE_OPENAI_AD_TOKEN", "entra-token")
+	t.Setenv("AZURE_OPENAI_API_VERSION", "2025-01-01-preview")
+	client, err = newAzureOpenAIClient(Options{})
+	if err != nil {
+		t.Fatalf("newAzureOpenAIClient() returned error: %v", err)
+	}
+	if _, _, err := client.Complete("Review this diff.", CompletionOptions{Model: "review-gpt4o", MaxTokens: 100}); err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+	if gotKey != "" || gotAuth != "Bearer entra-token" || gotVersion != "2025-01-01-preview" {
+		t.Errorf("request with Api-Key %q, Authorization %q and version %q, want the token", gotKey, gotAuth, gotVersion)
+	}
+
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "")
+	if _, err := newAzureOpenAIClient(Options{}); err == nil || !strings.Contains(err.Error(), "AZURE_OPENAI_ENDPOINT") {
+		t.Errorf("newAzureOpenAIClient() without an endpoint error = %v", err)
+	}
+}
+
+// TestAzureOpenAIClient_Errors tests that API errors name Azure
+func TestAzureOpenAIClient_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": {"code": "DeploymentNotFound"}}`))
+	}))
+	defer server.Close()
+	t.Setenv("AZURE_OPENAI_ENDPOINT", server.URL)
+	t.Setenv("AZURE_OPENAI_API_KEY", "key")
+
+	client, err := newAzureOpenAIClient(Options{})
+	if err != nil {
+		t.Fatalf("newAzureOpenAIClient() returned error: %v", err)
+	}
+	_, _, err = client.Complete("Review this diff.", CompletionOptions{Model: "missing", MaxTokens: 100})
+	if err == nil || !strings.Contains(err.Error(), "API error from Azure OpenAI (status 404)") {
+		t.Errorf("Complete() error = %v", err)
+	}
+}
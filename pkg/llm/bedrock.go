@@ -0,0 +1,222 @@
+package llm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bedrockAnthropicVersion is the Messages API version Bedrock expects in the
+// request body, in place of the anthropic-version header
+const bedrockAnthropicVersion = "bedrock-2023-05-31"
+
+// awsCredentials sign requests to AWS
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // set for temporary credentials
+}
+
+// bedrockClient calls Anthropic models through the Bedrock runtime API,
+// signing requests with SigV4 rather than sending an Anthropic API key
+type bedrockClient struct {
+	opts   Options
+	creds  awsCredentials
+	region string
+	url    string // endpoint override, e.g. a VPC endpoint; empty means the regional one
+
+	// now returns the signing time (for tests)
+	now func() time.Time
+}
+
+// newBedrockClient returns a client using the AWS credentials and region
+// from the standard environment variables
+func newBedrockClient(opts Options) (*bedrockClient, error) {
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables not set")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("AWS_REGION environment variable not set")
+	}
+	return &bedrockClient{opts: opts, creds: creds, region: region, url: os.Getenv("AWS_ENDPOINT_URL_BEDROCK_RUNTIME")}, nil
+}
+
+// bedrockRequest is a Messages API request as Bedrock takes it: the model
+// is in the URL, and the API version in the body
+type bedrockRequest struct {
+	AnthropicVersion string `json:"anthropic_version"`
+	claudeRequest
+	Model    string    `json:"model,omitempty"`    // hides claudeRequest.Model
+	Metadata *metadata `json:"metadata,omitempty"` // hides claudeRequest.Metadata, which Bedrock rejects
+}
+
+// Name implements Provider
+func (c *bedrockClient) Name() string {
+	return "Bedrock"
+}
+
+// Complete implements Provider with the same requests and responses as
+// the Messages API; Bedrock has no Files API, so nothing is attached
+func (c *bedrockClient) Complete(prompt string, opts CompletionOptions) (string, Usage, error) {
+	req := bedrockRequest{
+		AnthropicVersion: bedrockAnthropicVersion,
+		claudeRequest:    newClaudeRequest("", opts.Thinking, opts.ThinkingBudget, opts.MaxTokens, ""),
+	}
+	return c.opts.complete(opts.Model, prompt, opts.MaxTokens, func(turns []string) (*Completion, error) {
+		req.Messages = claudeMessages(turns, nil, !c.opts.NoPromptCache)
+		resp, err := c.send(opts.Model, req)
+		if err != nil {
+			return nil, err
+		}
+		return resp.completion(), nil
+	})
+}
+
+// send makes one InvokeModel request
+func (c *bedrockClient) send(model string, req bedrockRequest) (*claudeResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	root := strings.TrimSuffix(c.url, "/")
+	if root == "" {
+		root = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", c.region)
+	}
+	// Model IDs contain colons, which AWS expects escaped in the path
+	httpReq, err := http.NewRequest("POST", root+"/model/"+awsURIEncode(model)+"/invoke", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	signV4(httpReq, jsonData, c.creds, c.region, "bedrock", now())
+
+	body, status, err := c.opts.sendRecorded(httpReq, jsonData)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("API error from Bedrock (status %d): %s", status, string(body))
+	}
+
+	var resp claudeResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: %w", err)
+	}
+	return &resp, nil
+}
+
+// awsURIEncode escapes everything but RFC 3986's unreserved characters, as
+// SigV4 requires
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if 'A' <= ch && ch <= 'Z' || 'a' <= ch && ch <= 'z' || '0' <= ch && ch <= '9' || strings.IndexByte("-_.~", ch) >= 0 {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// signV4 signs req, whose body is body, with AWS Signature Version 4 for
+// service in region, setting its X-Amz-Date and Authorization headers
+func signV4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	// Sign the host and every header the request sets
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(headers[name]))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// Services other than S3 encode each path segment a second time
+	segments := strings.Split(req.URL.EscapedPath(), "/")
+	for i, segment := range segments {
+		segments[i] = awsURIEncode(segment)
+	}
+	path := strings.Join(segments, "/")
+	if path == "" {
+		path = "/"
+	}
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, awsURIEncode(key)+"="+awsURIEncode(value))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, strings.Join(params, "&"), canonicalHeaders.String(), signedHeaders, sha256Hex(body),
+	}, "\n")
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignV4 tests signing against vectors from the AWS SigV4 test suite
+func TestSignV4(t *testing.T) {
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	tests := []struct {
+		url, signature string
+	}{
+		{"https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		signV4(req, nil, creds, "us-east-1", "service", now)
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+		if got := req.Header.Get("Authorization"); got != want {
+			t.Errorf("%s: Authorization = %q\nwant %q", tt.url, got, want)
+		}
+	}
+}
+
+// TestBedrockClient tests invoking a model by its escaped ID with a signed
+// request, and reporting API errors
+func TestBedrockClient(t *testing.T) {
+	var paths, auth, tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		auth = append(auth, r.Header.Get("Authorization"))
+		tokens = append(tokens, r.Header.Get("X-Amz-Security-Token"))
+		if strings.Contains(r.URL.Path, "missing") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message": "The provided model identifier is invalid."}`)
+			return
+		}
+		fmt.Fprint(w, `{"content": [{"type": "text", "text": "Looks good."}], "stop_reason": "end_turn", "usage": {"input_tokens": 12, "output_tokens": 3}}`)
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ENDPOINT_URL_BEDROCK_RUNTIME", server.URL)
+	client, err := newBedrockClient(Options{})
+	if err != nil {
+		t.Fatalf("newBedrockClient() returned error: %v", err)
+	}
+
+	text, usage, err := client.Complete("Review this diff.", CompletionOptions{Model: "eu.anthropic.claude-sonnet-4-5-20250929-v1:0", MaxTokens: 100})
+	if err != nil || text != "Looks good." || usage.OutputTokens != 3 {
+		t.Fatalf("Complete() = %q, %+v, %v", text, usage, err)
+	}
+	if paths[0] != "/model/eu.anthropic.claude-sonnet-4-5-20250929-v1%3A0/invoke" {
+		t.Errorf("path = %s", paths[0])
+	}
+	if !strings.HasPrefix(auth[0], "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth[0], "/eu-west-1/bedrock/aws4_request") ||
+		!strings.Contains(auth[0], "x-amz-security-token") || tokens[0] != "session" {
+		t.Errorf("Authorization = %q, token = %q", auth[0], tokens[0])
+	}
+
+	if _, _, err := client.Complete("Review this diff.", CompletionOptions{Model: "missing", MaxTokens: 100}); err == nil || !strings.Contains(err.Error(), "model identifier is invalid") {
+		t.Errorf("Complete() of an invalid model error = %v", err)
+	}
+
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	if _, err := newBedrockClient(Options{}); err == nil {
+		t.Error("newBedrockClient() without a region expected error")
+	}
+}
@@ -0,0 +1,250 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Providers New can build
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+	ProviderAzure     = "azure"
+	ProviderBedrock   = "bedrock"
+)
+
+// DefaultModels is the model of each provider a caller without a preference
+// can use. Azure deployments are named by their owners, so there is none.
+var DefaultModels = map[string]string{
+	ProviderAnthropic: "claude-sonnet-4-5-20250929",
+	ProviderOpenAI:    "gpt-4o",
+	ProviderBedrock:   "us.anthropic.claude-sonnet-4-5-20250929-v1:0",
+	ProviderAzure:     "",
+}
+
+// DefaultMaxAttempts is how many times a request failing with a transient
+// error is tried when Options.MaxAttempts isn't set
+const DefaultMaxAttempts = 4
+
+// DefaultTruncatedNotice is appended to a response cut off at the output
+// limit when Options.TruncatedNotice isn't set
+const DefaultTruncatedNotice = "\n\n---\n\n⚠️ **This output was cut off at the output token limit and is incomplete.**"
+
+// Recorder records the API exchanges of a client, e.g. in an audit
+// transcript. resp is nil if the request could not be sent.
+type Recorder interface {
+	Record(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, started time.Time, err error)
+}
+
+// Options configure a provider client. The zero value of each field is a
+// usable default.
+type Options struct {
+	// APIKey authenticates with Anthropic or OpenAI. Azure OpenAI and
+	// Bedrock read their credentials from their usual environment variables.
+	APIKey string
+
+	// BaseURL replaces the root of the Anthropic or OpenAI API, e.g.
+	// https://api.openai.com/v1. An OpenAI base URL is taken to be a
+	// compatible server, and is sent the output limit as max_tokens.
+	BaseURL string
+
+	// MaxContinuations is how many follow-up requests may fetch the rest of
+	// a response cut off at the output limit
+	MaxContinuations int
+
+	// MaxAttempts is how many times a request failing with a transient
+	// error is tried in all; 0 means DefaultMaxAttempts
+	MaxAttempts int
+
+	// Headers are added to every request. A header the request already has
+	// is extended as a comma-separated list, so e.g. extra anthropic-beta
+	// features add to the ones the client needs.
+	Headers http.Header
+
+	// UserID identifies the user in requests for usage attribution, where
+	// the provider takes one
+	UserID string
+
+	// NoPromptCache turns off prompt caching with Anthropic and Bedrock
+	NoPromptCache bool
+
+	// StreamIdleTimeout gives up on a streamed response that sends nothing
+	// for this long, and sends it again without streaming; 0 waits for as
+	// long as the stream may run
+	StreamIdleTimeout time.Duration
+
+	// Transcript, if set, records every exchange
+	Transcript Recorder
+
+	// OnUsage, if set, is called with the usage of each successful request
+	OnUsage func(model string, usage Usage)
+
+	// Log gets warnings such as retries and truncated responses; nil
+	// discards them
+	Log io.Writer
+
+	// TruncatedNotice is appended to a response cut off at the output limit;
+	// empty means DefaultTruncatedNotice
+	TruncatedNotice string
+
+	// ThinkingLabel is written to a stream when the model starts thinking;
+	// empty means "Thinking"
+	ThinkingLabel string
+}
+
+// New returns a client for the named provider. Azure OpenAI reads its
+// endpoint and credentials from AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_KEY,
+// AZURE_OPENAI_AD_TOKEN (or the Azure CLI) and AZURE_OPENAI_API_VERSION, and
+// Bedrock from the standard AWS environment variables.
+func New(name string, opts Options) (Provider, error) {
+	switch name {
+	case ProviderAnthropic:
+		return newClaudeClient(opts), nil
+	case ProviderOpenAI:
+		return &openAIClient{opts: opts, url: opts.BaseURL, compatible: opts.BaseURL != ""}, nil
+	case ProviderAzure:
+		return newAzureOpenAIClient(opts)
+	case ProviderBedrock:
+		return newBedrockClient(opts)
+	}
+	return nil, fmt.Errorf("unknown provider %q (want anthropic, openai, azure or bedrock)", name)
+}
+
+// TokenCounter is a Provider that can count the input tokens of a prompt
+// without sending it to the model
+type TokenCounter interface {
+	CountTokens(prompt string, opts CompletionOptions) (int, error)
+}
+
+// WithoutDocuments returns p without the files attached to its requests,
+// for calls that don't need them; providers without attachments are
+// returned as they are
+func WithoutDocuments(p Provider) Provider {
+	if c, ok := p.(*claudeClient); ok {
+		return c.withoutDocuments()
+	}
+	return p
+}
+
+// Streaming returns p writing its responses to w as they are written, and
+// whether p can; providers that can't are returned as they are
+func Streaming(p Provider, w io.Writer) (Provider, bool) {
+	if c, ok := p.(*claudeClient); ok {
+		live := *c
+		live.stream = w
+		return &live, true
+	}
+	return p, false
+}
+
+// warnf writes a warning to the log
+func (o *Options) warnf(format string, args ...any) {
+	if o.Log != nil {
+		fmt.Fprintf(o.Log, format, args...)
+	}
+}
+
+// record records an exchange in the transcript, if there is one
+func (o *Options) record(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, started time.Time, err error) {
+	if o.Transcript != nil {
+		o.Transcript.Record(req, reqBody, resp, respBody, started, err)
+	}
+}
+
+// complete sends prompt and fetches the rest of a response cut off at
+// maxTokens with up to MaxContinuations follow-up requests: the
+// conversation is replayed with the output so far as the assistant's turn
+// and a request to go on, so the output limit caps each piece rather than
+// the whole response. send is given the conversation as alternating user
+// and assistant turns, starting with prompt. Each request's usage of model
+// is passed to OnUsage.
+func (o *Options) complete(model, prompt string, maxTokens int, send func(turns []string) (*Completion, error)) (string, Usage, error) {
+	if o.OnUsage != nil {
+		unrecorded := send
+		send = func(turns []string) (*Completion, error) {
+			resp, err := unrecorded(turns)
+			if err == nil {
+				o.OnUsage(model, resp.Usage)
+			}
+			return resp, err
+		}
+	}
+	resp, err := send([]string{prompt})
+	if err != nil {
+		return "", Usage{}, err
+	}
+	text, usage := resp.Text, resp.Usage
+
+	for i := 0; resp.Truncated && i < o.MaxContinuations; i++ {
+		o.warnf("Warning: Response reached the %d-token output limit; requesting the rest (%d/%d)...\n",
+			maxTokens, i+1, o.MaxContinuations)
+		next, err := send([]string{prompt, text, ContinuePrompt})
+		if err != nil {
+			o.warnf("Warning: Could not fetch the rest of the response: %v\n", err)
+			break
+		}
+		resp = next
+		text = Stitch(text, resp.Text)
+		usage.Add(resp.Usage)
+	}
+
+	if resp.Truncated {
+		o.warnf("Warning: The response was cut off at the %d-token output limit and is incomplete.\n", maxTokens)
+		if o.TruncatedNotice != "" {
+			text += o.TruncatedNotice
+		} else {
+			text += DefaultTruncatedNotice
+		}
+	}
+	return text, usage, nil
+}
+
+// requestTimeout bounds an API request that isn't streamed
+const requestTimeout = 5 * time.Minute
+
+// sendRecorded sends an API request, records it in the transcript, and
+// returns the response body and status
+func (o *Options) sendRecorded(httpReq *http.Request, reqBody []byte) ([]byte, int, error) {
+	client := &http.Client{Timeout: requestTimeout}
+	resp, started, err := o.doWithRetry(client, httpReq, reqBody)
+	if err != nil {
+		o.record(httpReq, reqBody, nil, nil, started, err)
+		return nil, 0, fmt.Errorf("error making request: %w", timeoutError(err, requestTimeout))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	o.record(httpReq, reqBody, resp, body, started, err)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("error reading response: %w", timeoutError(err, requestTimeout))
+	}
+	return body, resp.StatusCode, nil
+}
+
+// timeoutError says that a request ran into its total timeout, which a bare
+// "context deadline exceeded" doesn't
+func timeoutError(err error, total time.Duration) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("request timed out: no complete response within %s: %w", total, err)
+	}
+	return err
+}
+
+// addHeaders adds the configured headers to an API request. A header the
+// request already has is extended as a comma-separated list.
+func addHeaders(req *http.Request, headers http.Header) {
+	for name, values := range headers {
+		for _, v := range values {
+			if existing := req.Header.Get(name); existing != "" {
+				req.Header.Set(name, existing+", "+v)
+			} else {
+				req.Header.Set(name, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package llm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"time"
+)
+
+// filesAPIBeta enables the Files API and file references in messages
+const filesAPIBeta = "files-api-2025-04-14"
+
+// documentRef attaches an uploaded file to a message as a document
+type documentRef struct {
+	FileID string
+	Title  string
+}
+
+// documentBlock is a message content block referencing an uploaded file
+type documentBlock struct {
+	Type   string     `json:"type"`
+	Title  string     `json:"title,omitempty"`
+	Source fileSource `json:"source"`
+}
+
+type fileSource struct {
+	Type   string `json:"type"`
+	FileID string `json:"file_id"`
+}
+
+// cacheControl marks the end of a cached prompt prefix
+type cacheControl struct {
+	Type string `json:"type"`
+}
+
+// cachedTextBlock is a text content block ending a cached prefix
+type cachedTextBlock struct {
+	Type         string       `json:"type"`
+	Text         string       `json:"text"`
+	CacheControl cacheControl `json:"cache_control"`
+}
+
+// MarshalJSON sends a message with documents or a cached prefix as content
+// blocks, documents first, and a plain message as a string
+func (m message) MarshalJSON() ([]byte, error) {
+	if len(m.Documents) == 0 && m.Cached == "" {
+		return json.Marshal(struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		}{m.Role, m.Content})
+	}
+	blocks := make([]any, 0, len(m.Documents)+1)
+	for _, d := range m.Documents {
+		blocks = append(blocks, documentBlock{Type: "document", Title: d.Title, Source: fileSource{Type: "file", FileID: d.FileID}})
+	}
+	if m.Cached != "" {
+		blocks = append(blocks, cachedTextBlock{Type: "text", Text: m.Cached, CacheControl: cacheControl{Type: "ephemeral"}})
+	}
+	blocks = append(blocks, contentBlock{Type: "text", Text: m.Content})
+	return json.Marshal(struct {
+		Role    string `json:"role"`
+		Content []any  `json:"content"`
+	}{m.Role, blocks})
+}
+
+// UnmarshalJSON reads either form written by MarshalJSON
+func (m *message) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	m.Role = raw.Role
+	if len(raw.Content) > 0 && raw.Content[0] == '"' {
+		return json.Unmarshal(raw.Content, &m.Content)
+	}
+
+	var blocks []struct {
+		Type         string        `json:"type"`
+		Text         string        `json:"text"`
+		Title        string        `json:"title"`
+		Source       fileSource    `json:"source"`
+		CacheControl *cacheControl `json:"cache_control"`
+	}
+	if err := json.Unmarshal(raw.Content, &blocks); err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			if b.CacheControl != nil {
+				m.Cached += b.Text
+			} else {
+				m.Content += b.Text
+			}
+		case "document":
+			m.Documents = append(m.Documents, documentRef{FileID: b.Source.FileID, Title: b.Title})
+		}
+	}
+	return nil
+}
+
+// UploadedFile is a Files API upload, remembered so unchanged content is
+// uploaded once rather than on every run
+type UploadedFile struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// UploadCache remembers uploads by the SHA-256 of their content, in hex
+type UploadCache interface {
+	LookupUpload(key string) (UploadedFile, bool)
+	RememberUpload(key string, f UploadedFile) error
+}
+
+// FileAttacher is a Provider that can upload a file and attach it to every
+// request it makes, rather than have it inlined in the prompt
+type FileAttacher interface {
+	Provider
+
+	// AttachFile uploads content as a text document, unless the same
+	// content is in cache (which may be nil) and still uploaded. It reports
+	// whether an earlier upload was reused.
+	AttachFile(name string, content []byte, cache UploadCache) (bool, error)
+}
+
+// AttachFile implements FileAttacher with the Files API
+func (c *claudeClient) AttachFile(name string, content []byte, cache UploadCache) (bool, error) {
+	sum := sha256.Sum256(content)
+	key := hex.EncodeToString(sum[:])
+
+	reused := false
+	var f UploadedFile
+	var ok bool
+	if cache != nil {
+		f, ok = cache.LookupUpload(key)
+	}
+	if ok && c.fileExists(f.ID) {
+		reused = true
+	} else {
+		id, err := c.uploadFile(name, content)
+		if err != nil {
+			return false, err
+		}
+		f = UploadedFile{ID: id, Name: name, UploadedAt: time.Now().UTC()}
+		if cache != nil {
+			if err := cache.RememberUpload(key, f); err != nil {
+				c.opts.warnf("Warning: Could not save upload cache: %v\n", err)
+			}
+		}
+	}
+	c.documents = append(c.documents, documentRef{FileID: f.ID, Title: name})
+	return reused, nil
+}
+
+// uploadFile uploads content as a plain-text file and returns its ID
+func (c *claudeClient) uploadFile(name string, content []byte) (string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filepath.Base(name)))
+	header.Set("Content-Type", "text/plain")
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return "", fmt.Errorf("error creating upload: %w", err)
+	}
+	part.Write(content)
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("error creating upload: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", c.filesURL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", w.FormDataContentType())
+	httpReq.Header.Set("anthropic-beta", filesAPIBeta)
+	respBody, err := c.roundTrip(httpReq, body.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+
+	var uploaded struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &uploaded); err != nil || uploaded.ID == "" {
+		return "", fmt.Errorf("failed to upload %s: unexpected response %s", name, respBody)
+	}
+	return uploaded.ID, nil
+}
+
+// fileExists reports whether an uploaded file is still available
+func (c *claudeClient) fileExists(id string) bool {
+	httpReq, err := http.NewRequest("GET", c.filesURL+"/"+id, nil)
+	if err != nil {
+		return false
+	}
+	httpReq.Header.Set("anthropic-beta", filesAPIBeta)
+	_, err = c.roundTrip(httpReq, nil)
+	return err == nil
+}
+
+// withoutDocuments returns a copy of the client that doesn't attach the
+// uploaded context, for passes whose prompts don't use it
+func (c *claudeClient) withoutDocuments() *claudeClient {
+	plain := *c
+	plain.documents = nil
+	return &plain
+}
@@ -0,0 +1,181 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// uploadCache keeps uploads in memory
+type uploadCache map[string]UploadedFile
+
+func (c uploadCache) LookupUpload(key string) (UploadedFile, bool) {
+	f, ok := c[key]
+	return f, ok
+}
+
+func (c uploadCache) RememberUpload(key string, f UploadedFile) error {
+	c[key] = f
+	return nil
+}
+
+// TestMessageJSON tests that messages with documents are sent as content
+// blocks and read back
+func TestMessageJSON(t *testing.T) {
+	plain, err := json.Marshal(message{Role: "user", Content: "hi"})
+	if err != nil || string(plain) != `{"role":"user","content":"hi"}` {
+		t.Errorf("plain message = %s, %v", plain, err)
+	}
+
+	m := message{Role: "user", Content: "review this", Documents: []documentRef{{FileID: "file_1", Title: "schema.sql"}}}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal() returned error: %v", err)
+	}
+	want := `{"role":"user","content":[{"type":"document","title":"schema.sql","source":{"type":"file","file_id":"file_1"}},{"type":"text","text":"review this"}]}`
+	if string(data) != want {
+		t.Errorf("message = %s\nwant %s", data, want)
+	}
+
+	var back message
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("Unmarshal() returned error: %v", err)
+	}
+	if back.Content != m.Content || len(back.Documents) != 1 || back.Documents[0] != m.Documents[0] {
+		t.Errorf("round trip = %+v", back)
+	}
+}
+
+// TestPromptCache tests that a prompt up to its cache breakpoint is sent
+// as a cached block, and that cache usage adds up over
+// continuations
+func TestPromptCache(t *testing.T) {
+	cached, rest := "Review this diff:\n+fix()\n", "\n## Benchmark Results\nfaster"
+	prompt := cached + CacheBreakpoint + rest
+
+	messages := claudeMessages([]string{prompt, "partial", ContinuePrompt}, nil, true)
+	data, err := json.Marshal(messages[0])
+	if err != nil {
+		t.Fatalf("Marshal() returned error: %v", err)
+	}
+	if !strings.Contains(string(data), `"cache_control":{"type":"ephemeral"}},{"type":"text","text":"\n## Benchmark Results`) {
+		t.Errorf("first message = %s", data)
+	}
+	var back message
+	if err := json.Unmarshal(data, &back); err != nil || back.Cached != cached || back.Content != rest {
+		t.Errorf("round trip = %+v, %v", back, err)
+	}
+	if uncached := claudeMessages([]string{prompt}, nil, false); uncached[0].Cached != "" || strings.Contains(uncached[0].Content, CacheBreakpoint) {
+		t.Errorf("uncached message = %+v", uncached[0])
+	}
+
+	fake := &fakeClaude{responses: []string{
+		`{"content":[{"type":"text","text":"First part, "}],"stop_reason":"max_tokens","usage":{"input_tokens":10,"output_tokens":100,"cache_creation_input_tokens":5000}}`,
+		`{"content":[{"type":"text","text":"and the end."}],"stop_reason":"end_turn","usage":{"input_tokens":20,"output_tokens":5,"cache_read_input_tokens":5000}}`,
+	}}
+	client := fake.serve(t)
+	client.opts.MaxContinuations = 1
+	_, usage, err := client.Complete(prompt, CompletionOptions{Model: "claude", MaxTokens: 100})
+	if err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+	if usage != (Usage{InputTokens: 30, OutputTokens: 105, CacheCreationInputTokens: 5000, CacheReadInputTokens: 5000}) {
+		t.Errorf("usage = %+v", usage)
+	}
+	if fake.requests[1].Messages[0].Cached != cached {
+		t.Error("continuation doesn't reuse the cached prefix")
+	}
+}
+
+// TestAttachFile tests uploading context once and reusing the upload while
+// it still exists
+func TestAttachFile(t *testing.T) {
+	uploads := 0
+	live := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("anthropic-beta") != filesAPIBeta {
+			t.Errorf("%s %s without the Files API beta header", r.Method, r.URL.Path)
+		}
+		switch {
+		case r.Method == "POST":
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("upload has no file: %v", err)
+				return
+			}
+			content, _ := io.ReadAll(file)
+			if header.Filename != "schema.sql" || string(content) != "CREATE TABLE t;" {
+				t.Errorf("uploaded %s = %q", header.Filename, content)
+			}
+			uploads++
+			id := fmt.Sprintf("file_%d", uploads)
+			live[id] = true
+			fmt.Fprintf(w, `{"id":%q,"type":"file"}`, id)
+		case live[strings.TrimPrefix(r.URL.Path, "/files/")]:
+			fmt.Fprint(w, `{}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cache := uploadCache{}
+	attach := func() (*claudeClient, bool) {
+		t.Helper()
+		client := newClaudeClient(Options{APIKey: "test", BaseURL: server.URL})
+		reused, err := client.AttachFile("db/schema.sql", []byte("CREATE TABLE t;"), cache)
+		if err != nil {
+			t.Fatalf("AttachFile() returned error: %v", err)
+		}
+		return client, reused
+	}
+
+	client, reused := attach()
+	if reused || uploads != 1 || len(client.documents) != 1 || client.documents[0].FileID != "file_1" {
+		t.Fatalf("first attach: reused=%v uploads=%d documents=%+v", reused, uploads, client.documents)
+	}
+	if _, reused = attach(); !reused || uploads != 1 {
+		t.Errorf("second attach: reused=%v uploads=%d, want the cached upload", reused, uploads)
+	}
+
+	// A file deleted on the server is uploaded again
+	delete(live, "file_1")
+	if client, reused = attach(); reused || uploads != 2 || client.documents[0].FileID != "file_2" {
+		t.Errorf("after deletion: reused=%v uploads=%d documents=%+v", reused, uploads, client.documents)
+	}
+	if len(client.withoutDocuments().documents) != 0 || len(client.documents) != 1 {
+		t.Error("withoutDocuments() should drop documents from the copy only")
+	}
+}
+
+// TestOptionsHeaders tests that configured headers extend the ones a request
+// needs, and that the user ID goes in the request metadata
+func TestOptionsHeaders(t *testing.T) {
+	var got http.Header
+	var body claudeRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		io.WriteString(w, `{"content": [{"type": "text", "text": "ok"}], "stop_reason": "end_turn"}`)
+	}))
+	defer server.Close()
+
+	headers := http.Header{}
+	headers.Set("anthropic-beta", "context-1m-2025-08-07")
+	client := newClaudeClient(Options{APIKey: "test", BaseURL: server.URL, Headers: headers, UserID: "team-ci"})
+	client.documents = []documentRef{{FileID: "file_1", Title: "schema.sql"}}
+	if _, _, err := client.Complete("Review this diff.", CompletionOptions{Model: "claude", MaxTokens: 100}); err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+	if want := filesAPIBeta + ", context-1m-2025-08-07"; got.Get("anthropic-beta") != want {
+		t.Errorf("anthropic-beta = %q, want %q", got.Get("anthropic-beta"), want)
+	}
+	if body.Metadata == nil || body.Metadata.UserID != "team-ci" {
+		t.Errorf("request metadata = %+v, want the user ID", body.Metadata)
+	}
+}
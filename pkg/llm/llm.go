@@ -0,0 +1,96 @@
+// Package llm defines the interface between the review engine and the
+// model providers it sends prompts to, and what providers share: token
+// usage, and joining a response cut off at the output limit with the rest
+package llm
+
+import "strings"
+
+// Provider is an LLM backend the review pipeline sends its prompts to
+type Provider interface {
+	// Name is the backend as shown in messages, e.g. "Claude"
+	Name() string
+
+	// Complete returns the model's response to prompt and the tokens used
+	Complete(prompt string, opts CompletionOptions) (string, Usage, error)
+}
+
+// CompletionOptions are the model settings of a completion
+type CompletionOptions struct {
+	Model string
+
+	// Thinking enables extended thinking (reasoning) with up to
+	// ThinkingBudget tokens, where the model supports it
+	Thinking       bool
+	ThinkingBudget int
+
+	MaxTokens int
+}
+
+// CacheBreakpoint ends the part of a prompt that is the same every time a
+// change is reviewed. Providers with explicit prompt caching cache the
+// prompt up to it; the others drop it.
+const CacheBreakpoint = "\n<!-- cache breakpoint -->\n"
+
+// WithoutCacheBreakpoint removes the cache breakpoint from a prompt
+func WithoutCacheBreakpoint(prompt string) string {
+	return strings.Replace(prompt, CacheBreakpoint, "\n", 1)
+}
+
+// Usage is the tokens a completion used
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+
+	// CacheCreationInputTokens and CacheReadInputTokens are prompt tokens
+	// written to and read from the prompt cache, on top of InputTokens
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+
+	// ThinkingTokens are the output tokens spent on reasoning, where the
+	// provider reports them; they are part of OutputTokens
+	ThinkingTokens int `json:"thinking_tokens,omitempty"`
+}
+
+// Add adds the tokens of another request to u
+func (u *Usage) Add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheCreationInputTokens += other.CacheCreationInputTokens
+	u.CacheReadInputTokens += other.CacheReadInputTokens
+	u.ThinkingTokens += other.ThinkingTokens
+}
+
+// Completion is one response of a model: its text, the tokens used, and
+// whether it was cut off at the output limit
+type Completion struct {
+	Text      string
+	Usage     Usage
+	Truncated bool
+}
+
+// ContinuePrompt asks the model to carry on from where its previous
+// response was cut off
+const ContinuePrompt = `Your previous response was cut off at the output limit. Continue it from the
+exact point where it stopped, even if that is mid-sentence, mid-word or
+inside a code block. Do not repeat anything you already wrote, do not
+summarize it, and do not add any preamble.`
+
+// Stitch looks for repeated text of between minStitchOverlap bytes, so
+// short coincidental matches like a shared word are kept, and
+// maxStitchOverlap bytes
+const (
+	minStitchOverlap = 8
+	maxStitchOverlap = 2000
+)
+
+// Stitch joins a response cut off at the output limit with its
+// continuation. Models sometimes restate the last few words or lines before
+// continuing, so the longest end of prev that next starts with is dropped.
+func Stitch(prev, next string) string {
+	for n := min(len(prev), len(next), maxStitchOverlap); n >= minStitchOverlap; n-- {
+		if strings.HasSuffix(prev, next[:n]) {
+			return prev + next[n:]
+		}
+	}
+	return prev + next
+}
@@ -0,0 +1,21 @@
+package llm
+
+import "testing"
+
+// TestStitch tests removing text the continuation repeats
+func TestStitch(t *testing.T) {
+	tests := []struct {
+		prev, next, want string
+	}{
+		{"The function leaks a goro", "utine on error.", "The function leaks a goroutine on error."},
+		{"## Issues\n\n1. Missing error check\n", "1. Missing error check\n2. Race", "## Issues\n\n1. Missing error check\n2. Race"},
+		// Short coincidental overlaps are kept
+		{"uses the ", "the cache", "uses the the cache"},
+		{"", "all new", "all new"},
+	}
+	for _, tt := range tests {
+		if got := Stitch(tt.prev, tt.next); got != tt.want {
+			t.Errorf("Stitch(%q, %q) = %q, want %q", tt.prev, tt.next, got, tt.want)
+		}
+	}
+}
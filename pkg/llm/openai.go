@@ -0,0 +1,221 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// openAIAPIURL is the default root of the OpenAI API; Options.BaseURL
+// replaces it for compatible servers
+const openAIAPIURL = "https://api.openai.com/v1"
+
+// openAIFinishLength is the finish_reason of a response cut off at the
+// output limit
+const openAIFinishLength = "length"
+
+// openAIClient calls the Chat Completions API of OpenAI or a compatible
+// server
+type openAIClient struct {
+	opts Options
+	url  string // API root, without a trailing slash; empty means OpenAI's
+
+	// compatible marks a server at Options.BaseURL rather than OpenAI, which
+	// is sent the output limit as max_tokens: compatible servers take that
+	// more widely than OpenAI's newer max_completion_tokens
+	compatible bool
+
+	// name and newRequest replace the provider's name and how requests are
+	// addressed and authenticated, for servers such as Azure OpenAI that
+	// take the same requests at other URLs
+	name       string
+	newRequest func(model string, body []byte) (*http.Request, error)
+}
+
+type openAIRequest struct {
+	Model               string          `json:"model"`
+	Messages            []openAIMessage `json:"messages"`
+	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
+	MaxTokens           int             `json:"max_tokens,omitempty"` // for compatible servers
+	ReasoningEffort     string          `json:"reasoning_effort,omitempty"`
+	User                string          `json:"user,omitempty"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+
+		CompletionTokensDetails struct {
+			ReasoningTokens int `json:"reasoning_tokens"`
+		} `json:"completion_tokens_details"`
+	} `json:"usage"`
+}
+
+// Name implements Provider
+func (c *openAIClient) Name() string {
+	if c.name != "" {
+		return c.name
+	}
+	return "OpenAI"
+}
+
+// openAIOutputLimits are the most output tokens OpenAI's models take, by
+// model name prefix, longest prefixes first; requests for more are rejected
+var openAIOutputLimits = []struct {
+	prefix string
+	limit  int
+}{
+	{"gpt-4o-2024-05-13", 4096},
+	{"gpt-4o", 16384},
+	{"gpt-4.1", 32768},
+	{"gpt-4-turbo", 4096},
+	{"gpt-4", 8192},
+	{"gpt-3.5", 4096},
+	{"gpt-5", 128000},
+	{"o1-mini", 65536},
+	{"o1", 100000},
+	{"o3", 100000},
+	{"o4", 100000},
+}
+
+// openAIMaxTokens caps maxTokens at model's output limit, if it is one of
+// OpenAI's models, so the default -max-tokens sized for Claude works too
+func openAIMaxTokens(model string, maxTokens int) int {
+	for _, m := range openAIOutputLimits {
+		if strings.HasPrefix(model, m.prefix) {
+			return min(maxTokens, m.limit)
+		}
+	}
+	return maxTokens
+}
+
+// isReasoningModel reports whether model takes a reasoning effort, as the
+// o-series and GPT-5 models do
+func isReasoningModel(model string) bool {
+	for _, prefix := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reasoningEffort maps the thinking settings onto a reasoning effort.
+// OpenAI doesn't take a token budget, so larger budgets ask for more effort.
+func reasoningEffort(opts CompletionOptions) string {
+	switch {
+	case !isReasoningModel(opts.Model):
+		return ""
+	case !opts.Thinking:
+		return "low"
+	case opts.ThinkingBudget <= 4096:
+		return "low"
+	case opts.ThinkingBudget <= 16384:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// Complete implements Provider. Models without reasoning ignore the
+// thinking settings.
+func (c *openAIClient) Complete(prompt string, opts CompletionOptions) (string, Usage, error) {
+	maxTokens := openAIMaxTokens(opts.Model, opts.MaxTokens)
+	req := openAIRequest{
+		Model:           opts.Model,
+		ReasoningEffort: reasoningEffort(opts),
+		User:            c.opts.UserID,
+	}
+	if c.compatible {
+		req.MaxTokens = maxTokens
+	} else {
+		req.MaxCompletionTokens = maxTokens
+	}
+	return c.opts.complete(opts.Model, prompt, maxTokens, func(turns []string) (*Completion, error) {
+		req.Messages = make([]openAIMessage, len(turns))
+		for i, turn := range turns {
+			// OpenAI caches long prompt prefixes by itself
+			req.Messages[i] = openAIMessage{Role: "user", Content: WithoutCacheBreakpoint(turn)}
+			if i%2 == 1 {
+				req.Messages[i].Role = "assistant"
+			}
+		}
+
+		resp, err := c.send(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("response has no choices")
+		}
+		choice := resp.Choices[0]
+		return &Completion{
+			Text: choice.Message.Content,
+			Usage: Usage{
+				InputTokens:    resp.Usage.PromptTokens,
+				OutputTokens:   resp.Usage.CompletionTokens,
+				ThinkingTokens: resp.Usage.CompletionTokensDetails.ReasoningTokens,
+			},
+			Truncated: choice.FinishReason == openAIFinishLength,
+		}, nil
+	})
+}
+
+// send makes one Chat Completions request
+func (c *openAIClient) send(req openAIRequest) (*openAIResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	newRequest := c.newRequest
+	if newRequest == nil {
+		newRequest = c.openAIRequest
+	}
+	httpReq, err := newRequest(req.Model, jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	body, status, err := c.opts.sendRecorded(httpReq, jsonData)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("API error from %s (status %d): %s", c.Name(), status, string(body))
+	}
+
+	var resp openAIResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("error unmarshaling response: %w", err)
+	}
+	return &resp, nil
+}
+
+// openAIRequest addresses a request to the OpenAI API, or the server at
+// c.url
+func (c *openAIClient) openAIRequest(model string, body []byte) (*http.Request, error) {
+	root := strings.TrimSuffix(c.url, "/")
+	if root == "" {
+		root = openAIAPIURL
+	}
+	req, err := http.NewRequest("POST", root+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.opts.APIKey)
+	return req, nil
+}
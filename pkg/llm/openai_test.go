@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestReasoningEffort tests mapping the thinking settings onto OpenAI's
+// reasoning effort
+func TestReasoningEffort(t *testing.T) {
+	tests := []struct {
+		opts CompletionOptions
+		want string
+	}{
+		{CompletionOptions{Model: "gpt-4o", Thinking: true, ThinkingBudget: 10000}, ""},
+		{CompletionOptions{Model: "o3", Thinking: false}, "low"},
+		{CompletionOptions{Model: "o4-mini", Thinking: true, ThinkingBudget: 2000}, "low"},
+		{CompletionOptions{Model: "gpt-5", Thinking: true, ThinkingBudget: 10000}, "medium"},
+		{CompletionOptions{Model: "o3", Thinking: true, ThinkingBudget: 32000}, "high"},
+	}
+	for _, tt := range tests {
+		if got := reasoningEffort(tt.opts); got != tt.want {
+			t.Errorf("reasoningEffort(%+v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+// TestOpenAIClient_Errors tests that API errors and empty responses are
+// reported
+func TestOpenAIClient_Errors(t *testing.T) {
+	status, body := http.StatusUnauthorized, `{"error": {"message": "Incorrect API key provided"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer test" {
+			t.Errorf("request to %s with Authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	client := &openAIClient{opts: Options{APIKey: "test"}, url: server.URL + "/v1/"}
+
+	if _, _, err := client.Complete("Review this diff.", CompletionOptions{Model: "gpt-4o", MaxTokens: 100}); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("Complete() error = %v, want the API error", err)
+	}
+	status, body = http.StatusOK, `{"choices": []}`
+	if _, _, err := client.Complete("Review this diff.", CompletionOptions{Model: "gpt-4o", MaxTokens: 100}); err == nil {
+		t.Error("Complete() of a response without choices expected error")
+	}
+}
+
+// TestOpenAIClient_MaxTokens tests that the output limit is capped at the
+// model's, and sent as max_tokens to compatible servers
+func TestOpenAIClient_MaxTokens(t *testing.T) {
+	var got openAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = openAIRequest{}
+		json.Unmarshal(body, &got)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "LGTM"}, "finish_reason": "stop"}]}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		model                    string
+		compatible               bool
+		maxTokens, maxCompletion int
+	}{
+		{"gpt-4o", false, 0, 16384},
+		{"gpt-4o-mini", false, 0, 16384},
+		{"gpt-4.1", false, 0, 32768},
+		{"o3", false, 0, 64000},
+		{"llama3", true, 64000, 0},
+		{"gpt-4o", true, 16384, 0},
+	}
+	for _, tt := range tests {
+		client := &openAIClient{opts: Options{APIKey: "test"}, url: server.URL, compatible: tt.compatible}
+		if _, _, err := client.Complete("Review this diff.", CompletionOptions{Model: tt.model, MaxTokens: 64000}); err != nil {
+			t.Fatalf("Complete() returned error: %v", err)
+		}
+		if got.MaxTokens != tt.maxTokens || got.MaxCompletionTokens != tt.maxCompletion {
+			t.Errorf("%s (compatible %v): max_tokens %d, max_completion_tokens %d; want %d, %d",
+				tt.model, tt.compatible, got.MaxTokens, got.MaxCompletionTokens, tt.maxTokens, tt.maxCompletion)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// retryBaseDelay is the backoff before the second attempt; it doubles
+	// with each attempt up to retryMaxDelay
+	retryBaseDelay = 2 * time.Second
+	retryMaxDelay  = time.Minute
+
+	// maxRetryAfter is the longest wait asked for by a server that is
+	// honored; a longer one fails the request rather than stalling the run
+	maxRetryAfter = 5 * time.Minute
+)
+
+// retryableStatus are the statuses of transient errors: rate limits,
+// server errors and gateway errors, and Anthropic's 529 overloaded
+var retryableStatus = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+	529:                            true,
+}
+
+// retrySleep waits between attempts (replaced in tests)
+var retrySleep = time.Sleep
+
+// doWithRetry sends an API request with the configured headers added,
+// trying it again up to MaxAttempts times in all while it fails with a
+// transient error. Failed attempts are recorded in the transcript; the last
+// response is returned with its body unread, along with when its attempt
+// started.
+func (o *Options) doWithRetry(client *http.Client, httpReq *http.Request, reqBody []byte) (*http.Response, time.Time, error) {
+	maxAttempts := o.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		req := httpReq
+		if attempt > 1 {
+			req = httpReq.Clone(httpReq.Context())
+			if httpReq.GetBody != nil {
+				body, err := httpReq.GetBody()
+				if err != nil {
+					return nil, time.Now(), fmt.Errorf("error rewinding request body: %w", err)
+				}
+				req.Body = body
+			}
+		}
+
+		if attempt == 1 {
+			addHeaders(req, o.Headers)
+		}
+		started := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, started, err
+		}
+		delay, retry := retryDelay(resp, attempt)
+		if !retry || attempt >= maxAttempts {
+			return resp, started, nil
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		o.record(req, reqBody, resp, body, started, err)
+		o.warnf("Warning: API returned status %d; retrying in %s (attempt %d/%d)...\n",
+			resp.StatusCode, delay.Round(100*time.Millisecond), attempt+1, maxAttempts)
+		retrySleep(delay)
+	}
+}
+
+// retryDelay returns how long to wait before trying a request again after
+// resp, and whether to try again at all. The server's retry-after-ms or
+// Retry-After header is honored; otherwise the backoff doubles with each
+// attempt, with jitter so concurrent runs don't retry in lockstep.
+func retryDelay(resp *http.Response, attempt int) (time.Duration, bool) {
+	switch resp.Header.Get("X-Should-Retry") {
+	case "false":
+		return 0, false
+	case "true":
+	default:
+		if !retryableStatus[resp.StatusCode] {
+			return 0, false
+		}
+	}
+
+	if ms, err := strconv.ParseFloat(resp.Header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		d := time.Duration(ms * float64(time.Millisecond))
+		return d, d <= maxRetryAfter
+	}
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if secs, err := strconv.ParseFloat(value, 64); err == nil && secs >= 0 {
+			d := time.Duration(secs * float64(time.Second))
+			return d, d <= maxRetryAfter
+		}
+		if when, err := http.ParseTime(value); err == nil {
+			d := max(time.Until(when), 0)
+			return d, d <= maxRetryAfter
+		}
+	}
+
+	backoff := retryMaxDelay
+	if attempt < 8 {
+		backoff = min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+	}
+	return backoff/2 + rand.N(backoff/2+1), true
+}
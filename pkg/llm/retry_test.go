@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder counts the exchanges recorded with it and keeps the last
+// response body
+type recorder struct {
+	exchanges int
+	lastBody  []byte
+}
+
+func (r *recorder) Record(_ *http.Request, _ []byte, _ *http.Response, respBody []byte, _ time.Time, _ error) {
+	r.exchanges++
+	r.lastBody = respBody
+}
+
+// fakeRetrySleep records the waits between attempts instead of sleeping
+func fakeRetrySleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	sleep := retrySleep
+	retrySleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { retrySleep = sleep })
+	return &waits
+}
+
+// TestSendRecorded_Retry tests that transient errors are retried with the
+// same body, honoring Retry-After, and that every attempt is recorded
+func TestSendRecorded_Retry(t *testing.T) {
+	waits := fakeRetrySleep(t)
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		switch len(bodies) {
+		case 1:
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(529)
+		default:
+			fmt.Fprint(w, `{"ok": true}`)
+		}
+	}))
+	defer server.Close()
+
+	log := &recorder{}
+	opts := Options{Transcript: log}
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader(`{"prompt": "x"}`))
+	body, status, err := opts.sendRecorded(req, []byte(`{"prompt": "x"}`))
+	if err != nil || status != http.StatusOK || string(body) != `{"ok": true}` {
+		t.Fatalf("sendRecorded() = %q, %d, %v", body, status, err)
+	}
+	for i, b := range bodies {
+		if b != `{"prompt": "x"}` {
+			t.Errorf("attempt %d sent body %q", i+1, b)
+		}
+	}
+	if len(*waits) != 2 || (*waits)[0] != 7*time.Second {
+		t.Errorf("waits = %v, want 7s then a backoff", *waits)
+	}
+	if log.exchanges != 3 {
+		t.Errorf("transcript has %d exchanges, want 3", log.exchanges)
+	}
+}
+
+// TestSendRecorded_RetryLimits tests that retries stop at MaxAttempts and
+// aren't made for errors that won't go away
+func TestSendRecorded_RetryLimits(t *testing.T) {
+	waits := fakeRetrySleep(t)
+	opts := Options{MaxAttempts: 3}
+
+	tests := []struct {
+		name   string
+		status int
+		header [2]string
+		want   int
+	}{
+		{"server error", http.StatusInternalServerError, [2]string{}, 3},
+		{"bad request", http.StatusBadRequest, [2]string{}, 1},
+		{"should not retry", http.StatusServiceUnavailable, [2]string{"X-Should-Retry", "false"}, 1},
+		{"retry-after too long", http.StatusTooManyRequests, [2]string{"Retry-After", "3600"}, 1},
+	}
+	for _, tt := range tests {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if tt.header[0] != "" {
+				w.Header().Set(tt.header[0], tt.header[1])
+			}
+			w.WriteHeader(tt.status)
+		}))
+		req, _ := http.NewRequest("POST", server.URL, strings.NewReader("{}"))
+		_, status, err := opts.sendRecorded(req, []byte("{}"))
+		if err != nil || status != tt.status || calls != tt.want {
+			t.Errorf("%s: sendRecorded() status %d, %v after %d attempts, want %d", tt.name, status, err, calls, tt.want)
+		}
+		server.Close()
+	}
+	for _, d := range *waits {
+		if d < retryBaseDelay/2 || d > retryMaxDelay {
+			t.Errorf("backoff %s out of range", d)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// streamTimeout bounds a streamed request, which runs for as long as
+	// the model writes rather than until the whole response is ready
+	streamTimeout = 30 * time.Minute
+
+	// thinkingDotBytes is how much thinking each progress dot stands for
+	thinkingDotBytes = 2000
+)
+
+// streamIdleError is returned when a stream stalls, as opposed to running
+// into its total timeout
+type streamIdleError struct {
+	idle time.Duration
+}
+
+func (e *streamIdleError) Error() string {
+	return fmt.Sprintf("stream stalled: nothing received for %s", e.idle)
+}
+
+// idleWatchdog reads a response body, cancelling the request if nothing
+// arrives for idle
+type idleWatchdog struct {
+	r     io.Reader
+	idle  time.Duration
+	timer *time.Timer
+	fired atomic.Bool
+}
+
+// watchIdle starts a watchdog on body that calls cancel once it has been
+// idle for idle
+func watchIdle(body io.Reader, idle time.Duration, cancel func()) *idleWatchdog {
+	w := &idleWatchdog{r: body, idle: idle}
+	w.timer = time.AfterFunc(idle, func() {
+		w.fired.Store(true)
+		cancel()
+	})
+	return w
+}
+
+// Read implements io.Reader
+func (w *idleWatchdog) Read(p []byte) (int, error) {
+	n, err := w.r.Read(p)
+	if err != nil && w.fired.Load() {
+		return n, &streamIdleError{idle: w.idle}
+	}
+	if n > 0 {
+		w.timer.Reset(w.idle)
+	}
+	return n, err
+}
+
+// stop stops the watchdog once the body has been read
+func (w *idleWatchdog) stop() {
+	w.timer.Stop()
+}
+
+// streamEvent is a server-sent event of the streaming Messages API
+type streamEvent struct {
+	Type         string          `json:"type"`
+	Index        int             `json:"index"`
+	Message      *claudeResponse `json:"message"`
+	ContentBlock *contentBlock   `json:"content_block"`
+	Delta        struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		Thinking   string `json:"thinking"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *Usage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// sendStreamed makes one streaming Messages API request, writing the text
+// to c.stream as it arrives, and returns the response it adds up to. The
+// request is cancelled if the stream stalls for StreamIdleTimeout; the API
+// sends ping events while the model is busy, so a silent stream has
+// stalled. The transcript records the whole event stream once it ends.
+func (c *claudeClient) sendStreamed(httpReq *http.Request, reqBody []byte) (*claudeResponse, error) {
+	idle := c.opts.StreamIdleTimeout
+	httpReq.Header.Set("x-api-key", c.opts.APIKey)
+	httpReq.Header.Set("anthropic-version", apiVersion)
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	client := &http.Client{Timeout: streamTimeout}
+	cancel := func() {}
+	if idle > 0 {
+		// Waiting for the headers counts as idle too
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = idle
+		client.Transport = transport
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(httpReq.Context())
+		defer cancel()
+		httpReq = httpReq.WithContext(ctx)
+	}
+	resp, started, err := c.opts.doWithRetry(client, httpReq, reqBody)
+	if err != nil {
+		c.opts.record(httpReq, reqBody, nil, nil, started, err)
+		return nil, fmt.Errorf("error making request: %w", streamError(err, idle))
+	}
+	defer resp.Body.Close()
+
+	var stream io.Reader = resp.Body
+	if idle > 0 {
+		watchdog := watchIdle(resp.Body, idle, cancel)
+		defer watchdog.stop()
+		stream = watchdog
+	}
+
+	var body bytes.Buffer
+	if resp.StatusCode != http.StatusOK {
+		_, err := body.ReadFrom(stream)
+		c.opts.record(httpReq, reqBody, resp, body.Bytes(), started, err)
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, body.String())
+	}
+	label := c.opts.ThinkingLabel
+	if label == "" {
+		label = "Thinking"
+	}
+	claudeResp, err := readStream(io.TeeReader(stream, &body), c.stream, label)
+	c.opts.record(httpReq, reqBody, resp, body.Bytes(), started, err)
+	if err != nil {
+		return nil, streamError(err, idle)
+	}
+	return claudeResp, nil
+}
+
+// streamError tells a stalled stream and one that ran into streamTimeout
+// apart from each other and from other failures, which a bare "context
+// deadline exceeded" or "context canceled" doesn't. idle is the idle
+// timeout the stream had.
+func streamError(err error, idle time.Duration) error {
+	var stalled *streamIdleError
+	switch {
+	case errors.As(err, &stalled):
+		return err
+	case strings.Contains(err.Error(), "timeout awaiting response headers"):
+		return fmt.Errorf("%w: %w", &streamIdleError{idle: idle}, err)
+	}
+	return timeoutError(err, streamTimeout)
+}
+
+// readStream adds up the events of a streamed response, writing text to out
+// as it arrives, and label and a dot for each stretch of extended thinking
+func readStream(r io.Reader, out io.Writer, label string) (*claudeResponse, error) {
+	resp := &claudeResponse{}
+	thinking := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var ev streamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+			return nil, fmt.Errorf("error parsing stream event: %w", err)
+		}
+
+		switch ev.Type {
+		case "message_start":
+			if ev.Message != nil {
+				resp = ev.Message
+				resp.Content = nil
+			}
+		case "content_block_start":
+			for len(resp.Content) <= ev.Index {
+				resp.Content = append(resp.Content, contentBlock{})
+			}
+			if ev.ContentBlock != nil {
+				resp.Content[ev.Index].Type = ev.ContentBlock.Type
+				if ev.ContentBlock.Type == "thinking" {
+					thinking = 0
+					fmt.Fprint(out, "💭 "+label)
+				}
+			}
+		case "content_block_delta":
+			if ev.Index >= len(resp.Content) {
+				continue
+			}
+			switch ev.Delta.Type {
+			case "text_delta":
+				resp.Content[ev.Index].Text += ev.Delta.Text
+				io.WriteString(out, ev.Delta.Text)
+			case "thinking_delta":
+				for range (thinking+len(ev.Delta.Thinking))/thinkingDotBytes - thinking/thinkingDotBytes {
+					io.WriteString(out, ".")
+				}
+				thinking += len(ev.Delta.Thinking)
+			}
+		case "content_block_stop":
+			if ev.Index < len(resp.Content) && resp.Content[ev.Index].Type == "thinking" {
+				io.WriteString(out, "\n\n")
+			}
+		case "message_delta":
+			if ev.Delta.StopReason != "" {
+				resp.StopReason = ev.Delta.StopReason
+			}
+			if ev.Usage != nil {
+				resp.Usage.OutputTokens = ev.Usage.OutputTokens
+				if ev.Usage.InputTokens > 0 {
+					resp.Usage.InputTokens = ev.Usage.InputTokens
+				}
+			}
+		case "error":
+			if ev.Error != nil {
+				return nil, fmt.Errorf("API error in stream (%s): %s", ev.Error.Type, ev.Error.Message)
+			}
+			return nil, fmt.Errorf("API error in stream: %s", data)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading stream: %w", err)
+	}
+	if resp.StopReason == "" {
+		return nil, fmt.Errorf("stream ended before the response was complete")
+	}
+	return resp, nil
+}
@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvents formats events as a server-sent event stream
+func sseEvents(events ...string) string {
+	var b strings.Builder
+	for _, ev := range events {
+		var typed struct{ Type string }
+		json.Unmarshal([]byte(ev), &typed)
+		fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", typed.Type, ev)
+	}
+	return b.String()
+}
+
+// TestClaudeClient_Stream tests that a streamed response is written out as
+// it arrives and adds up to the same completion
+func TestClaudeClient_Stream(t *testing.T) {
+	var gotStream bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req claudeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		gotStream = req.Stream
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sseEvents(
+			`{"type": "message_start", "message": {"id": "msg_1", "type": "message", "role": "assistant", "content": [], "usage": {"input_tokens": 50, "output_tokens": 1}}}`,
+			`{"type": "content_block_start", "index": 0, "content_block": {"type": "thinking", "thinking": ""}}`,
+			`{"type": "content_block_delta", "index": 0, "delta": {"type": "thinking_delta", "thinking": "`+strings.Repeat("x", 4500)+`"}}`,
+			`{"type": "content_block_stop", "index": 0}`,
+			`{"type": "ping"}`,
+			`{"type": "content_block_start", "index": 1, "content_block": {"type": "text", "text": ""}}`,
+			`{"type": "content_block_delta", "index": 1, "delta": {"type": "text_delta", "text": "Looks "}}`,
+			`{"type": "content_block_delta", "index": 1, "delta": {"type": "text_delta", "text": "good."}}`,
+			`{"type": "content_block_stop", "index": 1}`,
+			`{"type": "message_delta", "delta": {"stop_reason": "end_turn"}, "usage": {"output_tokens": 12}}`,
+			`{"type": "message_stop"}`,
+		))
+	}))
+	defer server.Close()
+
+	var out strings.Builder
+	log := &recorder{}
+	client := &claudeClient{opts: Options{APIKey: "test", Transcript: log}, url: server.URL, stream: &out}
+	text, usage, err := client.Complete("Review this diff.", CompletionOptions{Model: "claude", Thinking: true, ThinkingBudget: 1000, MaxTokens: 100})
+	if err != nil {
+		t.Fatalf("Complete() returned error: %v", err)
+	}
+	if !gotStream {
+		t.Error("request didn't ask for a stream")
+	}
+	if text != "Looks good." || usage != (Usage{InputTokens: 50, OutputTokens: 12}) {
+		t.Errorf("Complete() = %q, %+v", text, usage)
+	}
+	if got := out.String(); got != "💭 Thinking..\n\nLooks good." {
+		t.Errorf("streamed %q", got)
+	}
+	if log.exchanges != 1 || !strings.Contains(string(log.lastBody), "message_stop") {
+		t.Error("transcript doesn't have the event stream")
+	}
+}
+
+// TestClaudeClient_StreamErrors tests errors sent in and instead of a stream
+func TestClaudeClient_StreamErrors(t *testing.T) {
+	fakeRetrySleep(t)
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"status", http.StatusTooManyRequests, `{"type": "error"}`, "API error (status 429)"},
+		{"error event", http.StatusOK, sseEvents(`{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`), "overloaded_error"},
+		{"cut short", http.StatusOK, sseEvents(`{"type": "message_start", "message": {"usage": {"input_tokens": 5}}}`), "before the response was complete"},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		}))
+		client := &claudeClient{opts: Options{APIKey: "test"}, url: server.URL, stream: &strings.Builder{}}
+		_, _, err := client.Complete("Review this diff.", CompletionOptions{Model: "claude", MaxTokens: 100})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Complete() error = %v, want %q", tt.name, err, tt.want)
+		}
+		server.Close()
+	}
+}
+
+// TestClaudeClient_StreamStall tests that a stream that stops sending, before
+// or after its headers, is given up on and sent again without streaming
+func TestClaudeClient_StreamStall(t *testing.T) {
+	for _, beforeHeaders := range []bool{false, true} {
+		var streamed, unstreamed int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req claudeRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if !req.Stream {
+				unstreamed++
+				io.WriteString(w, `{"content": [{"type": "text", "text": "Looks good."}], "stop_reason": "end_turn", "usage": {"input_tokens": 50, "output_tokens": 12}}`)
+				return
+			}
+			streamed++
+			if !beforeHeaders {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, sseEvents(
+					`{"type": "message_start", "message": {"id": "msg_1", "type": "message", "role": "assistant", "content": [], "usage": {"input_tokens": 50, "output_tokens": 1}}}`,
+					`{"type": "content_block_start", "index": 0, "content_block": {"type": "text", "text": ""}}`,
+					`{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Looks "}}`,
+				))
+				w.(http.Flusher).Flush()
+			}
+			<-r.Context().Done()
+		}))
+
+		var out strings.Builder
+		client := &claudeClient{opts: Options{APIKey: "test", StreamIdleTimeout: 50 * time.Millisecond}, url: server.URL, stream: &out}
+		text, usage, err := client.Complete("Review this diff.", CompletionOptions{Model: "claude", MaxTokens: 100})
+		server.Close()
+		if err != nil {
+			t.Fatalf("before headers %v: Complete() returned error: %v", beforeHeaders, err)
+		}
+		if streamed != 1 || unstreamed != 1 {
+			t.Errorf("before headers %v: %d streamed and %d unstreamed requests, want 1 of each", beforeHeaders, streamed, unstreamed)
+		}
+		if text != "Looks good." || usage.OutputTokens != 12 {
+			t.Errorf("before headers %v: Complete() = %q, %+v", beforeHeaders, text, usage)
+		}
+		if !strings.HasSuffix(out.String(), "---\n\nLooks good.") {
+			t.Errorf("before headers %v: streamed %q, want the whole response at the end", beforeHeaders, out.String())
+		}
+	}
+}
+
+// TestStreamError tests telling a stalled stream from one that ran into its
+// total timeout
+func TestStreamError(t *testing.T) {
+	stalled := streamError(fmt.Errorf("error reading stream: %w", &streamIdleError{idle: time.Minute}), time.Minute)
+	if !strings.Contains(stalled.Error(), "stream stalled: nothing received for 1m0s") {
+		t.Errorf("streamError() of a stall = %v", stalled)
+	}
+	headers := streamError(errors.New(`Post "https://api": net/http: timeout awaiting response headers`), time.Minute)
+	var idle *streamIdleError
+	if !errors.As(headers, &idle) {
+		t.Errorf("streamError() of a header timeout = %v, want a stall", headers)
+	}
+	total := streamError(&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, time.Minute)
+	if errors.As(total, &idle) || !strings.Contains(total.Error(), "no complete response within 30m0s") {
+		t.Errorf("streamError() of the total timeout = %v", total)
+	}
+	if other := errors.New("connection reset"); streamError(other, time.Minute) != other {
+		t.Errorf("streamError() changed an unrelated error")
+	}
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"github.com/marete/pr-review/pkg/wiretest"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// runWireConformance replays the wiretest cases against a provider in
+// testdata/wire/<provider>, calling model. newClient is given the files a
+// case attaches, which providers without attachments ignore. Run
+// `go test -run Wire -update` to rewrite the golden files after an intended
+// change, and review the diff.
+func runWireConformance(t *testing.T, provider, model string, newClient func(url string, documents []documentRef) Provider) {
+	suite := wiretest.Suite{Dir: filepath.Join("testdata", "wire", provider), Update: *updateGolden}
+	suite.Run(t, func(url string, c wiretest.Case) (string, any, error) {
+		var documents []documentRef
+		for _, d := range c.Documents {
+			documents = append(documents, documentRef{FileID: d.FileID, Title: d.Title})
+		}
+		opts := CompletionOptions{Model: model, Thinking: c.Thinking, ThinkingBudget: c.ThinkingBudget, MaxTokens: c.MaxTokens}
+		return newClient(url, documents).Complete(c.Prompt, opts)
+	})
+}
+
+// TestWire_Anthropic checks the Messages API wire format
+func TestWire_Anthropic(t *testing.T) {
+	runWireConformance(t, "anthropic", "claude-sonnet-4-5-20250929", func(url string, documents []documentRef) Provider {
+		return &claudeClient{opts: Options{APIKey: "test", MaxContinuations: 1}, url: url, documents: documents}
+	})
+}
+
+// TestWire_Bedrock checks the Bedrock InvokeModel wire format
+func TestWire_Bedrock(t *testing.T) {
+	runWireConformance(t, "bedrock", "us.anthropic.claude-sonnet-4-5-20250929-v1:0", func(url string, _ []documentRef) Provider {
+		return &bedrockClient{opts: Options{MaxContinuations: 1}, creds: awsCredentials{AccessKeyID: "test", SecretAccessKey: "test"}, region: "us-east-1", url: url}
+	})
+}
+
+// TestWire_OpenAI checks the Chat Completions wire format, with a reasoning
+// model so the thinking settings are sent
+func TestWire_OpenAI(t *testing.T) {
+	runWireConformance(t, "openai", "o4-mini", func(url string, _ []documentRef) Provider {
+		return &openAIClient{opts: Options{APIKey: "test", MaxContinuations: 1}, url: url}
+	})
+}
+
+// TestWire_Azure checks the Azure OpenAI wire format: OpenAI's requests,
+// addressed to a deployment and API version
+func TestWire_Azure(t *testing.T) {
+	runWireConformance(t, "azure", "o4-mini", func(url string, _ []documentRef) Provider {
+		t.Setenv("AZURE_OPENAI_ENDPOINT", url)
+		t.Setenv("AZURE_OPENAI_API_KEY", "test")
+		t.Setenv("AZURE_OPENAI_API_VERSION", "")
+		client, err := newAzureOpenAIClient(Options{MaxContinuations: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	})
+}
@@ -0,0 +1,128 @@
+// Package prompt builds the prompt a model is asked to review a change
+// with: the review instructions, the change itself and its context, and the
+// sections of context the caller gathered about it
+package prompt
+
+import (
+	"github.com/marete/pr-review/pkg/llm"
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// Section is a titled block of tool-gathered context added to the prompt
+type Section struct {
+	Title string `yaml:"title"`
+	Body  string `yaml:"body"`
+}
+
+// Rubric is the full review instructions, ahead of the diff
+const Rubric = `You are an expert code reviewer. Please perform a thorough and comprehensive review of this Pull Request.
+
+Your review should cover:
+
+1. **Code Quality & Best Practices**
+   - Design patterns and architecture
+   - Code organization and structure
+   - Naming conventions and readability
+   - DRY principle adherence
+   - SOLID principles where applicable
+
+2. **Potential Issues**
+   - Bugs or logic errors
+   - Edge cases not handled
+   - Race conditions or concurrency issues
+   - Memory leaks or performance problems
+   - Security vulnerabilities
+
+3. **Testing**
+   - Test coverage adequacy
+   - Missing test cases
+   - Test quality and effectiveness
+
+4. **Performance**
+   - Algorithmic complexity
+   - Database query efficiency
+   - Resource usage (memory, CPU, network)
+   - Caching opportunities
+
+5. **Security**
+   - Input validation
+   - Authentication/authorization issues
+   - SQL injection, XSS, or other vulnerabilities
+   - Secrets or sensitive data exposure
+
+6. **Maintainability**
+   - Documentation quality
+   - Code complexity
+   - Technical debt introduced
+   - Future extensibility
+
+7. **Specific Suggestions**
+   - Concrete code improvements
+   - Alternative approaches
+   - Refactoring opportunities
+
+Please be thorough but constructive. Highlight both concerns and things done well.`
+
+// Review is what a review prompt is made of
+type Review struct {
+	// Rubric is the review instructions, ahead of the diff; empty means
+	// the full Rubric
+	Rubric string
+
+	Diff           string
+	ChangedFiles   string
+	CommitMessages string
+
+	// Context is further context for the review, e.g. the contents of
+	// related files
+	Context string
+
+	// Sections follow the change, after the cache breakpoint, so they can
+	// differ between reviews of the same change without losing the cache
+	Sections []Section
+
+	// Calibration, if set, tells the model which severities to give
+	// which issues
+	Calibration string
+}
+
+// Build returns the prompt of r, which asks for the findings and reviewer
+// checklist pkg/review reads from the response
+func Build(r Review) string {
+	rubric := r.Rubric
+	if rubric == "" {
+		rubric = Rubric
+	}
+	prompt := rubric + `
+
+---
+
+## Changed Files
+` + "```\n" + r.ChangedFiles + "\n```\n\n"
+
+	if r.CommitMessages != "" {
+		prompt += "## Recent Commit Messages\n```\n" + r.CommitMessages + "\n```\n\n"
+	}
+
+	prompt += "## Full Diff\n```diff\n" + r.Diff + "\n```\n"
+
+	if r.Context != "" {
+		prompt += "\n## Additional Context\n" + r.Context + "\n"
+	}
+
+	// Everything above stays the same when the change is reviewed again,
+	// with other checks or flags, so it can be cached
+	prompt += llm.CacheBreakpoint
+
+	for _, section := range r.Sections {
+		prompt += "\n## " + section.Title + "\n" + section.Body + "\n"
+	}
+
+	if r.Calibration != "" {
+		prompt += "\n" + r.Calibration
+	}
+
+	prompt += "\n\nPlease provide your comprehensive code review.\n\n" + review.ChecklistInstructions + "\n\n" + review.FindingsInstructions
+
+	return prompt
+}
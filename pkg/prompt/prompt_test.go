@@ -0,0 +1,45 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marete/pr-review/pkg/llm"
+	"github.com/marete/pr-review/pkg/review"
+)
+
+// TestBuild tests the layout of a review prompt: what stays the same
+// between reviews of a change ahead of the cache breakpoint, the rest after
+func TestBuild(t *testing.T) {
+	prompt := Build(Review{
+		Diff:           "+fix()",
+		ChangedFiles:   "a.go",
+		CommitMessages: "abc1234 - Fix a (Ann, 2026-10-16)",
+		Context:        "docs",
+		Sections:       []Section{{Title: "Benchmark Results", Body: "faster"}},
+		Calibration:    "## Severity Calibration\n",
+	})
+	cached, rest, ok := strings.Cut(prompt, llm.CacheBreakpoint)
+	if !ok {
+		t.Fatalf("prompt has no cache breakpoint:\n%s", prompt)
+	}
+	if !strings.HasPrefix(cached, Rubric) {
+		t.Error("prompt doesn't start with the full rubric")
+	}
+	for _, want := range []string{"## Changed Files\n```\na.go\n```", "## Recent Commit Messages", "```diff\n+fix()\n```", "## Additional Context\ndocs"} {
+		if !strings.Contains(cached, want) {
+			t.Errorf("cached part doesn't contain %q:\n%s", want, cached)
+		}
+	}
+	for _, want := range []string{"## Benchmark Results\nfaster", "## Severity Calibration", review.ChecklistInstructions, review.FindingsInstructions} {
+		if !strings.Contains(rest, want) {
+			t.Errorf("part after the breakpoint doesn't contain %q:\n%s", want, rest)
+		}
+	}
+
+	// A rubric replaces the full one, and an empty commit log is left out
+	prompt = Build(Review{Rubric: "Check that behavior is unchanged.", Diff: "+x"})
+	if !strings.HasPrefix(prompt, "Check that behavior is unchanged.") || strings.Contains(prompt, "Code Quality") || strings.Contains(prompt, "Recent Commit Messages") {
+		t.Errorf("prompt with a rubric:\n%s", prompt)
+	}
+}
@@ -0,0 +1,55 @@
+package review
+
+import (
+	"strings"
+)
+
+// ChecklistStartTag and ChecklistEndTag enclose the reviewer checklist in a
+// response
+const (
+	ChecklistStartTag = "<checklist>"
+	ChecklistEndTag   = "</checklist>"
+)
+
+// ChecklistInstructions asks the model for a checklist of things a human
+// reviewer should verify that the diff alone can't show
+const ChecklistInstructions = `After your review, write a checklist for the human reviewer enclosed in
+` + ChecklistStartTag + ` and ` + ChecklistEndTag + ` tags: one markdown task item ("- [ ] ...") per
+thing they should verify outside the diff, specific to this change (e.g.
+"Verify the new orders.customer_id index exists in staging", "Confirm the
+new_checkout feature flag defaults to off"). Skip generic advice such as
+"run the tests". Output an empty section if there is nothing to verify.`
+
+// ExtractChecklist splits the reviewer checklist from a review, returning
+// the review without it and the checklist items. Bullets are accepted with
+// or without task boxes; a checked box is kept unchecked, since nothing has
+// been verified yet.
+func ExtractChecklist(review string) (string, []string) {
+	start := strings.LastIndex(review, ChecklistStartTag)
+	if start == -1 {
+		return review, nil
+	}
+	end := strings.Index(review[start:], ChecklistEndTag)
+	if end == -1 {
+		return review, nil
+	}
+	end += start
+
+	var items []string
+	for _, line := range strings.Split(review[start+len(ChecklistStartTag):end], "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "- ") && !strings.HasPrefix(line, "* ") {
+			continue
+		}
+		item := strings.TrimSpace(line[2:])
+		for _, box := range []string{"[ ]", "[x]", "[X]"} {
+			item = strings.TrimSpace(strings.TrimPrefix(item, box))
+		}
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	rest := strings.TrimSpace(review[:start] + review[end+len(ChecklistEndTag):])
+	return rest, items
+}
@@ -0,0 +1,46 @@
+package review
+
+import "testing"
+
+// TestExtractChecklist tests splitting the reviewer checklist from a review
+func TestExtractChecklist(t *testing.T) {
+	review := `The change looks good.
+
+<checklist>
+- [ ] Verify the new orders.customer_id index exists in staging
+- Confirm the new_checkout flag defaults to off
+* [x] Check the migration runs before the deploy
+
+</checklist>
+
+More prose.`
+
+	rest, items := ExtractChecklist(review)
+	want := []string{
+		"Verify the new orders.customer_id index exists in staging",
+		"Confirm the new_checkout flag defaults to off",
+		"Check the migration runs before the deploy",
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items %q, want %d", len(items), items, len(want))
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("item %d = %q, want %q", i, items[i], want[i])
+		}
+	}
+	if rest != "The change looks good.\n\n\n\nMore prose." {
+		t.Errorf("rest = %q", rest)
+	}
+}
+
+// TestExtractChecklist_Missing tests that a review without a checklist is
+// returned unchanged
+func TestExtractChecklist_Missing(t *testing.T) {
+	for _, review := range []string{"No checklist here.", "Unterminated <checklist>\n- [ ] item"} {
+		rest, items := ExtractChecklist(review)
+		if rest != review || items != nil {
+			t.Errorf("ExtractChecklist(%q) = %q, %q", review, rest, items)
+		}
+	}
+}
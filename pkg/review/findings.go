@@ -0,0 +1,277 @@
+// Package review runs code reviews with a model provider and holds their
+// findings model: the structured issues a model reports along with its
+// prose review, how it is asked for them, and how they are read back from
+// its response
+package review
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Severity ranks how serious a finding is, from informational to critical.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"info", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+	if s < SeverityInfo || s > SeverityCritical {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity converts a severity name (case-insensitive) into a Severity
+func ParseSeverity(name string) (Severity, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q (want one of: %s)", name, strings.Join(severityNames, ", "))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// Finding is a single structured issue reported by the model
+type Finding struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+	Severity Severity `json:"severity"`
+	Category string   `json:"category"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+
+	// Suggestion is a proposed fix, if the model has one
+	Suggestion string `json:"suggestion,omitempty"`
+
+	// Replacement is code to replace lines Line to EndLine (or just Line) of
+	// the new file with, when the fix is confined to them
+	EndLine     int    `json:"end_line,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+
+	// Patch is a unified diff against the new version of the code fixing
+	// the issue, for fixes a Replacement can't express
+	Patch string `json:"patch,omitempty"`
+
+	// Evidence quotes the lines of the diff the finding is based on.
+	// Ungrounded is set if they aren't in the diff.
+	Evidence   string `json:"evidence,omitempty"`
+	Ungrounded bool   `json:"ungrounded,omitempty"`
+
+	// CriticalPath is set when the finding is in a file matching the
+	// repository's critical_paths; its severity has been escalated
+	CriticalPath bool `json:"critical_path,omitempty"`
+
+	// Baselined is set when the finding is in the repository's baseline of
+	// known findings; it is left out of the report and the gate
+	Baselined bool `json:"baselined,omitempty"`
+
+	// EscalatedBy describes the severity rule that raised the finding's
+	// severity, if one did
+	EscalatedBy string `json:"escalated_by,omitempty"`
+
+	// Commit is the commit in the reviewed range that introduced the
+	// finding's lines, when there are several to choose from
+	Commit        string `json:"commit,omitempty"`
+	CommitSubject string `json:"commit_subject,omitempty"`
+
+	// RaisedBy lists the models that raised the finding when several
+	// reviewed the change. Confidence is "low" if only some of them did,
+	// and Disagreement suggests why they might differ.
+	RaisedBy     []string `json:"raised_by,omitempty"`
+	Confidence   string   `json:"confidence,omitempty"`
+	Disagreement string   `json:"disagreement,omitempty"`
+}
+
+// IntroducedIn describes the commit that introduced the finding
+func (f Finding) IntroducedIn() string {
+	sha := f.Commit
+	if len(sha) > 12 {
+		sha = sha[:12]
+	}
+	s := "`" + sha + "`"
+	if f.CommitSubject != "" {
+		s += " " + f.CommitSubject
+	}
+	return s
+}
+
+// FindingsStartTag and FindingsEndTag enclose the findings in a response
+const (
+	FindingsStartTag = "<findings>"
+	FindingsEndTag   = "</findings>"
+)
+
+// FindingsInstructions asks the model to append a machine-readable findings
+// section after the prose review
+const FindingsInstructions = `After your review, list every concrete issue you raised as a JSON array
+enclosed in ` + FindingsStartTag + ` and ` + FindingsEndTag + ` tags. Each element must have the
+fields "file", "line" (0 if unknown), "severity" (one of: info, low, medium,
+high, critical), "category", "title", "message", "suggestion" (a concrete
+fix, or "" if you have none), "evidence" (the line or lines of the diff that
+show the issue, copied exactly, or "" for issues about something the diff
+lacks), "end_line" (the last line the issue spans, 0 if it is one line) and
+"replacement": if the fix only changes lines "line" to "end_line" of the new
+version of the file, the complete code that should replace exactly those
+lines, correctly indented, otherwise "", and "patch": if the fix needs
+changes elsewhere (several places, or other files), a unified diff against
+the new version of the code that applies it, with ---, +++ and @@ headers,
+otherwise "". Output an empty array if there are no issues.`
+
+// ExtractFindings splits a model response into the prose review and the
+// structured findings section. If the response has no findings section the
+// prose is returned unchanged with no findings.
+func ExtractFindings(response string) (string, []Finding, error) {
+	start := strings.LastIndex(response, FindingsStartTag)
+	if start == -1 {
+		return response, nil, nil
+	}
+	end := strings.Index(response[start:], FindingsEndTag)
+	if end == -1 {
+		return response, nil, fmt.Errorf("findings section is not terminated by %s", FindingsEndTag)
+	}
+	end += start
+
+	body := strings.TrimSpace(response[start+len(FindingsStartTag) : end])
+	body = strings.TrimPrefix(body, "```json")
+	body = strings.TrimPrefix(body, "```")
+	body = strings.TrimSuffix(body, "```")
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &elements); err != nil {
+		return response, nil, fmt.Errorf("error parsing findings: %w", err)
+	}
+
+	// A finding that doesn't parse or validate is dropped rather than
+	// losing the others with it
+	findings := []Finding{}
+	var problems []string
+	for i, element := range elements {
+		var f Finding
+		err := json.Unmarshal(element, &f)
+		if err == nil {
+			err = f.Validate()
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("#%d: %v", i+1, err))
+			continue
+		}
+		findings = append(findings, f)
+	}
+	if len(findings) == 0 && len(problems) > 0 {
+		// Nothing usable: the prose keeps the section so no issue is lost
+		return response, nil, fmt.Errorf("no valid findings (%s)", strings.Join(problems, "; "))
+	}
+
+	prose := strings.TrimSpace(response[:start] + response[end+len(FindingsEndTag):])
+	if len(problems) > 0 {
+		dropped := "1 invalid finding"
+		if len(problems) > 1 {
+			dropped = fmt.Sprintf("%d invalid findings", len(problems))
+		}
+		return prose, findings, fmt.Errorf("dropped %s (%s)", dropped, strings.Join(problems, "; "))
+	}
+	return prose, findings, nil
+}
+
+// Validate checks a finding parsed from the model's response and repairs
+// what it can: its path is normalized, and a line range or patch that
+// doesn't hold together is left out rather than the whole finding
+func (f *Finding) Validate() error {
+	f.Title = strings.TrimSpace(f.Title)
+	if f.Title == "" {
+		return fmt.Errorf("no title")
+	}
+	if f.Line < 0 {
+		return fmt.Errorf("invalid line %d", f.Line)
+	}
+	f.File = NormalizePath(f.File)
+	if f.EndLine != 0 && (f.Line == 0 || f.EndLine < f.Line) {
+		// A replacement only makes sense for the lines it replaces
+		f.EndLine, f.Replacement = 0, ""
+	}
+	if f.Replacement != "" && (f.File == "" || f.Line == 0) {
+		f.Replacement = ""
+	}
+	if f.Patch != "" && !IsUnifiedDiff(f.Patch) {
+		f.Patch = ""
+	}
+	return nil
+}
+
+// IsUnifiedDiff reports whether patch is a unified diff with at least one
+// hunk that changes something
+func IsUnifiedDiff(patch string) bool {
+	hunk, changed := false, false
+	for _, line := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@ "):
+			hunk = true
+		case hunk && (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")) &&
+			!strings.HasPrefix(line, "+++ ") && !strings.HasPrefix(line, "--- "):
+			changed = true
+		}
+	}
+	return hunk && changed
+}
+
+// NormalizePath converts a path reported by the model to the
+// slash-separated, repository-relative form git uses, so findings match diff
+// paths whichever separator the model used
+func NormalizePath(p string) string {
+	p = strings.ReplaceAll(strings.TrimSpace(p), "\\", "/")
+	return strings.TrimPrefix(p, "./")
+}
+
+// Location returns the file:line reference of a finding, or "" if unknown
+func (f Finding) Location() string {
+	if f.File == "" {
+		return ""
+	}
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return f.File
+}
+
+// Fingerprint identifies a finding across reviews: its file, category, and
+// the diff lines it quotes, or its title if it quotes none. Line numbers are
+// left out, as they move when code above the finding changes.
+func (f Finding) Fingerprint() string {
+	text := strings.Join(strings.Fields(strings.ToLower(f.Title)), " ")
+	if f.Evidence != "" {
+		var lines []string
+		for _, line := range strings.Split(f.Evidence, "\n") {
+			if line = strings.Join(strings.Fields(line), " "); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		text = strings.Join(lines, "\n")
+	}
+	sum := sha256.Sum256([]byte(f.File + "\x00" + strings.ToLower(f.Category) + "\x00" + text))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,128 @@
+package review
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseSeverity tests parsing severity names
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Severity
+		wantErr bool
+	}{
+		{"info", SeverityInfo, false},
+		{"LOW", SeverityLow, false},
+		{" medium ", SeverityMedium, false},
+		{"high", SeverityHigh, false},
+		{"critical", SeverityCritical, false},
+		{"blocker", SeverityInfo, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSeverity(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSeverity(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSeverity(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestExtractFindings tests splitting a response into prose and findings
+func TestExtractFindings(t *testing.T) {
+	response := "# Review\n\nLooks mostly good.\n\n<findings>\n```json\n" +
+		`[{"file": "main.go", "line": 42, "severity": "high", "category": "bug", "title": "Nil dereference", "message": "resp may be nil"}]` +
+		"\n```\n</findings>\n"
+
+	prose, findings, err := ExtractFindings(response)
+	if err != nil {
+		t.Fatalf("ExtractFindings() returned error: %v", err)
+	}
+	if prose != "# Review\n\nLooks mostly good." {
+		t.Errorf("prose = %q", prose)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("got %d findings, want 1", len(findings))
+	}
+	f := findings[0]
+	if f.File != "main.go" || f.Line != 42 || f.Severity != SeverityHigh || f.Title != "Nil dereference" {
+		t.Errorf("unexpected finding: %+v", f)
+	}
+}
+
+// TestExtractFindings_NoSection tests that responses without findings are returned unchanged
+func TestExtractFindings_NoSection(t *testing.T) {
+	response := "# Review\n\nNo structured section here."
+
+	prose, findings, err := ExtractFindings(response)
+	if err != nil {
+		t.Fatalf("ExtractFindings() returned error: %v", err)
+	}
+	if prose != response {
+		t.Errorf("prose = %q, want %q", prose, response)
+	}
+	if findings != nil {
+		t.Errorf("findings = %v, want nil", findings)
+	}
+}
+
+// TestExtractFindings_Invalid tests that malformed findings keep the full response
+func TestExtractFindings_Invalid(t *testing.T) {
+	response := "Review\n<findings>[{\"severity\": \"catastrophic\"}]</findings>"
+
+	prose, _, err := ExtractFindings(response)
+	if err == nil {
+		t.Fatal("ExtractFindings() expected error for invalid severity")
+	}
+	if prose != response {
+		t.Errorf("prose = %q, want full response on error", prose)
+	}
+}
+
+// TestNormalizeFindingPath tests mapping model-reported paths to diff paths
+func TestNormalizeFindingPath(t *testing.T) {
+	tests := map[string]string{
+		`pkg\server\handler.go`: "pkg/server/handler.go",
+		"./main.go":             "main.go",
+		" cmd/tool/main.go ":    "cmd/tool/main.go",
+		"":                      "",
+	}
+	for in, want := range tests {
+		if got := NormalizePath(in); got != want {
+			t.Errorf("NormalizePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestExtractFindings_Validate tests dropping findings that don't validate
+// while keeping the rest, and repairing what can be repaired
+func TestExtractFindings_Validate(t *testing.T) {
+	response := "Review\n<findings>[" +
+		`{"file": "./db/query.go", "line": 12, "end_line": 10, "severity": "high", "title": "SQL injection", "replacement": "x"},` +
+		`{"file": "api.go", "severity": "urgent", "title": "Unknown severity"},` +
+		`{"file": "api.go", "severity": "low", "title": " "},` +
+		`{"file": "api.go", "line": 3, "severity": "medium", "title": "Leaked handle", "patch": "--- a/api.go\n+++ b/api.go\n@@ -3 +3,2 @@\n f := open()\n+defer f.Close()\n"},` +
+		`{"file": "api.go", "line": 4, "severity": "low", "title": "Bad patch", "patch": "add a defer"}` +
+		"]</findings>"
+
+	prose, findings, err := ExtractFindings(response)
+	if err == nil || !strings.Contains(err.Error(), "dropped 2 invalid findings") || !strings.Contains(err.Error(), "#3: no title") {
+		t.Errorf("ExtractFindings() error = %v", err)
+	}
+	if prose != "Review" {
+		t.Errorf("prose = %q", prose)
+	}
+	if len(findings) != 3 {
+		t.Fatalf("got %d findings, want 3: %+v", len(findings), findings)
+	}
+	if f := findings[0]; f.File != "db/query.go" || f.EndLine != 0 || f.Replacement != "" {
+		t.Errorf("finding with an inverted range = %+v", f)
+	}
+	if findings[1].Patch == "" || findings[2].Patch != "" {
+		t.Errorf("patches = %q, %q; want only the unified diff kept", findings[1].Patch, findings[2].Patch)
+	}
+}
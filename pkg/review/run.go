@@ -0,0 +1,74 @@
+package review
+
+import (
+	"sync"
+
+	"github.com/marete/pr-review/pkg/llm"
+)
+
+// Result is a review read back from a model's response
+type Result struct {
+	// Model wrote the review
+	Model string
+
+	// Response is the model's whole response
+	Response string
+
+	// Review is the prose, without the findings and checklist sections
+	Review    string
+	Findings  []Finding
+	Checklist []string
+
+	// FindingsErr says why the findings section, or some of its findings,
+	// could not be read. The rest of the review is still usable.
+	FindingsErr error
+
+	Usage llm.Usage
+
+	// Err is why the review failed, in the results of RunModels
+	Err error
+}
+
+// Parse splits a response into the prose review, its findings and the
+// reviewer checklist
+func Parse(response string) *Result {
+	r := &Result{Response: response}
+	r.Review, r.Findings, r.FindingsErr = ExtractFindings(response)
+	r.Review, r.Checklist = ExtractChecklist(r.Review)
+	return r
+}
+
+// Run sends a review prompt, e.g. one made with prompt.Build, to the
+// provider and parses its response. The error is the provider's; a
+// findings section that can't be read is reported in Result.FindingsErr.
+func Run(p llm.Provider, prompt string, opts llm.CompletionOptions) (*Result, error) {
+	response, usage, err := p.Complete(prompt, opts)
+	if err != nil {
+		return nil, err
+	}
+	r := Parse(response)
+	r.Model, r.Usage = opts.Model, usage
+	return r, nil
+}
+
+// RunModels runs the same review with each model at once. A model that
+// fails has its error in its result rather than failing the rest.
+func RunModels(p llm.Provider, prompt string, opts llm.CompletionOptions, models []string) []*Result {
+	results := make([]*Result, len(models))
+	var wg sync.WaitGroup
+	for i, model := range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			modelOpts := opts
+			modelOpts.Model = model
+			r, err := Run(p, prompt, modelOpts)
+			if err != nil {
+				r = &Result{Model: model, Err: err}
+			}
+			results[i] = r
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,71 @@
+package review
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/marete/pr-review/pkg/llm"
+)
+
+// fakeProvider answers with a canned response per model
+type fakeProvider map[string]string
+
+func (p fakeProvider) Name() string { return "fake" }
+
+func (p fakeProvider) Complete(prompt string, opts llm.CompletionOptions) (string, llm.Usage, error) {
+	response, ok := p[opts.Model]
+	if !ok {
+		return "", llm.Usage{}, errors.New("unknown model")
+	}
+	return response, llm.Usage{InputTokens: len(prompt), OutputTokens: len(response)}, nil
+}
+
+const fakeReview = `Looks fine.
+
+<findings>
+[{"severity": "high", "category": "bug", "file": "a.go", "line": 3, "title": "Nil map write", "description": "m is nil"}]
+</findings>
+
+<checklist>
+- [ ] Check the migration ran
+</checklist>`
+
+// TestRun tests sending a review and reading back its parts
+func TestRun(t *testing.T) {
+	r, err := Run(fakeProvider{"m": fakeReview}, "Review this diff.", llm.CompletionOptions{Model: "m"})
+	if err != nil {
+		t.Fatalf("Run() returned error: %v", err)
+	}
+	if r.Review != "Looks fine." || len(r.Findings) != 1 || r.Findings[0].Title != "Nil map write" || len(r.Checklist) != 1 || r.FindingsErr != nil {
+		t.Errorf("Run() = %+v", r)
+	}
+	if r.Model != "m" || r.Response != fakeReview || r.Usage.InputTokens != len("Review this diff.") {
+		t.Errorf("Run() = %+v", r)
+	}
+
+	// A findings section that can't be read leaves the prose usable
+	if r := Parse("Looks fine.\n\n<findings>\nnot json\n</findings>"); r.FindingsErr == nil || r.Findings != nil {
+		t.Errorf("Parse() of broken findings = %+v", r)
+	}
+
+	if _, err := Run(fakeProvider{}, "Review this diff.", llm.CompletionOptions{Model: "m"}); err == nil {
+		t.Error("Run() with a failing provider returned no error")
+	}
+}
+
+// TestRunModels tests that a model that fails doesn't fail the others
+func TestRunModels(t *testing.T) {
+	results := RunModels(fakeProvider{"a": fakeReview, "c": "No issues."}, "Review this diff.", llm.CompletionOptions{}, []string{"a", "b", "c"})
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if results[0].Model != "a" || results[0].Err != nil || len(results[0].Findings) != 1 {
+		t.Errorf("result of a = %+v", results[0])
+	}
+	if results[1].Model != "b" || results[1].Err == nil {
+		t.Errorf("result of b = %+v, want its error", results[1])
+	}
+	if results[2].Model != "c" || results[2].Review != "No issues." {
+		t.Errorf("result of c = %+v", results[2])
+	}
+}
@@ -0,0 +1,213 @@
+// Package wiretest checks a model provider's wire format against golden
+// files: canned responses are replayed to the provider's client, and the
+// requests it makes and what it makes of the responses are compared with
+// recorded ones, so a change to serialization can't slip through unnoticed.
+// pr-review's own providers are tested with it, and a provider maintained
+// elsewhere can use it the same way.
+package wiretest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Document is a file a request attaches, by the ID the provider's file
+// API gave it
+type Document struct {
+	FileID string
+	Title  string
+}
+
+// Case is a call a provider client makes
+type Case struct {
+	Name           string
+	Prompt         string
+	Thinking       bool
+	ThinkingBudget int
+	MaxTokens      int
+	Documents      []Document
+}
+
+// Cases cover every request shape pr-review sends: a plain completion, one
+// with extended thinking, one attaching a document, and one whose response
+// is cut off at the token limit, which the client continues
+var Cases = []Case{
+	{Name: "plain", Prompt: "Review this diff.", MaxTokens: 8000},
+	{Name: "thinking", Prompt: "Review this diff.", Thinking: true, ThinkingBudget: 10000, MaxTokens: 64000},
+	{Name: "documents", Prompt: "Review against the attached schema.", MaxTokens: 8000,
+		Documents: []Document{{FileID: "file_011CNha8iCJcU1wXNR6q4V8w", Title: "schema.sql"}}},
+	{Name: "continuation", Prompt: "Review this diff.", MaxTokens: 100},
+}
+
+// Headers are the request headers that are part of the wire protocol;
+// credentials and transport headers are left out of the golden files
+var Headers = []string{"Content-Type", "Anthropic-Version", "Anthropic-Beta"}
+
+// Exchange is a request recorded in a golden file. Path is the request's
+// path and query, where the provider addresses the model or API version,
+// unless it is "/".
+type Exchange struct {
+	Path    string            `json:"path,omitempty"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// Result is what a client made of the responses
+type Result struct {
+	Text  string `json:"text"`
+	Usage any    `json:"usage"`
+}
+
+// Call makes the request of c to the server at url with the client under
+// test, returning the text and token usage it parsed from the responses
+type Call func(url string, c Case) (text string, usage any, err error)
+
+// Suite is the golden files of a provider in Dir: for each case,
+// <case>.responses.json holds the responses the server returns in order, and
+// the requests made and the parsed result are compared with
+// <case>.requests.golden.json and <case>.result.golden.json. With Update
+// set, the golden files are rewritten instead; review their diff.
+type Suite struct {
+	Dir    string
+	Update bool
+
+	// Cases and Headers default to the package's
+	Cases   []Case
+	Headers []string
+}
+
+// Run replays each case against call, as a subtest
+func (s Suite) Run(t *testing.T, call Call) {
+	cases := s.Cases
+	if cases == nil {
+		cases = Cases
+	}
+	headers := s.Headers
+	if headers == nil {
+		headers = Headers
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			responses, err := LoadResponses(filepath.Join(s.Dir, c.Name+".responses.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := NewServer(responses, headers)
+			defer server.Close()
+
+			text, usage, err := call(server.URL, c)
+			if err != nil {
+				t.Fatalf("%s: call returned error: %v", c.Name, err)
+			}
+			exchanges, err := server.Exchanges()
+			if err != nil {
+				t.Error(err)
+			}
+			CompareGolden(t, filepath.Join(s.Dir, c.Name+".requests.golden.json"), exchanges, s.Update)
+			CompareGolden(t, filepath.Join(s.Dir, c.Name+".result.golden.json"), Result{Text: text, Usage: usage}, s.Update)
+		})
+	}
+}
+
+// LoadResponses reads a fixture of responses: a JSON array of the bodies a
+// server returns in order
+func LoadResponses(path string) ([]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var responses []json.RawMessage
+	if err := json.Unmarshal(data, &responses); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return responses, nil
+}
+
+// Server is a test server returning canned responses in order and
+// recording the requests it is sent
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	headers   []string
+	responses []json.RawMessage
+	exchanges []Exchange
+	err       error
+}
+
+// NewServer starts a server returning responses in order, recording the
+// given headers of each request. Once they run out it answers 400.
+func NewServer(responses []json.RawMessage, headers []string) *Server {
+	s := &Server{headers: headers, responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	ex := Exchange{Headers: map[string]string{}, Body: json.RawMessage(`null`)}
+	if uri := r.URL.RequestURI(); uri != "/" {
+		ex.Path = uri
+	}
+	var indented bytes.Buffer
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := json.Indent(&indented, body, "", "  "); err == nil {
+		ex.Body = indented.Bytes()
+	} else if s.err == nil {
+		s.err = fmt.Errorf("request body is not JSON: %w", err)
+	}
+	for _, h := range s.headers {
+		if v := r.Header.Get(h); v != "" {
+			ex.Headers[h] = v
+		}
+	}
+	s.exchanges = append(s.exchanges, ex)
+	if len(s.responses) == 0 {
+		http.Error(w, "no more responses", http.StatusBadRequest)
+		return
+	}
+	w.Write(s.responses[0])
+	s.responses = s.responses[1:]
+}
+
+// Exchanges returns the requests made so far, and an error if a body wasn't
+// JSON
+func (s *Server) Exchanges() ([]Exchange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Exchange(nil), s.exchanges...), s.err
+}
+
+// CompareGolden compares v, as indented JSON, with the golden file at
+// path, or with update set rewrites the file
+func CompareGolden(t testing.TB, path string, v any, update bool) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	got = append(got, '\n')
+
+	if update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file (rewrite it with the suite's Update set to create it): %v", err)
+	}
+	if !bytes.Equal(got, bytes.ReplaceAll(want, []byte("\r\n"), []byte("\n"))) {
+		t.Errorf("%s differs from the golden file.\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package wiretest
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// echoCall is a client sending the prompt and returning the response's text
+func echoCall(url string, c Case) (string, any, error) {
+	body, _ := json.Marshal(map[string]any{"prompt": c.Prompt, "max_tokens": c.MaxTokens})
+	resp, err := http.Post(url, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Text string `json:"text"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	return out.Text, map[string]int{"output_tokens": len(out.Text)}, err
+}
+
+// TestSuite tests recording golden files and then checking a client
+// against them
+func TestSuite(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "plain.responses.json"), []byte(`[{"text": "Looks good."}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []Case{{Name: "plain", Prompt: "Review this diff.", MaxTokens: 100}}
+
+	// The first run records, the second checks against the recording
+	Suite{Dir: dir, Update: true, Cases: cases}.Run(t, echoCall)
+	Suite{Dir: dir, Cases: cases}.Run(t, echoCall)
+
+	data, err := os.ReadFile(filepath.Join(dir, "plain.requests.golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exchanges []Exchange
+	if err := json.Unmarshal(data, &exchanges); err != nil || len(exchanges) != 1 {
+		t.Fatalf("requests golden file = %s, %v", data, err)
+	}
+	if exchanges[0].Headers["Content-Type"] != "application/json" || !strings.Contains(string(exchanges[0].Body), `"prompt": "Review this diff."`) {
+		t.Errorf("recorded request = %+v", exchanges[0])
+	}
+	if result, _ := os.ReadFile(filepath.Join(dir, "plain.result.golden.json")); !strings.Contains(string(result), `"text": "Looks good."`) {
+		t.Errorf("result golden file = %s", result)
+	}
+}
+
+// TestServer_RunsOut tests that a server out of responses refuses further
+// requests
+func TestServer_RunsOut(t *testing.T) {
+	s := NewServer(nil, Headers)
+	defer s.Close()
+	resp, err := http.Post(s.URL, "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+	if exchanges, err := s.Exchanges(); len(exchanges) != 1 || err != nil {
+		t.Errorf("Exchanges() = %+v, %v", exchanges, err)
+	}
+}